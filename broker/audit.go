@@ -0,0 +1,41 @@
+package broker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SebastienDorgan/gpac/providers"
+)
+
+// broker audit list --since=24h --resource=vm1
+
+//AuditContainerEnv environment variable selecting the object container storing the audit records, its value is
+//<tenant>/<container>. Records are appended to <dir>/audit.log if it is not set
+const AuditContainerEnv = "BROKER_AUDIT_CONTAINER"
+
+//Audit returns the sink of the audit records of the mutating operations performed by the clients of the tenants
+func (srv *TenantService) Audit() (providers.AuditSink, error) {
+	srv.auditMu.Lock()
+	defer srv.auditMu.Unlock()
+	if srv.audit != nil {
+		return srv.audit, nil
+	}
+	spec := os.Getenv(AuditContainerEnv)
+	if spec == "" {
+		srv.audit = providers.NewFileAuditSink(filepath.Join(srv.dir, "audit.log"))
+		return srv.audit, nil
+	}
+	parts := strings.SplitN(spec, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("Error opening audit log: invalid %s %s, expected <tenant>/<container>", AuditContainerEnv, spec)
+	}
+	//the records are written with a client which is not audited itself
+	clt, _, err := srv.providerClient(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("Error opening audit log: %s", err.Error())
+	}
+	srv.audit = providers.NewContainerAuditSink(clt, parts[1])
+	return srv.audit, nil
+}
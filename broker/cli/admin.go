@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/providers"
+)
+
+var adminCmd = cli.Command{
+	Name:  "admin",
+	Usage: "administration of the broker",
+	Subcommands: []cli.Command{
+		{
+			Name:  "migrate-metadata",
+			Usage: "rewrite the records of the metadata store of the tenant at the current schema version",
+			Flags: withOutput(
+				cli.BoolFlag{Name: "dry-run", Usage: "only list the records to migrate"},
+			),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 0); err != nil {
+					return err
+				}
+				srv, t, err := tenant(c)
+				if err != nil {
+					return err
+				}
+				report, err := srv.MigrateMetadata(t.Name, c.Bool("dry-run"))
+				if err != nil {
+					return fail(err)
+				}
+				return outputReport(c, report)
+			},
+		},
+		{
+			Name:  "rotate-key",
+			Usage: "generate a new master key for the tenant and re-encrypt the private keys of the VMs with it, private keys stored in clear are encrypted, the tenant must not be added with --plaintext-metadata",
+			Flags: withOutput(),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 0); err != nil {
+					return err
+				}
+				srv, t, err := tenant(c)
+				if err != nil {
+					return err
+				}
+				report, err := srv.RotateKey(t.Name)
+				if err != nil {
+					return fail(err)
+				}
+				return outputReport(c, report)
+			},
+		},
+	},
+}
+
+//outputReport prints report, the command fails if records could not be rewritten
+func outputReport(c *cli.Context, report *providers.MigrationReport) error {
+	err := output(c, report)
+	if err != nil {
+		return err
+	}
+	if len(report.Failed) > 0 {
+		return cli.NewExitError("", exitError)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker"
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/openstack"
+	"github.com/SebastienDorgan/gpac/providers/openstack/fake"
+)
+
+//captureStdout returns what run writes on the standard output and the error it returns
+func captureStdout(t *testing.T, run func() error) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = run()
+	os.Stdout = stdout
+	w.Close()
+	out, rerr := ioutil.ReadAll(r)
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	return string(out), err
+}
+
+//runBroker runs the broker with args on the configuration directory dir and returns its standard output
+func runBroker(t *testing.T, dir string, args ...string) (string, error) {
+	app := newApp()
+	app.ExitErrHandler = func(c *cli.Context, err error) {}
+	return captureStdout(t, func() error {
+		return app.Run(append([]string{"broker", "--config-dir", dir}, args...))
+	})
+}
+
+//addOpenStackTenant adds the tenant name of the fake cloud srv storing its metadata in a bolt database
+func addOpenStackTenant(t *testing.T, dir string, name string, srv *fake.Server, plaintext bool) broker.TenantAPI {
+	t.Setenv(broker.VaultPassphraseEnv, "passphrase")
+	tenants := broker.NewTenantService(dir)
+	auth := srv.AuthOptions()
+	_, err := tenants.Add(name, "openstack", map[string]interface{}{
+		"IdentityEndpoint": auth.IdentityEndpoint,
+		"Username":         auth.Username,
+		"Password":         auth.Password,
+		"TenantID":         auth.TenantID,
+		"Region":           auth.Region,
+		"ProviderNetwork":  srv.CfgOptions().ProviderNetwork,
+	}, broker.TenantDefaults{
+		Metadata: providers.MetadataOptions{Type: providers.MetadataBolt, Plaintext: plaintext},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tenants
+}
+
+func TestMigrateMetadataCommand(t *testing.T) {
+	srv := fake.NewServer(fake.Options{})
+	defer srv.Close()
+	dir := t.TempDir()
+	//the legacy record would be converted by the encryption of the private keys when the first client is created
+	addOpenStackTenant(t, dir, "os1", srv, true)
+
+	//VM definition written with gob before records existed
+	legacy, err := ioutil.ReadFile(filepath.Join("..", "..", "providers", "testdata", "legacy_vm.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := providers.NewBoltMetadataStore(filepath.Join(dir, "metadata", "os1.db"))
+	_, err = store.Put(openstack.VMContainer, "vm1", legacy)
+	store.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrate := func(args ...string) providers.MigrationReport {
+		out, err := runBroker(t, dir, append([]string{"--tenant", "os1", "admin", "migrate-metadata", "--output", "json"}, args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := providers.MigrationReport{}
+		err = json.Unmarshal([]byte(out), &report)
+		if err != nil {
+			t.Fatalf("invalid output %q: %s", out, err.Error())
+		}
+		return report
+	}
+	report := migrate("--dry-run")
+	if len(report.Migrated) != 1 || report.Migrated[0] != openstack.VMContainer+"/vm1" {
+		t.Fatalf("expected the VM record to be listed, got %+v", report)
+	}
+	report = migrate()
+	if len(report.Migrated) != 1 || len(report.Failed) != 0 {
+		t.Fatalf("expected the VM record to be migrated, got %+v", report)
+	}
+	report = migrate("--dry-run")
+	if len(report.Migrated) != 0 || report.Current != 1 {
+		t.Fatalf("expected the VM record to be current, got %+v", report)
+	}
+}
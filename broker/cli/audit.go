@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/providers"
+)
+
+//parseSince parses a duration before now (24h) or a time (2006-01-02 or RFC 3339)
+func parseSince(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid --since %s, expected a duration (24h), a date (2006-01-02) or a RFC 3339 time", s)
+}
+
+var auditCmd = cli.Command{
+	Name:  "audit",
+	Usage: "query the audit log of the mutating operations",
+	Subcommands: []cli.Command{
+		{
+			Name:  "list",
+			Usage: "list audit records, records of the tenant given with --tenant or of all tenants",
+			Flags: withOutput(
+				cli.StringFlag{Name: "since", Usage: "only records younger than a duration (24h) or more recent than a date (2006-01-02) or a RFC 3339 time"},
+				cli.StringFlag{Name: "resource", Usage: "only records of the resource with this ID or name"},
+			),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 0); err != nil {
+					return err
+				}
+				since, err := parseSince(c.String("since"))
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitUsage)
+				}
+				srv, err := tenants(c)
+				if err != nil {
+					return err
+				}
+				sink, err := srv.Audit()
+				if err != nil {
+					return fail(err)
+				}
+				list, err := sink.List(providers.AuditFilter{
+					Since:    since,
+					Resource: c.String("resource"),
+					Tenant:   c.GlobalString("tenant"),
+				})
+				if err != nil {
+					return fail(err)
+				}
+				if c.String("output") != outputTable {
+					return output(c, list)
+				}
+				//request payloads are displayed in json and yaml
+				type row struct {
+					Time      time.Time `json:"time"`
+					User      string    `json:"user"`
+					Tenant    string    `json:"tenant"`
+					Operation string    `json:"operation"`
+					Resource  string    `json:"resource"`
+					Outcome   string    `json:"outcome"`
+					Duration  string    `json:"duration"`
+					Error     string    `json:"error"`
+				}
+				rows := []row{}
+				for _, r := range list {
+					resource := r.ResourceType + " " + r.ResourceName
+					if r.ResourceID != "" && r.ResourceID != r.ResourceName {
+						resource += " " + r.ResourceID
+					}
+					user := r.User
+					if r.ClaimedUser != "" && r.ClaimedUser != r.User {
+						user += " (claims " + r.ClaimedUser + ")"
+					}
+					rows = append(rows, row{r.Time, user, r.Tenant, r.Operation, resource, r.Outcome, r.Duration.Round(time.Millisecond).String(), r.Error})
+				}
+				return output(c, rows)
+			},
+		},
+	},
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker"
+)
+
+//containerService returns the container service of the selected tenant
+func containerService(c *cli.Context) (broker.ContainerAPI, error) {
+	clt, _, err := client(c)
+	if err != nil {
+		return nil, err
+	}
+	return broker.NewContainerService(clt), nil
+}
+
+var containerCmd = cli.Command{
+	Name:  "container",
+	Usage: "manage object containers",
+	Subcommands: []cli.Command{
+		{
+			Name:      "create",
+			Usage:     "create a container",
+			ArgsUsage: "<name>",
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := containerService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Create(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "list",
+			Usage: "list containers",
+			Flags: outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 0); err != nil {
+					return err
+				}
+				srv, err := containerService(c)
+				if err != nil {
+					return err
+				}
+				list, err := srv.List()
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, list)
+			},
+		},
+		{
+			Name:      "inspect",
+			Usage:     "show a container and its objects",
+			ArgsUsage: "<name>",
+			Flags:     outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := containerService(c)
+				if err != nil {
+					return err
+				}
+				ct, err := srv.Inspect(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, ct)
+			},
+		},
+		{
+			Name:      "mount",
+			Usage:     "mount a container on a VM with s3ql",
+			ArgsUsage: "<container> <vm>",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "path", Usage: "mount point (default /containers/<container>)"},
+			},
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 2); err != nil {
+					return err
+				}
+				srv, err := containerService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Mount(c.Args().Get(0), c.Args().Get(1), c.String("path"))
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "umount",
+			Usage:     "unmount a container from a VM",
+			ArgsUsage: "<container> <vm>",
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 2); err != nil {
+					return err
+				}
+				srv, err := containerService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Umount(c.Args().Get(0), c.Args().Get(1))
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "delete a container",
+			ArgsUsage: "<name>",
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := containerService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Delete(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+	},
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/urfave/cli"
+)
+
+var gcCmd = cli.Command{
+	Name:  "gc",
+	Usage: "delete the resources created by gpac and leaked by failed operations (public IPs, temporary key pairs, security groups, networks, subnets and volumes without bookkeeping data, bookkeeping data of deleted resources), --dry-run only lists them",
+	Flags: withOutput(
+		cli.BoolFlag{Name: "dry-run", Usage: "only list the orphaned resources"},
+		cli.DurationFlag{Name: "min-age", Value: providers.DefaultGCMinAge, Usage: "resources created for less than min-age are not collected"},
+		cli.BoolFlag{Name: "unknown-age", Usage: "also collect the orphaned resources whose creation time is unknown, they may be in use by a running operation"},
+		cli.BoolFlag{Name: "force", Usage: "delete the orphans even if jobs of the tenant are running"},
+	),
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, 0); err != nil {
+			return err
+		}
+		srv, t, err := tenant(c)
+		if err != nil {
+			return err
+		}
+		dryRun := c.Bool("dry-run")
+		if !dryRun && !c.Bool("force") {
+			js, err := jobs(c)
+			if err != nil {
+				return err
+			}
+			list, err := js.List()
+			if err != nil {
+				return fail(err)
+			}
+			var running []string
+			for _, j := range list {
+				if j.Tenant == t.Name && !j.State.Done() {
+					running = append(running, j.ID)
+				}
+			}
+			if len(running) > 0 {
+				return cli.NewExitError(fmt.Sprintf("Jobs of tenant %s are running (%s), wait for them or use --force", t.Name, strings.Join(running, ", ")), exitError)
+			}
+		}
+		report, err := srv.CollectGarbage(t.Name, providers.GCOptions{
+			Delete:     !dryRun,
+			MinAge:     c.Duration("min-age"),
+			UnknownAge: c.Bool("unknown-age"),
+		})
+		if err != nil {
+			return fail(err)
+		}
+		//the table lists the orphans, the counts are given by json and yaml
+		if c.String("output") == outputTable {
+			err = output(c, report.Orphans)
+		} else {
+			err = output(c, report)
+		}
+		if err != nil {
+			return err
+		}
+		if dryRun && len(report.Orphans) > 0 {
+			fmt.Fprintf(os.Stderr, "%d orphaned resources listed, run without --dry-run to delete them\n", len(report.Orphans))
+		}
+		if report.Unknown > 0 && !c.Bool("unknown-age") {
+			fmt.Fprintf(os.Stderr, "%d orphaned resources of unknown age skipped, use --unknown-age to collect them\n", report.Unknown)
+		}
+		if report.Failed > 0 {
+			return cli.NewExitError("", exitError)
+		}
+		return nil
+	},
+}
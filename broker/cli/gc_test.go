@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/openstack"
+	"github.com/SebastienDorgan/gpac/providers/openstack/fake"
+)
+
+func TestGCCommand(t *testing.T) {
+	srv := fake.NewServer(fake.Options{})
+	defer srv.Close()
+	dir := t.TempDir()
+	addOpenStackTenant(t, dir, "os1", srv, true)
+
+	//record of a VM deleted two hours ago
+	b, err := json.Marshal(providers.Record{
+		Schema:  providers.RecordSchemaVersion,
+		Kind:    providers.RecordVM,
+		Data:    json.RawMessage(`{"id": "deleted-vm"}`),
+		Written: time.Now().Add(-2 * time.Hour).UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := providers.NewBoltMetadataStore(filepath.Join(dir, "metadata", "os1.db"))
+	defer store.Close()
+	_, err = store.Put(openstack.VMContainer, "deleted-vm", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := providers.GCReport{}
+	runJSON(t, dir, &report, "--tenant", "os1", "gc", "--dry-run")
+	if len(report.Orphans) != 1 || report.Deleted != 0 {
+		t.Fatalf("expected the VM record to be listed, got %+v", report)
+	}
+	_, _, err = store.Get(openstack.VMContainer, "deleted-vm")
+	if err != nil {
+		t.Fatalf("record deleted by a dry run: %v", err)
+	}
+
+	//the orphans are deleted by default
+	report = providers.GCReport{}
+	runJSON(t, dir, &report, "--tenant", "os1", "gc")
+	if len(report.Orphans) != 1 || report.Deleted != 1 {
+		t.Fatalf("expected the VM record to be deleted, got %+v", report)
+	}
+	_, _, err = store.Get(openstack.VMContainer, "deleted-vm")
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
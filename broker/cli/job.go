@@ -0,0 +1,123 @@
+package main
+
+import (
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker"
+)
+
+var jobCmd = cli.Command{
+	Name:  "job",
+	Usage: "manage jobs submitted with --async, jobs are executed by broker serve",
+	Subcommands: []cli.Command{
+		{
+			Name:  "list",
+			Usage: "list jobs",
+			Flags: outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 0); err != nil {
+					return err
+				}
+				srv, err := jobs(c)
+				if err != nil {
+					return err
+				}
+				list, err := srv.List()
+				if err != nil {
+					return fail(err)
+				}
+				if c.String("output") != outputTable {
+					return output(c, list)
+				}
+				//args, steps and results are displayed by job get
+				type row struct {
+					ID        string          `json:"id"`
+					Tenant    string          `json:"tenant"`
+					Operation string          `json:"operation"`
+					State     broker.JobState `json:"state"`
+					Created   time.Time       `json:"created"`
+					Error     string          `json:"error"`
+				}
+				rows := []row{}
+				for _, j := range list {
+					rows = append(rows, row{j.ID, j.Tenant, j.Operation, j.State, j.Created, j.Error})
+				}
+				return output(c, rows)
+			},
+		},
+		{
+			Name:      "get",
+			Usage:     "show a job",
+			ArgsUsage: "<id>",
+			Flags:     outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := jobs(c)
+				if err != nil {
+					return err
+				}
+				job, err := srv.Get(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, job)
+			},
+		},
+		{
+			Name:      "wait",
+			Usage:     "wait for a job to be done and show it, the command fails if the job does not succeed",
+			ArgsUsage: "<id>",
+			Flags: withOutput(
+				cli.DurationFlag{Name: "timeout", Value: 10 * time.Minute, Usage: "maximum waiting time"},
+			),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := jobs(c)
+				if err != nil {
+					return err
+				}
+				job, err := srv.Wait(c.Args().First(), c.Duration("timeout"))
+				if err != nil {
+					return fail(err)
+				}
+				err = output(c, job)
+				if err != nil {
+					return err
+				}
+				switch {
+				case !job.State.Done():
+					return cli.NewExitError("Timeout waiting for job "+job.ID, exitError)
+				case job.State != broker.JobSucceeded:
+					return cli.NewExitError("Job "+job.ID+" "+string(job.State), exitError)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "cancel",
+			Usage:     "cancel a job",
+			ArgsUsage: "<id>",
+			Flags:     outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := jobs(c)
+				if err != nil {
+					return err
+				}
+				job, err := srv.Cancel(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, job)
+			},
+		},
+	},
+}
@@ -44,4 +44,243 @@ broker nas mount nas1 vm2 --path="/data"
 broker nas umount nas1 vm2
 broker nas list
 broker nas inspect nas1
+
+broker network create net2 --async (la commande affiche le job exécuté par broker serve)
+broker job list
+broker job wait <id> --timeout=10m
+broker job cancel <id>
+
+broker audit list --since=24h --resource=vm1
+broker admin migrate-metadata --dry-run
+broker admin rotate-key
+broker gc --dry-run
 */
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker"
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+
+	//drivers register themselves in the provider registry
+	_ "github.com/SebastienDorgan/gpac/providers/aws"
+	_ "github.com/SebastienDorgan/gpac/providers/cloudwatt"
+	_ "github.com/SebastienDorgan/gpac/providers/memory"
+	_ "github.com/SebastienDorgan/gpac/providers/openstack"
+	_ "github.com/SebastienDorgan/gpac/providers/ovh"
+)
+
+//Exit codes
+const (
+	//exitError a command failed
+	exitError = 1
+	//exitUsage the command line is invalid
+	exitUsage = 2
+)
+
+//fail converts err in an error making the broker exit with exitError
+func fail(err error) error {
+	return cli.NewExitError(err.Error(), exitError)
+}
+
+//checkArgs checks the number of positional arguments and the output format of the command
+func checkArgs(c *cli.Context, n int) error {
+	if c.NArg() != n {
+		return cli.NewExitError(fmt.Sprintf("Invalid arguments, usage: broker %s %s", c.Command.FullName(), c.Command.ArgsUsage), exitUsage)
+	}
+	return checkOutput(c)
+}
+
+//configDir returns the configuration directory of the broker
+func configDir(c *cli.Context) (string, error) {
+	dir := c.GlobalString("config-dir")
+	if dir == "" {
+		d, err := broker.DefaultConfigDir()
+		if err != nil {
+			return "", fail(err)
+		}
+		dir = d
+	}
+	return dir, nil
+}
+
+//tenants returns the tenant service of the configuration directory
+func tenants(c *cli.Context) (broker.TenantAPI, error) {
+	dir, err := configDir(c)
+	if err != nil {
+		return nil, err
+	}
+	return broker.NewTenantService(dir), nil
+}
+
+//jobs returns the job service of the configuration directory
+func jobs(c *cli.Context) (*broker.JobService, error) {
+	dir, err := configDir(c)
+	if err != nil {
+		return nil, err
+	}
+	return broker.NewJobService(dir), nil
+}
+
+//asyncFlag flag of the commands which can be executed by a job
+var asyncFlag = cli.BoolFlag{
+	Name:  "async",
+	Usage: "submit a job executed by broker serve and print it instead of waiting for the operation",
+}
+
+//submit submits a job executing operation on the selected tenant and prints it
+func submit(c *cli.Context, t *broker.Tenant, operation string, args interface{}) error {
+	srv, err := jobs(c)
+	if err != nil {
+		return err
+	}
+	job, err := srv.Submit(t.Name, operation, args)
+	if err != nil {
+		return fail(err)
+	}
+	return output(c, job)
+}
+
+//tenant returns the tenant selected with --tenant or the current tenant
+func tenant(c *cli.Context) (broker.TenantAPI, *broker.Tenant, error) {
+	srv, err := tenants(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	var t *broker.Tenant
+	if name := c.GlobalString("tenant"); name != "" {
+		t, err = srv.Get(name)
+	} else {
+		t, err = srv.Current()
+	}
+	if err != nil {
+		return nil, nil, fail(err)
+	}
+	return srv, t, nil
+}
+
+//client returns a client of the selected tenant and its defaults
+func client(c *cli.Context) (api.ClientAPI, *broker.TenantDefaults, error) {
+	srv, t, err := tenant(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	clt, err := srv.Client(t.Name)
+	if err != nil {
+		return nil, nil, fail(err)
+	}
+	return clt, &t.Defaults, nil
+}
+
+//tracing sets the exporter of the traces of the provider calls
+//Spans are never written to stdout, which holds the output of the commands
+func tracing(c *cli.Context) error {
+	if c.GlobalString("trace-file") != "" {
+		if c.GlobalString("trace") != "" {
+			return cli.NewExitError("--trace and --trace-file are exclusive", exitUsage)
+		}
+		f, err := os.OpenFile(c.GlobalString("trace-file"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("Error opening trace file: %s", err.Error()), exitError)
+		}
+		providers.DefaultTracer.SetExporter(providers.NewJSONExporter(f))
+		return nil
+	}
+	switch c.GlobalString("trace") {
+	case "":
+	case "stderr":
+		providers.DefaultTracer.SetExporter(providers.NewJSONExporter(os.Stderr))
+	case "otlp":
+		providers.DefaultTracer.SetExporter(providers.NewOTLPExporter(c.GlobalString("otlp-endpoint"), "gpac-broker"))
+	default:
+		return cli.NewExitError(fmt.Sprintf("Invalid --trace %s, expected stderr or otlp", c.GlobalString("trace")), exitUsage)
+	}
+	return nil
+}
+
+//sizingFlags flags selecting the size and the OS of a VM
+var sizingFlags = []cli.Flag{
+	cli.IntFlag{Name: "cpu", Usage: "minimum number of cores"},
+	cli.Float64Flag{Name: "ram", Usage: "minimum RAM size in GB"},
+	cli.IntFlag{Name: "disk", Usage: "minimum disk size in GB"},
+	cli.StringFlag{Name: "os", Usage: "operating system"},
+}
+
+//sizing returns the sizing and the OS given on the command line, defaults of the tenant are used for missing flags
+func sizing(c *cli.Context, defaults *broker.TenantDefaults) (int, float32, int, string) {
+	return defaults.Resolve(c.Int("cpu"), float32(c.Float64("ram")), c.Int("disk"), c.String("os"))
+}
+
+//newApp returns the broker command line application
+func newApp() *cli.App {
+	app := cli.NewApp()
+	app.Name = "broker"
+	app.Usage = "manage infrastructures of cloud providers"
+	app.Version = "0.1.0"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "config-dir",
+			Usage:  "broker configuration directory (default ~/.config/gpac/broker)",
+			EnvVar: broker.ConfigDirEnv,
+		},
+		cli.StringFlag{
+			Name:   "tenant",
+			Usage:  "tenant used instead of the current tenant",
+			EnvVar: "BROKER_TENANT",
+		},
+		cli.StringFlag{
+			Name:   "trace",
+			Usage:  "export the traces of the provider calls to stderr or to an OpenTelemetry collector (otlp)",
+			EnvVar: "BROKER_TRACE",
+		},
+		cli.StringFlag{
+			Name:   "trace-file",
+			Usage:  "append the traces of the provider calls to a file, one span in JSON per line",
+			EnvVar: "BROKER_TRACE_FILE",
+		},
+		cli.StringFlag{
+			Name:   "otlp-endpoint",
+			Value:  "http://localhost:4318",
+			Usage:  "URL of the OpenTelemetry collector receiving the traces with --trace=otlp",
+			EnvVar: "OTEL_EXPORTER_OTLP_ENDPOINT",
+		},
+	}
+	app.Before = tracing
+	app.After = func(c *cli.Context) error {
+		providers.DefaultTracer.Flush()
+		return nil
+	}
+	//commands exit on error before app.After is called
+	app.ExitErrHandler = func(c *cli.Context, err error) {
+		providers.DefaultTracer.Flush()
+		cli.HandleExitCoder(err)
+	}
+	app.Commands = []cli.Command{
+		providerCmd,
+		tenantCmd,
+		networkCmd,
+		vmCmd,
+		sshCmd,
+		volumeCmd,
+		containerCmd,
+		nasCmd,
+		jobCmd,
+		auditCmd,
+		adminCmd,
+		gcCmd,
+		serveCmd,
+	}
+	return app
+}
+
+func main() {
+	//errors implementing cli.ExitCoder make Run exit, other errors are usage errors already reported by Run
+	err := newApp().Run(os.Args)
+	if err != nil {
+		os.Exit(exitUsage)
+	}
+}
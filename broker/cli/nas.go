@@ -0,0 +1,144 @@
+package main
+
+import (
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker"
+)
+
+//nasService returns the NAS service of the selected tenant
+func nasService(c *cli.Context) (broker.NasAPI, error) {
+	clt, _, err := client(c)
+	if err != nil {
+		return nil, err
+	}
+	return broker.NewNasService(clt), nil
+}
+
+var nasCmd = cli.Command{
+	Name:  "nas",
+	Usage: "manage NFS shares exported by VMs",
+	Subcommands: []cli.Command{
+		{
+			Name:      "create",
+			Usage:     "export a directory of a VM with NFS",
+			ArgsUsage: "<name> <vm>",
+			Flags: withOutput(
+				cli.StringFlag{Name: "path", Usage: "exported directory (default /shared/<name>)"},
+			),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 2); err != nil {
+					return err
+				}
+				srv, err := nasService(c)
+				if err != nil {
+					return err
+				}
+				nas, err := srv.Create(c.Args().Get(0), c.Args().Get(1), c.String("path"))
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, nas)
+			},
+		},
+		{
+			Name:  "list",
+			Usage: "list NAS",
+			Flags: outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 0); err != nil {
+					return err
+				}
+				srv, err := nasService(c)
+				if err != nil {
+					return err
+				}
+				list, err := srv.List()
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, list)
+			},
+		},
+		{
+			Name:      "inspect",
+			Usage:     "show a NAS",
+			ArgsUsage: "<name>",
+			Flags:     outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := nasService(c)
+				if err != nil {
+					return err
+				}
+				nas, err := srv.Inspect(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, nas)
+			},
+		},
+		{
+			Name:      "mount",
+			Usage:     "mount a NAS on a VM",
+			ArgsUsage: "<name> <vm>",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "path", Usage: "mount point (default /shared/<name>)"},
+			},
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 2); err != nil {
+					return err
+				}
+				srv, err := nasService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Mount(c.Args().Get(0), c.Args().Get(1), c.String("path"))
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "umount",
+			Usage:     "unmount a NAS from a VM",
+			ArgsUsage: "<name> <vm>",
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 2); err != nil {
+					return err
+				}
+				srv, err := nasService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Umount(c.Args().Get(0), c.Args().Get(1))
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "delete a NAS, it must not be mounted",
+			ArgsUsage: "<name>",
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := nasService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Delete(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+	},
+}
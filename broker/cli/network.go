@@ -0,0 +1,124 @@
+package main
+
+import (
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+)
+
+//networkService returns the network service of the selected tenant
+func networkService(c *cli.Context) (broker.NetworkAPI, *broker.TenantDefaults, error) {
+	clt, defaults, err := client(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return broker.NewNetworkService(clt), defaults, nil
+}
+
+var networkCmd = cli.Command{
+	Name:  "network",
+	Usage: "manage networks",
+	Subcommands: []cli.Command{
+		{
+			Name:      "create",
+			Usage:     "create a network and its gateway gw_<name>, --cpu, --ram, --disk and --os size the gateway",
+			ArgsUsage: "<name>",
+			Flags: withOutput(append([]cli.Flag{
+				cli.StringFlag{Name: "cidr", Value: "192.168.0.0/24", Usage: "address range of the network"},
+				asyncFlag,
+			}, sizingFlags...)...),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				if c.Bool("async") {
+					_, t, err := tenant(c)
+					if err != nil {
+						return err
+					}
+					cpu, ram, disk, os := sizing(c, &t.Defaults)
+					return submit(c, t, "network.create", broker.CreateNetworkArgs{
+						Name: c.Args().First(), CIDR: c.String("cidr"), CPU: cpu, RAM: ram, Disk: disk, OS: os,
+					})
+				}
+				srv, defaults, err := networkService(c)
+				if err != nil {
+					return err
+				}
+				cpu, ram, disk, os := sizing(c, defaults)
+				n, err := srv.Create(c.Args().First(), c.String("cidr"), IPVersion.IPv4, cpu, ram, disk, os)
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, n)
+			},
+		},
+		{
+			Name:  "list",
+			Usage: "list networks",
+			Flags: outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 0); err != nil {
+					return err
+				}
+				srv, _, err := networkService(c)
+				if err != nil {
+					return err
+				}
+				list, err := srv.List()
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, list)
+			},
+		},
+		{
+			Name:      "inspect",
+			Usage:     "show a network",
+			ArgsUsage: "<name|id>",
+			Flags:     outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, _, err := networkService(c)
+				if err != nil {
+					return err
+				}
+				n, err := srv.Get(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, n)
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "delete a network and its gateway",
+			ArgsUsage: "<name|id>",
+			Flags:     withOutput(asyncFlag),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				if c.Bool("async") {
+					_, t, err := tenant(c)
+					if err != nil {
+						return err
+					}
+					return submit(c, t, "network.delete", broker.RefArgs{Ref: c.Args().First()})
+				}
+				srv, _, err := networkService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Delete(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+	},
+}
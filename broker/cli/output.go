@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+//Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+//secretMask value displayed instead of secrets
+const secretMask = "********"
+
+//secretKeys JSON keys of the api structures holding secrets
+var secretKeys = map[string]bool{
+	"private_key": true,
+}
+
+//newOutputFlags returns the flags of the commands printing resources, format is the default output format
+func newOutputFlags(format string) []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "output, o",
+			Value:  format,
+			Usage:  "output format: table, json or yaml",
+			EnvVar: "BROKER_OUTPUT",
+		},
+		cli.BoolFlag{
+			Name:  "show-secrets",
+			Usage: "display secrets such as private keys",
+		},
+	}
+}
+
+//outputFlags flags of the commands printing resources
+var outputFlags = newOutputFlags(outputTable)
+
+//checkOutput checks the output format of commands having output flags
+func checkOutput(c *cli.Context) error {
+	switch c.String("output") {
+	case "", outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return cli.NewExitError(fmt.Sprintf("Invalid output format %s, possible values are table, json and yaml", c.String("output")), exitUsage)
+}
+
+//withOutput appends the output flags to flags
+func withOutput(flags ...cli.Flag) []cli.Flag {
+	return append(flags, outputFlags...)
+}
+
+//output writes v on the standard output in the format selected with --output
+//v is rendered using its JSON representation, secrets are masked unless --show-secrets is given
+func output(c *cli.Context, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fail(err)
+	}
+	doc, err := decodeOrdered(json.NewDecoder(bytes.NewReader(b)))
+	if err != nil {
+		return fail(err)
+	}
+	if !c.Bool("show-secrets") {
+		doc = mask(doc)
+	}
+	switch c.String("output") {
+	case outputJSON:
+		b, err = json.MarshalIndent(toJSON(doc), "", "  ")
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Println(string(b))
+	case outputYAML:
+		b, err = yaml.Marshal(doc)
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Print(string(b))
+	default:
+		writeTable(os.Stdout, doc)
+	}
+	return nil
+}
+
+//decodeOrdered decodes a JSON document keeping the order of the keys of the objects, objects are decoded in yaml.MapSlice
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	dec.UseNumber()
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		obj := yaml.MapSlice{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, yaml.MapItem{Key: k, Value: v})
+		}
+		_, err = dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err = dec.Token()
+		return arr, err
+	}
+	if n, ok := t.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		f, _ := n.Float64()
+		return f, nil
+	}
+	return t, nil
+}
+
+//mask replaces the values of the secret keys
+func mask(doc interface{}) interface{} {
+	switch d := doc.(type) {
+	case yaml.MapSlice:
+		for i, item := range d {
+			if k, ok := item.Key.(string); ok && secretKeys[k] && item.Value != "" {
+				d[i].Value = secretMask
+				continue
+			}
+			d[i].Value = mask(item.Value)
+		}
+	case []interface{}:
+		for i, v := range d {
+			d[i] = mask(v)
+		}
+	}
+	return doc
+}
+
+//orderedJSON JSON object keeping the order of its keys
+type orderedJSON yaml.MapSlice
+
+//MarshalJSON writes the object keeping the order of its keys
+func (o orderedJSON) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, item := range o {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		k, err := json.Marshal(item.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(toJSON(item.Value))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteString(":")
+		buf.Write(v)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+//toJSON converts a document decoded by decodeOrdered in a value that can be marshalled in JSON
+func toJSON(doc interface{}) interface{} {
+	switch d := doc.(type) {
+	case yaml.MapSlice:
+		return orderedJSON(d)
+	case []interface{}:
+		arr := make([]interface{}, len(d))
+		for i, v := range d {
+			arr[i] = toJSON(v)
+		}
+		return arr
+	}
+	return doc
+}
+
+//cell returns the text of a table cell, objects and arrays are written in compact JSON
+func cell(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return ""
+	case yaml.MapSlice, []interface{}:
+		b, _ := json.Marshal(toJSON(v))
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+//writeTable writes a list of objects as a table with a column per key and an object as a table of key/value pairs
+func writeTable(out io.Writer, doc interface{}) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	switch d := doc.(type) {
+	case yaml.MapSlice:
+		fmt.Fprintln(w, "KEY\tVALUE")
+		for _, item := range d {
+			fmt.Fprintf(w, "%s\t%s\n", cell(item.Key), cell(item.Value))
+		}
+	case []interface{}:
+		//columns are the keys of the objects in order of appearance
+		var columns []string
+		seen := map[string]bool{}
+		objects := true
+		for _, row := range d {
+			obj, ok := row.(yaml.MapSlice)
+			if !ok {
+				objects = false
+				break
+			}
+			for _, item := range obj {
+				k := cell(item.Key)
+				if !seen[k] {
+					seen[k] = true
+					columns = append(columns, k)
+				}
+			}
+		}
+		if !objects {
+			for _, row := range d {
+				fmt.Fprintln(w, cell(row))
+			}
+			return
+		}
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range d {
+			values := map[string]interface{}{}
+			for _, item := range row.(yaml.MapSlice) {
+				values[cell(item.Key)] = item.Value
+			}
+			var cells []string
+			for _, col := range columns {
+				cells = append(cells, cell(values[col]))
+			}
+			fmt.Fprintln(w, strings.Join(cells, "\t"))
+		}
+	default:
+		fmt.Fprintln(w, cell(d))
+	}
+}
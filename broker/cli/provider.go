@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/providers"
+)
+
+var providerCmd = cli.Command{
+	Name:  "provider",
+	Usage: "list providers and their configurations",
+	Subcommands: []cli.Command{
+		{
+			Name:  "list",
+			Usage: "list available providers",
+			Flags: outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 0); err != nil {
+					return err
+				}
+				return output(c, providers.Providers())
+			},
+		},
+		{
+			Name:      "sample",
+			Usage:     "print a sample configuration of a provider, usable with broker tenant add --config",
+			ArgsUsage: "<provider>",
+			Flags:     newOutputFlags(outputJSON),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				p, err := providers.GetProvider(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, p.Sample())
+			},
+		},
+	},
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker/server"
+)
+
+var serveCmd = cli.Command{
+	Name:  "serve",
+	Usage: "serve the broker REST API and execute jobs, the OpenAPI document is served on /" + server.APIVersion + "/openapi.json and the metrics on /metrics",
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "listen", Value: "127.0.0.1:8080", Usage: "address the server listens on"},
+		cli.IntFlag{Name: "workers", Value: 4, Usage: "number of jobs executed concurrently"},
+		cli.StringFlag{Name: "tokens", Usage: "JSON file mapping the users to the SHA-256 digests in hexadecimal of their bearer tokens, required unless listening on a loopback address"},
+	},
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, 0); err != nil {
+			return err
+		}
+		srv, err := tenants(c)
+		if err != nil {
+			return err
+		}
+		js, err := jobs(c)
+		if err != nil {
+			return err
+		}
+		if c.Int("workers") < 1 {
+			return cli.NewExitError("--workers must be positive", exitUsage)
+		}
+		s := server.New(srv, js)
+		if c.String("tokens") != "" {
+			tokens, err := server.LoadTokens(c.String("tokens"))
+			if err != nil {
+				return fail(err)
+			}
+			s.RequireTokens(tokens)
+		} else if !loopback(c.String("listen")) {
+			return cli.NewExitError("--tokens is required to listen on a non loopback address", exitUsage)
+		}
+		errs := make(chan error, 2)
+		go func() {
+			errs <- js.Run(context.Background(), s.Client, c.Int("workers"))
+		}()
+		go func() {
+			errs <- http.ListenAndServe(c.String("listen"), s)
+		}()
+		fmt.Fprintf(os.Stderr, "Serving broker API on http://%s/%s\n", c.String("listen"), server.APIVersion)
+		return fail(<-errs)
+	},
+}
+
+//loopback tells if the listen address only accepts local connections
+func loopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker"
+)
+
+//sshService returns the SSH service of the selected tenant
+func sshService(c *cli.Context) (broker.SSHAPI, error) {
+	clt, _, err := client(c)
+	if err != nil {
+		return nil, err
+	}
+	return broker.NewSSHService(clt), nil
+}
+
+var sshCmd = cli.Command{
+	Name:  "ssh",
+	Usage: "connect to VMs, run commands and copy files",
+	Subcommands: []cli.Command{
+		{
+			Name:      "connect",
+			Usage:     "open a shell on a VM",
+			ArgsUsage: "<vm>",
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := sshService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Connect(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "run",
+			Usage:     "run a command on a VM, the broker exits with the exit code of the command",
+			ArgsUsage: "<vm>",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "c", Usage: "command to run"},
+			},
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				if c.String("c") == "" {
+					return cli.NewExitError("-c is required", exitUsage)
+				}
+				srv, err := sshService(c)
+				if err != nil {
+					return err
+				}
+				code, stdout, stderr, err := srv.Run(c.Args().First(), c.String("c"))
+				if err != nil {
+					return fail(err)
+				}
+				fmt.Print(stdout)
+				fmt.Fprint(os.Stderr, stderr)
+				if code != 0 {
+					return cli.NewExitError("", code)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "copy",
+			Usage:     "copy a file from or to a VM, remote paths are prefixed by the VM name (vm1:/tmp/file.txt)",
+			ArgsUsage: "<from> <to>",
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 2); err != nil {
+					return err
+				}
+				srv, err := sshService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Scp(c.Args().Get(0), c.Args().Get(1))
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+	},
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker"
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+var tenantCmd = cli.Command{
+	Name:  "tenant",
+	Usage: "manage tenants",
+	Subcommands: []cli.Command{
+		{
+			Name:      "add",
+			Usage:     "add a tenant, secrets of the configuration are encrypted by the vault unlocked with $BROKER_VAULT_PASSPHRASE or the key file $BROKER_VAULT_KEY_FILE",
+			ArgsUsage: "<name>",
+			Flags: withOutput(
+				cli.StringFlag{Name: "provider", Usage: "provider of the tenant (see broker provider list)"},
+				cli.StringFlag{Name: "config", Usage: "JSON file containing the provider configuration (see broker provider sample)"},
+				cli.StringFlag{Name: "region", Usage: "default region"},
+				cli.StringFlag{Name: "os", Usage: "default operating system"},
+				cli.IntFlag{Name: "cpu", Usage: "default minimum number of cores"},
+				cli.Float64Flag{Name: "ram", Usage: "default minimum RAM size in GB"},
+				cli.IntFlag{Name: "disk", Usage: "default minimum disk size in GB"},
+				cli.IntFlag{Name: "retries", Usage: "maximum number of attempts of idempotent calls to the provider (default 4)"},
+				cli.DurationFlag{Name: "retry-delay", Usage: "delay before the first retry (default 500ms)"},
+				cli.Float64Flag{Name: "rate", Usage: "maximum number of calls per second to the provider (default unlimited)"},
+				cli.IntFlag{Name: "burst", Usage: "number of calls allowed above the rate"},
+				cli.BoolFlag{Name: "no-disk-cache", Usage: "do not cache images and templates on disk"},
+				cli.StringFlag{Name: "metadata", Usage: "store of the bookkeeping data of the driver: object (object storage of the provider, default), bolt (local database file) or etcd"},
+				cli.StringFlag{Name: "metadata-path", Usage: "database file of the bolt store (default <config-dir>/metadata/<name>.db)"},
+				cli.StringSliceFlag{Name: "etcd-endpoint", Usage: "endpoint of the etcd cluster, can be repeated"},
+				cli.StringFlag{Name: "etcd-prefix", Usage: "prefix of the etcd keys (default /gpac/metadata)"},
+				cli.StringFlag{Name: "etcd-cert", Usage: "TLS client certificate of the etcd cluster"},
+				cli.StringFlag{Name: "etcd-key", Usage: "TLS client key of the etcd cluster"},
+				cli.StringFlag{Name: "etcd-ca", Usage: "TLS CA certificate of the etcd cluster"},
+				cli.BoolFlag{Name: "plaintext-metadata", Usage: "store the private keys of the VMs in clear in the metadata store, by default they are encrypted and the brokers sharing the store must share the configuration directory and the vault"},
+			),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				if c.String("provider") == "" || c.String("config") == "" {
+					return cli.NewExitError("--provider and --config are required", exitUsage)
+				}
+				b, err := ioutil.ReadFile(c.String("config"))
+				if err != nil {
+					return fail(err)
+				}
+				cfg := map[string]interface{}{}
+				err = json.Unmarshal(b, &cfg)
+				if err != nil {
+					return fail(fmt.Errorf("Error reading %s: %s", c.String("config"), err.Error()))
+				}
+				srv, err := tenants(c)
+				if err != nil {
+					return err
+				}
+				t, err := srv.Add(c.Args().First(), c.String("provider"), cfg, broker.TenantDefaults{
+					Region: c.String("region"),
+					OS:     c.String("os"),
+					Sizing: api.SizingRequirements{
+						MinCores:    c.Int("cpu"),
+						MinRAMSize:  float32(c.Float64("ram")),
+						MinDiskSize: c.Int("disk"),
+					},
+					Retry: providers.RetryOptions{
+						MaxAttempts: c.Int("retries"),
+						MinDelay:    c.Duration("retry-delay"),
+						Rate:        c.Float64("rate"),
+						Burst:       c.Int("burst"),
+					},
+					Cache: providers.CacheOptions{
+						MemoryOnly: c.Bool("no-disk-cache"),
+					},
+					Metadata: providers.MetadataOptions{
+						Type:          c.String("metadata"),
+						Path:          c.String("metadata-path"),
+						Endpoints:     c.StringSlice("etcd-endpoint"),
+						Prefix:        c.String("etcd-prefix"),
+						CertFile:      c.String("etcd-cert"),
+						KeyFile:       c.String("etcd-key"),
+						TrustedCAFile: c.String("etcd-ca"),
+						Plaintext:     c.Bool("plaintext-metadata"),
+					},
+				})
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, t)
+			},
+		},
+		{
+			Name:  "list",
+			Usage: "list tenants",
+			Flags: outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 0); err != nil {
+					return err
+				}
+				srv, err := tenants(c)
+				if err != nil {
+					return err
+				}
+				list, err := srv.List()
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, list)
+			},
+		},
+		{
+			Name:      "get",
+			Usage:     "show a tenant, secrets are not displayed",
+			ArgsUsage: "<name>",
+			Flags:     outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := tenants(c)
+				if err != nil {
+					return err
+				}
+				t, err := srv.Get(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, t)
+			},
+		},
+		{
+			Name:      "set",
+			Usage:     "set the current tenant",
+			ArgsUsage: "<name>",
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := tenants(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Set(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "delete a tenant",
+			ArgsUsage: "<name>",
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := tenants(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Delete(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+	},
+}
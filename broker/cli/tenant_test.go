@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/openstack/fake"
+)
+
+//runJSON runs the broker with args and decodes its JSON output in v
+func runJSON(t *testing.T, dir string, v interface{}, args ...string) {
+	out, err := runBroker(t, dir, append(args, "--output", "json")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.Unmarshal([]byte(out), v)
+	if err != nil {
+		t.Fatalf("invalid output %q: %s", out, err.Error())
+	}
+}
+
+//checkExitCode checks that err makes the broker exit with code
+func checkExitCode(t *testing.T, err error, code int) {
+	t.Helper()
+	exit, ok := err.(cli.ExitCoder)
+	if !ok || exit.ExitCode() != code {
+		t.Fatalf("expected exit code %d, got %v", code, err)
+	}
+}
+
+func TestTenantCommands(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "memory.json")
+	err := ioutil.WriteFile(cfg, []byte(`{"PublicCIDR": "198.51.100.0/24"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant := broker.Tenant{}
+	runJSON(t, dir, &tenant, "tenant", "add", "mem1", "--provider", "memory", "--config", cfg, "--os", "Debian 9", "--cpu", "2")
+	if tenant.Name != "mem1" || tenant.Provider != "memory" || tenant.Defaults.OS != "Debian 9" || tenant.Defaults.Sizing.MinCores != 2 {
+		t.Fatalf("unexpected tenant %+v", tenant)
+	}
+	var list []broker.Tenant
+	runJSON(t, dir, &list, "tenant", "list")
+	if len(list) != 1 || list[0].Name != "mem1" {
+		t.Fatalf("expected mem1, got %+v", list)
+	}
+
+	_, err = runBroker(t, dir, "tenant", "set", "mem1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the commands use the current tenant
+	var vms []api.VM
+	runJSON(t, dir, &vms, "vm", "list")
+	if len(vms) != 0 {
+		t.Fatalf("expected no VM, got %+v", vms)
+	}
+
+	_, err = runBroker(t, dir, "tenant", "add", "mem2", "--provider", "memory")
+	checkExitCode(t, err, exitUsage)
+	_, err = runBroker(t, dir, "tenant", "get")
+	checkExitCode(t, err, exitUsage)
+	_, err = runBroker(t, dir, "tenant", "get", "mem2")
+	checkExitCode(t, err, exitError)
+
+	_, err = runBroker(t, dir, "tenant", "delete", "mem1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := runBroker(t, dir, "tenant", "list")
+	if err != nil || strings.Contains(out, "mem1") {
+		t.Fatalf("expected mem1 to be deleted, got %q (%v)", out, err)
+	}
+	_, err = runBroker(t, dir, "vm", "list")
+	checkExitCode(t, err, exitError)
+}
+
+func TestNetworkCommands(t *testing.T) {
+	srv := fake.NewServer(fake.Options{})
+	defer srv.Close()
+	dir := t.TempDir()
+	addOpenStackTenant(t, dir, "os1", srv, false)
+
+	network := api.Network{}
+	runJSON(t, dir, &network, "--tenant", "os1", "network", "create", "net1", "--cidr", "192.168.10.0/24")
+	if network.ID == "" || network.Name != "net1" || network.CIDR != "192.168.10.0/24" {
+		t.Fatalf("unexpected network %+v", network)
+	}
+	var list []api.Network
+	runJSON(t, dir, &list, "--tenant", "os1", "network", "list")
+	if len(list) != 1 || list[0].ID != network.ID {
+		t.Fatalf("expected net1, got %+v", list)
+	}
+	inspected := api.Network{}
+	runJSON(t, dir, &inspected, "--tenant", "os1", "network", "inspect", "net1")
+	if inspected.ID != network.ID {
+		t.Fatalf("expected net1, got %+v", inspected)
+	}
+	_, err := runBroker(t, dir, "--tenant", "os1", "network", "delete", "net1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list = nil
+	runJSON(t, dir, &list, "--tenant", "os1", "network", "list")
+	if len(list) != 0 {
+		t.Fatalf("expected no network, got %+v", list)
+	}
+}
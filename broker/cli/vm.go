@@ -0,0 +1,127 @@
+package main
+
+import (
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker"
+)
+
+//vmService returns the VM service of the selected tenant
+func vmService(c *cli.Context) (broker.VMAPI, *broker.TenantDefaults, error) {
+	clt, defaults, err := client(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return broker.NewVMService(clt), defaults, nil
+}
+
+var vmCmd = cli.Command{
+	Name:  "vm",
+	Usage: "manage VMs",
+	Subcommands: []cli.Command{
+		{
+			Name:      "create",
+			Usage:     "create a VM",
+			ArgsUsage: "<name>",
+			Flags: withOutput(append([]cli.Flag{
+				cli.StringFlag{Name: "net", Usage: "network of the VM"},
+				cli.BoolFlag{Name: "public", Usage: "the VM has a public IP"},
+				asyncFlag,
+			}, sizingFlags...)...),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				if c.String("net") == "" {
+					return cli.NewExitError("--net is required", exitUsage)
+				}
+				if c.Bool("async") {
+					_, t, err := tenant(c)
+					if err != nil {
+						return err
+					}
+					cpu, ram, disk, os := sizing(c, &t.Defaults)
+					return submit(c, t, "vm.create", broker.CreateVMArgs{
+						Name: c.Args().First(), Network: c.String("net"), CPU: cpu, RAM: ram, Disk: disk, OS: os, Public: c.Bool("public"),
+					})
+				}
+				srv, defaults, err := vmService(c)
+				if err != nil {
+					return err
+				}
+				cpu, ram, disk, os := sizing(c, defaults)
+				vm, err := srv.Create(c.Args().First(), c.String("net"), cpu, ram, disk, os, c.Bool("public"))
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, vm)
+			},
+		},
+		{
+			Name:  "list",
+			Usage: "list VMs",
+			Flags: outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 0); err != nil {
+					return err
+				}
+				srv, _, err := vmService(c)
+				if err != nil {
+					return err
+				}
+				list, err := srv.List()
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, list)
+			},
+		},
+		{
+			Name:      "inspect",
+			Usage:     "show a VM",
+			ArgsUsage: "<name|id>",
+			Flags:     outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, _, err := vmService(c)
+				if err != nil {
+					return err
+				}
+				vm, err := srv.Inspect(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, vm)
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "delete a VM",
+			ArgsUsage: "<name|id>",
+			Flags:     withOutput(asyncFlag),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				if c.Bool("async") {
+					_, t, err := tenant(c)
+					if err != nil {
+						return err
+					}
+					return submit(c, t, "vm.delete", broker.RefArgs{Ref: c.Args().First()})
+				}
+				srv, _, err := vmService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Delete(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+	},
+}
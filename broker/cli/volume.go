@@ -0,0 +1,228 @@
+package main
+
+import (
+	"github.com/urfave/cli"
+
+	"github.com/SebastienDorgan/gpac/broker"
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//volumeService returns the volume service of the selected tenant
+func volumeService(c *cli.Context) (broker.VolumeAPI, error) {
+	clt, _, err := client(c)
+	if err != nil {
+		return nil, err
+	}
+	return broker.NewVolumeService(clt), nil
+}
+
+var volumeCmd = cli.Command{
+	Name:  "volume",
+	Usage: "manage block volumes",
+	Subcommands: []cli.Command{
+		{
+			Name:      "create",
+			Usage:     "create a volume",
+			ArgsUsage: "<name>",
+			Flags: withOutput(
+				cli.StringFlag{Name: "speed", Value: "HDD", Usage: "speed of the volume: SSD, HDD or COLD"},
+				cli.IntFlag{Name: "size", Value: 10, Usage: "size of the volume in GB"},
+				asyncFlag,
+			),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				speed, err := broker.ParseVolumeSpeed(c.String("speed"))
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitUsage)
+				}
+				if c.Bool("async") {
+					_, t, err := tenant(c)
+					if err != nil {
+						return err
+					}
+					return submit(c, t, "volume.create", broker.CreateVolumeArgs{Name: c.Args().First(), Size: c.Int("size"), Speed: speed})
+				}
+				srv, err := volumeService(c)
+				if err != nil {
+					return err
+				}
+				v, err := srv.Create(c.Args().First(), c.Int("size"), speed)
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, v)
+			},
+		},
+		{
+			Name:  "list",
+			Usage: "list volumes",
+			Flags: outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 0); err != nil {
+					return err
+				}
+				srv, err := volumeService(c)
+				if err != nil {
+					return err
+				}
+				list, err := srv.List()
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, list)
+			},
+		},
+		{
+			Name:      "inspect",
+			Usage:     "show a volume",
+			ArgsUsage: "<name|id>",
+			Flags:     outputFlags,
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				srv, err := volumeService(c)
+				if err != nil {
+					return err
+				}
+				v, err := srv.Inspect(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, v)
+			},
+		},
+		{
+			Name:      "attach",
+			Usage:     "attach a volume to a VM, format it if it has no file system and mount it",
+			ArgsUsage: "<volume> <vm>",
+			Flags: withOutput(
+				cli.StringFlag{Name: "path", Usage: "mount point (default /shared/<volume>)"},
+				cli.StringFlag{Name: "format", Value: "ext4", Usage: "file system used to format the volume"},
+				asyncFlag,
+			),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 2); err != nil {
+					return err
+				}
+				if c.Bool("async") {
+					_, t, err := tenant(c)
+					if err != nil {
+						return err
+					}
+					return submit(c, t, "volume.attach", broker.AttachVolumeArgs{
+						Volume: c.Args().Get(0), VM: c.Args().Get(1), Path: c.String("path"), Format: c.String("format"),
+					})
+				}
+				srv, err := volumeService(c)
+				if err != nil {
+					return err
+				}
+				va, err := srv.Attach(c.Args().Get(0), c.Args().Get(1), c.String("path"), c.String("format"))
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, va)
+			},
+		},
+		{
+			Name:      "detach",
+			Usage:     "unmount a volume and detach it from its VM",
+			ArgsUsage: "<volume>",
+			Flags:     withOutput(asyncFlag),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				if c.Bool("async") {
+					_, t, err := tenant(c)
+					if err != nil {
+						return err
+					}
+					return submit(c, t, "volume.detach", broker.RefArgs{Ref: c.Args().First()})
+				}
+				srv, err := volumeService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Detach(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "delete a volume",
+			ArgsUsage: "<name|id>",
+			Flags:     withOutput(asyncFlag),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				if c.Bool("async") {
+					_, t, err := tenant(c)
+					if err != nil {
+						return err
+					}
+					return submit(c, t, "volume.delete", broker.RefArgs{Ref: c.Args().First()})
+				}
+				srv, err := volumeService(c)
+				if err != nil {
+					return err
+				}
+				err = srv.Delete(c.Args().First())
+				if err != nil {
+					return fail(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "update",
+			Usage:     "rename, resize or change the speed of a volume",
+			ArgsUsage: "<name|id>",
+			Flags: withOutput(
+				cli.StringFlag{Name: "name", Usage: "new name of the volume"},
+				cli.IntFlag{Name: "size", Usage: "new size of the volume in GB, volumes can only grow"},
+				cli.StringFlag{Name: "speed", Usage: "new speed of the volume: SSD, HDD or COLD"},
+			),
+			Action: func(c *cli.Context) error {
+				if err := checkArgs(c, 1); err != nil {
+					return err
+				}
+				req := api.VolumeUpdateRequest{}
+				if c.IsSet("name") {
+					name := c.String("name")
+					req.Name = &name
+				}
+				if c.IsSet("size") {
+					size := c.Int("size")
+					req.Size = &size
+				}
+				if c.IsSet("speed") {
+					speed, err := broker.ParseVolumeSpeed(c.String("speed"))
+					if err != nil {
+						return cli.NewExitError(err.Error(), exitUsage)
+					}
+					req.Speed = &speed
+				}
+				if req.Name == nil && req.Size == nil && req.Speed == nil {
+					return cli.NewExitError("Nothing to update, use --name, --size or --speed", exitUsage)
+				}
+				srv, err := volumeService(c)
+				if err != nil {
+					return err
+				}
+				v, err := srv.Update(c.Args().First(), req)
+				if err != nil {
+					return fail(err)
+				}
+				return output(c, v)
+			},
+		},
+	},
+}
@@ -1,8 +1,192 @@
 package broker
 
+import (
+	"context"
+	"fmt"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
 // broker container create c1
 // broker container mount c1 vm1 --path="/shared/data" (utilisation de s3ql, par default /containers/c1)
 // broker container umount c1 vm1
 // broker container delete c1
 // broker container list
 // broker container inspect C1
+
+//S3QLBackend is implemented by drivers whose object storage can be mounted with s3ql
+type S3QLBackend interface {
+	//S3QLBackend returns the s3ql storage URL of the container and the credentials to access it
+	S3QLBackend(container string) (url string, login string, password string, err error)
+}
+
+//Container an object container and its objects
+type Container struct {
+	Name    string   `json:"name"`
+	Objects []string `json:"objects"`
+}
+
+//ContainerAPI defines API to manage object containers
+type ContainerAPI interface {
+	Create(name string) error
+	Delete(name string) error
+	List() ([]string, error)
+	Inspect(name string) (*Container, error)
+	//Mount mounts the container on path on the VM using s3ql
+	Mount(container string, vm string, path string) error
+	//Umount unmounts the container from the VM
+	Umount(container string, vm string) error
+	//CreateContext is like Create but includes a context
+	CreateContext(ctx context.Context, name string) error
+	//DeleteContext is like Delete but includes a context
+	DeleteContext(ctx context.Context, name string) error
+	//ListContext is like List but includes a context
+	ListContext(ctx context.Context) ([]string, error)
+	//InspectContext is like Inspect but includes a context
+	InspectContext(ctx context.Context, name string) (*Container, error)
+}
+
+//NewContainerService creates a container service
+func NewContainerService(api api.ClientAPI) ContainerAPI {
+	return &ContainerService{
+		provider: providers.FromClient(api),
+		vm:       NewVMService(api),
+		ssh:      NewSSHService(api),
+	}
+}
+
+//ContainerService container service
+type ContainerService struct {
+	provider *providers.Service
+	vm       VMAPI
+	ssh      SSHAPI
+}
+
+//Create creates a container
+func (srv *ContainerService) Create(name string) error {
+	return srv.CreateContext(context.Background(), name)
+}
+
+//CreateContext is like Create but includes a context
+func (srv *ContainerService) CreateContext(ctx context.Context, name string) error {
+	return contextAPI(srv.provider).CreateContainerContext(ctx, name)
+}
+
+//Delete deletes a container
+func (srv *ContainerService) Delete(name string) error {
+	return srv.DeleteContext(context.Background(), name)
+}
+
+//DeleteContext is like Delete but includes a context
+func (srv *ContainerService) DeleteContext(ctx context.Context, name string) error {
+	return contextAPI(srv.provider).DeleteContainerContext(ctx, name)
+}
+
+//List returns the container list
+func (srv *ContainerService) List() ([]string, error) {
+	return srv.ListContext(context.Background())
+}
+
+//ListContext is like List but includes a context
+func (srv *ContainerService) ListContext(ctx context.Context) ([]string, error) {
+	return contextAPI(srv.provider).ListContainersContext(ctx)
+}
+
+//Inspect returns the container name and the list of its objects
+func (srv *ContainerService) Inspect(name string) (*Container, error) {
+	return srv.InspectContext(context.Background(), name)
+}
+
+//InspectContext is like Inspect but includes a context
+func (srv *ContainerService) InspectContext(ctx context.Context, name string) (*Container, error) {
+	names, err := contextAPI(srv.provider).ListContainersContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range names {
+		if n != name {
+			continue
+		}
+		objects, err := contextAPI(srv.provider).ListObjectsContext(ctx, name, api.ObjectFilter{})
+		if err != nil {
+			return nil, err
+		}
+		return &Container{
+			Name:    name,
+			Objects: objects,
+		}, nil
+	}
+	return nil, providers.ResourceNotFoundError("Container", name)
+}
+
+//s3qlDir directory where the s3ql configuration of the mounted containers is stored on the VMs
+const s3qlDir = "/etc/s3ql"
+
+//Mount mounts the container on the VM, the default mount point is /containers/<container>
+func (srv *ContainerService) Mount(container string, vm string, path string) error {
+	backend, ok := providers.Driver(srv.provider.ClientAPI).(S3QLBackend)
+	if !ok {
+		return fmt.Errorf("Error mounting container %s: the provider does not support s3ql", container)
+	}
+	_, err := srv.Inspect(container)
+	if err != nil {
+		return err
+	}
+	target, err := srv.vm.Inspect(vm)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		path = "/containers/" + container
+	}
+	url, login, password, err := backend.S3QLBackend(container)
+	if err != nil {
+		return err
+	}
+	//The file system is created the first time the container is mounted, mkfs.s3ql refuses to overwrite an existing one
+	script := fmt.Sprintf(`set -e
+%s
+sudo mkdir -p %s %s
+sudo chmod 700 %s
+sudo tee %s >/dev/null <<'ENDAUTH'
+[%s]
+storage-url: %s
+backend-login: %s
+backend-password: %s
+ENDAUTH
+sudo chmod 600 %s
+echo %s | sudo tee %s >/dev/null
+sudo mkfs.s3ql --plain --authfile %s %s </dev/null >/dev/null 2>&1 || true
+sudo mount.s3ql --allow-other --authfile %s %s %s`,
+		installScript("s3ql", "s3ql"),
+		s3qlDir, quote(path), s3qlDir,
+		quote(s3qlDir+"/"+container+".authinfo"), container, url, login, password,
+		quote(s3qlDir+"/"+container+".authinfo"),
+		quote(path), quote(s3qlDir+"/"+container+".mountpoint"),
+		quote(s3qlDir+"/"+container+".authinfo"), quote(url),
+		quote(s3qlDir+"/"+container+".authinfo"), quote(url), quote(path))
+	err = runScript(srv.ssh, target.ID, script)
+	if err != nil {
+		return fmt.Errorf("Error mounting container %s on %s: %s", container, target.Name, err.Error())
+	}
+	return nil
+}
+
+//Umount unmounts the container from the VM
+func (srv *ContainerService) Umount(container string, vm string) error {
+	target, err := srv.vm.Inspect(vm)
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf(`set -e
+f=%s
+sudo test -f "$f.mountpoint" || { echo "container is not mounted" >&2; exit 1; }
+sudo umount.s3ql "$(sudo cat "$f.mountpoint")"
+sudo rm -f "$f.mountpoint" "$f.authinfo"`, quote(s3qlDir+"/"+container))
+	err = runScript(srv.ssh, target.ID, script)
+	if err != nil {
+		return fmt.Errorf("Error unmounting container %s from %s: %s", container, target.Name, err.Error())
+	}
+	return nil
+}
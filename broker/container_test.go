@@ -0,0 +1,72 @@
+package broker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+	"github.com/SebastienDorgan/gpac/providers/openstack/fake"
+)
+
+//recordingSSH records the scripts run on the VMs
+type recordingSSH struct {
+	scripts []string
+}
+
+func (s *recordingSSH) Connect(name string) error {
+	return nil
+}
+
+func (s *recordingSSH) Run(ref string, cmd string) (int, string, string, error) {
+	return s.RunContext(context.Background(), ref, cmd)
+}
+
+func (s *recordingSSH) RunContext(ctx context.Context, ref string, cmd string) (int, string, string, error) {
+	s.scripts = append(s.scripts, cmd)
+	return 0, "", "", nil
+}
+
+func (s *recordingSSH) Scp(from string, to string) error {
+	return nil
+}
+
+func TestMountDecoratedClient(t *testing.T) {
+	srv := fake.NewServer(fake.Options{})
+	defer srv.Close()
+	unlock(t, "passphrase", "")
+	tenants := NewTenantService(t.TempDir())
+	addOpenStackTenant(t, tenants, "os1", srv, providers.MetadataOptions{})
+	//the client is observed, retried, cached and audited
+	clt, err := tenants.Client("os1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := clt.(S3QLBackend); ok {
+		t.Fatal("the test expects a decorated client")
+	}
+
+	_, err = NewNetworkService(clt).Create("net1", "192.168.1.0/24", IPVersion.IPv4, 1, 2, 10, "Ubuntu 16.04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewVMService(clt).Create("vm1", "net1", 1, 2, 10, "Ubuntu 16.04", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	containers := NewContainerService(clt).(*ContainerService)
+	ssh := &recordingSSH{}
+	containers.ssh = ssh
+	err = containers.Create("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = containers.Mount("c1", "vm1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ssh.scripts) != 1 || !strings.Contains(ssh.scripts[0], "storage-url: swiftks://") {
+		t.Fatalf("expected the container to be mounted with swift, got %v", ssh.scripts)
+	}
+}
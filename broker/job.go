@@ -0,0 +1,552 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeSpeed"
+	uuid "github.com/satori/go.uuid"
+)
+
+//JobState state of a job
+type JobState string
+
+//Job states
+const (
+	//JobQueued the job waits for a worker
+	JobQueued JobState = "queued"
+	//JobRunning the job is running
+	JobRunning JobState = "running"
+	//JobSucceeded the job is completed
+	JobSucceeded JobState = "succeeded"
+	//JobFailed the job failed, the error is in Job.Error
+	JobFailed JobState = "failed"
+	//JobCancelled the job was cancelled
+	JobCancelled JobState = "cancelled"
+)
+
+//Done tells if the state is final
+func (s JobState) Done() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+//JobStep a step of a job
+type JobStep struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+//Job an asynchronous broker operation
+type Job struct {
+	ID        string          `json:"id"`
+	Tenant    string          `json:"tenant"`
+	Operation string          `json:"operation"`
+	Args      json.RawMessage `json:"args,omitempty"`
+	State     JobState        `json:"state"`
+	Steps     []JobStep       `json:"steps,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Created   time.Time       `json:"created"`
+	Updated   time.Time       `json:"updated"`
+}
+
+//JobOperation executes a job, step reports the progress of the job
+//ctx is cancelled when the job is cancelled, the resources created by the operation must then be deleted
+type JobOperation func(ctx context.Context, clt api.ClientAPI, args json.RawMessage, step func(string)) (interface{}, error)
+
+var jobOperations = map[string]JobOperation{}
+
+//RegisterJobOperation registers the operation executed by the jobs named name
+func RegisterJobOperation(name string, op JobOperation) {
+	jobOperations[name] = op
+}
+
+//JobAPI defines API to manage jobs
+type JobAPI interface {
+	Submit(tenant string, operation string, args interface{}) (*Job, error)
+	List() ([]Job, error)
+	Get(id string) (*Job, error)
+	//Wait waits for the job to be done, the job is returned as is when timeout is reached
+	Wait(id string, timeout time.Duration) (*Job, error)
+	Cancel(id string) (*Job, error)
+}
+
+//JobService job service storing jobs in <dir>/jobs
+//Jobs are executed by the engine started with Run, usually by broker serve. A single engine can run on a
+//configuration directory at a time, jobs found running when it starts were interrupted and are marked as failed
+type JobService struct {
+	dir string
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+//NewJobService creates a job service storing jobs in the configuration directory dir
+func NewJobService(dir string) *JobService {
+	return &JobService{
+		dir:     filepath.Join(dir, "jobs"),
+		cancels: map[string]context.CancelFunc{},
+	}
+}
+
+func (srv *JobService) jobFile(id string) string {
+	return filepath.Join(srv.dir, id+".json")
+}
+
+func (srv *JobService) cancelFile(id string) string {
+	return filepath.Join(srv.dir, id+".cancel")
+}
+
+//save writes the job, the file is replaced atomically
+func (srv *JobService) save(job *Job) error {
+	job.Updated = time.Now().UTC()
+	b, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(srv.dir, 0700)
+	if err != nil {
+		return err
+	}
+	tmp := srv.jobFile(job.ID) + ".tmp"
+	err = ioutil.WriteFile(tmp, b, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, srv.jobFile(job.ID))
+}
+
+//Submit queues a job executing operation with args on the tenant
+func (srv *JobService) Submit(tenant string, operation string, args interface{}) (*Job, error) {
+	if _, ok := jobOperations[operation]; !ok {
+		return nil, fmt.Errorf("Error submitting job: unknown operation %s", operation)
+	}
+	b, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("Error submitting job: %s", err.Error())
+	}
+	job := Job{
+		ID:        uuid.NewV4().String(),
+		Tenant:    tenant,
+		Operation: operation,
+		Args:      b,
+		State:     JobQueued,
+		Created:   time.Now().UTC(),
+	}
+	err = srv.save(&job)
+	if err != nil {
+		return nil, fmt.Errorf("Error submitting job: %s", err.Error())
+	}
+	return &job, nil
+}
+
+//List returns the jobs ordered by creation date
+func (srv *JobService) List() ([]Job, error) {
+	files, err := filepath.Glob(filepath.Join(srv.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("Error listing jobs: %s", err.Error())
+	}
+	jobs := []Job{}
+	for _, f := range files {
+		job, err := srv.Get(strings.TrimSuffix(filepath.Base(f), ".json"))
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, *job)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Created.Before(jobs[j].Created)
+	})
+	return jobs, nil
+}
+
+//Get returns the job id
+func (srv *JobService) Get(id string) (*Job, error) {
+	if id == "" || strings.ContainsAny(id, "/\\.") {
+		return nil, providers.ResourceNotFoundError("Job", id)
+	}
+	b, err := ioutil.ReadFile(srv.jobFile(id))
+	if os.IsNotExist(err) {
+		return nil, providers.ResourceNotFoundError("Job", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading job %s: %s", id, err.Error())
+	}
+	job := Job{}
+	err = json.Unmarshal(b, &job)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading job %s: %s", id, err.Error())
+	}
+	return &job, nil
+}
+
+//Wait waits for the job to be done
+func (srv *JobService) Wait(id string, timeout time.Duration) (*Job, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		job, err := srv.Get(id)
+		if err != nil || job.State.Done() || !time.Now().Before(deadline) {
+			return job, err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+//Cancel cancels the job, a queued job is cancelled immediately, a running job is cancelled by its engine
+func (srv *JobService) Cancel(id string) (*Job, error) {
+	job, err := srv.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.State.Done() {
+		return nil, fmt.Errorf("Error cancelling job %s: job is %s", id, job.State)
+	}
+	//the marker is the request seen by the engine, it may run in another process
+	err = ioutil.WriteFile(srv.cancelFile(id), nil, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Error cancelling job %s: %s", id, err.Error())
+	}
+	if job.State == JobQueued {
+		job.State = JobCancelled
+		job.Steps = append(job.Steps, JobStep{Time: time.Now().UTC(), Message: "cancelled before start"})
+		err = srv.save(job)
+		if err != nil {
+			return nil, fmt.Errorf("Error cancelling job %s: %s", id, err.Error())
+		}
+	}
+	srv.mu.Lock()
+	if cancel, ok := srv.cancels[id]; ok {
+		cancel()
+	}
+	srv.mu.Unlock()
+	return job, nil
+}
+
+func (srv *JobService) cancelRequested(id string) bool {
+	_, err := os.Stat(srv.cancelFile(id))
+	return err == nil
+}
+
+//lock takes the engine lock of the configuration directory, a lock held by a dead process is taken over
+func (srv *JobService) lock() error {
+	err := os.MkdirAll(srv.dir, 0700)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(srv.dir, "engine.lock")
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			fmt.Fprint(f, os.Getpid())
+			return f.Close()
+		}
+		b, _ := ioutil.ReadFile(path)
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+		if pid > 0 && processAlive(pid) {
+			return fmt.Errorf("a job engine is already running (pid %d)", pid)
+		}
+		os.Remove(path)
+	}
+	return fmt.Errorf("unable to lock %s", path)
+}
+
+//Run executes queued jobs until ctx is done, workers jobs run concurrently
+//clients returns the client of a tenant
+func (srv *JobService) Run(ctx context.Context, clients func(tenant string) (api.ClientAPI, error), workers int) error {
+	err := srv.lock()
+	if err != nil {
+		return fmt.Errorf("Error starting job engine: %s", err.Error())
+	}
+	defer os.Remove(filepath.Join(srv.dir, "engine.lock"))
+	jobs, err := srv.List()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.State == JobRunning {
+			srv.finish(&job, nil, fmt.Errorf("interrupted by a broker restart"))
+		}
+	}
+	slots := make(chan bool, workers)
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		jobs, _ := srv.List()
+		for i := range jobs {
+			job := jobs[i]
+			srv.mu.Lock()
+			_, running := srv.cancels[job.ID]
+			srv.mu.Unlock()
+			if running {
+				if srv.cancelRequested(job.ID) {
+					srv.mu.Lock()
+					srv.cancels[job.ID]()
+					srv.mu.Unlock()
+				}
+				continue
+			}
+			if job.State == JobCancelled {
+				//marker of a job cancelled before start
+				os.Remove(srv.cancelFile(job.ID))
+			}
+			if job.State != JobQueued {
+				continue
+			}
+			select {
+			case slots <- true:
+			default:
+				continue
+			}
+			jobCtx, cancel := context.WithCancel(ctx)
+			srv.mu.Lock()
+			srv.cancels[job.ID] = cancel
+			srv.mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				srv.execute(jobCtx, &job, clients)
+				cancel()
+				srv.mu.Lock()
+				delete(srv.cancels, job.ID)
+				srv.mu.Unlock()
+				<-slots
+			}()
+		}
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+//execute runs the job
+func (srv *JobService) execute(ctx context.Context, job *Job, clients func(tenant string) (api.ClientAPI, error)) {
+	if srv.cancelRequested(job.ID) {
+		srv.finish(job, nil, context.Canceled)
+		return
+	}
+	job.State = JobRunning
+	job.Steps = append(job.Steps, JobStep{Time: time.Now().UTC(), Message: "started"})
+	srv.save(job)
+	op, ok := jobOperations[job.Operation]
+	if !ok {
+		srv.finish(job, nil, fmt.Errorf("unknown operation %s", job.Operation))
+		return
+	}
+	clt, err := clients(job.Tenant)
+	if err != nil {
+		srv.finish(job, nil, err)
+		return
+	}
+	step := func(msg string) {
+		job.Steps = append(job.Steps, JobStep{Time: time.Now().UTC(), Message: msg})
+		srv.save(job)
+	}
+	result, err := op(ctx, clt, job.Args, step)
+	//the error of an operation interrupted by the cancellation (e.g. a wait timeout) is reported as a cancellation
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	srv.finish(job, result, err)
+}
+
+//finish records the outcome of the job
+func (srv *JobService) finish(job *Job, result interface{}, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.State = JobCancelled
+		job.Steps = append(job.Steps, JobStep{Time: time.Now().UTC(), Message: "cancelled"})
+	case err != nil:
+		job.State = JobFailed
+		job.Error = err.Error()
+	default:
+		job.State = JobSucceeded
+		if result != nil {
+			job.Result, _ = json.Marshal(result)
+		}
+		job.Steps = append(job.Steps, JobStep{Time: time.Now().UTC(), Message: "succeeded"})
+	}
+	srv.save(job)
+	os.Remove(srv.cancelFile(job.ID))
+}
+
+//CreateNetworkArgs arguments of the network.create job operation
+type CreateNetworkArgs struct {
+	Name string  `json:"name"`
+	CIDR string  `json:"cidr"`
+	CPU  int     `json:"cpu"`
+	RAM  float32 `json:"ram"`
+	Disk int     `json:"disk"`
+	OS   string  `json:"os"`
+}
+
+//CreateVMArgs arguments of the vm.create job operation
+type CreateVMArgs struct {
+	Name    string  `json:"name"`
+	Network string  `json:"network"`
+	CPU     int     `json:"cpu"`
+	RAM     float32 `json:"ram"`
+	Disk    int     `json:"disk"`
+	OS      string  `json:"os"`
+	Public  bool    `json:"public"`
+}
+
+//CreateVolumeArgs arguments of the volume.create job operation
+type CreateVolumeArgs struct {
+	Name  string           `json:"name"`
+	Size  int              `json:"size"`
+	Speed VolumeSpeed.Enum `json:"speed"`
+}
+
+//AttachVolumeArgs arguments of the volume.attach job operation
+type AttachVolumeArgs struct {
+	Volume string `json:"volume"`
+	VM     string `json:"vm"`
+	Path   string `json:"path"`
+	Format string `json:"format"`
+}
+
+//RefArgs arguments of the job operations working on an existing resource
+type RefArgs struct {
+	Ref string `json:"ref"`
+}
+
+//rollback deletes the resource created by a job cancelled once the creation is done, the deletion is not bound to the
+//context of the job. The job is cancelled if the resource is deleted and failed otherwise
+func rollback(ctx context.Context, step func(string), resource string, del func(ctx context.Context) error) error {
+	step(fmt.Sprintf("cancelled, deleting %s", resource))
+	err := del(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error rolling back the creation of %s: %s", resource, err.Error())
+	}
+	return ctx.Err()
+}
+
+func init() {
+	RegisterJobOperation("network.create", func(ctx context.Context, clt api.ClientAPI, args json.RawMessage, step func(string)) (interface{}, error) {
+		a := CreateNetworkArgs{}
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, err
+		}
+		srv := NewNetworkService(clt)
+		step(fmt.Sprintf("creating network %s and its gateway gw_%s", a.Name, a.Name))
+		n, err := srv.CreateContext(ctx, a.Name, a.CIDR, IPVersion.IPv4, a.CPU, a.RAM, a.Disk, a.OS)
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, rollback(ctx, step, "network "+n.Name, func(ctx context.Context) error {
+				return srv.DeleteContext(ctx, n.ID)
+			})
+		}
+		step(fmt.Sprintf("network %s created", n.ID))
+		return n, nil
+	})
+	RegisterJobOperation("network.delete", func(ctx context.Context, clt api.ClientAPI, args json.RawMessage, step func(string)) (interface{}, error) {
+		a := RefArgs{}
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, err
+		}
+		step(fmt.Sprintf("deleting network %s and its gateway", a.Ref))
+		return nil, NewNetworkService(clt).DeleteContext(ctx, a.Ref)
+	})
+	RegisterJobOperation("vm.create", func(ctx context.Context, clt api.ClientAPI, args json.RawMessage, step func(string)) (interface{}, error) {
+		a := CreateVMArgs{}
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, err
+		}
+		srv := NewVMService(clt)
+		step(fmt.Sprintf("creating VM %s on network %s", a.Name, a.Network))
+		vm, err := srv.CreateContext(ctx, a.Name, a.Network, a.CPU, a.RAM, a.Disk, a.OS, a.Public)
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, rollback(ctx, step, "VM "+vm.Name, func(ctx context.Context) error {
+				return srv.DeleteContext(ctx, vm.ID)
+			})
+		}
+		step(fmt.Sprintf("VM %s created", vm.ID))
+		//the private key is not kept in the job, it is available with vm inspect
+		vm.PrivateKey = ""
+		return vm, nil
+	})
+	RegisterJobOperation("vm.delete", func(ctx context.Context, clt api.ClientAPI, args json.RawMessage, step func(string)) (interface{}, error) {
+		a := RefArgs{}
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, err
+		}
+		step(fmt.Sprintf("deleting VM %s", a.Ref))
+		return nil, NewVMService(clt).DeleteContext(ctx, a.Ref)
+	})
+	RegisterJobOperation("volume.create", func(ctx context.Context, clt api.ClientAPI, args json.RawMessage, step func(string)) (interface{}, error) {
+		a := CreateVolumeArgs{}
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, err
+		}
+		srv := NewVolumeService(clt)
+		step(fmt.Sprintf("creating volume %s", a.Name))
+		v, err := srv.CreateContext(ctx, a.Name, a.Size, a.Speed)
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, rollback(ctx, step, "volume "+v.Name, func(ctx context.Context) error {
+				return srv.DeleteContext(ctx, v.ID)
+			})
+		}
+		step(fmt.Sprintf("volume %s created", v.ID))
+		return v, nil
+	})
+	RegisterJobOperation("volume.delete", func(ctx context.Context, clt api.ClientAPI, args json.RawMessage, step func(string)) (interface{}, error) {
+		a := RefArgs{}
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, err
+		}
+		step(fmt.Sprintf("deleting volume %s", a.Ref))
+		return nil, NewVolumeService(clt).DeleteContext(ctx, a.Ref)
+	})
+	RegisterJobOperation("volume.attach", func(ctx context.Context, clt api.ClientAPI, args json.RawMessage, step func(string)) (interface{}, error) {
+		a := AttachVolumeArgs{}
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, err
+		}
+		srv := NewVolumeService(clt)
+		step(fmt.Sprintf("attaching volume %s to %s", a.Volume, a.VM))
+		va, err := srv.AttachContext(ctx, a.Volume, a.VM, a.Path, a.Format)
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, rollback(ctx, step, "attachment "+va.Name, func(ctx context.Context) error {
+				return srv.DetachContext(ctx, a.Volume)
+			})
+		}
+		step(fmt.Sprintf("volume %s mounted on %s", a.Volume, a.VM))
+		return va, nil
+	})
+	RegisterJobOperation("volume.detach", func(ctx context.Context, clt api.ClientAPI, args json.RawMessage, step func(string)) (interface{}, error) {
+		a := RefArgs{}
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, err
+		}
+		step(fmt.Sprintf("detaching volume %s", a.Ref))
+		return nil, NewVolumeService(clt).DetachContext(ctx, a.Ref)
+	})
+}
@@ -0,0 +1,129 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+	"github.com/SebastienDorgan/gpac/providers/memory"
+)
+
+//cancellingClient cancels the job once a resource is created, as a user cancelling the job during the creation
+type cancellingClient struct {
+	api.ClientAPI
+	api.ClientAPIContext
+	cancel context.CancelFunc
+}
+
+func (c *cancellingClient) CreateNetworkContext(ctx context.Context, req api.NetworkRequest) (*api.Network, error) {
+	defer c.cancel()
+	return c.ClientAPIContext.CreateNetworkContext(ctx, req)
+}
+
+func (c *cancellingClient) CreateVMContext(ctx context.Context, req api.VMRequest) (*api.VM, error) {
+	defer c.cancel()
+	return c.ClientAPIContext.CreateVMContext(ctx, req)
+}
+
+func (c *cancellingClient) CreateVolumeContext(ctx context.Context, req api.VolumeRequest) (*api.Volume, error) {
+	defer c.cancel()
+	return c.ClientAPIContext.CreateVolumeContext(ctx, req)
+}
+
+func newMemoryClient(t *testing.T) api.ClientAPI {
+	clt, err := memory.AuthenticatedClient(memory.AuthOptions{TransitionDelay: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clt
+}
+
+//runCancelled runs the operation with a client cancelling the job once the resource is created
+func runCancelled(t *testing.T, clt api.ClientAPI, operation string, args interface{}) []string {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []string{}
+	cc := &cancellingClient{ClientAPI: clt, ClientAPIContext: api.WithContext(clt), cancel: cancel}
+	_, err = jobOperations[operation](ctx, cc, b, func(msg string) {
+		steps = append(steps, msg)
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected %s to be cancelled, got %v", operation, err)
+	}
+	return steps
+}
+
+func TestCreateNetworkJobRollback(t *testing.T) {
+	clt := newMemoryClient(t)
+	steps := runCancelled(t, clt, "network.create", CreateNetworkArgs{Name: "net1", CIDR: "192.168.0.0/24", CPU: 1, RAM: 1, Disk: 10, OS: "Ubuntu 16.04"})
+	if len(steps) != 2 {
+		t.Errorf("expected the creation and the rollback steps, got %v", steps)
+	}
+	nets, err := clt.ListNetworks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 0 {
+		t.Errorf("expected the network to be deleted, got %v", nets)
+	}
+	vms, err := clt.ListVMs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 0 {
+		t.Errorf("expected the gateway to be deleted, got %v", vms)
+	}
+}
+
+func TestCreateVMJobRollback(t *testing.T) {
+	clt := newMemoryClient(t)
+	_, err := NewNetworkService(clt).Create("net1", "192.168.0.0/24", IPVersion.IPv4, 1, 1, 10, "Ubuntu 16.04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runCancelled(t, clt, "vm.create", CreateVMArgs{Name: "vm1", Network: "net1", CPU: 1, RAM: 1, Disk: 10, OS: "Ubuntu 16.04"})
+	if _, err := NewVMService(clt).Inspect("vm1"); err == nil {
+		t.Error("expected the VM to be deleted")
+	}
+}
+
+func TestCreateVolumeJobRollback(t *testing.T) {
+	clt := newMemoryClient(t)
+	runCancelled(t, clt, "volume.create", CreateVolumeArgs{Name: "v1", Size: 10})
+	if _, err := NewVolumeService(clt).Inspect("v1"); err == nil {
+		t.Error("expected the volume to be deleted")
+	}
+}
+
+func TestCancelledJobState(t *testing.T) {
+	jobOperations["test.wait"] = func(ctx context.Context, clt api.ClientAPI, args json.RawMessage, step func(string)) (interface{}, error) {
+		<-ctx.Done()
+		//waiters report the interruption as a timeout
+		return nil, api.NewError(api.ErrTimeout, ctx.Err(), "Timeout waiting for the resource")
+	}
+	defer delete(jobOperations, "test.wait")
+	srv := NewJobService(t.TempDir())
+	job, err := srv.Submit("tenant", "test.wait", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	srv.execute(ctx, job, func(tenant string) (api.ClientAPI, error) {
+		return newMemoryClient(t), nil
+	})
+	job, err = srv.Get(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != JobCancelled {
+		t.Fatalf("expected the job to be cancelled, got %s (%s)", job.State, job.Error)
+	}
+}
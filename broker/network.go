@@ -1,6 +1,7 @@
 package broker
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/SebastienDorgan/gpac/providers"
@@ -19,12 +20,19 @@ type NetworkAPI interface {
 	List() ([]api.Network, error)
 	Get(ref string) (*api.Network, error)
 	Delete(ref string) error
+	//CreateContext is like Create but includes a context
+	CreateContext(ctx context.Context, net string, cidr string, ipVersion IPVersion.Enum, cpu int, ram float32, disk int, os string) (*api.Network, error)
+	//GetContext is like Get but includes a context
+	GetContext(ctx context.Context, ref string) (*api.Network, error)
+	//DeleteContext is like Delete but includes a context
+	DeleteContext(ctx context.Context, ref string) error
+	//ListContext is like List but includes a context
+	ListContext(ctx context.Context) ([]api.Network, error)
 }
 
 //NetworkService an instance of NetworkAPI
 type NetworkService struct {
-	provider  *providers.Service
-	ipVersion IPVersion.Enum
+	provider *providers.Service
 }
 
 //NewNetworkService Creates new Network service
@@ -34,29 +42,40 @@ func NewNetworkService(api api.ClientAPI) NetworkAPI {
 	}
 }
 
-//Create creates a network
+//Create creates a network, a gateway named gw_<net> is created on the network
 func (srv *NetworkService) Create(net string, cidr string, ipVersion IPVersion.Enum, cpu int, ram float32, disk int, os string) (*api.Network, error) {
-	_, err := srv.Get(net)
-	if err != nil {
-		return nil, fmt.Errorf("Network %s already exists", net)
+	return srv.CreateContext(context.Background(), net, cidr, ipVersion, cpu, ram, disk, os)
+}
+
+//CreateContext is like Create but includes a context
+func (srv *NetworkService) CreateContext(ctx context.Context, net string, cidr string, ipVersion IPVersion.Enum, cpu int, ram float32, disk int, os string) (*api.Network, error) {
+	_, err := srv.GetContext(ctx, net)
+	if err == nil {
+		return nil, providers.ResourceAlreadyExistsError("Network", net)
 	}
-	tpls, err := srv.provider.SelectTemplatesBySize(api.SizingRequirements{
+	tpls, err := srv.provider.SelectTemplatesBySizeContext(ctx, api.SizingRequirements{
 		MinCores:    cpu,
 		MinRAMSize:  ram,
 		MinDiskSize: disk,
 	})
-	img, err := srv.provider.SearchImage(os)
+	if err != nil {
+		return nil, err
+	}
+	if len(tpls) == 0 {
+		return nil, fmt.Errorf("No template matching %d cores, %.1f GB of RAM and %d GB of disk", cpu, ram, disk)
+	}
+	img, err := srv.provider.SearchImageContext(ctx, os)
 	if err != nil {
 		return nil, err
 	}
 	gwRequest := api.VMRequest{
 		ImageID:    img.ID,
-		Name:       net,
+		Name:       "gw_" + net,
 		TemplateID: tpls[0].ID,
 	}
-	network, err := srv.provider.CreateNetwork(api.NetworkRequest{
+	network, err := contextAPI(srv.provider).CreateNetworkContext(ctx, api.NetworkRequest{
 		Name:      net,
-		IPVersion: srv.ipVersion,
+		IPVersion: ipVersion,
 		CIDR:      cidr,
 		GWRequest: gwRequest,
 	})
@@ -68,12 +87,22 @@ func (srv *NetworkService) Create(net string, cidr string, ipVersion IPVersion.E
 
 //List returns the network list
 func (srv *NetworkService) List() ([]api.Network, error) {
-	return srv.provider.ListNetworks()
+	return srv.ListContext(context.Background())
+}
+
+//ListContext is like List but includes a context
+func (srv *NetworkService) ListContext(ctx context.Context) ([]api.Network, error) {
+	return contextAPI(srv.provider).ListNetworksContext(ctx)
 }
 
 //Get returns the network identified by ref, ref can be the name or the id
 func (srv *NetworkService) Get(ref string) (*api.Network, error) {
-	nets, err := srv.provider.ListNetworks()
+	return srv.GetContext(context.Background(), ref)
+}
+
+//GetContext is like Get but includes a context
+func (srv *NetworkService) GetContext(ctx context.Context, ref string) (*api.Network, error) {
+	nets, err := contextAPI(srv.provider).ListNetworksContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -82,14 +111,24 @@ func (srv *NetworkService) Get(ref string) (*api.Network, error) {
 			return &n, nil
 		}
 	}
-	return nil, fmt.Errorf("Network %s does not exists", ref)
+	return nil, providers.ResourceNotFoundError("Network", ref)
 }
 
 //Delete deletes network referenced by ref
 func (srv *NetworkService) Delete(ref string) error {
-	n, err := srv.Get(ref)
+	return srv.DeleteContext(context.Background(), ref)
+}
+
+//DeleteContext is like Delete but includes a context
+func (srv *NetworkService) DeleteContext(ctx context.Context, ref string) error {
+	n, err := srv.GetContext(ctx, ref)
 	if err != nil {
-		return fmt.Errorf("Network %s does not exists", ref)
+		return err
 	}
-	return srv.provider.DeleteNetwork(n.ID)
+	return contextAPI(srv.provider).DeleteNetworkContext(ctx, n.ID)
+}
+
+//contextAPI returns the context-aware API of the client of srv
+func contextAPI(srv *providers.Service) api.ClientAPIContext {
+	return api.WithContext(srv.ClientAPI)
 }
@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package broker
+
+import "syscall"
+
+//processAlive tells if the process pid is running, signal 0 only checks that the process exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	//EPERM: the process exists but belongs to another user
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package broker
+
+import "os"
+
+//processAlive tells if the process pid is running, FindProcess fails on Windows if the process does not exist
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+//Tokens bearer tokens of the users of the server, the SHA-256 digests of the tokens in hexadecimal indexed by user
+type Tokens map[string]string
+
+//Digest returns the SHA-256 digest of token in hexadecimal, as stored in Tokens
+func Digest(token string) string {
+	d := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(d[:])
+}
+
+//LoadTokens loads the tokens of the JSON file path
+func LoadTokens(path string) (Tokens, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading tokens: %s", err.Error())
+	}
+	tokens := Tokens{}
+	err = json.Unmarshal(b, &tokens)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading tokens %s: %s", path, err.Error())
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("Error reading tokens %s: no token defined", path)
+	}
+	for user, digest := range tokens {
+		d, err := hex.DecodeString(digest)
+		if err != nil || len(d) != sha256.Size || user == "" {
+			return nil, fmt.Errorf("Error reading tokens %s: the token of '%s' is not a SHA-256 digest in hexadecimal", path, user)
+		}
+	}
+	return tokens, nil
+}
+
+//user returns the user owning the bearer token of r
+func (t Tokens) user(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return "", false
+	}
+	digest := sha256.Sum256([]byte(strings.TrimPrefix(auth, "Bearer ")))
+	//every digest is compared so that the time taken does not tell which users exist
+	found := ""
+	for user, d := range t {
+		expected, _ := hex.DecodeString(d)
+		if subtle.ConstantTimeCompare(digest[:], expected) == 1 {
+			found = user
+		}
+	}
+	return found, found != ""
+}
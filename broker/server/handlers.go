@@ -0,0 +1,500 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/broker"
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeSpeed"
+)
+
+//NetworkRequest body of network creation requests, the sizing and the OS are those of the gateway
+type NetworkRequest struct {
+	Name string  `json:"name"`
+	CIDR string  `json:"cidr,omitempty"`
+	CPU  int     `json:"cpu,omitempty"`
+	RAM  float32 `json:"ram,omitempty"`
+	Disk int     `json:"disk,omitempty"`
+	OS   string  `json:"os,omitempty"`
+}
+
+//VMRequest body of VM creation requests
+type VMRequest struct {
+	Name    string  `json:"name"`
+	Network string  `json:"network"`
+	CPU     int     `json:"cpu,omitempty"`
+	RAM     float32 `json:"ram,omitempty"`
+	Disk    int     `json:"disk,omitempty"`
+	OS      string  `json:"os,omitempty"`
+	Public  bool    `json:"public,omitempty"`
+}
+
+//RunRequest body of command execution requests
+type RunRequest struct {
+	Command string `json:"command"`
+}
+
+//RunResult result of a command execution
+type RunResult struct {
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+}
+
+//VolumeRequest body of volume creation requests
+type VolumeRequest struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+	//Speed SSD, HDD or COLD, HDD by default
+	Speed string `json:"speed,omitempty"`
+}
+
+//VolumeUpdateRequest body of volume update requests, the fields left out are not changed
+type VolumeUpdateRequest struct {
+	Name *string `json:"name,omitempty"`
+	Size *int    `json:"size,omitempty"`
+	//Speed SSD, HDD or COLD
+	Speed *string `json:"speed,omitempty"`
+}
+
+//AttachmentRequest body of volume attachment requests
+type AttachmentRequest struct {
+	VM     string `json:"vm"`
+	Path   string `json:"path,omitempty"`
+	Format string `json:"format,omitempty"`
+}
+
+//ContainerRequest body of container creation requests
+type ContainerRequest struct {
+	Name string `json:"name"`
+}
+
+//maxWait maximum waiting time of job requests
+const maxWait = 5 * time.Minute
+
+//TenantInfo tenant as exposed by the API
+type TenantInfo struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+}
+
+func (s *Server) initRoutes() {
+	prefix := "/" + APIVersion
+	s.handlePublic("GET", prefix+"/openapi.json", func(r *request) (int, interface{}, error) {
+		r.writer.Header().Set("Content-Type", "application/json")
+		r.writer.WriteHeader(http.StatusOK)
+		io.WriteString(r.writer, OpenAPI)
+		return 0, nil, nil
+	})
+	s.handle("GET", "/metrics", func(r *request) (int, interface{}, error) {
+		r.writer.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.writer.WriteHeader(http.StatusOK)
+		providers.DefaultMetrics.WritePrometheus(r.writer)
+		return 0, nil, nil
+	})
+	s.handle("GET", prefix+"/tenants", s.listTenants)
+
+	s.handle("GET", prefix+"/jobs", s.listJobs)
+	s.handle("GET", prefix+"/jobs/{job}", s.getJob)
+	s.handle("DELETE", prefix+"/jobs/{job}", s.cancelJob)
+
+	t := prefix + "/tenants/{tenant}"
+	s.handle("GET", t+"/networks", listNetworks)
+	s.handle("POST", t+"/networks", s.createNetwork)
+	s.handle("GET", t+"/networks/{network}", getNetwork)
+	s.handle("DELETE", t+"/networks/{network}", s.deleteNetwork)
+
+	s.handle("GET", t+"/vms", listVMs)
+	s.handle("POST", t+"/vms", s.createVM)
+	s.handle("GET", t+"/vms/{vm}", getVM)
+	s.handle("DELETE", t+"/vms/{vm}", s.deleteVM)
+	s.handle("POST", t+"/vms/{vm}/run", runCommand)
+
+	s.handle("GET", t+"/volumes", listVolumes)
+	s.handle("POST", t+"/volumes", s.createVolume)
+	s.handle("GET", t+"/volumes/{volume}", getVolume)
+	s.handle("DELETE", t+"/volumes/{volume}", s.deleteVolume)
+	s.handle("PATCH", t+"/volumes/{volume}", updateVolume)
+	s.handle("POST", t+"/volumes/{volume}/attachment", s.attachVolume)
+	s.handle("DELETE", t+"/volumes/{volume}/attachment", s.detachVolume)
+
+	s.handle("GET", t+"/containers", listContainers)
+	s.handle("POST", t+"/containers", createContainer)
+	s.handle("GET", t+"/containers/{container}", getContainer)
+	s.handle("DELETE", t+"/containers/{container}", deleteContainer)
+	s.handle("GET", t+"/containers/{container}/objects", listObjects)
+	s.handle("PUT", t+"/containers/{container}/objects/{object...}", putObject)
+	s.handle("GET", t+"/containers/{container}/objects/{object...}", getObject)
+	s.handle("DELETE", t+"/containers/{container}/objects/{object...}", deleteObject)
+}
+
+func (s *Server) listTenants(r *request) (int, interface{}, error) {
+	tenants, err := s.tenants.List()
+	if err != nil {
+		return 0, nil, err
+	}
+	list := []TenantInfo{}
+	for _, t := range tenants {
+		list = append(list, TenantInfo{Name: t.Name, Provider: t.Provider})
+	}
+	return http.StatusOK, list, nil
+}
+
+func (s *Server) listJobs(r *request) (int, interface{}, error) {
+	list, err := s.jobs.List()
+	if err != nil {
+		return 0, nil, err
+	}
+	tenant := r.URL.Query().Get("tenant")
+	jobs := []broker.Job{}
+	for _, j := range list {
+		if tenant == "" || j.Tenant == tenant {
+			jobs = append(jobs, j)
+		}
+	}
+	return http.StatusOK, jobs, nil
+}
+
+//getJob returns a job, the query parameter wait (e.g. 30s) waits for the job to be done
+func (s *Server) getJob(r *request) (int, interface{}, error) {
+	wait := r.URL.Query().Get("wait")
+	if wait == "" {
+		job, err := s.jobs.Get(r.params["job"])
+		return http.StatusOK, job, err
+	}
+	d, err := time.ParseDuration(wait)
+	if err != nil || d < 0 || d > maxWait {
+		return 0, nil, BadRequest{fmt.Sprintf("wait must be a duration between 0s and %s", maxWait)}
+	}
+	job, err := s.jobs.Wait(r.params["job"], d)
+	return http.StatusOK, job, err
+}
+
+func (s *Server) cancelJob(r *request) (int, interface{}, error) {
+	job, err := s.jobs.Get(r.params["job"])
+	if err != nil {
+		return 0, nil, err
+	}
+	if job.State.Done() {
+		return http.StatusConflict, Error{fmt.Sprintf("Job %s is %s", job.ID, job.State)}, nil
+	}
+	job, err = s.jobs.Cancel(job.ID)
+	return http.StatusOK, job, err
+}
+
+//defaults returns the defaults of the tenant of the request
+func (s *Server) defaults(r *request) (*broker.TenantDefaults, error) {
+	t, err := s.tenants.Get(r.params["tenant"])
+	if err != nil {
+		return nil, err
+	}
+	return &t.Defaults, nil
+}
+
+func listNetworks(r *request) (int, interface{}, error) {
+	list, err := broker.NewNetworkService(r.client).ListContext(r.Context())
+	if list == nil {
+		list = []api.Network{}
+	}
+	return http.StatusOK, list, err
+}
+
+func (s *Server) createNetwork(r *request) (int, interface{}, error) {
+	req := NetworkRequest{}
+	err := r.decode(&req)
+	if err != nil {
+		return 0, nil, err
+	}
+	if req.Name == "" {
+		return 0, nil, BadRequest{"name is required"}
+	}
+	if req.CIDR == "" {
+		req.CIDR = "192.168.0.0/24"
+	}
+	d, err := s.defaults(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	cpu, ram, disk, os := d.Resolve(req.CPU, req.RAM, req.Disk, req.OS)
+	if r.async() {
+		return s.submit(r, "network.create", broker.CreateNetworkArgs{Name: req.Name, CIDR: req.CIDR, CPU: cpu, RAM: ram, Disk: disk, OS: os})
+	}
+	n, err := broker.NewNetworkService(r.client).CreateContext(r.Context(), req.Name, req.CIDR, IPVersion.IPv4, cpu, ram, disk, os)
+	return http.StatusCreated, n, err
+}
+
+func getNetwork(r *request) (int, interface{}, error) {
+	n, err := broker.NewNetworkService(r.client).GetContext(r.Context(), r.params["network"])
+	return http.StatusOK, n, err
+}
+
+func (s *Server) deleteNetwork(r *request) (int, interface{}, error) {
+	if r.async() {
+		return s.submit(r, "network.delete", broker.RefArgs{Ref: r.params["network"]})
+	}
+	err := broker.NewNetworkService(r.client).DeleteContext(r.Context(), r.params["network"])
+	return http.StatusNoContent, nil, err
+}
+
+//maskVM removes the private key of the VM unless secrets are requested
+func maskVM(r *request, vm *api.VM) {
+	if vm != nil && !r.showSecrets() {
+		vm.PrivateKey = ""
+	}
+}
+
+func listVMs(r *request) (int, interface{}, error) {
+	list, err := broker.NewVMService(r.client).ListContext(r.Context())
+	if list == nil {
+		list = []api.VM{}
+	}
+	for i := range list {
+		maskVM(r, &list[i])
+	}
+	return http.StatusOK, list, err
+}
+
+func (s *Server) createVM(r *request) (int, interface{}, error) {
+	req := VMRequest{}
+	err := r.decode(&req)
+	if err != nil {
+		return 0, nil, err
+	}
+	if req.Name == "" || req.Network == "" {
+		return 0, nil, BadRequest{"name and network are required"}
+	}
+	d, err := s.defaults(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	cpu, ram, disk, os := d.Resolve(req.CPU, req.RAM, req.Disk, req.OS)
+	if r.async() {
+		return s.submit(r, "vm.create", broker.CreateVMArgs{Name: req.Name, Network: req.Network, CPU: cpu, RAM: ram, Disk: disk, OS: os, Public: req.Public})
+	}
+	vm, err := broker.NewVMService(r.client).CreateContext(r.Context(), req.Name, req.Network, cpu, ram, disk, os, req.Public)
+	maskVM(r, vm)
+	return http.StatusCreated, vm, err
+}
+
+func getVM(r *request) (int, interface{}, error) {
+	vm, err := broker.NewVMService(r.client).InspectContext(r.Context(), r.params["vm"])
+	maskVM(r, vm)
+	return http.StatusOK, vm, err
+}
+
+func (s *Server) deleteVM(r *request) (int, interface{}, error) {
+	if r.async() {
+		return s.submit(r, "vm.delete", broker.RefArgs{Ref: r.params["vm"]})
+	}
+	err := broker.NewVMService(r.client).DeleteContext(r.Context(), r.params["vm"])
+	return http.StatusNoContent, nil, err
+}
+
+func runCommand(r *request) (int, interface{}, error) {
+	req := RunRequest{}
+	err := r.decode(&req)
+	if err != nil {
+		return 0, nil, err
+	}
+	if req.Command == "" {
+		return 0, nil, BadRequest{"command is required"}
+	}
+	code, stdout, stderr, err := broker.NewSSHService(r.client).RunContext(r.Context(), r.params["vm"], req.Command)
+	return http.StatusOK, RunResult{ExitCode: code, Stdout: stdout, Stderr: stderr}, err
+}
+
+func listVolumes(r *request) (int, interface{}, error) {
+	list, err := broker.NewVolumeService(r.client).ListContext(r.Context())
+	if list == nil {
+		list = []api.Volume{}
+	}
+	return http.StatusOK, list, err
+}
+
+func (s *Server) createVolume(r *request) (int, interface{}, error) {
+	req := VolumeRequest{}
+	err := r.decode(&req)
+	if err != nil {
+		return 0, nil, err
+	}
+	if req.Name == "" || req.Size <= 0 {
+		return 0, nil, BadRequest{"name and a positive size are required"}
+	}
+	speed := VolumeSpeed.HDD
+	if req.Speed != "" {
+		speed, err = broker.ParseVolumeSpeed(req.Speed)
+		if err != nil {
+			return 0, nil, BadRequest{err.Error()}
+		}
+	}
+	if r.async() {
+		return s.submit(r, "volume.create", broker.CreateVolumeArgs{Name: req.Name, Size: req.Size, Speed: speed})
+	}
+	v, err := broker.NewVolumeService(r.client).CreateContext(r.Context(), req.Name, req.Size, speed)
+	return http.StatusCreated, v, err
+}
+
+func getVolume(r *request) (int, interface{}, error) {
+	v, err := broker.NewVolumeService(r.client).InspectContext(r.Context(), r.params["volume"])
+	return http.StatusOK, v, err
+}
+
+func (s *Server) deleteVolume(r *request) (int, interface{}, error) {
+	if r.async() {
+		return s.submit(r, "volume.delete", broker.RefArgs{Ref: r.params["volume"]})
+	}
+	err := broker.NewVolumeService(r.client).DeleteContext(r.Context(), r.params["volume"])
+	return http.StatusNoContent, nil, err
+}
+
+func updateVolume(r *request) (int, interface{}, error) {
+	req := VolumeUpdateRequest{}
+	err := r.decode(&req)
+	if err != nil {
+		return 0, nil, err
+	}
+	update := api.VolumeUpdateRequest{Name: req.Name, Size: req.Size}
+	if req.Speed != nil {
+		speed, err := broker.ParseVolumeSpeed(*req.Speed)
+		if err != nil {
+			return 0, nil, BadRequest{err.Error()}
+		}
+		update.Speed = &speed
+	}
+	if update.Name == nil && update.Size == nil && update.Speed == nil {
+		return 0, nil, BadRequest{"name, size or speed is required"}
+	}
+	v, err := broker.NewVolumeService(r.client).UpdateContext(r.Context(), r.params["volume"], update)
+	return http.StatusOK, v, err
+}
+
+func (s *Server) attachVolume(r *request) (int, interface{}, error) {
+	req := AttachmentRequest{}
+	err := r.decode(&req)
+	if err != nil {
+		return 0, nil, err
+	}
+	if req.VM == "" {
+		return 0, nil, BadRequest{"vm is required"}
+	}
+	if r.async() {
+		return s.submit(r, "volume.attach", broker.AttachVolumeArgs{Volume: r.params["volume"], VM: req.VM, Path: req.Path, Format: req.Format})
+	}
+	va, err := broker.NewVolumeService(r.client).AttachContext(r.Context(), r.params["volume"], req.VM, req.Path, req.Format)
+	return http.StatusCreated, va, err
+}
+
+func (s *Server) detachVolume(r *request) (int, interface{}, error) {
+	if r.async() {
+		return s.submit(r, "volume.detach", broker.RefArgs{Ref: r.params["volume"]})
+	}
+	err := broker.NewVolumeService(r.client).DetachContext(r.Context(), r.params["volume"])
+	return http.StatusNoContent, nil, err
+}
+
+func listContainers(r *request) (int, interface{}, error) {
+	list, err := broker.NewContainerService(r.client).ListContext(r.Context())
+	if list == nil {
+		list = []string{}
+	}
+	return http.StatusOK, list, err
+}
+
+func createContainer(r *request) (int, interface{}, error) {
+	req := ContainerRequest{}
+	err := r.decode(&req)
+	if err != nil {
+		return 0, nil, err
+	}
+	if req.Name == "" {
+		return 0, nil, BadRequest{"name is required"}
+	}
+	srv := broker.NewContainerService(r.client)
+	if _, err := srv.InspectContext(r.Context(), req.Name); err == nil {
+		return 0, nil, providers.ResourceAlreadyExistsError("Container", req.Name)
+	}
+	err = srv.CreateContext(r.Context(), req.Name)
+	return http.StatusCreated, broker.Container{Name: req.Name, Objects: []string{}}, err
+}
+
+func getContainer(r *request) (int, interface{}, error) {
+	c, err := broker.NewContainerService(r.client).InspectContext(r.Context(), r.params["container"])
+	if c != nil && c.Objects == nil {
+		c.Objects = []string{}
+	}
+	return http.StatusOK, c, err
+}
+
+func deleteContainer(r *request) (int, interface{}, error) {
+	srv := broker.NewContainerService(r.client)
+	if _, err := srv.InspectContext(r.Context(), r.params["container"]); err != nil {
+		return 0, nil, err
+	}
+	err := srv.DeleteContext(r.Context(), r.params["container"])
+	return http.StatusNoContent, nil, err
+}
+
+func listObjects(r *request) (int, interface{}, error) {
+	if _, err := broker.NewContainerService(r.client).InspectContext(r.Context(), r.params["container"]); err != nil {
+		return 0, nil, err
+	}
+	list, err := api.WithContext(r.client).ListObjectsContext(r.Context(), r.params["container"], api.ObjectFilter{
+		Prefix: r.URL.Query().Get("prefix"),
+		Path:   r.URL.Query().Get("path"),
+	})
+	if list == nil {
+		list = []string{}
+	}
+	return http.StatusOK, list, err
+}
+
+func putObject(r *request) (int, interface{}, error) {
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return 0, nil, BadRequest{err.Error()}
+	}
+	obj := api.Object{
+		Name:        r.params["object"],
+		Content:     bytes.NewReader(content),
+		ContentType: r.Header.Get("Content-Type"),
+	}
+	err = api.WithContext(r.client).PutObjectContext(r.Context(), r.params["container"], obj)
+	return http.StatusNoContent, nil, err
+}
+
+func getObject(r *request) (int, interface{}, error) {
+	clt := api.WithContext(r.client)
+	obj, err := clt.GetObjectContext(r.Context(), r.params["container"], r.params["object"], nil)
+	if err != nil {
+		if _, e := clt.GetObjectMetadataContext(r.Context(), r.params["container"], r.params["object"]); e != nil {
+			return 0, nil, providers.ResourceNotFoundError("Object", r.params["object"])
+		}
+		return 0, nil, err
+	}
+	if obj.ContentType != "" {
+		r.writer.Header().Set("Content-Type", obj.ContentType)
+	} else {
+		r.writer.Header().Set("Content-Type", "application/octet-stream")
+	}
+	r.writer.WriteHeader(http.StatusOK)
+	if obj.Content != nil {
+		io.Copy(r.writer, obj.Content)
+	}
+	return 0, nil, nil
+}
+
+func deleteObject(r *request) (int, interface{}, error) {
+	clt := api.WithContext(r.client)
+	if _, err := clt.GetObjectMetadataContext(r.Context(), r.params["container"], r.params["object"]); err != nil {
+		return 0, nil, providers.ResourceNotFoundError("Object", r.params["object"])
+	}
+	err := clt.DeleteObjectContext(r.Context(), r.params["container"], r.params["object"])
+	return http.StatusNoContent, nil, err
+}
@@ -0,0 +1,1667 @@
+package server
+
+//OpenAPI OpenAPI document of the REST API
+const OpenAPI = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "gpac broker API",
+    "version": "1.0.0",
+    "description": "Manage networks, VMs, volumes and objects of the broker tenants, long-running operations can be executed by jobs"
+  },
+  "servers": [
+    {
+      "url": "/v1"
+    }
+  ],
+  "security": [
+    {
+      "bearerAuth": []
+    }
+  ],
+  "paths": {
+    "/tenants": {
+      "get": {
+        "summary": "list tenants",
+        "operationId": "listTenants",
+        "responses": {
+          "200": {
+            "description": "tenants",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/Tenant"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/jobs": {
+      "get": {
+        "summary": "list jobs ordered by creation date",
+        "operationId": "listJobs",
+        "parameters": [
+          {
+            "name": "tenant",
+            "in": "query",
+            "required": false,
+            "description": "only list the jobs of the tenant",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "jobs",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/Job"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/jobs/{job}": {
+      "parameters": [
+        {
+          "name": "job",
+          "in": "path",
+          "required": true,
+          "description": "ID of the job",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "get a job",
+        "operationId": "getJob",
+        "parameters": [
+          {
+            "name": "wait",
+            "in": "query",
+            "required": false,
+            "description": "maximum time waiting for the job to be done (e.g. 30s, at most 5m)",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "job",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "delete": {
+        "summary": "cancel a job, running jobs are cancelled between steps",
+        "operationId": "cancelJob",
+        "responses": {
+          "200": {
+            "description": "job",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/networks": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "list networks",
+        "operationId": "listNetworks",
+        "responses": {
+          "200": {
+            "description": "networks",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/Network"
+                  }
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "post": {
+        "summary": "create a network and its gateway gw_<name>",
+        "operationId": "createNetwork",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/Async"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/NetworkRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "created network",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Network"
+                }
+              }
+            }
+          },
+          "202": {
+            "description": "queued job, the Location header is the path of the job",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/networks/{network}": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        },
+        {
+          "name": "network",
+          "in": "path",
+          "required": true,
+          "description": "name or ID of the network",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "get a network",
+        "operationId": "getNetwork",
+        "responses": {
+          "200": {
+            "description": "network",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Network"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "delete": {
+        "summary": "delete a network",
+        "operationId": "deleteNetwork",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/Async"
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "deleted"
+          },
+          "202": {
+            "description": "queued job, the Location header is the path of the job",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/vms": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "list vms",
+        "operationId": "listVMs",
+        "responses": {
+          "200": {
+            "description": "vms",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/VM"
+                  }
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "parameters": [
+          {
+            "name": "show_secrets",
+            "in": "query",
+            "required": false,
+            "description": "include private keys in the response",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ]
+      },
+      "post": {
+        "summary": "create a VM",
+        "operationId": "createVM",
+        "parameters": [
+          {
+            "name": "show_secrets",
+            "in": "query",
+            "required": false,
+            "description": "include private keys in the response",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/VMRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "created vm",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/VM"
+                }
+              }
+            }
+          },
+          "202": {
+            "description": "queued job, the Location header is the path of the job",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/vms/{vm}": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        },
+        {
+          "name": "vm",
+          "in": "path",
+          "required": true,
+          "description": "name or ID of the VM",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "get a vm",
+        "operationId": "getVM",
+        "responses": {
+          "200": {
+            "description": "vm",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/VM"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "parameters": [
+          {
+            "name": "show_secrets",
+            "in": "query",
+            "required": false,
+            "description": "include private keys in the response",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ]
+      },
+      "delete": {
+        "summary": "delete a vm",
+        "operationId": "deleteVM",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/Async"
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "deleted"
+          },
+          "202": {
+            "description": "queued job, the Location header is the path of the job",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/vms/{vm}/run": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        },
+        {
+          "name": "vm",
+          "in": "path",
+          "required": true,
+          "description": "name or ID of the VM",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "post": {
+        "summary": "run a command on a VM over SSH",
+        "operationId": "runCommand",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/RunRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "result of the command",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RunResult"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/volumes": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "list volumes",
+        "operationId": "listVolumes",
+        "responses": {
+          "200": {
+            "description": "volumes",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/Volume"
+                  }
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "post": {
+        "summary": "create a volume",
+        "operationId": "createVolume",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/Async"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/VolumeRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "created volume",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Volume"
+                }
+              }
+            }
+          },
+          "202": {
+            "description": "queued job, the Location header is the path of the job",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/volumes/{volume}": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        },
+        {
+          "name": "volume",
+          "in": "path",
+          "required": true,
+          "description": "name or ID of the volume",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "get a volume",
+        "operationId": "getVolume",
+        "responses": {
+          "200": {
+            "description": "volume",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Volume"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "patch": {
+        "summary": "rename, resize or change the speed of a volume",
+        "operationId": "updateVolume",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/VolumeUpdateRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "updated volume",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Volume"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "delete": {
+        "summary": "delete a volume",
+        "operationId": "deleteVolume",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/Async"
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "deleted"
+          },
+          "202": {
+            "description": "queued job, the Location header is the path of the job",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/volumes/{volume}/attachment": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        },
+        {
+          "name": "volume",
+          "in": "path",
+          "required": true,
+          "description": "name or ID of the volume",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "post": {
+        "summary": "attach a volume to a VM, format it if it has no file system and mount it",
+        "operationId": "attachVolume",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/Async"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/AttachmentRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "attachment",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/VolumeAttachment"
+                }
+              }
+            }
+          },
+          "202": {
+            "description": "queued job, the Location header is the path of the job",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "delete": {
+        "summary": "unmount a volume and detach it from its VM",
+        "operationId": "detachVolume",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/Async"
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "detached"
+          },
+          "202": {
+            "description": "queued job, the Location header is the path of the job",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/containers": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "list containers",
+        "operationId": "listContainers",
+        "responses": {
+          "200": {
+            "description": "containers",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "post": {
+        "summary": "create an object container",
+        "operationId": "createContainer",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/ContainerRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "created container",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Container"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/containers/{container}": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        },
+        {
+          "name": "container",
+          "in": "path",
+          "required": true,
+          "description": "name of the container",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "get a container",
+        "operationId": "getContainer",
+        "responses": {
+          "200": {
+            "description": "container",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Container"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "delete": {
+        "summary": "delete a container",
+        "operationId": "deleteContainer",
+        "responses": {
+          "204": {
+            "description": "deleted"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/containers/{container}/objects": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        },
+        {
+          "name": "container",
+          "in": "path",
+          "required": true,
+          "description": "name of the container",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "list objects",
+        "operationId": "listObjects",
+        "parameters": [
+          {
+            "name": "prefix",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "path",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "object names",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/tenants/{tenant}/containers/{container}/objects/{object}": {
+      "parameters": [
+        {
+          "name": "tenant",
+          "in": "path",
+          "required": true,
+          "description": "name of the tenant",
+          "schema": {
+            "type": "string"
+          }
+        },
+        {
+          "name": "container",
+          "in": "path",
+          "required": true,
+          "description": "name of the container",
+          "schema": {
+            "type": "string"
+          }
+        },
+        {
+          "name": "object",
+          "in": "path",
+          "required": true,
+          "description": "name of the object, it may contain slashes",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "put": {
+        "summary": "put an object",
+        "operationId": "putObject",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "*/*": {
+              "schema": {
+                "type": "string",
+                "format": "binary"
+              }
+            }
+          }
+        },
+        "responses": {
+          "204": {
+            "description": "stored"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "get": {
+        "summary": "get the content of an object",
+        "operationId": "getObject",
+        "responses": {
+          "200": {
+            "description": "content of the object",
+            "content": {
+              "*/*": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "delete": {
+        "summary": "delete an object",
+        "operationId": "deleteObject",
+        "responses": {
+          "204": {
+            "description": "deleted"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "this document",
+        "operationId": "getOpenAPI",
+        "responses": {
+          "200": {
+            "description": "OpenAPI document",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/metrics": {
+      "servers": [
+        {
+          "url": "/"
+        }
+      ],
+      "get": {
+        "summary": "metrics of the provider calls in the Prometheus text format",
+        "operationId": "getMetrics",
+        "responses": {
+          "200": {
+            "description": "latency histograms and error counters of the provider calls",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearerAuth": {
+        "type": "http",
+        "scheme": "bearer",
+        "description": "token of a user of the broker, required when the server is started with --tokens. The X-Broker-User header is recorded in the audit log as the user claimed by the caller, next to the authenticated user"
+      }
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "properties": {
+          "error": {
+            "type": "string"
+          }
+        },
+        "required": [
+          "error"
+        ]
+      },
+      "Tenant": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "provider": {
+            "type": "string"
+          }
+        }
+      },
+      "Network": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "ip_version": {
+            "type": "integer",
+            "description": "4 or 6"
+          },
+          "mask": {
+            "type": "string",
+            "description": "CIDR of the network"
+          },
+          "GatewayID": {
+            "type": "string"
+          }
+        }
+      },
+      "NetworkRequest": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "cidr": {
+            "type": "string",
+            "default": "192.168.0.0/24"
+          },
+          "cpu": {
+            "type": "integer",
+            "description": "minimum number of cores of the gateway"
+          },
+          "ram": {
+            "type": "number",
+            "description": "minimum RAM size of the gateway in GB"
+          },
+          "disk": {
+            "type": "integer",
+            "description": "minimum disk size of the gateway in GB"
+          },
+          "os": {
+            "type": "string",
+            "description": "operating system of the gateway"
+          }
+        },
+        "required": [
+          "name"
+        ]
+      },
+      "VMSize": {
+        "type": "object",
+        "properties": {
+          "cores": {
+            "type": "integer"
+          },
+          "ram_size": {
+            "type": "number"
+          },
+          "disk_size": {
+            "type": "integer"
+          }
+        }
+      },
+      "VM": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "private_ips_v4": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "private_ips_v6": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "access_ip_v4": {
+            "type": "string"
+          },
+          "access_ip_v6": {
+            "type": "string"
+          },
+          "size": {
+            "$ref": "#/components/schemas/VMSize"
+          },
+          "state": {
+            "type": "integer",
+            "description": "0: STOPPED, 1: STARTING, 2: STARTED, 3: STOPPING, 4: ERROR"
+          },
+          "private_key": {
+            "type": "string",
+            "description": "only returned with show_secrets=true"
+          },
+          "gateway_id": {
+            "type": "string"
+          }
+        }
+      },
+      "VMRequest": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "network": {
+            "type": "string",
+            "description": "name or ID of the network"
+          },
+          "cpu": {
+            "type": "integer"
+          },
+          "ram": {
+            "type": "number"
+          },
+          "disk": {
+            "type": "integer"
+          },
+          "os": {
+            "type": "string"
+          },
+          "public": {
+            "type": "boolean",
+            "description": "the VM has a public IP"
+          }
+        },
+        "required": [
+          "name",
+          "network"
+        ]
+      },
+      "RunRequest": {
+        "type": "object",
+        "properties": {
+          "command": {
+            "type": "string"
+          }
+        },
+        "required": [
+          "command"
+        ]
+      },
+      "RunResult": {
+        "type": "object",
+        "properties": {
+          "exit_code": {
+            "type": "integer"
+          },
+          "stdout": {
+            "type": "string"
+          },
+          "stderr": {
+            "type": "string"
+          }
+        }
+      },
+      "Volume": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "size": {
+            "type": "integer",
+            "description": "size in GB"
+          },
+          "speed": {
+            "type": "integer",
+            "description": "0: SSD, 1: HDD, 2: COLD"
+          },
+          "state": {
+            "type": "integer"
+          }
+        }
+      },
+      "VolumeRequest": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "size": {
+            "type": "integer",
+            "description": "size in GB"
+          },
+          "speed": {
+            "type": "string",
+            "enum": [
+              "SSD",
+              "HDD",
+              "COLD"
+            ],
+            "default": "HDD"
+          }
+        },
+        "required": [
+          "name",
+          "size"
+        ]
+      },
+      "VolumeUpdateRequest": {
+        "type": "object",
+        "description": "the properties left out are not changed",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "size": {
+            "type": "integer",
+            "description": "size in GB, volumes can only grow"
+          },
+          "speed": {
+            "type": "string",
+            "enum": [
+              "SSD",
+              "HDD",
+              "COLD"
+            ]
+          }
+        }
+      },
+      "AttachmentRequest": {
+        "type": "object",
+        "properties": {
+          "vm": {
+            "type": "string",
+            "description": "name or ID of the VM"
+          },
+          "path": {
+            "type": "string",
+            "description": "mount point, /shared/<volume> by default"
+          },
+          "format": {
+            "type": "string",
+            "default": "ext4"
+          }
+        },
+        "required": [
+          "vm"
+        ]
+      },
+      "VolumeAttachment": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "volume": {
+            "type": "string"
+          },
+          "vm": {
+            "type": "string"
+          },
+          "device": {
+            "type": "string"
+          }
+        }
+      },
+      "ContainerRequest": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          }
+        },
+        "required": [
+          "name"
+        ]
+      },
+      "Container": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "objects": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "Job": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "tenant": {
+            "type": "string"
+          },
+          "operation": {
+            "type": "string",
+            "enum": [
+              "network.create",
+              "network.delete",
+              "vm.create",
+              "vm.delete",
+              "volume.create",
+              "volume.delete",
+              "volume.attach",
+              "volume.detach"
+            ]
+          },
+          "args": {
+            "type": "object",
+            "description": "arguments of the operation"
+          },
+          "state": {
+            "type": "string",
+            "enum": [
+              "queued",
+              "running",
+              "succeeded",
+              "failed",
+              "cancelled"
+            ]
+          },
+          "steps": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "time": {
+                  "type": "string",
+                  "format": "date-time"
+                },
+                "message": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "result": {
+            "type": "object",
+            "description": "created resource of succeeded create and attach operations"
+          },
+          "error": {
+            "type": "string",
+            "description": "error of failed jobs"
+          },
+          "created": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "updated": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      }
+    },
+    "parameters": {
+      "Async": {
+        "name": "async",
+        "in": "query",
+        "required": false,
+        "description": "when true the operation is executed by a job, the response is the queued job",
+        "schema": {
+          "type": "boolean",
+          "default": false
+        }
+      }
+    },
+    "responses": {
+      "BadRequest": {
+        "description": "invalid request",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "NotFound": {
+        "description": "tenant or resource not found",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "Conflict": {
+        "description": "resource already exists",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "Error": {
+        "description": "unexpected error",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,277 @@
+//Package server exposes the broker services over a REST API
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/SebastienDorgan/gpac/broker"
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//APIVersion version of the REST API, it prefixes every path
+const APIVersion = "v1"
+
+//UserHeader header naming the user the caller claims to act for, it is recorded in the audit log next to the
+//authenticated user. Operations are recorded on behalf of the user running the server when tokens are not required
+const UserHeader = "X-Broker-User"
+
+//params path parameters of a request
+type params map[string]string
+
+//handler handles a request of a tenant, the returned value is written in JSON with the returned status
+type handler func(r *request) (int, interface{}, error)
+
+//route a route of the API
+type route struct {
+	method   string
+	segments []string
+	handler  handler
+	//public routes do not require authentication
+	public bool
+}
+
+//request a request routed to a handler
+type request struct {
+	*http.Request
+	params params
+	writer http.ResponseWriter
+	client api.ClientAPI
+}
+
+//BadRequest error raised when a request is invalid
+type BadRequest struct {
+	Message string
+}
+
+func (e BadRequest) Error() string {
+	return e.Message
+}
+
+//Server REST API server, clients of the tenants are created on first use and kept for the life of the server
+type Server struct {
+	tenants broker.TenantAPI
+	jobs    broker.JobAPI
+	routes  []route
+	mu      sync.Mutex
+	clients map[string]api.ClientAPI
+	tokens  Tokens
+}
+
+//New creates a server exposing the tenants and the jobs, requests with the query parameter async=true submit jobs
+func New(tenants broker.TenantAPI, jobs broker.JobAPI) *Server {
+	s := &Server{
+		tenants: tenants,
+		jobs:    jobs,
+		clients: map[string]api.ClientAPI{},
+	}
+	s.initRoutes()
+	return s
+}
+
+//RequireTokens requires requests to carry the bearer token of one of the users of tokens, operations are recorded on
+//behalf of this user
+func (s *Server) RequireTokens(tokens Tokens) {
+	s.tokens = tokens
+}
+
+//handle registers a handler, path segments between braces are parameters, {name...} matches the rest of the path
+func (s *Server) handle(method string, path string, h handler) {
+	s.routes = append(s.routes, route{
+		method:   method,
+		segments: strings.Split(strings.Trim(path, "/"), "/"),
+		handler:  h,
+	})
+}
+
+//handlePublic registers a handler which does not require authentication
+func (s *Server) handlePublic(method string, path string, h handler) {
+	s.handle(method, path, h)
+	s.routes[len(s.routes)-1].public = true
+}
+
+//match returns the parameters of path if it matches the route
+func (rt *route) match(path string) (params, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	p := params{}
+	for i, s := range rt.segments {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "...}") {
+			if i >= len(segments) {
+				return nil, false
+			}
+			p[strings.TrimSuffix(s[1:], "...}")] = strings.Join(segments[i:], "/")
+			return p, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			p[s[1:len(s)-1]] = segments[i]
+			continue
+		}
+		if s != segments[i] {
+			return nil, false
+		}
+	}
+	return p, len(segments) == len(rt.segments)
+}
+
+//Client returns the client of the tenant, it is shared with the job engine
+//The lock is not held while the client authenticates, so that a slow provider does not block the other tenants
+func (s *Server) Client(tenant string) (api.ClientAPI, error) {
+	s.mu.Lock()
+	clt, ok := s.clients[tenant]
+	s.mu.Unlock()
+	if ok {
+		return clt, nil
+	}
+	clt, err := s.tenants.Client(tenant)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	//the client created first by concurrent requests is kept
+	if existing, ok := s.clients[tenant]; ok {
+		return existing, nil
+	}
+	s.clients[tenant] = clt
+	return clt, nil
+}
+
+//status returns the HTTP status corresponding to err
+func status(err error) int {
+	if _, ok := err.(BadRequest); ok {
+		return http.StatusBadRequest
+	}
+	switch api.KindOf(err) {
+	case api.ErrNotFound:
+		return http.StatusNotFound
+	case api.ErrAlreadyExists, api.ErrConflict:
+		return http.StatusConflict
+	case api.ErrInvalidRequest:
+		return http.StatusBadRequest
+	case api.ErrQuotaExceeded:
+		return http.StatusTooManyRequests
+	case api.ErrUnauthorized:
+		//the credentials of the tenant are rejected by the provider, not the ones of the caller
+		return http.StatusBadGateway
+	case api.ErrTimeout:
+		return http.StatusGatewayTimeout
+	case api.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+//writeJSON writes v in JSON
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if v != nil {
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+//Error body of error responses
+type Error struct {
+	Error string `json:"error"`
+}
+
+//ServeHTTP routes the request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	allowed := false
+	for _, rt := range s.routes {
+		p, ok := rt.match(r.URL.Path)
+		if !ok {
+			continue
+		}
+		if rt.method != r.Method {
+			allowed = true
+			continue
+		}
+		user := ""
+		if s.tokens != nil && !rt.public {
+			if user, ok = s.tokens.user(r); !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="broker"`)
+				writeJSON(w, http.StatusUnauthorized, Error{"Missing or invalid bearer token"})
+				return
+			}
+		}
+		req := &request{
+			Request: r,
+			params:  p,
+			writer:  w,
+		}
+		if tenant, ok := p["tenant"]; ok {
+			clt, err := s.Client(tenant)
+			if err != nil {
+				writeJSON(w, status(err), Error{err.Error()})
+				return
+			}
+			if ac, ok := clt.(*providers.AuditClient); ok {
+				if user != "" {
+					ac = ac.As(user)
+				}
+				if claimed := r.Header.Get(UserHeader); claimed != "" {
+					ac = ac.Claiming(claimed)
+				}
+				clt = ac
+			}
+			req.client = clt
+		}
+		code, v, err := rt.handler(req)
+		if err != nil {
+			writeJSON(w, status(err), Error{err.Error()})
+			return
+		}
+		//handlers writing the response themselves return a 0 status
+		if code != 0 {
+			writeJSON(w, code, v)
+		}
+		return
+	}
+	if allowed {
+		writeJSON(w, http.StatusMethodNotAllowed, Error{fmt.Sprintf("Method %s not allowed on %s", r.Method, r.URL.Path)})
+		return
+	}
+	writeJSON(w, http.StatusNotFound, Error{fmt.Sprintf("Unknown path %s", r.URL.Path)})
+}
+
+//decode decodes the JSON body of the request in v
+func (r *request) decode(v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err != nil {
+		return BadRequest{fmt.Sprintf("Invalid request body: %s", err.Error())}
+	}
+	return nil
+}
+
+//async tells if the request must be executed by a job
+func (r *request) async() bool {
+	return r.URL.Query().Get("async") == "true"
+}
+
+//submit submits a job executing operation, the response is the job with the status 202
+func (s *Server) submit(r *request, operation string, args interface{}) (int, interface{}, error) {
+	job, err := s.jobs.Submit(r.params["tenant"], operation, args)
+	if err != nil {
+		return 0, nil, err
+	}
+	r.writer.Header().Set("Location", "/"+APIVersion+"/jobs/"+job.ID)
+	return http.StatusAccepted, job, nil
+}
+
+//showSecrets tells if secrets must be included in the response
+func (r *request) showSecrets() bool {
+	return r.URL.Query().Get("show_secrets") == "true"
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/broker"
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	_ "github.com/SebastienDorgan/gpac/providers/memory"
+)
+
+//newServer returns a server exposing the tenant m1 of the memory provider
+func newServer(t *testing.T) (*Server, broker.TenantAPI) {
+	t.Setenv("BROKER_VAULT_PASSPHRASE", "passphrase")
+	dir := t.TempDir()
+	srv := broker.NewTenantService(dir)
+	_, err := srv.Add("m1", "memory", map[string]interface{}{}, broker.TenantDefaults{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(srv, broker.NewJobService(dir)), srv
+}
+
+func do(t *testing.T, s *Server, method string, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestTokens(t *testing.T) {
+	s, srv := newServer(t)
+	s.RequireTokens(Tokens{"alice": Digest("alice-token")})
+
+	w := do(t, s, "GET", "/v1/tenants/m1/containers", "", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", w.Code)
+	}
+	w = do(t, s, "GET", "/v1/tenants/m1/containers", "", map[string]string{"Authorization": "Bearer wrong"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with an invalid token, got %d", w.Code)
+	}
+	w = do(t, s, "GET", "/v1/openapi.json", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the OpenAPI document to be public, got %d", w.Code)
+	}
+
+	headers := map[string]string{"Authorization": "Bearer alice-token", UserHeader: "bob"}
+	w = do(t, s, "POST", "/v1/tenants/m1/containers", `{"name":"c1"}`, headers)
+	if w.Code != http.StatusCreated && w.Code != http.StatusOK {
+		t.Fatalf("expected the container to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	sink, err := srv.Audit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := sink.List(providers.AuditFilter{Tenant: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 audit record, got %d", len(records))
+	}
+	if records[0].User != "alice" || records[0].ClaimedUser != "bob" {
+		t.Errorf("expected user alice claiming bob, got %s claiming %s", records[0].User, records[0].ClaimedUser)
+	}
+}
+
+//slowTenants tenants whose clients take time to authenticate
+type slowTenants struct {
+	broker.TenantAPI
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowTenants) Client(name string) (api.ClientAPI, error) {
+	if name == "slow" {
+		close(s.started)
+		<-s.release
+	}
+	return s.TenantAPI.Client(name)
+}
+
+func TestClientDoesNotBlockOtherTenants(t *testing.T) {
+	_, srv := newServer(t)
+	tenants := &slowTenants{TenantAPI: srv, started: make(chan struct{}), release: make(chan struct{})}
+	s := New(tenants, nil)
+	go s.Client("slow")
+	defer close(tenants.release)
+	<-tenants.started
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Client("m1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client of m1 is blocked by the authentication of another tenant")
+	}
+}
+
+func TestCancelledRequest(t *testing.T) {
+	s, _ := newServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	//the client gave up, the network is not created
+	r := httptest.NewRequest("POST", "/v1/tenants/m1/networks", strings.NewReader(`{"name":"net1"}`)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code == http.StatusCreated {
+		t.Fatal("network created by a cancelled request")
+	}
+	w = do(t, s, "GET", "/v1/tenants/m1/networks", "", nil)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Fatalf("expected no network, got %d: %s", w.Code, w.Body.String())
+	}
+
+	//the volume is not renamed
+	w = do(t, s, "POST", "/v1/tenants/m1/volumes", `{"name":"v1","size":10}`, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	r = httptest.NewRequest("PATCH", "/v1/tenants/m1/volumes/v1", strings.NewReader(`{"name":"v2"}`)).WithContext(ctx)
+	s.ServeHTTP(httptest.NewRecorder(), r)
+	w = do(t, s, "GET", "/v1/tenants/m1/volumes/v1", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("volume renamed by a cancelled request: %d: %s", w.Code, w.Body.String())
+	}
+
+	//the object is not stored
+	w = do(t, s, "POST", "/v1/tenants/m1/containers", `{"name":"c1"}`, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	r = httptest.NewRequest("PUT", "/v1/tenants/m1/containers/c1/objects/o1", strings.NewReader("content")).WithContext(ctx)
+	s.ServeHTTP(httptest.NewRecorder(), r)
+	w = do(t, s, "GET", "/v1/tenants/m1/containers/c1/objects", "", nil)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Fatalf("expected no object, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -1,8 +1,22 @@
 package broker
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
 	"github.com/SebastienDorgan/gpac/providers"
 	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/system"
 )
 
 // broker ssh connect vm2
@@ -12,9 +26,14 @@ import (
 
 //SSHAPI defines ssh management API
 type SSHAPI interface {
+	//Connect opens an interactive session on the VM, the current process is replaced by the ssh client
 	Connect(name string) error
-	Run(ref string) error
-	Scp(from string, to string)
+	//Run runs cmd on the VM and returns the exit code, the standard output and the standard error of the command
+	Run(ref string, cmd string) (int, string, string, error)
+	//RunContext is like Run but includes a context, the command is killed when the context is done
+	RunContext(ctx context.Context, ref string, cmd string) (int, string, string, error)
+	//Scp copies a file from or to a VM, the remote path is prefixed by the name of the VM: vm1:/tmp/file.txt
+	Scp(from string, to string) error
 }
 
 //NewSSHService creates a SSH service
@@ -30,3 +49,217 @@ type SSHService struct {
 	provider *providers.Service
 	vm       VMAPI
 }
+
+func (srv *SSHService) sshConfig(ref string) (*system.SSHConfig, error) {
+	return srv.sshConfigContext(context.Background(), ref)
+}
+
+func (srv *SSHService) sshConfigContext(ctx context.Context, ref string) (*system.SSHConfig, error) {
+	vm, err := srv.vm.InspectContext(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return contextAPI(srv.provider).GetSSHConfigContext(ctx, vm.ID)
+}
+
+//Connect opens an interactive session on the VM
+func (srv *SSHService) Connect(name string) error {
+	ssh, err := srv.sshConfig(name)
+	if err != nil {
+		return err
+	}
+	return ssh.Exec("")
+}
+
+//Run runs cmd on the VM ref
+func (srv *SSHService) Run(ref string, cmd string) (int, string, string, error) {
+	return srv.RunContext(context.Background(), ref, cmd)
+}
+
+//RunContext is like Run but includes a context
+func (srv *SSHService) RunContext(ctx context.Context, ref string, cmd string) (int, string, string, error) {
+	ssh, err := srv.sshConfigContext(ctx, ref)
+	if err != nil {
+		return 0, "", "", err
+	}
+	c, err := ssh.CommandContext(ctx, cmd)
+	if err != nil {
+		return 0, "", "", err
+	}
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return 0, "", "", err
+	}
+	stderr, err := c.StderrPipe()
+	if err != nil {
+		return 0, "", "", err
+	}
+	err = c.Start()
+	if err != nil {
+		return 0, "", "", err
+	}
+	var outBuf, errBuf bytes.Buffer
+	done := make(chan bool)
+	go func() {
+		errBuf.ReadFrom(stderr)
+		done <- true
+	}()
+	outBuf.ReadFrom(stdout)
+	<-done
+	err = c.Wait()
+	if err != nil && ctx.Err() != nil {
+		//the command was killed
+		return 0, "", "", ctx.Err()
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), outBuf.String(), errBuf.String(), nil
+	}
+	if err != nil {
+		return 0, "", "", err
+	}
+	return 0, outBuf.String(), errBuf.String(), nil
+}
+
+//splitRemotePath splits vm1:/tmp/file.txt in vm1 and /tmp/file.txt, ok is false for a local path
+func splitRemotePath(path string) (string, string, bool) {
+	i := strings.Index(path, ":")
+	if i <= 0 || strings.ContainsAny(path[:i], `/\`) {
+		return "", path, false
+	}
+	remote := path[i+1:]
+	//vm1://tmp is accepted as vm1:/tmp
+	if strings.HasPrefix(remote, "//") {
+		remote = remote[1:]
+	}
+	return path[:i], remote, true
+}
+
+//quote quotes s to be used in a shell command
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
+//checkPath checks that path can be written in the tables of a VM (/etc/fstab, /etc/exports), their fields are
+//separated by whitespaces and their comments start with #
+func checkPath(path string) error {
+	if strings.IndexFunc(path, unicode.IsSpace) >= 0 || strings.Contains(path, "#") {
+		return api.NewError(api.ErrInvalidRequest, nil, "Invalid path %q: a path cannot contain whitespaces or #", path)
+	}
+	return nil
+}
+
+//sedPattern escapes the characters of s which are special in the basic regular expressions of sed
+func sedPattern(s string) string {
+	return sedSpecial.ReplaceAllString(s, `\$0`)
+}
+
+var sedSpecial = regexp.MustCompile(`[][\\.*^$]`)
+
+//upload copies the local file src to dst on the VM, the content is streamed on the standard input of ssh
+func (srv *SSHService) upload(src string, vm string, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ssh, err := srv.sshConfig(vm)
+	if err != nil {
+		return err
+	}
+	c, err := ssh.CommandWithInputContext(context.Background(), fmt.Sprintf(`dst=%s; if [ -d "$dst" ]; then dst="$dst"/%s; fi; cat > "$dst"`,
+		quote(dst), quote(filepath.Base(src))))
+	if err != nil {
+		return err
+	}
+	stdin, err := c.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := c.StderrPipe()
+	if err != nil {
+		return err
+	}
+	err = c.Start()
+	if err != nil {
+		return err
+	}
+	var errBuf bytes.Buffer
+	done := make(chan bool)
+	go func() {
+		errBuf.ReadFrom(stderr)
+		done <- true
+	}()
+	_, err = io.Copy(stdin, f)
+	stdin.Close()
+	<-done
+	werr := c.Wait()
+	if exitErr, ok := werr.(*exec.ExitError); ok {
+		return fmt.Errorf("exit code %d: %s", exitErr.ExitCode(), strings.TrimSpace(errBuf.String()))
+	}
+	if werr != nil {
+		return werr
+	}
+	return err
+}
+
+//Scp copies a file from or to a VM
+//If the destination is a directory the file is copied in it under its base name
+func (srv *SSHService) Scp(from string, to string) error {
+	fromVM, fromPath, fromRemote := splitRemotePath(from)
+	toVM, toPath, toRemote := splitRemotePath(to)
+	if fromRemote == toRemote {
+		return fmt.Errorf("Error copying %s to %s: exactly one of the paths must be on a VM (vm:/path)", from, to)
+	}
+	if toRemote {
+		err := srv.upload(fromPath, toVM, toPath)
+		if err != nil {
+			return fmt.Errorf("Error copying %s to %s: %s", from, to, err.Error())
+		}
+		return nil
+	}
+	code, stdout, stderr, err := srv.Run(fromVM, "base64 "+quote(fromPath))
+	if err != nil {
+		return fmt.Errorf("Error copying %s to %s: %s", from, to, err.Error())
+	}
+	if code != 0 {
+		return fmt.Errorf("Error copying %s to %s: %s", from, to, strings.TrimSpace(stderr))
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.Replace(stdout, "\n", "", -1))
+	if err != nil {
+		return fmt.Errorf("Error copying %s to %s: %s", from, to, err.Error())
+	}
+	if st, err := os.Stat(toPath); err == nil && st.IsDir() {
+		toPath = filepath.Join(toPath, filepath.Base(fromPath))
+	}
+	err = ioutil.WriteFile(toPath, content, 0644)
+	if err != nil {
+		return fmt.Errorf("Error copying %s to %s: %s", from, to, err.Error())
+	}
+	return nil
+}
+
+//runScript runs script on the VM ref, an error is returned if the script fails
+func runScript(ssh SSHAPI, ref string, script string) error {
+	return runScriptContext(context.Background(), ssh, ref, script)
+}
+
+//runScriptContext is like runScript but includes a context
+func runScriptContext(ctx context.Context, ssh SSHAPI, ref string, script string) error {
+	code, _, stderr, err := ssh.RunContext(ctx, ref, script)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("command failed on %s with exit code %d: %s", ref, code, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+//installScript returns a script installing debPackages on Debian based systems and rpmPackages on RedHat based systems
+func installScript(debPackages string, rpmPackages string) string {
+	return fmt.Sprintf(`if command -v apt-get >/dev/null 2>&1; then
+	sudo apt-get update -qq && sudo DEBIAN_FRONTEND=noninteractive apt-get install -qqy %s >/dev/null
+else
+	sudo yum install -q -y %s
+fi`, debPackages, rpmPackages)
+}
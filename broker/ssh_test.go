@@ -0,0 +1,58 @@
+package broker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+)
+
+func TestScpLargeFile(t *testing.T) {
+	//the fake ssh client runs the remote command locally, its last argument
+	bin := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(bin, "ssh"), []byte("#!/bin/bash\nexec bash -c \"${@: -1}\"\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	clt := newMemoryClient(t)
+	_, err = NewNetworkService(clt).Create("net1", "192.168.0.0/24", IPVersion.IPv4, 1, 1, 10, "Ubuntu 16.04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//a public VM is reached without gateway
+	_, err = NewVMService(clt).Create("vm1", "net1", 1, 1, 10, "Ubuntu 16.04", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	//larger than the maximum length of an argument
+	content := bytes.Repeat([]byte("0123456789abcdef"), 300*1024/16)
+	err = ioutil.WriteFile(src, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := filepath.Join(dir, "remote")
+	err = os.Mkdir(remote, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssh := NewSSHService(clt)
+	err = ssh.Scp(src, "vm1:"+remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := ioutil.ReadFile(filepath.Join(remote, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(copied, content) {
+		t.Fatalf("copied %d bytes, expected %d", len(copied), len(content))
+	}
+}
@@ -1 +1,724 @@
-package broker
\ No newline at end of file
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+// broker tenant add ovh1 --provider="OVH" --config="ovh1.json"
+// broker tenant list
+// broker tenant get ovh1
+// broker tenant set ovh1
+
+//ConfigDirEnv environment variable overriding the broker configuration directory
+const ConfigDirEnv = "BROKER_CONFIG_DIR"
+
+//TenantDefaults default values used by broker services when a parameter is not given
+type TenantDefaults struct {
+	//Region region used when the provider configuration does not define one
+	Region string `json:"region,omitempty"`
+	//OS default operating system of VMs and gateways
+	OS string `json:"os,omitempty"`
+	//Sizing default sizing of VMs and gateways
+	Sizing api.SizingRequirements `json:"sizing"`
+	//Retry retry and rate limit settings of the calls to the provider
+	Retry providers.RetryOptions `json:"retry"`
+	//Cache cache settings of the provider resources
+	Cache providers.CacheOptions `json:"cache"`
+	//Metadata store of the bookkeeping data of the driver, the object storage of the provider by default
+	//The bolt database file is <dir>/metadata/<tenant>.db if its path is not given
+	Metadata providers.MetadataOptions `json:"metadata"`
+}
+
+//Defaults used when neither the request nor the tenant give a value
+const (
+	DefaultOS       = "Ubuntu 16.04"
+	DefaultCores    = 1
+	DefaultRAMSize  = 2
+	DefaultDiskSize = 16
+)
+
+//Resolve returns the sizing and the OS of a VM, zero values are replaced by the defaults of the tenant
+//then by the broker defaults
+func (d *TenantDefaults) Resolve(cpu int, ram float32, disk int, os string) (int, float32, int, string) {
+	if cpu <= 0 {
+		cpu = d.Sizing.MinCores
+	}
+	if cpu <= 0 {
+		cpu = DefaultCores
+	}
+	if ram <= 0 {
+		ram = d.Sizing.MinRAMSize
+	}
+	if ram <= 0 {
+		ram = DefaultRAMSize
+	}
+	if disk <= 0 {
+		disk = d.Sizing.MinDiskSize
+	}
+	if disk <= 0 {
+		disk = DefaultDiskSize
+	}
+	if os == "" {
+		os = d.OS
+	}
+	if os == "" {
+		os = DefaultOS
+	}
+	return cpu, ram, disk, os
+}
+
+//Tenant a named account on a provider
+//Config only holds the non secret fields of the provider configuration, secret fields are encrypted by the vault
+type Tenant struct {
+	Name     string                 `json:"name"`
+	Provider string                 `json:"provider"`
+	Config   map[string]interface{} `json:"config"`
+	Defaults TenantDefaults         `json:"defaults"`
+	//Secrets names of the secret fields of the provider configuration
+	Secrets []string `json:"secrets,omitempty"`
+	//MasterKey ID of the master key encrypting the private keys of the VMs stored by the driver, empty if the
+	//metadata of the tenant are not encrypted
+	MasterKey string `json:"master_key,omitempty"`
+}
+
+//tenantRecord tenant as stored in the configuration directory
+type tenantRecord struct {
+	Tenant
+	//Sealed secret fields of the provider configuration encrypted by the vault
+	Sealed map[string]string `json:"sealed,omitempty"`
+	//MasterKeys master keys of the tenant encrypted by the vault indexed by ID, keys other than MasterKey are
+	//only kept until the records they encrypt are re-encrypted by RotateKey
+	MasterKeys map[string]string `json:"master_keys,omitempty"`
+}
+
+//TenantAPI defines API to manage tenants
+type TenantAPI interface {
+	Add(name string, provider string, config map[string]interface{}, defaults TenantDefaults) (*Tenant, error)
+	List() ([]Tenant, error)
+	Get(name string) (*Tenant, error)
+	Set(name string) error
+	Current() (*Tenant, error)
+	Delete(name string) error
+	Client(name string) (api.ClientAPI, error)
+	CurrentClient() (api.ClientAPI, error)
+	Audit() (providers.AuditSink, error)
+	MigrateMetadata(name string, dryRun bool) (*providers.MigrationReport, error)
+	RotateKey(name string) (*providers.MigrationReport, error)
+	CollectGarbage(name string, opts providers.GCOptions) (*providers.GCReport, error)
+}
+
+//TenantService tenant service storing tenants in a local configuration directory
+//Each tenant is stored in <dir>/tenants/<name>.json, the name of the current tenant in <dir>/current
+//Secret fields of the provider configurations are encrypted by the vault stored in <dir>/vault.json
+type TenantService struct {
+	dir string
+	//vault vault of the secrets, opened on first use
+	vaultMu sync.Mutex
+	vault   *Vault
+	//audit sink of the audit records, created on first use
+	auditMu sync.Mutex
+	audit   providers.AuditSink
+	//metadata stores of the tenants not using the object storage, created on first use
+	metadataMu sync.Mutex
+	metadata   map[string]providers.MetadataStore
+	//keyMu serializes the changes of the master keys of the tenants
+	keyMu sync.Mutex
+}
+
+//DefaultConfigDir returns the broker configuration directory, $BROKER_CONFIG_DIR or ~/.config/gpac/broker
+func DefaultConfigDir() (string, error) {
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("Error locating broker configuration directory: %s", err.Error())
+	}
+	return filepath.Join(home, ".config", "gpac", "broker"), nil
+}
+
+//NewTenantService creates a tenant service storing tenants in dir
+func NewTenantService(dir string) TenantAPI {
+	return &TenantService{
+		dir: dir,
+	}
+}
+
+var tenantName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+func (srv *TenantService) tenantFile(name string) string {
+	return filepath.Join(srv.dir, "tenants", name+".json")
+}
+
+//cacheDir returns the directory of the on-disk cache of the tenant name
+func (srv *TenantService) cacheDir(name string) string {
+	return filepath.Join(srv.dir, "cache", name)
+}
+
+//metadataFile returns the default database file of the bolt metadata store of the tenant name
+func (srv *TenantService) metadataFile(name string) string {
+	return filepath.Join(srv.dir, "metadata", name+".db")
+}
+
+func (srv *TenantService) currentFile() string {
+	return filepath.Join(srv.dir, "current")
+}
+
+//getVault opens the vault the first time it is needed, tenants without secret can be used while the vault is locked
+func (srv *TenantService) getVault() (*Vault, error) {
+	srv.vaultMu.Lock()
+	defer srv.vaultMu.Unlock()
+	if srv.vault != nil {
+		return srv.vault, nil
+	}
+	v, err := OpenVault(srv.dir)
+	if err != nil {
+		return nil, err
+	}
+	srv.vault = v
+	return v, nil
+}
+
+//secretContext authenticates a secret with the tenant and the field it belongs to
+func secretContext(tenant string, field string) string {
+	return tenant + "/" + field
+}
+
+//lookupProvider returns the registered provider matching name, case is ignored
+func lookupProvider(name string) (*providers.Provider, error) {
+	for _, p := range providers.Providers() {
+		if strings.EqualFold(p.Name, name) {
+			return providers.GetProvider(p.Name)
+		}
+	}
+	return nil, providers.ResourceNotFoundError("Provider", name)
+}
+
+//providerConfig returns the configuration given to the provider, secrets are merged with the configuration
+//and the default region is added if the provider accepts a region and the configuration does not define one
+func (t *Tenant) providerConfig(p *providers.Provider, secrets map[string]interface{}) map[string]interface{} {
+	cfg := make(map[string]interface{}, len(t.Config)+len(secrets)+1)
+	for k, v := range t.Config {
+		cfg[k] = v
+	}
+	for k, v := range secrets {
+		cfg[k] = v
+	}
+	if t.Defaults.Region == "" {
+		return cfg
+	}
+	for _, f := range p.Fields {
+		if f.Name == "Region" {
+			if _, ok := cfg[f.Name]; !ok {
+				cfg[f.Name] = t.Defaults.Region
+			}
+		}
+	}
+	return cfg
+}
+
+//Add adds a tenant, the configuration is validated against the provider schema
+func (srv *TenantService) Add(name string, provider string, config map[string]interface{}, defaults TenantDefaults) (*Tenant, error) {
+	if !tenantName.MatchString(name) {
+		return nil, fmt.Errorf("Invalid tenant name %s: only letters, digits, '.', '_' and '-' are allowed", name)
+	}
+	if _, err := os.Stat(srv.tenantFile(name)); err == nil {
+		return nil, providers.ResourceAlreadyExistsError("Tenant", name)
+	}
+	p, err := lookupProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+	err = defaults.Metadata.Validate()
+	if err != nil {
+		return nil, err
+	}
+	rec := tenantRecord{
+		Tenant: Tenant{
+			Name:     name,
+			Provider: p.Name,
+			Config:   map[string]interface{}{},
+			Defaults: defaults,
+		},
+	}
+	secrets := map[string]interface{}{}
+	for k, v := range config {
+		rec.Config[k] = v
+	}
+	for _, f := range p.Fields {
+		if v, ok := rec.Config[f.Name]; ok && f.Secret {
+			secrets[f.Name] = v
+			delete(rec.Config, f.Name)
+		}
+	}
+	err = p.Validate(rec.providerConfig(p, secrets))
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) > 0 {
+		err = srv.seal(&rec, secrets)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = srv.save(&rec)
+	if err != nil {
+		return nil, err
+	}
+	return &rec.Tenant, nil
+}
+
+//seal encrypts secrets in the tenant record
+func (srv *TenantService) seal(rec *tenantRecord, secrets map[string]interface{}) error {
+	v, err := srv.getVault()
+	if err != nil {
+		return err
+	}
+	rec.Sealed = map[string]string{}
+	for k, value := range secrets {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("Error encrypting secret %s of tenant %s: %s", k, rec.Name, err.Error())
+		}
+		rec.Sealed[k], err = v.Encrypt(secretContext(rec.Name, k), b)
+		if err != nil {
+			return fmt.Errorf("Error encrypting secret %s of tenant %s: %s", k, rec.Name, err.Error())
+		}
+		rec.Secrets = append(rec.Secrets, k)
+	}
+	sort.Strings(rec.Secrets)
+	return nil
+}
+
+//unseal decrypts the secrets of the tenant record
+func (srv *TenantService) unseal(rec *tenantRecord) (map[string]interface{}, error) {
+	secrets := map[string]interface{}{}
+	if len(rec.Sealed) == 0 {
+		return secrets, nil
+	}
+	v, err := srv.getVault()
+	if err != nil {
+		return nil, err
+	}
+	for k, sealed := range rec.Sealed {
+		b, err := v.Decrypt(secretContext(rec.Name, k), sealed)
+		if err != nil {
+			return nil, fmt.Errorf("Error decrypting secret %s of tenant %s", k, rec.Name)
+		}
+		var value interface{}
+		err = json.Unmarshal(b, &value)
+		if err != nil {
+			return nil, fmt.Errorf("Error decrypting secret %s of tenant %s", k, rec.Name)
+		}
+		secrets[k] = value
+	}
+	return secrets, nil
+}
+
+//save writes the tenant file, it is only readable by the owner
+func (srv *TenantService) save(t *tenantRecord) error {
+	err := os.MkdirAll(filepath.Join(srv.dir, "tenants"), 0700)
+	if err != nil {
+		return fmt.Errorf("Error saving tenant %s: %s", t.Name, err.Error())
+	}
+	b, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error saving tenant %s: %s", t.Name, err.Error())
+	}
+	err = ioutil.WriteFile(srv.tenantFile(t.Name), b, 0600)
+	if err != nil {
+		return fmt.Errorf("Error saving tenant %s: %s", t.Name, err.Error())
+	}
+	return nil
+}
+
+//List returns the tenants sorted by name
+func (srv *TenantService) List() ([]Tenant, error) {
+	files, err := filepath.Glob(filepath.Join(srv.dir, "tenants", "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("Error listing tenants: %s", err.Error())
+	}
+	sort.Strings(files)
+	var tenants []Tenant
+	for _, f := range files {
+		t, err := srv.Get(strings.TrimSuffix(filepath.Base(f), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, *t)
+	}
+	return tenants, nil
+}
+
+//Get returns the tenant name, secrets are not decrypted
+func (srv *TenantService) Get(name string) (*Tenant, error) {
+	rec, err := srv.load(name)
+	if err != nil {
+		return nil, err
+	}
+	return &rec.Tenant, nil
+}
+
+//load reads the tenant record name
+func (srv *TenantService) load(name string) (*tenantRecord, error) {
+	if !tenantName.MatchString(name) {
+		return nil, providers.ResourceNotFoundError("Tenant", name)
+	}
+	b, err := ioutil.ReadFile(srv.tenantFile(name))
+	if os.IsNotExist(err) {
+		return nil, providers.ResourceNotFoundError("Tenant", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading tenant %s: %s", name, err.Error())
+	}
+	rec := tenantRecord{}
+	err = json.Unmarshal(b, &rec)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading tenant %s: %s", name, err.Error())
+	}
+	return &rec, nil
+}
+
+//Set sets the current tenant
+func (srv *TenantService) Set(name string) error {
+	_, err := srv.Get(name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(srv.dir, 0700)
+	if err != nil {
+		return fmt.Errorf("Error setting current tenant: %s", err.Error())
+	}
+	err = ioutil.WriteFile(srv.currentFile(), []byte(name), 0600)
+	if err != nil {
+		return fmt.Errorf("Error setting current tenant: %s", err.Error())
+	}
+	return nil
+}
+
+//Current returns the current tenant
+func (srv *TenantService) Current() (*Tenant, error) {
+	b, err := ioutil.ReadFile(srv.currentFile())
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("No current tenant, use 'broker tenant set <name>'")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading current tenant: %s", err.Error())
+	}
+	return srv.Get(strings.TrimSpace(string(b)))
+}
+
+//Delete deletes the tenant name, if it is the current tenant there is no current tenant anymore
+func (srv *TenantService) Delete(name string) error {
+	_, err := srv.Get(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(srv.tenantFile(name))
+	if err != nil {
+		return fmt.Errorf("Error deleting tenant %s: %s", name, err.Error())
+	}
+	os.RemoveAll(srv.cacheDir(name))
+	srv.metadataMu.Lock()
+	if store, ok := srv.metadata[name]; ok {
+		store.Close()
+		delete(srv.metadata, name)
+	}
+	srv.metadataMu.Unlock()
+	b, err := ioutil.ReadFile(srv.currentFile())
+	if err == nil && strings.TrimSpace(string(b)) == name {
+		os.Remove(srv.currentFile())
+	}
+	return nil
+}
+
+//providerClient returns a client of the provider of the tenant name without decorators
+func (srv *TenantService) providerClient(name string) (api.ClientAPI, *tenantRecord, error) {
+	rec, err := srv.load(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := providers.GetProvider(rec.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	secrets, err := srv.unseal(rec)
+	if err != nil {
+		return nil, nil, err
+	}
+	clt, err := providers.New(p.Name, rec.providerConfig(p, secrets))
+	if err != nil {
+		return nil, nil, err
+	}
+	if mc, ok := clt.(providers.MetadataClient); ok {
+		store, err := srv.metadataStore(rec)
+		if err != nil {
+			return nil, nil, err
+		}
+		if store == nil {
+			store = mc.MetadataStore()
+		}
+		//the records encrypted before the encryption was disabled must still be read
+		if !rec.Defaults.Metadata.Plaintext || rec.MasterKey != "" {
+			migrate := rec.MasterKey == ""
+			keyring, err := srv.keyring(rec)
+			if err != nil {
+				return nil, nil, err
+			}
+			sealed := providers.NewSealedMetadataStore(store, keyring)
+			//the private keys written in clear by the tenants created before the encryption was the default are
+			//encrypted with the first master key
+			if migrate {
+				err = resealClearRecords(rec.Name, sealed, mc.MetadataBuckets())
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			store = sealed
+		}
+		mc.SetMetadataStore(store)
+	}
+	return clt, rec, nil
+}
+
+//resealClearRecords encrypts the private keys stored in clear in the metadata store of the tenant
+func resealClearRecords(tenant string, store *providers.SealedMetadataStore, buckets []providers.RecordBucket) error {
+	report, err := providers.ResealRecords(store, buckets)
+	if err == nil && len(report.Failed) > 0 {
+		err = fmt.Errorf("%d records failed", len(report.Failed))
+	}
+	if err != nil {
+		return fmt.Errorf("Error encrypting the metadata of tenant %s, run broker admin rotate-key to encrypt them: %s", tenant, err.Error())
+	}
+	return nil
+}
+
+//resealRecords re-encrypts the private keys stored in buckets with the primary key of the keyring of store and tells
+//if no record is left sealed with another key. Brokers which loaded the keyring before the rotation may write records
+//with a previous key during the first pass, so the previous keys are only unused if a second pass finds no record
+//to re-encrypt
+func resealRecords(store *providers.SealedMetadataStore, buckets []providers.RecordBucket) (*providers.MigrationReport, bool, error) {
+	report, err := providers.ResealRecords(store, buckets)
+	if err != nil || len(report.Failed) > 0 {
+		return report, false, err
+	}
+	check, err := providers.ResealRecords(store, buckets)
+	if err != nil {
+		return nil, false, err
+	}
+	report.Migrated = append(report.Migrated, check.Migrated...)
+	for name, e := range check.Failed {
+		report.Failed[name] = e
+	}
+	return report, len(check.Migrated) == 0 && len(check.Failed) == 0, nil
+}
+
+//masterKeyContext authenticates the master key id with the tenant
+func masterKeyContext(tenant string, id string) string {
+	return secretContext(tenant, "master_key/"+id)
+}
+
+//addMasterKey generates a master key, encrypts it with the vault and makes it the primary key of the tenant record
+func (srv *TenantService) addMasterKey(rec *tenantRecord) error {
+	v, err := srv.getVault()
+	if err != nil {
+		return err
+	}
+	id, key, err := providers.NewMasterKey()
+	if err != nil {
+		return err
+	}
+	sealed, err := v.Encrypt(masterKeyContext(rec.Name, id), key)
+	if err != nil {
+		return fmt.Errorf("Error encrypting master key of tenant %s: %s", rec.Name, err.Error())
+	}
+	if rec.MasterKeys == nil {
+		rec.MasterKeys = map[string]string{}
+	}
+	rec.MasterKeys[id] = sealed
+	rec.MasterKey = id
+	return nil
+}
+
+//keyring returns the master keys of the tenant decrypted by the vault, the first master key is generated and saved
+//the first time a client of a tenant with encrypted metadata is created
+func (srv *TenantService) keyring(rec *tenantRecord) (*providers.Keyring, error) {
+	if rec.MasterKey == "" {
+		srv.keyMu.Lock()
+		//another client may have generated the key since rec was loaded
+		current, err := srv.load(rec.Name)
+		if err == nil && current.MasterKey == "" {
+			err = srv.addMasterKey(current)
+			if err == nil {
+				err = srv.save(current)
+			}
+		}
+		srv.keyMu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		rec.MasterKey, rec.MasterKeys = current.MasterKey, current.MasterKeys
+	}
+	v, err := srv.getVault()
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string][]byte{}
+	for id, sealed := range rec.MasterKeys {
+		keys[id], err = v.Decrypt(masterKeyContext(rec.Name, id), sealed)
+		if err != nil {
+			return nil, fmt.Errorf("Error decrypting master key %s of tenant %s", id, rec.Name)
+		}
+	}
+	return providers.NewKeyring(rec.MasterKey, keys)
+}
+
+//metadataStore returns the metadata store of the tenant, nil if the driver keeps its metadata in the object storage
+//Stores are shared by the clients of the tenant so that connections to etcd are not opened for each client
+func (srv *TenantService) metadataStore(rec *tenantRecord) (providers.MetadataStore, error) {
+	opts := rec.Defaults.Metadata
+	if opts.Type == "" || strings.EqualFold(opts.Type, providers.MetadataObject) {
+		return nil, nil
+	}
+	srv.metadataMu.Lock()
+	defer srv.metadataMu.Unlock()
+	if store, ok := srv.metadata[rec.Name]; ok {
+		return store, nil
+	}
+	if strings.EqualFold(opts.Type, providers.MetadataBolt) && opts.Path == "" {
+		opts.Path = srv.metadataFile(rec.Name)
+	}
+	store, err := providers.NewMetadataStore(opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	if srv.metadata == nil {
+		srv.metadata = map[string]providers.MetadataStore{}
+	}
+	srv.metadata[rec.Name] = store
+	return store, nil
+}
+
+//Client returns a client of the provider of the tenant name, secrets are decrypted by the vault
+//The calls of the client are observed, retried and cached following the defaults of the tenant, mutating calls
+//are recorded in the audit log
+func (srv *TenantService) Client(name string) (api.ClientAPI, error) {
+	clt, rec, err := srv.providerClient(name)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := srv.Audit()
+	if err != nil {
+		return nil, err
+	}
+	cache := rec.Defaults.Cache
+	cache.Dir = srv.cacheDir(name)
+	clt = providers.NewObservedClient(clt, rec.Provider, providers.DefaultMetrics, providers.DefaultTracer)
+	clt = providers.NewCacheClient(providers.NewRetryClient(clt, name, rec.Defaults.Retry), cache)
+	return providers.NewAuditClient(clt, sink, name, rec.Provider), nil
+}
+
+//MigrateMetadata rewrites the records of the metadata store of the tenant name at the current schema version
+//Records are only listed if dryRun is true
+func (srv *TenantService) MigrateMetadata(name string, dryRun bool) (*providers.MigrationReport, error) {
+	clt, rec, err := srv.providerClient(name)
+	if err != nil {
+		return nil, err
+	}
+	mc, ok := clt.(providers.MetadataClient)
+	if !ok {
+		return nil, api.NewError(api.ErrInvalidRequest, nil, "Provider %s of tenant %s does not store metadata", rec.Provider, name)
+	}
+	return providers.MigrateRecords(mc.MetadataStore(), mc.MetadataBuckets(), dryRun)
+}
+
+//RotateKey generates a new master key for the tenant name and re-encrypts with it the private keys stored by the
+//driver, private keys stored before they were encrypted are encrypted at the same time
+//Previous master keys are deleted only if all the records were re-encrypted and a second pass found no record
+//written with them since, otherwise they are kept so that these records can still be read and RotateKey can be run
+//again
+//The metadata of the tenant must not be stored in plaintext (see providers.MetadataOptions)
+func (srv *TenantService) RotateKey(name string) (*providers.MigrationReport, error) {
+	clt, rec, err := srv.providerClient(name)
+	if err != nil {
+		return nil, err
+	}
+	mc, ok := clt.(providers.MetadataClient)
+	if !ok {
+		return nil, api.NewError(api.ErrInvalidRequest, nil, "Provider %s of tenant %s does not store metadata", rec.Provider, name)
+	}
+	sealed, ok := mc.MetadataStore().(*providers.SealedMetadataStore)
+	if !ok {
+		return nil, api.NewError(api.ErrInvalidRequest, nil, "Metadata of tenant %s are not encrypted", name)
+	}
+	srv.keyMu.Lock()
+	defer srv.keyMu.Unlock()
+	rec, err = srv.load(name)
+	if err != nil {
+		return nil, err
+	}
+	err = srv.addMasterKey(rec)
+	if err != nil {
+		return nil, err
+	}
+	err = srv.save(rec)
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := srv.keyring(rec)
+	if err != nil {
+		return nil, err
+	}
+	report, done, err := resealRecords(providers.NewSealedMetadataStore(sealed.MetadataStore, keyring), mc.MetadataBuckets())
+	if err != nil {
+		return nil, err
+	}
+	if !done {
+		return report, nil
+	}
+	rec.MasterKeys = map[string]string{rec.MasterKey: rec.MasterKeys[rec.MasterKey]}
+	err = srv.save(rec)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+//CollectGarbage lists the resources of the tenant name leaked by failed operations and deletes them if opts.Delete is
+//true. Deletions are recorded in the audit log
+func (srv *TenantService) CollectGarbage(name string, opts providers.GCOptions) (*providers.GCReport, error) {
+	clt, rec, err := srv.providerClient(name)
+	if err != nil {
+		return nil, err
+	}
+	gc, ok := clt.(providers.GarbageCollector)
+	if !ok {
+		return nil, api.NewError(api.ErrInvalidRequest, nil, "Provider %s of tenant %s does not support garbage collection", rec.Provider, name)
+	}
+	sink, err := srv.Audit()
+	if err != nil {
+		return nil, err
+	}
+	gc = providers.NewAuditClient(clt, sink, name, rec.Provider).GarbageCollector(gc)
+	return providers.CollectGarbage(gc, opts)
+}
+
+//CurrentClient returns a client of the provider of the current tenant
+func (srv *TenantService) CurrentClient() (api.ClientAPI, error) {
+	t, err := srv.Current()
+	if err != nil {
+		return nil, err
+	}
+	return srv.Client(t.Name)
+}
@@ -0,0 +1,372 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+	_ "github.com/SebastienDorgan/gpac/providers/memory"
+	"github.com/SebastienDorgan/gpac/providers/openstack/fake"
+)
+
+//addOpenStackTenant adds the tenant name of the fake cloud srv, its password is a secret field
+func addOpenStackTenant(t *testing.T, tenants TenantAPI, name string, srv *fake.Server, metadata providers.MetadataOptions) *Tenant {
+	auth := srv.AuthOptions()
+	tenant, err := tenants.Add(name, "openstack", map[string]interface{}{
+		"IdentityEndpoint": auth.IdentityEndpoint,
+		"Username":         auth.Username,
+		"Password":         auth.Password,
+		"TenantID":         auth.TenantID,
+		"Region":           auth.Region,
+		"ProviderNetwork":  srv.CfgOptions().ProviderNetwork,
+	}, TenantDefaults{Metadata: metadata})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tenant
+}
+
+func TestTenants(t *testing.T) {
+	unlock(t, "", "")
+	tenants := NewTenantService(t.TempDir())
+	for _, name := range []string{"mem2", "mem1"} {
+		_, err := tenants.Add(name, "Memory", map[string]interface{}{}, TenantDefaults{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := tenants.Add("mem1", "memory", map[string]interface{}{}, TenantDefaults{})
+	if !errors.Is(err, api.ErrAlreadyExists) {
+		t.Fatalf("expected an already exists error, got %v", err)
+	}
+	for _, name := range []string{"../mem", ".mem", "mem/1", ""} {
+		_, err = tenants.Add(name, "memory", map[string]interface{}{}, TenantDefaults{})
+		if err == nil {
+			t.Fatalf("tenant %q added", name)
+		}
+	}
+	_, err = tenants.Add("mem3", "unknown", map[string]interface{}{}, TenantDefaults{})
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected an unknown provider error, got %v", err)
+	}
+	_, err = tenants.Add("mem3", "memory", map[string]interface{}{}, TenantDefaults{
+		Metadata: providers.MetadataOptions{Type: providers.MetadataEtcd},
+	})
+	if !errors.Is(err, api.ErrInvalidRequest) {
+		t.Fatalf("expected an invalid metadata store error, got %v", err)
+	}
+
+	list, err := tenants.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "mem1" || list[1].Name != "mem2" || list[0].Provider != "memory" {
+		t.Fatalf("expected mem1 and mem2, got %+v", list)
+	}
+	_, err = tenants.Get("mem3")
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+
+	_, err = tenants.Current()
+	if err == nil {
+		t.Fatal("current tenant set")
+	}
+	err = tenants.Set("mem3")
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	err = tenants.Set("mem1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := tenants.Current()
+	if err != nil || current.Name != "mem1" {
+		t.Fatalf("expected mem1 to be the current tenant, got %+v (%v)", current, err)
+	}
+	//the current tenant is unset when it is deleted
+	err = tenants.Delete("mem1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tenants.Current()
+	if err == nil {
+		t.Fatal("deleted tenant still current")
+	}
+	err = tenants.Delete("mem1")
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
+
+func TestTenantWithoutSecret(t *testing.T) {
+	//a tenant without secret nor encrypted metadata is usable while the vault is locked
+	unlock(t, "", "")
+	dir := t.TempDir()
+	tenants := NewTenantService(dir)
+	_, err := tenants.Add("mem1", "memory", map[string]interface{}{}, TenantDefaults{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clt, err := tenants.Client("mem1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = clt.ListImages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenants.(*TenantService).vault != nil {
+		t.Fatal("vault opened")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vault.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected no vault, got %v", err)
+	}
+}
+
+func TestTenantSecrets(t *testing.T) {
+	srv := fake.NewServer(fake.Options{})
+	defer srv.Close()
+	dir := t.TempDir()
+	unlock(t, "passphrase", "")
+	tenant := addOpenStackTenant(t, NewTenantService(dir), "os1", srv, providers.MetadataOptions{})
+	if len(tenant.Secrets) != 1 || tenant.Secrets[0] != "Password" {
+		t.Fatalf("expected the password to be secret, got %+v", tenant.Secrets)
+	}
+	if _, ok := tenant.Config["Password"]; ok {
+		t.Fatal("password kept in the configuration")
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "tenants", "os1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := tenantRecord{}
+	err = json.Unmarshal(b, &rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rec.Config["Password"]; ok || rec.Sealed["Password"] == "" || strings.Contains(rec.Sealed["Password"], srv.AuthOptions().Password) {
+		t.Fatalf("password stored in clear: %s", b)
+	}
+
+	//the password is decrypted by the vault to create the client
+	_, err = NewTenantService(dir).Client("os1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock(t, "wrong", "")
+	_, err = NewTenantService(dir).Client("os1")
+	if err == nil {
+		t.Fatal("client created with the wrong passphrase")
+	}
+}
+
+func TestTenantEncryptedMetadata(t *testing.T) {
+	srv := fake.NewServer(fake.Options{})
+	defer srv.Close()
+	unlock(t, "passphrase", "")
+	tenants := NewTenantService(t.TempDir())
+	addOpenStackTenant(t, tenants, "clear", srv, providers.MetadataOptions{Type: providers.MetadataBolt, Plaintext: true})
+	_, err := tenants.RotateKey("clear")
+	if !errors.Is(err, api.ErrInvalidRequest) {
+		t.Fatalf("expected an invalid request error, got %v", err)
+	}
+
+	addOpenStackTenant(t, tenants, "sealed", srv, providers.MetadataOptions{Type: providers.MetadataBolt})
+	//the master key is generated with the first client
+	_, err = tenants.Client("sealed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant, err := tenants.Get("sealed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenant.MasterKey == "" {
+		t.Fatal("master key not generated")
+	}
+	_, err = tenants.RotateKey("sealed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := tenants.Get("sealed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.MasterKey == "" || rotated.MasterKey == tenant.MasterKey {
+		t.Fatalf("expected a new master key, got %s", rotated.MasterKey)
+	}
+}
+
+func TestTenantMetadataMigration(t *testing.T) {
+	srv := fake.NewServer(fake.Options{})
+	defer srv.Close()
+	unlock(t, "passphrase", "")
+	tenants := NewTenantService(t.TempDir())
+	addOpenStackTenant(t, tenants, "os1", srv, providers.MetadataOptions{Type: providers.MetadataBolt, Plaintext: true})
+	clt, err := tenants.Client("os1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewNetworkService(clt).Create("net1", "192.168.1.0/24", IPVersion.IPv4, 1, 2, 10, "Ubuntu 16.04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewVMService(clt).Create("vm1", "net1", 1, 2, 10, "Ubuntu 16.04", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//privateKeys checks that the private keys of the records are stored in clear if clear is true and returns their
+	//number
+	privateKeys := func(clear bool) int {
+		pc, _, err := tenants.(*TenantService).providerClient("os1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := providers.NewBoltMetadataStore(tenants.(*TenantService).metadataFile("os1"))
+		n := 0
+		for _, bucket := range pc.(providers.MetadataClient).MetadataBuckets() {
+			keys, err := store.List(bucket.Bucket, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range keys {
+				value, _, err := store.Get(bucket.Bucket, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(value), "private_key") {
+					continue
+				}
+				if strings.Contains(string(value), `"private_key"`) != clear {
+					t.Fatalf("expected the private key of %s/%s to be in clear: %t, got %s", bucket.Bucket, key, clear, value)
+				}
+				n++
+			}
+		}
+		return n
+	}
+	if privateKeys(true) == 0 {
+		t.Fatal("no private key stored")
+	}
+
+	//the tenant was added before the metadata were encrypted by default
+	rec, err := tenants.(*TenantService).load("os1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Defaults.Metadata.Plaintext = false
+	err = tenants.(*TenantService).save(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clt, err = tenants.Client("os1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if privateKeys(false) == 0 {
+		t.Fatal("no private key stored")
+	}
+	_, err = NewVMService(clt).Inspect("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveDefaults(t *testing.T) {
+	tenant := TenantDefaults{OS: "Debian 9", Sizing: api.SizingRequirements{MinCores: 2, MinRAMSize: 4}}
+	cases := []struct {
+		defaults TenantDefaults
+		cpu      int
+		ram      float32
+		disk     int
+		os       string
+	}{
+		{TenantDefaults{}, DefaultCores, DefaultRAMSize, DefaultDiskSize, DefaultOS},
+		{tenant, 2, 4, DefaultDiskSize, "Debian 9"},
+	}
+	for _, c := range cases {
+		cpu, ram, disk, os := c.defaults.Resolve(0, 0, 0, "")
+		if cpu != c.cpu || ram != c.ram || disk != c.disk || os != c.os {
+			t.Errorf("expected %d %g %d %s, got %d %g %d %s", c.cpu, c.ram, c.disk, c.os, cpu, ram, disk, os)
+		}
+	}
+	//the values of the request are kept
+	cpu, ram, disk, os := tenant.Resolve(8, 32, 100, "CentOS 7")
+	if cpu != 8 || ram != 32 || disk != 100 || os != "CentOS 7" {
+		t.Fatalf("expected the values of the request, got %d %g %d %s", cpu, ram, disk, os)
+	}
+}
+
+//lateWriter writes a record before the n-th listing of the store, like a broker which loaded the keyring before the
+//rotation
+type lateWriter struct {
+	providers.MetadataStore
+	n     int
+	write func()
+}
+
+func (s *lateWriter) List(bucket string, prefix string) ([]string, error) {
+	s.n--
+	if s.n == 0 {
+		s.write()
+	}
+	return s.MetadataStore.List(bucket, prefix)
+}
+
+func TestResealRecordsSecondPass(t *testing.T) {
+	keys := map[string][]byte{}
+	for _, id := range []string{"mk-1", "mk-2"} {
+		_, key, err := providers.NewMasterKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[id] = key
+	}
+	previous, err := providers.NewKeyring("mk-1", map[string][]byte{"mk-1": keys["mk-1"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := providers.NewKeyring("mk-2", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bolt := providers.NewBoltMetadataStore(filepath.Join(t.TempDir(), "metadata.db"))
+	defer bolt.Close()
+	put := func(id string) {
+		b, err := providers.EncodeRecord(providers.RecordVM, api.VM{ID: id, PrivateKey: "private-" + id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = providers.NewSealedMetadataStore(bolt, previous).Put("vms", id, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("vm1")
+	buckets := []providers.RecordBucket{{Bucket: "vms", Kind: providers.RecordVM, New: func() interface{} { return new(api.VM) }}}
+
+	//vm2 is written with the previous key after the first pass listed the records
+	store := &lateWriter{MetadataStore: bolt, n: 2, write: func() { put("vm2") }}
+	report, done, err := resealRecords(providers.NewSealedMetadataStore(store, rotated), buckets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done || len(report.Migrated) != 2 {
+		t.Fatalf("expected vm2 to be resealed by the second pass and the previous key to be kept, got %+v", report)
+	}
+	report, done, err = resealRecords(providers.NewSealedMetadataStore(bolt, rotated), buckets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !done || len(report.Migrated) != 0 || report.Current != 2 {
+		t.Fatalf("expected the records to be current, got %+v", report)
+	}
+}
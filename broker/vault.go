@@ -0,0 +1,197 @@
+package broker
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/SebastienDorgan/gpac/providers"
+)
+
+//VaultPassphraseEnv environment variable holding the passphrase unlocking the vault
+const VaultPassphraseEnv = "BROKER_VAULT_PASSPHRASE"
+
+//VaultKeyFileEnv environment variable holding the path of the key file unlocking the vault
+const VaultKeyFileEnv = "BROKER_VAULT_KEY_FILE"
+
+const (
+	vaultKeySize = 32
+	vaultCheck   = "gpac-broker-vault"
+	kdfScrypt    = "scrypt"
+	kdfKeyFile   = "keyfile"
+)
+
+//vaultHeader header of the vault, it allows to derive the key from a passphrase and to check it
+type vaultHeader struct {
+	KDF  string `json:"kdf"`
+	Salt []byte `json:"salt,omitempty"`
+	N    int    `json:"n,omitempty"`
+	R    int    `json:"r,omitempty"`
+	P    int    `json:"p,omitempty"`
+	//Check known value encrypted with the key, used to detect a wrong passphrase or key file
+	Check string `json:"check"`
+}
+
+//Vault encrypts secrets at rest with AES-256-GCM
+//The key is derived from a passphrase with scrypt or read from a key file. The passphrase is read from
+//$BROKER_VAULT_PASSPHRASE, the path of the key file from $BROKER_VAULT_KEY_FILE, one of them must be set. If the key
+//file does not exist when the vault is created a random key is generated in it
+//The key file has no default location: a key file stored in the configuration directory, next to the vault, would
+//be read by anyone able to read the encrypted secrets
+type Vault struct {
+	cipher *providers.Cipher
+}
+
+//readKeyFile reads a key file, it contains the base64 encoding of a 32 bytes key
+func readKeyFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(string(b))
+	if err != nil || len(key) != vaultKeySize {
+		return nil, fmt.Errorf("Error reading vault key file %s: invalid key", path)
+	}
+	return key, nil
+}
+
+//createKeyFile generates a random key and writes it in a file only readable by the owner
+func createKeyFile(path string) ([]byte, error) {
+	key := make([]byte, vaultKeySize)
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)), 0600)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func deriveKey(passphrase string, h *vaultHeader) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), h.Salt, h.N, h.R, h.P, vaultKeySize)
+}
+
+//OpenVault opens the vault stored in dir, the vault is created if it does not exist
+func OpenVault(dir string) (*Vault, error) {
+	path := filepath.Join(dir, "vault.json")
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return createVault(dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error opening vault: %s", err.Error())
+	}
+	h := vaultHeader{}
+	err = json.Unmarshal(b, &h)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening vault: %s", err.Error())
+	}
+	var key []byte
+	switch h.KDF {
+	case kdfScrypt:
+		passphrase := os.Getenv(VaultPassphraseEnv)
+		if passphrase == "" {
+			return nil, fmt.Errorf("Error opening vault: vault is locked, set %s", VaultPassphraseEnv)
+		}
+		key, err = deriveKey(passphrase, &h)
+	case kdfKeyFile:
+		keyFile := os.Getenv(VaultKeyFileEnv)
+		if keyFile == "" {
+			return nil, fmt.Errorf("Error opening vault: vault is locked, set %s", VaultKeyFileEnv)
+		}
+		key, err = readKeyFile(keyFile)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Error opening vault: vault is locked, key file %s not found, set %s", keyFile, VaultKeyFileEnv)
+		}
+	default:
+		return nil, fmt.Errorf("Error opening vault: unknown key derivation %s", h.KDF)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error opening vault: %s", err.Error())
+	}
+	c, err := providers.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening vault: %s", err.Error())
+	}
+	v := &Vault{cipher: c}
+	check, err := v.Decrypt("vault", h.Check)
+	if err != nil || string(check) != vaultCheck {
+		return nil, fmt.Errorf("Error opening vault: wrong passphrase or key file")
+	}
+	return v, nil
+}
+
+//createVault creates a vault in dir, the key is derived from $BROKER_VAULT_PASSPHRASE if set otherwise read
+//from the key file $BROKER_VAULT_KEY_FILE
+func createVault(dir string) (*Vault, error) {
+	h := vaultHeader{}
+	var key []byte
+	var err error
+	passphrase, keyFile := os.Getenv(VaultPassphraseEnv), os.Getenv(VaultKeyFileEnv)
+	switch {
+	case passphrase == "" && keyFile == "":
+		return nil, fmt.Errorf("Error creating vault: set %s or %s", VaultPassphraseEnv, VaultKeyFileEnv)
+	case passphrase != "":
+		h.KDF = kdfScrypt
+		h.Salt = make([]byte, 16)
+		h.N, h.R, h.P = 1<<15, 8, 1
+		_, err = io.ReadFull(rand.Reader, h.Salt)
+		if err == nil {
+			key, err = deriveKey(passphrase, &h)
+		}
+	default:
+		h.KDF = kdfKeyFile
+		key, err = readKeyFile(keyFile)
+		if os.IsNotExist(err) {
+			key, err = createKeyFile(keyFile)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error creating vault: %s", err.Error())
+	}
+	c, err := providers.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating vault: %s", err.Error())
+	}
+	v := &Vault{cipher: c}
+	h.Check, err = v.Encrypt("vault", []byte(vaultCheck))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating vault: %s", err.Error())
+	}
+	b, err := json.MarshalIndent(&h, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("Error creating vault: %s", err.Error())
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating vault: %s", err.Error())
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "vault.json"), b, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating vault: %s", err.Error())
+	}
+	return v, nil
+}
+
+//Encrypt encrypts value, context is authenticated with the value and must be given to decrypt it
+func (v *Vault) Encrypt(context string, value []byte) (string, error) {
+	return v.cipher.Seal(value, context)
+}
+
+//Decrypt decrypts a value encrypted with context
+func (v *Vault) Decrypt(context string, value string) ([]byte, error) {
+	return v.cipher.Open(value, context)
+}
@@ -0,0 +1,114 @@
+package broker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//unlock sets the passphrase and the key file unlocking the vault, empty values are unset
+func unlock(t *testing.T, passphrase string, keyFile string) {
+	t.Setenv(VaultPassphraseEnv, passphrase)
+	t.Setenv(VaultKeyFileEnv, keyFile)
+}
+
+//checkVault checks that a secret encrypted by v can be decrypted by the vault of dir
+func checkVault(t *testing.T, v *Vault, dir string) {
+	sealed, err := v.Encrypt("tenant/password", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenVault(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := reopened.Decrypt("tenant/password", sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "secret" {
+		t.Fatalf("expected secret, got %q", b)
+	}
+	//the context is authenticated
+	_, err = reopened.Decrypt("other/password", sealed)
+	if err == nil {
+		t.Fatal("secret decrypted with another context")
+	}
+}
+
+func TestVaultPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	unlock(t, "passphrase", "")
+	v, err := OpenVault(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVault(t, v, dir)
+
+	unlock(t, "wrong", "")
+	_, err = OpenVault(dir)
+	if err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("expected a wrong passphrase error, got %v", err)
+	}
+	unlock(t, "", "")
+	_, err = OpenVault(dir)
+	if err == nil || !strings.Contains(err.Error(), "vault is locked") {
+		t.Fatalf("expected a locked vault error, got %v", err)
+	}
+}
+
+func TestVaultKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(t.TempDir(), "vault.key")
+	unlock(t, "", keyFile)
+	v, err := OpenVault(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected a key file only readable by its owner, got %v", info.Mode())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vault.key")); !os.IsNotExist(err) {
+		t.Fatalf("key file written in the configuration directory")
+	}
+	checkVault(t, v, dir)
+
+	other := filepath.Join(t.TempDir(), "other.key")
+	_, err = createKeyFile(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock(t, "", other)
+	_, err = OpenVault(dir)
+	if err == nil || !strings.Contains(err.Error(), "wrong passphrase or key file") {
+		t.Fatalf("expected a wrong key file error, got %v", err)
+	}
+	unlock(t, "", filepath.Join(t.TempDir(), "missing.key"))
+	_, err = OpenVault(dir)
+	if err == nil || !strings.Contains(err.Error(), "vault is locked") {
+		t.Fatalf("expected a locked vault error, got %v", err)
+	}
+	//the key file has no default location
+	unlock(t, "", "")
+	_, err = OpenVault(dir)
+	if err == nil || !strings.Contains(err.Error(), "vault is locked") {
+		t.Fatalf("expected a locked vault error, got %v", err)
+	}
+}
+
+func TestCreateVaultLocked(t *testing.T) {
+	dir := t.TempDir()
+	unlock(t, "", "")
+	_, err := OpenVault(dir)
+	if err == nil {
+		t.Fatal("vault created without passphrase nor key file")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vault.json")); !os.IsNotExist(err) {
+		t.Fatal("vault written without passphrase nor key file")
+	}
+}
@@ -1,6 +1,7 @@
 package broker
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/SebastienDorgan/gpac/providers"
@@ -18,6 +19,14 @@ type VMAPI interface {
 	List() ([]api.VM, error)
 	Inspect(ref string) (*api.VM, error)
 	Delete(ref string) error
+	//CreateContext is like Create but includes a context
+	CreateContext(ctx context.Context, name string, net string, cpu int, ram float32, disk int, os string, public bool) (*api.VM, error)
+	//InspectContext is like Inspect but includes a context
+	InspectContext(ctx context.Context, ref string) (*api.VM, error)
+	//DeleteContext is like Delete but includes a context
+	DeleteContext(ctx context.Context, ref string) error
+	//ListContext is like List but includes a context
+	ListContext(ctx context.Context) ([]api.VM, error)
 }
 
 //NewVMService creates a VM service
@@ -34,34 +43,45 @@ type VMService struct {
 	network  NetworkAPI
 }
 
-//Create creates a network
+//Create creates a VM
 func (srv *VMService) Create(name string, net string, cpu int, ram float32, disk int, os string, public bool) (*api.VM, error) {
-	_, err := srv.Get(net)
-	if err != nil {
-		return nil, fmt.Errorf("VM %s already exists", net)
+	return srv.CreateContext(context.Background(), name, net, cpu, ram, disk, os, public)
+}
+
+//CreateContext is like Create but includes a context
+func (srv *VMService) CreateContext(ctx context.Context, name string, net string, cpu int, ram float32, disk int, os string, public bool) (*api.VM, error) {
+	_, err := srv.GetContext(ctx, name)
+	if err == nil {
+		return nil, providers.ResourceAlreadyExistsError("VM", name)
 	}
-	n, err := srv.network.Get(net)
+	n, err := srv.network.GetContext(ctx, net)
 	if err != nil {
 		return nil, err
 	}
-	tpls, err := srv.provider.SelectTemplatesBySize(api.SizingRequirements{
+	tpls, err := srv.provider.SelectTemplatesBySizeContext(ctx, api.SizingRequirements{
 		MinCores:    cpu,
 		MinRAMSize:  ram,
 		MinDiskSize: disk,
 	})
-	img, err := srv.provider.SearchImage(os)
+	if err != nil {
+		return nil, err
+	}
+	if len(tpls) == 0 {
+		return nil, fmt.Errorf("No template matching %d cores, %.1f GB of RAM and %d GB of disk", cpu, ram, disk)
+	}
+	img, err := srv.provider.SearchImageContext(ctx, os)
 	if err != nil {
 		return nil, err
 	}
 	gwRequest := api.VMRequest{
 		ImageID:    img.ID,
-		Name:       net,
+		Name:       name,
 		TemplateID: tpls[0].ID,
 		IsGateway:  false,
 		PublicIP:   public,
 		NetworkIDs: []string{n.ID},
 	}
-	vm, err := srv.provider.CreateVM(gwRequest)
+	vm, err := contextAPI(srv.provider).CreateVMContext(ctx, gwRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -69,14 +89,34 @@ func (srv *VMService) Create(name string, net string, cpu int, ram float32, disk
 
 }
 
-//List returns the network list
+//List returns the VM list
 func (srv *VMService) List() ([]api.VM, error) {
-	return srv.provider.ListVMs()
+	return srv.ListContext(context.Background())
+}
+
+//ListContext is like List but includes a context
+func (srv *VMService) ListContext(ctx context.Context) ([]api.VM, error) {
+	return contextAPI(srv.provider).ListVMsContext(ctx)
 }
 
-//Get returns the network identified by ref, ref can be the name or the id
+//Inspect returns the VM identified by ref, ref can be the name or the id
+func (srv *VMService) Inspect(ref string) (*api.VM, error) {
+	return srv.Get(ref)
+}
+
+//InspectContext is like Inspect but includes a context
+func (srv *VMService) InspectContext(ctx context.Context, ref string) (*api.VM, error) {
+	return srv.GetContext(ctx, ref)
+}
+
+//Get returns the VM identified by ref, ref can be the name or the id
 func (srv *VMService) Get(ref string) (*api.VM, error) {
-	vms, err := srv.provider.ListVMs()
+	return srv.GetContext(context.Background(), ref)
+}
+
+//GetContext is like Get but includes a context
+func (srv *VMService) GetContext(ctx context.Context, ref string) (*api.VM, error) {
+	vms, err := contextAPI(srv.provider).ListVMsContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -85,14 +125,19 @@ func (srv *VMService) Get(ref string) (*api.VM, error) {
 			return &vm, nil
 		}
 	}
-	return nil, fmt.Errorf("Network %s does not exists", ref)
+	return nil, providers.ResourceNotFoundError("VM", ref)
 }
 
-//Delete deletes network referenced by ref
+//Delete deletes VM referenced by ref
 func (srv *VMService) Delete(ref string) error {
-	vm, err := srv.Get(ref)
+	return srv.DeleteContext(context.Background(), ref)
+}
+
+//DeleteContext is like Delete but includes a context
+func (srv *VMService) DeleteContext(ctx context.Context, ref string) error {
+	vm, err := srv.GetContext(ctx, ref)
 	if err != nil {
-		return fmt.Errorf("Network %s does not exists", ref)
+		return err
 	}
-	return srv.provider.DeleteVM(vm.ID)
+	return contextAPI(srv.provider).DeleteVMContext(ctx, vm.ID)
 }
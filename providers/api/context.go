@@ -0,0 +1,547 @@
+package api
+
+import (
+	"context"
+
+	"github.com/SebastienDorgan/gpac/system"
+)
+
+//ClientAPIContext is the context-aware variant of ClientAPI
+//Each method behaves like the ClientAPI method of the same name without the Context suffix. The context cancels
+//the pending requests of the call and bounds its duration with its deadline
+type ClientAPIContext interface {
+	//ListImagesContext lists available OS images
+	ListImagesContext(ctx context.Context) ([]Image, error)
+	//GetImageContext returns the Image referenced by id
+	GetImageContext(ctx context.Context, id string) (*Image, error)
+	//GetTemplateContext returns the Template referenced by id
+	GetTemplateContext(ctx context.Context, id string) (*VMTemplate, error)
+	//ListTemplatesContext lists available VM templates
+	ListTemplatesContext(ctx context.Context) ([]VMTemplate, error)
+
+	//CreateKeyPairContext creates and import a key pair
+	CreateKeyPairContext(ctx context.Context, name string) (*KeyPair, error)
+	//GetKeyPairContext returns the key pair identified by id
+	GetKeyPairContext(ctx context.Context, id string) (*KeyPair, error)
+	//ListKeyPairsContext lists available key pairs
+	ListKeyPairsContext(ctx context.Context) ([]KeyPair, error)
+	//DeleteKeyPairContext deletes the key pair identified by id
+	DeleteKeyPairContext(ctx context.Context, id string) error
+
+	//CreateNetworkContext creates a network named name
+	CreateNetworkContext(ctx context.Context, req NetworkRequest) (*Network, error)
+	//GetNetworkContext returns the network identified by id
+	GetNetworkContext(ctx context.Context, id string) (*Network, error)
+	//ListNetworksContext lists available networks
+	ListNetworksContext(ctx context.Context) ([]Network, error)
+	//DeleteNetworkContext deletes the network identified by id
+	DeleteNetworkContext(ctx context.Context, id string) error
+
+	//CreateVMContext creates a VM that fulfils the request
+	CreateVMContext(ctx context.Context, request VMRequest) (*VM, error)
+	//GetVMContext returns the VM identified by id
+	GetVMContext(ctx context.Context, id string) (*VM, error)
+	//ListVMsContext lists available VMs
+	ListVMsContext(ctx context.Context) ([]VM, error)
+	//DeleteVMContext deletes the VM identified by id
+	DeleteVMContext(ctx context.Context, id string) error
+	//StopVMContext stops the VM identified by id
+	StopVMContext(ctx context.Context, id string) error
+	//StartVMContext starts the VM identified by id
+	StartVMContext(ctx context.Context, id string) error
+	//GetSSHConfigContext creates SSHConfig from VM
+	GetSSHConfigContext(ctx context.Context, id string) (*system.SSHConfig, error)
+
+	//CreateVolumeContext creates a block volume
+	CreateVolumeContext(ctx context.Context, request VolumeRequest) (*Volume, error)
+	//GetVolumeContext returns the volume identified by id
+	GetVolumeContext(ctx context.Context, id string) (*Volume, error)
+	//ListVolumesContext list available volumes
+	ListVolumesContext(ctx context.Context) ([]Volume, error)
+	//DeleteVolumeContext deletes the volume identified by id
+	DeleteVolumeContext(ctx context.Context, id string) error
+	//UpdateVolumeContext renames, resizes or changes the speed of the volume identified by id
+	UpdateVolumeContext(ctx context.Context, id string, request VolumeUpdateRequest) (*Volume, error)
+
+	//CreateVolumeAttachmentContext attaches a volume to a VM
+	CreateVolumeAttachmentContext(ctx context.Context, request VolumeAttachmentRequest) (*VolumeAttachment, error)
+	//GetVolumeAttachmentContext returns the volume attachment identified by id
+	GetVolumeAttachmentContext(ctx context.Context, serverID string, id string) (*VolumeAttachment, error)
+	//ListVolumeAttachmentsContext lists available volume attachment
+	ListVolumeAttachmentsContext(ctx context.Context, serverID string) ([]VolumeAttachment, error)
+	//DeleteVolumeAttachmentContext deletes the volume attachment identifed by id
+	DeleteVolumeAttachmentContext(ctx context.Context, serverID string, id string) error
+
+	//CreateContainerContext creates an object container
+	CreateContainerContext(ctx context.Context, name string) error
+	//DeleteContainerContext deletes an object container
+	DeleteContainerContext(ctx context.Context, name string) error
+	//ListContainersContext list object containers
+	ListContainersContext(ctx context.Context) ([]string, error)
+
+	//PutObjectContext put an object into an object container
+	PutObjectContext(ctx context.Context, container string, obj Object) error
+	//UpdateObjectMetadataContext update an object into  object container
+	UpdateObjectMetadataContext(ctx context.Context, container string, obj Object) error
+	//GetObjectContext get  object content from an object container
+	GetObjectContext(ctx context.Context, container string, name string, ranges []Range) (*Object, error)
+	//GetObjectMetadataContext get  object metadata from an object container
+	GetObjectMetadataContext(ctx context.Context, container string, name string) (*Object, error)
+	//ListObjectsContext list objects of a container
+	ListObjectsContext(ctx context.Context, container string, filter ObjectFilter) ([]string, error)
+	//CopyObjectContext copies an object
+	CopyObjectContext(ctx context.Context, containerSrc string, objectSrc string, objectDst string) error
+	//DeleteObjectContext deleta an object from a container
+	DeleteObjectContext(ctx context.Context, container string, object string) error
+}
+
+//WithContext returns the context-aware API of clt
+//Drivers implementing ClientAPIContext are returned as is. Calls to other drivers run in a goroutine, the
+//call returns the error of the context when it is done first while the driver call keeps running in background
+func WithContext(clt ClientAPI) ClientAPIContext {
+	if c, ok := clt.(ClientAPIContext); ok {
+		return c
+	}
+	return contextAdapter{clt}
+}
+
+//contextAdapter adapts a ClientAPI which does not support contexts
+type contextAdapter struct {
+	clt ClientAPI
+}
+
+//run calls f unless ctx is done and waits for f or for ctx to be done
+func run(ctx context.Context, f func() error) error {
+	if ctx.Done() == nil {
+		return f()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	res := make(chan error, 1)
+	go func() {
+		res <- f()
+	}()
+	select {
+	case err := <-res:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//ListImagesContext implements ClientAPIContext
+func (a contextAdapter) ListImagesContext(ctx context.Context) ([]Image, error) {
+	var res []Image
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.ListImages()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetImageContext implements ClientAPIContext
+func (a contextAdapter) GetImageContext(ctx context.Context, id string) (*Image, error) {
+	var res *Image
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.GetImage(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetTemplateContext implements ClientAPIContext
+func (a contextAdapter) GetTemplateContext(ctx context.Context, id string) (*VMTemplate, error) {
+	var res *VMTemplate
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.GetTemplate(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListTemplatesContext implements ClientAPIContext
+func (a contextAdapter) ListTemplatesContext(ctx context.Context) ([]VMTemplate, error) {
+	var res []VMTemplate
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.ListTemplates()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CreateKeyPairContext implements ClientAPIContext
+func (a contextAdapter) CreateKeyPairContext(ctx context.Context, name string) (*KeyPair, error) {
+	var res *KeyPair
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.CreateKeyPair(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetKeyPairContext implements ClientAPIContext
+func (a contextAdapter) GetKeyPairContext(ctx context.Context, id string) (*KeyPair, error) {
+	var res *KeyPair
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.GetKeyPair(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListKeyPairsContext implements ClientAPIContext
+func (a contextAdapter) ListKeyPairsContext(ctx context.Context) ([]KeyPair, error) {
+	var res []KeyPair
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.ListKeyPairs()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteKeyPairContext implements ClientAPIContext
+func (a contextAdapter) DeleteKeyPairContext(ctx context.Context, id string) error {
+	return run(ctx, func() error {
+		return a.clt.DeleteKeyPair(id)
+	})
+}
+
+//CreateNetworkContext implements ClientAPIContext
+func (a contextAdapter) CreateNetworkContext(ctx context.Context, req NetworkRequest) (*Network, error) {
+	var res *Network
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.CreateNetwork(req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetNetworkContext implements ClientAPIContext
+func (a contextAdapter) GetNetworkContext(ctx context.Context, id string) (*Network, error) {
+	var res *Network
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.GetNetwork(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListNetworksContext implements ClientAPIContext
+func (a contextAdapter) ListNetworksContext(ctx context.Context) ([]Network, error) {
+	var res []Network
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.ListNetworks()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteNetworkContext implements ClientAPIContext
+func (a contextAdapter) DeleteNetworkContext(ctx context.Context, id string) error {
+	return run(ctx, func() error {
+		return a.clt.DeleteNetwork(id)
+	})
+}
+
+//CreateVMContext implements ClientAPIContext
+func (a contextAdapter) CreateVMContext(ctx context.Context, request VMRequest) (*VM, error) {
+	var res *VM
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.CreateVM(request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVMContext implements ClientAPIContext
+func (a contextAdapter) GetVMContext(ctx context.Context, id string) (*VM, error) {
+	var res *VM
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.GetVM(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListVMsContext implements ClientAPIContext
+func (a contextAdapter) ListVMsContext(ctx context.Context) ([]VM, error) {
+	var res []VM
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.ListVMs()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteVMContext implements ClientAPIContext
+func (a contextAdapter) DeleteVMContext(ctx context.Context, id string) error {
+	return run(ctx, func() error {
+		return a.clt.DeleteVM(id)
+	})
+}
+
+//StopVMContext implements ClientAPIContext
+func (a contextAdapter) StopVMContext(ctx context.Context, id string) error {
+	return run(ctx, func() error {
+		return a.clt.StopVM(id)
+	})
+}
+
+//StartVMContext implements ClientAPIContext
+func (a contextAdapter) StartVMContext(ctx context.Context, id string) error {
+	return run(ctx, func() error {
+		return a.clt.StartVM(id)
+	})
+}
+
+//GetSSHConfigContext implements ClientAPIContext
+func (a contextAdapter) GetSSHConfigContext(ctx context.Context, id string) (*system.SSHConfig, error) {
+	var res *system.SSHConfig
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.GetSSHConfig(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CreateVolumeContext implements ClientAPIContext
+func (a contextAdapter) CreateVolumeContext(ctx context.Context, request VolumeRequest) (*Volume, error) {
+	var res *Volume
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.CreateVolume(request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVolumeContext implements ClientAPIContext
+func (a contextAdapter) GetVolumeContext(ctx context.Context, id string) (*Volume, error) {
+	var res *Volume
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.GetVolume(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListVolumesContext implements ClientAPIContext
+func (a contextAdapter) ListVolumesContext(ctx context.Context) ([]Volume, error) {
+	var res []Volume
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.ListVolumes()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteVolumeContext implements ClientAPIContext
+func (a contextAdapter) DeleteVolumeContext(ctx context.Context, id string) error {
+	return run(ctx, func() error {
+		return a.clt.DeleteVolume(id)
+	})
+}
+
+//UpdateVolumeContext implements ClientAPIContext
+func (a contextAdapter) UpdateVolumeContext(ctx context.Context, id string, request VolumeUpdateRequest) (*Volume, error) {
+	var res *Volume
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.UpdateVolume(id, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CreateVolumeAttachmentContext implements ClientAPIContext
+func (a contextAdapter) CreateVolumeAttachmentContext(ctx context.Context, request VolumeAttachmentRequest) (*VolumeAttachment, error) {
+	var res *VolumeAttachment
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.CreateVolumeAttachment(request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVolumeAttachmentContext implements ClientAPIContext
+func (a contextAdapter) GetVolumeAttachmentContext(ctx context.Context, serverID string, id string) (*VolumeAttachment, error) {
+	var res *VolumeAttachment
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.GetVolumeAttachment(serverID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListVolumeAttachmentsContext implements ClientAPIContext
+func (a contextAdapter) ListVolumeAttachmentsContext(ctx context.Context, serverID string) ([]VolumeAttachment, error) {
+	var res []VolumeAttachment
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.ListVolumeAttachments(serverID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteVolumeAttachmentContext implements ClientAPIContext
+func (a contextAdapter) DeleteVolumeAttachmentContext(ctx context.Context, serverID string, id string) error {
+	return run(ctx, func() error {
+		return a.clt.DeleteVolumeAttachment(serverID, id)
+	})
+}
+
+//CreateContainerContext implements ClientAPIContext
+func (a contextAdapter) CreateContainerContext(ctx context.Context, name string) error {
+	return run(ctx, func() error {
+		return a.clt.CreateContainer(name)
+	})
+}
+
+//DeleteContainerContext implements ClientAPIContext
+func (a contextAdapter) DeleteContainerContext(ctx context.Context, name string) error {
+	return run(ctx, func() error {
+		return a.clt.DeleteContainer(name)
+	})
+}
+
+//ListContainersContext implements ClientAPIContext
+func (a contextAdapter) ListContainersContext(ctx context.Context) ([]string, error) {
+	var res []string
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.ListContainers()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//PutObjectContext implements ClientAPIContext
+func (a contextAdapter) PutObjectContext(ctx context.Context, container string, obj Object) error {
+	return run(ctx, func() error {
+		return a.clt.PutObject(container, obj)
+	})
+}
+
+//UpdateObjectMetadataContext implements ClientAPIContext
+func (a contextAdapter) UpdateObjectMetadataContext(ctx context.Context, container string, obj Object) error {
+	return run(ctx, func() error {
+		return a.clt.UpdateObjectMetadata(container, obj)
+	})
+}
+
+//GetObjectContext implements ClientAPIContext
+func (a contextAdapter) GetObjectContext(ctx context.Context, container string, name string, ranges []Range) (*Object, error) {
+	var res *Object
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.GetObject(container, name, ranges)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetObjectMetadataContext implements ClientAPIContext
+func (a contextAdapter) GetObjectMetadataContext(ctx context.Context, container string, name string) (*Object, error) {
+	var res *Object
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.GetObjectMetadata(container, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListObjectsContext implements ClientAPIContext
+func (a contextAdapter) ListObjectsContext(ctx context.Context, container string, filter ObjectFilter) ([]string, error) {
+	var res []string
+	err := run(ctx, func() (err error) {
+		res, err = a.clt.ListObjects(container, filter)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CopyObjectContext implements ClientAPIContext
+func (a contextAdapter) CopyObjectContext(ctx context.Context, containerSrc string, objectSrc string, objectDst string) error {
+	return run(ctx, func() error {
+		return a.clt.CopyObject(containerSrc, objectSrc, objectDst)
+	})
+}
+
+//DeleteObjectContext implements ClientAPIContext
+func (a contextAdapter) DeleteObjectContext(ctx context.Context, container string, object string) error {
+	return run(ctx, func() error {
+		return a.clt.DeleteObject(container, object)
+	})
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+//Kinds of errors, errors returned by the drivers match their kind with errors.Is
+var (
+	//ErrNotFound the resource does not exist
+	ErrNotFound = errors.New("resource not found")
+	//ErrAlreadyExists a resource with the same name or ID already exists
+	ErrAlreadyExists = errors.New("resource already exists")
+	//ErrQuotaExceeded the quota of the tenant does not allow the request
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	//ErrInvalidRequest the request is rejected by the provider
+	ErrInvalidRequest = errors.New("invalid request")
+	//ErrUnauthorized the credentials are invalid or do not allow the request
+	ErrUnauthorized = errors.New("unauthorized")
+	//ErrTimeout the request or the wait for a resource timed out
+	ErrTimeout = errors.New("timeout")
+	//ErrUnavailable the provider is unreachable, overloaded or throttles the requests
+	ErrUnavailable = errors.New("provider unavailable")
+	//ErrConflict the state of the resource does not allow the request (resource in use, not empty ...)
+	ErrConflict = errors.New("conflict")
+)
+
+//Error error returned by the drivers
+type Error struct {
+	//Kind one of the Err* kinds, nil if the error is not classified
+	Kind error
+	//Message describes the failed operation
+	Message string
+	//Code code of the provider error (HTTP status, AWS error code ...), empty if the error does not come from the provider
+	Code string
+	//Body body or message of the provider error
+	Body string
+	//Err underlying error
+	Err error
+	//RetryAfter delay after which the provider invites to retry the request, 0 if not given
+	RetryAfter time.Duration
+}
+
+//NewError creates an error of kind kind caused by err, the message is formatted with args
+func NewError(kind error, err error, format string, args ...interface{}) *Error {
+	return &Error{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+		Err:     err,
+	}
+}
+
+func (e *Error) Error() string {
+	cause := ""
+	if e.Code != "" {
+		cause = fmt.Sprintf("code : %s reason ; %s", e.Code, e.Body)
+	} else if e.Err != nil {
+		cause = e.Err.Error()
+	}
+	if e.Message == "" {
+		return cause
+	}
+	if cause == "" {
+		return e.Message
+	}
+	return e.Message + ": " + cause
+}
+
+//Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+//Is tells if target is the kind of the error
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+//Is tells if target is ErrTimeout
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+//RetryAfter returns the delay after which the request failing with err may be retried, 0 if the provider did not give it
+func RetryAfter(err error) time.Duration {
+	var e *Error
+	for errors.As(err, &e) {
+		if e.RetryAfter > 0 {
+			return e.RetryAfter
+		}
+		err = e.Err
+	}
+	return 0
+}
+
+//kinds kinds of errors in the order they are looked for by KindOf
+var kinds = []error{
+	ErrNotFound,
+	ErrAlreadyExists,
+	ErrQuotaExceeded,
+	ErrInvalidRequest,
+	ErrUnauthorized,
+	ErrTimeout,
+	ErrUnavailable,
+	ErrConflict,
+}
+
+//KindOf returns the kind of err, nil if err is not classified
+//Besides errors of the drivers, context deadlines are timeouts, malformed addresses are invalid requests and network errors make the provider unavailable
+func KindOf(err error) error {
+	for _, k := range kinds {
+		if errors.Is(err, k) {
+			return k
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	//malformed addresses implement net.Error but are not network failures
+	var perr *net.ParseError
+	var aerr *net.AddrError
+	if errors.As(err, &perr) || errors.As(err, &aerr) {
+		return ErrInvalidRequest
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		if nerr.Timeout() {
+			return ErrTimeout
+		}
+		return ErrUnavailable
+	}
+	return nil
+}
+
+//kindNames names of the kinds of errors used in logs and metrics
+var kindNames = map[error]string{
+	ErrNotFound:       "not_found",
+	ErrAlreadyExists:  "already_exists",
+	ErrQuotaExceeded:  "quota_exceeded",
+	ErrInvalidRequest: "invalid_request",
+	ErrUnauthorized:   "unauthorized",
+	ErrTimeout:        "timeout",
+	ErrUnavailable:    "unavailable",
+	ErrConflict:       "conflict",
+}
+
+//KindName returns the name of the kind of err, "unknown" if err is not classified and an empty string if err is nil
+func KindName(err error) string {
+	if err == nil {
+		return ""
+	}
+	if name, ok := kindNames[KindOf(err)]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+//KindOfStatus returns the kind of error corresponding to the HTTP status of a provider response
+func KindOfStatus(status int) error {
+	switch {
+	case status == 400 || status == 422:
+		return ErrInvalidRequest
+	case status == 401 || status == 403:
+		return ErrUnauthorized
+	case status == 404:
+		return ErrNotFound
+	case status == 409:
+		return ErrConflict
+	case status == 413:
+		//OpenStack services reply 413 Over Limit when a quota is exceeded
+		return ErrQuotaExceeded
+	case status == 408 || status == 504:
+		return ErrTimeout
+	case status == 429 || status >= 500:
+		return ErrUnavailable
+	}
+	return nil
+}
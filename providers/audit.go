@@ -0,0 +1,868 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/system"
+)
+
+//Outcomes of the audited operations
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+//AuditRecord record of a mutating operation on a provider
+type AuditRecord struct {
+	Time         time.Time `json:"time"`
+	Tenant       string    `json:"tenant,omitempty"`
+	User         string    `json:"user,omitempty"`
+	ClaimedUser  string    `json:"claimed_user,omitempty"`
+	Provider     string    `json:"provider,omitempty"`
+	Operation    string    `json:"operation"`
+	ResourceType string    `json:"resource_type"`
+	ResourceID   string    `json:"resource_id,omitempty"`
+	ResourceName string    `json:"resource_name,omitempty"`
+	//Request payload of the operation, secrets are redacted
+	Request json.RawMessage `json:"request,omitempty"`
+	//Outcome AuditSuccess or AuditFailure
+	Outcome   string `json:"outcome"`
+	Error     string `json:"error,omitempty"`
+	ErrorKind string `json:"error_kind,omitempty"`
+	//Duration duration of the operation in nanoseconds
+	Duration time.Duration `json:"duration"`
+}
+
+//AuditFilter selects audit records, zero fields select all the records
+type AuditFilter struct {
+	//Since records older than Since are not selected
+	Since time.Time
+	//Resource ID or name of the resource
+	Resource string
+	//Tenant name of the tenant
+	Tenant string
+}
+
+//Match tells if rec is selected by the filter
+func (f *AuditFilter) Match(rec *AuditRecord) bool {
+	if rec.Time.Before(f.Since) {
+		return false
+	}
+	if f.Resource != "" && rec.ResourceID != f.Resource && rec.ResourceName != f.Resource {
+		return false
+	}
+	return f.Tenant == "" || rec.Tenant == f.Tenant
+}
+
+//AuditSink stores audit records, records are never modified once written
+type AuditSink interface {
+	//Write appends rec
+	Write(rec *AuditRecord) error
+	//List lists the records selected by filter sorted by time
+	List(filter AuditFilter) ([]AuditRecord, error)
+}
+
+//FileAuditSink stores the audit records in an append-only JSON-lines file
+type FileAuditSink struct {
+	Path string
+	mu   sync.Mutex
+}
+
+//NewFileAuditSink creates a sink appending the records to the file path
+func NewFileAuditSink(path string) *FileAuditSink {
+	return &FileAuditSink{Path: path}
+}
+
+//Write appends rec to the file, records are written with a single write so that processes sharing the file do not mix them
+func (s *FileAuditSink) Write(rec *AuditRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("Error writing audit record: %s", err.Error())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = os.MkdirAll(filepath.Dir(s.Path), 0700)
+	if err != nil {
+		return fmt.Errorf("Error writing audit record: %s", err.Error())
+	}
+	f, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("Error writing audit record: %s", err.Error())
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	if err != nil {
+		return fmt.Errorf("Error writing audit record: %s", err.Error())
+	}
+	return nil
+}
+
+//List reads the records of the file selected by filter
+func (s *FileAuditSink) List(filter AuditFilter) ([]AuditRecord, error) {
+	f, err := os.Open(s.Path)
+	if os.IsNotExist(err) {
+		return []AuditRecord{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading audit records: %s", err.Error())
+	}
+	defer f.Close()
+	records := []AuditRecord{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		rec := AuditRecord{}
+		//a truncated last line is left by a process killed while writing, it is skipped
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+			continue
+		}
+		if filter.Match(&rec) {
+			records = append(records, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading audit records: %s", err.Error())
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	return records, nil
+}
+
+//auditTimeFormat format of the times prefixing the names of the audit objects, names sort in chronological order
+const auditTimeFormat = "20060102T150405.000000000Z"
+
+//ContainerAuditSink stores each audit record in an object of an object container
+//Object names start with the time of the record so that they are listed in chronological order
+type ContainerAuditSink struct {
+	//Client client storing the objects, it must not be audited itself
+	Client    api.ClientAPI
+	Container string
+	once      sync.Once
+}
+
+//NewContainerAuditSink creates a sink storing the records in container, the container is created if needed
+func NewContainerAuditSink(clt api.ClientAPI, container string) *ContainerAuditSink {
+	return &ContainerAuditSink{Client: clt, Container: container}
+}
+
+//Write stores rec in a new object
+func (s *ContainerAuditSink) Write(rec *AuditRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("Error writing audit record: %s", err.Error())
+	}
+	s.once.Do(func() {
+		s.Client.CreateContainer(s.Container)
+	})
+	err = s.Client.PutObject(s.Container, api.Object{
+		Name:          fmt.Sprintf("%s-%s", rec.Time.UTC().Format(auditTimeFormat), randomID(4)),
+		Content:       bytes.NewReader(b),
+		ContentType:   "application/json",
+		ContentLength: int64(len(b)),
+	})
+	if err != nil {
+		return fmt.Errorf("Error writing audit record: %s", err.Error())
+	}
+	return nil
+}
+
+//List reads the records of the container selected by filter
+func (s *ContainerAuditSink) List(filter AuditFilter) ([]AuditRecord, error) {
+	names, err := s.Client.ListObjects(s.Container, api.ObjectFilter{})
+	if err != nil {
+		if api.KindOf(err) == api.ErrNotFound {
+			return []AuditRecord{}, nil
+		}
+		return nil, fmt.Errorf("Error reading audit records: %s", err.Error())
+	}
+	sort.Strings(names)
+	since := filter.Since.UTC().Format(auditTimeFormat)
+	records := []AuditRecord{}
+	for _, name := range names {
+		if !filter.Since.IsZero() && name < since {
+			continue
+		}
+		obj, err := s.Client.GetObject(s.Container, name, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading audit record %s: %s", name, err.Error())
+		}
+		b, err := ioutil.ReadAll(obj.Content)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading audit record %s: %s", name, err.Error())
+		}
+		rec := AuditRecord{}
+		if json.Unmarshal(b, &rec) != nil {
+			continue
+		}
+		if filter.Match(&rec) {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+//redactedKeys fields of the request payloads replaced by redactedValue, names are compared without case, '_' and '-'
+var redactedKeys = []string{"privatekey", "password", "passphrase", "secret", "token", "content"}
+
+//redactedValue value replacing secrets
+const redactedValue = "***"
+
+//redactValue redacts the secret fields of a decoded JSON value
+func redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			key := strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(k))
+			secret := false
+			for _, r := range redactedKeys {
+				if strings.Contains(key, r) {
+					secret = true
+					break
+				}
+			}
+			if secret {
+				t[k] = redactedValue
+			} else {
+				t[k] = redactValue(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = redactValue(e)
+		}
+	}
+	return v
+}
+
+//Redact returns the JSON encoding of v where secret fields (private keys, passwords, tokens, object contents ...)
+//are replaced by "***"
+func Redact(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var decoded interface{}
+	if json.Unmarshal(b, &decoded) != nil {
+		return nil
+	}
+	b, err = json.Marshal(redactValue(decoded))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+//DefaultAuditUser returns the user recorded in the audit records, $BROKER_USER or the name of the user of the process
+func DefaultAuditUser() string {
+	if u := os.Getenv("BROKER_USER"); u != "" {
+		return u
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+/*AuditClient decorates a ClientAPI with an audit log of the mutating operations
+Each Create, Delete, Start, Stop, Put, Update and Copy call is recorded in the sink with the tenant, the user, the
+resource, the request payload with its secrets redacted, the outcome and the duration of the call. Read calls are
+not recorded. A Service created on an AuditClient records the operations it performs
+*/
+type AuditClient struct {
+	clt       api.ClientAPIContext
+	decorated api.ClientAPI
+	sink      AuditSink
+	tenant    string
+	provider  string
+	user      string
+	claimed   string
+	//OnError is called when a record cannot be written, the audited operation is not failed
+	OnError func(rec *AuditRecord, err error)
+}
+
+//NewAuditClient decorates clt, a client of the tenant tenant on the provider provider. Operations are recorded in sink
+//on behalf of DefaultAuditUser
+func NewAuditClient(clt api.ClientAPI, sink AuditSink, tenant string, provider string) *AuditClient {
+	return &AuditClient{
+		clt:       api.WithContext(clt),
+		decorated: clt,
+		sink:      sink,
+		tenant:    tenant,
+		provider:  provider,
+		user:      DefaultAuditUser(),
+		OnError: func(rec *AuditRecord, err error) {
+			fmt.Fprintf(os.Stderr, "%s: %s %s %s not recorded\n", err.Error(), rec.Operation, rec.ResourceType, rec.ResourceID+rec.ResourceName)
+		},
+	}
+}
+
+//Unwrap returns the decorated client
+func (c *AuditClient) Unwrap() api.ClientAPI {
+	return c.decorated
+}
+
+//As returns a copy of the client recording the operations on behalf of user
+func (c *AuditClient) As(user string) *AuditClient {
+	cc := *c
+	cc.user = user
+	return &cc
+}
+
+//Claiming returns a copy of the client recording user as the user claimed by the caller, next to the user of the client
+func (c *AuditClient) Claiming(user string) *AuditClient {
+	cc := *c
+	cc.claimed = user
+	return &cc
+}
+
+//auditCall an audited call
+type auditCall struct {
+	operation    string
+	resourceType string
+	id           string
+	name         string
+	request      interface{}
+}
+
+//record calls f and records the call, f returns the ID of the resource when it is only known after the call
+func (c *AuditClient) record(ctx context.Context, call auditCall, f func(ctx context.Context) (string, error)) error {
+	start := time.Now()
+	id, err := f(ctx)
+	if id != "" {
+		call.id = id
+	}
+	rec := &AuditRecord{
+		Time:         start,
+		Tenant:       c.tenant,
+		User:         c.user,
+		ClaimedUser:  c.claimed,
+		Provider:     c.provider,
+		Operation:    call.operation,
+		ResourceType: call.resourceType,
+		ResourceID:   call.id,
+		ResourceName: call.name,
+		Request:      Redact(call.request),
+		Outcome:      AuditSuccess,
+		Duration:     time.Since(start),
+	}
+	if err != nil {
+		rec.Outcome = AuditFailure
+		rec.Error = err.Error()
+		rec.ErrorKind = api.KindName(err)
+	}
+	if werr := c.sink.Write(rec); werr != nil && c.OnError != nil {
+		c.OnError(rec, werr)
+	}
+	return err
+}
+
+//auditedCollector GarbageCollector whose deletions are recorded by an AuditClient
+type auditedCollector struct {
+	GarbageCollector
+	clt *AuditClient
+}
+
+//GarbageCollector returns gc with the deletions of the orphans recorded like the operations of the client
+func (c *AuditClient) GarbageCollector(gc GarbageCollector) GarbageCollector {
+	return &auditedCollector{GarbageCollector: gc, clt: c}
+}
+
+//DeleteOrphan deletes the orphan o, the call is audited
+func (a *auditedCollector) DeleteOrphan(o Orphan) error {
+	call := auditCall{operation: "DeleteOrphan", resourceType: o.Kind, id: o.ID, name: o.Name, request: o}
+	return a.clt.record(context.Background(), call, func(ctx context.Context) (string, error) {
+		return "", a.GarbageCollector.DeleteOrphan(o)
+	})
+}
+
+//ListImages lists available OS images
+func (c *AuditClient) ListImages() ([]api.Image, error) {
+	return c.ListImagesContext(context.Background())
+}
+
+//ListImagesContext implements api.ClientAPIContext
+func (c *AuditClient) ListImagesContext(ctx context.Context) ([]api.Image, error) {
+	return c.clt.ListImagesContext(ctx)
+}
+
+//GetImage returns the Image referenced by id
+func (c *AuditClient) GetImage(id string) (*api.Image, error) {
+	return c.GetImageContext(context.Background(), id)
+}
+
+//GetImageContext implements api.ClientAPIContext
+func (c *AuditClient) GetImageContext(ctx context.Context, id string) (*api.Image, error) {
+	return c.clt.GetImageContext(ctx, id)
+}
+
+//GetTemplate returns the Template referenced by id
+func (c *AuditClient) GetTemplate(id string) (*api.VMTemplate, error) {
+	return c.GetTemplateContext(context.Background(), id)
+}
+
+//GetTemplateContext implements api.ClientAPIContext
+func (c *AuditClient) GetTemplateContext(ctx context.Context, id string) (*api.VMTemplate, error) {
+	return c.clt.GetTemplateContext(ctx, id)
+}
+
+//ListTemplates lists available VM templates
+func (c *AuditClient) ListTemplates() ([]api.VMTemplate, error) {
+	return c.ListTemplatesContext(context.Background())
+}
+
+//ListTemplatesContext implements api.ClientAPIContext
+func (c *AuditClient) ListTemplatesContext(ctx context.Context) ([]api.VMTemplate, error) {
+	return c.clt.ListTemplatesContext(ctx)
+}
+
+//CreateKeyPair creates and import a key pair, the call is audited
+func (c *AuditClient) CreateKeyPair(name string) (*api.KeyPair, error) {
+	return c.CreateKeyPairContext(context.Background(), name)
+}
+
+//CreateKeyPairContext implements api.ClientAPIContext
+func (c *AuditClient) CreateKeyPairContext(ctx context.Context, name string) (*api.KeyPair, error) {
+	var res *api.KeyPair
+	err := c.record(ctx, auditCall{operation: "CreateKeyPair", resourceType: "KeyPair", name: name}, func(ctx context.Context) (string, error) {
+		var err error
+		res, err = c.clt.CreateKeyPairContext(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		return res.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetKeyPair returns the key pair identified by id
+func (c *AuditClient) GetKeyPair(id string) (*api.KeyPair, error) {
+	return c.GetKeyPairContext(context.Background(), id)
+}
+
+//GetKeyPairContext implements api.ClientAPIContext
+func (c *AuditClient) GetKeyPairContext(ctx context.Context, id string) (*api.KeyPair, error) {
+	return c.clt.GetKeyPairContext(ctx, id)
+}
+
+//ListKeyPairs lists available key pairs
+func (c *AuditClient) ListKeyPairs() ([]api.KeyPair, error) {
+	return c.ListKeyPairsContext(context.Background())
+}
+
+//ListKeyPairsContext implements api.ClientAPIContext
+func (c *AuditClient) ListKeyPairsContext(ctx context.Context) ([]api.KeyPair, error) {
+	return c.clt.ListKeyPairsContext(ctx)
+}
+
+//DeleteKeyPair deletes the key pair identified by id, the call is audited
+func (c *AuditClient) DeleteKeyPair(id string) error {
+	return c.DeleteKeyPairContext(context.Background(), id)
+}
+
+//DeleteKeyPairContext implements api.ClientAPIContext
+func (c *AuditClient) DeleteKeyPairContext(ctx context.Context, id string) error {
+	return c.record(ctx, auditCall{operation: "DeleteKeyPair", resourceType: "KeyPair", id: id}, func(ctx context.Context) (string, error) {
+		return "", c.clt.DeleteKeyPairContext(ctx, id)
+	})
+}
+
+//CreateNetwork creates a network named name, the call is audited
+func (c *AuditClient) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
+	return c.CreateNetworkContext(context.Background(), req)
+}
+
+//CreateNetworkContext implements api.ClientAPIContext
+func (c *AuditClient) CreateNetworkContext(ctx context.Context, req api.NetworkRequest) (*api.Network, error) {
+	var res *api.Network
+	err := c.record(ctx, auditCall{operation: "CreateNetwork", resourceType: "Network", name: req.Name, request: req}, func(ctx context.Context) (string, error) {
+		var err error
+		res, err = c.clt.CreateNetworkContext(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		return res.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetNetwork returns the network identified by id
+func (c *AuditClient) GetNetwork(id string) (*api.Network, error) {
+	return c.GetNetworkContext(context.Background(), id)
+}
+
+//GetNetworkContext implements api.ClientAPIContext
+func (c *AuditClient) GetNetworkContext(ctx context.Context, id string) (*api.Network, error) {
+	return c.clt.GetNetworkContext(ctx, id)
+}
+
+//ListNetworks lists available networks
+func (c *AuditClient) ListNetworks() ([]api.Network, error) {
+	return c.ListNetworksContext(context.Background())
+}
+
+//ListNetworksContext implements api.ClientAPIContext
+func (c *AuditClient) ListNetworksContext(ctx context.Context) ([]api.Network, error) {
+	return c.clt.ListNetworksContext(ctx)
+}
+
+//DeleteNetwork deletes the network identified by id, the call is audited
+func (c *AuditClient) DeleteNetwork(id string) error {
+	return c.DeleteNetworkContext(context.Background(), id)
+}
+
+//DeleteNetworkContext implements api.ClientAPIContext
+func (c *AuditClient) DeleteNetworkContext(ctx context.Context, id string) error {
+	return c.record(ctx, auditCall{operation: "DeleteNetwork", resourceType: "Network", id: id}, func(ctx context.Context) (string, error) {
+		return "", c.clt.DeleteNetworkContext(ctx, id)
+	})
+}
+
+//CreateVM creates a VM that fulfils the request, the call is audited
+func (c *AuditClient) CreateVM(request api.VMRequest) (*api.VM, error) {
+	return c.CreateVMContext(context.Background(), request)
+}
+
+//CreateVMContext implements api.ClientAPIContext
+func (c *AuditClient) CreateVMContext(ctx context.Context, request api.VMRequest) (*api.VM, error) {
+	var res *api.VM
+	err := c.record(ctx, auditCall{operation: "CreateVM", resourceType: "VM", name: request.Name, request: request}, func(ctx context.Context) (string, error) {
+		var err error
+		res, err = c.clt.CreateVMContext(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		return res.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVM returns the VM identified by id
+func (c *AuditClient) GetVM(id string) (*api.VM, error) {
+	return c.GetVMContext(context.Background(), id)
+}
+
+//GetVMContext implements api.ClientAPIContext
+func (c *AuditClient) GetVMContext(ctx context.Context, id string) (*api.VM, error) {
+	return c.clt.GetVMContext(ctx, id)
+}
+
+//ListVMs lists available VMs
+func (c *AuditClient) ListVMs() ([]api.VM, error) {
+	return c.ListVMsContext(context.Background())
+}
+
+//ListVMsContext implements api.ClientAPIContext
+func (c *AuditClient) ListVMsContext(ctx context.Context) ([]api.VM, error) {
+	return c.clt.ListVMsContext(ctx)
+}
+
+//DeleteVM deletes the VM identified by id, the call is audited
+func (c *AuditClient) DeleteVM(id string) error {
+	return c.DeleteVMContext(context.Background(), id)
+}
+
+//DeleteVMContext implements api.ClientAPIContext
+func (c *AuditClient) DeleteVMContext(ctx context.Context, id string) error {
+	return c.record(ctx, auditCall{operation: "DeleteVM", resourceType: "VM", id: id}, func(ctx context.Context) (string, error) {
+		return "", c.clt.DeleteVMContext(ctx, id)
+	})
+}
+
+//StopVM stops the VM identified by id, the call is audited
+func (c *AuditClient) StopVM(id string) error {
+	return c.StopVMContext(context.Background(), id)
+}
+
+//StopVMContext implements api.ClientAPIContext
+func (c *AuditClient) StopVMContext(ctx context.Context, id string) error {
+	return c.record(ctx, auditCall{operation: "StopVM", resourceType: "VM", id: id}, func(ctx context.Context) (string, error) {
+		return "", c.clt.StopVMContext(ctx, id)
+	})
+}
+
+//StartVM starts the VM identified by id, the call is audited
+func (c *AuditClient) StartVM(id string) error {
+	return c.StartVMContext(context.Background(), id)
+}
+
+//StartVMContext implements api.ClientAPIContext
+func (c *AuditClient) StartVMContext(ctx context.Context, id string) error {
+	return c.record(ctx, auditCall{operation: "StartVM", resourceType: "VM", id: id}, func(ctx context.Context) (string, error) {
+		return "", c.clt.StartVMContext(ctx, id)
+	})
+}
+
+//GetSSHConfig creates SSHConfig from VM
+func (c *AuditClient) GetSSHConfig(id string) (*system.SSHConfig, error) {
+	return c.GetSSHConfigContext(context.Background(), id)
+}
+
+//GetSSHConfigContext implements api.ClientAPIContext
+func (c *AuditClient) GetSSHConfigContext(ctx context.Context, id string) (*system.SSHConfig, error) {
+	return c.clt.GetSSHConfigContext(ctx, id)
+}
+
+//CreateVolume creates a block volume, the call is audited
+func (c *AuditClient) CreateVolume(request api.VolumeRequest) (*api.Volume, error) {
+	return c.CreateVolumeContext(context.Background(), request)
+}
+
+//CreateVolumeContext implements api.ClientAPIContext
+func (c *AuditClient) CreateVolumeContext(ctx context.Context, request api.VolumeRequest) (*api.Volume, error) {
+	var res *api.Volume
+	err := c.record(ctx, auditCall{operation: "CreateVolume", resourceType: "Volume", name: request.Name, request: request}, func(ctx context.Context) (string, error) {
+		var err error
+		res, err = c.clt.CreateVolumeContext(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		return res.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVolume returns the volume identified by id
+func (c *AuditClient) GetVolume(id string) (*api.Volume, error) {
+	return c.GetVolumeContext(context.Background(), id)
+}
+
+//GetVolumeContext implements api.ClientAPIContext
+func (c *AuditClient) GetVolumeContext(ctx context.Context, id string) (*api.Volume, error) {
+	return c.clt.GetVolumeContext(ctx, id)
+}
+
+//ListVolumes list available volumes
+func (c *AuditClient) ListVolumes() ([]api.Volume, error) {
+	return c.ListVolumesContext(context.Background())
+}
+
+//ListVolumesContext implements api.ClientAPIContext
+func (c *AuditClient) ListVolumesContext(ctx context.Context) ([]api.Volume, error) {
+	return c.clt.ListVolumesContext(ctx)
+}
+
+//DeleteVolume deletes the volume identified by id, the call is audited
+func (c *AuditClient) DeleteVolume(id string) error {
+	return c.DeleteVolumeContext(context.Background(), id)
+}
+
+//DeleteVolumeContext implements api.ClientAPIContext
+func (c *AuditClient) DeleteVolumeContext(ctx context.Context, id string) error {
+	return c.record(ctx, auditCall{operation: "DeleteVolume", resourceType: "Volume", id: id}, func(ctx context.Context) (string, error) {
+		return "", c.clt.DeleteVolumeContext(ctx, id)
+	})
+}
+
+//UpdateVolume renames, resizes or changes the speed of the volume identified by id, the call is audited
+func (c *AuditClient) UpdateVolume(id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	return c.UpdateVolumeContext(context.Background(), id, request)
+}
+
+//UpdateVolumeContext implements api.ClientAPIContext
+func (c *AuditClient) UpdateVolumeContext(ctx context.Context, id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	var res *api.Volume
+	err := c.record(ctx, auditCall{operation: "UpdateVolume", resourceType: "Volume", id: id, request: request}, func(ctx context.Context) (string, error) {
+		var err error
+		res, err = c.clt.UpdateVolumeContext(ctx, id, request)
+		return "", err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CreateVolumeAttachment attaches a volume to a VM, the call is audited
+func (c *AuditClient) CreateVolumeAttachment(request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	return c.CreateVolumeAttachmentContext(context.Background(), request)
+}
+
+//CreateVolumeAttachmentContext implements api.ClientAPIContext
+func (c *AuditClient) CreateVolumeAttachmentContext(ctx context.Context, request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	var res *api.VolumeAttachment
+	err := c.record(ctx, auditCall{operation: "CreateVolumeAttachment", resourceType: "VolumeAttachment", name: request.Name, request: request}, func(ctx context.Context) (string, error) {
+		var err error
+		res, err = c.clt.CreateVolumeAttachmentContext(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		return res.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVolumeAttachment returns the volume attachment identified by id
+func (c *AuditClient) GetVolumeAttachment(serverID string, id string) (*api.VolumeAttachment, error) {
+	return c.GetVolumeAttachmentContext(context.Background(), serverID, id)
+}
+
+//GetVolumeAttachmentContext implements api.ClientAPIContext
+func (c *AuditClient) GetVolumeAttachmentContext(ctx context.Context, serverID string, id string) (*api.VolumeAttachment, error) {
+	return c.clt.GetVolumeAttachmentContext(ctx, serverID, id)
+}
+
+//ListVolumeAttachments lists available volume attachment
+func (c *AuditClient) ListVolumeAttachments(serverID string) ([]api.VolumeAttachment, error) {
+	return c.ListVolumeAttachmentsContext(context.Background(), serverID)
+}
+
+//ListVolumeAttachmentsContext implements api.ClientAPIContext
+func (c *AuditClient) ListVolumeAttachmentsContext(ctx context.Context, serverID string) ([]api.VolumeAttachment, error) {
+	return c.clt.ListVolumeAttachmentsContext(ctx, serverID)
+}
+
+//DeleteVolumeAttachment deletes the volume attachment identifed by id, the call is audited
+func (c *AuditClient) DeleteVolumeAttachment(serverID string, id string) error {
+	return c.DeleteVolumeAttachmentContext(context.Background(), serverID, id)
+}
+
+//DeleteVolumeAttachmentContext implements api.ClientAPIContext
+func (c *AuditClient) DeleteVolumeAttachmentContext(ctx context.Context, serverID string, id string) error {
+	return c.record(ctx, auditCall{operation: "DeleteVolumeAttachment", resourceType: "VolumeAttachment", id: id, request: map[string]string{"vm": serverID}}, func(ctx context.Context) (string, error) {
+		return "", c.clt.DeleteVolumeAttachmentContext(ctx, serverID, id)
+	})
+}
+
+//CreateContainer creates an object container, the call is audited
+func (c *AuditClient) CreateContainer(name string) error {
+	return c.CreateContainerContext(context.Background(), name)
+}
+
+//CreateContainerContext implements api.ClientAPIContext
+func (c *AuditClient) CreateContainerContext(ctx context.Context, name string) error {
+	return c.record(ctx, auditCall{operation: "CreateContainer", resourceType: "Container", name: name}, func(ctx context.Context) (string, error) {
+		return "", c.clt.CreateContainerContext(ctx, name)
+	})
+}
+
+//DeleteContainer deletes an object container, the call is audited
+func (c *AuditClient) DeleteContainer(name string) error {
+	return c.DeleteContainerContext(context.Background(), name)
+}
+
+//DeleteContainerContext implements api.ClientAPIContext
+func (c *AuditClient) DeleteContainerContext(ctx context.Context, name string) error {
+	return c.record(ctx, auditCall{operation: "DeleteContainer", resourceType: "Container", name: name}, func(ctx context.Context) (string, error) {
+		return "", c.clt.DeleteContainerContext(ctx, name)
+	})
+}
+
+//ListContainers list object containers
+func (c *AuditClient) ListContainers() ([]string, error) {
+	return c.ListContainersContext(context.Background())
+}
+
+//ListContainersContext implements api.ClientAPIContext
+func (c *AuditClient) ListContainersContext(ctx context.Context) ([]string, error) {
+	return c.clt.ListContainersContext(ctx)
+}
+
+//PutObject put an object into an object container, the call is audited
+func (c *AuditClient) PutObject(container string, obj api.Object) error {
+	return c.PutObjectContext(context.Background(), container, obj)
+}
+
+//PutObjectContext implements api.ClientAPIContext
+func (c *AuditClient) PutObjectContext(ctx context.Context, container string, obj api.Object) error {
+	return c.record(ctx, auditCall{operation: "PutObject", resourceType: "Object", name: container + "/" + obj.Name, request: obj}, func(ctx context.Context) (string, error) {
+		return "", c.clt.PutObjectContext(ctx, container, obj)
+	})
+}
+
+//UpdateObjectMetadata update an object into  object container, the call is audited
+func (c *AuditClient) UpdateObjectMetadata(container string, obj api.Object) error {
+	return c.UpdateObjectMetadataContext(context.Background(), container, obj)
+}
+
+//UpdateObjectMetadataContext implements api.ClientAPIContext
+func (c *AuditClient) UpdateObjectMetadataContext(ctx context.Context, container string, obj api.Object) error {
+	return c.record(ctx, auditCall{operation: "UpdateObjectMetadata", resourceType: "Object", name: container + "/" + obj.Name, request: obj}, func(ctx context.Context) (string, error) {
+		return "", c.clt.UpdateObjectMetadataContext(ctx, container, obj)
+	})
+}
+
+//GetObject get  object content from an object container
+func (c *AuditClient) GetObject(container string, name string, ranges []api.Range) (*api.Object, error) {
+	return c.GetObjectContext(context.Background(), container, name, ranges)
+}
+
+//GetObjectContext implements api.ClientAPIContext
+func (c *AuditClient) GetObjectContext(ctx context.Context, container string, name string, ranges []api.Range) (*api.Object, error) {
+	return c.clt.GetObjectContext(ctx, container, name, ranges)
+}
+
+//GetObjectMetadata get  object metadata from an object container
+func (c *AuditClient) GetObjectMetadata(container string, name string) (*api.Object, error) {
+	return c.GetObjectMetadataContext(context.Background(), container, name)
+}
+
+//GetObjectMetadataContext implements api.ClientAPIContext
+func (c *AuditClient) GetObjectMetadataContext(ctx context.Context, container string, name string) (*api.Object, error) {
+	return c.clt.GetObjectMetadataContext(ctx, container, name)
+}
+
+//ListObjects list objects of a container
+func (c *AuditClient) ListObjects(container string, filter api.ObjectFilter) ([]string, error) {
+	return c.ListObjectsContext(context.Background(), container, filter)
+}
+
+//ListObjectsContext implements api.ClientAPIContext
+func (c *AuditClient) ListObjectsContext(ctx context.Context, container string, filter api.ObjectFilter) ([]string, error) {
+	return c.clt.ListObjectsContext(ctx, container, filter)
+}
+
+//CopyObject copies an object, the call is audited
+func (c *AuditClient) CopyObject(containerSrc string, objectSrc string, objectDst string) error {
+	return c.CopyObjectContext(context.Background(), containerSrc, objectSrc, objectDst)
+}
+
+//CopyObjectContext implements api.ClientAPIContext
+func (c *AuditClient) CopyObjectContext(ctx context.Context, containerSrc string, objectSrc string, objectDst string) error {
+	return c.record(ctx, auditCall{operation: "CopyObject", resourceType: "Object", name: containerSrc + "/" + objectDst, request: map[string]string{"source": containerSrc + "/" + objectSrc}}, func(ctx context.Context) (string, error) {
+		return "", c.clt.CopyObjectContext(ctx, containerSrc, objectSrc, objectDst)
+	})
+}
+
+//DeleteObject deleta an object from a container, the call is audited
+func (c *AuditClient) DeleteObject(container string, object string) error {
+	return c.DeleteObjectContext(context.Background(), container, object)
+}
+
+//DeleteObjectContext implements api.ClientAPIContext
+func (c *AuditClient) DeleteObjectContext(ctx context.Context, container string, object string) error {
+	return c.record(ctx, auditCall{operation: "DeleteObject", resourceType: "Object", name: container + "/" + object}, func(ctx context.Context) (string, error) {
+		return "", c.clt.DeleteObjectContext(ctx, container, object)
+	})
+}
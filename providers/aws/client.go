@@ -4,13 +4,15 @@ package aws
 import (
 	"bytes"
 	"encoding/base64"
-	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/SebastienDorgan/gpac/providers"
@@ -30,12 +32,19 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/pricing"
 	"github.com/aws/aws-sdk-go/service/s3"
+	uuid "github.com/satori/go.uuid"
 )
 
+//GatewayTerminationTimeout time given to the gateway of a network to terminate when the network is deleted
+var GatewayTerminationTimeout = 120 * time.Second
+
 //Config AWS configurations
 type Config struct {
 	ImageOwners    []string
 	DefaultNetwork string
+	//AvailabilityZone zone of the subnets and of the volumes, volumes can only be attached to the VMs of their zone
+	//The first available zone of the region is used if empty
+	AvailabilityZone string
 }
 
 //AuthOpts AWS credentials
@@ -53,7 +62,11 @@ type AuthOpts struct {
 	// @see http://docs.aws.amazon.com/general/latest/gr/rande.html
 	//   AWS Regions and Endpoints
 	Region string
-	Config *Config
+	// Endpoint overrides the URL of the EC2, S3 and Pricing services (used to
+	// target a local stand-in such as providers/aws/fake). S3 requests are
+	// then sent path style.
+	Endpoint string
+	Config   *Config
 }
 
 // Retrieve returns nil if it successfully retrieved the value.
@@ -72,19 +85,26 @@ func (o AuthOpts) IsExpired() bool {
 	return false
 }
 
+//sessionConfig returns the configuration of a session targeting region
+func sessionConfig(opts AuthOpts, region string) *aws.Config {
+	cfg := aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.NewCredentials(opts),
+	}
+	if opts.Endpoint != "" {
+		cfg.Endpoint = aws.String(opts.Endpoint)
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	}
+	return &cfg
+}
+
 //AuthenticatedClient returns an authenticated client
 func AuthenticatedClient(opts AuthOpts) (*Client, error) {
-	s, err := session.NewSession(&aws.Config{
-		Region:      aws.String(opts.Region),
-		Credentials: credentials.NewCredentials(opts),
-	})
+	s, err := session.NewSession(sessionConfig(opts, opts.Region))
 	if err != nil {
 		return nil, err
 	}
-	sPricing, err := session.NewSession(&aws.Config{
-		Region:      aws.String("us-east-1"),
-		Credentials: credentials.NewCredentials(opts),
-	})
+	sPricing, err := session.NewSession(sessionConfig(opts, "us-east-1"))
 	if err != nil {
 		return nil, err
 	}
@@ -106,22 +126,89 @@ func AuthenticatedClient(opts AuthOpts) (*Client, error) {
 		Pricing:     pricing.New(sPricing),
 		AuthOpts:    opts,
 		UserDataTpl: tpl,
+		templates:   &templateCache{tpls: map[string]*api.VMTemplate{}},
 	}
-	c.CreateContainer("gpac.aws.networks")
-	c.CreateContainer("gpac.aws.wms")
-	c.CreateContainer("gpac.aws.volumes")
-
+	c.metadata = providers.NewObjectMetadataStore(&c)
 	return &c, nil
 }
 
+//Buckets of the metadata store where the driver keeps the data AWS does not store
+const (
+	networksBucket = "gpac.aws.networks"
+	vmsBucket      = "gpac.aws.wms"
+	volumesBucket  = "gpac.aws.volumes"
+)
+
+//SetMetadataStore sets the store of the networks, VM definitions and volume names, they are kept in S3 by default
+func (c *Client) SetMetadataStore(store providers.MetadataStore) {
+	c.metadata = store
+}
+
+//MetadataStore returns the store of the networks, VM definitions and volume names
+func (c *Client) MetadataStore() providers.MetadataStore {
+	return c.metadata
+}
+
+//MetadataBuckets returns the buckets of the networks, VM definitions and volume names
+func (c *Client) MetadataBuckets() []providers.RecordBucket {
+	return []providers.RecordBucket{
+		{Bucket: networksBucket, Kind: providers.RecordNetwork, New: func() interface{} { return &api.Network{} }},
+		{Bucket: vmsBucket, Kind: providers.RecordVM, New: func() interface{} { return &api.VM{} }},
+		{Bucket: volumesBucket, Kind: providers.RecordVolumeName, New: func() interface{} { return new(string) }},
+	}
+}
+
+//wrapError creates an api.Error from an error of the AWS SDK, the kind of the error is deduced from the AWS error code
+//and the AWS error code and message are kept. Errors already created by the driver are returned unchanged
 func wrapError(msg string, err error) error {
 	if err == nil {
 		return nil
 	}
+	if _, ok := err.(*api.Error); ok {
+		return err
+	}
+	e := api.NewError(api.KindOf(err), err, "%s", msg)
 	if aerr, ok := err.(awserr.Error); ok {
-		return fmt.Errorf("%s: cause by %s", msg, aerr.Message())
+		e.Code = aerr.Code()
+		e.Body = aerr.Message()
+		if kind := errorKind(aerr); kind != nil {
+			e.Kind = kind
+		} else if e.Kind == nil {
+			//the errors of the SDK do not unwrap, the kind of a failed request is the kind of its cause
+			e.Kind = api.KindOf(aerr.OrigErr())
+		}
 	}
-	return err
+	return e
+}
+
+//errorKind returns the kind of an error of the AWS SDK
+func errorKind(aerr awserr.Error) error {
+	code := aerr.Code()
+	switch {
+	case strings.HasSuffix(code, "NotFound") || code == "NoSuchBucket" || code == "NoSuchKey":
+		return api.ErrNotFound
+	case strings.Contains(code, "AlreadyExists") || strings.HasSuffix(code, ".Duplicate") || code == "BucketAlreadyOwnedByYou":
+		return api.ErrAlreadyExists
+	case code == "RequestLimitExceeded" || strings.HasPrefix(code, "Throttling") || code == "ServiceUnavailable" ||
+		code == "Unavailable" || code == "InternalError" || code == "InsufficientInstanceCapacity":
+		return api.ErrUnavailable
+	case strings.HasSuffix(code, "LimitExceeded") || strings.HasSuffix(code, "QuotaExceeded"):
+		return api.ErrQuotaExceeded
+	case code == "AuthFailure" || code == "UnauthorizedOperation" || code == "AccessDenied" || code == "SignatureDoesNotMatch" ||
+		code == "InvalidClientTokenId" || code == "ExpiredToken" || code == "OptInRequired":
+		return api.ErrUnauthorized
+	case strings.HasPrefix(code, "IncorrectState") || code == "IncorrectInstanceState" || code == "DependencyViolation" ||
+		code == "VolumeInUse" || code == "BucketNotEmpty" || code == "OperationAborted":
+		return api.ErrConflict
+	case strings.HasPrefix(code, "RequestTimeout"):
+		return api.ErrTimeout
+	case strings.HasPrefix(code, "Invalid") || code == "MissingParameter" || code == "ValidationError" || code == "UnknownParameter":
+		return api.ErrInvalidRequest
+	}
+	if rerr, ok := aerr.(awserr.RequestFailure); ok {
+		return api.KindOfStatus(rerr.StatusCode())
+	}
+	return nil
 }
 
 //Client a AWS provider client
@@ -132,6 +219,49 @@ type Client struct {
 	AuthOpts    AuthOpts
 	UserDataTpl *template.Template
 	ImageOwners []string
+	//templates templates of the instance types of the VMs, it is shared by the copies of the client
+	templates *templateCache
+	//metadata store of the data AWS does not keep (gateways of the networks, VM definitions, volume names)
+	metadata providers.MetadataStore
+	//background client the copy was made from by withContext, nil if the client is not bound to a context
+	background *Client
+}
+
+//unbound returns the client whose requests are not bound to a context
+//The cleanups of failed operations run on it so that cancelling an operation does not leak the resources it created,
+//as the allocations whose response is needed to release them
+func (c *Client) unbound() *Client {
+	if c.background != nil {
+		return c.background
+	}
+	return c
+}
+
+//templateCache templates indexed by instance type, each GetVM and ListVMs would otherwise query the Pricing API
+type templateCache struct {
+	mu   sync.Mutex
+	tpls map[string]*api.VMTemplate
+}
+
+//instanceTemplate returns the template of the instance type
+func (c *Client) instanceTemplate(instanceType string) (*api.VMTemplate, error) {
+	if c.templates == nil {
+		return c.GetTemplate(instanceType)
+	}
+	c.templates.mu.Lock()
+	tpl, ok := c.templates.tpls[instanceType]
+	c.templates.mu.Unlock()
+	if ok {
+		return tpl, nil
+	}
+	tpl, err := c.GetTemplate(instanceType)
+	if err != nil {
+		return nil, err
+	}
+	c.templates.mu.Lock()
+	c.templates.tpls[instanceType] = tpl
+	c.templates.mu.Unlock()
+	return tpl, nil
 }
 
 func createFilters() []*ec2.Filter {
@@ -182,7 +312,7 @@ func (c *Client) ListImages() ([]api.Image, error) {
 		Filters: createFilters(),
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error listing images", err)
 	}
 	var list []api.Image
 	for _, img := range images.Images {
@@ -236,19 +366,17 @@ type Product struct {
 
 //PriceDimension compute instance price related to term condition
 type PriceDimension struct {
-	AppliesTo    []string           `json:"appliesTo,omitempty"`
-	BeginRange   string             `json:"beginRange,omitempty"`
-	Description  string             `json:"description,omitempty"`
-	EndRange     string             `json:"endRange,omitempty"`
-	PricePerUnit map[string]float32 `json:"pricePerUnit,omitempty"`
-	RateCode     string             `json:"RateCode,omitempty"`
-	Unit         string             `json:"Unit,omitempty"`
+	AppliesTo    []string          `json:"appliesTo,omitempty"`
+	BeginRange   string            `json:"beginRange,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	EndRange     string            `json:"endRange,omitempty"`
+	PricePerUnit map[string]string `json:"pricePerUnit,omitempty"`
+	RateCode     string            `json:"rateCode,omitempty"`
+	Unit         string            `json:"unit,omitempty"`
 }
 
-//PriceDimensions compute instance price dimensions
-type PriceDimensions struct {
-	PriceDimensionMap map[string]PriceDimension `json:"price_dimension_map,omitempty"`
-}
+//PriceDimensions compute instance price dimensions indexed by rate code
+type PriceDimensions map[string]PriceDimension
 
 //TermAttributes compute instance terms
 type TermAttributes struct {
@@ -266,20 +394,39 @@ type Card struct {
 	TermAttributes  TermAttributes  `json:"termAttributes,omitempty"`
 }
 
-//OnDemand on demand compute instance cards
-type OnDemand struct {
-	Cards map[string]Card
-}
+//OnDemand on demand compute instance cards indexed by offer term
+type OnDemand map[string]Card
 
-//Reserved reserved compute instance cards
-type Reserved struct {
-	Cards map[string]Card `json:"cards,omitempty"`
-}
+//Reserved reserved compute instance cards indexed by offer term
+type Reserved map[string]Card
 
 //Terms compute instance prices terms
 type Terms struct {
-	OnDemand OnDemand `json:"onDemand,omitempty"`
-	Reserved Reserved `json:"reserved,omitempty"`
+	OnDemand OnDemand `json:"OnDemand,omitempty"`
+	Reserved Reserved `json:"Reserved,omitempty"`
+}
+
+//hourlyPrice returns the on demand hourly price of a compute instance, nil if not found
+func (p *Price) hourlyPrice() *api.Price {
+	for _, card := range p.Terms.OnDemand {
+		for _, dim := range card.PriceDimensions {
+			if dim.Unit != "Hrs" {
+				continue
+			}
+			for currency, amount := range dim.PricePerUnit {
+				value, err := strconv.ParseFloat(amount, 64)
+				if err != nil {
+					continue
+				}
+				return &api.Price{
+					Amount:   value,
+					Currency: currency,
+					Unit:     api.PriceUnitHour,
+				}
+			}
+		}
+	}
+	return nil
 }
 
 //Price Compute instance price information
@@ -296,10 +443,10 @@ func (c *Client) GetImage(id string) (*api.Image, error) {
 		ImageIds: []*string{aws.String(id)},
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error getting image", err)
 	}
 	if len(images.Images) == 0 {
-		return nil, fmt.Errorf("Image %s does not exists", id)
+		return nil, api.NewError(api.ErrNotFound, nil, "Image %s does not exists", id)
 	}
 	img := images.Images[0]
 	return &api.Image{
@@ -308,38 +455,77 @@ func (c *Client) GetImage(id string) (*api.Image, error) {
 	}, nil
 }
 
+//pricingFilters returns the Pricing filters selecting on demand Linux instances of the client region
+func (c *Client) pricingFilters(region *RegionInfo) []*pricing.Filter {
+	return []*pricing.Filter{
+		{
+			Field: aws.String("ServiceCode"),
+			Type:  aws.String("TERM_MATCH"),
+			Value: aws.String("AmazonEC2"),
+		},
+		{
+			Field: aws.String("location"),
+			Type:  aws.String("TERM_MATCH"),
+			Value: aws.String(region.Location),
+		},
+		{
+			Field: aws.String("preInstalledSw"),
+			Type:  aws.String("TERM_MATCH"),
+			Value: aws.String("NA"),
+		},
+		{
+			Field: aws.String("operatingSystem"),
+			Type:  aws.String("TERM_MATCH"),
+			Value: aws.String("Linux"),
+		},
+	}
+}
+
+//toTemplate converts a price document into a VM template
+//ok is false if the price document does not describe an on demand shared instance of the region
+func toTemplate(region *RegionInfo, doc aws.JSONValue) (tpl *api.VMTemplate, ok bool) {
+	jsonPrice, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false
+	}
+	price := Price{}
+	err = json.Unmarshal(jsonPrice, &price)
+	if err != nil {
+		return nil, false
+	}
+	attrs := price.Product.Attributes
+	if attrs.Usagetype != region.BoxUsage(attrs.InstanceType) {
+		return nil, false
+	}
+	cores, err := strconv.Atoi(attrs.Vcpu)
+	if err != nil {
+		return nil, false
+	}
+	return &api.VMTemplate{
+		ID:   attrs.InstanceType,
+		Name: attrs.InstanceType,
+		VMSize: api.VMSize{
+			Cores:    cores,
+			DiskSize: int(parseStorage(attrs.Storage)),
+			RAMSize:  float32(parseMemory(attrs.Memory)),
+		},
+		Price: price.hourlyPrice(),
+	}, true
+}
+
 //GetTemplate returns the Template referenced by id
 func (c *Client) GetTemplate(id string) (*api.VMTemplate, error) {
+	region, err := GetRegionInfo(c.AuthOpts.Region)
+	if err != nil {
+		return nil, wrapError("Error getting template", err)
+	}
+	filters := append(c.pricingFilters(region), &pricing.Filter{
+		Field: aws.String("instanceType"),
+		Type:  aws.String("TERM_MATCH"),
+		Value: aws.String(id),
+	})
 	input := pricing.GetProductsInput{
-		Filters: []*pricing.Filter{
-			{
-				Field: aws.String("ServiceCode"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("AmazonEC2"),
-			},
-			{
-				Field: aws.String("location"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("US East (Ohio)"),
-			},
-
-			{
-				Field: aws.String("preInstalledSw"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("NA"),
-			},
-			{
-				Field: aws.String("operatingSystem"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("Linux"),
-			},
-
-			{
-				Field: aws.String("instanceType"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String(id),
-			},
-		},
+		Filters:       filters,
 		FormatVersion: aws.String("aws_v1"),
 		MaxResults:    aws.Int64(100),
 		ServiceCode:   aws.String("AmazonEC2"),
@@ -347,37 +533,14 @@ func (c *Client) GetTemplate(id string) (*api.VMTemplate, error) {
 
 	p, err := c.Pricing.GetProducts(&input)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error getting template", err)
 	}
 	for _, price := range p.PriceList {
-		jsonPrice, err := json.Marshal(price)
-		if err != nil {
-			continue
-		}
-		price := Price{}
-		err = json.Unmarshal(jsonPrice, &price)
-		if err != nil {
-			continue
-		}
-		if strings.Contains(price.Product.Attributes.Usagetype, "USE2-BoxUsage:") {
-			cores, err := strconv.Atoi(price.Product.Attributes.Vcpu)
-			if err != nil {
-				continue
-			}
-
-			tpl := api.VMTemplate{
-				ID:   price.Product.Attributes.InstanceType,
-				Name: price.Product.Attributes.InstanceType,
-				VMSize: api.VMSize{
-					Cores:    cores,
-					DiskSize: int(parseStorage(price.Product.Attributes.Storage)),
-					RAMSize:  float32(parseMemory(price.Product.Attributes.Memory)),
-				},
-			}
-			return &tpl, nil
+		if tpl, ok := toTemplate(region, price); ok {
+			return tpl, nil
 		}
 	}
-	return nil, fmt.Errorf("Unable to find template %s", id)
+	return nil, api.NewError(api.ErrNotFound, nil, "Unable to find template %s", id)
 
 }
 
@@ -422,73 +585,60 @@ func parseMemory(str string) float64 {
 	return size
 }
 
-//ListTemplates lists available VM templates
+//offeredInstanceTypes returns the instance types offered in the client region
+func (c *Client) offeredInstanceTypes() (map[string]bool, error) {
+	offered := map[string]bool{}
+	err := c.EC2.DescribeInstanceTypeOfferingsPages(&ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: aws.String("region"),
+		Filters: []*ec2.Filter{
+			&ec2.Filter{
+				Name:   aws.String("location"),
+				Values: []*string{aws.String(c.AuthOpts.Region)},
+			},
+		},
+	},
+		func(out *ec2.DescribeInstanceTypeOfferingsOutput, lastPage bool) bool {
+			for _, o := range out.InstanceTypeOfferings {
+				offered[pStr(o.InstanceType)] = true
+			}
+			return true
+		})
+	if err != nil {
+		return nil, wrapError("Error listing instance type offerings", err)
+	}
+	return offered, nil
+}
+
+//ListTemplates lists VM templates offered in the client region
 //VM templates are sorted using Dominant Resource Fairness Algorithm
 func (c *Client) ListTemplates() ([]api.VMTemplate, error) {
+	region, err := GetRegionInfo(c.AuthOpts.Region)
+	if err != nil {
+		return nil, wrapError("Error listing templates", err)
+	}
+	offered, err := c.offeredInstanceTypes()
+	if err != nil {
+		return nil, wrapError("Error listing templates", err)
+	}
 	input := pricing.GetProductsInput{
-		Filters: []*pricing.Filter{
-			{
-				Field: aws.String("ServiceCode"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("AmazonEC2"),
-			},
-			{
-				Field: aws.String("location"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("US East (Ohio)"),
-			},
-			{
-				Field: aws.String("preInstalledSw"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("NA"),
-			},
-			{
-				Field: aws.String("operatingSystem"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("Linux"),
-			},
-		},
+		Filters:       c.pricingFilters(region),
 		FormatVersion: aws.String("aws_v1"),
 		MaxResults:    aws.Int64(100),
 		ServiceCode:   aws.String("AmazonEC2"),
 	}
 	tpls := []api.VMTemplate{}
-	//prices := map[string]interface{}{}
-	err := c.Pricing.GetProductsPages(&input,
+	err = c.Pricing.GetProductsPages(&input,
 		func(p *pricing.GetProductsOutput, lastPage bool) bool {
-
 			for _, price := range p.PriceList {
-				jsonPrice, err := json.Marshal(price)
-				if err != nil {
-					continue
-				}
-				price := Price{}
-				err = json.Unmarshal(jsonPrice, &price)
-				if err != nil {
-					continue
-				}
-				if strings.Contains(price.Product.Attributes.Usagetype, "USE2-BoxUsage:") {
-					cores, err := strconv.Atoi(price.Product.Attributes.Vcpu)
-					if err != nil {
-						continue
-					}
-
-					tpl := api.VMTemplate{
-						ID:   price.Product.Attributes.InstanceType,
-						Name: price.Product.Attributes.InstanceType,
-						VMSize: api.VMSize{
-							Cores:    cores,
-							DiskSize: int(parseStorage(price.Product.Attributes.Storage)),
-							RAMSize:  float32(parseMemory(price.Product.Attributes.Memory)),
-						},
-					}
-					tpls = append(tpls, tpl)
+				tpl, ok := toTemplate(region, price)
+				if ok && offered[tpl.ID] {
+					tpls = append(tpls, *tpl)
 				}
 			}
-			return lastPage
+			return true
 		})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error listing templates", err)
 	}
 	return tpls, nil
 }
@@ -497,17 +647,18 @@ func (c *Client) ListTemplates() ([]api.VMTemplate, error) {
 func (c *Client) CreateKeyPair(name string) (*api.KeyPair, error) {
 	publicKey, privateKey, err := system.CreateKeyPair()
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error creating key pair", err)
 	}
-	c.EC2.ImportKeyPair(&ec2.ImportKeyPairInput{
+	_, err = c.EC2.ImportKeyPair(&ec2.ImportKeyPairInput{
 		KeyName:           aws.String(name),
 		PublicKeyMaterial: publicKey,
+		TagSpecifications: createdTags(ec2.ResourceTypeKeyPair),
 	})
 	// out, err := c.EC2.CreateKeyPair(&ec2.CreateKeyPairInput{
 	// 	KeyName: aws.String(name),
 	// })
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error creating key pair", err)
 	}
 	return &api.KeyPair{
 		ID:         name,
@@ -523,7 +674,7 @@ func (c *Client) GetKeyPair(id string) (*api.KeyPair, error) {
 		KeyNames: []*string{aws.String(id)},
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error getting key pair", err)
 	}
 	kp := out.KeyPairs[0]
 	return &api.KeyPair{
@@ -538,7 +689,7 @@ func (c *Client) GetKeyPair(id string) (*api.KeyPair, error) {
 func (c *Client) ListKeyPairs() ([]api.KeyPair, error) {
 	out, err := c.EC2.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error listing key pairs", err)
 	}
 	keys := []api.KeyPair{}
 	for _, kp := range out.KeyPairs {
@@ -558,67 +709,98 @@ func (c *Client) DeleteKeyPair(id string) error {
 	_, err := c.EC2.DeleteKeyPair(&ec2.DeleteKeyPairInput{
 		KeyName: aws.String(id),
 	})
-	return err
+	return wrapError("Error deleting key pair", err)
 }
 
 func (c *Client) saveNetwork(n api.Network) error {
-	b, err := json.Marshal(n)
+	b, err := providers.EncodeRecord(providers.RecordNetwork, n)
 	if err != nil {
 		return err
 	}
-	buffer := bytes.NewReader(b)
-	return c.PutObject("gpac.aws.networks", api.Object{
-		Name:    n.ID,
-		Content: buffer,
-	})
+	_, err = c.metadata.Put(networksBucket, n.ID, b)
+	return err
 }
 
 func (c *Client) getNetwork(netID string) (*api.Network, error) {
-	o, err := c.GetObject("gpac.aws.networks", netID, nil)
+	b, _, err := c.metadata.Get(networksBucket, netID)
 	if err != nil {
 		return nil, err
 	}
-	var buffer bytes.Buffer
-	buffer.ReadFrom(o.Content)
 	net := api.Network{}
-	err = json.Unmarshal(buffer.Bytes(), &net)
+	err = providers.DecodeRecord(providers.RecordNetwork, b, &net)
 	if err != nil {
 		return nil, err
 	}
 	return &net, err
 }
 func (c *Client) removeNetwork(netID string) error {
-	return c.DeleteObject("gpac.aws.networks", netID)
+	return c.metadata.Delete(networksBucket, netID)
+}
+
+//availabilityZone returns the zone where the subnets and the volumes are created
+func (c *Client) availabilityZone() (string, error) {
+	if c.AuthOpts.Config != nil && c.AuthOpts.Config.AvailabilityZone != "" {
+		return c.AuthOpts.Config.AvailabilityZone, nil
+	}
+	out, err := c.EC2.DescribeAvailabilityZones(&ec2.DescribeAvailabilityZonesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("state"),
+				Values: []*string{aws.String("available")},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	zones := []string{}
+	for _, z := range out.AvailabilityZones {
+		zones = append(zones, pStr(z.ZoneName))
+	}
+	if len(zones) == 0 {
+		return "", api.NewError(api.ErrUnavailable, nil, "No availability zone available in region %s", c.AuthOpts.Region)
+	}
+	sort.Strings(zones)
+	return zones[0], nil
 }
 
 //CreateNetwork creates a network named name
 func (c *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
+	zone, err := c.availabilityZone()
+	if err != nil {
+		return nil, wrapError("Error creating network", err)
+	}
 	vpcOut, err := c.EC2.CreateVpc(&ec2.CreateVpcInput{
-		CidrBlock: aws.String(req.CIDR),
+		CidrBlock:         aws.String(req.CIDR),
+		TagSpecifications: createdTags(ec2.ResourceTypeVpc),
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error creating network", err)
 	}
 	sn, err := c.EC2.CreateSubnet(&ec2.CreateSubnetInput{
-		CidrBlock: aws.String(req.CIDR),
-		VpcId:     vpcOut.Vpc.VpcId,
+		AvailabilityZone:  aws.String(zone),
+		CidrBlock:         aws.String(req.CIDR),
+		VpcId:             vpcOut.Vpc.VpcId,
+		TagSpecifications: createdTags(ec2.ResourceTypeSubnet),
 	})
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		c.unbound().DeleteNetwork(*vpcOut.Vpc.VpcId)
+		return nil, wrapError("Error creating network", err)
 	}
-	gw, err := c.EC2.CreateInternetGateway(&ec2.CreateInternetGatewayInput{})
+	gw, err := c.EC2.CreateInternetGateway(&ec2.CreateInternetGatewayInput{
+		TagSpecifications: createdTags(ec2.ResourceTypeInternetGateway),
+	})
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		c.unbound().DeleteNetwork(*vpcOut.Vpc.VpcId)
+		return nil, wrapError("Error creating network", err)
 	}
 	_, err = c.EC2.AttachInternetGateway(&ec2.AttachInternetGatewayInput{
 		VpcId:             vpcOut.Vpc.VpcId,
 		InternetGatewayId: gw.InternetGateway.InternetGatewayId,
 	})
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		c.unbound().DeleteNetwork(*vpcOut.Vpc.VpcId)
+		return nil, wrapError("Error creating network", err)
 	}
 	table, err := c.EC2.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
 		Filters: []*ec2.Filter{
@@ -630,13 +812,12 @@ func (c *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
 			},
 		},
 	})
-	if err != nil || len(table.RouteTables) < 1 {
-		return nil, err
+	if err == nil && len(table.RouteTables) < 1 {
+		err = api.NewError(nil, nil, "Error creating network: no main route table in VPC %s", pStr(vpcOut.Vpc.VpcId))
 	}
-
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		c.unbound().DeleteNetwork(*vpcOut.Vpc.VpcId)
+		return nil, wrapError("Error creating network", err)
 	}
 	_, err = c.EC2.CreateRoute(&ec2.CreateRouteInput{
 		DestinationCidrBlock: aws.String("0.0.0.0/0"),
@@ -644,16 +825,16 @@ func (c *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
 		RouteTableId:         table.RouteTables[0].RouteTableId,
 	})
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		c.unbound().DeleteNetwork(*vpcOut.Vpc.VpcId)
+		return nil, wrapError("Error creating network", err)
 	}
 	_, err = c.EC2.AssociateRouteTable(&ec2.AssociateRouteTableInput{
 		RouteTableId: table.RouteTables[0].RouteTableId,
 		SubnetId:     sn.Subnet.SubnetId,
 	})
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		c.unbound().DeleteNetwork(*vpcOut.Vpc.VpcId)
+		return nil, wrapError("Error creating network", err)
 	}
 
 	req.GWRequest.PublicIP = true
@@ -661,7 +842,7 @@ func (c *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
 	req.GWRequest.NetworkIDs = append(req.GWRequest.NetworkIDs, *vpcOut.Vpc.VpcId)
 	vm, err := c.CreateVM(req.GWRequest)
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
+		c.unbound().DeleteNetwork(*vpcOut.Vpc.VpcId)
 		return nil, wrapError("Error creating network", err)
 	}
 	net := api.Network{
@@ -673,8 +854,8 @@ func (c *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
 	}
 	err = c.saveNetwork(net)
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		c.unbound().DeleteNetwork(*vpcOut.Vpc.VpcId)
+		return nil, wrapError("Error creating network", err)
 	}
 	return &net, nil
 }
@@ -683,33 +864,36 @@ func (c *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
 func (c *Client) GetNetwork(id string) (*api.Network, error) {
 	net, err := c.getNetwork(id)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error getting network", err)
 	}
 	out, err := c.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{
 		VpcIds: []*string{aws.String(id)},
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error getting network", err)
 	}
 	net.CIDR = *out.Vpcs[0].CidrBlock
 	net.ID = *out.Vpcs[0].VpcId
 	return net, nil
 }
 
-//ListNetworks lists available networks
+//ListNetworks lists the networks created by gpac
 func (c *Client) ListNetworks() ([]api.Network, error) {
 	out, err := c.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error listing networks", err)
 	}
 	nets := []api.Network{}
 	for _, vpc := range out.Vpcs {
 		net, err := c.getNetwork(*vpc.VpcId)
+		//the VPCs not created by gpac (e.g. the default VPC) have no record
+		if api.KindOf(err) == api.ErrNotFound {
+			continue
+		}
 		if err != nil {
-			return nil, err
+			return nil, wrapError("Error listing networks", err)
 		}
 		net.CIDR = *vpc.CidrBlock
-		net.CIDR = *vpc.VpcId
 		nets = append(nets, *net)
 	}
 	return nets, nil
@@ -745,24 +929,72 @@ func (c *Client) DeleteNetwork(id string) error {
 				AllocationId: addr.AllocationId,
 			})
 		}
+		//Network interfaces of the gateway are released once the VM is terminated
+		service := providers.Service{
+			ClientAPI: c,
+		}
+		_, err = service.WaitVMState(net.GatewayID, VMState.STOPPED, GatewayTerminationTimeout)
+		//the subnets and the VPC cannot be deleted while the network interfaces of the gateway are attached
+		if err != nil && api.KindOf(err) != api.ErrNotFound {
+			return wrapError("Error deleting network", err)
+		}
+	}
+	sns, err := c.getSubnets([]string{id})
+	if err != nil {
+		return wrapError("Error deleting network", err)
+	}
+	for _, sn := range sns {
+		_, err = c.EC2.DeleteSubnet(&ec2.DeleteSubnetInput{
+			SubnetId: sn.SubnetId,
+		})
+		if err != nil {
+			return wrapError("Error deleting network", err)
+		}
+	}
+	gws, err := c.EC2.DescribeInternetGateways(&ec2.DescribeInternetGatewaysInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("attachment.vpc-id"),
+				Values: []*string{aws.String(id)},
+			},
+		},
+	})
+	if err != nil {
+		return wrapError("Error deleting network", err)
+	}
+	for _, gw := range gws.InternetGateways {
+		_, err = c.EC2.DetachInternetGateway(&ec2.DetachInternetGatewayInput{
+			InternetGatewayId: gw.InternetGatewayId,
+			VpcId:             aws.String(id),
+		})
+		if err != nil {
+			return wrapError("Error deleting network", err)
+		}
+		_, err = c.EC2.DeleteInternetGateway(&ec2.DeleteInternetGatewayInput{
+			InternetGatewayId: gw.InternetGatewayId,
+		})
+		if err != nil {
+			return wrapError("Error deleting network", err)
+		}
 	}
-
 	_, err = c.EC2.DeleteVpc(&ec2.DeleteVpcInput{
 		VpcId: aws.String(id),
 	})
-	return err
+	if err != nil {
+		return wrapError("Error deleting network", err)
+	}
+	c.removeNetwork(id)
+	return nil
 }
 
 func (c *Client) getSubnets(vpcIDs []string) ([]*ec2.Subnet, error) {
-	filters := []*ec2.Filter{}
-	for _, id := range vpcIDs {
-		filters = append(filters, &ec2.Filter{
-			Name:   aws.String("vpc-id"),
-			Values: []*string{&id},
-		})
+	//Values of a filter are ORed while filters are ANDed
+	filter := ec2.Filter{
+		Name:   aws.String("vpc-id"),
+		Values: aws.StringSlice(vpcIDs),
 	}
 	out, err := c.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{
-		Filters: filters,
+		Filters: []*ec2.Filter{&filter},
 	})
 	if err != nil {
 		return nil, err
@@ -785,7 +1017,6 @@ func getState(state *ec2.InstanceState) (VMState.Enum, error) {
 	//    * 64 : stopping
 	//
 	//    * 80 : stopped
-	fmt.Println("State", state.Code)
 	if state == nil {
 		return VMState.ERROR, fmt.Errorf("Unexpected VM state")
 	}
@@ -865,30 +1096,23 @@ func (c *Client) prepareUserData(request api.VMRequest, kp *api.KeyPair, gw *api
 }
 
 func (c *Client) saveVM(vm api.VM) error {
-	var buffer bytes.Buffer
-	enc := gob.NewEncoder(&buffer)
-	err := enc.Encode(vm)
+	b, err := providers.EncodeRecord(providers.RecordVM, vm)
 	if err != nil {
 		return err
 	}
-	return c.PutObject("gpac.aws.wms", api.Object{
-		Name:    vm.ID,
-		Content: bytes.NewReader(buffer.Bytes()),
-	})
+	_, err = c.metadata.Put(vmsBucket, vm.ID, b)
+	return err
 }
 func (c *Client) removeVM(vmID string) error {
-	return c.DeleteObject("gpac.aws.wms", vmID)
+	return c.metadata.Delete(vmsBucket, vmID)
 }
 func (c *Client) readVM(vmID string) (*api.VM, error) {
-	o, err := c.GetObject("gpac.aws.wms", vmID, nil)
+	b, _, err := c.metadata.Get(vmsBucket, vmID)
 	if err != nil {
 		return nil, err
 	}
-	var buffer bytes.Buffer
-	buffer.ReadFrom(o.Content)
-	enc := gob.NewDecoder(&buffer)
 	var vm api.VM
-	err = enc.Decode(&vm)
+	err = providers.DecodeRecord(providers.RecordVM, b, &vm)
 	if err != nil {
 		return nil, err
 	}
@@ -897,8 +1121,9 @@ func (c *Client) readVM(vmID string) (*api.VM, error) {
 
 func (c *Client) createSecurityGroup(vpcID string, name string) (string, error) {
 	out, err := c.EC2.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
-		GroupName: aws.String(name),
-		VpcId:     aws.String(vpcID),
+		GroupName:         aws.String(name),
+		VpcId:             aws.String(vpcID),
+		TagSpecifications: createdTags(ec2.ResourceTypeSecurityGroup),
 	})
 	if err != nil {
 		return "", err
@@ -933,11 +1158,11 @@ func (c *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 	//If no KeyPair is supplied a temporay one is created
 	kp := request.KeyPair
 	if kp == nil {
-		kpTmp, err := c.CreateKeyPair(request.Name)
+		kpTmp, err := c.CreateKeyPair(fmt.Sprintf("%s_%s", request.Name, uuid.NewV4()))
 		if err != nil {
-			return nil, err
+			return nil, wrapError("Error creating VM", err)
 		}
-		defer c.DeleteKeyPair(kpTmp.ID)
+		defer c.unbound().DeleteKeyPair(kpTmp.ID)
 		kp = kpTmp
 	}
 	//If the VM is not a Gateway, get gateway of the first network
@@ -946,12 +1171,12 @@ func (c *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 	if !request.IsGateway {
 		net, err := c.getNetwork(request.NetworkIDs[0])
 		if err != nil {
-			return nil, err
+			return nil, wrapError("Error creating VM", err)
 		}
 		gwID = net.GatewayID
 		gw, err = c.GetVM(gwID)
 		if err != nil {
-			return nil, err
+			return nil, wrapError("Error creating VM", err)
 		}
 
 	}
@@ -961,7 +1186,7 @@ func (c *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 	//Prepare user data
 	userData, err := c.prepareUserData(request, kp, gw)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error creating VM", err)
 	}
 
 	//Create networks interfaces
@@ -1009,7 +1234,7 @@ func (c *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 		// },
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error creating VM", err)
 	}
 	instance := out.Instances[0]
 
@@ -1026,93 +1251,97 @@ func (c *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 		},
 	})
 	if err != nil {
-		c.DeleteVM(*instance.InstanceId)
-		return nil, err
+		c.unbound().DeleteVM(*instance.InstanceId)
+		return nil, wrapError("Error creating VM", err)
 	}
 
-	addr, err := c.EC2.AllocateAddress(&ec2.AllocateAddressInput{
-		Domain: aws.String("vpc"),
+	//The address is allocated even if the operation is cancelled, the response of EC2 would otherwise be lost with
+	//the allocation ID needed to release it
+	addr, err := c.unbound().EC2.AllocateAddress(&ec2.AllocateAddressInput{
+		Domain:            aws.String("vpc"),
+		TagSpecifications: createdTags(ec2.ResourceTypeElasticIp),
 	})
 	if err != nil {
-		c.DeleteVM(*instance.InstanceId)
-		return nil, err
+		c.unbound().DeleteVM(*instance.InstanceId)
+		return nil, wrapError("Error creating VM", err)
+	}
+	//rollback terminates the instance and releases the Elastic IP, it is only released with the instance once it
+	//is associated
+	rollback := func() {
+		c.unbound().DeleteVM(*instance.InstanceId)
+		c.unbound().EC2.ReleaseAddress(&ec2.ReleaseAddressInput{
+			AllocationId: addr.AllocationId,
+		})
 	}
 	//Wait that VM is started
 	service := providers.Service{
 		ClientAPI: c,
 	}
-	_, err = service.WaitVMState(*instance.InstanceId, VMState.STARTED, 120*time.Second)
+	started, err := service.WaitVMState(*instance.InstanceId, VMState.STARTED, 120*time.Second)
 	if err != nil {
-		return nil, err
+		rollback()
+		return nil, wrapError("Error creating VM", err)
 	}
 	_, err = c.EC2.AssociateAddress(&ec2.AssociateAddressInput{
 		NetworkInterfaceId: netIFs.NetworkInterfaces[0].NetworkInterfaceId,
 		AllocationId:       addr.AllocationId,
 	})
 	if err != nil {
-		c.DeleteVM(*instance.InstanceId)
-		return nil, err
+		rollback()
+		return nil, wrapError("Error creating VM", err)
 	}
 	//Create api.VM
 
-	tpl, err := c.GetTemplate(*instance.InstanceType)
+	tpl, err := c.instanceTemplate(*instance.InstanceType)
 	if err != nil {
-		c.DeleteVM(*instance.InstanceId)
-		return nil, err
+		rollback()
+		return nil, wrapError("Error creating VM", err)
 	}
 	v4IPs := []string{}
 	for _, nif := range instance.NetworkInterfaces {
 		v4IPs = append(v4IPs, *nif.PrivateIpAddress)
 	}
-	accessAddr := ""
-	if instance.PublicIpAddress != nil {
-		accessAddr = *instance.PublicIpAddress
-	}
-	state, err := getState(instance.State)
-	if err != nil {
-		c.DeleteVM(*instance.InstanceId)
-		return nil, err
-	}
-
+	//the description of the instance returned by RunInstances predates its start and the association of its address
 	vm := api.VM{
 		ID:           pStr(instance.InstanceId),
 		Name:         request.Name,
 		Size:         tpl.VMSize,
 		PrivateIPsV4: v4IPs,
-		AccessIPv4:   accessAddr,
+		AccessIPv4:   pStr(addr.PublicIp),
 		PrivateKey:   kp.PrivateKey,
-		State:        state,
+		State:        started.State,
 		GatewayID:    gwID,
 	}
-	c.saveVM(vm)
+	err = c.saveVM(vm)
+	if err != nil {
+		rollback()
+		return nil, wrapError("Error creating VM", err)
+	}
 	return &vm, nil
 }
 
-//GetVM returns the VM identified by id
-func (c *Client) GetVM(id string) (*api.VM, error) {
-
-	out, err := c.EC2.DescribeInstances(&ec2.DescribeInstancesInput{
-		InstanceIds: []*string{aws.String(id)},
-	})
-	if err != nil {
+//toVM merges the description of instance with the VM data stored in the gpac.aws.wms container
+func (c *Client) toVM(instance *ec2.Instance, tpl *api.VMTemplate) (*api.VM, error) {
+	vm, err := c.readVM(*instance.InstanceId)
+	if err != nil && api.KindOf(err) != api.ErrNotFound {
 		return nil, err
 	}
-	instance := out.Reservations[0].Instances[0]
-	vm, err := c.readVM(id)
 	if err != nil {
+		//The VM has not been created by gpac, its name is recovered from the Name tag if any
 		vm = &api.VM{
 			ID: *instance.InstanceId,
 		}
+		for _, tag := range instance.Tags {
+			if pStr(tag.Key) == "Name" {
+				vm.Name = pStr(tag.Value)
+			}
+		}
 	}
 
 	vm.State, err = getState(instance.State)
 	if err != nil {
 		return nil, err
 	}
-	tpl, err := c.GetTemplate(*instance.InstanceType)
-	if err != nil {
-		return nil, err
-	}
 	vm.Size = tpl.VMSize
 	v4IPs := []string{}
 	for _, nif := range instance.NetworkInterfaces {
@@ -1124,13 +1353,83 @@ func (c *Client) GetVM(id string) (*api.VM, error) {
 	}
 	vm.PrivateIPsV4 = v4IPs
 	vm.AccessIPv4 = accessAddr
+	return vm, nil
+}
+
+//GetVM returns the VM identified by id
+func (c *Client) GetVM(id string) (*api.VM, error) {
 
+	out, err := c.EC2.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		return nil, wrapError("Error getting VM", err)
+	}
+	if len(out.Reservations) == 0 || len(out.Reservations[0].Instances) == 0 {
+		return nil, api.NewError(api.ErrNotFound, nil, "VM %s does not exists", id)
+	}
+	instance := out.Reservations[0].Instances[0]
+	tpl, err := c.instanceTemplate(*instance.InstanceType)
+	if err != nil {
+		return nil, wrapError("Error getting VM", err)
+	}
+	vm, err := c.toVM(instance, tpl)
+	if err != nil {
+		return nil, wrapError("Error getting VM", err)
+	}
 	return vm, nil
 }
 
-//ListVMs lists available VMs
+//ListVMs lists available VMs, terminated instances are ignored
 func (c *Client) ListVMs() ([]api.VM, error) {
-	panic("Not Implemented")
+	input := ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			&ec2.Filter{
+				Name: aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{
+					"pending",
+					"running",
+					"shutting-down",
+					"stopping",
+					"stopped",
+				}),
+			},
+		},
+		MaxResults: aws.Int64(100),
+	}
+	//Templates are shared by instances of the same type
+	tpls := map[string]*api.VMTemplate{}
+	vms := []api.VM{}
+	var vmErr error
+	err := c.EC2.DescribeInstancesPages(&input,
+		func(out *ec2.DescribeInstancesOutput, lastPage bool) bool {
+			for _, r := range out.Reservations {
+				for _, instance := range r.Instances {
+					tpl, ok := tpls[*instance.InstanceType]
+					if !ok {
+						tpl, vmErr = c.instanceTemplate(*instance.InstanceType)
+						if vmErr != nil {
+							return false
+						}
+						tpls[*instance.InstanceType] = tpl
+					}
+					vm, err := c.toVM(instance, tpl)
+					if err != nil {
+						vmErr = err
+						return false
+					}
+					vms = append(vms, *vm)
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return nil, wrapError("Error listing vms", err)
+	}
+	if vmErr != nil {
+		return nil, wrapError("Error listing vms", vmErr)
+	}
+	return vms, nil
 }
 
 //DeleteVM deletes the VM identified by id
@@ -1144,8 +1443,13 @@ func (c *Client) DeleteVM(id string) error {
 			},
 		},
 	})
-	if err != nil {
+	if err == nil {
 		for _, ip := range ips.Addresses {
+			if ip.AssociationId != nil {
+				c.EC2.DisassociateAddress(&ec2.DisassociateAddressInput{
+					AssociationId: ip.AssociationId,
+				})
+			}
 			c.EC2.ReleaseAddress(&ec2.ReleaseAddressInput{
 				AllocationId: ip.AllocationId,
 			})
@@ -1154,7 +1458,7 @@ func (c *Client) DeleteVM(id string) error {
 	_, err = c.EC2.TerminateInstances(&ec2.TerminateInstancesInput{
 		InstanceIds: []*string{aws.String(id)},
 	})
-	return err
+	return wrapError("Error deleting VM", err)
 
 }
 
@@ -1164,7 +1468,7 @@ func (c *Client) StopVM(id string) error {
 		Force:       aws.Bool(true),
 		InstanceIds: []*string{aws.String(id)},
 	})
-	return err
+	return wrapError("Error stopping VM", err)
 }
 
 //StartVM starts the VM identified by id
@@ -1172,14 +1476,14 @@ func (c *Client) StartVM(id string) error {
 	_, err := c.EC2.StartInstances(&ec2.StartInstancesInput{
 		InstanceIds: []*string{aws.String(id)},
 	})
-	return err
+	return wrapError("Error starting VM", err)
 }
 
 //GetSSHConfig creates SSHConfig from VM
 func (c *Client) GetSSHConfig(vmID string) (*system.SSHConfig, error) {
 	vm, err := c.GetVM(vmID)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error getting SSH config", err)
 	}
 	ip := vm.GetAccessIP()
 	sshConfig := system.SSHConfig{
@@ -1191,7 +1495,7 @@ func (c *Client) GetSSHConfig(vmID string) (*system.SSHConfig, error) {
 	if vm.GatewayID != "" {
 		gw, err := c.GetVM(vm.GatewayID)
 		if err != nil {
-			return nil, err
+			return nil, wrapError("Error getting SSH config", err)
 		}
 		ip := gw.GetAccessIP()
 		GatewayConfig := system.SSHConfig{
@@ -1266,24 +1570,26 @@ func toVolumeState(s *string) VolumeState.Enum {
 }
 
 func (c *Client) saveVolumeName(id, name string) error {
-	return c.PutObject("gpac.aws.volumes", api.Object{
-		Name:    id,
-		Content: strings.NewReader(name),
-	})
+	b, err := providers.EncodeRecord(providers.RecordVolumeName, name)
+	if err != nil {
+		return err
+	}
+	_, err = c.metadata.Put(volumesBucket, id, b)
+	return err
 }
 
 func (c *Client) getVolumeName(id string) (string, error) {
-	obj, err := c.GetObject("gpac.aws.volumes", id, nil)
+	b, _, err := c.metadata.Get(volumesBucket, id)
 	if err != nil {
 		return "", err
 	}
-	buffer := bytes.Buffer{}
-	buffer.ReadFrom(obj.Content)
-	return buffer.String(), nil
+	name := ""
+	err = providers.DecodeRecord(providers.RecordVolumeName, b, &name)
+	return name, err
 }
 
 func (c *Client) removeVolumeName(id string) error {
-	return c.DeleteObject("gpac.aws.volumes", id)
+	return c.metadata.Delete(volumesBucket, id)
 }
 
 //CreateVolume creates a block volume
@@ -1291,81 +1597,128 @@ func (c *Client) removeVolumeName(id string) error {
 //- size is the size of the volume in GB
 //- volumeType is the type of volume to create, if volumeType is empty the driver use a default type
 func (c *Client) CreateVolume(request api.VolumeRequest) (*api.Volume, error) {
+	zone, err := c.availabilityZone()
+	if err != nil {
+		return nil, wrapError("Error creating volume", err)
+	}
 	v, err := c.EC2.CreateVolume(&ec2.CreateVolumeInput{
-		Size:       aws.Int64(int64(request.Size)),
-		VolumeType: aws.String(toVolumeType(request.Speed)),
+		AvailabilityZone:  aws.String(zone),
+		Size:              aws.Int64(int64(request.Size)),
+		VolumeType:        aws.String(toVolumeType(request.Speed)),
+		TagSpecifications: createdTags(ec2.ResourceTypeVolume),
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error creating volume", err)
 	}
 	err = c.saveVolumeName(*v.VolumeId, request.Name)
 	if err != nil {
-		c.DeleteVolume(*v.VolumeId)
+		c.unbound().DeleteVolume(*v.VolumeId)
+		return nil, wrapError("Error creating volume", err)
 	}
-	volume := api.Volume{
+	volume := toVolume(v, request.Name)
+	return &volume, nil
+}
+
+//toVolume converts an EC2 volume, name is its name
+func toVolume(v *ec2.Volume, name string) api.Volume {
+	return api.Volume{
 		ID:    pStr(v.VolumeId),
-		Name:  request.Name,
+		Name:  name,
 		Size:  int(pInt64(v.Size)),
 		Speed: toVolumeSpeed(v.VolumeType),
 		State: toVolumeState(v.State),
 	}
-	return &volume, nil
 }
 
 //GetVolume returns the volume identified by id
+//The name of a volume not created by gpac (e.g. the root volume of a VM) is recovered from its Name tag if any
 func (c *Client) GetVolume(id string) (*api.Volume, error) {
 	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{
 		VolumeIds: []*string{aws.String(id)},
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error getting volume", err)
+	}
+	if len(out.Volumes) == 0 {
+		return nil, api.NewError(api.ErrNotFound, nil, "Volume %s does not exists", id)
 	}
 	v := out.Volumes[0]
 	name, err := c.getVolumeName(id)
-	if err != nil {
-		return nil, err
+	if err != nil && api.KindOf(err) != api.ErrNotFound {
+		return nil, wrapError("Error getting volume", err)
 	}
-	volume := api.Volume{
-		ID:    pStr(v.VolumeId),
-		Name:  name,
-		Size:  int(pInt64(v.Size)),
-		Speed: toVolumeSpeed(v.VolumeType),
-		State: toVolumeState(v.State),
+	if err != nil {
+		for _, tag := range v.Tags {
+			if pStr(tag.Key) == "Name" {
+				name = pStr(tag.Value)
+			}
+		}
 	}
+	volume := toVolume(v, name)
 	return &volume, nil
 }
 
-//ListVolumes list available volumes
+//ListVolumes list the volumes created by gpac, the other volumes (e.g. the root volumes of the VMs) are not listed
 func (c *Client) ListVolumes() ([]api.Volume, error) {
 	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error listing volumes", err)
 	}
 	volumes := []api.Volume{}
 	for _, v := range out.Volumes {
 		name, err := c.getVolumeName(*v.VolumeId)
-		if err != nil {
-			return nil, err
+		if api.KindOf(err) == api.ErrNotFound {
+			continue
 		}
-		volume := api.Volume{
-			ID:    pStr(v.VolumeId),
-			Name:  name,
-			Size:  int(pInt64(v.Size)),
-			Speed: toVolumeSpeed(v.VolumeType),
-			State: toVolumeState(v.State),
+		if err != nil {
+			return nil, wrapError("Error listing volumes", err)
 		}
-		volumes = append(volumes, volume)
+		volumes = append(volumes, toVolume(v, name))
 	}
 
 	return volumes, nil
 }
 
-//DeleteVolume deletes the volume identified by id
+//DeleteVolume deletes the volume identified by id and its record
 func (c *Client) DeleteVolume(id string) error {
 	_, err := c.EC2.DeleteVolume(&ec2.DeleteVolumeInput{
 		VolumeId: aws.String(id),
 	})
-	return err
+	if err != nil {
+		return wrapError("Error deleting volume", err)
+	}
+	err = c.removeVolumeName(id)
+	if err != nil && api.KindOf(err) != api.ErrNotFound {
+		return wrapError("Error deleting volume", err)
+	}
+	return nil
+}
+
+//UpdateVolume renames, resizes or changes the speed of the volume identified by id
+//EC2 modifies the size and the type of a volume while it is in use, a volume can only be modified once every 6 hours
+func (c *Client) UpdateVolume(id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	if request.Size != nil || request.Speed != nil {
+		input := ec2.ModifyVolumeInput{
+			VolumeId: aws.String(id),
+		}
+		if request.Size != nil {
+			input.Size = aws.Int64(int64(*request.Size))
+		}
+		if request.Speed != nil {
+			input.VolumeType = aws.String(toVolumeType(*request.Speed))
+		}
+		_, err := c.EC2.ModifyVolume(&input)
+		if err != nil {
+			return nil, wrapError("Error updating volume", err)
+		}
+	}
+	if request.Name != nil {
+		err := c.saveVolumeName(id, *request.Name)
+		if err != nil {
+			return nil, wrapError("Error updating volume", err)
+		}
+	}
+	return c.GetVolume(id)
 }
 
 // func (c *Client) saveVolumeAttachmentName(id, name string) error {
@@ -1393,17 +1746,42 @@ func (c *Client) DeleteVolume(id string) error {
 // 	return fmt.Sprintf("%s###%s", vmID, volumeID)
 // }
 
+//freeDevice returns the first device name recommended for EBS volumes not used by the volumes attached to the VM
+//identified by serverID
+func (c *Client) freeDevice(serverID string) (string, error) {
+	vas, err := c.ListVolumeAttachments(serverID)
+	if err != nil {
+		return "", err
+	}
+	used := map[string]bool{}
+	for _, va := range vas {
+		used[va.Device] = true
+	}
+	for l := 'f'; l <= 'p'; l++ {
+		device := "/dev/sd" + string(l)
+		if !used[device] {
+			return device, nil
+		}
+	}
+	return "", api.NewError(api.ErrQuotaExceeded, nil, "No device name available on VM %s", serverID)
+}
+
 //CreateVolumeAttachment attaches a volume to a VM
 //- name the name of the volume attachment
 //- volume the volume to attach
 //- vm the VM on which the volume is attached
 func (c *Client) CreateVolumeAttachment(request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	device, err := c.freeDevice(request.ServerID)
+	if err != nil {
+		return nil, wrapError("Error creating volume attachment", err)
+	}
 	va, err := c.EC2.AttachVolume(&ec2.AttachVolumeInput{
+		Device:     aws.String(device),
 		InstanceId: aws.String(request.ServerID),
 		VolumeId:   aws.String(request.VolumeID),
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error creating volume attachment", err)
 	}
 	return &api.VolumeAttachment{
 		Device:   pStr(va.Device),
@@ -1420,19 +1798,20 @@ func (c *Client) GetVolumeAttachment(serverID, id string) (*api.VolumeAttachment
 		VolumeIds: []*string{aws.String(id)},
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error getting volume attachment", err)
 	}
 	v := out.Volumes[0]
 	for _, va := range v.Attachments {
 		if *va.InstanceId == serverID {
 			return &api.VolumeAttachment{
 				Device:   pStr(va.Device),
+				ID:       pStr(va.VolumeId),
 				ServerID: pStr(va.InstanceId),
 				VolumeID: pStr(va.VolumeId),
 			}, nil
 		}
 	}
-	return nil, fmt.Errorf("Volume attachment of volume %s on server %s does not exists", serverID, id)
+	return nil, api.NewError(api.ErrNotFound, nil, "Volume attachment of volume %s on server %s does not exists", serverID, id)
 }
 
 //ListVolumeAttachments lists available volume attachment
@@ -1446,13 +1825,14 @@ func (c *Client) ListVolumeAttachments(serverID string) ([]api.VolumeAttachment,
 		},
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error listing volume attachments", err)
 	}
 	vas := []api.VolumeAttachment{}
 	for _, v := range out.Volumes {
 		for _, va := range v.Attachments {
 			vas = append(vas, api.VolumeAttachment{
 				Device:   pStr(va.Device),
+				ID:       pStr(va.VolumeId),
 				ServerID: pStr(va.InstanceId),
 				VolumeID: pStr(va.VolumeId),
 			})
@@ -1468,7 +1848,7 @@ func (c *Client) DeleteVolumeAttachment(serverID, id string) error {
 		InstanceId: aws.String(serverID),
 		VolumeId:   aws.String(id),
 	})
-	return err
+	return wrapError("Error deleting volume attachment", err)
 }
 
 //CreateContainer creates an object container
@@ -1476,13 +1856,16 @@ func (c *Client) CreateContainer(name string) error {
 	svc := s3.New(c.Session)
 	input := &s3.CreateBucketInput{
 		Bucket: aws.String(name),
-		CreateBucketConfiguration: &s3.CreateBucketConfiguration{
+	}
+	//us-east-1 is the default location and is rejected as location constraint
+	if c.AuthOpts.Region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
 			LocationConstraint: aws.String(c.AuthOpts.Region),
-		},
+		}
 	}
 
 	_, err := svc.CreateBucket(input)
-	return err
+	return wrapError("Error creating container", err)
 }
 
 //DeleteContainer deletes an object container
@@ -1492,7 +1875,7 @@ func (c *Client) DeleteContainer(name string) error {
 		Bucket: aws.String(name),
 	}
 	_, err := svc.DeleteBucket(input)
-	return err
+	return wrapError("Error deleting container", err)
 }
 
 //ListContainers list object containers
@@ -1502,7 +1885,7 @@ func (c *Client) ListContainers() ([]string, error) {
 
 	result, err := svc.ListBuckets(input)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error listing containers", err)
 	}
 	buckets := []string{}
 	for _, b := range result.Buckets {
@@ -1511,6 +1894,20 @@ func (c *Client) ListContainers() ([]string, error) {
 	return buckets, nil
 }
 
+//S3QLBackend returns the s3ql storage URL of the bucket container and the credentials to access it
+func (c *Client) S3QLBackend(container string) (string, string, string, error) {
+	storageURL := fmt.Sprintf("s3://%s/%s", c.AuthOpts.Region, container)
+	if c.AuthOpts.Endpoint != "" {
+		//S3 compatible end point
+		u, err := url.Parse(c.AuthOpts.Endpoint)
+		if err != nil {
+			return "", "", "", fmt.Errorf("Error parsing endpoint: %s", err.Error())
+		}
+		storageURL = fmt.Sprintf("s3c://%s/%s", u.Host, container)
+	}
+	return storageURL, c.AuthOpts.AccessKeyID, c.AuthOpts.SecretAccessKey, nil
+}
+
 func createTagging(m map[string]string) string {
 	tags := []string{}
 	for k, v := range m {
@@ -1541,7 +1938,7 @@ func (c *Client) PutObject(container string, obj api.Object) error {
 			},
 		})
 		if err != nil {
-			return err
+			return wrapError("Error putting object", err)
 		}
 	}
 
@@ -1562,7 +1959,7 @@ func (c *Client) PutObject(container string, obj api.Object) error {
 
 	_, err := svc.PutObject(input)
 
-	return err
+	return wrapError("Error putting object", err)
 }
 
 //UpdateObjectMetadata update an object into  object container
@@ -1584,7 +1981,7 @@ func (c *Client) UpdateObjectMetadata(container string, obj api.Object) error {
 		},
 	}
 	_, err := svc.PutObjectTagging(input)
-	return err
+	return wrapError("Error updating object metadata", err)
 }
 
 func pStr(s *string) string {
@@ -1622,12 +2019,12 @@ func (c *Client) GetObject(container string, name string, ranges []api.Range) (*
 		Range:  aws.String(sRanges),
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error getting object", err)
 	}
 
 	obj, err := c.GetObjectMetadata(container, name)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error getting object", err)
 	}
 	return &api.Object{
 		Content:       aws.ReadSeekCloser(out.Body),
@@ -1691,9 +2088,9 @@ func (c *Client) ListObjects(container string, filter api.ObjectFilter) ([]strin
 		},
 	)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("Error listing objects", err)
 	}
-	return objs, err
+	return objs, wrapError("Error listing objects", err)
 
 }
 
@@ -1706,7 +2103,7 @@ func (c *Client) CopyObject(containerSrc, objectSrc, objectDst string) error {
 		Key:        aws.String(objectSrc),
 		CopySource: aws.String(src),
 	})
-	return err
+	return wrapError("Error copying object", err)
 }
 
 //DeleteObject deleta an object from a container
@@ -1716,5 +2113,5 @@ func (c *Client) DeleteObject(container, object string) error {
 		Bucket: aws.String(container),
 		Key:    aws.String(object),
 	})
-	return err
+	return wrapError("Error deleting object", err)
 }
@@ -0,0 +1,94 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestErrorKind(t *testing.T) {
+	cases := []struct {
+		code string
+		kind error
+	}{
+		{"InvalidInstanceID.NotFound", api.ErrNotFound},
+		{"NoSuchKey", api.ErrNotFound},
+		{"NoSuchBucket", api.ErrNotFound},
+		{"InvalidKeyPair.Duplicate", api.ErrAlreadyExists},
+		{"BucketAlreadyOwnedByYou", api.ErrAlreadyExists},
+		{"VcpuLimitExceeded", api.ErrQuotaExceeded},
+		{"AddressLimitExceeded", api.ErrQuotaExceeded},
+		{"VolumeLimitExceeded", api.ErrQuotaExceeded},
+		{"RequestLimitExceeded", api.ErrUnavailable},
+		{"Throttling", api.ErrUnavailable},
+		{"InsufficientInstanceCapacity", api.ErrUnavailable},
+		{"UnauthorizedOperation", api.ErrUnauthorized},
+		{"AuthFailure", api.ErrUnauthorized},
+		{"IncorrectInstanceState", api.ErrConflict},
+		{"DependencyViolation", api.ErrConflict},
+		{"VolumeInUse", api.ErrConflict},
+		{"RequestTimeout", api.ErrTimeout},
+		{"InvalidParameterValue", api.ErrInvalidRequest},
+		{"MissingParameter", api.ErrInvalidRequest},
+		{"SomethingElse", nil},
+	}
+	for _, c := range cases {
+		kind := errorKind(awserr.New(c.code, "message", nil))
+		if kind != c.kind {
+			t.Errorf("%s: expected kind %v, got %v", c.code, c.kind, kind)
+		}
+	}
+}
+
+func TestErrorKindStatus(t *testing.T) {
+	//unknown codes are classified by the HTTP status of the response
+	cases := map[int]error{
+		400: api.ErrInvalidRequest,
+		403: api.ErrUnauthorized,
+		404: api.ErrNotFound,
+		503: api.ErrUnavailable,
+		302: nil,
+	}
+	for status, expected := range cases {
+		kind := errorKind(awserr.NewRequestFailure(awserr.New("SomethingElse", "message", nil), status, "request-id"))
+		if kind != expected {
+			t.Errorf("%d: expected kind %v, got %v", status, expected, kind)
+		}
+	}
+}
+
+func TestWrapError(t *testing.T) {
+	if wrapError("Error", nil) != nil {
+		t.Fatal("nil error wrapped")
+	}
+	err := wrapError("Error creating VM", awserr.New("VcpuLimitExceeded", "too many vCPUs", nil))
+	var e *api.Error
+	if !errors.As(err, &e) || e.Code != "VcpuLimitExceeded" || e.Body != "too many vCPUs" {
+		t.Fatalf("expected the AWS code and message to be kept, got %#v", err)
+	}
+	if !errors.Is(err, api.ErrQuotaExceeded) {
+		t.Fatalf("expected a quota error, got %v", err)
+	}
+	//errors of the driver are not wrapped twice
+	if wrapError("Error", err) != err {
+		t.Fatal("error of the driver wrapped")
+	}
+	//an unknown code keeps the kind of the cause of the error
+	err = wrapError("Error listing VMs", awserr.New("RequestError", "send request failed", context.DeadlineExceeded))
+	if !errors.Is(err, api.ErrTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	err = wrapError("Error listing VMs", awserr.New("SomethingElse", "message", fmt.Errorf("cause")))
+	if !errors.As(err, &e) || e.Kind != nil {
+		t.Fatalf("expected an unclassified error, got %#v", err)
+	}
+	//errors that are not AWS errors are classified by KindOf
+	err = wrapError("Error listing VMs", context.DeadlineExceeded)
+	if !errors.Is(err, api.ErrTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/system"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/pricing"
+)
+
+//withContext returns a copy of the client whose requests, including the ones of its metadata store, are bound to ctx
+//The SDK stops sending and retrying a request, and stops waiting between retries, when ctx is done
+func (c *Client) withContext(ctx context.Context) *Client {
+	bind := func(r *request.Request) {
+		r.SetContext(ctx)
+	}
+	bound := func(cl *client.Client) *client.Client {
+		b := *cl
+		b.Handlers = cl.Handlers.Copy()
+		b.Handlers.Validate.PushFront(bind)
+		return &b
+	}
+	cc := *c
+	//S3 clients are created from the session by each call
+	cc.Session = c.Session.Copy()
+	cc.Session.Handlers.Validate.PushFront(bind)
+	cc.EC2 = &ec2.EC2{Client: bound(c.EC2.Client)}
+	cc.Pricing = &pricing.Pricing{Client: bound(c.Pricing.Client)}
+	cc.background = c.unbound()
+	if store, ok := c.metadata.(providers.ClientMetadataStore); ok {
+		cc.metadata = store.WithClient(&cc)
+	}
+	return &cc
+}
+
+//ListImagesContext lists available OS images, the requests are cancelled when ctx is done
+func (c *Client) ListImagesContext(ctx context.Context) ([]api.Image, error) {
+	return c.withContext(ctx).ListImages()
+}
+
+//GetImageContext returns the Image referenced by id, the requests are cancelled when ctx is done
+func (c *Client) GetImageContext(ctx context.Context, id string) (*api.Image, error) {
+	return c.withContext(ctx).GetImage(id)
+}
+
+//GetTemplateContext returns the Template referenced by id, the requests are cancelled when ctx is done
+func (c *Client) GetTemplateContext(ctx context.Context, id string) (*api.VMTemplate, error) {
+	return c.withContext(ctx).GetTemplate(id)
+}
+
+//ListTemplatesContext lists available VM templates, the requests are cancelled when ctx is done
+func (c *Client) ListTemplatesContext(ctx context.Context) ([]api.VMTemplate, error) {
+	return c.withContext(ctx).ListTemplates()
+}
+
+//CreateKeyPairContext creates and import a key pair, the requests are cancelled when ctx is done
+func (c *Client) CreateKeyPairContext(ctx context.Context, name string) (*api.KeyPair, error) {
+	return c.withContext(ctx).CreateKeyPair(name)
+}
+
+//GetKeyPairContext returns the key pair identified by id, the requests are cancelled when ctx is done
+func (c *Client) GetKeyPairContext(ctx context.Context, id string) (*api.KeyPair, error) {
+	return c.withContext(ctx).GetKeyPair(id)
+}
+
+//ListKeyPairsContext lists available key pairs, the requests are cancelled when ctx is done
+func (c *Client) ListKeyPairsContext(ctx context.Context) ([]api.KeyPair, error) {
+	return c.withContext(ctx).ListKeyPairs()
+}
+
+//DeleteKeyPairContext deletes the key pair identified by id, the requests are cancelled when ctx is done
+func (c *Client) DeleteKeyPairContext(ctx context.Context, id string) error {
+	return c.withContext(ctx).DeleteKeyPair(id)
+}
+
+//CreateNetworkContext creates a network named name, the requests are cancelled when ctx is done
+func (c *Client) CreateNetworkContext(ctx context.Context, req api.NetworkRequest) (*api.Network, error) {
+	return c.withContext(ctx).CreateNetwork(req)
+}
+
+//GetNetworkContext returns the network identified by id, the requests are cancelled when ctx is done
+func (c *Client) GetNetworkContext(ctx context.Context, id string) (*api.Network, error) {
+	return c.withContext(ctx).GetNetwork(id)
+}
+
+//ListNetworksContext lists available networks, the requests are cancelled when ctx is done
+func (c *Client) ListNetworksContext(ctx context.Context) ([]api.Network, error) {
+	return c.withContext(ctx).ListNetworks()
+}
+
+//DeleteNetworkContext deletes the network identified by id, the requests are cancelled when ctx is done
+func (c *Client) DeleteNetworkContext(ctx context.Context, id string) error {
+	return c.withContext(ctx).DeleteNetwork(id)
+}
+
+//CreateVMContext creates a VM that fulfils the request, the requests are cancelled when ctx is done
+func (c *Client) CreateVMContext(ctx context.Context, request api.VMRequest) (*api.VM, error) {
+	return c.withContext(ctx).CreateVM(request)
+}
+
+//GetVMContext returns the VM identified by id, the requests are cancelled when ctx is done
+func (c *Client) GetVMContext(ctx context.Context, id string) (*api.VM, error) {
+	return c.withContext(ctx).GetVM(id)
+}
+
+//ListVMsContext lists available VMs, the requests are cancelled when ctx is done
+func (c *Client) ListVMsContext(ctx context.Context) ([]api.VM, error) {
+	return c.withContext(ctx).ListVMs()
+}
+
+//DeleteVMContext deletes the VM identified by id, the requests are cancelled when ctx is done
+func (c *Client) DeleteVMContext(ctx context.Context, id string) error {
+	return c.withContext(ctx).DeleteVM(id)
+}
+
+//StopVMContext stops the VM identified by id, the requests are cancelled when ctx is done
+func (c *Client) StopVMContext(ctx context.Context, id string) error {
+	return c.withContext(ctx).StopVM(id)
+}
+
+//StartVMContext starts the VM identified by id, the requests are cancelled when ctx is done
+func (c *Client) StartVMContext(ctx context.Context, id string) error {
+	return c.withContext(ctx).StartVM(id)
+}
+
+//GetSSHConfigContext creates SSHConfig from VM, the requests are cancelled when ctx is done
+func (c *Client) GetSSHConfigContext(ctx context.Context, id string) (*system.SSHConfig, error) {
+	return c.withContext(ctx).GetSSHConfig(id)
+}
+
+//CreateVolumeContext creates a block volume, the requests are cancelled when ctx is done
+func (c *Client) CreateVolumeContext(ctx context.Context, request api.VolumeRequest) (*api.Volume, error) {
+	return c.withContext(ctx).CreateVolume(request)
+}
+
+//GetVolumeContext returns the volume identified by id, the requests are cancelled when ctx is done
+func (c *Client) GetVolumeContext(ctx context.Context, id string) (*api.Volume, error) {
+	return c.withContext(ctx).GetVolume(id)
+}
+
+//ListVolumesContext list available volumes, the requests are cancelled when ctx is done
+func (c *Client) ListVolumesContext(ctx context.Context) ([]api.Volume, error) {
+	return c.withContext(ctx).ListVolumes()
+}
+
+//DeleteVolumeContext deletes the volume identified by id, the requests are cancelled when ctx is done
+func (c *Client) DeleteVolumeContext(ctx context.Context, id string) error {
+	return c.withContext(ctx).DeleteVolume(id)
+}
+
+//UpdateVolumeContext renames, resizes or changes the speed of the volume identified by id, the requests are cancelled
+//when ctx is done
+func (c *Client) UpdateVolumeContext(ctx context.Context, id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	return c.withContext(ctx).UpdateVolume(id, request)
+}
+
+//CreateVolumeAttachmentContext attaches a volume to a VM, the requests are cancelled when ctx is done
+func (c *Client) CreateVolumeAttachmentContext(ctx context.Context, request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	return c.withContext(ctx).CreateVolumeAttachment(request)
+}
+
+//GetVolumeAttachmentContext returns the volume attachment identified by id, the requests are cancelled when ctx is done
+func (c *Client) GetVolumeAttachmentContext(ctx context.Context, serverID string, id string) (*api.VolumeAttachment, error) {
+	return c.withContext(ctx).GetVolumeAttachment(serverID, id)
+}
+
+//ListVolumeAttachmentsContext lists available volume attachment, the requests are cancelled when ctx is done
+func (c *Client) ListVolumeAttachmentsContext(ctx context.Context, serverID string) ([]api.VolumeAttachment, error) {
+	return c.withContext(ctx).ListVolumeAttachments(serverID)
+}
+
+//DeleteVolumeAttachmentContext deletes the volume attachment identifed by id, the requests are cancelled when ctx is done
+func (c *Client) DeleteVolumeAttachmentContext(ctx context.Context, serverID string, id string) error {
+	return c.withContext(ctx).DeleteVolumeAttachment(serverID, id)
+}
+
+//CreateContainerContext creates an object container, the requests are cancelled when ctx is done
+func (c *Client) CreateContainerContext(ctx context.Context, name string) error {
+	return c.withContext(ctx).CreateContainer(name)
+}
+
+//DeleteContainerContext deletes an object container, the requests are cancelled when ctx is done
+func (c *Client) DeleteContainerContext(ctx context.Context, name string) error {
+	return c.withContext(ctx).DeleteContainer(name)
+}
+
+//ListContainersContext list object containers, the requests are cancelled when ctx is done
+func (c *Client) ListContainersContext(ctx context.Context) ([]string, error) {
+	return c.withContext(ctx).ListContainers()
+}
+
+//PutObjectContext put an object into an object container, the requests are cancelled when ctx is done
+func (c *Client) PutObjectContext(ctx context.Context, container string, obj api.Object) error {
+	return c.withContext(ctx).PutObject(container, obj)
+}
+
+//UpdateObjectMetadataContext update an object into  object container, the requests are cancelled when ctx is done
+func (c *Client) UpdateObjectMetadataContext(ctx context.Context, container string, obj api.Object) error {
+	return c.withContext(ctx).UpdateObjectMetadata(container, obj)
+}
+
+//GetObjectContext get  object content from an object container, the requests are cancelled when ctx is done
+func (c *Client) GetObjectContext(ctx context.Context, container string, name string, ranges []api.Range) (*api.Object, error) {
+	return c.withContext(ctx).GetObject(container, name, ranges)
+}
+
+//GetObjectMetadataContext get  object metadata from an object container, the requests are cancelled when ctx is done
+func (c *Client) GetObjectMetadataContext(ctx context.Context, container string, name string) (*api.Object, error) {
+	return c.withContext(ctx).GetObjectMetadata(container, name)
+}
+
+//ListObjectsContext list objects of a container, the requests are cancelled when ctx is done
+func (c *Client) ListObjectsContext(ctx context.Context, container string, filter api.ObjectFilter) ([]string, error) {
+	return c.withContext(ctx).ListObjects(container, filter)
+}
+
+//CopyObjectContext copies an object, the requests are cancelled when ctx is done
+func (c *Client) CopyObjectContext(ctx context.Context, containerSrc string, objectSrc string, objectDst string) error {
+	return c.withContext(ctx).CopyObject(containerSrc, objectSrc, objectDst)
+}
+
+//DeleteObjectContext deleta an object from a container, the requests are cancelled when ctx is done
+func (c *Client) DeleteObjectContext(ctx context.Context, container string, object string) error {
+	return c.withContext(ctx).DeleteObject(container, object)
+}
@@ -0,0 +1,788 @@
+package fake
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//Instance states
+const (
+	statePending      = "pending"
+	stateRunning      = "running"
+	stateShuttingDown = "shutting-down"
+	stateTerminated   = "terminated"
+	stateStopping     = "stopping"
+	stateStopped      = "stopped"
+)
+
+//stateCodes codes of instance states
+var stateCodes = map[string]int{
+	statePending:      0,
+	stateRunning:      16,
+	stateShuttingDown: 32,
+	stateTerminated:   48,
+	stateStopping:     64,
+	stateStopped:      80,
+}
+
+type instance struct {
+	ID            string
+	ReservationID string
+	ImageID       string
+	InstanceType  string
+	KeyName       string
+	UserData      string
+	LaunchTime    time.Time
+	State         string
+	//Interfaces network interfaces of the instance ordered by device index
+	Interfaces []string
+	pending    *transition
+}
+
+type networkInterface struct {
+	ID          string
+	SubnetID    string
+	VpcID       string
+	PrivateIP   string
+	InstanceID  string
+	DeviceIndex int
+	//DeleteOnTermination if true the interface is deleted with its instance
+	DeleteOnTermination bool
+}
+
+type volumeAttachment struct {
+	InstanceID string
+	Device     string
+	AttachTime time.Time
+}
+
+type volume struct {
+	ID               string
+	Size             int
+	VolumeType       string
+	AvailabilityZone string
+	CreateTime       time.Time
+	State            string
+	Attachment       *volumeAttachment
+	Tags             map[string]string
+	pending          *transition
+}
+
+//refreshInstance applies the pending state change of an instance
+func (srv *Server) refreshInstance(i *instance) {
+	if !i.pending.done() {
+		return
+	}
+	i.State = i.pending.target
+	i.pending = nil
+	if i.State == stateTerminated {
+		srv.release(i)
+	}
+}
+
+//zoneOf returns the availability zone of an instance, the zone of the subnet of its first network interface
+func (srv *Server) zoneOf(i *instance) string {
+	if len(i.Interfaces) > 0 {
+		if ni, ok := srv.interfaces[i.Interfaces[0]]; ok {
+			if sn, ok := srv.subnets[ni.SubnetID]; ok {
+				return sn.AvailabilityZone
+			}
+		}
+	}
+	return srv.Opts.Region + "a"
+}
+
+//release releases the resources of a terminated instance
+func (srv *Server) release(i *instance) {
+	for _, niID := range i.Interfaces {
+		ni := srv.interfaces[niID]
+		if addr := srv.addressOf(niID); addr != nil {
+			addr.InterfaceID = ""
+			addr.AssociationID = ""
+		}
+		if ni.DeleteOnTermination {
+			delete(srv.interfaces, niID)
+		} else {
+			ni.InstanceID = ""
+		}
+	}
+	for _, v := range srv.volumes {
+		if v.Attachment != nil && v.Attachment.InstanceID == i.ID {
+			v.Attachment = nil
+			v.State = "available"
+		}
+	}
+}
+
+//refresh applies the pending state change of a volume
+func (v *volume) refresh() {
+	if !v.pending.done() {
+		return
+	}
+	v.State = v.pending.target
+	v.pending = nil
+}
+
+func stateNode(name string, state string) node {
+	return el(name,
+		txt("code", strconv.Itoa(stateCodes[state])),
+		txt("name", state),
+	)
+}
+
+func (srv *Server) instanceNode(i *instance) node {
+	n := item(
+		txt("instanceId", i.ID),
+		txt("imageId", i.ImageID),
+		stateNode("instanceState", i.State),
+		txt("privateDnsName", ""),
+		txt("dnsName", ""),
+		txt("keyName", i.KeyName),
+		txt("amiLaunchIndex", "0"),
+		txt("instanceType", i.InstanceType),
+		txt("launchTime", timeText(i.LaunchTime)),
+		el("placement",
+			txt("availabilityZone", srv.zoneOf(i)),
+			txt("tenancy", "default"),
+		),
+		el("monitoring", txt("state", "disabled")),
+		txt("architecture", "x86_64"),
+		txt("rootDeviceType", "ebs"),
+		txt("rootDeviceName", "/dev/sda1"),
+		txt("virtualizationType", "hvm"),
+		txt("hypervisor", "xen"),
+	)
+	var interfaces []node
+	for _, niID := range i.Interfaces {
+		ni, ok := srv.interfaces[niID]
+		if !ok {
+			continue
+		}
+		if ni.DeviceIndex == 0 {
+			n.Children = append(n.Children,
+				txt("subnetId", ni.SubnetID),
+				txt("vpcId", ni.VpcID),
+				txt("privateIpAddress", ni.PrivateIP),
+			)
+			if addr := srv.addressOf(ni.ID); addr != nil {
+				n.Children = append(n.Children, txt("ipAddress", addr.PublicIP))
+			}
+		}
+		interfaces = append(interfaces, srv.interfaceNode(ni, true))
+	}
+	n.Children = append(n.Children, el("networkInterfaceSet", interfaces...))
+	return n
+}
+
+func (srv *Server) interfaceNode(ni *networkInterface, short bool) node {
+	n := item(
+		txt("networkInterfaceId", ni.ID),
+		txt("subnetId", ni.SubnetID),
+		txt("vpcId", ni.VpcID),
+		txt("ownerId", ownerID),
+		txt("status", "in-use"),
+		txt("privateIpAddress", ni.PrivateIP),
+		txt("sourceDestCheck", "true"),
+	)
+	if !short {
+		sn := srv.subnets[ni.SubnetID]
+		n.Children = append(n.Children,
+			txt("availabilityZone", sn.AvailabilityZone),
+			txt("interfaceType", "interface"),
+			txt("requesterManaged", "false"),
+		)
+	}
+	if ni.InstanceID != "" {
+		attachment := el("attachment",
+			txt("attachmentId", "eni-attach-"+ni.ID[4:]),
+			txt("deviceIndex", strconv.Itoa(ni.DeviceIndex)),
+			txt("status", "attached"),
+			txt("deleteOnTermination", boolText(ni.DeleteOnTermination)),
+		)
+		if !short {
+			attachment.Children = append(attachment.Children,
+				txt("instanceId", ni.InstanceID),
+				txt("instanceOwnerId", ownerID),
+			)
+		}
+		n.Children = append(n.Children, attachment)
+	}
+	if addr := srv.addressOf(ni.ID); addr != nil {
+		n.Children = append(n.Children, el("association",
+			txt("publicIp", addr.PublicIP),
+			txt("ipOwnerId", ownerID),
+			txt("allocationId", addr.AllocationID),
+			txt("associationId", addr.AssociationID),
+		))
+	}
+	return n
+}
+
+//interfaceRequest a network interface requested by RunInstances
+type interfaceRequest struct {
+	subnet              *subnet
+	deviceIndex         int
+	deleteOnTermination bool
+}
+
+//interfaceRequests returns the network interfaces requested by RunInstances
+func (srv *Server) interfaceRequests(form url.Values) ([]interfaceRequest, *awsError) {
+	var reqs []interfaceRequest
+	if snID := form.Get("SubnetId"); snID != "" {
+		sn, ok := srv.subnets[snID]
+		if !ok {
+			return nil, newError(http.StatusBadRequest, "InvalidSubnetID.NotFound", "The subnet ID '%s' does not exist", snID)
+		}
+		reqs = append(reqs, interfaceRequest{subnet: sn, deleteOnTermination: true})
+	}
+	for i := 1; ; i++ {
+		prefix := "NetworkInterface." + strconv.Itoa(i)
+		snID := form.Get(prefix + ".SubnetId")
+		if snID == "" {
+			break
+		}
+		if form.Get("SubnetId") != "" {
+			return nil, newError(http.StatusBadRequest, "InvalidParameterCombination", "Network interfaces and an instance-level subnet ID may not be specified on the same request")
+		}
+		sn, ok := srv.subnets[snID]
+		if !ok {
+			return nil, newError(http.StatusBadRequest, "InvalidSubnetID.NotFound", "The subnet ID '%s' does not exist", snID)
+		}
+		index, e := strconv.Atoi(form.Get(prefix + ".DeviceIndex"))
+		if e != nil {
+			return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Each network interface requires a device index.")
+		}
+		for _, r := range reqs {
+			if r.deviceIndex == index {
+				return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Each network interface requires a unique device index.")
+			}
+			if r.subnet.VpcID != sn.VpcID {
+				return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Network interfaces must be in the same VPC.")
+			}
+		}
+		reqs = append(reqs, interfaceRequest{
+			subnet:              sn,
+			deviceIndex:         index,
+			deleteOnTermination: form.Get(prefix+".DeleteOnTermination") == "true",
+		})
+	}
+	if len(reqs) == 0 {
+		return nil, newError(http.StatusBadRequest, "VPCIdNotSpecified", "No default VPC for this user")
+	}
+	sort.Slice(reqs, func(i, j int) bool {
+		return reqs[i].deviceIndex < reqs[j].deviceIndex
+	})
+	if reqs[0].deviceIndex != 0 {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "When specifying network interfaces, you must include a device at index 0.")
+	}
+	return reqs, nil
+}
+
+func (srv *Server) runInstances(form url.Values) ([]node, *awsError) {
+	imageID, err := required(form, "ImageId")
+	if err != nil {
+		return nil, err
+	}
+	if srv.findImage(imageID) == nil {
+		return nil, newError(http.StatusBadRequest, "InvalidAMIID.NotFound", "The image id '[%s]' does not exist", imageID)
+	}
+	instanceType := form.Get("InstanceType")
+	if instanceType == "" {
+		instanceType = "m1.small"
+	}
+	t := srv.findInstanceType(instanceType)
+	if t == nil {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Invalid value '%s' for InstanceType.", instanceType)
+	}
+	if !t.offered(srv.Opts.Region) {
+		return nil, newError(http.StatusBadRequest, "Unsupported", "The requested configuration is currently not supported. Please check the documentation for supported configurations.")
+	}
+	minCount, e := strconv.Atoi(form.Get("MinCount"))
+	if e != nil || minCount < 1 {
+		return nil, newError(http.StatusBadRequest, "MissingParameter", "The request must contain the parameter MinCount")
+	}
+	maxCount, e := strconv.Atoi(form.Get("MaxCount"))
+	if e != nil || maxCount < minCount {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Invalid value for MaxCount")
+	}
+	keyName := form.Get("KeyName")
+	if _, ok := srv.keyPairs[keyName]; keyName != "" && !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidKeyPair.NotFound", "The key pair '%s' does not exist", keyName)
+	}
+	userData := form.Get("UserData")
+	if _, e := base64.StdEncoding.DecodeString(userData); e != nil {
+		return nil, newError(http.StatusBadRequest, "InvalidUserData.Malformed", "Invalid BASE64 encoding of user data.")
+	}
+	reqs, err := srv.interfaceRequests(form)
+	if err != nil {
+		return nil, err
+	}
+	if maxCount > 1 && len(reqs) > 1 {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterCombination", "Multiple network interfaces can only be specified when launching a single instance.")
+	}
+	reservationID := newID("r")
+	var items []node
+	for n := 0; n < maxCount; n++ {
+		i := instance{
+			ID:            newID("i"),
+			ReservationID: reservationID,
+			ImageID:       imageID,
+			InstanceType:  instanceType,
+			KeyName:       keyName,
+			UserData:      userData,
+			LaunchTime:    time.Now(),
+			State:         statePending,
+			pending:       srv.schedule(stateRunning),
+		}
+		for _, r := range reqs {
+			ip, e := r.subnet.allocateIP()
+			if e != nil {
+				return nil, newError(http.StatusBadRequest, "InsufficientFreeAddressesInSubnet", "There are not enough free addresses in subnet '%s' to satisfy the requested number of instances.", r.subnet.ID)
+			}
+			ni := networkInterface{
+				ID:                  newID("eni"),
+				SubnetID:            r.subnet.ID,
+				VpcID:               r.subnet.VpcID,
+				PrivateIP:           ip,
+				InstanceID:          i.ID,
+				DeviceIndex:         r.deviceIndex,
+				DeleteOnTermination: r.deleteOnTermination,
+			}
+			srv.interfaces[ni.ID] = &ni
+			i.Interfaces = append(i.Interfaces, ni.ID)
+		}
+		srv.instances[i.ID] = &i
+		items = append(items, srv.instanceNode(&i))
+	}
+	return []node{
+		txt("reservationId", reservationID),
+		txt("ownerId", ownerID),
+		el("groupSet"),
+		el("instancesSet", items...),
+	}, nil
+}
+
+func (srv *Server) describeInstances(form url.Values) ([]node, *awsError) {
+	ids := list(form, "InstanceId")
+	exists := func(id string) bool {
+		_, ok := srv.instances[id]
+		return ok
+	}
+	if err := checkIDs(ids, exists, "InvalidInstanceID.NotFound", "instance ID"); err != nil {
+		return nil, err
+	}
+	fs := filters(form)
+	var selection []*instance
+	for _, i := range srv.instances {
+		attrs := map[string][]string{
+			"instance-id":         {i.ID},
+			"image-id":            {i.ImageID},
+			"instance-type":       {i.InstanceType},
+			"key-name":            {i.KeyName},
+			"reservation-id":      {i.ReservationID},
+			"instance-state-name": {i.State},
+			"instance-state-code": {strconv.Itoa(stateCodes[i.State])},
+		}
+		for _, niID := range i.Interfaces {
+			if ni, ok := srv.interfaces[niID]; ok {
+				attrs["vpc-id"] = append(attrs["vpc-id"], ni.VpcID)
+				attrs["subnet-id"] = append(attrs["subnet-id"], ni.SubnetID)
+				attrs["private-ip-address"] = append(attrs["private-ip-address"], ni.PrivateIP)
+				attrs["network-interface.network-interface-id"] = append(attrs["network-interface.network-interface-id"], ni.ID)
+				if addr := srv.addressOf(niID); addr != nil {
+					attrs["ip-address"] = append(attrs["ip-address"], addr.PublicIP)
+				}
+			}
+		}
+		if selected(ids, i.ID) && match(fs, attrs) {
+			selection = append(selection, i)
+		}
+	}
+	sort.Slice(selection, func(a, b int) bool {
+		if !selection[a].LaunchTime.Equal(selection[b].LaunchTime) {
+			return selection[a].LaunchTime.Before(selection[b].LaunchTime)
+		}
+		return selection[a].ID < selection[b].ID
+	})
+
+	if form.Get("MaxResults") != "" && len(ids) > 0 {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterCombination", "The parameter instancesSet cannot be used with the parameter maxResults")
+	}
+	start, end, err := page(form, len(selection))
+	if err != nil {
+		return nil, err
+	}
+
+	//Instances are grouped by reservation
+	var reservations []node
+	index := map[string]int{}
+	for _, i := range selection[start:end] {
+		k, ok := index[i.ReservationID]
+		if !ok {
+			k = len(reservations)
+			index[i.ReservationID] = k
+			reservations = append(reservations, item(
+				txt("reservationId", i.ReservationID),
+				txt("ownerId", ownerID),
+				el("groupSet"),
+				el("instancesSet"),
+			))
+		}
+		set := &reservations[k].Children[3]
+		set.Children = append(set.Children, srv.instanceNode(i))
+	}
+	nodes := []node{el("reservationSet", reservations...)}
+	if end < len(selection) {
+		nodes = append(nodes, txt("nextToken", strconv.Itoa(end)))
+	}
+	return nodes, nil
+}
+
+//changeStates applies a state change to the instances of the request
+//change returns the new state and the state targeted once the change is completed
+func (srv *Server) changeStates(form url.Values, change func(i *instance) (string, string, *awsError)) ([]node, *awsError) {
+	ids := list(form, "InstanceId")
+	if len(ids) == 0 {
+		return nil, newError(http.StatusBadRequest, "MissingParameter", "The request must contain the parameter InstanceId")
+	}
+	exists := func(id string) bool {
+		_, ok := srv.instances[id]
+		return ok
+	}
+	if err := checkIDs(ids, exists, "InvalidInstanceID.NotFound", "instance ID"); err != nil {
+		return nil, err
+	}
+	var items []node
+	for _, id := range ids {
+		i := srv.instances[id]
+		previous := i.State
+		state, target, err := change(i)
+		if err != nil {
+			return nil, err
+		}
+		if state != previous {
+			i.State = state
+			i.pending = srv.schedule(target)
+		}
+		items = append(items, item(
+			txt("instanceId", i.ID),
+			stateNode("currentState", state),
+			stateNode("previousState", previous),
+		))
+	}
+	return []node{el("instancesSet", items...)}, nil
+}
+
+func (srv *Server) stopInstances(form url.Values) ([]node, *awsError) {
+	return srv.changeStates(form, func(i *instance) (string, string, *awsError) {
+		switch i.State {
+		case stateRunning:
+			return stateStopping, stateStopped, nil
+		case stateStopping, stateStopped:
+			return i.State, i.State, nil
+		}
+		return "", "", newError(http.StatusBadRequest, "IncorrectInstanceState", "This instance '%s' is not in a state from which it can be stopped.", i.ID)
+	})
+}
+
+func (srv *Server) startInstances(form url.Values) ([]node, *awsError) {
+	return srv.changeStates(form, func(i *instance) (string, string, *awsError) {
+		switch i.State {
+		case stateStopped:
+			return statePending, stateRunning, nil
+		case statePending, stateRunning:
+			return i.State, i.State, nil
+		}
+		return "", "", newError(http.StatusBadRequest, "IncorrectInstanceState", "The instance '%s' is not in a state from which it can be started.", i.ID)
+	})
+}
+
+func (srv *Server) terminateInstances(form url.Values) ([]node, *awsError) {
+	return srv.changeStates(form, func(i *instance) (string, string, *awsError) {
+		switch i.State {
+		case stateShuttingDown, stateTerminated:
+			return i.State, i.State, nil
+		}
+		return stateShuttingDown, stateTerminated, nil
+	})
+}
+
+func (srv *Server) describeNetworkInterfaces(form url.Values) ([]node, *awsError) {
+	ids := list(form, "NetworkInterfaceId")
+	exists := func(id string) bool {
+		_, ok := srv.interfaces[id]
+		return ok
+	}
+	if err := checkIDs(ids, exists, "InvalidNetworkInterfaceID.NotFound", "networkInterface ID"); err != nil {
+		return nil, err
+	}
+	fs := filters(form)
+	var keys []string
+	for id := range srv.interfaces {
+		keys = append(keys, id)
+	}
+	var items []node
+	for _, id := range sortedKeys(keys) {
+		ni := srv.interfaces[id]
+		attrs := map[string][]string{
+			"network-interface-id": {ni.ID},
+			"subnet-id":            {ni.SubnetID},
+			"vpc-id":               {ni.VpcID},
+			"private-ip-address":   {ni.PrivateIP},
+		}
+		if ni.InstanceID != "" {
+			attrs["attachment.instance-id"] = []string{ni.InstanceID}
+			attrs["attachment.device-index"] = []string{strconv.Itoa(ni.DeviceIndex)}
+		}
+		if selected(ids, id) && match(fs, attrs) {
+			items = append(items, srv.interfaceNode(ni, false))
+		}
+	}
+	return []node{el("networkInterfaceSet", items...)}, nil
+}
+
+func volumeNode(name string, v *volume) node {
+	var attachments []node
+	if v.Attachment != nil {
+		attachments = append(attachments, item(
+			txt("volumeId", v.ID),
+			txt("instanceId", v.Attachment.InstanceID),
+			txt("device", v.Attachment.Device),
+			txt("status", "attached"),
+			txt("attachTime", timeText(v.Attachment.AttachTime)),
+			txt("deleteOnTermination", "false"),
+		))
+	}
+	return el(name,
+		txt("volumeId", v.ID),
+		txt("size", strconv.Itoa(v.Size)),
+		txt("snapshotId", ""),
+		txt("availabilityZone", v.AvailabilityZone),
+		txt("status", v.State),
+		txt("createTime", timeText(v.CreateTime)),
+		el("attachmentSet", attachments...),
+		txt("volumeType", v.VolumeType),
+		txt("encrypted", "false"),
+		tagSet(v.Tags),
+	)
+}
+
+//volumeSizes minimum and maximum sizes in GiB of volume types
+var volumeSizes = map[string][2]int{
+	"standard": {1, 1024},
+	"gp2":      {1, 16384},
+	"io1":      {4, 16384},
+	"st1":      {125, 16384},
+	"sc1":      {125, 16384},
+}
+
+func (srv *Server) createVolume(form url.Values) ([]node, *awsError) {
+	az, err := required(form, "AvailabilityZone")
+	if err != nil {
+		return nil, err
+	}
+	if !selected(srv.zones(), az) {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Invalid availability zone: [%s]", az)
+	}
+	volumeType := form.Get("VolumeType")
+	if volumeType == "" {
+		volumeType = "standard"
+	}
+	sizes, ok := volumeSizes[volumeType]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Value (%s) for parameter volumeType is invalid.", volumeType)
+	}
+	sizeStr, err := required(form, "Size")
+	if err != nil {
+		return nil, err
+	}
+	size, e := strconv.Atoi(sizeStr)
+	if e != nil || size < sizes[0] || size > sizes[1] {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Volume of %s GiB is too small or too large for volume type %s; minimum is %d, maximum is %d", sizeStr, volumeType, sizes[0], sizes[1])
+	}
+	tags, err := tagSpecifications(form, "volume")
+	if err != nil {
+		return nil, err
+	}
+	v := volume{
+		ID:               newID("vol"),
+		Tags:             tags,
+		Size:             size,
+		VolumeType:       volumeType,
+		AvailabilityZone: az,
+		CreateTime:       time.Now(),
+		State:            "creating",
+		pending:          srv.schedule("available"),
+	}
+	srv.volumes[v.ID] = &v
+	nodes := volumeNode("", &v).Children
+	return nodes, nil
+}
+
+func (srv *Server) describeVolumes(form url.Values) ([]node, *awsError) {
+	ids := list(form, "VolumeId")
+	exists := func(id string) bool {
+		_, ok := srv.volumes[id]
+		return ok
+	}
+	if err := checkIDs(ids, exists, "InvalidVolume.NotFound", "volume"); err != nil {
+		return nil, err
+	}
+	fs := filters(form)
+	var keys []string
+	for id := range srv.volumes {
+		keys = append(keys, id)
+	}
+	var items []node
+	for _, id := range sortedKeys(keys) {
+		v := srv.volumes[id]
+		attrs := map[string][]string{
+			"volume-id":         {v.ID},
+			"volume-type":       {v.VolumeType},
+			"size":              {strconv.Itoa(v.Size)},
+			"status":            {v.State},
+			"availability-zone": {v.AvailabilityZone},
+		}
+		if v.Attachment != nil {
+			attrs["attachment.instance-id"] = []string{v.Attachment.InstanceID}
+			attrs["attachment.device"] = []string{v.Attachment.Device}
+			attrs["attachment.status"] = []string{"attached"}
+		}
+		if selected(ids, id) && match(fs, attrs) {
+			items = append(items, volumeNode("item", v))
+		}
+	}
+	return []node{el("volumeSet", items...)}, nil
+}
+
+func (srv *Server) deleteVolume(form url.Values) ([]node, *awsError) {
+	id, err := required(form, "VolumeId")
+	if err != nil {
+		return nil, err
+	}
+	v, ok := srv.volumes[id]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidVolume.NotFound", "The volume '%s' does not exist.", id)
+	}
+	if v.Attachment != nil {
+		return nil, newError(http.StatusBadRequest, "VolumeInUse", "Volume %s is currently attached to %s", id, v.Attachment.InstanceID)
+	}
+	delete(srv.volumes, id)
+	return returnTrue(), nil
+}
+
+//modifyVolume completes the modifications immediately, EC2 optimizes the volume in the background
+func (srv *Server) modifyVolume(form url.Values) ([]node, *awsError) {
+	id, err := required(form, "VolumeId")
+	if err != nil {
+		return nil, err
+	}
+	v, ok := srv.volumes[id]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidVolume.NotFound", "The volume '%s' does not exist.", id)
+	}
+	volumeType := v.VolumeType
+	if t := form.Get("VolumeType"); t != "" {
+		volumeType = t
+	}
+	sizes, ok := volumeSizes[volumeType]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Value (%s) for parameter volumeType is invalid.", volumeType)
+	}
+	size := v.Size
+	if s := form.Get("Size"); s != "" {
+		size, _ = strconv.Atoi(s)
+	}
+	if size < v.Size {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "New size cannot be smaller than existing size")
+	}
+	if size < sizes[0] || size > sizes[1] {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Volume of %d GiB is too small or too large for volume type %s; minimum is %d, maximum is %d", size, volumeType, sizes[0], sizes[1])
+	}
+	modification := el("volumeModification",
+		txt("volumeId", v.ID),
+		txt("modificationState", "completed"),
+		txt("originalSize", strconv.Itoa(v.Size)),
+		txt("originalVolumeType", v.VolumeType),
+		txt("targetSize", strconv.Itoa(size)),
+		txt("targetVolumeType", volumeType),
+		txt("progress", "100"),
+	)
+	v.Size = size
+	v.VolumeType = volumeType
+	return []node{modification}, nil
+}
+
+func attachmentNodes(v *volume, status string) []node {
+	return []node{
+		txt("volumeId", v.ID),
+		txt("instanceId", v.Attachment.InstanceID),
+		txt("device", v.Attachment.Device),
+		txt("status", status),
+		txt("attachTime", timeText(v.Attachment.AttachTime)),
+	}
+}
+
+func (srv *Server) attachVolume(form url.Values) ([]node, *awsError) {
+	volumeID, err := required(form, "VolumeId")
+	if err != nil {
+		return nil, err
+	}
+	instanceID, err := required(form, "InstanceId")
+	if err != nil {
+		return nil, err
+	}
+	device, err := required(form, "Device")
+	if err != nil {
+		return nil, err
+	}
+	v, ok := srv.volumes[volumeID]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidVolume.NotFound", "The volume '%s' does not exist.", volumeID)
+	}
+	i, ok := srv.instances[instanceID]
+	if !ok || i.State == stateTerminated {
+		return nil, newError(http.StatusBadRequest, "InvalidInstanceID.NotFound", "The instance ID '%s' does not exist", instanceID)
+	}
+	if v.State != "available" {
+		return nil, newError(http.StatusBadRequest, "IncorrectState", "vol '%s' is not 'available'.", volumeID)
+	}
+	if v.AvailabilityZone != srv.zoneOf(i) {
+		return nil, newError(http.StatusBadRequest, "InvalidVolume.ZoneMismatch", "The volume '%s' is not in the same availability zone as instance '%s'", volumeID, instanceID)
+	}
+	for _, other := range srv.volumes {
+		if other.Attachment != nil && other.Attachment.InstanceID == instanceID && other.Attachment.Device == device {
+			return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Attachment point %s is already in use", device)
+		}
+	}
+	v.State = "in-use"
+	v.Attachment = &volumeAttachment{
+		InstanceID: instanceID,
+		Device:     device,
+		AttachTime: time.Now(),
+	}
+	return attachmentNodes(v, "attaching"), nil
+}
+
+func (srv *Server) detachVolume(form url.Values) ([]node, *awsError) {
+	volumeID, err := required(form, "VolumeId")
+	if err != nil {
+		return nil, err
+	}
+	v, ok := srv.volumes[volumeID]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidVolume.NotFound", "The volume '%s' does not exist.", volumeID)
+	}
+	instanceID := form.Get("InstanceId")
+	if v.Attachment == nil || (instanceID != "" && v.Attachment.InstanceID != instanceID) {
+		return nil, newError(http.StatusBadRequest, "IncorrectState", "Volume '%s' is in the 'available' state.", volumeID)
+	}
+	nodes := attachmentNodes(v, "detaching")
+	v.Attachment = nil
+	v.State = "available"
+	return nodes, nil
+}
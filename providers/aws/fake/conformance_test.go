@@ -0,0 +1,35 @@
+package fake
+
+import (
+	"os"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/aws"
+	"github.com/SebastienDorgan/gpac/providers/tests"
+)
+
+//TestConformance runs the conformance scenarios against the fake, the report is saved in $GPAC_CONFORMANCE_DIR if set
+func TestConformance(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	c := tests.Conformance{
+		Provider: "aws-fake",
+		Factory: func() (api.ClientAPI, error) {
+			return aws.AuthenticatedClient(srv.AuthOpts())
+		},
+		SkipRemote: true,
+		Unsupported: map[string]string{
+			"GetKeyPair":       "key pairs are returned with their fingerprint instead of their public key",
+			"ListKeyPairs":     "key pairs are listed with their fingerprint instead of their public key",
+			"VolumeAttachment": "the names of the volume attachments are not kept",
+			"Containers":       "the container names of the scenario are not valid bucket names",
+			"Objects":          "the container names of the scenario are not valid bucket names",
+		},
+	}
+	report := c.Run(t)
+	err := report.Save(os.Getenv("GPAC_CONFORMANCE_DIR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
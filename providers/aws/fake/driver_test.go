@@ -0,0 +1,223 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeSpeed"
+	"github.com/SebastienDorgan/gpac/providers/aws"
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestDeleteNetworkGatewayTimeout(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+
+	net, err := clt.CreateNetwork(networkRequest(t, clt, "net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the gateway stays shutting-down until Release is called
+	srv.Hold()
+	timeout := aws.GatewayTerminationTimeout
+	aws.GatewayTerminationTimeout = 100 * time.Millisecond
+	defer func() { aws.GatewayTerminationTimeout = timeout }()
+	err = clt.DeleteNetwork(net.ID)
+	if api.KindOf(err) != api.ErrTimeout {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	//nothing is deleted while the gateway is attached
+	if n := srv.Count("EC2 DeleteSubnet") + srv.Count("EC2 DeleteVpc"); n != 0 {
+		t.Fatalf("%d subnet or VPC deletions sent while the gateway is terminating", n)
+	}
+
+	//the network is deleted once the gateway is terminated
+	srv.Release()
+	err = clt.DeleteNetwork(net.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetVMUnreadableRecord(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+
+	net, err := clt.CreateNetwork(networkRequest(t, clt, "net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = clt.MetadataStore().Put("gpac.aws.wms", net.GatewayID, []byte("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the gateway must not be returned without its private key
+	vm, err := clt.GetVM(net.GatewayID)
+	if err == nil {
+		t.Fatalf("VM returned from an unreadable record: %+v", vm)
+	}
+}
+
+//failingStore metadata store failing to write the VM records
+type failingStore struct {
+	providers.MetadataStore
+}
+
+func (s failingStore) Put(bucket string, key string, value []byte) (string, error) {
+	if bucket == "gpac.aws.wms" {
+		return "", errors.New("store unavailable")
+	}
+	return s.MetadataStore.Put(bucket, key, value)
+}
+
+func TestCreateVMRollback(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+
+	req := networkRequest(t, clt, "net")
+	net, err := clt.CreateNetwork(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := srv.count()
+	clt.SetMetadataStore(failingStore{clt.MetadataStore()})
+	vmReq := req.GWRequest
+	vmReq.Name = "vm"
+	vmReq.NetworkIDs = []string{net.ID}
+	_, err = clt.CreateVM(vmReq)
+	if err == nil {
+		t.Fatal("VM created without its record")
+	}
+	//the temporary key pair, the instance and its Elastic IP are deleted
+	if after := srv.count(); after != before {
+		t.Fatalf("resources left behind: %+v, expected %+v", after, before)
+	}
+}
+
+func TestCreateVMContextCancel(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+
+	req := networkRequest(t, clt, "net")
+	net, err := clt.CreateNetwork(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := srv.count()
+	//the instance stays pending and the creation is cancelled once EC2 has allocated its Elastic IP, before the
+	//response of the allocation is sent
+	srv.Hold()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv.OnHandled = func(action string) {
+		if action == "AllocateAddress" {
+			cancel()
+		}
+	}
+	vmReq := req.GWRequest
+	vmReq.Name = "vm"
+	vmReq.NetworkIDs = []string{net.ID}
+	_, err = clt.CreateVMContext(ctx, vmReq)
+	if err == nil {
+		t.Fatal("VM created with a cancelled context")
+	}
+	srv.Release()
+	//the temporary key pair, the instance and its Elastic IP are deleted
+	if after := srv.count(); after != before {
+		t.Fatalf("resources left behind: %+v, expected %+v", after, before)
+	}
+}
+
+func TestUpdateVolume(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+
+	out, err := clt.CreateVolume(api.VolumeRequest{Name: "v0", Size: 10, Speed: VolumeSpeed.SSD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "v1"
+	size := 20
+	speed := VolumeSpeed.SSD
+	v, err := clt.UpdateVolume(out.ID, api.VolumeUpdateRequest{Name: &name, Size: &size, Speed: &speed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != name || v.Size != size || v.Speed != speed {
+		t.Fatalf("expected volume %s of %d GB on SSD, got %+v", name, size, v)
+	}
+	size = 5
+	_, err = clt.UpdateVolume(out.ID, api.VolumeUpdateRequest{Size: &size})
+	if api.KindOf(err) != api.ErrInvalidRequest {
+		t.Fatalf("expected an invalid request error shrinking the volume, got %v", err)
+	}
+}
+
+func TestListUnrecordedResources(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+
+	//a VPC and a volume not created by the driver, like the default VPC and the root volumes of the VMs
+	_, err := clt.EC2.CreateVpc(&ec2.CreateVpcInput{CidrBlock: awssdk.String("172.31.0.0/16")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := clt.EC2.CreateVolume(&ec2.CreateVolumeInput{
+		Size:             awssdk.Int64(10),
+		AvailabilityZone: awssdk.String(srv.AuthOpts().Region + "a"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nets, err := clt.ListNetworks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 0 {
+		t.Fatalf("unrecorded networks listed: %+v", nets)
+	}
+	volumes, err := clt.ListVolumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 0 {
+		t.Fatalf("unrecorded volumes listed: %+v", volumes)
+	}
+	v, err := clt.GetVolume(*out.VolumeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Size != 10 {
+		t.Fatalf("expected a volume of 10 GB, got %+v", v)
+	}
+}
+
+func TestDeleteVolumeRecord(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+
+	v, err := clt.CreateVolume(api.VolumeRequest{Name: "v", Size: 10, Speed: VolumeSpeed.SSD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = clt.DeleteVolume(v.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = clt.MetadataStore().Get("gpac.aws.volumes", v.ID)
+	if api.KindOf(err) != api.ErrNotFound {
+		t.Fatalf("expected the record of the volume to be deleted, got %v", err)
+	}
+}
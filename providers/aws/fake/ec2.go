@@ -0,0 +1,616 @@
+package fake
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+//ec2Namespace XML namespace of EC2 responses
+const ec2Namespace = "http://ec2.amazonaws.com/doc/2016-11-15/"
+
+//ownerID account identifier of the resources created by the fake
+const ownerID = "123456789012"
+
+//node an XML element of an EC2 response
+type node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []node     `xml:",any"`
+}
+
+//el creates an element
+func el(name string, children ...node) node {
+	return node{
+		XMLName:  xml.Name{Local: name},
+		Children: children,
+	}
+}
+
+//txt creates a text element
+func txt(name string, value string) node {
+	return node{
+		XMLName: xml.Name{Local: name},
+		Text:    value,
+	}
+}
+
+//item creates an element of a set
+func item(children ...node) node {
+	return el("item", children...)
+}
+
+//tagSpecifications returns the tags given to the created resource of type resourceType by the TagSpecification
+//parameters
+func tagSpecifications(form url.Values, resourceType string) (map[string]string, *awsError) {
+	tags := map[string]string{}
+	for i := 1; ; i++ {
+		prefix := fmt.Sprintf("TagSpecification.%d.", i)
+		rt := form.Get(prefix + "ResourceType")
+		if rt == "" {
+			return tags, nil
+		}
+		if rt != resourceType {
+			return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "'%s' is not a valid taggable resource type for this operation.", rt)
+		}
+		for j := 1; ; j++ {
+			key := form.Get(fmt.Sprintf("%sTag.%d.Key", prefix, j))
+			if key == "" {
+				break
+			}
+			tags[key] = form.Get(fmt.Sprintf("%sTag.%d.Value", prefix, j))
+		}
+	}
+}
+
+//tagSet returns the tagSet element of a resource
+func tagSet(tags map[string]string) node {
+	var keys []string
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	var items []node
+	for _, k := range sortedKeys(keys) {
+		items = append(items, item(txt("key", k), txt("value", tags[k])))
+	}
+	return el("tagSet", items...)
+}
+
+func boolText(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+func timeText(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+//ec2Handler handles an EC2 action, form contains the request parameters
+type ec2Handler func(srv *Server, form url.Values) ([]node, *awsError)
+
+var ec2Handlers = map[string]ec2Handler{
+	"DescribeImages":                (*Server).describeImages,
+	"DescribeInstanceTypeOfferings": (*Server).describeInstanceTypeOfferings,
+	"DescribeAvailabilityZones":     (*Server).describeAvailabilityZones,
+	"ImportKeyPair":                 (*Server).importKeyPair,
+	"DescribeKeyPairs":              (*Server).describeKeyPairs,
+	"DeleteKeyPair":                 (*Server).deleteKeyPair,
+	"CreateVpc":                     (*Server).createVpc,
+	"DescribeVpcs":                  (*Server).describeVpcs,
+	"DeleteVpc":                     (*Server).deleteVpc,
+	"CreateSubnet":                  (*Server).createSubnet,
+	"DescribeSubnets":               (*Server).describeSubnets,
+	"DeleteSubnet":                  (*Server).deleteSubnet,
+	"CreateInternetGateway":         (*Server).createInternetGateway,
+	"DescribeInternetGateways":      (*Server).describeInternetGateways,
+	"AttachInternetGateway":         (*Server).attachInternetGateway,
+	"DetachInternetGateway":         (*Server).detachInternetGateway,
+	"DeleteInternetGateway":         (*Server).deleteInternetGateway,
+	"DescribeRouteTables":           (*Server).describeRouteTables,
+	"CreateRoute":                   (*Server).createRoute,
+	"AssociateRouteTable":           (*Server).associateRouteTable,
+	"DisassociateRouteTable":        (*Server).disassociateRouteTable,
+	"CreateSecurityGroup":           (*Server).createSecurityGroup,
+	"DescribeSecurityGroups":        (*Server).describeSecurityGroups,
+	"DeleteSecurityGroup":           (*Server).deleteSecurityGroup,
+	"AuthorizeSecurityGroupIngress": (*Server).authorizeSecurityGroupIngress,
+	"AuthorizeSecurityGroupEgress":  (*Server).authorizeSecurityGroupEgress,
+	"AllocateAddress":               (*Server).allocateAddress,
+	"DescribeAddresses":             (*Server).describeAddresses,
+	"AssociateAddress":              (*Server).associateAddress,
+	"DisassociateAddress":           (*Server).disassociateAddress,
+	"ReleaseAddress":                (*Server).releaseAddress,
+	"RunInstances":                  (*Server).runInstances,
+	"DescribeInstances":             (*Server).describeInstances,
+	"StopInstances":                 (*Server).stopInstances,
+	"StartInstances":                (*Server).startInstances,
+	"TerminateInstances":            (*Server).terminateInstances,
+	"DescribeNetworkInterfaces":     (*Server).describeNetworkInterfaces,
+	"CreateVolume":                  (*Server).createVolume,
+	"DescribeVolumes":               (*Server).describeVolumes,
+	"DeleteVolume":                  (*Server).deleteVolume,
+	"ModifyVolume":                  (*Server).modifyVolume,
+	"AttachVolume":                  (*Server).attachVolume,
+	"DetachVolume":                  (*Server).detachVolume,
+}
+
+func (srv *Server) serveEC2(w http.ResponseWriter, r *http.Request) {
+	requestID := uuid.NewV4().String()
+	if err := r.ParseForm(); err != nil {
+		writeEC2Error(w, requestID, newError(http.StatusBadRequest, "InvalidParameterValue", "%s", err.Error()))
+		return
+	}
+	action := r.PostForm.Get("Action")
+	srv.record("EC2 " + action)
+	handler, ok := ec2Handlers[action]
+	if !ok {
+		writeEC2Error(w, requestID, newError(http.StatusBadRequest, "InvalidAction", "The action %s is not valid for this web service.", action))
+		return
+	}
+	srv.mu.Lock()
+	srv.refresh()
+	nodes, err := handler(srv, r.PostForm)
+	onHandled := srv.OnHandled
+	srv.mu.Unlock()
+	if onHandled != nil {
+		onHandled(action)
+	}
+	if err != nil {
+		writeEC2Error(w, requestID, err)
+		return
+	}
+	res := el(action+"Response", append([]node{txt("requestId", requestID)}, nodes...)...)
+	res.Attrs = []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: ec2Namespace}}
+	writeXML(w, http.StatusOK, res)
+}
+
+func writeEC2Error(w http.ResponseWriter, requestID string, err *awsError) {
+	writeXML(w, err.Status, el("Response",
+		el("Errors",
+			el("Error",
+				txt("Code", err.Code),
+				txt("Message", err.Message),
+			),
+		),
+		txt("RequestID", requestID),
+	))
+}
+
+//returnTrue is the response of actions returning a boolean
+func returnTrue() []node {
+	return []node{txt("return", "true")}
+}
+
+//list returns the values of a list parameter (prefix.1, prefix.2 ...)
+func list(form url.Values, prefix string) []string {
+	var values []string
+	for i := 1; ; i++ {
+		v, ok := form[fmt.Sprintf("%s.%d", prefix, i)]
+		if !ok {
+			return values
+		}
+		values = append(values, v[0])
+	}
+}
+
+//required returns the value of a required parameter
+func required(form url.Values, name string) (string, *awsError) {
+	v := form.Get(name)
+	if v == "" {
+		return "", newError(http.StatusBadRequest, "MissingParameter", "The request must contain the parameter %s", name)
+	}
+	return v, nil
+}
+
+//filter an EC2 filter
+type filter struct {
+	name   string
+	values []string
+}
+
+//filters returns the filters of the request
+func filters(form url.Values) []filter {
+	var res []filter
+	for i := 1; ; i++ {
+		name := form.Get(fmt.Sprintf("Filter.%d.Name", i))
+		if name == "" {
+			return res
+		}
+		res = append(res, filter{
+			name:   name,
+			values: list(form, fmt.Sprintf("Filter.%d.Value", i)),
+		})
+	}
+}
+
+//match tells if a resource having attributes attrs matches all the filters
+//Filter values may contain * and ? wildcards
+func match(filters []filter, attrs map[string][]string) bool {
+	for _, f := range filters {
+		found := false
+		for _, v := range attrs[f.name] {
+			for _, pattern := range f.values {
+				if ok, _ := path.Match(pattern, v); ok {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+//selected tells if id is selected by the list of ids requested, an empty list selects everything
+func selected(ids []string, id string) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+//checkIDs returns a NotFound error if one of the requested ids does not exist
+func checkIDs(ids []string, exists func(id string) bool, code string, kind string) *awsError {
+	for _, id := range ids {
+		if !exists(id) {
+			return newError(http.StatusBadRequest, code, "The %s '%s' does not exist", kind, id)
+		}
+	}
+	return nil
+}
+
+//page returns the bounds of the page of a list of n elements requested by MaxResults and NextToken
+func page(form url.Values, n int) (int, int, *awsError) {
+	start := 0
+	if token := form.Get("NextToken"); token != "" {
+		var e error
+		start, e = strconv.Atoi(token)
+		if e != nil || start < 0 || start > n {
+			return 0, 0, newError(http.StatusBadRequest, "InvalidParameterValue", "Invalid value '%s' for nextToken", token)
+		}
+	}
+	end := n
+	if max := form.Get("MaxResults"); max != "" {
+		size, e := strconv.Atoi(max)
+		if e != nil || size < 5 || size > 1000 {
+			return 0, 0, newError(http.StatusBadRequest, "InvalidParameterValue", "Value ( %s ) for parameter maxResults is invalid. Expecting a value between 5 and 1000.", max)
+		}
+		if start+size < end {
+			end = start + size
+		}
+	}
+	return start, end, nil
+}
+
+//ipAt returns the n-th address of the network defined by cidr
+func ipAt(cidr string, n int) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := ipNet.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	offset := big.NewInt(int64(n))
+	if offset.Cmp(size) >= 0 {
+		return "", fmt.Errorf("Network %s is full", cidr)
+	}
+	ip := new(big.Int).SetBytes(ipNet.IP)
+	ip.Add(ip, offset)
+	b := ip.Bytes()
+	addr := make(net.IP, len(ipNet.IP))
+	copy(addr[len(addr)-len(b):], b)
+	return addr.String(), nil
+}
+
+//refresh applies the pending state changes of instances and volumes
+func (srv *Server) refresh() {
+	for _, i := range srv.instances {
+		srv.refreshInstance(i)
+	}
+	for _, v := range srv.volumes {
+		v.refresh()
+	}
+}
+
+//transition a pending state change
+type transition struct {
+	target string
+	at     time.Time
+	//held the state change is not completed before Release is called
+	held bool
+}
+
+func (srv *Server) schedule(target string) *transition {
+	return &transition{
+		target: target,
+		at:     time.Now().Add(srv.Opts.BuildDelay),
+		held:   srv.held,
+	}
+}
+
+//done tells if the state change is completed
+func (t *transition) done() bool {
+	return t != nil && !t.held && !time.Now().Before(t.at)
+}
+
+//Hold keeps the instances and volumes changing state from now on in their transient states until Release is called
+func (srv *Server) Hold() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.held = true
+}
+
+//Release lets the held state changes complete, they complete at once if BuildDelay is elapsed
+func (srv *Server) Release() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.held = false
+	for _, i := range srv.instances {
+		if i.pending != nil {
+			i.pending.held = false
+		}
+	}
+	for _, v := range srv.volumes {
+		if v.pending != nil {
+			v.pending.held = false
+		}
+	}
+}
+
+func (srv *Server) describeImages(form url.Values) ([]node, *awsError) {
+	ids := list(form, "ImageId")
+	owners := list(form, "Owner")
+	exists := func(id string) bool {
+		for _, img := range srv.Opts.Images {
+			if img.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+	if err := checkIDs(ids, exists, "InvalidAMIID.NotFound", "image id"); err != nil {
+		return nil, err
+	}
+	fs := filters(form)
+	var items []node
+	for _, img := range srv.Opts.Images {
+		attrs := map[string][]string{
+			"image-id":            {img.ID},
+			"name":                {img.Name},
+			"description":         {img.Description},
+			"owner-id":            {img.OwnerID},
+			"state":               {"available"},
+			"architecture":        {"x86_64"},
+			"virtualization-type": {"hvm"},
+			"root-device-type":    {"ebs"},
+			"is-public":           {"true"},
+		}
+		if !selected(ids, img.ID) || !selected(owners, img.OwnerID) || !match(fs, attrs) {
+			continue
+		}
+		items = append(items, item(
+			txt("imageId", img.ID),
+			txt("imageLocation", img.OwnerID+"/"+img.Name),
+			txt("imageState", "available"),
+			txt("imageOwnerId", img.OwnerID),
+			txt("isPublic", "true"),
+			txt("architecture", "x86_64"),
+			txt("imageType", "machine"),
+			txt("name", img.Name),
+			txt("description", img.Description),
+			txt("rootDeviceType", "ebs"),
+			txt("rootDeviceName", "/dev/sda1"),
+			txt("virtualizationType", "hvm"),
+			txt("hypervisor", "xen"),
+			txt("enaSupport", "true"),
+		))
+	}
+	return []node{el("imagesSet", items...)}, nil
+}
+
+func (srv *Server) findImage(id string) *Image {
+	for _, img := range srv.Opts.Images {
+		if img.ID == id {
+			res := img
+			return &res
+		}
+	}
+	return nil
+}
+
+func (srv *Server) findInstanceType(name string) *InstanceType {
+	for _, t := range srv.Opts.InstanceTypes {
+		if t.Name == name {
+			res := t
+			return &res
+		}
+	}
+	return nil
+}
+
+//offered tells if the instance type is offered in region
+func (t *InstanceType) offered(region string) bool {
+	if len(t.Regions) == 0 {
+		return true
+	}
+	for _, r := range t.Regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+//zones availability zones of the region of the server
+func (srv *Server) zones() []string {
+	return []string{srv.Opts.Region + "a", srv.Opts.Region + "b", srv.Opts.Region + "c"}
+}
+
+func (srv *Server) describeAvailabilityZones(form url.Values) ([]node, *awsError) {
+	names := list(form, "ZoneName")
+	fs := filters(form)
+	var items []node
+	for _, z := range srv.zones() {
+		attrs := map[string][]string{
+			"zone-name":   {z},
+			"region-name": {srv.Opts.Region},
+			"state":       {"available"},
+		}
+		if !selected(names, z) || !match(fs, attrs) {
+			continue
+		}
+		items = append(items, item(
+			txt("zoneName", z),
+			txt("zoneState", "available"),
+			txt("regionName", srv.Opts.Region),
+		))
+	}
+	return []node{el("availabilityZoneInfo", items...)}, nil
+}
+
+func (srv *Server) describeInstanceTypeOfferings(form url.Values) ([]node, *awsError) {
+	locationType := form.Get("LocationType")
+	if locationType == "" {
+		locationType = "region"
+	}
+	if locationType != "region" && locationType != "availability-zone" {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "The value '%s' for parameter LocationType is invalid.", locationType)
+	}
+	location := srv.Opts.Region
+	if locationType == "availability-zone" {
+		location += "a"
+	}
+	fs := filters(form)
+	var offers []InstanceType
+	for _, t := range srv.Opts.InstanceTypes {
+		attrs := map[string][]string{
+			"instance-type": {t.Name},
+			"location":      {location},
+		}
+		if t.offered(srv.Opts.Region) && match(fs, attrs) {
+			offers = append(offers, t)
+		}
+	}
+	start, end, err := page(form, len(offers))
+	if err != nil {
+		return nil, err
+	}
+	var items []node
+	for _, t := range offers[start:end] {
+		items = append(items, item(
+			txt("instanceType", t.Name),
+			txt("locationType", locationType),
+			txt("location", location),
+		))
+	}
+	nodes := []node{el("instanceTypeOfferingSet", items...)}
+	if end < len(offers) {
+		nodes = append(nodes, txt("nextToken", strconv.Itoa(end)))
+	}
+	return nodes, nil
+}
+
+type keyPair struct {
+	Name        string
+	Fingerprint string
+	PublicKey   string
+	Tags        map[string]string
+}
+
+func (srv *Server) importKeyPair(form url.Values) ([]node, *awsError) {
+	name, err := required(form, "KeyName")
+	if err != nil {
+		return nil, err
+	}
+	material, err := required(form, "PublicKeyMaterial")
+	if err != nil {
+		return nil, err
+	}
+	key, e := base64.StdEncoding.DecodeString(material)
+	if e != nil || !strings.HasPrefix(string(key), "ssh-") {
+		return nil, newError(http.StatusBadRequest, "InvalidKey.Format", "Key is not in valid OpenSSH public key format")
+	}
+	if _, ok := srv.keyPairs[name]; ok {
+		return nil, newError(http.StatusBadRequest, "InvalidKeyPair.Duplicate", "The keypair '%s' already exists.", name)
+	}
+	tags, err := tagSpecifications(form, "key-pair")
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(key)
+	var fp []string
+	for _, b := range sum {
+		fp = append(fp, fmt.Sprintf("%02x", b))
+	}
+	kp := keyPair{
+		Name:        name,
+		Fingerprint: strings.Join(fp, ":"),
+		PublicKey:   string(key),
+		Tags:        tags,
+	}
+	srv.keyPairs[name] = &kp
+	return []node{
+		txt("keyName", kp.Name),
+		txt("keyFingerprint", kp.Fingerprint),
+	}, nil
+}
+
+func (srv *Server) describeKeyPairs(form url.Values) ([]node, *awsError) {
+	names := list(form, "KeyName")
+	exists := func(name string) bool {
+		_, ok := srv.keyPairs[name]
+		return ok
+	}
+	if err := checkIDs(names, exists, "InvalidKeyPair.NotFound", "key pair"); err != nil {
+		return nil, err
+	}
+	fs := filters(form)
+	var keys []string
+	for name, kp := range srv.keyPairs {
+		attrs := map[string][]string{
+			"key-name":    {kp.Name},
+			"fingerprint": {kp.Fingerprint},
+		}
+		if selected(names, name) && match(fs, attrs) {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+	var items []node
+	for _, name := range keys {
+		kp := srv.keyPairs[name]
+		items = append(items, item(
+			txt("keyName", kp.Name),
+			txt("keyFingerprint", kp.Fingerprint),
+			tagSet(kp.Tags),
+		))
+	}
+	return []node{el("keySet", items...)}, nil
+}
+
+func (srv *Server) deleteKeyPair(form url.Values) ([]node, *awsError) {
+	name, err := required(form, "KeyName")
+	if err != nil {
+		return nil, err
+	}
+	delete(srv.keyPairs, name)
+	return returnTrue(), nil
+}
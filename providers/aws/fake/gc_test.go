@@ -0,0 +1,90 @@
+package fake
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/aws"
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	uuid "github.com/satori/go.uuid"
+)
+
+//createdTags returns the tag specifications of a resource created by gpac at created
+func createdTags(resourceType string, created time.Time) []*ec2.TagSpecification {
+	return []*ec2.TagSpecification{
+		{
+			ResourceType: awssdk.String(resourceType),
+			Tags: []*ec2.Tag{
+				{
+					Key:   awssdk.String(aws.CreatedTag),
+					Value: awssdk.String(created.UTC().Format(time.RFC3339)),
+				},
+			},
+		},
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+	before := srv.count()
+
+	//resources not created by gpac
+	_, err := clt.EC2.AllocateAddress(&ec2.AllocateAddressInput{Domain: awssdk.String("vpc")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = clt.EC2.CreateVpc(&ec2.CreateVpcInput{CidrBlock: awssdk.String("10.1.0.0/16")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//resources leaked by gpac two hours ago
+	old := time.Now().Add(-2 * time.Hour)
+	_, err = clt.EC2.AllocateAddress(&ec2.AllocateAddressInput{
+		Domain:            awssdk.String("vpc"),
+		TagSpecifications: createdTags(ec2.ResourceTypeElasticIp, old),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = clt.EC2.CreateVpc(&ec2.CreateVpcInput{
+		CidrBlock:         awssdk.String("10.2.0.0/16"),
+		TagSpecifications: createdTags(ec2.ResourceTypeVpc, old),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//temporary key pair of a VM being created
+	_, err = clt.CreateKeyPair("vm_" + uuid.NewV4().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := providers.CollectGarbage(clt, providers.GCOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphans) != 2 || report.Recent != 1 || report.Deleted != 0 {
+		t.Fatalf("expected 2 orphans listed and 1 recent orphan, got %+v", report)
+	}
+	report, err = providers.CollectGarbage(clt, providers.GCOptions{Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deleted != 2 || report.Failed != 0 {
+		t.Fatalf("expected 2 orphans deleted, got %+v", report)
+	}
+	expected := before
+	expected.addresses++
+	//the untagged VPC keeps its main route table and its default security group
+	expected.vpcs++
+	expected.routeTables++
+	expected.secGroups++
+	expected.keyPairs++
+	if after := srv.count(); after != expected {
+		t.Fatalf("expected the untagged and recent resources to be kept, got %+v, expected %+v", after, expected)
+	}
+}
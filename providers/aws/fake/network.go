@@ -0,0 +1,998 @@
+package fake
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+type vpc struct {
+	ID   string
+	CIDR string
+	Tags map[string]string
+}
+
+type subnet struct {
+	ID               string
+	VpcID            string
+	CIDR             string
+	AvailabilityZone string
+	Tags             map[string]string
+	//allocated number of private IPs already allocated in the subnet
+	allocated int
+}
+
+type internetGateway struct {
+	ID    string
+	VpcID string
+	Tags  map[string]string
+}
+
+type route struct {
+	Destination string
+	GatewayID   string
+}
+
+type routeAssociation struct {
+	ID       string
+	SubnetID string
+	Main     bool
+}
+
+type routeTable struct {
+	ID           string
+	VpcID        string
+	Routes       []route
+	Associations []routeAssociation
+}
+
+type permission struct {
+	Protocol string
+	FromPort string
+	ToPort   string
+	CIDRs    []string
+}
+
+type securityGroup struct {
+	ID          string
+	Name        string
+	Description string
+	VpcID       string
+	Ingress     []permission
+	Egress      []permission
+	Tags        map[string]string
+}
+
+type address struct {
+	AllocationID  string
+	PublicIP      string
+	AssociationID string
+	InterfaceID   string
+	Tags          map[string]string
+}
+
+//sortedKeys sorts resource identifiers so that responses do not depend on map ordering
+func sortedKeys(ids []string) []string {
+	sort.Strings(ids)
+	return ids
+}
+
+func vpcNode(name string, v *vpc) node {
+	return el(name,
+		txt("vpcId", v.ID),
+		txt("state", "available"),
+		txt("cidrBlock", v.CIDR),
+		el("cidrBlockAssociationSet", item(
+			txt("cidrBlock", v.CIDR),
+			txt("associationId", "vpc-cidr-assoc-"+v.ID[4:]),
+			el("cidrBlockState", txt("state", "associated")),
+		)),
+		txt("dhcpOptionsId", "default"),
+		txt("instanceTenancy", "default"),
+		txt("isDefault", "false"),
+		tagSet(v.Tags),
+	)
+}
+
+func (srv *Server) createVpc(form url.Values) ([]node, *awsError) {
+	cidr, err := required(form, "CidrBlock")
+	if err != nil {
+		return nil, err
+	}
+	_, ipNet, e := net.ParseCIDR(cidr)
+	if e != nil || ipNet.IP.To4() == nil {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Value (%s) for parameter cidrBlock is invalid. This is not a valid CIDR block.", cidr)
+	}
+	if ones, _ := ipNet.Mask.Size(); ones < 16 || ones > 28 {
+		return nil, newError(http.StatusBadRequest, "InvalidVpc.Range", "The CIDR '%s' is invalid.", cidr)
+	}
+	tags, err := tagSpecifications(form, "vpc")
+	if err != nil {
+		return nil, err
+	}
+	v := vpc{
+		ID:   newID("vpc"),
+		CIDR: ipNet.String(),
+		Tags: tags,
+	}
+	srv.vpcs[v.ID] = &v
+	rt := routeTable{
+		ID:     newID("rtb"),
+		VpcID:  v.ID,
+		Routes: []route{{Destination: v.CIDR, GatewayID: "local"}},
+		Associations: []routeAssociation{
+			{ID: newID("rtbassoc"), Main: true},
+		},
+	}
+	srv.routeTables[rt.ID] = &rt
+	sg := securityGroup{
+		ID:          newID("sg"),
+		Name:        "default",
+		Description: "default VPC security group",
+		VpcID:       v.ID,
+		Egress:      []permission{{Protocol: "-1", CIDRs: []string{"0.0.0.0/0"}}},
+	}
+	srv.secGroups[sg.ID] = &sg
+	return []node{vpcNode("vpc", &v)}, nil
+}
+
+func (srv *Server) describeVpcs(form url.Values) ([]node, *awsError) {
+	ids := list(form, "VpcId")
+	exists := func(id string) bool {
+		_, ok := srv.vpcs[id]
+		return ok
+	}
+	if err := checkIDs(ids, exists, "InvalidVpcID.NotFound", "vpc ID"); err != nil {
+		return nil, err
+	}
+	fs := filters(form)
+	var keys []string
+	for id := range srv.vpcs {
+		keys = append(keys, id)
+	}
+	var items []node
+	for _, id := range sortedKeys(keys) {
+		v := srv.vpcs[id]
+		attrs := map[string][]string{
+			"vpc-id":                            {v.ID},
+			"cidr":                              {v.CIDR},
+			"cidr-block-association.cidr-block": {v.CIDR},
+			"state":                             {"available"},
+			"is-default":                        {"false"},
+			"owner-id":                          {ownerID},
+		}
+		if selected(ids, id) && match(fs, attrs) {
+			items = append(items, vpcNode("item", v))
+		}
+	}
+	return []node{el("vpcSet", items...)}, nil
+}
+
+func (srv *Server) deleteVpc(form url.Values) ([]node, *awsError) {
+	id, err := required(form, "VpcId")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := srv.vpcs[id]; !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidVpcID.NotFound", "The vpc ID '%s' does not exist", id)
+	}
+	dependency := newError(http.StatusBadRequest, "DependencyViolation", "The vpc '%s' has dependencies and cannot be deleted.", id)
+	for _, sn := range srv.subnets {
+		if sn.VpcID == id {
+			return nil, dependency
+		}
+	}
+	for _, gw := range srv.gateways {
+		if gw.VpcID == id {
+			return nil, dependency
+		}
+	}
+	for _, rt := range srv.routeTables {
+		if rt.VpcID == id && !rt.main() {
+			return nil, dependency
+		}
+	}
+	for _, sg := range srv.secGroups {
+		if sg.VpcID == id && sg.Name != "default" {
+			return nil, dependency
+		}
+	}
+	for rtID, rt := range srv.routeTables {
+		if rt.VpcID == id {
+			delete(srv.routeTables, rtID)
+		}
+	}
+	for sgID, sg := range srv.secGroups {
+		if sg.VpcID == id {
+			delete(srv.secGroups, sgID)
+		}
+	}
+	delete(srv.vpcs, id)
+	return returnTrue(), nil
+}
+
+//contains tells if the network defined by inner is included in the network defined by outer
+func contains(outer, inner *net.IPNet) bool {
+	outerOnes, _ := outer.Mask.Size()
+	innerOnes, _ := inner.Mask.Size()
+	return outer.Contains(inner.IP) && innerOnes >= outerOnes
+}
+
+//overlaps tells if two networks overlap
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func (srv *Server) subnetNode(name string, sn *subnet) node {
+	_, ipNet, _ := net.ParseCIDR(sn.CIDR)
+	ones, bits := ipNet.Mask.Size()
+	//AWS reserves 5 addresses in each subnet
+	available := (1 << uint(bits-ones)) - 5 - sn.used(srv)
+	return el(name,
+		txt("subnetId", sn.ID),
+		txt("state", "available"),
+		txt("vpcId", sn.VpcID),
+		txt("cidrBlock", sn.CIDR),
+		txt("availableIpAddressCount", strconv.Itoa(available)),
+		txt("availabilityZone", sn.AvailabilityZone),
+		txt("defaultForAz", "false"),
+		txt("mapPublicIpOnLaunch", "false"),
+		tagSet(sn.Tags),
+	)
+}
+
+//used returns the number of network interfaces of the subnet
+func (sn *subnet) used(srv *Server) int {
+	n := 0
+	for _, ni := range srv.interfaces {
+		if ni.SubnetID == sn.ID {
+			n++
+		}
+	}
+	return n
+}
+
+//allocateIP allocates a private IP in the subnet
+func (sn *subnet) allocateIP() (string, error) {
+	//The first four addresses are reserved by AWS
+	sn.allocated++
+	return ipAt(sn.CIDR, sn.allocated+3)
+}
+
+func (srv *Server) createSubnet(form url.Values) ([]node, *awsError) {
+	vpcID, err := required(form, "VpcId")
+	if err != nil {
+		return nil, err
+	}
+	cidr, err := required(form, "CidrBlock")
+	if err != nil {
+		return nil, err
+	}
+	v, ok := srv.vpcs[vpcID]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidVpcID.NotFound", "The vpc ID '%s' does not exist", vpcID)
+	}
+	_, ipNet, e := net.ParseCIDR(cidr)
+	if e != nil {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Value (%s) for parameter cidrBlock is invalid. This is not a valid CIDR block.", cidr)
+	}
+	_, vpcNet, _ := net.ParseCIDR(v.CIDR)
+	if ones, _ := ipNet.Mask.Size(); !contains(vpcNet, ipNet) || ones > 28 {
+		return nil, newError(http.StatusBadRequest, "InvalidSubnet.Range", "The CIDR '%s' is invalid.", cidr)
+	}
+	for _, sn := range srv.subnets {
+		_, snNet, _ := net.ParseCIDR(sn.CIDR)
+		if sn.VpcID == vpcID && overlaps(snNet, ipNet) {
+			return nil, newError(http.StatusBadRequest, "InvalidSubnet.Conflict", "The CIDR '%s' conflicts with another subnet", cidr)
+		}
+	}
+	az := form.Get("AvailabilityZone")
+	if az == "" {
+		az = srv.Opts.Region + "a"
+	}
+	tags, err := tagSpecifications(form, "subnet")
+	if err != nil {
+		return nil, err
+	}
+	sn := subnet{
+		ID:               newID("subnet"),
+		VpcID:            vpcID,
+		CIDR:             ipNet.String(),
+		AvailabilityZone: az,
+		Tags:             tags,
+	}
+	srv.subnets[sn.ID] = &sn
+	return []node{srv.subnetNode("subnet", &sn)}, nil
+}
+
+func (srv *Server) describeSubnets(form url.Values) ([]node, *awsError) {
+	ids := list(form, "SubnetId")
+	exists := func(id string) bool {
+		_, ok := srv.subnets[id]
+		return ok
+	}
+	if err := checkIDs(ids, exists, "InvalidSubnetID.NotFound", "subnet ID"); err != nil {
+		return nil, err
+	}
+	fs := filters(form)
+	var keys []string
+	for id := range srv.subnets {
+		keys = append(keys, id)
+	}
+	var items []node
+	for _, id := range sortedKeys(keys) {
+		sn := srv.subnets[id]
+		attrs := map[string][]string{
+			"subnet-id":         {sn.ID},
+			"vpc-id":            {sn.VpcID},
+			"cidr-block":        {sn.CIDR},
+			"availability-zone": {sn.AvailabilityZone},
+			"state":             {"available"},
+		}
+		if selected(ids, id) && match(fs, attrs) {
+			items = append(items, srv.subnetNode("item", sn))
+		}
+	}
+	return []node{el("subnetSet", items...)}, nil
+}
+
+func (srv *Server) deleteSubnet(form url.Values) ([]node, *awsError) {
+	id, err := required(form, "SubnetId")
+	if err != nil {
+		return nil, err
+	}
+	sn, ok := srv.subnets[id]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidSubnetID.NotFound", "The subnet ID '%s' does not exist", id)
+	}
+	if sn.used(srv) > 0 {
+		return nil, newError(http.StatusBadRequest, "DependencyViolation", "The subnet '%s' has dependencies and cannot be deleted.", id)
+	}
+	for _, rt := range srv.routeTables {
+		var assocs []routeAssociation
+		for _, a := range rt.Associations {
+			if a.SubnetID != id {
+				assocs = append(assocs, a)
+			}
+		}
+		rt.Associations = assocs
+	}
+	delete(srv.subnets, id)
+	return returnTrue(), nil
+}
+
+func gatewayNode(name string, gw *internetGateway) node {
+	var attachments []node
+	if gw.VpcID != "" {
+		attachments = append(attachments, item(
+			txt("vpcId", gw.VpcID),
+			txt("state", "available"),
+		))
+	}
+	return el(name,
+		txt("internetGatewayId", gw.ID),
+		el("attachmentSet", attachments...),
+		tagSet(gw.Tags),
+	)
+}
+
+func (srv *Server) createInternetGateway(form url.Values) ([]node, *awsError) {
+	tags, err := tagSpecifications(form, "internet-gateway")
+	if err != nil {
+		return nil, err
+	}
+	gw := internetGateway{
+		ID:   newID("igw"),
+		Tags: tags,
+	}
+	srv.gateways[gw.ID] = &gw
+	return []node{gatewayNode("internetGateway", &gw)}, nil
+}
+
+func (srv *Server) describeInternetGateways(form url.Values) ([]node, *awsError) {
+	ids := list(form, "InternetGatewayId")
+	exists := func(id string) bool {
+		_, ok := srv.gateways[id]
+		return ok
+	}
+	if err := checkIDs(ids, exists, "InvalidInternetGatewayID.NotFound", "internetGateway ID"); err != nil {
+		return nil, err
+	}
+	fs := filters(form)
+	var keys []string
+	for id := range srv.gateways {
+		keys = append(keys, id)
+	}
+	var items []node
+	for _, id := range sortedKeys(keys) {
+		gw := srv.gateways[id]
+		attrs := map[string][]string{
+			"internet-gateway-id": {gw.ID},
+			"attachment.vpc-id":   {gw.VpcID},
+		}
+		if selected(ids, id) && match(fs, attrs) {
+			items = append(items, gatewayNode("item", gw))
+		}
+	}
+	return []node{el("internetGatewaySet", items...)}, nil
+}
+
+//gatewayAndVpc returns the internet gateway and the VPC of an attach or detach request
+func (srv *Server) gatewayAndVpc(form url.Values) (*internetGateway, *vpc, *awsError) {
+	gwID, err := required(form, "InternetGatewayId")
+	if err != nil {
+		return nil, nil, err
+	}
+	vpcID, err := required(form, "VpcId")
+	if err != nil {
+		return nil, nil, err
+	}
+	gw, ok := srv.gateways[gwID]
+	if !ok {
+		return nil, nil, newError(http.StatusBadRequest, "InvalidInternetGatewayID.NotFound", "The internetGateway ID '%s' does not exist", gwID)
+	}
+	v, ok := srv.vpcs[vpcID]
+	if !ok {
+		return nil, nil, newError(http.StatusBadRequest, "InvalidVpcID.NotFound", "The vpc ID '%s' does not exist", vpcID)
+	}
+	return gw, v, nil
+}
+
+func (srv *Server) attachInternetGateway(form url.Values) ([]node, *awsError) {
+	gw, v, err := srv.gatewayAndVpc(form)
+	if err != nil {
+		return nil, err
+	}
+	if gw.VpcID != "" {
+		return nil, newError(http.StatusBadRequest, "Resource.AlreadyAssociated", "resource %s is already attached to network %s", gw.ID, gw.VpcID)
+	}
+	for _, other := range srv.gateways {
+		if other.VpcID == v.ID {
+			return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Network %s already has an internet gateway attached", v.ID)
+		}
+	}
+	gw.VpcID = v.ID
+	return returnTrue(), nil
+}
+
+func (srv *Server) detachInternetGateway(form url.Values) ([]node, *awsError) {
+	gw, v, err := srv.gatewayAndVpc(form)
+	if err != nil {
+		return nil, err
+	}
+	if gw.VpcID != v.ID {
+		return nil, newError(http.StatusBadRequest, "Gateway.NotAttached", "resource %s is not attached to network %s", gw.ID, v.ID)
+	}
+	for _, addr := range srv.addresses {
+		if ni, ok := srv.interfaces[addr.InterfaceID]; ok && ni.VpcID == v.ID {
+			return nil, newError(http.StatusBadRequest, "DependencyViolation", "Network %s has some mapped public address(es). Please unmap those public address(es) before detaching the gateway.", v.ID)
+		}
+	}
+	gw.VpcID = ""
+	return returnTrue(), nil
+}
+
+func (srv *Server) deleteInternetGateway(form url.Values) ([]node, *awsError) {
+	id, err := required(form, "InternetGatewayId")
+	if err != nil {
+		return nil, err
+	}
+	gw, ok := srv.gateways[id]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidInternetGatewayID.NotFound", "The internetGateway ID '%s' does not exist", id)
+	}
+	if gw.VpcID != "" {
+		return nil, newError(http.StatusBadRequest, "DependencyViolation", "The internetGateway '%s' has dependencies and cannot be deleted.", id)
+	}
+	delete(srv.gateways, id)
+	return returnTrue(), nil
+}
+
+//main tells if the route table is the main route table of its VPC
+func (rt *routeTable) main() bool {
+	for _, a := range rt.Associations {
+		if a.Main {
+			return true
+		}
+	}
+	return false
+}
+
+func routeTableNode(rt *routeTable) node {
+	var routes []node
+	for _, r := range rt.Routes {
+		origin := "CreateRoute"
+		if r.GatewayID == "local" {
+			origin = "CreateRouteTable"
+		}
+		routes = append(routes, item(
+			txt("destinationCidrBlock", r.Destination),
+			txt("gatewayId", r.GatewayID),
+			txt("state", "active"),
+			txt("origin", origin),
+		))
+	}
+	var assocs []node
+	for _, a := range rt.Associations {
+		n := item(
+			txt("routeTableAssociationId", a.ID),
+			txt("routeTableId", rt.ID),
+			txt("main", boolText(a.Main)),
+		)
+		if a.SubnetID != "" {
+			n.Children = append(n.Children, txt("subnetId", a.SubnetID))
+		}
+		assocs = append(assocs, n)
+	}
+	return item(
+		txt("routeTableId", rt.ID),
+		txt("vpcId", rt.VpcID),
+		txt("ownerId", ownerID),
+		el("routeSet", routes...),
+		el("associationSet", assocs...),
+		el("propagatingVgwSet"),
+		el("tagSet"),
+	)
+}
+
+func (srv *Server) describeRouteTables(form url.Values) ([]node, *awsError) {
+	ids := list(form, "RouteTableId")
+	exists := func(id string) bool {
+		_, ok := srv.routeTables[id]
+		return ok
+	}
+	if err := checkIDs(ids, exists, "InvalidRouteTableID.NotFound", "routeTable ID"); err != nil {
+		return nil, err
+	}
+	fs := filters(form)
+	var keys []string
+	for id := range srv.routeTables {
+		keys = append(keys, id)
+	}
+	var items []node
+	for _, id := range sortedKeys(keys) {
+		rt := srv.routeTables[id]
+		attrs := map[string][]string{
+			"route-table-id":   {rt.ID},
+			"vpc-id":           {rt.VpcID},
+			"association.main": {boolText(rt.main())},
+		}
+		for _, a := range rt.Associations {
+			attrs["association.subnet-id"] = append(attrs["association.subnet-id"], a.SubnetID)
+			attrs["association.route-table-association-id"] = append(attrs["association.route-table-association-id"], a.ID)
+		}
+		for _, r := range rt.Routes {
+			attrs["route.gateway-id"] = append(attrs["route.gateway-id"], r.GatewayID)
+			attrs["route.destination-cidr-block"] = append(attrs["route.destination-cidr-block"], r.Destination)
+		}
+		if selected(ids, id) && match(fs, attrs) {
+			items = append(items, routeTableNode(rt))
+		}
+	}
+	return []node{el("routeTableSet", items...)}, nil
+}
+
+func (srv *Server) createRoute(form url.Values) ([]node, *awsError) {
+	rtID, err := required(form, "RouteTableId")
+	if err != nil {
+		return nil, err
+	}
+	dest, err := required(form, "DestinationCidrBlock")
+	if err != nil {
+		return nil, err
+	}
+	rt, ok := srv.routeTables[rtID]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidRouteTableID.NotFound", "The routeTable ID '%s' does not exist", rtID)
+	}
+	gwID := form.Get("GatewayId")
+	if gwID == "" {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterCombination", "No target specified for route %s", dest)
+	}
+	gw, ok := srv.gateways[gwID]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidGatewayID.NotFound", "The gateway ID '%s' does not exist", gwID)
+	}
+	if gw.VpcID != rt.VpcID {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "route table %s and network gateway %s belong to different networks", rt.ID, gw.ID)
+	}
+	for _, r := range rt.Routes {
+		if r.Destination == dest {
+			return nil, newError(http.StatusBadRequest, "RouteAlreadyExists", "The route identified by %s already exists.", dest)
+		}
+	}
+	rt.Routes = append(rt.Routes, route{Destination: dest, GatewayID: gwID})
+	return returnTrue(), nil
+}
+
+func (srv *Server) associateRouteTable(form url.Values) ([]node, *awsError) {
+	rtID, err := required(form, "RouteTableId")
+	if err != nil {
+		return nil, err
+	}
+	snID, err := required(form, "SubnetId")
+	if err != nil {
+		return nil, err
+	}
+	rt, ok := srv.routeTables[rtID]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidRouteTableID.NotFound", "The routeTable ID '%s' does not exist", rtID)
+	}
+	sn, ok := srv.subnets[snID]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidSubnetID.NotFound", "The subnet ID '%s' does not exist", snID)
+	}
+	if sn.VpcID != rt.VpcID {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Route table %s and subnet %s belong to different networks", rt.ID, sn.ID)
+	}
+	for _, other := range srv.routeTables {
+		for _, a := range other.Associations {
+			if a.SubnetID == snID {
+				return nil, newError(http.StatusBadRequest, "Resource.AlreadyAssociated", "the specified association for route table %s conflicts with an existing association", rt.ID)
+			}
+		}
+	}
+	a := routeAssociation{
+		ID:       newID("rtbassoc"),
+		SubnetID: snID,
+	}
+	rt.Associations = append(rt.Associations, a)
+	return []node{txt("associationId", a.ID)}, nil
+}
+
+func (srv *Server) disassociateRouteTable(form url.Values) ([]node, *awsError) {
+	id, err := required(form, "AssociationId")
+	if err != nil {
+		return nil, err
+	}
+	for _, rt := range srv.routeTables {
+		for i, a := range rt.Associations {
+			if a.ID == id && !a.Main {
+				rt.Associations = append(rt.Associations[:i], rt.Associations[i+1:]...)
+				return returnTrue(), nil
+			}
+		}
+	}
+	return nil, newError(http.StatusBadRequest, "InvalidAssociationID.NotFound", "The association ID '%s' does not exist", id)
+}
+
+func (srv *Server) createSecurityGroup(form url.Values) ([]node, *awsError) {
+	name, err := required(form, "GroupName")
+	if err != nil {
+		return nil, err
+	}
+	description, err := required(form, "GroupDescription")
+	if err != nil {
+		return nil, err
+	}
+	vpcID, err := required(form, "VpcId")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := srv.vpcs[vpcID]; !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidVpcID.NotFound", "The vpc ID '%s' does not exist", vpcID)
+	}
+	for _, sg := range srv.secGroups {
+		if sg.VpcID == vpcID && sg.Name == name {
+			return nil, newError(http.StatusBadRequest, "InvalidGroup.Duplicate", "The security group '%s' already exists for VPC '%s'", name, vpcID)
+		}
+	}
+	tags, err := tagSpecifications(form, "security-group")
+	if err != nil {
+		return nil, err
+	}
+	sg := securityGroup{
+		ID:          newID("sg"),
+		Name:        name,
+		Description: description,
+		VpcID:       vpcID,
+		Egress:      []permission{{Protocol: "-1", CIDRs: []string{"0.0.0.0/0"}}},
+		Tags:        tags,
+	}
+	srv.secGroups[sg.ID] = &sg
+	return []node{txt("return", "true"), txt("groupId", sg.ID)}, nil
+}
+
+func permissionsNode(name string, perms []permission) node {
+	var items []node
+	for _, p := range perms {
+		var ranges []node
+		for _, c := range p.CIDRs {
+			ranges = append(ranges, item(txt("cidrIp", c)))
+		}
+		n := item(txt("ipProtocol", p.Protocol))
+		if p.FromPort != "" {
+			n.Children = append(n.Children, txt("fromPort", p.FromPort), txt("toPort", p.ToPort))
+		}
+		n.Children = append(n.Children, el("groups"), el("ipRanges", ranges...))
+		items = append(items, n)
+	}
+	return el(name, items...)
+}
+
+func (srv *Server) describeSecurityGroups(form url.Values) ([]node, *awsError) {
+	ids := list(form, "GroupId")
+	exists := func(id string) bool {
+		_, ok := srv.secGroups[id]
+		return ok
+	}
+	if err := checkIDs(ids, exists, "InvalidGroup.NotFound", "security group"); err != nil {
+		return nil, err
+	}
+	fs := filters(form)
+	var keys []string
+	for id := range srv.secGroups {
+		keys = append(keys, id)
+	}
+	var items []node
+	for _, id := range sortedKeys(keys) {
+		sg := srv.secGroups[id]
+		attrs := map[string][]string{
+			"group-id":   {sg.ID},
+			"group-name": {sg.Name},
+			"vpc-id":     {sg.VpcID},
+		}
+		if selected(ids, id) && match(fs, attrs) {
+			items = append(items, item(
+				txt("ownerId", ownerID),
+				txt("groupId", sg.ID),
+				txt("groupName", sg.Name),
+				txt("groupDescription", sg.Description),
+				txt("vpcId", sg.VpcID),
+				permissionsNode("ipPermissions", sg.Ingress),
+				permissionsNode("ipPermissionsEgress", sg.Egress),
+				tagSet(sg.Tags),
+			))
+		}
+	}
+	return []node{el("securityGroupInfo", items...)}, nil
+}
+
+func (srv *Server) deleteSecurityGroup(form url.Values) ([]node, *awsError) {
+	id, err := required(form, "GroupId")
+	if err != nil {
+		return nil, err
+	}
+	sg, ok := srv.secGroups[id]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidGroup.NotFound", "The security group '%s' does not exist", id)
+	}
+	if sg.Name == "default" {
+		return nil, newError(http.StatusBadRequest, "CannotDelete", "the specified group: \"%s\" name: \"default\" cannot be deleted by a user", id)
+	}
+	delete(srv.secGroups, id)
+	return returnTrue(), nil
+}
+
+//permissions returns the IP permissions of an authorize request
+func permissions(form url.Values) []permission {
+	var perms []permission
+	for i := 1; ; i++ {
+		prefix := "IpPermissions." + strconv.Itoa(i)
+		protocol := form.Get(prefix + ".IpProtocol")
+		if protocol == "" {
+			return perms
+		}
+		perms = append(perms, permission{
+			Protocol: protocol,
+			FromPort: form.Get(prefix + ".FromPort"),
+			ToPort:   form.Get(prefix + ".ToPort"),
+		})
+		for j := 1; ; j++ {
+			cidr := form.Get(prefix + ".IpRanges." + strconv.Itoa(j) + ".CidrIp")
+			if cidr == "" {
+				break
+			}
+			perms[len(perms)-1].CIDRs = append(perms[len(perms)-1].CIDRs, cidr)
+		}
+	}
+}
+
+//authorizedGroup returns the security group of an authorize request
+func (srv *Server) authorizedGroup(form url.Values) (*securityGroup, []permission, *awsError) {
+	id, err := required(form, "GroupId")
+	if err != nil {
+		return nil, nil, err
+	}
+	sg, ok := srv.secGroups[id]
+	if !ok {
+		return nil, nil, newError(http.StatusBadRequest, "InvalidGroup.NotFound", "The security group '%s' does not exist", id)
+	}
+	perms := permissions(form)
+	if len(perms) == 0 {
+		return nil, nil, newError(http.StatusBadRequest, "MissingParameter", "The request must contain the parameter ipPermissions")
+	}
+	return sg, perms, nil
+}
+
+func (srv *Server) authorizeSecurityGroupIngress(form url.Values) ([]node, *awsError) {
+	sg, perms, err := srv.authorizedGroup(form)
+	if err != nil {
+		return nil, err
+	}
+	sg.Ingress = append(sg.Ingress, perms...)
+	return returnTrue(), nil
+}
+
+func (srv *Server) authorizeSecurityGroupEgress(form url.Values) ([]node, *awsError) {
+	sg, perms, err := srv.authorizedGroup(form)
+	if err != nil {
+		return nil, err
+	}
+	sg.Egress = append(sg.Egress, perms...)
+	return returnTrue(), nil
+}
+
+func (srv *Server) addressNode(addr *address) node {
+	n := item(
+		txt("publicIp", addr.PublicIP),
+		txt("allocationId", addr.AllocationID),
+		txt("domain", "vpc"),
+		tagSet(addr.Tags),
+	)
+	if ni, ok := srv.interfaces[addr.InterfaceID]; ok {
+		n.Children = append(n.Children,
+			txt("associationId", addr.AssociationID),
+			txt("networkInterfaceId", ni.ID),
+			txt("networkInterfaceOwnerId", ownerID),
+			txt("privateIpAddress", ni.PrivateIP),
+		)
+		if ni.InstanceID != "" {
+			n.Children = append(n.Children, txt("instanceId", ni.InstanceID))
+		}
+	}
+	return n
+}
+
+//addressOf returns the elastic IP associated to a network interface
+func (srv *Server) addressOf(niID string) *address {
+	for _, addr := range srv.addresses {
+		if addr.InterfaceID == niID {
+			return addr
+		}
+	}
+	return nil
+}
+
+func (srv *Server) allocateAddress(form url.Values) ([]node, *awsError) {
+	if d := form.Get("Domain"); d != "" && d != "vpc" {
+		return nil, newError(http.StatusBadRequest, "InvalidParameterValue", "Invalid value '%s' for domain.", d)
+	}
+	tags, err := tagSpecifications(form, "elastic-ip")
+	if err != nil {
+		return nil, err
+	}
+	srv.publicIPs++
+	ip, e := ipAt("203.0.113.0/24", srv.publicIPs)
+	if e != nil {
+		return nil, newError(http.StatusBadRequest, "AddressLimitExceeded", "The maximum number of addresses has been reached.")
+	}
+	addr := address{
+		AllocationID: newID("eipalloc"),
+		PublicIP:     ip,
+		Tags:         tags,
+	}
+	srv.addresses[addr.AllocationID] = &addr
+	return []node{
+		txt("publicIp", addr.PublicIP),
+		txt("domain", "vpc"),
+		txt("allocationId", addr.AllocationID),
+	}, nil
+}
+
+func (srv *Server) describeAddresses(form url.Values) ([]node, *awsError) {
+	ids := list(form, "AllocationId")
+	ips := list(form, "PublicIp")
+	exists := func(id string) bool {
+		_, ok := srv.addresses[id]
+		return ok
+	}
+	if err := checkIDs(ids, exists, "InvalidAllocationID.NotFound", "allocation ID"); err != nil {
+		return nil, err
+	}
+	fs := filters(form)
+	var keys []string
+	for id := range srv.addresses {
+		keys = append(keys, id)
+	}
+	var items []node
+	for _, id := range sortedKeys(keys) {
+		addr := srv.addresses[id]
+		attrs := map[string][]string{
+			"allocation-id": {addr.AllocationID},
+			"public-ip":     {addr.PublicIP},
+			"domain":        {"vpc"},
+		}
+		if ni, ok := srv.interfaces[addr.InterfaceID]; ok {
+			attrs["association-id"] = []string{addr.AssociationID}
+			attrs["network-interface-id"] = []string{ni.ID}
+			attrs["private-ip-address"] = []string{ni.PrivateIP}
+			attrs["instance-id"] = []string{ni.InstanceID}
+		}
+		if selected(ids, id) && selected(ips, addr.PublicIP) && match(fs, attrs) {
+			items = append(items, srv.addressNode(addr))
+		}
+	}
+	return []node{el("addressesSet", items...)}, nil
+}
+
+func (srv *Server) associateAddress(form url.Values) ([]node, *awsError) {
+	allocID, err := required(form, "AllocationId")
+	if err != nil {
+		return nil, err
+	}
+	addr, ok := srv.addresses[allocID]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidAllocationID.NotFound", "The allocation ID '%s' does not exist", allocID)
+	}
+	var ni *networkInterface
+	if niID := form.Get("NetworkInterfaceId"); niID != "" {
+		ni, ok = srv.interfaces[niID]
+		if !ok {
+			return nil, newError(http.StatusBadRequest, "InvalidNetworkInterfaceID.NotFound", "The networkInterface ID '%s' does not exist", niID)
+		}
+	} else if instanceID := form.Get("InstanceId"); instanceID != "" {
+		i, ok := srv.instances[instanceID]
+		if !ok || i.State == stateTerminated {
+			return nil, newError(http.StatusBadRequest, "InvalidInstanceID.NotFound", "The instance ID '%s' does not exist", instanceID)
+		}
+		if len(i.Interfaces) > 1 {
+			return nil, newError(http.StatusBadRequest, "InvalidInstanceID", "There are multiple interfaces attached to instance '%s'. Please specify an interface ID for the operation instead.", instanceID)
+		}
+		ni = srv.interfaces[i.Interfaces[0]]
+	} else {
+		return nil, newError(http.StatusBadRequest, "MissingParameter", "Either instance ID or network interface id must be specified")
+	}
+	attached := false
+	for _, gw := range srv.gateways {
+		if gw.VpcID == ni.VpcID {
+			attached = true
+		}
+	}
+	if !attached {
+		return nil, newError(http.StatusBadRequest, "Gateway.NotAttached", "Network %s is not attached to any internet gateway", ni.VpcID)
+	}
+	if addr.InterfaceID != "" && form.Get("AllowReassociation") != "true" {
+		return nil, newError(http.StatusBadRequest, "Resource.AlreadyAssociated", "resource %s is already associated with associate-id %s", addr.AllocationID, addr.AssociationID)
+	}
+	if other := srv.addressOf(ni.ID); other != nil && other != addr {
+		other.InterfaceID = ""
+		other.AssociationID = ""
+	}
+	addr.InterfaceID = ni.ID
+	addr.AssociationID = newID("eipassoc")
+	return []node{txt("return", "true"), txt("associationId", addr.AssociationID)}, nil
+}
+
+func (srv *Server) disassociateAddress(form url.Values) ([]node, *awsError) {
+	id, err := required(form, "AssociationId")
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range srv.addresses {
+		if addr.AssociationID == id {
+			addr.AssociationID = ""
+			addr.InterfaceID = ""
+			return returnTrue(), nil
+		}
+	}
+	return nil, newError(http.StatusBadRequest, "InvalidAssociationID.NotFound", "The association ID '%s' does not exist", id)
+}
+
+func (srv *Server) releaseAddress(form url.Values) ([]node, *awsError) {
+	id, err := required(form, "AllocationId")
+	if err != nil {
+		return nil, err
+	}
+	addr, ok := srv.addresses[id]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "InvalidAllocationID.NotFound", "The allocation ID '%s' does not exist", id)
+	}
+	if addr.InterfaceID != "" {
+		return nil, newError(http.StatusBadRequest, "InvalidIPAddress.InUse", "Address %s is in use.", addr.PublicIP)
+	}
+	delete(srv.addresses, id)
+	return returnTrue(), nil
+}
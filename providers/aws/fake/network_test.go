@@ -0,0 +1,165 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/aws"
+)
+
+//newClient returns a client of the aws driver connected to srv
+func newClient(t *testing.T, srv *Server) *aws.Client {
+	clt, err := aws.AuthenticatedClient(srv.AuthOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clt
+}
+
+//networkRequest returns the request of a network whose gateway uses the first template and image of clt
+func networkRequest(t *testing.T, clt *aws.Client, name string) api.NetworkRequest {
+	tpls, err := clt.ListTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgs, err := clt.ListImages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api.NetworkRequest{
+		Name: name,
+		CIDR: "10.0.0.0/16",
+		GWRequest: api.VMRequest{
+			Name:       name + "-gw",
+			TemplateID: tpls[0].ID,
+			ImageID:    imgs[0].ID,
+		},
+	}
+}
+
+//resources numbers of resources of a fake server
+type resources struct {
+	vpcs, subnets, gateways, routeTables, secGroups, instances, addresses, keyPairs int
+}
+
+//count returns the numbers of resources of srv, terminated instances are not counted
+func (srv *Server) count() resources {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.refresh()
+	res := resources{
+		vpcs:        len(srv.vpcs),
+		subnets:     len(srv.subnets),
+		gateways:    len(srv.gateways),
+		routeTables: len(srv.routeTables),
+		secGroups:   len(srv.secGroups),
+		addresses:   len(srv.addresses),
+		keyPairs:    len(srv.keyPairs),
+	}
+	for _, i := range srv.instances {
+		if i.State != "terminated" {
+			res.instances++
+		}
+	}
+	return res
+}
+
+func TestCreateNetwork(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+	before := srv.count()
+
+	net, err := clt.CreateNetwork(networkRequest(t, clt, "net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := srv.count()
+	if after.vpcs != before.vpcs+1 || after.subnets != before.subnets+1 || after.gateways != before.gateways+1 || after.instances != before.instances+1 {
+		t.Fatalf("expected a VPC, a subnet, an internet gateway and a gateway instance, got %+v from %+v", after, before)
+	}
+	//the default route of the VPC goes through its internet gateway
+	srv.mu.Lock()
+	igw := ""
+	for _, gw := range srv.gateways {
+		if gw.VpcID == net.ID {
+			igw = gw.ID
+		}
+	}
+	routed := false
+	for _, rt := range srv.routeTables {
+		for _, r := range rt.Routes {
+			routed = routed || (rt.VpcID == net.ID && r.Destination == "0.0.0.0/0" && r.GatewayID == igw && igw != "")
+		}
+	}
+	srv.mu.Unlock()
+	if !routed {
+		t.Fatalf("no default route through the internet gateway of %s", net.ID)
+	}
+
+	nets, err := clt.ListNetworks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 1 || nets[0].ID != net.ID || nets[0].CIDR != "10.0.0.0/16" {
+		t.Fatalf("expected %s with the CIDR 10.0.0.0/16, got %+v", net.ID, nets)
+	}
+	gw, err := clt.GetVM(net.GatewayID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gw.PrivateKey == "" || gw.AccessIPv4 == "" {
+		t.Fatalf("expected a gateway with a private key and a public IP, got %+v", gw)
+	}
+}
+
+func TestCreateNetworkRollback(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+	before := srv.count()
+
+	req := networkRequest(t, clt, "net")
+	req.GWRequest.ImageID = "ami-00000000000000000"
+	_, err := clt.CreateNetwork(req)
+	if err == nil {
+		t.Fatal("network created with an unknown image")
+	}
+	if after := srv.count(); after != before {
+		t.Fatalf("resources left behind: %+v, expected %+v", after, before)
+	}
+}
+
+func TestDeleteNetwork(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+	before := srv.count()
+
+	net, err := clt.CreateNetwork(networkRequest(t, clt, "net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = clt.DeleteNetwork(net.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := srv.count(); after != before {
+		t.Fatalf("resources left behind: %+v, expected %+v", after, before)
+	}
+	_, err = clt.GetNetwork(net.ID)
+	if api.KindOf(err) != api.ErrNotFound {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
+
+func TestGetVMNotFound(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+
+	_, err := clt.GetVM("i-0123456789abcdef0")
+	if api.KindOf(err) != api.ErrNotFound {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package fake
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SebastienDorgan/gpac/providers/aws"
+)
+
+//pricingTarget prefix of the X-Amz-Target header of Pricing requests
+const pricingTarget = "AWSPriceListService."
+
+//variant a product variant generated for each instance type
+type variant struct {
+	os       string
+	usage    string
+	tenancy  string
+	operator string
+	factor   float64
+}
+
+var variants = []variant{
+	{os: "Linux", usage: "BoxUsage:", tenancy: "Shared", operator: "RunInstances", factor: 1},
+	{os: "Windows", usage: "BoxUsage:", tenancy: "Shared", operator: "RunInstances:0002", factor: 1.8},
+	{os: "Linux", usage: "DedicatedUsage:", tenancy: "Dedicated", operator: "RunInstances", factor: 1.1},
+}
+
+//products returns the price list of the products in all the regions known by the aws driver
+func (srv *Server) products() []map[string]interface{} {
+	var regions []string
+	for r := range aws.Regions {
+		regions = append(regions, r)
+	}
+	sort.Strings(regions)
+	var res []map[string]interface{}
+	for _, region := range regions {
+		loc := aws.Regions[region]
+		for _, t := range srv.Opts.InstanceTypes {
+			for i, v := range variants {
+				sum := md5.Sum([]byte(fmt.Sprintf("%s/%s/%d", region, t.Name, i)))
+				sku := strings.ToUpper(hex.EncodeToString(sum[:]))[:16]
+				price := t.Price * v.factor
+				res = append(res, map[string]interface{}{
+					"product": map[string]interface{}{
+						"productFamily": "Compute Instance",
+						"sku":           sku,
+						"attributes": map[string]string{
+							"servicecode":     "AmazonEC2",
+							"servicename":     "Amazon Elastic Compute Cloud",
+							"location":        loc.Location,
+							"locationType":    "AWS Region",
+							"instanceType":    t.Name,
+							"instanceFamily":  "General purpose",
+							"vcpu":            strconv.Itoa(t.VCPU),
+							"memory":          strconv.FormatFloat(t.Memory, 'f', -1, 64) + " GiB",
+							"storage":         t.Storage,
+							"usagetype":       loc.UsagePrefix + v.usage + t.Name,
+							"operation":       v.operator,
+							"operatingSystem": v.os,
+							"preInstalledSw":  "NA",
+							"tenancy":         v.tenancy,
+							"licenseModel":    "No License required",
+						},
+					},
+					"serviceCode":     "AmazonEC2",
+					"publicationDate": "2018-06-01T00:00:00Z",
+					"version":         "20180601000000",
+					"terms": map[string]interface{}{
+						"OnDemand": map[string]interface{}{
+							sku + ".JRTCKXETXF": map[string]interface{}{
+								"offerTermCode": "JRTCKXETXF",
+								"sku":           sku,
+								"effectiveDate": "2018-06-01T00:00:00Z",
+								"priceDimensions": map[string]interface{}{
+									sku + ".JRTCKXETXF.6YS6EN2CT7": map[string]interface{}{
+										"rateCode":     sku + ".JRTCKXETXF.6YS6EN2CT7",
+										"unit":         "Hrs",
+										"description":  fmt.Sprintf("$%.4f per On Demand %s %s Instance Hour", price, v.os, t.Name),
+										"beginRange":   "0",
+										"endRange":     "Inf",
+										"appliesTo":    []string{},
+										"pricePerUnit": map[string]string{"USD": strconv.FormatFloat(price, 'f', 10, 64)},
+									},
+								},
+								"termAttributes": map[string]string{},
+							},
+						},
+					},
+				})
+			}
+		}
+	}
+	return res
+}
+
+//productFilter a TERM_MATCH filter of GetProducts
+type productFilter struct {
+	Field string
+	Type  string
+	Value string
+}
+
+//matches tells if the attributes of a product match the filter, field names and values are case insensitive
+func (f productFilter) matches(product map[string]interface{}) bool {
+	attrs := product["product"].(map[string]interface{})["attributes"].(map[string]string)
+	for k, v := range attrs {
+		if strings.EqualFold(k, f.Field) {
+			return strings.EqualFold(v, f.Value)
+		}
+	}
+	return false
+}
+
+func writePricingError(w http.ResponseWriter, err *awsError) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"__type":  err.Code,
+		"message": err.Message,
+	})
+}
+
+func (srv *Server) servePricing(w http.ResponseWriter, r *http.Request, target string) {
+	operation := strings.TrimPrefix(target, pricingTarget)
+	srv.record("Pricing " + operation)
+	if operation != "GetProducts" {
+		writePricingError(w, newError(http.StatusBadRequest, "UnknownOperationException", "Unknown operation %s", operation))
+		return
+	}
+	var req struct {
+		ServiceCode   string
+		FormatVersion string
+		Filters       []productFilter
+		MaxResults    int
+		NextToken     string
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if e := json.Unmarshal(body, &req); e != nil {
+		writePricingError(w, newError(http.StatusBadRequest, "SerializationException", "%s", e.Error()))
+		return
+	}
+	if req.ServiceCode != "AmazonEC2" {
+		writePricingError(w, newError(http.StatusBadRequest, "InvalidParameterException", "Input parameters are invalid. Invalid Service Code"))
+		return
+	}
+	if req.FormatVersion != "" && req.FormatVersion != "aws_v1" {
+		writePricingError(w, newError(http.StatusBadRequest, "InvalidParameterException", "Input parameters are invalid. Invalid Format Version"))
+		return
+	}
+	if req.MaxResults == 0 {
+		req.MaxResults = 100
+	}
+	if req.MaxResults < 1 || req.MaxResults > 100 {
+		writePricingError(w, newError(http.StatusBadRequest, "InvalidParameterException", "Input parameters are invalid. MaxResults must be between 1 and 100"))
+		return
+	}
+	srv.mu.Lock()
+	products := srv.products()
+	srv.mu.Unlock()
+	var selection []map[string]interface{}
+	for _, p := range products {
+		ok := true
+		for _, f := range req.Filters {
+			if f.Type != "TERM_MATCH" {
+				writePricingError(w, newError(http.StatusBadRequest, "InvalidParameterException", "Input parameters are invalid. Invalid Filter Type"))
+				return
+			}
+			//ServiceCode is not an attribute but is accepted as filter
+			if strings.EqualFold(f.Field, "ServiceCode") {
+				ok = ok && f.Value == "AmazonEC2"
+				continue
+			}
+			ok = ok && f.matches(p)
+		}
+		if ok {
+			selection = append(selection, p)
+		}
+	}
+	start := 0
+	if req.NextToken != "" {
+		n, e := strconv.Atoi(req.NextToken)
+		if e != nil || n < 0 || n > len(selection) {
+			writePricingError(w, newError(http.StatusBadRequest, "InvalidNextTokenException", "The pagination token is invalid"))
+			return
+		}
+		start = n
+	}
+	end := start + req.MaxResults
+	if end > len(selection) {
+		end = len(selection)
+	}
+	//Each entry of the price list is a JSON document serialized as a string
+	priceList := []string{}
+	for _, p := range selection[start:end] {
+		b, _ := json.Marshal(p)
+		priceList = append(priceList, string(b))
+	}
+	res := map[string]interface{}{
+		"FormatVersion": "aws_v1",
+		"PriceList":     priceList,
+	}
+	if end < len(selection) {
+		res["NextToken"] = strconv.Itoa(end)
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,554 @@
+package fake
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//s3Namespace XML namespace of S3 responses
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+type object struct {
+	Content      []byte
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+	Tags         map[string]string
+}
+
+//lifecycleRule an expiration rule of a bucket
+type lifecycleRule struct {
+	Prefix string
+	Date   time.Time
+}
+
+type bucket struct {
+	Name         string
+	CreationDate time.Time
+	Objects      map[string]*object
+	Lifecycle    []lifecycleRule
+}
+
+//s3Error an S3 error response
+type s3Error struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string
+	Message   string
+	Resource  string `xml:",omitempty"`
+	RequestID string `xml:"RequestId"`
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, err *awsError) {
+	writeXML(w, err.Status, s3Error{
+		Code:     err.Code,
+		Message:  err.Message,
+		Resource: r.URL.Path,
+	})
+}
+
+//expire removes the objects of the bucket expired by lifecycle rules
+func (b *bucket) expire() {
+	now := time.Now()
+	for _, rule := range b.Lifecycle {
+		if now.Before(rule.Date) {
+			continue
+		}
+		for name := range b.Objects {
+			if strings.HasPrefix(name, rule.Prefix) {
+				delete(b.Objects, name)
+			}
+		}
+	}
+}
+
+func (srv *Server) serveS3(w http.ResponseWriter, r *http.Request) {
+	srv.record(fmt.Sprintf("S3 %s %s", r.Method, r.URL.Path))
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	key := ""
+	if i := strings.Index(name, "/"); i >= 0 {
+		name, key = name[:i], name[i+1:]
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	var err *awsError
+	switch {
+	case name == "":
+		err = srv.listBuckets(w, r)
+	case key == "":
+		err = srv.serveBucket(w, r, name)
+	default:
+		err = srv.serveObject(w, r, name, key)
+	}
+	if err != nil {
+		writeS3Error(w, r, err)
+	}
+}
+
+func (srv *Server) listBuckets(w http.ResponseWriter, r *http.Request) *awsError {
+	if r.Method != "GET" {
+		return newError(http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+	var names []string
+	for name := range srv.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	type bucketEntry struct {
+		Name         string
+		CreationDate string
+	}
+	res := struct {
+		XMLName xml.Name      `xml:"ListAllMyBucketsResult"`
+		Xmlns   string        `xml:"xmlns,attr"`
+		OwnerID string        `xml:"Owner>ID"`
+		Buckets []bucketEntry `xml:"Buckets>Bucket"`
+	}{
+		Xmlns:   s3Namespace,
+		OwnerID: ownerID,
+	}
+	for _, name := range names {
+		res.Buckets = append(res.Buckets, bucketEntry{
+			Name:         name,
+			CreationDate: timeText(srv.buckets[name].CreationDate),
+		})
+	}
+	writeXML(w, http.StatusOK, res)
+	return nil
+}
+
+func (srv *Server) serveBucket(w http.ResponseWriter, r *http.Request, name string) *awsError {
+	b, exists := srv.buckets[name]
+	if r.Method == "PUT" && !isSubresource(r, "lifecycle") {
+		return srv.createBucket(w, r, name)
+	}
+	if !exists {
+		return newError(http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	}
+	b.expire()
+	switch r.Method {
+	case "PUT":
+		return putLifecycle(w, r, b)
+	case "DELETE":
+		if len(b.Objects) > 0 {
+			return newError(http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty")
+		}
+		delete(srv.buckets, name)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	case "HEAD":
+		w.WriteHeader(http.StatusOK)
+		return nil
+	case "GET":
+		return listObjects(w, r, b)
+	}
+	return newError(http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+}
+
+//isSubresource tells if the request targets the given sub resource (?lifecycle, ?tagging ...)
+func isSubresource(r *http.Request, name string) bool {
+	_, ok := r.URL.Query()[name]
+	return ok
+}
+
+func (srv *Server) createBucket(w http.ResponseWriter, r *http.Request, name string) *awsError {
+	if _, ok := srv.buckets[name]; ok {
+		return newError(http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.")
+	}
+	if len(name) < 3 || len(name) > 63 || strings.ToLower(name) != name {
+		return newError(http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid.")
+	}
+	var conf struct {
+		LocationConstraint string
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if len(bytes.TrimSpace(body)) > 0 {
+		if e := xml.Unmarshal(body, &conf); e != nil {
+			return newError(http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+		}
+	}
+	//us-east-1 is the default location and cannot be given as location constraint
+	location := conf.LocationConstraint
+	if location == "" {
+		location = "us-east-1"
+	}
+	if conf.LocationConstraint == "us-east-1" || location != srv.Opts.Region {
+		return newError(http.StatusBadRequest, "InvalidLocationConstraint", "The specified location-constraint is not valid")
+	}
+	srv.buckets[name] = &bucket{
+		Name:         name,
+		CreationDate: time.Now(),
+		Objects:      make(map[string]*object),
+	}
+	w.Header().Set("Location", "/"+name)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func putLifecycle(w http.ResponseWriter, r *http.Request, b *bucket) *awsError {
+	if r.Header.Get("Content-MD5") == "" {
+		return newError(http.StatusBadRequest, "InvalidRequest", "Missing required header for this request: Content-MD5")
+	}
+	var conf struct {
+		Rules []struct {
+			Prefix     string
+			Status     string
+			Expiration struct {
+				Date string
+				Days int
+			}
+		} `xml:"Rule"`
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if e := xml.Unmarshal(body, &conf); e != nil || len(conf.Rules) == 0 {
+		return newError(http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	//A lifecycle configuration replaces the previous one
+	var rules []lifecycleRule
+	for _, rule := range conf.Rules {
+		if rule.Status != "Enabled" {
+			continue
+		}
+		var date time.Time
+		if rule.Expiration.Date != "" {
+			d, e := time.Parse(time.RFC3339, rule.Expiration.Date)
+			if e != nil || !d.UTC().Truncate(24*time.Hour).Equal(d) {
+				return newError(http.StatusBadRequest, "InvalidArgument", "'Date' must be at midnight GMT")
+			}
+			date = d
+		} else if rule.Expiration.Days > 0 {
+			date = time.Now().Add(time.Duration(rule.Expiration.Days) * 24 * time.Hour)
+		} else {
+			return newError(http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+		}
+		rules = append(rules, lifecycleRule{Prefix: rule.Prefix, Date: date})
+	}
+	b.Lifecycle = rules
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func listObjects(w http.ResponseWriter, r *http.Request, b *bucket) *awsError {
+	q := r.URL.Query()
+	if q.Get("list-type") != "2" {
+		return newError(http.StatusNotImplemented, "NotImplemented", "Only ListObjectsV2 is supported")
+	}
+	prefix := q.Get("prefix")
+	maxKeys := 1000
+	if m := q.Get("max-keys"); m != "" {
+		n, e := strconv.Atoi(m)
+		if e != nil || n < 0 {
+			return newError(http.StatusBadRequest, "InvalidArgument", "Provided max-keys not an integer or within integer range")
+		}
+		maxKeys = n
+	}
+	var keys []string
+	for key := range b.Objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	start := 0
+	if token := q.Get("continuation-token"); token != "" {
+		start = sort.SearchStrings(keys, token)
+	}
+	end := len(keys)
+	if start+maxKeys < end {
+		end = start + maxKeys
+	}
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+		StorageClass string
+	}
+	res := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Xmlns                 string   `xml:"xmlns,attr"`
+		Name                  string
+		Prefix                string
+		KeyCount              int
+		MaxKeys               int
+		IsTruncated           bool
+		ContinuationToken     string    `xml:",omitempty"`
+		NextContinuationToken string    `xml:",omitempty"`
+		Contents              []content `xml:"Contents"`
+	}{
+		Xmlns:             s3Namespace,
+		Name:              b.Name,
+		Prefix:            prefix,
+		KeyCount:          end - start,
+		MaxKeys:           maxKeys,
+		IsTruncated:       end < len(keys),
+		ContinuationToken: q.Get("continuation-token"),
+	}
+	if res.IsTruncated {
+		res.NextContinuationToken = keys[end]
+	}
+	for _, key := range keys[start:end] {
+		o := b.Objects[key]
+		res.Contents = append(res.Contents, content{
+			Key:          key,
+			LastModified: timeText(o.LastModified),
+			ETag:         o.ETag,
+			Size:         len(o.Content),
+			StorageClass: "STANDARD",
+		})
+	}
+	writeXML(w, http.StatusOK, res)
+	return nil
+}
+
+func (srv *Server) serveObject(w http.ResponseWriter, r *http.Request, name, key string) *awsError {
+	b, ok := srv.buckets[name]
+	if !ok {
+		return newError(http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	}
+	b.expire()
+	if r.Method == "PUT" && r.Header.Get("X-Amz-Copy-Source") != "" {
+		return srv.copyObject(w, r, b, key)
+	}
+	if r.Method == "PUT" && !isSubresource(r, "tagging") {
+		return putObject(w, r, b, key)
+	}
+	if r.Method == "DELETE" {
+		delete(b.Objects, key)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	o, ok := b.Objects[key]
+	if !ok {
+		return newError(http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+	}
+	if isSubresource(r, "tagging") {
+		return serveTagging(w, r, o)
+	}
+	switch r.Method {
+	case "GET", "HEAD":
+		writeObject(w, r, o)
+		return nil
+	}
+	return newError(http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+}
+
+//parseTags parses tags encoded as URL query parameters
+func parseTags(s string) (map[string]string, *awsError) {
+	values, e := url.ParseQuery(s)
+	if e != nil {
+		return nil, newError(http.StatusBadRequest, "InvalidArgument", "The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+	}
+	tags := map[string]string{}
+	for k, v := range values {
+		if len(v) > 1 {
+			return nil, newError(http.StatusBadRequest, "InvalidTag", "Cannot provide multiple Tags with the same key")
+		}
+		tags[k] = v[0]
+	}
+	if len(tags) > 10 {
+		return nil, newError(http.StatusBadRequest, "BadRequest", "Object tags cannot be greater than 10")
+	}
+	return tags, nil
+}
+
+func etag(content []byte) string {
+	sum := md5.Sum(content)
+	return "\"" + hex.EncodeToString(sum[:]) + "\""
+}
+
+func putObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) *awsError {
+	tags, err := parseTags(r.Header.Get("X-Amz-Tagging"))
+	if err != nil {
+		return err
+	}
+	content, e := ioutil.ReadAll(r.Body)
+	if e != nil {
+		return newError(http.StatusBadRequest, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header")
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "binary/octet-stream"
+	}
+	o := object{
+		Content:      content,
+		ContentType:  contentType,
+		ETag:         etag(content),
+		LastModified: time.Now(),
+		Tags:         tags,
+	}
+	b.Objects[key] = &o
+	w.Header().Set("ETag", o.ETag)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (srv *Server) copyObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) *awsError {
+	source, e := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if e != nil {
+		return newError(http.StatusBadRequest, "InvalidArgument", "Invalid copy source encoding")
+	}
+	source = strings.TrimPrefix(source, "/")
+	i := strings.Index(source, "/")
+	if i < 0 {
+		return newError(http.StatusBadRequest, "InvalidArgument", "Invalid copy source object key")
+	}
+	srcBucket, ok := srv.buckets[source[:i]]
+	if !ok {
+		return newError(http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	}
+	src, ok := srcBucket.Objects[source[i+1:]]
+	if !ok {
+		return newError(http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+	}
+	if srcBucket == b && source[i+1:] == key && r.Header.Get("X-Amz-Metadata-Directive") != "REPLACE" {
+		return newError(http.StatusBadRequest, "InvalidRequest", "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes.")
+	}
+	tags := map[string]string{}
+	for k, v := range src.Tags {
+		tags[k] = v
+	}
+	o := object{
+		Content:      append([]byte(nil), src.Content...),
+		ContentType:  src.ContentType,
+		ETag:         src.ETag,
+		LastModified: time.Now(),
+		Tags:         tags,
+	}
+	b.Objects[key] = &o
+	res := struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		LastModified string
+		ETag         string
+	}{
+		LastModified: timeText(o.LastModified),
+		ETag:         o.ETag,
+	}
+	writeXML(w, http.StatusOK, res)
+	return nil
+}
+
+//tagging XML representation of object tags
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Tags    []struct {
+		Key   string
+		Value string
+	} `xml:"TagSet>Tag"`
+}
+
+func serveTagging(w http.ResponseWriter, r *http.Request, o *object) *awsError {
+	switch r.Method {
+	case "GET":
+		res := tagging{}
+		var keys []string
+		for k := range o.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			res.Tags = append(res.Tags, struct {
+				Key   string
+				Value string
+			}{k, o.Tags[k]})
+		}
+		writeXML(w, http.StatusOK, res)
+		return nil
+	case "PUT":
+		var req tagging
+		body, _ := ioutil.ReadAll(r.Body)
+		if e := xml.Unmarshal(body, &req); e != nil {
+			return newError(http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+		}
+		if len(req.Tags) > 10 {
+			return newError(http.StatusBadRequest, "BadRequest", "Object tags cannot be greater than 10")
+		}
+		tags := map[string]string{}
+		for _, t := range req.Tags {
+			tags[t.Key] = t.Value
+		}
+		o.Tags = tags
+		w.WriteHeader(http.StatusOK)
+		return nil
+	case "DELETE":
+		o.Tags = map[string]string{}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return newError(http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+}
+
+//byteRange returns the first and last byte of a single range "bytes=first-last", ok is false when the range is ignored
+func byteRange(header string, size int) (first int, last int, ok bool) {
+	if !strings.HasPrefix(header, "bytes=") || strings.Contains(header, ",") {
+		return 0, 0, false
+	}
+	spec := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(spec) != 2 {
+		return 0, 0, false
+	}
+	if spec[0] == "" {
+		n, e := strconv.Atoi(spec[1])
+		if e != nil {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+	first, e := strconv.Atoi(spec[0])
+	if e != nil {
+		return 0, 0, false
+	}
+	last = size - 1
+	if spec[1] != "" {
+		last, e = strconv.Atoi(spec[1])
+		if e != nil {
+			return 0, 0, false
+		}
+		if last > size-1 {
+			last = size - 1
+		}
+	}
+	return first, last, true
+}
+
+func writeObject(w http.ResponseWriter, r *http.Request, o *object) {
+	h := w.Header()
+	h.Set("Content-Type", o.ContentType)
+	h.Set("ETag", o.ETag)
+	h.Set("Last-Modified", o.LastModified.UTC().Format(http.TimeFormat))
+	h.Set("Accept-Ranges", "bytes")
+	h.Set("X-Amz-Tagging-Count", strconv.Itoa(len(o.Tags)))
+	content := o.Content
+	status := http.StatusOK
+	//Multiple ranges and malformed ranges are ignored as S3 does
+	if first, last, ok := byteRange(r.Header.Get("Range"), len(o.Content)); ok {
+		if first > last || first >= len(o.Content) {
+			h.Set("Content-Type", "application/xml")
+			writeXML(w, http.StatusRequestedRangeNotSatisfiable, s3Error{
+				Code:    "InvalidRange",
+				Message: "The requested range is not satisfiable",
+			})
+			return
+		}
+		content = o.Content[first : last+1]
+		status = http.StatusPartialContent
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(o.Content)))
+	}
+	h.Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(status)
+	if r.Method == "GET" {
+		w.Write(content)
+	}
+}
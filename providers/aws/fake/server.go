@@ -0,0 +1,218 @@
+//Package fake provides an in-process stand-in of the EC2, S3 and Pricing APIs used by the aws driver
+//to test the driver without an AWS account.
+//All services are served on the same endpoint: Pricing requests are recognized by their X-Amz-Target header,
+//EC2 requests by their Action form value, everything else is handled as path style S3 requests.
+package fake
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/aws"
+	uuid "github.com/satori/go.uuid"
+)
+
+//Image an Amazon Machine Image exposed by the fake
+type Image struct {
+	ID          string
+	Name        string
+	Description string
+	OwnerID     string
+}
+
+//InstanceType an instance type exposed by the fake
+type InstanceType struct {
+	Name string
+	VCPU int
+	//Memory in GiB
+	Memory float64
+	//Storage as written in price lists ("EBS only", "1 x 75 NVMe SSD" ...)
+	Storage string
+	//Price on demand price in USD per hour
+	Price float64
+	//Regions regions where the instance type is offered, the instance type is offered in all regions if empty
+	Regions []string
+}
+
+//Options options of the fake server
+type Options struct {
+	//Region region of the client
+	Region string
+	//AccessKeyID access key accepted by the server, any access key is accepted if empty
+	AccessKeyID string
+	//BuildDelay time spent by instances and volumes in transient states
+	BuildDelay time.Duration
+	//Images images exposed by EC2, DefaultImages is used if empty
+	Images []Image
+	//InstanceTypes instance types exposed by EC2 and Pricing, DefaultInstanceTypes is used if empty
+	InstanceTypes []InstanceType
+}
+
+//DefaultImages images exposed when Options.Images is empty
+var DefaultImages = []Image{
+	{ID: "ami-0552e3455b9bc8d50", Name: "ubuntu/images/hvm-ssd/ubuntu-xenial-16.04-amd64-server-20180522", Description: "Canonical, Ubuntu, 16.04 LTS", OwnerID: "099720109477"},
+	{ID: "ami-0f65671a86f061fcd", Name: "ubuntu/images/hvm-ssd/ubuntu-bionic-18.04-amd64-server-20180617", Description: "Canonical, Ubuntu, 18.04 LTS", OwnerID: "099720109477"},
+	{ID: "ami-0b8d1c6d3e4f5a6b7", Name: "debian-stretch-hvm-x86_64-gp2-2018-06-13", Description: "Debian stretch", OwnerID: "379101102735"},
+	{ID: "ami-0c2d3e4f5a6b7c8d9", Name: "CentOS Linux 7 x86_64 HVM EBS ENA 1805_01", Description: "CentOS Linux 7", OwnerID: "057448758665"},
+}
+
+//DefaultInstanceTypes instance types exposed when Options.InstanceTypes is empty
+var DefaultInstanceTypes = []InstanceType{
+	{Name: "t2.micro", VCPU: 1, Memory: 1, Storage: "EBS only", Price: 0.0116},
+	{Name: "t2.medium", VCPU: 2, Memory: 4, Storage: "EBS only", Price: 0.0464},
+	{Name: "m5.large", VCPU: 2, Memory: 8, Storage: "EBS only", Price: 0.096},
+	{Name: "c5d.large", VCPU: 2, Memory: 4, Storage: "1 x 50 NVMe SSD", Price: 0.096},
+	{Name: "r5d.xlarge", VCPU: 4, Memory: 32, Storage: "1 x 150 NVMe SSD", Price: 0.288},
+}
+
+//Server fake AWS endpoint listening on a local HTTP endpoint
+type Server struct {
+	*httptest.Server
+	Opts Options
+
+	mu       sync.Mutex
+	requests []string
+
+	keyPairs    map[string]*keyPair
+	vpcs        map[string]*vpc
+	subnets     map[string]*subnet
+	gateways    map[string]*internetGateway
+	routeTables map[string]*routeTable
+	secGroups   map[string]*securityGroup
+	instances   map[string]*instance
+	interfaces  map[string]*networkInterface
+	addresses   map[string]*address
+	volumes     map[string]*volume
+	buckets     map[string]*bucket
+	publicIPs   int
+	//held state changes are held until Release is called
+	held bool
+	//OnHandled is called once an EC2 action is handled, before its response is written
+	OnHandled func(action string)
+}
+
+//NewServer starts a fake AWS endpoint
+func NewServer(opts Options) *Server {
+	if opts.Region == "" {
+		opts.Region = "us-east-2"
+	}
+	if len(opts.Images) == 0 {
+		opts.Images = DefaultImages
+	}
+	if len(opts.InstanceTypes) == 0 {
+		opts.InstanceTypes = DefaultInstanceTypes
+	}
+	srv := &Server{
+		Opts:        opts,
+		keyPairs:    make(map[string]*keyPair),
+		vpcs:        make(map[string]*vpc),
+		subnets:     make(map[string]*subnet),
+		gateways:    make(map[string]*internetGateway),
+		routeTables: make(map[string]*routeTable),
+		secGroups:   make(map[string]*securityGroup),
+		instances:   make(map[string]*instance),
+		interfaces:  make(map[string]*networkInterface),
+		addresses:   make(map[string]*address),
+		volumes:     make(map[string]*volume),
+		buckets:     make(map[string]*bucket),
+	}
+	srv.Server = httptest.NewServer(http.HandlerFunc(srv.serveHTTP))
+	return srv
+}
+
+//AuthOpts returns aws.AuthOpts targeting the fake endpoint
+func (srv *Server) AuthOpts() aws.AuthOpts {
+	accessKey := srv.Opts.AccessKeyID
+	if accessKey == "" {
+		accessKey = "AKIAFAKE"
+	}
+	return aws.AuthOpts{
+		AccessKeyID:     accessKey,
+		SecretAccessKey: "secret",
+		Region:          srv.Opts.Region,
+		Endpoint:        srv.URL,
+	}
+}
+
+//Requests returns the list of requests received by the server
+//EC2 and Pricing requests are formatted as "EC2 Action" and "Pricing Operation", S3 requests as "S3 METHOD /path"
+func (srv *Server) Requests() []string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return append([]string(nil), srv.requests...)
+}
+
+//Count returns the number of requests received by the server starting with prefix
+func (srv *Server) Count(prefix string) int {
+	n := 0
+	for _, r := range srv.Requests() {
+		if strings.HasPrefix(r, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+//newID generates an AWS like resource identifier
+func newID(prefix string) string {
+	hex := strings.Replace(uuid.NewV4().String(), "-", "", -1)
+	return fmt.Sprintf("%s-%s", prefix, hex[:17])
+}
+
+//awsError an error returned by the fake services
+type awsError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *awsError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func newError(status int, code string, format string, args ...interface{}) *awsError {
+	return &awsError{
+		Status:  status,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+//writeXML writes v as an XML response
+func writeXML(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	w.WriteHeader(code)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(v)
+}
+
+func (srv *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if srv.Opts.AccessKeyID != "" && !strings.Contains(r.Header.Get("Authorization"), "Credential="+srv.Opts.AccessKeyID+"/") {
+		writeXML(w, http.StatusForbidden, s3Error{
+			Code:    "InvalidAccessKeyId",
+			Message: "The AWS Access Key Id you provided does not exist in our records.",
+		})
+		return
+	}
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		srv.servePricing(w, r, target)
+		return
+	}
+	if r.Method == "POST" && r.URL.Path == "/" {
+		srv.serveEC2(w, r)
+		return
+	}
+	srv.serveS3(w, r)
+}
+
+//record records a request
+func (srv *Server) record(req string) {
+	srv.mu.Lock()
+	srv.requests = append(srv.requests, req)
+	srv.mu.Unlock()
+}
@@ -0,0 +1,273 @@
+package aws
+
+import (
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+//liveStates states of the instances that are not terminated
+var liveStates = []string{"pending", "running", "shutting-down", "stopping", "stopped"}
+
+//liveInstances returns the IDs of the instances that are not terminated
+func (c *Client) liveInstances() (map[string]bool, error) {
+	input := ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: aws.StringSlice(liveStates),
+			},
+		},
+	}
+	ids := map[string]bool{}
+	err := c.EC2.DescribeInstancesPages(&input, func(out *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, r := range out.Reservations {
+			for _, instance := range r.Instances {
+				ids[pStr(instance.InstanceId)] = true
+			}
+		}
+		return true
+	})
+	return ids, err
+}
+
+//CreatedTag EC2 tag marking the resources created by gpac, its value is the creation time in RFC 3339 format
+//Resources without this tag are never orphans
+const CreatedTag = "gpac:created"
+
+//createdTags returns the tag specifications marking a resource of type resourceType as created now by gpac
+func createdTags(resourceType string) []*ec2.TagSpecification {
+	return []*ec2.TagSpecification{
+		{
+			ResourceType: aws.String(resourceType),
+			Tags: []*ec2.Tag{
+				{
+					Key:   aws.String(CreatedTag),
+					Value: aws.String(time.Now().UTC().Format(time.RFC3339)),
+				},
+			},
+		},
+	}
+}
+
+//createdAt returns the creation time recorded in tags, ok is false if the resource was not created by gpac
+func createdAt(tags []*ec2.Tag) (time.Time, bool) {
+	for _, t := range tags {
+		if pStr(t.Key) != CreatedTag {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, pStr(t.Value))
+		if err != nil {
+			return time.Time{}, false
+		}
+		return created, true
+	}
+	return time.Time{}, false
+}
+
+//records returns the keys of bucket
+func (c *Client) records(bucket string) ([]string, map[string]bool, error) {
+	keys, err := c.metadata.List(bucket, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	res := map[string]bool{}
+	for _, k := range keys {
+		res[k] = true
+	}
+	return keys, res, nil
+}
+
+//Orphans lists the resources created by gpac and leaked by failed operations: Elastic IPs not associated, temporary
+//key pairs, security groups not used by any network interface, VPCs (and their subnets) without network record,
+//available volumes without volume record and records of the VMs, networks and volumes that do not exist anymore
+//Only the resources tagged with CreatedTag are considered
+func (c *Client) Orphans() ([]providers.Orphan, error) {
+	orphans := []providers.Orphan{}
+	//records are listed first, the records of the resources created meanwhile are not seen
+	vmKeys, _, err := c.records(vmsBucket)
+	if err != nil {
+		return nil, wrapError("Error listing orphans", err)
+	}
+	networkKeys, networks, err := c.records(networksBucket)
+	if err != nil {
+		return nil, wrapError("Error listing orphans", err)
+	}
+	volumeKeys, volumes, err := c.records(volumesBucket)
+	if err != nil {
+		return nil, wrapError("Error listing orphans", err)
+	}
+	instances, err := c.liveInstances()
+	if err != nil {
+		return nil, wrapError("Error listing orphans", err)
+	}
+
+	addrs, err := c.EC2.DescribeAddresses(&ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("domain"),
+				Values: []*string{aws.String("vpc")},
+			},
+		},
+	})
+	if err != nil {
+		return nil, wrapError("Error listing orphans", err)
+	}
+	for _, addr := range addrs.Addresses {
+		created, ok := createdAt(addr.Tags)
+		if ok && addr.AssociationId == nil {
+			orphans = append(orphans, providers.Orphan{
+				Kind:    providers.OrphanAddress,
+				ID:      pStr(addr.AllocationId),
+				Name:    pStr(addr.PublicIp),
+				Reason:  "Elastic IP not associated",
+				Created: created,
+			})
+		}
+	}
+
+	kps, err := c.EC2.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{})
+	if err != nil {
+		return nil, wrapError("Error listing orphans", err)
+	}
+	for _, kp := range kps.KeyPairs {
+		created, ok := createdAt(kp.Tags)
+		if ok && providers.TemporaryKeyPair(pStr(kp.KeyName)) {
+			orphans = append(orphans, providers.Orphan{
+				Kind:    providers.OrphanKeyPair,
+				ID:      pStr(kp.KeyName),
+				Reason:  "temporary key pair of a VM creation",
+				Created: created,
+			})
+		}
+	}
+
+	vpcs, err := c.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{})
+	if err != nil {
+		return nil, wrapError("Error listing orphans", err)
+	}
+	var orphanVpcs []*ec2.Vpc
+	for _, vpc := range vpcs.Vpcs {
+		if _, ok := createdAt(vpc.Tags); ok && !networks[pStr(vpc.VpcId)] {
+			orphanVpcs = append(orphanVpcs, vpc)
+		}
+	}
+
+	nifs, err := c.EC2.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{})
+	if err != nil {
+		return nil, wrapError("Error listing orphans", err)
+	}
+	used := map[string]bool{}
+	for _, nif := range nifs.NetworkInterfaces {
+		for _, g := range nif.Groups {
+			used[pStr(g.GroupId)] = true
+		}
+	}
+	sgs, err := c.EC2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{})
+	if err != nil {
+		return nil, wrapError("Error listing orphans", err)
+	}
+	for _, sg := range sgs.SecurityGroups {
+		created, ok := createdAt(sg.Tags)
+		if !ok || used[pStr(sg.GroupId)] {
+			continue
+		}
+		orphans = append(orphans, providers.Orphan{
+			Kind:    providers.OrphanSecurityGroup,
+			ID:      pStr(sg.GroupId),
+			Name:    pStr(sg.GroupName),
+			Reason:  "not used by any network interface",
+			Created: created,
+		})
+	}
+
+	for _, vpc := range orphanVpcs {
+		sns, err := c.getSubnets([]string{pStr(vpc.VpcId)})
+		if err != nil {
+			return nil, wrapError("Error listing orphans", err)
+		}
+		for _, sn := range sns {
+			created, ok := createdAt(sn.Tags)
+			if !ok {
+				continue
+			}
+			orphans = append(orphans, providers.Orphan{
+				Kind:    providers.OrphanSubnet,
+				ID:      pStr(sn.SubnetId),
+				Name:    pStr(sn.CidrBlock),
+				Reason:  "subnet of VPC " + pStr(vpc.VpcId) + " without network record",
+				Created: created,
+			})
+		}
+	}
+	for _, vpc := range orphanVpcs {
+		created, _ := createdAt(vpc.Tags)
+		orphans = append(orphans, providers.Orphan{
+			Kind:    providers.OrphanNetwork,
+			ID:      pStr(vpc.VpcId),
+			Name:    pStr(vpc.CidrBlock),
+			Reason:  "VPC without network record",
+			Created: created,
+		})
+	}
+
+	vols, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{})
+	if err != nil {
+		return nil, wrapError("Error listing orphans", err)
+	}
+	existing := map[string]bool{}
+	for _, v := range vols.Volumes {
+		existing[pStr(v.VolumeId)] = true
+		//root volumes of the instances are not tagged, they are attached
+		created, ok := createdAt(v.Tags)
+		if ok && pStr(v.State) == ec2.VolumeStateAvailable && !volumes[pStr(v.VolumeId)] {
+			orphans = append(orphans, providers.Orphan{
+				Kind:    providers.OrphanVolume,
+				ID:      pStr(v.VolumeId),
+				Reason:  "available volume without volume record",
+				Created: created,
+			})
+		}
+	}
+
+	vpcIDs := map[string]bool{}
+	for _, vpc := range vpcs.Vpcs {
+		vpcIDs[pStr(vpc.VpcId)] = true
+	}
+	orphans = append(orphans, providers.MetadataOrphans(c.metadata, vmsBucket, vmKeys, instances, "VM record of a terminated instance")...)
+	orphans = append(orphans, providers.MetadataOrphans(c.metadata, networksBucket, networkKeys, vpcIDs, "network record of a deleted VPC")...)
+	orphans = append(orphans, providers.MetadataOrphans(c.metadata, volumesBucket, volumeKeys, existing, "volume record of a deleted volume")...)
+	return orphans, nil
+}
+
+//DeleteOrphan deletes an orphan returned by Orphans, the internet gateway of an orphaned VPC is deleted with it
+func (c *Client) DeleteOrphan(o providers.Orphan) error {
+	var err error
+	switch o.Kind {
+	case providers.OrphanAddress:
+		_, err = c.EC2.ReleaseAddress(&ec2.ReleaseAddressInput{
+			AllocationId: aws.String(o.ID),
+		})
+	case providers.OrphanKeyPair:
+		return c.DeleteKeyPair(o.ID)
+	case providers.OrphanSecurityGroup:
+		_, err = c.EC2.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+			GroupId: aws.String(o.ID),
+		})
+	case providers.OrphanSubnet:
+		_, err = c.EC2.DeleteSubnet(&ec2.DeleteSubnetInput{
+			SubnetId: aws.String(o.ID),
+		})
+	case providers.OrphanNetwork:
+		return c.DeleteNetwork(o.ID)
+	case providers.OrphanVolume:
+		return c.DeleteVolume(o.ID)
+	case providers.OrphanMetadata:
+		return providers.DeleteMetadataOrphan(c.metadata, o)
+	default:
+		return providers.UnknownOrphanError(o)
+	}
+	return wrapError("Error deleting orphan "+o.ID, err)
+}
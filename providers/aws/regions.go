@@ -0,0 +1,62 @@
+package aws
+
+import (
+	"fmt"
+)
+
+//RegionInfo pricing information of an AWS region
+type RegionInfo struct {
+	//Location name of the region in the price lists
+	Location string
+	//UsagePrefix prefix of the usage types of the region in the price lists
+	UsagePrefix string
+}
+
+//Regions pricing information of AWS regions
+var Regions = map[string]RegionInfo{
+	"us-east-1":      {Location: "US East (N. Virginia)", UsagePrefix: ""},
+	"us-east-2":      {Location: "US East (Ohio)", UsagePrefix: "USE2-"},
+	"us-west-1":      {Location: "US West (N. California)", UsagePrefix: "USW1-"},
+	"us-west-2":      {Location: "US West (Oregon)", UsagePrefix: "USW2-"},
+	"ca-central-1":   {Location: "Canada (Central)", UsagePrefix: "CAN1-"},
+	"ca-west-1":      {Location: "Canada West (Calgary)", UsagePrefix: "CAN2-"},
+	"sa-east-1":      {Location: "South America (Sao Paulo)", UsagePrefix: "SAE1-"},
+	"eu-west-1":      {Location: "EU (Ireland)", UsagePrefix: "EU-"},
+	"eu-west-2":      {Location: "EU (London)", UsagePrefix: "EUW2-"},
+	"eu-west-3":      {Location: "EU (Paris)", UsagePrefix: "EUW3-"},
+	"eu-central-1":   {Location: "EU (Frankfurt)", UsagePrefix: "EUC1-"},
+	"eu-central-2":   {Location: "EU (Zurich)", UsagePrefix: "EUC2-"},
+	"eu-north-1":     {Location: "EU (Stockholm)", UsagePrefix: "EUN1-"},
+	"eu-south-1":     {Location: "EU (Milan)", UsagePrefix: "EUS1-"},
+	"eu-south-2":     {Location: "EU (Spain)", UsagePrefix: "EUS2-"},
+	"ap-east-1":      {Location: "Asia Pacific (Hong Kong)", UsagePrefix: "APE1-"},
+	"ap-northeast-1": {Location: "Asia Pacific (Tokyo)", UsagePrefix: "APN1-"},
+	"ap-northeast-2": {Location: "Asia Pacific (Seoul)", UsagePrefix: "APN2-"},
+	"ap-northeast-3": {Location: "Asia Pacific (Osaka-Local)", UsagePrefix: "APN3-"},
+	"ap-southeast-1": {Location: "Asia Pacific (Singapore)", UsagePrefix: "APS1-"},
+	"ap-southeast-2": {Location: "Asia Pacific (Sydney)", UsagePrefix: "APS2-"},
+	"ap-southeast-3": {Location: "Asia Pacific (Jakarta)", UsagePrefix: "APS4-"},
+	"ap-southeast-4": {Location: "Asia Pacific (Melbourne)", UsagePrefix: "APS6-"},
+	"ap-south-1":     {Location: "Asia Pacific (Mumbai)", UsagePrefix: "APS3-"},
+	"ap-south-2":     {Location: "Asia Pacific (Hyderabad)", UsagePrefix: "APS5-"},
+	"me-south-1":     {Location: "Middle East (Bahrain)", UsagePrefix: "MES1-"},
+	"me-central-1":   {Location: "Middle East (UAE)", UsagePrefix: "MEC1-"},
+	"il-central-1":   {Location: "Israel (Tel Aviv)", UsagePrefix: "ILC1-"},
+	"af-south-1":     {Location: "Africa (Cape Town)", UsagePrefix: "AFS1-"},
+	"us-gov-west-1":  {Location: "AWS GovCloud (US-West)", UsagePrefix: "UGW1-"},
+	"us-gov-east-1":  {Location: "AWS GovCloud (US-East)", UsagePrefix: "UGE1-"},
+}
+
+//GetRegionInfo returns the pricing information of region
+func GetRegionInfo(region string) (*RegionInfo, error) {
+	info, ok := Regions[region]
+	if !ok {
+		return nil, fmt.Errorf("Error getting region information: unknown region %s", region)
+	}
+	return &info, nil
+}
+
+//BoxUsage returns the usage type of on demand shared instances of type instanceType in the region
+func (r *RegionInfo) BoxUsage(instanceType string) string {
+	return r.UsagePrefix + "BoxUsage:" + instanceType
+}
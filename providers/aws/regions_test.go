@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestGetRegionInfo(t *testing.T) {
+	cases := []struct {
+		region   string
+		location string
+		usage    string
+	}{
+		//the usage types of us-east-1 have no prefix
+		{"us-east-1", "US East (N. Virginia)", "BoxUsage:t2.micro"},
+		{"us-east-2", "US East (Ohio)", "USE2-BoxUsage:t2.micro"},
+		{"eu-west-1", "EU (Ireland)", "EU-BoxUsage:t2.micro"},
+		{"eu-west-3", "EU (Paris)", "EUW3-BoxUsage:t2.micro"},
+		{"ap-southeast-3", "Asia Pacific (Jakarta)", "APS4-BoxUsage:t2.micro"},
+		{"ap-south-1", "Asia Pacific (Mumbai)", "APS3-BoxUsage:t2.micro"},
+		{"us-gov-west-1", "AWS GovCloud (US-West)", "UGW1-BoxUsage:t2.micro"},
+	}
+	for _, c := range cases {
+		info, err := GetRegionInfo(c.region)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Location != c.location || info.BoxUsage("t2.micro") != c.usage {
+			t.Errorf("%s: expected %s and %s, got %s and %s", c.region, c.location, c.usage, info.Location, info.BoxUsage("t2.micro"))
+		}
+	}
+	_, err := GetRegionInfo("mars-north-1")
+	if err == nil {
+		t.Fatal("unknown region found")
+	}
+}
+
+//priceDocument returns the price document of an instance type with the usage type usage
+func priceDocument(usage string) aws.JSONValue {
+	return aws.JSONValue{
+		"product": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"instanceType": "m5.large",
+				"usagetype":    usage,
+				"vcpu":         "2",
+				"memory":       "8 GiB",
+				"storage":      "EBS only",
+			},
+		},
+		"terms": map[string]interface{}{
+			"OnDemand": map[string]interface{}{
+				"SKU.JRTCKXETXF": map[string]interface{}{
+					"priceDimensions": map[string]interface{}{
+						"SKU.JRTCKXETXF.6YS6EN2CT7": map[string]interface{}{
+							"unit":         "Hrs",
+							"pricePerUnit": map[string]interface{}{"USD": "0.1120000000"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestToTemplate(t *testing.T) {
+	region, err := GetRegionInfo("eu-west-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"EUW3-BoxUsage:m5.large":       true,
+		"EUW3-DedicatedUsage:m5.large": false,
+		"EUW3-HostBoxUsage:m5.large":   false,
+		"USE2-BoxUsage:m5.large":       false,
+		"BoxUsage:m5.large":            false,
+		"EUW3-BoxUsage:m5.xlarge":      false,
+	}
+	for usage, expected := range cases {
+		tpl, ok := toTemplate(region, priceDocument(usage))
+		if ok != expected {
+			t.Errorf("%s: expected %t, got %t", usage, expected, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if tpl.ID != "m5.large" || tpl.Cores != 2 || tpl.RAMSize != 8 {
+			t.Errorf("unexpected template %+v", tpl)
+		}
+		if tpl.Price == nil || tpl.Price.Amount != 0.112 || tpl.Price.Currency != "USD" {
+			t.Errorf("unexpected price %+v", tpl.Price)
+		}
+	}
+}
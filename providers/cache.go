@@ -0,0 +1,749 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/system"
+)
+
+//Default time to live of the cached resources
+const (
+	DefaultImagesTTL    = time.Hour
+	DefaultTemplatesTTL = 24 * time.Hour
+	DefaultKeyPairsTTL  = 5 * time.Minute
+	DefaultNetworksTTL  = time.Minute
+)
+
+//CacheOptions settings of the cache of a provider client
+//Time to live are in nanoseconds, the default is used if 0 and a negative value disables the cache of the resource
+type CacheOptions struct {
+	ImagesTTL    time.Duration `json:"images_ttl,omitempty"`
+	TemplatesTTL time.Duration `json:"templates_ttl,omitempty"`
+	KeyPairsTTL  time.Duration `json:"key_pairs_ttl,omitempty"`
+	NetworksTTL  time.Duration `json:"networks_ttl,omitempty"`
+	//MemoryOnly images and templates are not cached on disk
+	MemoryOnly bool `json:"memory_only,omitempty"`
+	//Dir directory of the on-disk cache of images and templates, they are only cached in memory if empty
+	Dir string `json:"-"`
+}
+
+//cacheItem resource cached by ID
+type cacheItem struct {
+	value   interface{}
+	expires time.Time
+}
+
+//cacheFile content of an on-disk cache file
+type cacheFile struct {
+	Expires time.Time       `json:"expires"`
+	Value   json.RawMessage `json:"value"`
+}
+
+//resourceCache caches the list of the resources of a type and the resources got by ID
+type resourceCache struct {
+	ttl time.Duration
+	//file on-disk cache of the list, empty if the list is only cached in memory
+	file string
+	//decode decodes the list read from file
+	decode func(b []byte) (interface{}, error)
+
+	mu          sync.Mutex
+	value       interface{}
+	expires     time.Time
+	items       map[string]cacheItem
+	loadingList sync.Mutex
+	//generation incremented by each invalidation, values fetched before an invalidation are not cached
+	generation int
+}
+
+func newResourceCache(ttl time.Duration, file string, decode func(b []byte) (interface{}, error)) *resourceCache {
+	return &resourceCache{
+		ttl:    ttl,
+		file:   file,
+		decode: decode,
+		items:  map[string]cacheItem{},
+	}
+}
+
+//cached returns the cached list if it is fresh
+func (rc *resourceCache) cached() (interface{}, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.value != nil && time.Now().Before(rc.expires) {
+		return rc.value, true
+	}
+	return nil, false
+}
+
+//readFile loads the list from the on-disk cache if it is fresh
+func (rc *resourceCache) readFile() (interface{}, bool) {
+	b, err := ioutil.ReadFile(rc.file)
+	if err != nil {
+		return nil, false
+	}
+	f := cacheFile{}
+	if json.Unmarshal(b, &f) != nil || !time.Now().Before(f.Expires) {
+		return nil, false
+	}
+	v, err := rc.decode(f.Value)
+	if err != nil {
+		return nil, false
+	}
+	rc.mu.Lock()
+	rc.value, rc.expires = v, f.Expires
+	rc.mu.Unlock()
+	return v, true
+}
+
+//writeFile saves the list in the on-disk cache, failures only disable the on-disk cache
+func (rc *resourceCache) writeFile(v interface{}, expires time.Time) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	b, err := json.Marshal(cacheFile{Expires: expires, Value: value})
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(rc.file), 0700) != nil {
+		return
+	}
+	tmp := rc.file + ".tmp"
+	if ioutil.WriteFile(tmp, b, 0600) == nil {
+		os.Rename(tmp, rc.file)
+	}
+}
+
+//list returns the cached list or fetches it, concurrent fetches of the list are merged
+func (rc *resourceCache) list(ctx context.Context, fetch func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if rc.ttl < 0 {
+		return fetch(ctx)
+	}
+	if v, ok := rc.cached(); ok {
+		return v, nil
+	}
+	rc.loadingList.Lock()
+	defer rc.loadingList.Unlock()
+	if v, ok := rc.cached(); ok {
+		return v, nil
+	}
+	if rc.file != "" {
+		if v, ok := rc.readFile(); ok {
+			return v, nil
+		}
+	}
+	rc.mu.Lock()
+	generation := rc.generation
+	rc.mu.Unlock()
+	v, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(rc.ttl)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if generation != rc.generation {
+		return v, nil
+	}
+	rc.value, rc.expires = v, expires
+	if rc.file != "" {
+		rc.writeFile(v, expires)
+	}
+	return v, nil
+}
+
+//get returns the resource id, it is looked for with find in the cached list, then in the resources got by ID.
+//It is fetched if it is not cached
+func (rc *resourceCache) get(ctx context.Context, id string, find func(list interface{}) (interface{}, bool), fetch func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if rc.ttl < 0 {
+		return fetch(ctx)
+	}
+	if list, ok := rc.cached(); ok {
+		if v, ok := find(list); ok {
+			return v, nil
+		}
+	}
+	rc.mu.Lock()
+	item, ok := rc.items[id]
+	generation := rc.generation
+	rc.mu.Unlock()
+	if ok && time.Now().Before(item.expires) {
+		return item.value, nil
+	}
+	v, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rc.mu.Lock()
+	if generation == rc.generation {
+		rc.items[id] = cacheItem{value: v, expires: time.Now().Add(rc.ttl)}
+	}
+	rc.mu.Unlock()
+	return v, nil
+}
+
+//invalidate empties the cache
+func (rc *resourceCache) invalidate() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.generation++
+	rc.value = nil
+	rc.items = map[string]cacheItem{}
+	if rc.file != "" {
+		os.Remove(rc.file)
+	}
+}
+
+/*CacheClient decorates a ClientAPI with a read-through cache of images, templates, key pairs and networks
+The lists of resources are cached for the time to live of their type, resources got by ID are looked for in the cached
+list before being fetched and cached. Creating or deleting a key pair or a network invalidates the cached key pairs or
+networks. When a directory is given, the lists of images and templates, which are slow to fetch on some providers,
+are also cached on disk and shared by the processes using the same directory.
+Other calls are not cached
+*/
+type CacheClient struct {
+	clt       api.ClientAPIContext
+	decorated api.ClientAPI
+	images    *resourceCache
+	templates *resourceCache
+	keyPairs  *resourceCache
+	networks  *resourceCache
+}
+
+//cacheTTL returns the time to live ttl or def if ttl is 0
+func cacheTTL(ttl time.Duration, def time.Duration) time.Duration {
+	if ttl == 0 {
+		return def
+	}
+	return ttl
+}
+
+//NewCacheClient decorates clt with a cache
+func NewCacheClient(clt api.ClientAPI, opts CacheOptions) *CacheClient {
+	file := func(name string) string {
+		if opts.Dir == "" || opts.MemoryOnly {
+			return ""
+		}
+		return filepath.Join(opts.Dir, name+".json")
+	}
+	return &CacheClient{
+		clt:       api.WithContext(clt),
+		decorated: clt,
+		images: newResourceCache(cacheTTL(opts.ImagesTTL, DefaultImagesTTL), file("images"), func(b []byte) (interface{}, error) {
+			var list []api.Image
+			err := json.Unmarshal(b, &list)
+			return list, err
+		}),
+		templates: newResourceCache(cacheTTL(opts.TemplatesTTL, DefaultTemplatesTTL), file("templates"), func(b []byte) (interface{}, error) {
+			var list []api.VMTemplate
+			err := json.Unmarshal(b, &list)
+			return list, err
+		}),
+		keyPairs: newResourceCache(cacheTTL(opts.KeyPairsTTL, DefaultKeyPairsTTL), "", nil),
+		networks: newResourceCache(cacheTTL(opts.NetworksTTL, DefaultNetworksTTL), "", nil),
+	}
+}
+
+//Unwrap returns the decorated client
+func (c *CacheClient) Unwrap() api.ClientAPI {
+	return c.decorated
+}
+
+//Invalidate empties the cache, including the on-disk cache
+func (c *CacheClient) Invalidate() {
+	c.images.invalidate()
+	c.templates.invalidate()
+	c.keyPairs.invalidate()
+	c.networks.invalidate()
+}
+
+//ListImages lists available OS images, the list is cached
+func (c *CacheClient) ListImages() ([]api.Image, error) {
+	return c.ListImagesContext(context.Background())
+}
+
+//ListImagesContext implements api.ClientAPIContext
+func (c *CacheClient) ListImagesContext(ctx context.Context) ([]api.Image, error) {
+	v, err := c.images.list(ctx, func(ctx context.Context) (interface{}, error) {
+		return c.clt.ListImagesContext(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]api.Image(nil), v.([]api.Image)...), nil
+}
+
+//GetImage returns the Image referenced by id, it is looked for in the cached list first
+func (c *CacheClient) GetImage(id string) (*api.Image, error) {
+	return c.GetImageContext(context.Background(), id)
+}
+
+//GetImageContext implements api.ClientAPIContext
+func (c *CacheClient) GetImageContext(ctx context.Context, id string) (*api.Image, error) {
+	find := func(list interface{}) (interface{}, bool) {
+		for _, r := range list.([]api.Image) {
+			if r.ID == id {
+				return r, true
+			}
+		}
+		return nil, false
+	}
+	v, err := c.images.get(ctx, id, find, func(ctx context.Context) (interface{}, error) {
+		r, err := c.clt.GetImageContext(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return *r, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := v.(api.Image)
+	return &r, nil
+}
+
+//ListTemplates lists available VM templates, the list is cached
+func (c *CacheClient) ListTemplates() ([]api.VMTemplate, error) {
+	return c.ListTemplatesContext(context.Background())
+}
+
+//ListTemplatesContext implements api.ClientAPIContext
+func (c *CacheClient) ListTemplatesContext(ctx context.Context) ([]api.VMTemplate, error) {
+	v, err := c.templates.list(ctx, func(ctx context.Context) (interface{}, error) {
+		return c.clt.ListTemplatesContext(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]api.VMTemplate(nil), v.([]api.VMTemplate)...), nil
+}
+
+//GetTemplate returns the Template referenced by id, it is looked for in the cached list first
+func (c *CacheClient) GetTemplate(id string) (*api.VMTemplate, error) {
+	return c.GetTemplateContext(context.Background(), id)
+}
+
+//GetTemplateContext implements api.ClientAPIContext
+func (c *CacheClient) GetTemplateContext(ctx context.Context, id string) (*api.VMTemplate, error) {
+	find := func(list interface{}) (interface{}, bool) {
+		for _, r := range list.([]api.VMTemplate) {
+			if r.ID == id {
+				return r, true
+			}
+		}
+		return nil, false
+	}
+	v, err := c.templates.get(ctx, id, find, func(ctx context.Context) (interface{}, error) {
+		r, err := c.clt.GetTemplateContext(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return *r, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := v.(api.VMTemplate)
+	return &r, nil
+}
+
+//ListKeyPairs lists available key pairs, the list is cached
+func (c *CacheClient) ListKeyPairs() ([]api.KeyPair, error) {
+	return c.ListKeyPairsContext(context.Background())
+}
+
+//ListKeyPairsContext implements api.ClientAPIContext
+func (c *CacheClient) ListKeyPairsContext(ctx context.Context) ([]api.KeyPair, error) {
+	v, err := c.keyPairs.list(ctx, func(ctx context.Context) (interface{}, error) {
+		return c.clt.ListKeyPairsContext(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]api.KeyPair(nil), v.([]api.KeyPair)...), nil
+}
+
+//GetKeyPair returns the key pair identified by id, it is looked for in the cached list first
+func (c *CacheClient) GetKeyPair(id string) (*api.KeyPair, error) {
+	return c.GetKeyPairContext(context.Background(), id)
+}
+
+//GetKeyPairContext implements api.ClientAPIContext
+func (c *CacheClient) GetKeyPairContext(ctx context.Context, id string) (*api.KeyPair, error) {
+	find := func(list interface{}) (interface{}, bool) {
+		for _, r := range list.([]api.KeyPair) {
+			if r.ID == id {
+				return r, true
+			}
+		}
+		return nil, false
+	}
+	v, err := c.keyPairs.get(ctx, id, find, func(ctx context.Context) (interface{}, error) {
+		r, err := c.clt.GetKeyPairContext(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return *r, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := v.(api.KeyPair)
+	return &r, nil
+}
+
+//CreateKeyPair creates and import a key pair, the cached key pairs are invalidated
+func (c *CacheClient) CreateKeyPair(name string) (*api.KeyPair, error) {
+	return c.CreateKeyPairContext(context.Background(), name)
+}
+
+//CreateKeyPairContext implements api.ClientAPIContext
+func (c *CacheClient) CreateKeyPairContext(ctx context.Context, name string) (*api.KeyPair, error) {
+	defer c.keyPairs.invalidate()
+	return c.clt.CreateKeyPairContext(ctx, name)
+}
+
+//DeleteKeyPair deletes the key pair identified by id, the cached key pairs are invalidated
+func (c *CacheClient) DeleteKeyPair(id string) error {
+	return c.DeleteKeyPairContext(context.Background(), id)
+}
+
+//DeleteKeyPairContext implements api.ClientAPIContext
+func (c *CacheClient) DeleteKeyPairContext(ctx context.Context, id string) error {
+	defer c.keyPairs.invalidate()
+	return c.clt.DeleteKeyPairContext(ctx, id)
+}
+
+//ListNetworks lists available networks, the list is cached
+func (c *CacheClient) ListNetworks() ([]api.Network, error) {
+	return c.ListNetworksContext(context.Background())
+}
+
+//ListNetworksContext implements api.ClientAPIContext
+func (c *CacheClient) ListNetworksContext(ctx context.Context) ([]api.Network, error) {
+	v, err := c.networks.list(ctx, func(ctx context.Context) (interface{}, error) {
+		return c.clt.ListNetworksContext(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]api.Network(nil), v.([]api.Network)...), nil
+}
+
+//GetNetwork returns the network identified by id, it is looked for in the cached list first
+func (c *CacheClient) GetNetwork(id string) (*api.Network, error) {
+	return c.GetNetworkContext(context.Background(), id)
+}
+
+//GetNetworkContext implements api.ClientAPIContext
+func (c *CacheClient) GetNetworkContext(ctx context.Context, id string) (*api.Network, error) {
+	find := func(list interface{}) (interface{}, bool) {
+		for _, r := range list.([]api.Network) {
+			if r.ID == id {
+				return r, true
+			}
+		}
+		return nil, false
+	}
+	v, err := c.networks.get(ctx, id, find, func(ctx context.Context) (interface{}, error) {
+		r, err := c.clt.GetNetworkContext(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return *r, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := v.(api.Network)
+	return &r, nil
+}
+
+//CreateNetwork creates a network named name, the cached networks are invalidated
+func (c *CacheClient) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
+	return c.CreateNetworkContext(context.Background(), req)
+}
+
+//CreateNetworkContext implements api.ClientAPIContext
+func (c *CacheClient) CreateNetworkContext(ctx context.Context, req api.NetworkRequest) (*api.Network, error) {
+	defer c.networks.invalidate()
+	return c.clt.CreateNetworkContext(ctx, req)
+}
+
+//DeleteNetwork deletes the network identified by id, the cached networks are invalidated
+func (c *CacheClient) DeleteNetwork(id string) error {
+	return c.DeleteNetworkContext(context.Background(), id)
+}
+
+//DeleteNetworkContext implements api.ClientAPIContext
+func (c *CacheClient) DeleteNetworkContext(ctx context.Context, id string) error {
+	defer c.networks.invalidate()
+	return c.clt.DeleteNetworkContext(ctx, id)
+}
+
+//CreateVM creates a VM that fulfils the request
+func (c *CacheClient) CreateVM(request api.VMRequest) (*api.VM, error) {
+	return c.clt.CreateVMContext(context.Background(), request)
+}
+
+//CreateVMContext implements api.ClientAPIContext
+func (c *CacheClient) CreateVMContext(ctx context.Context, request api.VMRequest) (*api.VM, error) {
+	return c.clt.CreateVMContext(ctx, request)
+}
+
+//GetVM returns the VM identified by id
+func (c *CacheClient) GetVM(id string) (*api.VM, error) {
+	return c.clt.GetVMContext(context.Background(), id)
+}
+
+//GetVMContext implements api.ClientAPIContext
+func (c *CacheClient) GetVMContext(ctx context.Context, id string) (*api.VM, error) {
+	return c.clt.GetVMContext(ctx, id)
+}
+
+//ListVMs lists available VMs
+func (c *CacheClient) ListVMs() ([]api.VM, error) {
+	return c.clt.ListVMsContext(context.Background())
+}
+
+//ListVMsContext implements api.ClientAPIContext
+func (c *CacheClient) ListVMsContext(ctx context.Context) ([]api.VM, error) {
+	return c.clt.ListVMsContext(ctx)
+}
+
+//DeleteVM deletes the VM identified by id
+func (c *CacheClient) DeleteVM(id string) error {
+	return c.clt.DeleteVMContext(context.Background(), id)
+}
+
+//DeleteVMContext implements api.ClientAPIContext
+func (c *CacheClient) DeleteVMContext(ctx context.Context, id string) error {
+	return c.clt.DeleteVMContext(ctx, id)
+}
+
+//StopVM stops the VM identified by id
+func (c *CacheClient) StopVM(id string) error {
+	return c.clt.StopVMContext(context.Background(), id)
+}
+
+//StopVMContext implements api.ClientAPIContext
+func (c *CacheClient) StopVMContext(ctx context.Context, id string) error {
+	return c.clt.StopVMContext(ctx, id)
+}
+
+//StartVM starts the VM identified by id
+func (c *CacheClient) StartVM(id string) error {
+	return c.clt.StartVMContext(context.Background(), id)
+}
+
+//StartVMContext implements api.ClientAPIContext
+func (c *CacheClient) StartVMContext(ctx context.Context, id string) error {
+	return c.clt.StartVMContext(ctx, id)
+}
+
+//GetSSHConfig creates SSHConfig from VM
+func (c *CacheClient) GetSSHConfig(id string) (*system.SSHConfig, error) {
+	return c.clt.GetSSHConfigContext(context.Background(), id)
+}
+
+//GetSSHConfigContext implements api.ClientAPIContext
+func (c *CacheClient) GetSSHConfigContext(ctx context.Context, id string) (*system.SSHConfig, error) {
+	return c.clt.GetSSHConfigContext(ctx, id)
+}
+
+//CreateVolume creates a block volume
+func (c *CacheClient) CreateVolume(request api.VolumeRequest) (*api.Volume, error) {
+	return c.clt.CreateVolumeContext(context.Background(), request)
+}
+
+//CreateVolumeContext implements api.ClientAPIContext
+func (c *CacheClient) CreateVolumeContext(ctx context.Context, request api.VolumeRequest) (*api.Volume, error) {
+	return c.clt.CreateVolumeContext(ctx, request)
+}
+
+//GetVolume returns the volume identified by id
+func (c *CacheClient) GetVolume(id string) (*api.Volume, error) {
+	return c.clt.GetVolumeContext(context.Background(), id)
+}
+
+//GetVolumeContext implements api.ClientAPIContext
+func (c *CacheClient) GetVolumeContext(ctx context.Context, id string) (*api.Volume, error) {
+	return c.clt.GetVolumeContext(ctx, id)
+}
+
+//ListVolumes list available volumes
+func (c *CacheClient) ListVolumes() ([]api.Volume, error) {
+	return c.clt.ListVolumesContext(context.Background())
+}
+
+//ListVolumesContext implements api.ClientAPIContext
+func (c *CacheClient) ListVolumesContext(ctx context.Context) ([]api.Volume, error) {
+	return c.clt.ListVolumesContext(ctx)
+}
+
+//DeleteVolume deletes the volume identified by id
+func (c *CacheClient) DeleteVolume(id string) error {
+	return c.clt.DeleteVolumeContext(context.Background(), id)
+}
+
+//DeleteVolumeContext implements api.ClientAPIContext
+func (c *CacheClient) DeleteVolumeContext(ctx context.Context, id string) error {
+	return c.clt.DeleteVolumeContext(ctx, id)
+}
+
+//UpdateVolume renames, resizes or changes the speed of the volume identified by id
+func (c *CacheClient) UpdateVolume(id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	return c.clt.UpdateVolumeContext(context.Background(), id, request)
+}
+
+//UpdateVolumeContext implements api.ClientAPIContext
+func (c *CacheClient) UpdateVolumeContext(ctx context.Context, id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	return c.clt.UpdateVolumeContext(ctx, id, request)
+}
+
+//CreateVolumeAttachment attaches a volume to a VM
+func (c *CacheClient) CreateVolumeAttachment(request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	return c.clt.CreateVolumeAttachmentContext(context.Background(), request)
+}
+
+//CreateVolumeAttachmentContext implements api.ClientAPIContext
+func (c *CacheClient) CreateVolumeAttachmentContext(ctx context.Context, request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	return c.clt.CreateVolumeAttachmentContext(ctx, request)
+}
+
+//GetVolumeAttachment returns the volume attachment identified by id
+func (c *CacheClient) GetVolumeAttachment(serverID string, id string) (*api.VolumeAttachment, error) {
+	return c.clt.GetVolumeAttachmentContext(context.Background(), serverID, id)
+}
+
+//GetVolumeAttachmentContext implements api.ClientAPIContext
+func (c *CacheClient) GetVolumeAttachmentContext(ctx context.Context, serverID string, id string) (*api.VolumeAttachment, error) {
+	return c.clt.GetVolumeAttachmentContext(ctx, serverID, id)
+}
+
+//ListVolumeAttachments lists available volume attachment
+func (c *CacheClient) ListVolumeAttachments(serverID string) ([]api.VolumeAttachment, error) {
+	return c.clt.ListVolumeAttachmentsContext(context.Background(), serverID)
+}
+
+//ListVolumeAttachmentsContext implements api.ClientAPIContext
+func (c *CacheClient) ListVolumeAttachmentsContext(ctx context.Context, serverID string) ([]api.VolumeAttachment, error) {
+	return c.clt.ListVolumeAttachmentsContext(ctx, serverID)
+}
+
+//DeleteVolumeAttachment deletes the volume attachment identifed by id
+func (c *CacheClient) DeleteVolumeAttachment(serverID string, id string) error {
+	return c.clt.DeleteVolumeAttachmentContext(context.Background(), serverID, id)
+}
+
+//DeleteVolumeAttachmentContext implements api.ClientAPIContext
+func (c *CacheClient) DeleteVolumeAttachmentContext(ctx context.Context, serverID string, id string) error {
+	return c.clt.DeleteVolumeAttachmentContext(ctx, serverID, id)
+}
+
+//CreateContainer creates an object container
+func (c *CacheClient) CreateContainer(name string) error {
+	return c.clt.CreateContainerContext(context.Background(), name)
+}
+
+//CreateContainerContext implements api.ClientAPIContext
+func (c *CacheClient) CreateContainerContext(ctx context.Context, name string) error {
+	return c.clt.CreateContainerContext(ctx, name)
+}
+
+//DeleteContainer deletes an object container
+func (c *CacheClient) DeleteContainer(name string) error {
+	return c.clt.DeleteContainerContext(context.Background(), name)
+}
+
+//DeleteContainerContext implements api.ClientAPIContext
+func (c *CacheClient) DeleteContainerContext(ctx context.Context, name string) error {
+	return c.clt.DeleteContainerContext(ctx, name)
+}
+
+//ListContainers list object containers
+func (c *CacheClient) ListContainers() ([]string, error) {
+	return c.clt.ListContainersContext(context.Background())
+}
+
+//ListContainersContext implements api.ClientAPIContext
+func (c *CacheClient) ListContainersContext(ctx context.Context) ([]string, error) {
+	return c.clt.ListContainersContext(ctx)
+}
+
+//PutObject put an object into an object container
+func (c *CacheClient) PutObject(container string, obj api.Object) error {
+	return c.clt.PutObjectContext(context.Background(), container, obj)
+}
+
+//PutObjectContext implements api.ClientAPIContext
+func (c *CacheClient) PutObjectContext(ctx context.Context, container string, obj api.Object) error {
+	return c.clt.PutObjectContext(ctx, container, obj)
+}
+
+//UpdateObjectMetadata update an object into  object container
+func (c *CacheClient) UpdateObjectMetadata(container string, obj api.Object) error {
+	return c.clt.UpdateObjectMetadataContext(context.Background(), container, obj)
+}
+
+//UpdateObjectMetadataContext implements api.ClientAPIContext
+func (c *CacheClient) UpdateObjectMetadataContext(ctx context.Context, container string, obj api.Object) error {
+	return c.clt.UpdateObjectMetadataContext(ctx, container, obj)
+}
+
+//GetObject get  object content from an object container
+func (c *CacheClient) GetObject(container string, name string, ranges []api.Range) (*api.Object, error) {
+	return c.clt.GetObjectContext(context.Background(), container, name, ranges)
+}
+
+//GetObjectContext implements api.ClientAPIContext
+func (c *CacheClient) GetObjectContext(ctx context.Context, container string, name string, ranges []api.Range) (*api.Object, error) {
+	return c.clt.GetObjectContext(ctx, container, name, ranges)
+}
+
+//GetObjectMetadata get  object metadata from an object container
+func (c *CacheClient) GetObjectMetadata(container string, name string) (*api.Object, error) {
+	return c.clt.GetObjectMetadataContext(context.Background(), container, name)
+}
+
+//GetObjectMetadataContext implements api.ClientAPIContext
+func (c *CacheClient) GetObjectMetadataContext(ctx context.Context, container string, name string) (*api.Object, error) {
+	return c.clt.GetObjectMetadataContext(ctx, container, name)
+}
+
+//ListObjects list objects of a container
+func (c *CacheClient) ListObjects(container string, filter api.ObjectFilter) ([]string, error) {
+	return c.clt.ListObjectsContext(context.Background(), container, filter)
+}
+
+//ListObjectsContext implements api.ClientAPIContext
+func (c *CacheClient) ListObjectsContext(ctx context.Context, container string, filter api.ObjectFilter) ([]string, error) {
+	return c.clt.ListObjectsContext(ctx, container, filter)
+}
+
+//CopyObject copies an object
+func (c *CacheClient) CopyObject(containerSrc string, objectSrc string, objectDst string) error {
+	return c.clt.CopyObjectContext(context.Background(), containerSrc, objectSrc, objectDst)
+}
+
+//CopyObjectContext implements api.ClientAPIContext
+func (c *CacheClient) CopyObjectContext(ctx context.Context, containerSrc string, objectSrc string, objectDst string) error {
+	return c.clt.CopyObjectContext(ctx, containerSrc, objectSrc, objectDst)
+}
+
+//DeleteObject deleta an object from a container
+func (c *CacheClient) DeleteObject(container string, object string) error {
+	return c.clt.DeleteObjectContext(context.Background(), container, object)
+}
+
+//DeleteObjectContext implements api.ClientAPIContext
+func (c *CacheClient) DeleteObjectContext(ctx context.Context, container string, object string) error {
+	return c.clt.DeleteObjectContext(ctx, container, object)
+}
@@ -0,0 +1,269 @@
+package providers_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/memory"
+)
+
+//countingClient counts the calls of the cached methods of the in-memory client
+type countingClient struct {
+	api.ClientAPI
+	mu    sync.Mutex
+	calls map[string]int
+	//listing called by ListNetworks before the list is returned
+	listing func()
+}
+
+func newCountingClient(t *testing.T) *countingClient {
+	clt, err := memory.AuthenticatedClient(memory.AuthOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &countingClient{ClientAPI: clt, calls: map[string]int{}}
+}
+
+func (c *countingClient) count(method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[method]++
+}
+
+//check checks the number of calls of method
+func (c *countingClient) check(t *testing.T, method string, expected int) {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calls[method] != expected {
+		t.Fatalf("expected %d calls of %s, got %d", expected, method, c.calls[method])
+	}
+}
+
+func (c *countingClient) ListImages() ([]api.Image, error) {
+	c.count("ListImages")
+	return c.ClientAPI.ListImages()
+}
+
+func (c *countingClient) GetImage(id string) (*api.Image, error) {
+	c.count("GetImage")
+	return c.ClientAPI.GetImage(id)
+}
+
+func (c *countingClient) ListKeyPairs() ([]api.KeyPair, error) {
+	c.count("ListKeyPairs")
+	return c.ClientAPI.ListKeyPairs()
+}
+
+func (c *countingClient) ListNetworks() ([]api.Network, error) {
+	c.count("ListNetworks")
+	list, err := c.ClientAPI.ListNetworks()
+	if c.listing != nil {
+		c.listing()
+	}
+	return list, err
+}
+
+func (c *countingClient) GetNetwork(id string) (*api.Network, error) {
+	c.count("GetNetwork")
+	return c.ClientAPI.GetNetwork(id)
+}
+
+//networkRequest returns the request of a network of the in-memory driver
+func networkRequest(name string) api.NetworkRequest {
+	return api.NetworkRequest{
+		Name: name,
+		CIDR: "192.168.1.0/24",
+		GWRequest: api.VMRequest{
+			TemplateID: memory.DefaultTemplates[0].ID,
+			ImageID:    memory.DefaultImages[0].ID,
+		},
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	clt := newCountingClient(t)
+	c := providers.NewCacheClient(clt, providers.CacheOptions{NetworksTTL: 50 * time.Millisecond, KeyPairsTTL: -1})
+	for i := 0; i < 2; i++ {
+		_, err := c.ListNetworks()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	clt.check(t, "ListNetworks", 1)
+	time.Sleep(60 * time.Millisecond)
+	_, err := c.ListNetworks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clt.check(t, "ListNetworks", 2)
+
+	//a negative time to live disables the cache
+	for i := 0; i < 2; i++ {
+		_, err := c.ListKeyPairs()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	clt.check(t, "ListKeyPairs", 2)
+}
+
+func TestCacheGet(t *testing.T) {
+	clt := newCountingClient(t)
+	c := providers.NewCacheClient(clt, providers.CacheOptions{})
+	net, err := c.CreateNetwork(networkRequest("net1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//resources got by ID are cached
+	for i := 0; i < 2; i++ {
+		n, err := c.GetNetwork(net.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.ID != net.ID {
+			t.Fatalf("expected network %s, got %+v", net.ID, n)
+		}
+	}
+	clt.check(t, "GetNetwork", 1)
+	//then looked for in the cached list
+	c.Invalidate()
+	_, err = c.ListNetworks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetNetwork(net.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clt.check(t, "GetNetwork", 1)
+	//errors are not cached
+	for i := 0; i < 2; i++ {
+		_, err = c.GetImage("unknown")
+		if err == nil {
+			t.Fatal("unknown image found")
+		}
+	}
+	clt.check(t, "GetImage", 2)
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	clt := newCountingClient(t)
+	c := providers.NewCacheClient(clt, providers.CacheOptions{})
+	list := func() []api.Network {
+		nets, err := c.ListNetworks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nets
+	}
+	list()
+	net, err := c.CreateNetwork(networkRequest("net1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nets := list(); len(nets) != 1 {
+		t.Fatalf("expected the created network to be listed, got %+v", nets)
+	}
+	err = c.DeleteNetwork(net.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nets := list(); len(nets) != 0 {
+		t.Fatalf("expected the deleted network not to be listed, got %+v", nets)
+	}
+	clt.check(t, "ListNetworks", 3)
+
+	_, err = c.ListKeyPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kp, err := c.CreateKeyPair("kp1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kps, err := c.ListKeyPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kps) != 1 || kps[0].ID != kp.ID {
+		t.Fatalf("expected the created key pair to be listed, got %+v", kps)
+	}
+	clt.check(t, "ListKeyPairs", 2)
+}
+
+func TestCacheGeneration(t *testing.T) {
+	clt := newCountingClient(t)
+	c := providers.NewCacheClient(clt, providers.CacheOptions{})
+	//the cache is invalidated while the list is fetched: the fetched list may be stale and is not cached
+	clt.listing = func() {
+		clt.listing = nil
+		c.Invalidate()
+	}
+	for i := 0; i < 3; i++ {
+		_, err := c.ListNetworks()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	clt.check(t, "ListNetworks", 2)
+}
+
+func TestCacheDisk(t *testing.T) {
+	dir := t.TempDir()
+	first := newCountingClient(t)
+	images, err := providers.NewCacheClient(first, providers.CacheOptions{Dir: dir}).ListImages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.check(t, "ListImages", 1)
+	if _, err := os.Stat(filepath.Join(dir, "images.json")); err != nil {
+		t.Fatalf("images not cached on disk: %s", err.Error())
+	}
+
+	//the images are read from the disk by another client
+	second := newCountingClient(t)
+	c := providers.NewCacheClient(second, providers.CacheOptions{Dir: dir})
+	cached, err := c.ListImages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.check(t, "ListImages", 0)
+	if len(cached) != len(images) || cached[0] != images[0] {
+		t.Fatalf("expected %+v, got %+v", images, cached)
+	}
+	//invalidation removes the file
+	c.Invalidate()
+	if _, err := os.Stat(filepath.Join(dir, "images.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected the cache file to be removed, got %v", err)
+	}
+
+	//an expired file is ignored
+	expired := providers.CacheOptions{Dir: dir, ImagesTTL: time.Millisecond}
+	_, err = providers.NewCacheClient(newCountingClient(t), expired).ListImages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	third := newCountingClient(t)
+	_, err = providers.NewCacheClient(third, expired).ListImages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	third.check(t, "ListImages", 1)
+
+	//nothing is written in the directory when the cache is kept in memory
+	memoryDir := t.TempDir()
+	_, err = providers.NewCacheClient(newCountingClient(t), providers.CacheOptions{Dir: memoryDir, MemoryOnly: true}).ListImages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(memoryDir, "*")); len(files) != 0 {
+		t.Fatalf("expected no cache file, got %v", files)
+	}
+}
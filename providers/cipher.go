@@ -0,0 +1,56 @@
+package providers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+//Cipher encrypts values with AES-256-GCM, it is shared by the keyrings of the tenants and the vault of the broker
+//The encrypted values are base64 encoded, the random nonce is prepended to the ciphertext
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+//NewCipher creates a cipher with a key of MasterKeySize bytes
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != MasterKeySize {
+		return nil, fmt.Errorf("invalid key size %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+//Seal encrypts value, ad is authenticated with the value and must be given to open it
+func (c *Cipher) Seal(value []byte, ad string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, value, []byte(ad))), nil
+}
+
+//Open decrypts a value sealed with ad
+func (c *Cipher) Open(value string, ad string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	n := c.aead.NonceSize()
+	if len(b) < n {
+		return nil, errors.New("invalid encrypted value")
+	}
+	return c.aead.Open(nil, b[:n], b[n:], []byte(ad))
+}
@@ -18,6 +18,8 @@ type AuthOptions struct {
 	Password   string
 	TenantName string
 	Region     string
+	//IdentityEndpoint overrides the Cloudwatt Keystone endpoint of the region (optional)
+	IdentityEndpoint string
 }
 
 // func parseOpenRC(openrc string) (*openstack.AuthOptions, error) {
@@ -26,7 +28,10 @@ type AuthOptions struct {
 
 //AuthenticatedClient returns an authenticated client
 func AuthenticatedClient(opts AuthOptions) (*Client, error) {
-	IdentityEndpoint := fmt.Sprintf("https://identity.%s.cloudwatt.com/v2.0", opts.Region)
+	IdentityEndpoint := opts.IdentityEndpoint
+	if IdentityEndpoint == "" {
+		IdentityEndpoint = fmt.Sprintf("https://identity.%s.cloudwatt.com/v2.0", opts.Region)
+	}
 	os, err := openstack.AuthenticatedClient(openstack.AuthOptions{
 		IdentityEndpoint: IdentityEndpoint,
 		//UserID:           opts.OpenstackID,
@@ -0,0 +1,28 @@
+package cloudwatt
+
+import (
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+func init() {
+	providers.Register(providers.Provider{
+		Name:        "cloudwatt",
+		Description: "Cloudwatt public cloud",
+		Fields: []providers.ConfigField{
+			{Name: "Username", Description: "OpenStack user name", Required: true, Sample: "user@example.com"},
+			{Name: "Password", Description: "OpenStack password", Required: true, Secret: true, Sample: "password"},
+			{Name: "TenantName", Description: "OpenStack tenant name", Required: true, Sample: "0750190000"},
+			{Name: "Region", Description: "Cloudwatt region (fr1, fr2)", Required: true, Sample: "fr1"},
+			{Name: "IdentityEndpoint", Description: "URL overriding the Cloudwatt Keystone endpoint of the region (optional)"},
+		},
+		Factory: func(cfg map[string]interface{}) (api.ClientAPI, error) {
+			opts := AuthOptions{}
+			err := providers.DecodeConfig(cfg, &opts)
+			if err != nil {
+				return nil, err
+			}
+			return AuthenticatedClient(opts)
+		},
+	})
+}
@@ -0,0 +1,177 @@
+package providers
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//Kinds of the orphaned resources
+const (
+	//OrphanAddress public IP allocated to a VM (AWS Elastic IP, OpenStack floating IP) and not associated anymore
+	OrphanAddress = "address"
+	//OrphanKeyPair temporary key pair of a VM creation
+	OrphanKeyPair = "key_pair"
+	//OrphanSecurityGroup security group not used by any VM
+	OrphanSecurityGroup = "security_group"
+	//OrphanSubnet subnet of an orphaned network
+	OrphanSubnet = "subnet"
+	//OrphanNetwork network without bookkeeping data, its creation failed before it was recorded
+	OrphanNetwork = "network"
+	//OrphanVolume volume without bookkeeping data and not attached
+	OrphanVolume = "volume"
+	//OrphanMetadata bookkeeping data of a resource that does not exist anymore
+	OrphanMetadata = "metadata"
+)
+
+//Orphan resource of a provider leaked by a failed or interrupted operation
+type Orphan struct {
+	Kind string `json:"kind"`
+	//ID ID of the resource, <bucket>/<key> for metadata
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	//Reason why the resource is considered orphaned
+	Reason string `json:"reason"`
+	//Created creation time of the resource, the time the record was written for metadata. It is zero if the time
+	//is unknown
+	Created time.Time `json:"created"`
+	//Deleted tells if the resource was deleted
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error,omitempty"`
+}
+
+/*GarbageCollector is implemented by the drivers able to find the resources leaked by failed operations
+Provider resources are cross-referenced with the bookkeeping data of the driver. Only the resources marked as
+created by gpac when they were created are considered, resources created by other means are never orphans. Drivers
+give the creation time of the orphans so that the resources being created by a running operation are not collected.
+Bookkeeping data is listed before the resources, the data of a resource being created is never an orphan
+Orphans whose creation time is unknown are only collected on demand (see GCOptions)
+*/
+type GarbageCollector interface {
+	//Orphans lists the orphaned resources in the order they must be deleted
+	Orphans() ([]Orphan, error)
+	//DeleteOrphan deletes an orphan returned by Orphans
+	DeleteOrphan(o Orphan) error
+}
+
+//DefaultGCMinAge minimum age of the orphans collected by default
+const DefaultGCMinAge = time.Hour
+
+//GCOptions options of CollectGarbage
+type GCOptions struct {
+	//Delete deletes the orphans, they are only listed otherwise
+	Delete bool
+	//MinAge resources created for less than MinAge are not collected, DefaultGCMinAge is used if 0
+	MinAge time.Duration
+	//UnknownAge collects the orphans whose creation time is unknown too, they may be in use by a running operation
+	UnknownAge bool
+}
+
+//GCReport result of CollectGarbage
+type GCReport struct {
+	Orphans []Orphan `json:"orphans"`
+	//Recent number of orphans created for less than the minimum age, they are not collected nor listed
+	Recent int `json:"recent"`
+	//Unknown number of orphans whose creation time is unknown, they are not collected nor listed unless
+	//GCOptions.UnknownAge is true
+	Unknown int `json:"unknown"`
+	//Deleted number of deleted orphans
+	Deleted int `json:"deleted"`
+	//Failed number of orphans that could not be deleted, the error is in Orphan.Error
+	Failed int `json:"failed"`
+}
+
+//CollectGarbage finds the orphans of gc older than the minimum age and deletes them if opts.Delete is true
+//An orphan already deleted when it is collected is reported as deleted
+func CollectGarbage(gc GarbageCollector, opts GCOptions) (*GCReport, error) {
+	if opts.MinAge <= 0 {
+		opts.MinAge = DefaultGCMinAge
+	}
+	orphans, err := gc.Orphans()
+	if err != nil {
+		return nil, err
+	}
+	report := GCReport{Orphans: []Orphan{}}
+	limit := time.Now().Add(-opts.MinAge)
+	for _, o := range orphans {
+		if o.Created.IsZero() && !opts.UnknownAge {
+			report.Unknown++
+			continue
+		}
+		if o.Created.After(limit) {
+			report.Recent++
+			continue
+		}
+		report.Orphans = append(report.Orphans, o)
+	}
+	if !opts.Delete {
+		return &report, nil
+	}
+	for i := range report.Orphans {
+		o := &report.Orphans[i]
+		err := gc.DeleteOrphan(*o)
+		if err != nil && api.KindOf(err) != api.ErrNotFound {
+			o.Error = err.Error()
+			report.Failed++
+			continue
+		}
+		o.Deleted = true
+		report.Deleted++
+	}
+	return &report, nil
+}
+
+//temporaryKeyPair matches the names of the key pairs created for a single VM creation: a UUID, optionally
+//prefixed by the name of the VM (<name>_<uuid>)
+var temporaryKeyPair = regexp.MustCompile(`(^|_)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+//TemporaryKeyPair tells if name is the name of a key pair created for a single VM creation, such key pairs are
+//deleted once the VM is created
+func TemporaryKeyPair(name string) bool {
+	return temporaryKeyPair.MatchString(name)
+}
+
+//MetadataOrphans lists the keys of bucket of store not in live as orphans, live holds the IDs of the existing
+//resources. The keys must be listed before the resources, a resource is recorded once it is created
+//The creation time of an orphan is the time its record was written, it is zero if the record cannot be read
+func MetadataOrphans(store MetadataStore, bucket string, keys []string, live map[string]bool, reason string) []Orphan {
+	orphans := []Orphan{}
+	for _, key := range keys {
+		if live[key] {
+			continue
+		}
+		b, _, err := store.Get(bucket, key)
+		if api.KindOf(err) == api.ErrNotFound {
+			//deleted since it was listed
+			continue
+		}
+		rec := Record{}
+		if err == nil {
+			json.Unmarshal(b, &rec)
+		}
+		orphans = append(orphans, Orphan{
+			Kind:    OrphanMetadata,
+			ID:      bucket + "/" + key,
+			Reason:  reason,
+			Created: rec.Written,
+		})
+	}
+	return orphans
+}
+
+//DeleteMetadataOrphan deletes the key of the metadata orphan o
+func DeleteMetadataOrphan(store MetadataStore, o Orphan) error {
+	parts := strings.SplitN(o.ID, "/", 2)
+	if o.Kind != OrphanMetadata || len(parts) != 2 {
+		return api.NewError(api.ErrInvalidRequest, nil, "Invalid metadata orphan %s", o.ID)
+	}
+	return store.Delete(parts[0], parts[1])
+}
+
+//UnknownOrphanError returns the error of the deletion of an orphan of a kind the driver does not collect
+func UnknownOrphanError(o Orphan) error {
+	return api.NewError(api.ErrInvalidRequest, nil, "Invalid orphan %s: unknown kind %s", o.ID, o.Kind)
+}
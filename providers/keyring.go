@@ -0,0 +1,344 @@
+package providers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//MasterKeySize size in bytes of the master keys and of the data keys
+const MasterKeySize = 32
+
+//sealedFields fields of the records encrypted by SealedMetadataStore
+var sealedFields = []string{"private_key"}
+
+//NewMasterKey generates a random master key and its ID
+func NewMasterKey() (string, []byte, error) {
+	key := make([]byte, MasterKeySize)
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return "", nil, fmt.Errorf("Error generating master key: %s", err.Error())
+	}
+	id := make([]byte, 8)
+	_, err = io.ReadFull(rand.Reader, id)
+	if err != nil {
+		return "", nil, fmt.Errorf("Error generating master key: %s", err.Error())
+	}
+	return "mk-" + hex.EncodeToString(id), key, nil
+}
+
+//SealedSecret secret encrypted with envelope encryption
+//The secret is encrypted with a random data key, the data key is encrypted with the master key KeyID
+type SealedSecret struct {
+	KeyID string `json:"key_id"`
+	//DataKey data key encrypted by the master key, base64 encoded
+	DataKey string `json:"data_key"`
+	//Ciphertext secret encrypted by the data key, base64 encoded
+	Ciphertext string `json:"ciphertext"`
+}
+
+//Keyring master keys of a tenant indexed by ID, secrets are sealed with the primary key and opened with the key
+//that sealed them
+type Keyring struct {
+	Primary string
+	keys    map[string]*Cipher
+}
+
+//NewKeyring creates a keyring from master keys indexed by ID, primary is the ID of the key sealing new secrets
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("Error creating keyring: primary key %s not found", primary)
+	}
+	k := Keyring{
+		Primary: primary,
+		keys:    map[string]*Cipher{},
+	}
+	for id, key := range keys {
+		c, err := NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating keyring: master key %s: %s", id, err.Error())
+		}
+		k.keys[id] = c
+	}
+	return &k, nil
+}
+
+//Seal encrypts secret with a new data key encrypted by the primary key, the ciphertext is bound to ad (e.g. the
+//location of the secret) and can only be opened with the same ad
+func (k *Keyring) Seal(secret []byte, ad string) (*SealedSecret, error) {
+	dataKey := make([]byte, MasterKeySize)
+	_, err := io.ReadFull(rand.Reader, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error encrypting secret: %s", err.Error())
+	}
+	c, err := NewCipher(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error encrypting secret: %s", err.Error())
+	}
+	s := SealedSecret{KeyID: k.Primary}
+	s.Ciphertext, err = c.Seal(secret, ad)
+	if err != nil {
+		return nil, fmt.Errorf("Error encrypting secret: %s", err.Error())
+	}
+	//the data key is bound to the ID of the master key encrypting it
+	s.DataKey, err = k.keys[k.Primary].Seal(dataKey, k.Primary)
+	if err != nil {
+		return nil, fmt.Errorf("Error encrypting secret: %s", err.Error())
+	}
+	return &s, nil
+}
+
+//Open decrypts a secret sealed with ad
+func (k *Keyring) Open(s *SealedSecret, ad string) ([]byte, error) {
+	master, ok := k.keys[s.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("Error decrypting secret: master key %s not found", s.KeyID)
+	}
+	dataKey, err := master.Open(s.DataKey, s.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("Error decrypting secret: invalid data key")
+	}
+	c, err := NewCipher(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error decrypting secret: invalid data key")
+	}
+	secret, err := c.Open(s.Ciphertext, ad)
+	if err != nil {
+		return nil, fmt.Errorf("Error decrypting secret: invalid ciphertext")
+	}
+	return secret, nil
+}
+
+/*SealedMetadataStore decorates a MetadataStore with the encryption of the secrets of the records
+The private keys of the records are replaced by a sealed_private_key object (see SealedSecret) before they are
+written and decrypted when they are read, so that a reader of the underlying store (e.g. of the object storage of
+the tenant) cannot get them. Values which are not records are stored unchanged
+A secret is bound to the bucket, the key and the field it is stored in, a sealed secret copied to another record
+cannot be opened
+*/
+type SealedMetadataStore struct {
+	MetadataStore
+	Keyring *Keyring
+}
+
+//NewSealedMetadataStore creates a store encrypting the secrets of the records written in store with keyring
+func NewSealedMetadataStore(store MetadataStore, keyring *Keyring) *SealedMetadataStore {
+	return &SealedMetadataStore{
+		MetadataStore: store,
+		Keyring:       keyring,
+	}
+}
+
+//WithClient returns a copy of the store whose underlying store sends its requests with clt, s is returned if the
+//underlying store does not use the client of the provider
+func (s *SealedMetadataStore) WithClient(clt api.ClientAPI) MetadataStore {
+	store, ok := s.MetadataStore.(ClientMetadataStore)
+	if !ok {
+		return s
+	}
+	return NewSealedMetadataStore(store.WithClient(clt), s.Keyring)
+}
+
+//recordFields decodes value as a record and the fields of its data, rec is nil if value is not a record and
+//fields is nil if the data of the record is not an object
+func recordFields(value []byte) (*Record, map[string]json.RawMessage) {
+	rec := Record{}
+	if json.Unmarshal(value, &rec) != nil || rec.Schema == 0 {
+		return nil, nil
+	}
+	fields := map[string]json.RawMessage{}
+	if json.Unmarshal(rec.Data, &fields) != nil {
+		return &rec, nil
+	}
+	return &rec, fields
+}
+
+//encodeFields encodes rec with the fields as data
+func encodeFields(rec *Record, fields map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	rec.Data = data
+	return json.Marshal(rec)
+}
+
+//secretLocation associated data of the secret stored in field of the record key of bucket
+func secretLocation(bucket string, key string, field string) string {
+	return bucket + "/" + key + "/" + field
+}
+
+//seal encrypts the secrets of value, the value of key in bucket
+func (s *SealedMetadataStore) seal(bucket string, key string, value []byte) ([]byte, error) {
+	rec, fields := recordFields(value)
+	if fields == nil {
+		return value, nil
+	}
+	changed := false
+	for _, f := range sealedFields {
+		secret := ""
+		if json.Unmarshal(fields[f], &secret) != nil || secret == "" {
+			continue
+		}
+		sealed, err := s.Keyring.Seal([]byte(secret), secretLocation(bucket, key, f))
+		if err != nil {
+			return nil, err
+		}
+		fields["sealed_"+f], err = json.Marshal(sealed)
+		if err != nil {
+			return nil, err
+		}
+		delete(fields, f)
+		changed = true
+	}
+	if !changed {
+		return value, nil
+	}
+	return encodeFields(rec, fields)
+}
+
+//unseal decrypts the secrets of value, the value of key in bucket
+func (s *SealedMetadataStore) unseal(bucket string, key string, value []byte) ([]byte, error) {
+	rec, fields := recordFields(value)
+	if fields == nil {
+		return value, nil
+	}
+	changed := false
+	for _, f := range sealedFields {
+		raw, ok := fields["sealed_"+f]
+		if !ok {
+			continue
+		}
+		sealed := SealedSecret{}
+		err := json.Unmarshal(raw, &sealed)
+		if err != nil {
+			return nil, fmt.Errorf("Error decrypting secret: %s", err.Error())
+		}
+		secret, err := s.Keyring.Open(&sealed, secretLocation(bucket, key, f))
+		if err != nil {
+			return nil, err
+		}
+		fields[f], err = json.Marshal(string(secret))
+		if err != nil {
+			return nil, err
+		}
+		delete(fields, "sealed_"+f)
+		changed = true
+	}
+	if !changed {
+		return value, nil
+	}
+	return encodeFields(rec, fields)
+}
+
+//sealedWith tells if the secrets of value are all sealed with the master key id, it is true for values
+//without secrets
+func sealedWith(value []byte, id string) bool {
+	_, fields := recordFields(value)
+	for _, f := range sealedFields {
+		if _, ok := fields[f]; ok {
+			return false
+		}
+		raw, ok := fields["sealed_"+f]
+		if !ok {
+			continue
+		}
+		sealed := SealedSecret{}
+		if json.Unmarshal(raw, &sealed) != nil || sealed.KeyID != id {
+			return false
+		}
+	}
+	return true
+}
+
+//Get returns the value of key with its secrets decrypted
+func (s *SealedMetadataStore) Get(bucket string, key string) ([]byte, string, error) {
+	value, version, err := s.MetadataStore.Get(bucket, key)
+	if err != nil {
+		return nil, "", err
+	}
+	value, err = s.unseal(bucket, key, value)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error reading metadata %s/%s: %s", bucket, key, err.Error())
+	}
+	return value, version, nil
+}
+
+//Put writes the value of key with its secrets encrypted
+func (s *SealedMetadataStore) Put(bucket string, key string, value []byte) (string, error) {
+	value, err := s.seal(bucket, key, value)
+	if err != nil {
+		return "", fmt.Errorf("Error writing metadata %s/%s: %s", bucket, key, err.Error())
+	}
+	return s.MetadataStore.Put(bucket, key, value)
+}
+
+//CompareAndSwap writes the value of key with its secrets encrypted if its current version is version
+func (s *SealedMetadataStore) CompareAndSwap(bucket string, key string, value []byte, version string) (string, error) {
+	value, err := s.seal(bucket, key, value)
+	if err != nil {
+		return "", fmt.Errorf("Error writing metadata %s/%s: %s", bucket, key, err.Error())
+	}
+	return s.MetadataStore.CompareAndSwap(bucket, key, value, version)
+}
+
+//ResealRecords rewrites the records of buckets whose secrets are in clear or sealed with another key than the
+//primary key of the keyring of store, records written before records existed are converted at the same time
+func ResealRecords(store *SealedMetadataStore, buckets []RecordBucket) (*MigrationReport, error) {
+	report := MigrationReport{
+		Migrated: []string{},
+		Failed:   map[string]string{},
+	}
+	for _, bucket := range buckets {
+		keys, err := store.List(bucket.Bucket, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			name := bucket.Bucket + "/" + key
+			err := resealRecord(store, bucket, key)
+			switch {
+			case errors.Is(err, errRecordCurrent):
+				report.Current++
+			case errors.Is(err, api.ErrNotFound):
+				//deleted since it was listed
+			case err != nil:
+				report.Failed[name] = err.Error()
+			default:
+				report.Migrated = append(report.Migrated, name)
+			}
+		}
+	}
+	return &report, nil
+}
+
+//resealRecord rewrites the record key of bucket with its secrets sealed with the primary key
+func resealRecord(store *SealedMetadataStore, bucket RecordBucket, key string) error {
+	raw, version, err := store.MetadataStore.Get(bucket.Bucket, key)
+	if err != nil {
+		return err
+	}
+	if rec, _ := recordFields(raw); rec != nil && sealedWith(raw, store.Keyring.Primary) {
+		return errRecordCurrent
+	}
+	value, err := store.unseal(bucket.Bucket, key, raw)
+	if err != nil {
+		return err
+	}
+	v := bucket.New()
+	_, err = decodeRecord(bucket.Kind, value, v)
+	if err != nil {
+		return err
+	}
+	value, err = EncodeRecord(bucket.Kind, v)
+	if err != nil {
+		return err
+	}
+	_, err = store.CompareAndSwap(bucket.Bucket, key, value, version)
+	return err
+}
@@ -0,0 +1,206 @@
+package providers
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//newTestKeyring returns a keyring whose primary key is the first of ids, the other keys are only used to open
+func newTestKeyring(t *testing.T, keys map[string][]byte, ids ...string) *Keyring {
+	for _, id := range ids {
+		if _, ok := keys[id]; ok {
+			continue
+		}
+		_, key, err := NewMasterKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[id] = key
+	}
+	selected := map[string][]byte{}
+	for _, id := range ids {
+		selected[id] = keys[id]
+	}
+	k, err := NewKeyring(ids[0], selected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+//newTestStore returns a bolt store in a temporary directory
+func newTestStore(t *testing.T) MetadataStore {
+	store := NewBoltMetadataStore(filepath.Join(t.TempDir(), "metadata.db"))
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+var vmBucket = RecordBucket{Bucket: "vms", Kind: RecordVM, New: func() interface{} { return new(api.VM) }}
+
+func TestSealOpen(t *testing.T) {
+	keys := map[string][]byte{}
+	k := newTestKeyring(t, keys, "mk-1")
+	s, err := k.Seal([]byte("secret"), "vms/vm1/private_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.KeyID != "mk-1" || strings.Contains(s.Ciphertext, "secret") {
+		t.Fatalf("unexpected sealed secret %+v", s)
+	}
+	secret, err := k.Open(s, "vms/vm1/private_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, []byte("secret")) {
+		t.Fatalf("expected secret, got %q", secret)
+	}
+	//the secret is bound to its location
+	_, err = k.Open(s, "vms/vm2/private_key")
+	if err == nil {
+		t.Fatal("secret opened with another associated data")
+	}
+}
+
+func TestOpenWrongKey(t *testing.T) {
+	keys := map[string][]byte{}
+	k := newTestKeyring(t, keys, "mk-1")
+	s, err := k.Seal([]byte("secret"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//unknown master key
+	_, err = newTestKeyring(t, keys, "mk-2").Open(s, "")
+	if err == nil || !strings.Contains(err.Error(), "mk-1 not found") {
+		t.Fatalf("expected a missing master key error, got %v", err)
+	}
+	//data key sealed by another master key under the same ID
+	other := newTestKeyring(t, map[string][]byte{}, "mk-1")
+	_, err = other.Open(s, "")
+	if err == nil {
+		t.Fatal("secret opened with another master key")
+	}
+	//data key bound to the ID of its master key
+	s.KeyID = "mk-2"
+	_, err = newTestKeyring(t, map[string][]byte{"mk-2": keys["mk-1"]}, "mk-2").Open(s, "")
+	if err == nil {
+		t.Fatal("secret opened with the master key under another ID")
+	}
+}
+
+func TestSealedMetadataStore(t *testing.T) {
+	store := newTestStore(t)
+	sealed := NewSealedMetadataStore(store, newTestKeyring(t, map[string][]byte{}, "mk-1"))
+	b, err := EncodeRecord(RecordVM, api.VM{ID: "vm1", PrivateKey: "private"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sealed.Put("vms", "vm1", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _, err := store.Get("vms", "vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte(`"private"`)) || !bytes.Contains(raw, []byte("sealed_private_key")) {
+		t.Fatalf("private key stored in clear: %s", raw)
+	}
+	vm := api.VM{}
+	b, _, err = sealed.Get("vms", "vm1")
+	if err == nil {
+		err = DecodeRecord(RecordVM, b, &vm)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.PrivateKey != "private" {
+		t.Fatalf("expected the private key to be decrypted, got %+v", vm)
+	}
+	//a sealed private key copied to another record cannot be read
+	_, err = store.Put("vms", "vm2", raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = sealed.Get("vms", "vm2")
+	if err == nil {
+		t.Fatal("private key copied to another record decrypted")
+	}
+}
+
+func TestResealRecords(t *testing.T) {
+	store := newTestStore(t)
+	keys := map[string][]byte{}
+	old := NewSealedMetadataStore(store, newTestKeyring(t, keys, "mk-1"))
+	put := func(s MetadataStore, id string) {
+		b, err := EncodeRecord(RecordVM, api.VM{ID: id, PrivateKey: "private-" + id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Put("vms", id, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	//a record sealed with the previous key and a record in clear
+	put(old, "vm1")
+	put(store, "vm2")
+
+	//rotation: the new key is the primary key, the previous key is kept to open the records
+	rotated := NewSealedMetadataStore(store, newTestKeyring(t, keys, "mk-2", "mk-1"))
+	report, err := ResealRecords(rotated, []RecordBucket{vmBucket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Migrated) != 2 || len(report.Failed) != 0 {
+		t.Fatalf("expected 2 records resealed, got %+v", report)
+	}
+	report, err = ResealRecords(rotated, []RecordBucket{vmBucket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Migrated) != 0 || report.Current != 2 {
+		t.Fatalf("expected the records to be current, got %+v", report)
+	}
+
+	//the previous key is not needed anymore
+	current := NewSealedMetadataStore(store, newTestKeyring(t, keys, "mk-2"))
+	for _, id := range []string{"vm1", "vm2"} {
+		b, _, err := current.Get("vms", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vm := api.VM{}
+		err = DecodeRecord(RecordVM, b, &vm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vm.PrivateKey != "private-"+id {
+			t.Fatalf("expected the private key of %s, got %+v", id, vm)
+		}
+	}
+}
+
+func TestResealRecordsMissingKey(t *testing.T) {
+	store := newTestStore(t)
+	keys := map[string][]byte{}
+	b, err := EncodeRecord(RecordVM, api.VM{ID: "vm1", PrivateKey: "private"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewSealedMetadataStore(store, newTestKeyring(t, keys, "mk-1")).Put("vms", "vm1", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the record sealed with a key which is not in the keyring is reported and left unchanged
+	report, err := ResealRecords(NewSealedMetadataStore(store, newTestKeyring(t, keys, "mk-2")), []RecordBucket{vmBucket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := report.Failed["vms/vm1"]; !ok || len(report.Migrated) != 0 {
+		t.Fatalf("expected vms/vm1 to fail, got %+v", report)
+	}
+}
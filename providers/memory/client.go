@@ -0,0 +1,216 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/system"
+)
+
+//DefaultTransitionDelay time taken by a resource to reach a stable state when AuthOptions.TransitionDelay is not set
+const DefaultTransitionDelay = 200 * time.Millisecond
+
+//DefaultImages images exposed by the driver when AuthOptions.Images is empty
+var DefaultImages = []api.Image{
+	{ID: "img-ubuntu-1604", Name: "Ubuntu 16.04"},
+	{ID: "img-ubuntu-1804", Name: "Ubuntu 18.04"},
+	{ID: "img-debian-9", Name: "Debian 9"},
+	{ID: "img-centos-7", Name: "CentOS 7"},
+}
+
+//DefaultTemplates templates exposed by the driver when AuthOptions.Templates is empty
+var DefaultTemplates = []api.VMTemplate{
+	{ID: "tpl-s1-2", Name: "s1-2", VMSize: api.VMSize{Cores: 1, RAMSize: 2, DiskSize: 10}},
+	{ID: "tpl-s1-4", Name: "s1-4", VMSize: api.VMSize{Cores: 1, RAMSize: 4, DiskSize: 20}},
+	{ID: "tpl-s1-8", Name: "s1-8", VMSize: api.VMSize{Cores: 2, RAMSize: 8, DiskSize: 40}},
+	{ID: "tpl-b2-15", Name: "b2-15", VMSize: api.VMSize{Cores: 4, RAMSize: 15, DiskSize: 100}},
+	{ID: "tpl-b2-30", Name: "b2-30", VMSize: api.VMSize{Cores: 8, RAMSize: 30, DiskSize: 200}},
+}
+
+/*AuthOptions options of the in-memory driver
+The driver does not authenticate against anything, the options only tune the simulation
+*/
+type AuthOptions struct {
+	//TransitionDelay time taken by a resource to move from a transient state (STARTING, CREATING ...)
+	//to a stable one (STARTED, AVAILABLE ...)
+	TransitionDelay time.Duration
+	//Images OS images exposed by the driver, DefaultImages is used if empty
+	Images []api.Image
+	//Templates VM templates exposed by the driver, DefaultTemplates is used if empty
+	Templates []api.VMTemplate
+	//PublicCIDR network in which public IPs are allocated
+	PublicCIDR string
+}
+
+//driverError creates an api.Error caused by err, the kind of err is kept
+func driverError(err error, format string, args ...interface{}) error {
+	return api.NewError(api.KindOf(err), err, format, args...)
+}
+
+//AuthenticatedClient returns an in-memory client
+func AuthenticatedClient(opts AuthOptions) (*Client, error) {
+	if opts.TransitionDelay == 0 {
+		opts.TransitionDelay = DefaultTransitionDelay
+	}
+	if len(opts.Images) == 0 {
+		opts.Images = DefaultImages
+	}
+	if len(opts.Templates) == 0 {
+		opts.Templates = DefaultTemplates
+	}
+	if opts.PublicCIDR == "" {
+		opts.PublicCIDR = "203.0.113.0/24"
+	}
+	if _, err := ipAt(opts.PublicCIDR, 1); err != nil {
+		return nil, api.NewError(api.ErrInvalidRequest, err, "Invalid public CIDR %s", opts.PublicCIDR)
+	}
+	return &Client{
+		Opts:       &opts,
+		keyPairs:   make(map[string]*api.KeyPair),
+		networks:   make(map[string]*network),
+		vms:        make(map[string]*vm),
+		volumes:    make(map[string]*volume),
+		containers: make(map[string]map[string]*object),
+		publicIPs:  make(map[string]bool),
+	}, nil
+}
+
+//Client is the implementation of the in-memory driver regarding to the api.ClientAPI
+//All resources live in process and are lost when the client is garbage collected
+type Client struct {
+	Opts *AuthOptions
+
+	mu         sync.Mutex
+	keyPairs   map[string]*api.KeyPair
+	networks   map[string]*network
+	vms        map[string]*vm
+	volumes    map[string]*volume
+	containers map[string]map[string]*object
+	publicIPs  map[string]bool
+}
+
+//transition simulates an asynchronous state change
+//the resource reaches state target once at is passed
+type transition struct {
+	target int
+	at     time.Time
+}
+
+//schedule plans a state change to target after the configured transition delay
+func (client *Client) schedule(target int) *transition {
+	return &transition{
+		target: target,
+		at:     time.Now().Add(client.Opts.TransitionDelay),
+	}
+}
+
+//done tells if the transition is completed
+func (t *transition) done() bool {
+	return t != nil && !time.Now().Before(t.at)
+}
+
+//ListImages lists available OS images
+func (client *Client) ListImages() ([]api.Image, error) {
+	imgs := make([]api.Image, len(client.Opts.Images))
+	copy(imgs, client.Opts.Images)
+	return imgs, nil
+}
+
+//GetImage returns the Image referenced by id
+func (client *Client) GetImage(id string) (*api.Image, error) {
+	for _, img := range client.Opts.Images {
+		if img.ID == id {
+			i := img
+			return &i, nil
+		}
+	}
+	return nil, providers.ResourceNotFoundError("Image", id)
+}
+
+//GetTemplate returns the Template referenced by id
+func (client *Client) GetTemplate(id string) (*api.VMTemplate, error) {
+	for _, tpl := range client.Opts.Templates {
+		if tpl.ID == id {
+			t := tpl
+			return &t, nil
+		}
+	}
+	return nil, providers.ResourceNotFoundError("Template", id)
+}
+
+//ListTemplates lists available VM templates
+//VM templates are sorted using Dominant Resource Fairness Algorithm
+func (client *Client) ListTemplates() ([]api.VMTemplate, error) {
+	tpls := make([]api.VMTemplate, len(client.Opts.Templates))
+	copy(tpls, client.Opts.Templates)
+	sort.Sort(providers.ByRankDRF(tpls))
+	return tpls, nil
+}
+
+//CreateKeyPair creates and import a key pair
+func (client *Client) CreateKeyPair(name string) (*api.KeyPair, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if _, ok := client.keyPairs[name]; ok {
+		return nil, providers.ResourceAlreadyExistsError("KeyPair", name)
+	}
+	pub, pri, err := system.CreateKeyPair()
+	if err != nil {
+		return nil, driverError(err, "Error creating key pair")
+	}
+	kp := api.KeyPair{
+		ID:         name,
+		Name:       name,
+		PublicKey:  string(pub),
+		PrivateKey: string(pri),
+	}
+	client.keyPairs[name] = &kp
+	res := kp
+	return &res, nil
+}
+
+//GetKeyPair returns the key pair identified by id
+//As with real providers the private key is not returned
+func (client *Client) GetKeyPair(id string) (*api.KeyPair, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	kp, ok := client.keyPairs[id]
+	if !ok {
+		return nil, providers.ResourceNotFoundError("KeyPair", id)
+	}
+	return &api.KeyPair{
+		ID:        kp.ID,
+		Name:      kp.Name,
+		PublicKey: kp.PublicKey,
+	}, nil
+}
+
+//ListKeyPairs lists available key pairs
+func (client *Client) ListKeyPairs() ([]api.KeyPair, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	var kps []api.KeyPair
+	for _, kp := range client.keyPairs {
+		kps = append(kps, api.KeyPair{
+			ID:        kp.ID,
+			Name:      kp.Name,
+			PublicKey: kp.PublicKey,
+		})
+	}
+	sort.Slice(kps, func(i, j int) bool { return kps[i].Name < kps[j].Name })
+	return kps, nil
+}
+
+//DeleteKeyPair deletes the key pair identified by id
+func (client *Client) DeleteKeyPair(id string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if _, ok := client.keyPairs[id]; !ok {
+		return providers.ResourceNotFoundError("KeyPair", id)
+	}
+	delete(client.keyPairs, id)
+	return nil
+}
@@ -0,0 +1,249 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+	"github.com/SebastienDorgan/gpac/providers/api/VMState"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeState"
+	"github.com/SebastienDorgan/gpac/system"
+	uuid "github.com/satori/go.uuid"
+)
+
+//vm in-memory representation of a VM
+type vm struct {
+	api.VM
+	networkIDs []string
+	//ips private IPs of the VM indexed by network ID
+	ips     map[string]string
+	pending *transition
+}
+
+//refresh applies the pending state change if it is completed
+func (v *vm) refresh() {
+	if v.pending.done() {
+		v.State = VMState.Enum(v.pending.target)
+		v.pending = nil
+	}
+}
+
+//isConnected tells if the VM is connected to the network identified by netID
+func (v *vm) isConnected(netID string) bool {
+	for _, id := range v.networkIDs {
+		if id == netID {
+			return true
+		}
+	}
+	return false
+}
+
+//toVM returns a copy of the api.VM
+func (v *vm) toVM() *api.VM {
+	res := v.VM
+	res.PrivateIPsV4 = append([]string(nil), v.PrivateIPsV4...)
+	res.PrivateIPsV6 = append([]string(nil), v.PrivateIPsV6...)
+	return &res
+}
+
+//CreateVM creates a VM satisfying request
+func (client *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
+	if len(request.NetworkIDs) == 0 {
+		return nil, api.NewError(api.ErrInvalidRequest, nil, "Error creating VM: at least one network is required")
+	}
+	tpl, err := client.GetTemplate(request.TemplateID)
+	if err != nil {
+		return nil, driverError(err, "Error creating VM")
+	}
+	_, err = client.GetImage(request.ImageID)
+	if err != nil {
+		return nil, driverError(err, "Error creating VM")
+	}
+
+	//Prepare key pair
+	kp := request.KeyPair
+	if kp == nil {
+		name := fmt.Sprintf("%s_%s", request.Name, uuid.NewV4())
+		kp, err = client.CreateKeyPair(name)
+		if err != nil {
+			return nil, driverError(err, "Error creating VM")
+		}
+		defer client.DeleteKeyPair(kp.ID)
+	}
+
+	client.mu.Lock()
+	v := vm{
+		VM: api.VM{
+			ID:         uuid.NewV4().String(),
+			Name:       request.Name,
+			Size:       tpl.VMSize,
+			State:      VMState.STARTING,
+			PrivateKey: kp.PrivateKey,
+		},
+		networkIDs: append([]string(nil), request.NetworkIDs...),
+		ips:        map[string]string{},
+		pending:    client.schedule(int(VMState.STARTED)),
+	}
+	//The IPs allocated on the networks are released if the VM cannot be created
+	release := func() {
+		client.releaseIPs(&v)
+		client.mu.Unlock()
+	}
+	for i, netID := range request.NetworkIDs {
+		n, ok := client.networks[netID]
+		if !ok {
+			release()
+			return nil, driverError(providers.ResourceNotFoundError("Network", netID), "Error creating VM")
+		}
+		//If the VM is not public it is connected to the gateway of its first network
+		if i == 0 && !request.PublicIP {
+			gw, ok := client.vms[n.GatewayID]
+			if !ok {
+				release()
+				return nil, api.NewError(api.ErrNotFound, nil, "Error creating VM: Enable to found Gateway of network %s", n.Name)
+			}
+			v.GatewayID = gw.ID
+		}
+		ip, err := n.allocateIP()
+		if err != nil {
+			release()
+			return nil, driverError(err, "Error creating VM")
+		}
+		v.ips[netID] = ip
+		if n.IPVersion == IPVersion.IPv6 {
+			v.PrivateIPsV6 = append(v.PrivateIPsV6, ip)
+		} else {
+			v.PrivateIPsV4 = append(v.PrivateIPsV4, ip)
+		}
+	}
+	if request.PublicIP {
+		ip, err := client.allocatePublicIP()
+		if err != nil {
+			release()
+			return nil, driverError(err, "Error creating VM")
+		}
+		v.AccessIPv4 = ip
+	}
+	client.vms[v.ID] = &v
+	client.mu.Unlock()
+
+	//Wait that VM is started
+	service := providers.Service{
+		ClientAPI: client,
+	}
+	res, err := service.WaitVMState(v.ID, VMState.STARTED, 120*time.Second)
+	if err != nil {
+		client.DeleteVM(v.ID)
+		return nil, driverError(err, "Timeout creating VM")
+	}
+	return res, nil
+}
+
+//GetVM returns the VM identified by id
+func (client *Client) GetVM(id string) (*api.VM, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	v, ok := client.vms[id]
+	if !ok {
+		return nil, providers.ResourceNotFoundError("VM", id)
+	}
+	v.refresh()
+	return v.toVM(), nil
+}
+
+//ListVMs lists available VMs
+func (client *Client) ListVMs() ([]api.VM, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	var vms []api.VM
+	for _, v := range client.vms {
+		v.refresh()
+		vms = append(vms, *v.toVM())
+	}
+	sort.Slice(vms, func(i, j int) bool { return vms[i].Name < vms[j].Name })
+	return vms, nil
+}
+
+//DeleteVM deletes the VM identified by id
+//Volumes attached to the VM are detached and its IPs are released
+func (client *Client) DeleteVM(id string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	server, ok := client.vms[id]
+	if !ok {
+		return providers.ResourceNotFoundError("VM", id)
+	}
+	client.releaseIPs(server)
+	for _, v := range client.volumes {
+		if v.attachment != nil && v.attachment.ServerID == id {
+			v.attachment = nil
+			v.State = VolumeState.AVAILABLE
+		}
+	}
+	delete(client.vms, id)
+	return nil
+}
+
+//StopVM stops the VM identified by id
+func (client *Client) StopVM(id string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	v, ok := client.vms[id]
+	if !ok {
+		return providers.ResourceNotFoundError("VM", id)
+	}
+	v.refresh()
+	if v.State != VMState.STARTED {
+		return api.NewError(api.ErrConflict, nil, "Error stoping VM : VM %s is %s", id, v.State)
+	}
+	v.State = VMState.STOPPING
+	v.pending = client.schedule(int(VMState.STOPPED))
+	return nil
+}
+
+//StartVM starts the VM identified by id
+func (client *Client) StartVM(id string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	v, ok := client.vms[id]
+	if !ok {
+		return providers.ResourceNotFoundError("VM", id)
+	}
+	v.refresh()
+	if v.State != VMState.STOPPED {
+		return api.NewError(api.ErrConflict, nil, "Error starting VM : VM %s is %s", id, v.State)
+	}
+	v.State = VMState.STARTING
+	v.pending = client.schedule(int(VMState.STARTED))
+	return nil
+}
+
+//GetSSHConfig creates SSHConfig to connect a VM
+func (client *Client) GetSSHConfig(id string) (*system.SSHConfig, error) {
+	vm, err := client.GetVM(id)
+	if err != nil {
+		return nil, err
+	}
+	sshConfig := system.SSHConfig{
+		PrivateKey: vm.PrivateKey,
+		Port:       22,
+		Host:       vm.GetAccessIP(),
+		User:       api.DefaultUser,
+	}
+	if vm.GatewayID != "" {
+		gw, err := client.GetVM(vm.GatewayID)
+		if err != nil {
+			return nil, err
+		}
+		sshConfig.GatewayConfig = &system.SSHConfig{
+			PrivateKey: gw.PrivateKey,
+			Port:       22,
+			User:       api.DefaultUser,
+			Host:       gw.GetAccessIP(),
+		}
+	}
+	return &sshConfig, nil
+}
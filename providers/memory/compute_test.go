@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+)
+
+func TestCreateVMReleasesIPs(t *testing.T) {
+	clt, err := AuthenticatedClient(AuthOptions{TransitionDelay: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := clt.CreateNetwork(api.NetworkRequest{
+		Name:      "net1",
+		IPVersion: IPVersion.IPv4,
+		CIDR:      "192.168.1.0/24",
+		GWRequest: api.VMRequest{Name: "gw", TemplateID: DefaultTemplates[0].ID, ImageID: DefaultImages[0].ID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := api.VMRequest{
+		Name:       "vm1",
+		TemplateID: DefaultTemplates[0].ID,
+		ImageID:    DefaultImages[0].ID,
+		NetworkIDs: []string{n.ID, "unknown"},
+	}
+	_, err = clt.CreateVM(req)
+	if api.KindOf(err) != api.ErrNotFound {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	//the IP allocated on net1 by the failed creation is allocated again
+	req.NetworkIDs = []string{n.ID}
+	vm, err := clt.CreateVM(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vm.PrivateIPsV4) != 1 || vm.PrivateIPsV4[0] != "192.168.1.3" {
+		t.Fatalf("expected the IP 192.168.1.3, got %v", vm.PrivateIPsV4)
+	}
+}
+
+func TestDeleteVMReleasesIPs(t *testing.T) {
+	//2 public IPs: one for the gateway, one for the VM
+	clt, err := AuthenticatedClient(AuthOptions{TransitionDelay: 10 * time.Millisecond, PublicCIDR: "203.0.113.0/30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//2 private IPs: one for the gateway, one for the VM
+	n, err := clt.CreateNetwork(api.NetworkRequest{
+		Name:      "net1",
+		IPVersion: IPVersion.IPv4,
+		CIDR:      "192.168.1.0/30",
+		GWRequest: api.VMRequest{Name: "gw", TemplateID: DefaultTemplates[0].ID, ImageID: DefaultImages[0].ID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := api.VMRequest{
+		Name:       "vm1",
+		TemplateID: DefaultTemplates[0].ID,
+		ImageID:    DefaultImages[0].ID,
+		NetworkIDs: []string{n.ID},
+		PublicIP:   true,
+	}
+	for i := 0; i < 3; i++ {
+		vm, err := clt.CreateVM(req)
+		if err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+		if vm.PrivateIPsV4[0] != "192.168.1.3" || vm.AccessIPv4 != "203.0.113.2" {
+			t.Fatalf("expected the IPs 192.168.1.3 and 203.0.113.2, got %v and %s", vm.PrivateIPsV4, vm.AccessIPv4)
+		}
+		err = clt.DeleteVM(vm.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
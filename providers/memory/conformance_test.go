@@ -0,0 +1,26 @@
+package memory
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/tests"
+)
+
+//TestConformance runs the conformance scenarios, the report is saved in $GPAC_CONFORMANCE_DIR if set
+func TestConformance(t *testing.T) {
+	c := tests.Conformance{
+		Provider: "memory",
+		Factory: func() (api.ClientAPI, error) {
+			return AuthenticatedClient(AuthOptions{TransitionDelay: 10 * time.Millisecond})
+		},
+		SkipRemote: true,
+	}
+	report := c.Run(t)
+	err := report.Save(os.Getenv("GPAC_CONFORMANCE_DIR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"sort"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+	uuid "github.com/satori/go.uuid"
+)
+
+//network in-memory representation of a network
+type network struct {
+	api.Network
+	//allocated private IPs allocated in the network
+	allocated map[string]bool
+}
+
+//ipAt returns the n-th address of the network defined by cidr
+func ipAt(cidr string, n int) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := ipNet.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	offset := big.NewInt(int64(n))
+	if offset.Cmp(size) >= 0 {
+		return "", api.NewError(api.ErrQuotaExceeded, nil, "Network %s is full", cidr)
+	}
+	ip := new(big.Int).SetBytes(ipNet.IP)
+	ip.Add(ip, offset)
+	b := ip.Bytes()
+	addr := make(net.IP, len(ipNet.IP))
+	copy(addr[len(addr)-len(b):], b)
+	return addr.String(), nil
+}
+
+//allocateFrom allocates the first address of cidr from offset first which is not in allocated
+func allocateFrom(cidr string, first int, allocated map[string]bool) (string, error) {
+	for n := first; ; n++ {
+		ip, err := ipAt(cidr, n)
+		if err != nil {
+			return "", err
+		}
+		if !allocated[ip] {
+			allocated[ip] = true
+			return ip, nil
+		}
+	}
+}
+
+//allocateIP allocates a private IP in the network
+func (n *network) allocateIP() (string, error) {
+	//The first address is reserved to the network, the second one to the provider
+	return allocateFrom(n.CIDR, 2, n.allocated)
+}
+
+//releaseIP releases the private IP ip of the network
+func (n *network) releaseIP(ip string) {
+	delete(n.allocated, ip)
+}
+
+//allocatePublicIP allocates a public IP
+func (client *Client) allocatePublicIP() (string, error) {
+	return allocateFrom(client.Opts.PublicCIDR, 1, client.publicIPs)
+}
+
+//releaseIPs releases the private IPs and the public IP of the VM
+func (client *Client) releaseIPs(v *vm) {
+	for netID, ip := range v.ips {
+		if n, ok := client.networks[netID]; ok {
+			n.releaseIP(ip)
+		}
+	}
+	delete(client.publicIPs, v.AccessIPv4)
+}
+
+//CreateNetwork creates a network named name
+func (client *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
+	_, ipNet, err := net.ParseCIDR(req.CIDR)
+	if err != nil {
+		return nil, driverError(err, "Error creating network %s", req.Name)
+	}
+	ipVersion := req.IPVersion
+	if ipVersion == 0 {
+		ipVersion = IPVersion.IPv4
+	}
+	if !ipVersion.Is(ipNet.IP.String()) {
+		return nil, api.NewError(api.ErrInvalidRequest, nil, "Error creating network %s: %s is not an %s network", req.Name, req.CIDR, ipVersion)
+	}
+	n := network{
+		Network: api.Network{
+			ID:        uuid.NewV4().String(),
+			Name:      req.Name,
+			CIDR:      ipNet.String(),
+			IPVersion: ipVersion,
+		},
+		allocated: map[string]bool{},
+	}
+	client.mu.Lock()
+	client.networks[n.ID] = &n
+	client.mu.Unlock()
+
+	req.GWRequest.PublicIP = true
+	req.GWRequest.IsGateway = true
+	req.GWRequest.NetworkIDs = append(req.GWRequest.NetworkIDs, n.ID)
+	vm, err := client.CreateVM(req.GWRequest)
+	if err != nil {
+		client.mu.Lock()
+		delete(client.networks, n.ID)
+		client.mu.Unlock()
+		return nil, driverError(err, "Error creating network %s", req.Name)
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	n.GatewayID = vm.ID
+	res := n.Network
+	return &res, nil
+}
+
+//GetNetwork returns the network identified by id
+func (client *Client) GetNetwork(id string) (*api.Network, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	n, ok := client.networks[id]
+	if !ok {
+		return nil, providers.ResourceNotFoundError("Network", id)
+	}
+	res := n.Network
+	return &res, nil
+}
+
+//ListNetworks lists available networks
+func (client *Client) ListNetworks() ([]api.Network, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	var nets []api.Network
+	for _, n := range client.networks {
+		nets = append(nets, n.Network)
+	}
+	sort.Slice(nets, func(i, j int) bool { return nets[i].Name < nets[j].Name })
+	return nets, nil
+}
+
+//DeleteNetwork deletes the network identified by id
+//The network must not be used by any VM other than its gateway
+func (client *Client) DeleteNetwork(id string) error {
+	client.mu.Lock()
+	n, ok := client.networks[id]
+	if !ok {
+		client.mu.Unlock()
+		return providers.ResourceNotFoundError("Network", id)
+	}
+	for _, v := range client.vms {
+		if v.ID != n.GatewayID && v.isConnected(id) {
+			client.mu.Unlock()
+			return api.NewError(api.ErrConflict, nil, "Error deleting network: VM %s is still connected to network %s", v.Name, n.Name)
+		}
+	}
+	gwID := n.GatewayID
+	client.mu.Unlock()
+
+	if gwID != "" {
+		err := client.DeleteVM(gwID)
+		if err != nil {
+			if !errors.Is(err, api.ErrNotFound) {
+				return driverError(err, "Error deleting network")
+			}
+		}
+	}
+	client.mu.Lock()
+	delete(client.networks, id)
+	client.mu.Unlock()
+	return nil
+}
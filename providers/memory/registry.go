@@ -0,0 +1,27 @@
+package memory
+
+import (
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+func init() {
+	providers.Register(providers.Provider{
+		Name:        "memory",
+		Description: "in-memory simulation, resources are lost when the process exits",
+		Fields: []providers.ConfigField{
+			{Name: "TransitionDelay", Description: "time in nanoseconds taken by a resource to reach a stable state (optional)", Sample: 200000000},
+			{Name: "Images", Description: "OS images exposed by the driver (optional)"},
+			{Name: "Templates", Description: "VM templates exposed by the driver (optional)"},
+			{Name: "PublicCIDR", Description: "network in which public IPs are allocated (optional)", Sample: "203.0.113.0/24"},
+		},
+		Factory: func(cfg map[string]interface{}) (api.ClientAPI, error) {
+			opts := AuthOptions{}
+			err := providers.DecodeConfig(cfg, &opts)
+			if err != nil {
+				return nil, err
+			}
+			return AuthenticatedClient(opts)
+		},
+	})
+}
@@ -0,0 +1,466 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeState"
+	uuid "github.com/satori/go.uuid"
+)
+
+//volume in-memory representation of a volume
+type volume struct {
+	api.Volume
+	attachment *api.VolumeAttachment
+	pending    *transition
+}
+
+//refresh applies the pending state change if it is completed
+func (v *volume) refresh() {
+	if v.pending.done() {
+		v.State = VolumeState.Enum(v.pending.target)
+		v.pending = nil
+	}
+}
+
+//object in-memory representation of an object
+type object struct {
+	api.Object
+	content []byte
+}
+
+//expired tells if the object reached its deletion date
+func (o *object) expired() bool {
+	return !o.DeleteAt.IsZero() && !time.Now().Before(o.DeleteAt)
+}
+
+//CreateVolume creates a block volume
+//- name is the name of the volume
+//- size is the size of the volume in GB
+//- volumeType is the type of volume to create, if volumeType is empty the driver use a default type
+func (client *Client) CreateVolume(request api.VolumeRequest) (*api.Volume, error) {
+	if request.Size <= 0 {
+		return nil, api.NewError(api.ErrInvalidRequest, nil, "Error creating volume : invalid size %d", request.Size)
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	v := volume{
+		Volume: api.Volume{
+			ID:    uuid.NewV4().String(),
+			Name:  request.Name,
+			Size:  request.Size,
+			Speed: request.Speed,
+			State: VolumeState.CREATING,
+		},
+		pending: client.schedule(int(VolumeState.AVAILABLE)),
+	}
+	client.volumes[v.ID] = &v
+	res := v.Volume
+	return &res, nil
+}
+
+//GetVolume returns the volume identified by id
+func (client *Client) GetVolume(id string) (*api.Volume, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	v, ok := client.volumes[id]
+	if !ok {
+		return nil, providers.ResourceNotFoundError("Volume", id)
+	}
+	v.refresh()
+	res := v.Volume
+	return &res, nil
+}
+
+//ListVolumes list available volumes
+func (client *Client) ListVolumes() ([]api.Volume, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	var vs []api.Volume
+	for _, v := range client.volumes {
+		v.refresh()
+		vs = append(vs, v.Volume)
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i].Name < vs[j].Name })
+	return vs, nil
+}
+
+//DeleteVolume deletes the volume identified by id
+func (client *Client) DeleteVolume(id string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	v, ok := client.volumes[id]
+	if !ok {
+		return providers.ResourceNotFoundError("Volume", id)
+	}
+	if v.attachment != nil {
+		return api.NewError(api.ErrConflict, nil, "Error deleting volume: volume %s is attached to VM %s", id, v.attachment.ServerID)
+	}
+	delete(client.volumes, id)
+	return nil
+}
+
+//UpdateVolume renames, resizes or changes the speed of the volume identified by id
+func (client *Client) UpdateVolume(id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	v, ok := client.volumes[id]
+	if !ok {
+		return nil, providers.ResourceNotFoundError("Volume", id)
+	}
+	if request.Size != nil && *request.Size < v.Size {
+		return nil, api.NewError(api.ErrInvalidRequest, nil, "Error updating volume: volume %s cannot shrink from %d to %d GB", id, v.Size, *request.Size)
+	}
+	v.refresh()
+	if request.Name != nil {
+		v.Name = *request.Name
+	}
+	if request.Size != nil {
+		v.Size = *request.Size
+	}
+	if request.Speed != nil {
+		v.Speed = *request.Speed
+	}
+	res := v.Volume
+	return &res, nil
+}
+
+//CreateVolumeAttachment attaches a volume to a VM
+//- name the name of the volume attachment
+//- volume the volume to attach
+//- vm the VM on which the volume is attached
+func (client *Client) CreateVolumeAttachment(request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if _, ok := client.vms[request.ServerID]; !ok {
+		return nil, providers.ResourceNotFoundError("VM", request.ServerID)
+	}
+	v, ok := client.volumes[request.VolumeID]
+	if !ok {
+		return nil, providers.ResourceNotFoundError("Volume", request.VolumeID)
+	}
+	v.refresh()
+	if v.attachment != nil || v.State == VolumeState.ERROR {
+		return nil, api.NewError(api.ErrConflict, nil, "Error creating volume attachement between server %s and volume %s: volume is %s", request.ServerID, request.VolumeID, v.State)
+	}
+	//Devices are named like virtio disks, the first one being the root disk
+	used := map[string]bool{}
+	for _, other := range client.volumes {
+		if other.attachment != nil && other.attachment.ServerID == request.ServerID {
+			used[other.attachment.Device] = true
+		}
+	}
+	device := ""
+	for c := 'b'; c <= 'z'; c++ {
+		d := fmt.Sprintf("/dev/vd%c", c)
+		if !used[d] {
+			device = d
+			break
+		}
+	}
+	if device == "" {
+		return nil, api.NewError(api.ErrQuotaExceeded, nil, "Error creating volume attachement between server %s and volume %s: no device available", request.ServerID, request.VolumeID)
+	}
+	v.attachment = &api.VolumeAttachment{
+		ID:       v.ID,
+		Name:     request.Name,
+		VolumeID: v.ID,
+		ServerID: request.ServerID,
+		Device:   device,
+	}
+	v.State = VolumeState.USED
+	v.pending = nil
+	res := *v.attachment
+	return &res, nil
+}
+
+//GetVolumeAttachment returns the volume attachment identified by id
+func (client *Client) GetVolumeAttachment(serverID, id string) (*api.VolumeAttachment, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	v, ok := client.volumes[id]
+	if !ok || v.attachment == nil || v.attachment.ServerID != serverID {
+		return nil, providers.ResourceNotFoundError("VolumeAttachment", id)
+	}
+	res := *v.attachment
+	return &res, nil
+}
+
+//ListVolumeAttachments lists available volume attachment
+func (client *Client) ListVolumeAttachments(serverID string) ([]api.VolumeAttachment, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	var vas []api.VolumeAttachment
+	for _, v := range client.volumes {
+		if v.attachment != nil && v.attachment.ServerID == serverID {
+			vas = append(vas, *v.attachment)
+		}
+	}
+	sort.Slice(vas, func(i, j int) bool { return vas[i].Device < vas[j].Device })
+	return vas, nil
+}
+
+//DeleteVolumeAttachment deletes the volume attachment identifed by id
+func (client *Client) DeleteVolumeAttachment(serverID, id string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	v, ok := client.volumes[id]
+	if !ok || v.attachment == nil || v.attachment.ServerID != serverID {
+		return providers.ResourceNotFoundError("VolumeAttachment", id)
+	}
+	v.attachment = nil
+	v.State = VolumeState.DETACHING
+	v.pending = client.schedule(int(VolumeState.AVAILABLE))
+	return nil
+}
+
+//CreateContainer creates an object container
+//Creating an existing container is a no-op, like with Swift
+func (client *Client) CreateContainer(name string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if _, ok := client.containers[name]; !ok {
+		client.containers[name] = make(map[string]*object)
+	}
+	return nil
+}
+
+//purge removes expired objects of a container
+func purge(objs map[string]*object) {
+	for name, o := range objs {
+		if o.expired() {
+			delete(objs, name)
+		}
+	}
+}
+
+//getContainer returns the objects of the container named name, expired objects are removed
+func (client *Client) getContainer(name string) (map[string]*object, error) {
+	objs, ok := client.containers[name]
+	if !ok {
+		return nil, providers.ResourceNotFoundError("Container", name)
+	}
+	purge(objs)
+	return objs, nil
+}
+
+//DeleteContainer deletes an object container
+func (client *Client) DeleteContainer(name string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	objs, err := client.getContainer(name)
+	if err != nil {
+		return err
+	}
+	if len(objs) > 0 {
+		return api.NewError(api.ErrConflict, nil, "Error deleting container %s: container is not empty", name)
+	}
+	delete(client.containers, name)
+	return nil
+}
+
+//ListContainers list object containers
+func (client *Client) ListContainers() ([]string, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	var names []string
+	for name := range client.containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+//copyMetadata returns a copy of meta
+func copyMetadata(meta map[string]string) map[string]string {
+	res := make(map[string]string)
+	for k, v := range meta {
+		res[k] = v
+	}
+	return res
+}
+
+//PutObject put an object into an object container
+func (client *Client) PutObject(container string, obj api.Object) error {
+	var content []byte
+	if obj.Content != nil {
+		var err error
+		content, err = ioutil.ReadAll(obj.Content)
+		if err != nil {
+			return driverError(err, "Error creating object %s in container %s", obj.Name, container)
+		}
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	objs, err := client.getContainer(container)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	o := object{
+		Object: api.Object{
+			Name:          obj.Name,
+			DeleteAt:      obj.DeleteAt,
+			Metadata:      copyMetadata(obj.Metadata),
+			Date:          now,
+			LastModified:  now,
+			ContentType:   obj.ContentType,
+			ContentLength: int64(len(content)),
+		},
+		content: content,
+	}
+	objs[obj.Name] = &o
+	return nil
+}
+
+//UpdateObjectMetadata update an object into an object container
+func (client *Client) UpdateObjectMetadata(container string, obj api.Object) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	o, err := client.getObject(container, obj.Name)
+	if err != nil {
+		return err
+	}
+	o.Metadata = copyMetadata(obj.Metadata)
+	o.DeleteAt = obj.DeleteAt
+	o.LastModified = time.Now()
+	return nil
+}
+
+//getObject returns the object named name of the container
+func (client *Client) getObject(container string, name string) (*object, error) {
+	objs, err := client.getContainer(container)
+	if err != nil {
+		return nil, err
+	}
+	o, ok := objs[name]
+	if !ok {
+		return nil, providers.ResourceNotFoundError("Object", name)
+	}
+	return o, nil
+}
+
+//readRange extracts the bytes of content in the inclusive range r
+func readRange(content []byte, r api.Range) ([]byte, error) {
+	size := len(content)
+	from, to := 0, size-1
+	if r.From != nil {
+		from = *r.From
+		if r.To != nil {
+			to = *r.To
+		}
+	} else if r.To != nil {
+		//Suffix range: the last To bytes
+		from = size - *r.To
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to >= size {
+		to = size - 1
+	}
+	if from > to || from >= size {
+		return nil, api.NewError(api.ErrInvalidRequest, nil, "Range %s not satisfiable", r.String())
+	}
+	return content[from : to+1], nil
+}
+
+//GetObject get  object content from an object container
+func (client *Client) GetObject(container string, name string, ranges []api.Range) (*api.Object, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	o, err := client.getObject(container, name)
+	if err != nil {
+		return nil, err
+	}
+	content := o.content
+	if len(ranges) > 0 {
+		var buff bytes.Buffer
+		for _, r := range ranges {
+			b, err := readRange(o.content, r)
+			if err != nil {
+				return nil, driverError(err, "Error getting object %s from %s", name, container)
+			}
+			buff.Write(b)
+		}
+		content = buff.Bytes()
+	}
+	res := o.Object
+	res.Metadata = copyMetadata(o.Metadata)
+	res.Content = bytes.NewReader(append([]byte(nil), content...))
+	res.ContentLength = int64(len(content))
+	return &res, nil
+}
+
+//GetObjectMetadata get  object metadata from an object container
+func (client *Client) GetObjectMetadata(container string, name string) (*api.Object, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	o, err := client.getObject(container, name)
+	if err != nil {
+		return nil, err
+	}
+	res := o.Object
+	res.Metadata = copyMetadata(o.Metadata)
+	return &res, nil
+}
+
+//ListObjects list objects of a container
+//Path filters objects of a pseudo directory, Prefix filters objects by name prefix
+func (client *Client) ListObjects(container string, filter api.ObjectFilter) ([]string, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	objs, err := client.getContainer(container)
+	if err != nil {
+		return nil, err
+	}
+	dir := ""
+	if filter.Path != "" {
+		dir = strings.TrimSuffix(filter.Path, "/") + "/"
+	}
+	var names []string
+	for name := range objs {
+		if !strings.HasPrefix(name, dir+filter.Prefix) {
+			continue
+		}
+		if dir != "" && strings.Contains(strings.TrimPrefix(name, dir), "/") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+//CopyObject copies an object
+func (client *Client) CopyObject(containerSrc, objectSrc, objectDst string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	o, err := client.getObject(containerSrc, objectSrc)
+	if err != nil {
+		return driverError(err, "Error copying object %s into %s from container %s", objectSrc, objectDst, containerSrc)
+	}
+	cp := *o
+	cp.Name = objectDst
+	cp.Metadata = copyMetadata(o.Metadata)
+	cp.LastModified = time.Now()
+	client.containers[containerSrc][objectDst] = &cp
+	return nil
+}
+
+//DeleteObject deleta an object from a container
+func (client *Client) DeleteObject(container, object string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if _, err := client.getObject(container, object); err != nil {
+		return err
+	}
+	delete(client.containers[container], object)
+	return nil
+}
@@ -0,0 +1,288 @@
+package providers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//Types of metadata stores
+const (
+	//MetadataObject metadata are stored in the object storage of the provider, one container per bucket
+	MetadataObject = "object"
+	//MetadataBolt metadata are stored in a local embedded database file
+	MetadataBolt = "bolt"
+	//MetadataEtcd metadata are stored in an etcd cluster
+	MetadataEtcd = "etcd"
+)
+
+/*MetadataStore stores the bookkeeping data of the drivers (gateways of the networks, definitions of the VMs ...)
+Keys are grouped in buckets. Each value has a version changing on every write, the version of a missing key is
+the empty string. CompareAndSwap and CompareAndDelete fail with an api.ErrConflict error when the current
+version of the key is not the expected one, concurrent writers use them to detect that they lost a race
+*/
+type MetadataStore interface {
+	//Get returns the value of key and its version, it fails with an api.ErrNotFound error if key does not exist
+	Get(bucket string, key string) ([]byte, string, error)
+	//Put writes the value of key whatever its current version and returns the new version
+	Put(bucket string, key string, value []byte) (string, error)
+	//CompareAndSwap writes the value of key if its current version is version and returns the new version
+	//An empty version creates the key only if it does not exist
+	CompareAndSwap(bucket string, key string, value []byte, version string) (string, error)
+	//Delete deletes key, it fails with an api.ErrNotFound error if key does not exist
+	Delete(bucket string, key string) error
+	//CompareAndDelete deletes key if its current version is version
+	CompareAndDelete(bucket string, key string, version string) error
+	//List lists the keys of bucket starting with prefix sorted in lexical order
+	List(bucket string, prefix string) ([]string, error)
+	//Close releases the resources of the store
+	Close() error
+}
+
+//ClientMetadataStore is implemented by the metadata stores sending their requests with the client of the provider
+//The drivers use it to bind the requests of the store to the context of an operation
+type ClientMetadataStore interface {
+	MetadataStore
+	//WithClient returns a copy of the store sending its requests with clt
+	WithClient(clt api.ClientAPI) MetadataStore
+}
+
+//MetadataClient is implemented by the drivers keeping bookkeeping data, the drivers store them in the object
+//storage of the provider unless another store is set
+type MetadataClient interface {
+	//SetMetadataStore sets the store of the bookkeeping data, it must be called before the client is used
+	SetMetadataStore(store MetadataStore)
+	//MetadataStore returns the store of the bookkeeping data
+	MetadataStore() MetadataStore
+	//MetadataBuckets returns the buckets of the store written by the driver
+	MetadataBuckets() []RecordBucket
+}
+
+//MetadataOptions selects and configures the metadata store of a tenant
+type MetadataOptions struct {
+	//Type MetadataObject (default), MetadataBolt or MetadataEtcd
+	Type string `json:"type,omitempty"`
+	//Path path of the database file of the bolt store
+	Path string `json:"path,omitempty"`
+	//Endpoints endpoints of the etcd cluster
+	Endpoints []string `json:"endpoints,omitempty"`
+	//Prefix prefix of the etcd keys, DefaultEtcdPrefix is used if empty
+	Prefix string `json:"prefix,omitempty"`
+	//CertFile, KeyFile and TrustedCAFile TLS files used to authenticate with the etcd cluster (optional)
+	CertFile      string `json:"cert_file,omitempty"`
+	KeyFile       string `json:"key_file,omitempty"`
+	TrustedCAFile string `json:"trusted_ca_file,omitempty"`
+	//Plaintext writes the private keys of the VMs in clear in the store, by default they are encrypted (see
+	//SealedMetadataStore)
+	//The master keys are kept by the broker that created them: the brokers sharing the store must share its
+	//configuration directory, including the vault, to read the VM records
+	Plaintext bool `json:"plaintext,omitempty"`
+}
+
+//Validate checks that the type of the store is known and that the etcd store has endpoints
+func (opts *MetadataOptions) Validate() error {
+	switch strings.ToLower(opts.Type) {
+	case "", MetadataObject, MetadataBolt:
+		return nil
+	case MetadataEtcd:
+		if len(opts.Endpoints) == 0 {
+			return api.NewError(api.ErrInvalidRequest, nil, "Invalid metadata store: no etcd endpoint given")
+		}
+		return nil
+	}
+	return api.NewError(api.ErrInvalidRequest, nil, "Invalid metadata store: unknown type %s", opts.Type)
+}
+
+//NewMetadataStore creates the metadata store described by opts, clt is the client of the provider used by the
+//object store
+func NewMetadataStore(opts MetadataOptions, clt api.ClientAPI) (MetadataStore, error) {
+	err := opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(opts.Type) {
+	case MetadataBolt:
+		if opts.Path == "" {
+			return nil, api.NewError(api.ErrInvalidRequest, nil, "Invalid metadata store: path of the database file not given")
+		}
+		return NewBoltMetadataStore(opts.Path), nil
+	case MetadataEtcd:
+		return NewEtcdMetadataStore(opts)
+	}
+	return NewObjectMetadataStore(clt), nil
+}
+
+//metadataConflict returns the error of a compare and swap failing on key
+func metadataConflict(bucket string, key string, version string) error {
+	return api.NewError(api.ErrConflict, nil, "Metadata %s/%s is not at version %q", bucket, key, version)
+}
+
+//metadataNotFound returns the error of a missing key
+func metadataNotFound(bucket string, key string) error {
+	return ResourceNotFoundError("Metadata", bucket+"/"+key)
+}
+
+//ObjectMetadataStore stores the metadata in the object storage of the provider
+//Each bucket is a container created on first write and each key an object, the version of a value is a digest
+//of its content. The object storage has no conditional write: compare and swap operations are serialized in the
+//process but a writer of another process may still win a race between the check of the version and the write
+type ObjectMetadataStore struct {
+	clt api.ClientAPI
+
+	//mu and created are shared by the copies of the store
+	mu      *sync.Mutex
+	created map[string]bool
+}
+
+//NewObjectMetadataStore creates a store keeping the metadata in the object storage of clt
+func NewObjectMetadataStore(clt api.ClientAPI) *ObjectMetadataStore {
+	return &ObjectMetadataStore{
+		clt:     clt,
+		mu:      &sync.Mutex{},
+		created: map[string]bool{},
+	}
+}
+
+//WithClient returns a copy of the store sending its requests with clt
+func (s *ObjectMetadataStore) WithClient(clt api.ClientAPI) MetadataStore {
+	return &ObjectMetadataStore{
+		clt:     clt,
+		mu:      s.mu,
+		created: s.created,
+	}
+}
+
+//objectVersion returns the version of an object content
+func objectVersion(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:8])
+}
+
+//Get returns the value of key and its version
+func (s *ObjectMetadataStore) Get(bucket string, key string) ([]byte, string, error) {
+	o, err := s.clt.GetObject(bucket, key, nil)
+	if err != nil {
+		if errors.Is(err, api.ErrNotFound) {
+			return nil, "", metadataNotFound(bucket, key)
+		}
+		return nil, "", api.NewError(api.KindOf(err), err, "Error reading metadata %s/%s", bucket, key)
+	}
+	var buffer bytes.Buffer
+	_, err = buffer.ReadFrom(o.Content)
+	if err != nil {
+		return nil, "", api.NewError(api.KindOf(err), err, "Error reading metadata %s/%s", bucket, key)
+	}
+	return buffer.Bytes(), objectVersion(buffer.Bytes()), nil
+}
+
+//createBucket creates the container of bucket the first time it is written
+func (s *ObjectMetadataStore) createBucket(bucket string) {
+	if s.created[bucket] {
+		return
+	}
+	//the container may already exist, a real failure is reported by the write
+	s.clt.CreateContainer(bucket)
+	s.created[bucket] = true
+}
+
+//put writes the value of key, s.mu is held
+func (s *ObjectMetadataStore) put(bucket string, key string, value []byte) (string, error) {
+	s.createBucket(bucket)
+	err := s.clt.PutObject(bucket, api.Object{
+		Name:    key,
+		Content: bytes.NewReader(value),
+	})
+	if err != nil {
+		return "", api.NewError(api.KindOf(err), err, "Error writing metadata %s/%s", bucket, key)
+	}
+	return objectVersion(value), nil
+}
+
+//Put writes the value of key whatever its current version
+func (s *ObjectMetadataStore) Put(bucket string, key string, value []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(bucket, key, value)
+}
+
+//current returns the current version of key, the empty string if key does not exist
+func (s *ObjectMetadataStore) current(bucket string, key string) (string, error) {
+	_, v, err := s.Get(bucket, key)
+	if errors.Is(err, api.ErrNotFound) {
+		return "", nil
+	}
+	return v, err
+}
+
+//CompareAndSwap writes the value of key if its current version is version
+func (s *ObjectMetadataStore) CompareAndSwap(bucket string, key string, value []byte, version string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.current(bucket, key)
+	if err != nil {
+		return "", err
+	}
+	if v != version {
+		return "", metadataConflict(bucket, key, version)
+	}
+	return s.put(bucket, key, value)
+}
+
+//Delete deletes key
+func (s *ObjectMetadataStore) Delete(bucket string, key string) error {
+	err := s.clt.DeleteObject(bucket, key)
+	if errors.Is(err, api.ErrNotFound) {
+		return metadataNotFound(bucket, key)
+	}
+	if err != nil {
+		return api.NewError(api.KindOf(err), err, "Error deleting metadata %s/%s", bucket, key)
+	}
+	return nil
+}
+
+//CompareAndDelete deletes key if its current version is version
+func (s *ObjectMetadataStore) CompareAndDelete(bucket string, key string, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.current(bucket, key)
+	if err != nil {
+		return err
+	}
+	if v != version {
+		return metadataConflict(bucket, key, version)
+	}
+	if v == "" {
+		return nil
+	}
+	return s.Delete(bucket, key)
+}
+
+//List lists the keys of bucket starting with prefix
+func (s *ObjectMetadataStore) List(bucket string, prefix string) ([]string, error) {
+	keys, err := s.clt.ListObjects(bucket, api.ObjectFilter{Prefix: prefix})
+	if errors.Is(err, api.ErrNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, api.NewError(api.KindOf(err), err, "Error listing metadata %s", bucket)
+	}
+	res := []string{}
+	for _, k := range keys {
+		if strings.HasPrefix(k, prefix) {
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+//Close does nothing, the client of the provider is owned by the caller
+func (s *ObjectMetadataStore) Close() error {
+	return nil
+}
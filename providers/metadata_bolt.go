@@ -0,0 +1,214 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//DefaultBoltTimeout time waited for the lock of a bolt database file held by another process
+const DefaultBoltTimeout = 10 * time.Second
+
+//BoltMetadataStore stores the metadata in a local bolt database file
+//The file is opened for each operation so that several processes (the broker CLI and broker serve) can share it,
+//the operations of the processes are serialized by the lock of the file
+//Values are prefixed with their version, the sequence of their bucket when they were written
+type BoltMetadataStore struct {
+	Path string
+	//Timeout time waited for the lock of the file, DefaultBoltTimeout is used if 0
+	Timeout time.Duration
+}
+
+//NewBoltMetadataStore creates a store keeping the metadata in the database file path
+func NewBoltMetadataStore(path string) *BoltMetadataStore {
+	return &BoltMetadataStore{Path: path}
+}
+
+//update runs f in a read-write transaction
+func (s *BoltMetadataStore) update(f func(tx *bolt.Tx) error) error {
+	return s.run(false, f)
+}
+
+//view runs f in a read-only transaction, f is not called if the file does not exist
+func (s *BoltMetadataStore) view(f func(tx *bolt.Tx) error) error {
+	if _, err := os.Stat(s.Path); os.IsNotExist(err) {
+		return nil
+	}
+	return s.run(true, f)
+}
+
+//run opens the database file and runs f in a transaction
+func (s *BoltMetadataStore) run(readOnly bool, f func(tx *bolt.Tx) error) error {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = DefaultBoltTimeout
+	}
+	if !readOnly {
+		err := os.MkdirAll(filepath.Dir(s.Path), 0700)
+		if err != nil {
+			return err
+		}
+	}
+	db, err := bolt.Open(s.Path, 0600, &bolt.Options{Timeout: timeout, ReadOnly: readOnly})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if readOnly {
+		return db.View(f)
+	}
+	return db.Update(f)
+}
+
+//boltVersion returns the version of a stored value, the empty string if v is nil
+func boltVersion(v []byte) string {
+	if len(v) < 8 {
+		return ""
+	}
+	return strconv.FormatUint(binary.BigEndian.Uint64(v[:8]), 10)
+}
+
+//boltPut writes value under key with a new version
+func boltPut(b *bolt.Bucket, key string, value []byte) (string, error) {
+	seq, err := b.NextSequence()
+	if err != nil {
+		return "", err
+	}
+	v := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(v, seq)
+	copy(v[8:], value)
+	err = b.Put([]byte(key), v)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(seq, 10), nil
+}
+
+//Get returns the value of key and its version
+func (s *BoltMetadataStore) Get(bucket string, key string) ([]byte, string, error) {
+	var value []byte
+	version := ""
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		version = boltVersion(v)
+		value = append([]byte{}, v[8:]...)
+		return nil
+	})
+	if err != nil {
+		return nil, "", api.NewError(api.KindOf(err), err, "Error reading metadata %s/%s", bucket, key)
+	}
+	if version == "" {
+		return nil, "", metadataNotFound(bucket, key)
+	}
+	return value, version, nil
+}
+
+//swap writes the value of key, if check is true the current version of key must be version
+func (s *BoltMetadataStore) swap(bucket string, key string, value []byte, check bool, version string) (string, error) {
+	res := ""
+	conflict := false
+	err := s.update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		if check && boltVersion(b.Get([]byte(key))) != version {
+			conflict = true
+			return nil
+		}
+		res, err = boltPut(b, key, value)
+		return err
+	})
+	if err != nil {
+		return "", api.NewError(api.KindOf(err), err, "Error writing metadata %s/%s", bucket, key)
+	}
+	if conflict {
+		return "", metadataConflict(bucket, key, version)
+	}
+	return res, nil
+}
+
+//Put writes the value of key whatever its current version
+func (s *BoltMetadataStore) Put(bucket string, key string, value []byte) (string, error) {
+	return s.swap(bucket, key, value, false, "")
+}
+
+//CompareAndSwap writes the value of key if its current version is version
+func (s *BoltMetadataStore) CompareAndSwap(bucket string, key string, value []byte, version string) (string, error) {
+	return s.swap(bucket, key, value, true, version)
+}
+
+//remove deletes key, if check is true the current version of key must be version
+func (s *BoltMetadataStore) remove(bucket string, key string, check bool, version string) error {
+	var res error
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		current := ""
+		if b != nil {
+			current = boltVersion(b.Get([]byte(key)))
+		}
+		switch {
+		case check && current != version:
+			res = metadataConflict(bucket, key, version)
+		case current == "" && !check:
+			res = metadataNotFound(bucket, key)
+		case current != "":
+			return b.Delete([]byte(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return api.NewError(api.KindOf(err), err, "Error deleting metadata %s/%s", bucket, key)
+	}
+	return res
+}
+
+//Delete deletes key
+func (s *BoltMetadataStore) Delete(bucket string, key string) error {
+	return s.remove(bucket, key, false, "")
+}
+
+//CompareAndDelete deletes key if its current version is version
+func (s *BoltMetadataStore) CompareAndDelete(bucket string, key string, version string) error {
+	return s.remove(bucket, key, true, version)
+}
+
+//List lists the keys of bucket starting with prefix
+func (s *BoltMetadataStore) List(bucket string, prefix string) ([]string, error) {
+	keys := []string{}
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		p := []byte(prefix)
+		for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = c.Next() {
+			keys = append(keys, string(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, api.NewError(api.KindOf(err), err, "Error listing metadata %s", bucket)
+	}
+	return keys, nil
+}
+
+//Close does nothing, the file is closed after each operation
+func (s *BoltMetadataStore) Close() error {
+	return nil
+}
@@ -0,0 +1,195 @@
+package providers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//DefaultEtcdPrefix prefix of the keys of the etcd store when MetadataOptions.Prefix is not set
+const DefaultEtcdPrefix = "/gpac/metadata"
+
+//DefaultEtcdTimeout timeout of the connection to the etcd cluster and of each operation
+const DefaultEtcdTimeout = 10 * time.Second
+
+//EtcdMetadataStore stores the metadata in an etcd cluster, key k of bucket b is stored under <prefix>/b/k
+//The version of a value is the revision of the cluster when it was last modified
+type EtcdMetadataStore struct {
+	Prefix string
+	//Timeout timeout of each operation, DefaultEtcdTimeout is used if 0
+	Timeout time.Duration
+	clt     *clientv3.Client
+}
+
+//NewEtcdMetadataStore creates a store keeping the metadata in the etcd cluster defined by opts
+func NewEtcdMetadataStore(opts MetadataOptions) (*EtcdMetadataStore, error) {
+	if len(opts.Endpoints) == 0 {
+		return nil, fmt.Errorf("Error connecting to etcd: no endpoint given")
+	}
+	var tlsCfg *tls.Config
+	if opts.CertFile != "" || opts.TrustedCAFile != "" {
+		info := transport.TLSInfo{
+			CertFile:      opts.CertFile,
+			KeyFile:       opts.KeyFile,
+			TrustedCAFile: opts.TrustedCAFile,
+		}
+		cfg, err := info.ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("Error connecting to etcd: %s", err.Error())
+		}
+		tlsCfg = cfg
+	}
+	clt, err := clientv3.New(clientv3.Config{
+		Endpoints:   opts.Endpoints,
+		DialTimeout: DefaultEtcdTimeout,
+		TLS:         tlsCfg,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to etcd: %s", err.Error())
+	}
+	prefix := opts.Prefix
+	if prefix == "" {
+		prefix = DefaultEtcdPrefix
+	}
+	return &EtcdMetadataStore{
+		Prefix: strings.TrimSuffix(prefix, "/"),
+		clt:    clt,
+	}, nil
+}
+
+//key returns the etcd key of key in bucket
+func (s *EtcdMetadataStore) key(bucket string, key string) string {
+	return s.Prefix + "/" + bucket + "/" + key
+}
+
+//context returns the context of an operation
+func (s *EtcdMetadataStore) context() (context.Context, context.CancelFunc) {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = DefaultEtcdTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+//etcdVersion returns the version of a revision
+func etcdVersion(rev int64) string {
+	return strconv.FormatInt(rev, 10)
+}
+
+//compare returns the comparison of the revision of key with version, an empty version matches a missing key
+func (s *EtcdMetadataStore) compare(k string, version string) (clientv3.Cmp, bool) {
+	if version == "" {
+		return clientv3.Compare(clientv3.CreateRevision(k), "=", 0), true
+	}
+	rev, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return clientv3.Cmp{}, false
+	}
+	return clientv3.Compare(clientv3.ModRevision(k), "=", rev), true
+}
+
+//Get returns the value of key and its version
+func (s *EtcdMetadataStore) Get(bucket string, key string) ([]byte, string, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	resp, err := s.clt.Get(ctx, s.key(bucket, key))
+	if err != nil {
+		return nil, "", api.NewError(api.KindOf(err), err, "Error reading metadata %s/%s", bucket, key)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, "", metadataNotFound(bucket, key)
+	}
+	return resp.Kvs[0].Value, etcdVersion(resp.Kvs[0].ModRevision), nil
+}
+
+//Put writes the value of key whatever its current version
+func (s *EtcdMetadataStore) Put(bucket string, key string, value []byte) (string, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	resp, err := s.clt.Put(ctx, s.key(bucket, key), string(value))
+	if err != nil {
+		return "", api.NewError(api.KindOf(err), err, "Error writing metadata %s/%s", bucket, key)
+	}
+	return etcdVersion(resp.Header.Revision), nil
+}
+
+//CompareAndSwap writes the value of key if its current version is version
+func (s *EtcdMetadataStore) CompareAndSwap(bucket string, key string, value []byte, version string) (string, error) {
+	k := s.key(bucket, key)
+	cmp, ok := s.compare(k, version)
+	if !ok {
+		return "", metadataConflict(bucket, key, version)
+	}
+	ctx, cancel := s.context()
+	defer cancel()
+	resp, err := s.clt.Txn(ctx).If(cmp).Then(clientv3.OpPut(k, string(value))).Commit()
+	if err != nil {
+		return "", api.NewError(api.KindOf(err), err, "Error writing metadata %s/%s", bucket, key)
+	}
+	if !resp.Succeeded {
+		return "", metadataConflict(bucket, key, version)
+	}
+	return etcdVersion(resp.Header.Revision), nil
+}
+
+//Delete deletes key
+func (s *EtcdMetadataStore) Delete(bucket string, key string) error {
+	ctx, cancel := s.context()
+	defer cancel()
+	resp, err := s.clt.Delete(ctx, s.key(bucket, key))
+	if err != nil {
+		return api.NewError(api.KindOf(err), err, "Error deleting metadata %s/%s", bucket, key)
+	}
+	if resp.Deleted == 0 {
+		return metadataNotFound(bucket, key)
+	}
+	return nil
+}
+
+//CompareAndDelete deletes key if its current version is version
+func (s *EtcdMetadataStore) CompareAndDelete(bucket string, key string, version string) error {
+	k := s.key(bucket, key)
+	cmp, ok := s.compare(k, version)
+	if !ok {
+		return metadataConflict(bucket, key, version)
+	}
+	ctx, cancel := s.context()
+	defer cancel()
+	resp, err := s.clt.Txn(ctx).If(cmp).Then(clientv3.OpDelete(k)).Commit()
+	if err != nil {
+		return api.NewError(api.KindOf(err), err, "Error deleting metadata %s/%s", bucket, key)
+	}
+	if !resp.Succeeded {
+		return metadataConflict(bucket, key, version)
+	}
+	return nil
+}
+
+//List lists the keys of bucket starting with prefix
+func (s *EtcdMetadataStore) List(bucket string, prefix string) ([]string, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	base := s.key(bucket, "")
+	resp, err := s.clt.Get(ctx, base+prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, api.NewError(api.KindOf(err), err, "Error listing metadata %s", bucket)
+	}
+	keys := []string{}
+	for _, kv := range resp.Kvs {
+		keys = append(keys, strings.TrimPrefix(string(kv.Key), base))
+	}
+	return keys, nil
+}
+
+//Close closes the connection to the etcd cluster
+func (s *EtcdMetadataStore) Close() error {
+	return s.clt.Close()
+}
@@ -0,0 +1,260 @@
+package providers_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/memory"
+)
+
+//EtcdEndpointsEnv comma separated endpoints of the etcd cluster used by TestEtcdMetadataStore, the test is
+//skipped if it is not set
+const EtcdEndpointsEnv = "GPAC_TEST_ETCD_ENDPOINTS"
+
+//testMetadataStore checks that store implements the contract of providers.MetadataStore
+func testMetadataStore(t *testing.T, store providers.MetadataStore) {
+	defer store.Close()
+	const bucket = "vms"
+
+	//missing keys
+	_, _, err := store.Get(bucket, "vm1")
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	err = store.Delete(bucket, "vm1")
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	keys, err := store.List(bucket, "")
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("expected an empty bucket, got %v (%v)", keys, err)
+	}
+
+	//an empty version creates the key only if it does not exist
+	v1, err := store.CompareAndSwap(bucket, "vm1", []byte("v1"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.CompareAndSwap(bucket, "vm1", []byte("other"), "")
+	if !errors.Is(err, api.ErrConflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	checkValue(t, store, bucket, "vm1", "v1", v1)
+
+	//Put ignores the version
+	v2, err := store.Put(bucket, "vm1", []byte("v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2 == v1 {
+		t.Fatalf("version %s not changed by Put", v1)
+	}
+	checkValue(t, store, bucket, "vm1", "v2", v2)
+
+	//a stale version is rejected
+	_, err = store.CompareAndSwap(bucket, "vm1", []byte("stale"), v1)
+	if !errors.Is(err, api.ErrConflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	checkValue(t, store, bucket, "vm1", "v2", v2)
+	v3, err := store.CompareAndSwap(bucket, "vm1", []byte("v3"), v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkValue(t, store, bucket, "vm1", "v3", v3)
+
+	err = store.CompareAndDelete(bucket, "vm1", v2)
+	if !errors.Is(err, api.ErrConflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	err = store.CompareAndDelete(bucket, "vm1", v3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = store.Get(bucket, "vm1")
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	//an empty version deletes a missing key
+	err = store.CompareAndDelete(bucket, "vm1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//keys are listed in order, by prefix and by bucket
+	for _, k := range []string{"b", "ab", "a"} {
+		_, err = store.Put(bucket, k, []byte(k))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = store.Put("networks", "a", []byte("network"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkList(t, store, bucket, "", []string{"a", "ab", "b"})
+	checkList(t, store, bucket, "a", []string{"a", "ab"})
+	checkList(t, store, "networks", "", []string{"a"})
+	checkValue(t, store, bucket, "a", "a", "")
+
+	//concurrent writers do not lose updates
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- increment(store, bucket, "counter")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkValue(t, store, bucket, "counter", strconv.Itoa(writers), "")
+
+	for _, k := range []string{"a", "ab", "b", "counter"} {
+		err = store.Delete(bucket, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = store.Delete("networks", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkList(t, store, bucket, "", []string{})
+}
+
+//checkValue checks the value of key and its version if version is not empty
+func checkValue(t *testing.T, store providers.MetadataStore, bucket string, key string, value string, version string) {
+	t.Helper()
+	b, v, err := store.Get(bucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != value || (version != "" && v != version) {
+		t.Fatalf("expected %s/%s to be %q at version %s, got %q at version %s", bucket, key, value, version, b, v)
+	}
+}
+
+//checkList checks the keys of bucket starting with prefix
+func checkList(t *testing.T, store providers.MetadataStore, bucket string, prefix string, expected []string) {
+	t.Helper()
+	keys, err := store.List(bucket, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected the keys %v in %s with prefix %q, got %v", expected, bucket, prefix, keys)
+	}
+}
+
+//increment increments the counter stored in key with a compare and swap loop
+func increment(store providers.MetadataStore, bucket string, key string) error {
+	for {
+		n := 0
+		b, version, err := store.Get(bucket, key)
+		if err == nil {
+			n, err = strconv.Atoi(string(b))
+		}
+		if err != nil && !errors.Is(err, api.ErrNotFound) {
+			return err
+		}
+		_, err = store.CompareAndSwap(bucket, key, []byte(strconv.Itoa(n+1)), version)
+		if !errors.Is(err, api.ErrConflict) {
+			return err
+		}
+	}
+}
+
+func TestBoltMetadataStore(t *testing.T) {
+	testMetadataStore(t, providers.NewBoltMetadataStore(filepath.Join(t.TempDir(), "metadata.db")))
+}
+
+func TestEtcdMetadataStore(t *testing.T) {
+	endpoints := os.Getenv(EtcdEndpointsEnv)
+	if endpoints == "" {
+		t.Skipf("%s not set", EtcdEndpointsEnv)
+	}
+	store, err := providers.NewEtcdMetadataStore(providers.MetadataOptions{
+		Endpoints: strings.Split(endpoints, ","),
+		Prefix:    "gpac-test/" + uuid.NewV4().String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMetadataStore(t, store)
+}
+
+func TestObjectMetadataStore(t *testing.T) {
+	clt, err := memory.AuthenticatedClient(memory.AuthOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMetadataStore(t, providers.NewObjectMetadataStore(clt))
+}
+
+//unavailableStorage fails the requests to the object storage
+type unavailableStorage struct {
+	api.ClientAPI
+}
+
+func (c *unavailableStorage) unavailable() error {
+	return api.NewError(api.ErrUnavailable, nil, "Service unavailable")
+}
+
+func (c *unavailableStorage) GetObject(container string, name string, ranges []api.Range) (*api.Object, error) {
+	return nil, c.unavailable()
+}
+
+func (c *unavailableStorage) PutObject(container string, obj api.Object) error {
+	return c.unavailable()
+}
+
+func (c *unavailableStorage) CreateContainer(name string) error {
+	return c.unavailable()
+}
+
+func (c *unavailableStorage) DeleteObject(container string, object string) error {
+	return c.unavailable()
+}
+
+func (c *unavailableStorage) ListObjects(container string, filter api.ObjectFilter) ([]string, error) {
+	return nil, c.unavailable()
+}
+
+func TestObjectMetadataStoreErrorKind(t *testing.T) {
+	//the errors of the object storage keep their kind, transient errors are retried by the callers
+	store := providers.NewObjectMetadataStore(&unavailableStorage{})
+	_, _, err := store.Get("vms", "vm1")
+	if !errors.Is(err, api.ErrUnavailable) {
+		t.Fatalf("expected an unavailable error, got %v", err)
+	}
+	_, err = store.Put("vms", "vm1", []byte("vm1"))
+	if !errors.Is(err, api.ErrUnavailable) {
+		t.Fatalf("expected an unavailable error, got %v", err)
+	}
+	err = store.Delete("vms", "vm1")
+	if !errors.Is(err, api.ErrUnavailable) {
+		t.Fatalf("expected an unavailable error, got %v", err)
+	}
+	_, err = store.List("vms", "")
+	if !errors.Is(err, api.ErrUnavailable) {
+		t.Fatalf("expected an unavailable error, got %v", err)
+	}
+}
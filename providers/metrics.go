@@ -0,0 +1,140 @@
+package providers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//DefaultBuckets upper bounds in seconds of the buckets of the latency histograms
+var DefaultBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+//DefaultMetrics metrics of the provider calls of the process
+var DefaultMetrics = NewMetrics(DefaultBuckets)
+
+//callKey labels of the metrics of a provider method
+type callKey struct {
+	provider string
+	method   string
+}
+
+//errorKey labels of the error counters
+type errorKey struct {
+	callKey
+	kind string
+}
+
+//histogram latency histogram of a provider method
+type histogram struct {
+	//counts number of calls per bucket, the last bucket is +Inf
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+//Metrics latency histograms and error counters of the provider calls
+type Metrics struct {
+	buckets   []float64
+	mu        sync.Mutex
+	durations map[callKey]*histogram
+	errors    map[errorKey]uint64
+}
+
+//NewMetrics creates metrics whose latency histograms have the buckets buckets, upper bounds in seconds
+func NewMetrics(buckets []float64) *Metrics {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &Metrics{
+		buckets:   b,
+		durations: map[callKey]*histogram{},
+		errors:    map[errorKey]uint64{},
+	}
+}
+
+//Observe records a call of method on provider which lasted d and failed with err if not nil
+func (m *Metrics) Observe(provider string, method string, d time.Duration, err error) {
+	key := callKey{provider: provider, method: method}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets)+1)}
+		m.durations[key] = h
+	}
+	s := d.Seconds()
+	i := sort.SearchFloat64s(m.buckets, s)
+	h.counts[i]++
+	h.sum += s
+	h.count++
+	if err != nil {
+		m.errors[errorKey{callKey: key, kind: api.KindName(err)}]++
+	}
+}
+
+//labelValue escapes a label value of the Prometheus text format
+func labelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+//labels formats the labels of a provider method
+func (k callKey) labels() string {
+	return fmt.Sprintf(`provider="%s",method="%s"`, labelValue(k.provider), labelValue(k.method))
+}
+
+//WritePrometheus writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bw := bufio.NewWriter(w)
+
+	keys := make([]callKey, 0, len(m.durations))
+	for k := range m.durations {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].provider != keys[j].provider {
+			return keys[i].provider < keys[j].provider
+		}
+		return keys[i].method < keys[j].method
+	})
+	fmt.Fprintln(bw, "# HELP gpac_provider_call_duration_seconds Duration of the calls to the providers.")
+	fmt.Fprintln(bw, "# TYPE gpac_provider_call_duration_seconds histogram")
+	for _, k := range keys {
+		h := m.durations[k]
+		var cumulative uint64
+		for i, le := range m.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(bw, "gpac_provider_call_duration_seconds_bucket{%s,le=\"%g\"} %d\n", k.labels(), le, cumulative)
+		}
+		fmt.Fprintf(bw, "gpac_provider_call_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", k.labels(), h.count)
+		fmt.Fprintf(bw, "gpac_provider_call_duration_seconds_sum{%s} %g\n", k.labels(), h.sum)
+		fmt.Fprintf(bw, "gpac_provider_call_duration_seconds_count{%s} %d\n", k.labels(), h.count)
+	}
+
+	ekeys := make([]errorKey, 0, len(m.errors))
+	for k := range m.errors {
+		ekeys = append(ekeys, k)
+	}
+	sort.Slice(ekeys, func(i, j int) bool {
+		a, b := ekeys[i], ekeys[j]
+		if a.provider != b.provider {
+			return a.provider < b.provider
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.kind < b.kind
+	})
+	fmt.Fprintln(bw, "# HELP gpac_provider_call_errors_total Failed calls to the providers by kind of error.")
+	fmt.Fprintln(bw, "# TYPE gpac_provider_call_errors_total counter")
+	for _, k := range ekeys {
+		fmt.Fprintf(bw, "gpac_provider_call_errors_total{%s,kind=\"%s\"} %d\n", k.labels(), labelValue(k.kind), m.errors[k])
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,58 @@
+package providers
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	m := NewMetrics([]float64{1, 0.1})
+	m.Observe("openstack", "ListVMs", 50*time.Millisecond, nil)
+	m.Observe("openstack", "ListVMs", 500*time.Millisecond, nil)
+	m.Observe("openstack", "ListVMs", 5*time.Second, api.NewError(api.ErrUnavailable, nil, "Error listing VMs"))
+	m.Observe("openstack", "GetVM", time.Second, api.NewError(api.ErrNotFound, nil, "VM not found"))
+	m.Observe("aws", "GetVM", 100*time.Millisecond, errors.New("unclassified"))
+	m.Observe(`a"b`, "GetVM", 100*time.Millisecond, nil)
+
+	var buf bytes.Buffer
+	err := m.WritePrometheus(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//buckets are sorted and cumulative, a duration equal to a bound is counted in its bucket
+	expected := `# HELP gpac_provider_call_duration_seconds Duration of the calls to the providers.
+# TYPE gpac_provider_call_duration_seconds histogram
+gpac_provider_call_duration_seconds_bucket{provider="a\"b",method="GetVM",le="0.1"} 1
+gpac_provider_call_duration_seconds_bucket{provider="a\"b",method="GetVM",le="1"} 1
+gpac_provider_call_duration_seconds_bucket{provider="a\"b",method="GetVM",le="+Inf"} 1
+gpac_provider_call_duration_seconds_sum{provider="a\"b",method="GetVM"} 0.1
+gpac_provider_call_duration_seconds_count{provider="a\"b",method="GetVM"} 1
+gpac_provider_call_duration_seconds_bucket{provider="aws",method="GetVM",le="0.1"} 1
+gpac_provider_call_duration_seconds_bucket{provider="aws",method="GetVM",le="1"} 1
+gpac_provider_call_duration_seconds_bucket{provider="aws",method="GetVM",le="+Inf"} 1
+gpac_provider_call_duration_seconds_sum{provider="aws",method="GetVM"} 0.1
+gpac_provider_call_duration_seconds_count{provider="aws",method="GetVM"} 1
+gpac_provider_call_duration_seconds_bucket{provider="openstack",method="GetVM",le="0.1"} 0
+gpac_provider_call_duration_seconds_bucket{provider="openstack",method="GetVM",le="1"} 1
+gpac_provider_call_duration_seconds_bucket{provider="openstack",method="GetVM",le="+Inf"} 1
+gpac_provider_call_duration_seconds_sum{provider="openstack",method="GetVM"} 1
+gpac_provider_call_duration_seconds_count{provider="openstack",method="GetVM"} 1
+gpac_provider_call_duration_seconds_bucket{provider="openstack",method="ListVMs",le="0.1"} 1
+gpac_provider_call_duration_seconds_bucket{provider="openstack",method="ListVMs",le="1"} 2
+gpac_provider_call_duration_seconds_bucket{provider="openstack",method="ListVMs",le="+Inf"} 3
+gpac_provider_call_duration_seconds_sum{provider="openstack",method="ListVMs"} 5.55
+gpac_provider_call_duration_seconds_count{provider="openstack",method="ListVMs"} 3
+# HELP gpac_provider_call_errors_total Failed calls to the providers by kind of error.
+# TYPE gpac_provider_call_errors_total counter
+gpac_provider_call_errors_total{provider="aws",method="GetVM",kind="unknown"} 1
+gpac_provider_call_errors_total{provider="openstack",method="GetVM",kind="not_found"} 1
+gpac_provider_call_errors_total{provider="openstack",method="ListVMs",kind="unavailable"} 1
+`
+	if buf.String() != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,655 @@
+package providers
+
+import (
+	"context"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/system"
+)
+
+//ObservedClient decorates a ClientAPI with metrics and traces
+//The latency and the errors of each call are recorded in the metrics and each call is traced by a span named
+//provider.<Method> with the provider and method attributes
+type ObservedClient struct {
+	clt       api.ClientAPIContext
+	decorated api.ClientAPI
+	provider  string
+	metrics   *Metrics
+	tracer    *Tracer
+}
+
+//NewObservedClient decorates clt, a client of the provider named provider, the calls are recorded in metrics and
+//traced by tracer
+func NewObservedClient(clt api.ClientAPI, provider string, metrics *Metrics, tracer *Tracer) *ObservedClient {
+	return &ObservedClient{
+		clt:       api.WithContext(clt),
+		decorated: clt,
+		provider:  provider,
+		metrics:   metrics,
+		tracer:    tracer,
+	}
+}
+
+//Unwrap returns the decorated client
+func (c *ObservedClient) Unwrap() api.ClientAPI {
+	return c.decorated
+}
+
+//observe calls f, the call of method is recorded in the metrics and traced
+func (c *ObservedClient) observe(ctx context.Context, method string, f func(ctx context.Context) error) error {
+	ctx, span := c.tracer.Start(ctx, "provider."+method)
+	span.SetAttribute("provider", c.provider)
+	span.SetAttribute("method", method)
+	start := time.Now()
+	err := f(ctx)
+	c.metrics.Observe(c.provider, method, time.Since(start), err)
+	span.Finish(err)
+	return err
+}
+
+//ListImages lists available OS images
+func (c *ObservedClient) ListImages() ([]api.Image, error) {
+	return c.ListImagesContext(context.Background())
+}
+
+//ListImagesContext implements api.ClientAPIContext
+func (c *ObservedClient) ListImagesContext(ctx context.Context) ([]api.Image, error) {
+	var res []api.Image
+	err := c.observe(ctx, "ListImages", func(ctx context.Context) (err error) {
+		res, err = c.clt.ListImagesContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetImage returns the Image referenced by id
+func (c *ObservedClient) GetImage(id string) (*api.Image, error) {
+	return c.GetImageContext(context.Background(), id)
+}
+
+//GetImageContext implements api.ClientAPIContext
+func (c *ObservedClient) GetImageContext(ctx context.Context, id string) (*api.Image, error) {
+	var res *api.Image
+	err := c.observe(ctx, "GetImage", func(ctx context.Context) (err error) {
+		res, err = c.clt.GetImageContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetTemplate returns the Template referenced by id
+func (c *ObservedClient) GetTemplate(id string) (*api.VMTemplate, error) {
+	return c.GetTemplateContext(context.Background(), id)
+}
+
+//GetTemplateContext implements api.ClientAPIContext
+func (c *ObservedClient) GetTemplateContext(ctx context.Context, id string) (*api.VMTemplate, error) {
+	var res *api.VMTemplate
+	err := c.observe(ctx, "GetTemplate", func(ctx context.Context) (err error) {
+		res, err = c.clt.GetTemplateContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListTemplates lists available VM templates
+func (c *ObservedClient) ListTemplates() ([]api.VMTemplate, error) {
+	return c.ListTemplatesContext(context.Background())
+}
+
+//ListTemplatesContext implements api.ClientAPIContext
+func (c *ObservedClient) ListTemplatesContext(ctx context.Context) ([]api.VMTemplate, error) {
+	var res []api.VMTemplate
+	err := c.observe(ctx, "ListTemplates", func(ctx context.Context) (err error) {
+		res, err = c.clt.ListTemplatesContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CreateKeyPair creates and import a key pair
+func (c *ObservedClient) CreateKeyPair(name string) (*api.KeyPair, error) {
+	return c.CreateKeyPairContext(context.Background(), name)
+}
+
+//CreateKeyPairContext implements api.ClientAPIContext
+func (c *ObservedClient) CreateKeyPairContext(ctx context.Context, name string) (*api.KeyPair, error) {
+	var res *api.KeyPair
+	err := c.observe(ctx, "CreateKeyPair", func(ctx context.Context) (err error) {
+		res, err = c.clt.CreateKeyPairContext(ctx, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetKeyPair returns the key pair identified by id
+func (c *ObservedClient) GetKeyPair(id string) (*api.KeyPair, error) {
+	return c.GetKeyPairContext(context.Background(), id)
+}
+
+//GetKeyPairContext implements api.ClientAPIContext
+func (c *ObservedClient) GetKeyPairContext(ctx context.Context, id string) (*api.KeyPair, error) {
+	var res *api.KeyPair
+	err := c.observe(ctx, "GetKeyPair", func(ctx context.Context) (err error) {
+		res, err = c.clt.GetKeyPairContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListKeyPairs lists available key pairs
+func (c *ObservedClient) ListKeyPairs() ([]api.KeyPair, error) {
+	return c.ListKeyPairsContext(context.Background())
+}
+
+//ListKeyPairsContext implements api.ClientAPIContext
+func (c *ObservedClient) ListKeyPairsContext(ctx context.Context) ([]api.KeyPair, error) {
+	var res []api.KeyPair
+	err := c.observe(ctx, "ListKeyPairs", func(ctx context.Context) (err error) {
+		res, err = c.clt.ListKeyPairsContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteKeyPair deletes the key pair identified by id
+func (c *ObservedClient) DeleteKeyPair(id string) error {
+	return c.DeleteKeyPairContext(context.Background(), id)
+}
+
+//DeleteKeyPairContext implements api.ClientAPIContext
+func (c *ObservedClient) DeleteKeyPairContext(ctx context.Context, id string) error {
+	return c.observe(ctx, "DeleteKeyPair", func(ctx context.Context) error {
+		return c.clt.DeleteKeyPairContext(ctx, id)
+	})
+}
+
+//CreateNetwork creates a network named name
+func (c *ObservedClient) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
+	return c.CreateNetworkContext(context.Background(), req)
+}
+
+//CreateNetworkContext implements api.ClientAPIContext
+func (c *ObservedClient) CreateNetworkContext(ctx context.Context, req api.NetworkRequest) (*api.Network, error) {
+	var res *api.Network
+	err := c.observe(ctx, "CreateNetwork", func(ctx context.Context) (err error) {
+		res, err = c.clt.CreateNetworkContext(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetNetwork returns the network identified by id
+func (c *ObservedClient) GetNetwork(id string) (*api.Network, error) {
+	return c.GetNetworkContext(context.Background(), id)
+}
+
+//GetNetworkContext implements api.ClientAPIContext
+func (c *ObservedClient) GetNetworkContext(ctx context.Context, id string) (*api.Network, error) {
+	var res *api.Network
+	err := c.observe(ctx, "GetNetwork", func(ctx context.Context) (err error) {
+		res, err = c.clt.GetNetworkContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListNetworks lists available networks
+func (c *ObservedClient) ListNetworks() ([]api.Network, error) {
+	return c.ListNetworksContext(context.Background())
+}
+
+//ListNetworksContext implements api.ClientAPIContext
+func (c *ObservedClient) ListNetworksContext(ctx context.Context) ([]api.Network, error) {
+	var res []api.Network
+	err := c.observe(ctx, "ListNetworks", func(ctx context.Context) (err error) {
+		res, err = c.clt.ListNetworksContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteNetwork deletes the network identified by id
+func (c *ObservedClient) DeleteNetwork(id string) error {
+	return c.DeleteNetworkContext(context.Background(), id)
+}
+
+//DeleteNetworkContext implements api.ClientAPIContext
+func (c *ObservedClient) DeleteNetworkContext(ctx context.Context, id string) error {
+	return c.observe(ctx, "DeleteNetwork", func(ctx context.Context) error {
+		return c.clt.DeleteNetworkContext(ctx, id)
+	})
+}
+
+//CreateVM creates a VM that fulfils the request
+func (c *ObservedClient) CreateVM(request api.VMRequest) (*api.VM, error) {
+	return c.CreateVMContext(context.Background(), request)
+}
+
+//CreateVMContext implements api.ClientAPIContext
+func (c *ObservedClient) CreateVMContext(ctx context.Context, request api.VMRequest) (*api.VM, error) {
+	var res *api.VM
+	err := c.observe(ctx, "CreateVM", func(ctx context.Context) (err error) {
+		res, err = c.clt.CreateVMContext(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVM returns the VM identified by id
+func (c *ObservedClient) GetVM(id string) (*api.VM, error) {
+	return c.GetVMContext(context.Background(), id)
+}
+
+//GetVMContext implements api.ClientAPIContext
+func (c *ObservedClient) GetVMContext(ctx context.Context, id string) (*api.VM, error) {
+	var res *api.VM
+	err := c.observe(ctx, "GetVM", func(ctx context.Context) (err error) {
+		res, err = c.clt.GetVMContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListVMs lists available VMs
+func (c *ObservedClient) ListVMs() ([]api.VM, error) {
+	return c.ListVMsContext(context.Background())
+}
+
+//ListVMsContext implements api.ClientAPIContext
+func (c *ObservedClient) ListVMsContext(ctx context.Context) ([]api.VM, error) {
+	var res []api.VM
+	err := c.observe(ctx, "ListVMs", func(ctx context.Context) (err error) {
+		res, err = c.clt.ListVMsContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteVM deletes the VM identified by id
+func (c *ObservedClient) DeleteVM(id string) error {
+	return c.DeleteVMContext(context.Background(), id)
+}
+
+//DeleteVMContext implements api.ClientAPIContext
+func (c *ObservedClient) DeleteVMContext(ctx context.Context, id string) error {
+	return c.observe(ctx, "DeleteVM", func(ctx context.Context) error {
+		return c.clt.DeleteVMContext(ctx, id)
+	})
+}
+
+//StopVM stops the VM identified by id
+func (c *ObservedClient) StopVM(id string) error {
+	return c.StopVMContext(context.Background(), id)
+}
+
+//StopVMContext implements api.ClientAPIContext
+func (c *ObservedClient) StopVMContext(ctx context.Context, id string) error {
+	return c.observe(ctx, "StopVM", func(ctx context.Context) error {
+		return c.clt.StopVMContext(ctx, id)
+	})
+}
+
+//StartVM starts the VM identified by id
+func (c *ObservedClient) StartVM(id string) error {
+	return c.StartVMContext(context.Background(), id)
+}
+
+//StartVMContext implements api.ClientAPIContext
+func (c *ObservedClient) StartVMContext(ctx context.Context, id string) error {
+	return c.observe(ctx, "StartVM", func(ctx context.Context) error {
+		return c.clt.StartVMContext(ctx, id)
+	})
+}
+
+//GetSSHConfig creates SSHConfig from VM
+func (c *ObservedClient) GetSSHConfig(id string) (*system.SSHConfig, error) {
+	return c.GetSSHConfigContext(context.Background(), id)
+}
+
+//GetSSHConfigContext implements api.ClientAPIContext
+func (c *ObservedClient) GetSSHConfigContext(ctx context.Context, id string) (*system.SSHConfig, error) {
+	var res *system.SSHConfig
+	err := c.observe(ctx, "GetSSHConfig", func(ctx context.Context) (err error) {
+		res, err = c.clt.GetSSHConfigContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CreateVolume creates a block volume
+func (c *ObservedClient) CreateVolume(request api.VolumeRequest) (*api.Volume, error) {
+	return c.CreateVolumeContext(context.Background(), request)
+}
+
+//CreateVolumeContext implements api.ClientAPIContext
+func (c *ObservedClient) CreateVolumeContext(ctx context.Context, request api.VolumeRequest) (*api.Volume, error) {
+	var res *api.Volume
+	err := c.observe(ctx, "CreateVolume", func(ctx context.Context) (err error) {
+		res, err = c.clt.CreateVolumeContext(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVolume returns the volume identified by id
+func (c *ObservedClient) GetVolume(id string) (*api.Volume, error) {
+	return c.GetVolumeContext(context.Background(), id)
+}
+
+//GetVolumeContext implements api.ClientAPIContext
+func (c *ObservedClient) GetVolumeContext(ctx context.Context, id string) (*api.Volume, error) {
+	var res *api.Volume
+	err := c.observe(ctx, "GetVolume", func(ctx context.Context) (err error) {
+		res, err = c.clt.GetVolumeContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListVolumes list available volumes
+func (c *ObservedClient) ListVolumes() ([]api.Volume, error) {
+	return c.ListVolumesContext(context.Background())
+}
+
+//ListVolumesContext implements api.ClientAPIContext
+func (c *ObservedClient) ListVolumesContext(ctx context.Context) ([]api.Volume, error) {
+	var res []api.Volume
+	err := c.observe(ctx, "ListVolumes", func(ctx context.Context) (err error) {
+		res, err = c.clt.ListVolumesContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteVolume deletes the volume identified by id
+func (c *ObservedClient) DeleteVolume(id string) error {
+	return c.DeleteVolumeContext(context.Background(), id)
+}
+
+//DeleteVolumeContext implements api.ClientAPIContext
+func (c *ObservedClient) DeleteVolumeContext(ctx context.Context, id string) error {
+	return c.observe(ctx, "DeleteVolume", func(ctx context.Context) error {
+		return c.clt.DeleteVolumeContext(ctx, id)
+	})
+}
+
+//UpdateVolume renames, resizes or changes the speed of the volume identified by id
+func (c *ObservedClient) UpdateVolume(id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	return c.UpdateVolumeContext(context.Background(), id, request)
+}
+
+//UpdateVolumeContext implements api.ClientAPIContext
+func (c *ObservedClient) UpdateVolumeContext(ctx context.Context, id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	var res *api.Volume
+	err := c.observe(ctx, "UpdateVolume", func(ctx context.Context) (err error) {
+		res, err = c.clt.UpdateVolumeContext(ctx, id, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CreateVolumeAttachment attaches a volume to a VM
+func (c *ObservedClient) CreateVolumeAttachment(request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	return c.CreateVolumeAttachmentContext(context.Background(), request)
+}
+
+//CreateVolumeAttachmentContext implements api.ClientAPIContext
+func (c *ObservedClient) CreateVolumeAttachmentContext(ctx context.Context, request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	var res *api.VolumeAttachment
+	err := c.observe(ctx, "CreateVolumeAttachment", func(ctx context.Context) (err error) {
+		res, err = c.clt.CreateVolumeAttachmentContext(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVolumeAttachment returns the volume attachment identified by id
+func (c *ObservedClient) GetVolumeAttachment(serverID string, id string) (*api.VolumeAttachment, error) {
+	return c.GetVolumeAttachmentContext(context.Background(), serverID, id)
+}
+
+//GetVolumeAttachmentContext implements api.ClientAPIContext
+func (c *ObservedClient) GetVolumeAttachmentContext(ctx context.Context, serverID string, id string) (*api.VolumeAttachment, error) {
+	var res *api.VolumeAttachment
+	err := c.observe(ctx, "GetVolumeAttachment", func(ctx context.Context) (err error) {
+		res, err = c.clt.GetVolumeAttachmentContext(ctx, serverID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListVolumeAttachments lists available volume attachment
+func (c *ObservedClient) ListVolumeAttachments(serverID string) ([]api.VolumeAttachment, error) {
+	return c.ListVolumeAttachmentsContext(context.Background(), serverID)
+}
+
+//ListVolumeAttachmentsContext implements api.ClientAPIContext
+func (c *ObservedClient) ListVolumeAttachmentsContext(ctx context.Context, serverID string) ([]api.VolumeAttachment, error) {
+	var res []api.VolumeAttachment
+	err := c.observe(ctx, "ListVolumeAttachments", func(ctx context.Context) (err error) {
+		res, err = c.clt.ListVolumeAttachmentsContext(ctx, serverID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteVolumeAttachment deletes the volume attachment identifed by id
+func (c *ObservedClient) DeleteVolumeAttachment(serverID string, id string) error {
+	return c.DeleteVolumeAttachmentContext(context.Background(), serverID, id)
+}
+
+//DeleteVolumeAttachmentContext implements api.ClientAPIContext
+func (c *ObservedClient) DeleteVolumeAttachmentContext(ctx context.Context, serverID string, id string) error {
+	return c.observe(ctx, "DeleteVolumeAttachment", func(ctx context.Context) error {
+		return c.clt.DeleteVolumeAttachmentContext(ctx, serverID, id)
+	})
+}
+
+//CreateContainer creates an object container
+func (c *ObservedClient) CreateContainer(name string) error {
+	return c.CreateContainerContext(context.Background(), name)
+}
+
+//CreateContainerContext implements api.ClientAPIContext
+func (c *ObservedClient) CreateContainerContext(ctx context.Context, name string) error {
+	return c.observe(ctx, "CreateContainer", func(ctx context.Context) error {
+		return c.clt.CreateContainerContext(ctx, name)
+	})
+}
+
+//DeleteContainer deletes an object container
+func (c *ObservedClient) DeleteContainer(name string) error {
+	return c.DeleteContainerContext(context.Background(), name)
+}
+
+//DeleteContainerContext implements api.ClientAPIContext
+func (c *ObservedClient) DeleteContainerContext(ctx context.Context, name string) error {
+	return c.observe(ctx, "DeleteContainer", func(ctx context.Context) error {
+		return c.clt.DeleteContainerContext(ctx, name)
+	})
+}
+
+//ListContainers list object containers
+func (c *ObservedClient) ListContainers() ([]string, error) {
+	return c.ListContainersContext(context.Background())
+}
+
+//ListContainersContext implements api.ClientAPIContext
+func (c *ObservedClient) ListContainersContext(ctx context.Context) ([]string, error) {
+	var res []string
+	err := c.observe(ctx, "ListContainers", func(ctx context.Context) (err error) {
+		res, err = c.clt.ListContainersContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//PutObject put an object into an object container
+func (c *ObservedClient) PutObject(container string, obj api.Object) error {
+	return c.PutObjectContext(context.Background(), container, obj)
+}
+
+//PutObjectContext implements api.ClientAPIContext
+func (c *ObservedClient) PutObjectContext(ctx context.Context, container string, obj api.Object) error {
+	return c.observe(ctx, "PutObject", func(ctx context.Context) error {
+		return c.clt.PutObjectContext(ctx, container, obj)
+	})
+}
+
+//UpdateObjectMetadata update an object into  object container
+func (c *ObservedClient) UpdateObjectMetadata(container string, obj api.Object) error {
+	return c.UpdateObjectMetadataContext(context.Background(), container, obj)
+}
+
+//UpdateObjectMetadataContext implements api.ClientAPIContext
+func (c *ObservedClient) UpdateObjectMetadataContext(ctx context.Context, container string, obj api.Object) error {
+	return c.observe(ctx, "UpdateObjectMetadata", func(ctx context.Context) error {
+		return c.clt.UpdateObjectMetadataContext(ctx, container, obj)
+	})
+}
+
+//GetObject get  object content from an object container
+func (c *ObservedClient) GetObject(container string, name string, ranges []api.Range) (*api.Object, error) {
+	return c.GetObjectContext(context.Background(), container, name, ranges)
+}
+
+//GetObjectContext implements api.ClientAPIContext
+func (c *ObservedClient) GetObjectContext(ctx context.Context, container string, name string, ranges []api.Range) (*api.Object, error) {
+	var res *api.Object
+	err := c.observe(ctx, "GetObject", func(ctx context.Context) (err error) {
+		res, err = c.clt.GetObjectContext(ctx, container, name, ranges)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetObjectMetadata get  object metadata from an object container
+func (c *ObservedClient) GetObjectMetadata(container string, name string) (*api.Object, error) {
+	return c.GetObjectMetadataContext(context.Background(), container, name)
+}
+
+//GetObjectMetadataContext implements api.ClientAPIContext
+func (c *ObservedClient) GetObjectMetadataContext(ctx context.Context, container string, name string) (*api.Object, error) {
+	var res *api.Object
+	err := c.observe(ctx, "GetObjectMetadata", func(ctx context.Context) (err error) {
+		res, err = c.clt.GetObjectMetadataContext(ctx, container, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListObjects list objects of a container
+func (c *ObservedClient) ListObjects(container string, filter api.ObjectFilter) ([]string, error) {
+	return c.ListObjectsContext(context.Background(), container, filter)
+}
+
+//ListObjectsContext implements api.ClientAPIContext
+func (c *ObservedClient) ListObjectsContext(ctx context.Context, container string, filter api.ObjectFilter) ([]string, error) {
+	var res []string
+	err := c.observe(ctx, "ListObjects", func(ctx context.Context) (err error) {
+		res, err = c.clt.ListObjectsContext(ctx, container, filter)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CopyObject copies an object
+func (c *ObservedClient) CopyObject(containerSrc string, objectSrc string, objectDst string) error {
+	return c.CopyObjectContext(context.Background(), containerSrc, objectSrc, objectDst)
+}
+
+//CopyObjectContext implements api.ClientAPIContext
+func (c *ObservedClient) CopyObjectContext(ctx context.Context, containerSrc string, objectSrc string, objectDst string) error {
+	return c.observe(ctx, "CopyObject", func(ctx context.Context) error {
+		return c.clt.CopyObjectContext(ctx, containerSrc, objectSrc, objectDst)
+	})
+}
+
+//DeleteObject deleta an object from a container
+func (c *ObservedClient) DeleteObject(container string, object string) error {
+	return c.DeleteObjectContext(context.Background(), container, object)
+}
+
+//DeleteObjectContext implements api.ClientAPIContext
+func (c *ObservedClient) DeleteObjectContext(ctx context.Context, container string, object string) error {
+	return c.observe(ctx, "DeleteObject", func(ctx context.Context) error {
+		return c.clt.DeleteObjectContext(ctx, container, object)
+	})
+}
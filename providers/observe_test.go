@@ -0,0 +1,69 @@
+package providers_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers"
+)
+
+//spanRecorder keeps the exported spans
+type spanRecorder struct {
+	spans []*providers.Span
+}
+
+func (r *spanRecorder) Export(spans []*providers.Span) error {
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *spanRecorder) Flush() error {
+	return nil
+}
+
+func TestObservedClient(t *testing.T) {
+	metrics := providers.NewMetrics(providers.DefaultBuckets)
+	recorder := &spanRecorder{}
+	tracer := &providers.Tracer{}
+	tracer.SetExporter(recorder)
+	clt := providers.NewObservedClient(newCountingClient(t), "memory", metrics, tracer)
+
+	ctx, parent := tracer.Start(context.Background(), "broker.ListVMs")
+	_, err := clt.ListVMsContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent.Finish(nil)
+	_, err = clt.GetVM("unknown")
+	if err == nil {
+		t.Fatal("unknown VM found")
+	}
+
+	if len(recorder.spans) != 3 {
+		t.Fatalf("expected 3 spans, got %+v", recorder.spans)
+	}
+	list, get := recorder.spans[0], recorder.spans[2]
+	if list.Name != "provider.ListVMs" || list.ParentID != parent.SpanID || list.Attributes["provider"] != "memory" || list.Attributes["method"] != "ListVMs" {
+		t.Fatalf("unexpected span %+v", list)
+	}
+	if get.Name != "provider.GetVM" || get.ParentID != "" || get.Attributes["error.kind"] != "not_found" {
+		t.Fatalf("unexpected span %+v", get)
+	}
+
+	var buf bytes.Buffer
+	err = metrics.WritePrometheus(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`gpac_provider_call_duration_seconds_count{provider="memory",method="ListVMs"} 1`,
+		`gpac_provider_call_duration_seconds_count{provider="memory",method="GetVM"} 1`,
+		`gpac_provider_call_errors_total{provider="memory",method="GetVM",kind="not_found"} 1`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("expected %s in\n%s", line, buf.String())
+		}
+	}
+}
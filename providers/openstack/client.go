@@ -2,11 +2,17 @@ package openstack
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"text/template"
+	"time"
 
 	"github.com/rackspace/gophercloud/openstack/networking/v2/networks"
 
 	"github.com/GeertJohan/go.rice"
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
 	"github.com/SebastienDorgan/gpac/providers/api/VolumeSpeed"
 
 	gc "github.com/rackspace/gophercloud"
@@ -91,21 +97,48 @@ type CfgOptions struct {
 
 	//VolumeSpeeds map volume types with volume speeds
 	VolumeSpeeds map[string]VolumeSpeed.Enum
+
+	//TemplatePrices prices of VM templates indexed by flavor name (optional)
+	TemplatePrices map[string]api.Price
 }
 
-//errorString creates an error string from openstack api error
-func errorString(err error) string {
-	switch e := err.(type) {
+//retryAfterRe matches the retryAfter field of the overLimit responses of openstack
+var retryAfterRe = regexp.MustCompile(`"retryAfter"\s*:\s*"?([0-9]+)`)
+
+//providerError creates an api.Error from an error of the openstack api, the message is formatted with args
+//The kind of the error is deduced from the HTTP status of the response, the status and the body are kept
+func providerError(err error, format string, args ...interface{}) error {
+	e := api.NewError(nil, err, format, args...)
+	switch cause := err.(type) {
 	default:
-		return e.Error()
+		e.Kind = api.KindOf(err)
 	case *gc.UnexpectedResponseCodeError:
-		return fmt.Sprintf("code : %d reason ; %s", e.Actual, string(e.Body[:]))
+		e.Code = strconv.Itoa(cause.Actual)
+		e.Body = string(cause.Body[:])
+		e.Kind = api.KindOfStatus(cause.Actual)
+		//nova replies 403 and neutron 409 when a quota is exceeded
+		if (cause.Actual == 403 || cause.Actual == 409) && strings.Contains(strings.ToLower(e.Body), "quota") {
+			e.Kind = api.ErrQuotaExceeded
+		}
+		//rate limited requests give the number of seconds to wait in the body
+		if m := retryAfterRe.FindStringSubmatch(e.Body); m != nil {
+			s, _ := strconv.Atoi(m[1])
+			e.RetryAfter = time.Duration(s) * time.Second
+		}
 	}
+	return e
 }
 
 //NetworkGWContainer container where Gateway configuratiion are stored
 const NetworkGWContainer string = "__network_gws__"
 
+//VMContainer container where VM definitions are stored
+const VMContainer string = "__vms__"
+
+//CreatedContainer container where the creations of the networks, floating IPs and key pairs are recorded, the
+//version of gophercloud used cannot tag them
+const CreatedContainer string = "__created__"
+
 //AuthenticatedClient returns an authenticated client
 func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
 	gcOpts := gc.AuthOptions{
@@ -125,7 +158,7 @@ func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
 	//Openstack client
 	pClient, err := openstack.AuthenticatedClient(gcOpts)
 	if err != nil {
-		return nil, fmt.Errorf("%s", errorString(err))
+		return nil, providerError(err, "Error authenticating")
 	}
 
 	//Compute API
@@ -134,7 +167,7 @@ func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("%s", errorString(err))
+		return nil, providerError(err, "Error creating compute client")
 	}
 
 	//Network API
@@ -142,11 +175,11 @@ func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
 		Region: opts.Region,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("%s", errorString(err))
+		return nil, providerError(err, "Error creating network client")
 	}
 	nID, err := networks.IDFromName(network, cfg.ProviderNetwork)
 	if err != nil {
-		return nil, fmt.Errorf("%s", errorString(err))
+		return nil, providerError(err, "Error getting provider network")
 	}
 	//Storage API
 	blocstorage, err := openstack.NewBlockStorageV1(pClient, gc.EndpointOpts{
@@ -157,7 +190,7 @@ func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
 		Region: opts.Region,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("%s", errorString(err))
+		return nil, providerError(err, "Error creating object storage client")
 	}
 	box, err := rice.FindBox("scripts")
 	if err != nil {
@@ -188,14 +221,36 @@ func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	clt.CreateContainer("__network_gws__")
-	clt.CreateContainer("__vms__")
+	clt.metadata = providers.NewObjectMetadataStore(&clt)
 	return &clt, nil
 }
 
+//SetMetadataStore sets the store of the gateways of the networks and of the VM definitions, they are kept in the
+//object storage by default
+func (client *Client) SetMetadataStore(store providers.MetadataStore) {
+	client.metadata = store
+}
+
+//MetadataStore returns the store of the gateways of the networks and of the VM definitions
+func (client *Client) MetadataStore() providers.MetadataStore {
+	return client.metadata
+}
+
+//MetadataBuckets returns the buckets of the gateways of the networks, of the VM definitions and of the creations
+func (client *Client) MetadataBuckets() []providers.RecordBucket {
+	return []providers.RecordBucket{
+		{Bucket: NetworkGWContainer, Kind: providers.RecordGateway, New: func() interface{} { return new(string) }},
+		{Bucket: VMContainer, Kind: providers.RecordVM, New: func() interface{} { return &api.VM{} }},
+		{Bucket: CreatedContainer, Kind: providers.RecordCreation, New: func() interface{} { return &creation{} }},
+	}
+}
+
 const defaultRouter string = "d46886b1-cb8e-4e98-9b18-b60bf847dd09"
 const defaultSecurityGroup string = "30ad3142-a5ec-44b5-9560-618bde3de1ef"
 
+//securityGroupDescription prefix of the description of the security group of gpac, followed by its creation time
+const securityGroupDescription string = "Default security group, created "
+
 //Client is the implementation of the openstack driver regarding to the api.ClientAPI
 type Client struct {
 	Opts        *AuthOptions
@@ -210,6 +265,19 @@ type Client struct {
 
 	SecurityGroup     *secgroups.SecurityGroup
 	ProviderNetworkID string
+
+	metadata providers.MetadataStore
+	//background client the copy was made from by withContext, nil if the client is not bound to a context
+	background *Client
+}
+
+//unbound returns the client whose requests are not bound to a context
+//The cleanups of failed operations run on it so that cancelling an operation does not leak the resources it created
+func (client *Client) unbound() *Client {
+	if client.background != nil {
+		return client.background
+	}
+	return client
 }
 
 //getDefaultSecurityGroup returns the default security group
@@ -330,7 +398,7 @@ func (client *Client) initDefaultSecurityGroup() error {
 	}
 	opts := secgroups.CreateOpts{
 		Name:        defaultSecurityGroup,
-		Description: "Default security group",
+		Description: securityGroupDescription + time.Now().UTC().Format(time.RFC3339),
 	}
 
 	group, err := secgroups.Create(client.Compute, opts).Extract()
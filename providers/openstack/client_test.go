@@ -0,0 +1,68 @@
+package openstack
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	gc "github.com/rackspace/gophercloud"
+)
+
+func TestProviderError(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		kind   error
+	}{
+		{400, `{"badRequest": {"message": "Invalid flavor"}}`, api.ErrInvalidRequest},
+		{401, `{"error": {"message": "The request you have made requires authentication."}}`, api.ErrUnauthorized},
+		{403, `{"forbidden": {"message": "Policy doesn't allow os_compute_api:servers:create to be performed."}}`, api.ErrUnauthorized},
+		{404, `{"itemNotFound": {"message": "Instance could not be found"}}`, api.ErrNotFound},
+		{409, `{"conflictingRequest": {"message": "Cannot delete a volume in use"}}`, api.ErrConflict},
+		{413, `{"overLimit": {"message": "Over limit"}}`, api.ErrQuotaExceeded},
+		{429, `{"overLimit": {"message": "This request was rate-limited."}}`, api.ErrUnavailable},
+		{503, `Service Unavailable`, api.ErrUnavailable},
+		{504, `Gateway Timeout`, api.ErrTimeout},
+		//nova replies 403 and neutron 409 when a quota is exceeded
+		{403, `{"forbidden": {"message": "Quota exceeded for instances: Requested 1, but already used 10 of 10 instances"}}`, api.ErrQuotaExceeded},
+		{409, `{"NeutronError": {"type": "OverQuota", "message": "Quota exceeded for resources: ['floatingip']."}}`, api.ErrQuotaExceeded},
+		{302, ``, nil},
+	}
+	for _, c := range cases {
+		err := providerError(&gc.UnexpectedResponseCodeError{Actual: c.status, Body: []byte(c.body)}, "Error creating VM %s", "vm1")
+		var e *api.Error
+		if !errors.As(err, &e) {
+			t.Fatalf("expected an api.Error, got %#v", err)
+		}
+		if e.Kind != c.kind {
+			t.Errorf("%d %s: expected kind %v, got %v", c.status, c.body, c.kind, e.Kind)
+		}
+		if e.Message != "Error creating VM vm1" || e.Body != c.body {
+			t.Errorf("expected the message and the body to be kept, got %#v", e)
+		}
+	}
+}
+
+func TestProviderErrorRetryAfter(t *testing.T) {
+	cases := map[string]time.Duration{
+		`{"overLimit": {"message": "This request was rate-limited.", "retryAfter": "12"}}`: 12 * time.Second,
+		`{"overLimit": {"code": 413, "retryAfter": 3}}`:                                    3 * time.Second,
+		`{"overLimit": {"message": "This request was rate-limited."}}`:                     0,
+	}
+	for body, expected := range cases {
+		err := providerError(&gc.UnexpectedResponseCodeError{Actual: 429, Body: []byte(body)}, "Error listing VMs")
+		if d := api.RetryAfter(err); d != expected {
+			t.Errorf("%s: expected to retry after %s, got %s", body, expected, d)
+		}
+	}
+}
+
+func TestProviderErrorCause(t *testing.T) {
+	//errors that are not responses of openstack are classified by KindOf
+	err := providerError(context.DeadlineExceeded, "Error listing VMs")
+	if !errors.Is(err, api.ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout caused by the deadline, got %v", err)
+	}
+}
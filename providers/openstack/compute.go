@@ -5,7 +5,6 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
-	"encoding/gob"
 	"encoding/pem"
 	"fmt"
 	"strings"
@@ -53,7 +52,7 @@ func (client *Client) ListImages() ([]api.Image, error) {
 	})
 	if len(imgList) == 0 {
 		if err != nil {
-			return nil, fmt.Errorf("Error listing images: %s", errorString(err))
+			return nil, providerError(err, "Error listing images")
 		}
 	}
 	return imgList, nil
@@ -63,7 +62,7 @@ func (client *Client) ListImages() ([]api.Image, error) {
 func (client *Client) GetImage(id string) (*api.Image, error) {
 	img, err := images.Get(client.Compute, id).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting image: %s", errorString(err))
+		return nil, providerError(err, "Error getting image")
 	}
 	return &api.Image{ID: img.ID, Name: img.Name}, nil
 }
@@ -72,7 +71,7 @@ func (client *Client) GetImage(id string) (*api.Image, error) {
 func (client *Client) GetTemplate(id string) (*api.VMTemplate, error) {
 	flv, err := flavors.Get(client.Compute, id).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting template: %s", errorString(err))
+		return nil, providerError(err, "Error getting template")
 	}
 	return &api.VMTemplate{
 		VMSize: api.VMSize{
@@ -80,11 +79,21 @@ func (client *Client) GetTemplate(id string) (*api.VMTemplate, error) {
 			RAMSize:  float32(flv.RAM) / 1000.0,
 			DiskSize: flv.Disk,
 		},
-		ID:   flv.ID,
-		Name: flv.Name,
+		ID:    flv.ID,
+		Name:  flv.Name,
+		Price: client.templatePrice(flv.Name),
 	}, nil
 }
 
+//templatePrice returns the price of the flavor named name, nil if unknown
+func (client *Client) templatePrice(name string) *api.Price {
+	price, ok := client.Cfg.TemplatePrices[name]
+	if !ok {
+		return nil
+	}
+	return &price
+}
+
 //ListTemplates lists available VM templates
 //VM templates are sorted using Dominant Resource Fairness Algorithm
 func (client *Client) ListTemplates() ([]api.VMTemplate, error) {
@@ -109,8 +118,9 @@ func (client *Client) ListTemplates() ([]api.VMTemplate, error) {
 					RAMSize:  float32(flv.RAM) / 1000.0,
 					DiskSize: flv.Disk,
 				},
-				ID:   flv.ID,
-				Name: flv.Name,
+				ID:    flv.ID,
+				Name:  flv.Name,
+				Price: client.templatePrice(flv.Name),
 			})
 
 		}
@@ -146,7 +156,12 @@ func (client *Client) CreateKeyPair(name string) (*api.KeyPair, error) {
 		PublicKey: pubKey,
 	}).Extract()
 	if err != nil {
-		return nil, err
+		return nil, providerError(err, "Error creating key pair")
+	}
+	err = client.markCreated(providers.OrphanKeyPair, kp.Name)
+	if err != nil {
+		keypairs.Delete(client.Compute, kp.Name)
+		return nil, providerError(err, "Error creating key pair")
 	}
 	return &api.KeyPair{
 		ID:         kp.Name,
@@ -160,7 +175,7 @@ func (client *Client) CreateKeyPair(name string) (*api.KeyPair, error) {
 func (client *Client) GetKeyPair(id string) (*api.KeyPair, error) {
 	kp, err := keypairs.Get(client.Compute, id).Extract()
 	if err != nil {
-		return nil, err
+		return nil, providerError(err, "Error getting key pair")
 	}
 	return &api.KeyPair{
 		ID:         kp.Name,
@@ -198,7 +213,7 @@ func (client *Client) ListKeyPairs() ([]api.KeyPair, error) {
 	})
 	if len(kpList) == 0 {
 		if err != nil {
-			return nil, err
+			return nil, providerError(err, "Error listing key pairs")
 		}
 	}
 	return kpList, nil
@@ -208,8 +223,9 @@ func (client *Client) ListKeyPairs() ([]api.KeyPair, error) {
 func (client *Client) DeleteKeyPair(id string) error {
 	err := keypairs.Delete(client.Compute, id).ExtractErr()
 	if err != nil {
-		return fmt.Errorf("Error deleting key pair: %s", errorString(err))
+		return providerError(err, "Error deleting key pair")
 	}
+	client.forgetCreated(id)
 	return nil
 }
 
@@ -232,7 +248,6 @@ func (client *Client) toVMSize(flavor map[string]interface{}) api.VMSize {
 
 //toVMState converts VM status returned by OpenStack driver into VMState enum
 func toVMState(status string) VMState.Enum {
-	fmt.Println("VM status", status)
 	switch status {
 	case "BUILD", "build", "BUILDING", "building":
 		return VMState.STARTING
@@ -372,40 +387,33 @@ func (client *Client) prepareUserData(request api.VMRequest, kp *api.KeyPair, gw
 func (client *Client) readGateway(networkID string) (*servers.Server, error) {
 	gwID, err := client.getGateway(networkID)
 	if err != nil {
-		return nil, fmt.Errorf("Error creating VM: Enable to found Gateway %s", errorString(err))
+		return nil, providerError(err, "Error creating VM: Enable to found Gateway")
 	}
 	gw, err := servers.Get(client.Compute, gwID).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error creating VM: Enable to found Gateway %s", errorString(err))
+		return nil, providerError(err, "Error creating VM: Enable to found Gateway")
 	}
 	return gw, nil
 }
 
 func (client *Client) saveVMDefinition(vm api.VM) error {
-	var buffer bytes.Buffer
-	enc := gob.NewEncoder(&buffer)
-	err := enc.Encode(vm)
+	b, err := providers.EncodeRecord(providers.RecordVM, vm)
 	if err != nil {
 		return err
 	}
-	return client.PutObject("__vms__", api.Object{
-		Name:    vm.ID,
-		Content: bytes.NewReader(buffer.Bytes()),
-	})
+	_, err = client.metadata.Put(VMContainer, vm.ID, b)
+	return err
 }
 func (client *Client) removeVMDefinition(vmID string) error {
-	return client.DeleteObject("__vms__", vmID)
+	return client.metadata.Delete(VMContainer, vmID)
 }
 func (client *Client) readVMDefinition(vmID string) (*api.VM, error) {
-	o, err := client.GetObject("__vms__", vmID, nil)
+	b, _, err := client.metadata.Get(VMContainer, vmID)
 	if err != nil {
 		return nil, err
 	}
-	var buffer bytes.Buffer
-	buffer.ReadFrom(o.Content)
-	enc := gob.NewDecoder(&buffer)
 	var vm api.VM
-	err = enc.Decode(&vm)
+	err = providers.DecodeRecord(providers.RecordVM, b, &vm)
 	if err != nil {
 		return nil, err
 	}
@@ -440,9 +448,9 @@ func (client *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 		name := fmt.Sprintf("%s_%s", request.Name, id)
 		kp, err = client.CreateKeyPair(name)
 		if err != nil {
-			return nil, fmt.Errorf("Error creating VM: %s", errorString(err))
+			return nil, providerError(err, "Error creating VM")
 		}
-		defer client.DeleteKeyPair(kp.ID)
+		defer client.unbound().DeleteKeyPair(kp.ID)
 	}
 
 	if err != nil {
@@ -452,15 +460,17 @@ func (client *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 	if !request.PublicIP {
 		gwServer, err := client.readGateway(mainNetID)
 		if err != nil {
-			return nil, fmt.Errorf("Error creating VM: %s", errorString(err))
+			return nil, providerError(err, "Error creating VM")
 		}
 		gw, err = client.readVMDefinition(gwServer.ID)
 		if err != nil {
-			return nil, fmt.Errorf("Error creating VM: %s", errorString(err))
+			return nil, providerError(err, "Error creating VM")
 		}
 	}
 	userData, err := client.prepareUserData(request, kp, gw)
-	fmt.Println(string(userData))
+	if err != nil {
+		return nil, providerError(err, "Error creating VM")
+	}
 	//Create VM
 	srvOpts := servers.CreateOpts{
 		Name:           request.Name,
@@ -475,7 +485,7 @@ func (client *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 		KeyName:           kp.ID,
 	}).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error creating VM: %s", errorString(err))
+		return nil, providerError(err, "Error creating VM")
 	}
 	//Wait that VM is started
 	service := providers.Service{
@@ -483,7 +493,8 @@ func (client *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 	}
 	vm, err := service.WaitVMState(server.ID, VMState.STARTED, 120*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("Timeout creating VM: %s", errorString(err))
+		client.unbound().DeleteVM(server.ID)
+		return nil, providerError(err, "Timeout creating VM")
 	}
 	//Add gateway ID to VM definition
 	var gwID string
@@ -496,8 +507,8 @@ func (client *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 	if !client.Cfg.UseFloatingIP || !request.PublicIP {
 		err = client.saveVMDefinition(*vm)
 		if err != nil {
-			client.DeleteVM(vm.ID)
-			return nil, fmt.Errorf("Error creating VM: %s", errorString(err))
+			client.unbound().DeleteVM(vm.ID)
+			return nil, providerError(err, "Error creating VM")
 		}
 		return vm, nil
 	}
@@ -507,8 +518,14 @@ func (client *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 		Pool: client.Opts.FloatingIPPool,
 	}).Extract()
 	if err != nil {
-		servers.Delete(client.Compute, vm.ID)
-		return nil, fmt.Errorf("Error creating VM: %s", errorString(err))
+		servers.Delete(client.unbound().Compute, vm.ID)
+		return nil, providerError(err, "Error creating VM")
+	}
+	err = client.markCreated(providers.OrphanAddress, ip.ID)
+	if err != nil {
+		floatingip.Delete(client.unbound().Compute, ip.ID)
+		servers.Delete(client.unbound().Compute, vm.ID)
+		return nil, providerError(err, "Error creating VM")
 	}
 
 	//Associate floating IP to VM
@@ -517,9 +534,9 @@ func (client *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 		ServerID:   vm.ID,
 	}).ExtractErr()
 	if err != nil {
-		floatingip.Delete(client.Compute, ip.ID)
-		servers.Delete(client.Compute, vm.ID)
-		return nil, fmt.Errorf("Error creating VM: %s", errorString(err))
+		floatingip.Delete(client.unbound().Compute, ip.ID)
+		servers.Delete(client.unbound().Compute, vm.ID)
+		return nil, providerError(err, "Error creating VM")
 	}
 
 	if IPVersion.IPv4.Is(ip.IP) {
@@ -529,8 +546,8 @@ func (client *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 	}
 	err = client.saveVMDefinition(*vm)
 	if err != nil {
-		client.DeleteVM(vm.ID)
-		return nil, fmt.Errorf("Error creating VM: %s", errorString(err))
+		client.unbound().DeleteVM(vm.ID)
+		return nil, providerError(err, "Error creating VM")
 	}
 
 	return vm, nil
@@ -541,7 +558,7 @@ func (client *Client) CreateVM(request api.VMRequest) (*api.VM, error) {
 func (client *Client) GetVM(id string) (*api.VM, error) {
 	server, err := servers.Get(client.Compute, id).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting VM: %s", errorString(err))
+		return nil, providerError(err, "Error getting VM")
 	}
 	return client.toVM(server), nil
 }
@@ -562,7 +579,7 @@ func (client *Client) ListVMs() ([]api.VM, error) {
 		return true, nil
 	})
 	if len(vms) == 0 && err != nil {
-		return nil, fmt.Errorf("Error listing vms : %s", errorString(err))
+		return nil, providerError(err, "Error listing vms")
 	}
 	return vms, nil
 }
@@ -587,9 +604,9 @@ func (client *Client) getFloatingIP(vmID string) (*floatingip.FloatingIP, error)
 	})
 	if len(fips) == 0 {
 		if err != nil {
-			return nil, fmt.Errorf("No floating IP found for VM %s: %s", vmID, errorString(err))
+			return nil, providerError(err, "No floating IP found for VM %s", vmID)
 		}
-		return nil, fmt.Errorf("No floating IP found for VM %s", vmID)
+		return nil, api.NewError(api.ErrNotFound, nil, "No floating IP found for VM %s", vmID)
 
 	}
 	if len(fips) > 1 {
@@ -610,18 +627,19 @@ func (client *Client) DeleteVM(id string) error {
 					FloatingIP: fip.IP,
 				}).ExtractErr()
 				if err != nil {
-					return fmt.Errorf("Error deleting VM %s : %s", id, errorString(err))
+					return providerError(err, "Error deleting VM %s", id)
 				}
 				err = floatingip.Delete(client.Compute, fip.ID).ExtractErr()
 				if err != nil {
-					return fmt.Errorf("Error deleting VM %s : %s", id, errorString(err))
+					return providerError(err, "Error deleting VM %s", id)
 				}
+				client.forgetCreated(fip.ID)
 			}
 		}
 	}
 	err := servers.Delete(client.Compute, id).ExtractErr()
 	if err != nil {
-		return fmt.Errorf("Error deleting VM %s : %s", id, errorString(err))
+		return providerError(err, "Error deleting VM %s", id)
 	}
 	client.removeVMDefinition(id)
 	return nil
@@ -631,7 +649,7 @@ func (client *Client) DeleteVM(id string) error {
 func (client *Client) StopVM(id string) error {
 	err := startstop.Stop(client.Compute, id).ExtractErr()
 	if err != nil {
-		return fmt.Errorf("Error stoping VM : %s", errorString(err))
+		return providerError(err, "Error stoping VM")
 	}
 	return nil
 }
@@ -640,7 +658,7 @@ func (client *Client) StopVM(id string) error {
 func (client *Client) StartVM(id string) error {
 	err := startstop.Start(client.Compute, id).ExtractErr()
 	if err != nil {
-		return fmt.Errorf("Error stoping VM : %s", errorString(err))
+		return providerError(err, "Error stoping VM")
 	}
 	return nil
 }
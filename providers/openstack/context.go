@@ -0,0 +1,250 @@
+package openstack
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/system"
+
+	gc "github.com/rackspace/gophercloud"
+)
+
+//contextTransport binds the requests it sends to a context
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+//RoundTrip implements http.RoundTripper
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+//withContext returns a copy of the client whose requests, including the ones of its metadata store, are bound to ctx
+//The copy shares the authentication of the client, a token renewed by the copy is renewed for the client too
+func (client *Client) withContext(ctx context.Context) *Client {
+	base := client.pClient.HTTPClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	pc := *client.pClient
+	pc.HTTPClient.Transport = &contextTransport{ctx: ctx, base: base}
+	if client.pClient.ReauthFunc != nil {
+		pc.ReauthFunc = func() error {
+			err := client.pClient.ReauthFunc()
+			pc.TokenID = client.pClient.TokenID
+			return err
+		}
+	}
+	bind := func(sc *gc.ServiceClient) *gc.ServiceClient {
+		if sc == nil {
+			return nil
+		}
+		c := *sc
+		c.ProviderClient = &pc
+		return &c
+	}
+	c := *client
+	c.pClient = &pc
+	c.Compute = bind(client.Compute)
+	c.Network = bind(client.Network)
+	c.Volume = bind(client.Volume)
+	c.Container = bind(client.Container)
+	c.background = client.unbound()
+	if store, ok := client.metadata.(providers.ClientMetadataStore); ok {
+		c.metadata = store.WithClient(&c)
+	}
+	return &c
+}
+
+//ListImagesContext lists available OS images, the requests are cancelled when ctx is done
+func (client *Client) ListImagesContext(ctx context.Context) ([]api.Image, error) {
+	return client.withContext(ctx).ListImages()
+}
+
+//GetImageContext returns the Image referenced by id, the requests are cancelled when ctx is done
+func (client *Client) GetImageContext(ctx context.Context, id string) (*api.Image, error) {
+	return client.withContext(ctx).GetImage(id)
+}
+
+//GetTemplateContext returns the Template referenced by id, the requests are cancelled when ctx is done
+func (client *Client) GetTemplateContext(ctx context.Context, id string) (*api.VMTemplate, error) {
+	return client.withContext(ctx).GetTemplate(id)
+}
+
+//ListTemplatesContext lists available VM templates, the requests are cancelled when ctx is done
+func (client *Client) ListTemplatesContext(ctx context.Context) ([]api.VMTemplate, error) {
+	return client.withContext(ctx).ListTemplates()
+}
+
+//CreateKeyPairContext creates and import a key pair, the requests are cancelled when ctx is done
+func (client *Client) CreateKeyPairContext(ctx context.Context, name string) (*api.KeyPair, error) {
+	return client.withContext(ctx).CreateKeyPair(name)
+}
+
+//GetKeyPairContext returns the key pair identified by id, the requests are cancelled when ctx is done
+func (client *Client) GetKeyPairContext(ctx context.Context, id string) (*api.KeyPair, error) {
+	return client.withContext(ctx).GetKeyPair(id)
+}
+
+//ListKeyPairsContext lists available key pairs, the requests are cancelled when ctx is done
+func (client *Client) ListKeyPairsContext(ctx context.Context) ([]api.KeyPair, error) {
+	return client.withContext(ctx).ListKeyPairs()
+}
+
+//DeleteKeyPairContext deletes the key pair identified by id, the requests are cancelled when ctx is done
+func (client *Client) DeleteKeyPairContext(ctx context.Context, id string) error {
+	return client.withContext(ctx).DeleteKeyPair(id)
+}
+
+//CreateNetworkContext creates a network named name, the requests are cancelled when ctx is done
+func (client *Client) CreateNetworkContext(ctx context.Context, req api.NetworkRequest) (*api.Network, error) {
+	return client.withContext(ctx).CreateNetwork(req)
+}
+
+//GetNetworkContext returns the network identified by id, the requests are cancelled when ctx is done
+func (client *Client) GetNetworkContext(ctx context.Context, id string) (*api.Network, error) {
+	return client.withContext(ctx).GetNetwork(id)
+}
+
+//ListNetworksContext lists available networks, the requests are cancelled when ctx is done
+func (client *Client) ListNetworksContext(ctx context.Context) ([]api.Network, error) {
+	return client.withContext(ctx).ListNetworks()
+}
+
+//DeleteNetworkContext deletes the network identified by id, the requests are cancelled when ctx is done
+func (client *Client) DeleteNetworkContext(ctx context.Context, id string) error {
+	return client.withContext(ctx).DeleteNetwork(id)
+}
+
+//CreateVMContext creates a VM that fulfils the request, the requests are cancelled when ctx is done
+func (client *Client) CreateVMContext(ctx context.Context, request api.VMRequest) (*api.VM, error) {
+	return client.withContext(ctx).CreateVM(request)
+}
+
+//GetVMContext returns the VM identified by id, the requests are cancelled when ctx is done
+func (client *Client) GetVMContext(ctx context.Context, id string) (*api.VM, error) {
+	return client.withContext(ctx).GetVM(id)
+}
+
+//ListVMsContext lists available VMs, the requests are cancelled when ctx is done
+func (client *Client) ListVMsContext(ctx context.Context) ([]api.VM, error) {
+	return client.withContext(ctx).ListVMs()
+}
+
+//DeleteVMContext deletes the VM identified by id, the requests are cancelled when ctx is done
+func (client *Client) DeleteVMContext(ctx context.Context, id string) error {
+	return client.withContext(ctx).DeleteVM(id)
+}
+
+//StopVMContext stops the VM identified by id, the requests are cancelled when ctx is done
+func (client *Client) StopVMContext(ctx context.Context, id string) error {
+	return client.withContext(ctx).StopVM(id)
+}
+
+//StartVMContext starts the VM identified by id, the requests are cancelled when ctx is done
+func (client *Client) StartVMContext(ctx context.Context, id string) error {
+	return client.withContext(ctx).StartVM(id)
+}
+
+//GetSSHConfigContext creates SSHConfig from VM, the requests are cancelled when ctx is done
+func (client *Client) GetSSHConfigContext(ctx context.Context, id string) (*system.SSHConfig, error) {
+	return client.withContext(ctx).GetSSHConfig(id)
+}
+
+//CreateVolumeContext creates a block volume, the requests are cancelled when ctx is done
+func (client *Client) CreateVolumeContext(ctx context.Context, request api.VolumeRequest) (*api.Volume, error) {
+	return client.withContext(ctx).CreateVolume(request)
+}
+
+//GetVolumeContext returns the volume identified by id, the requests are cancelled when ctx is done
+func (client *Client) GetVolumeContext(ctx context.Context, id string) (*api.Volume, error) {
+	return client.withContext(ctx).GetVolume(id)
+}
+
+//ListVolumesContext list available volumes, the requests are cancelled when ctx is done
+func (client *Client) ListVolumesContext(ctx context.Context) ([]api.Volume, error) {
+	return client.withContext(ctx).ListVolumes()
+}
+
+//DeleteVolumeContext deletes the volume identified by id, the requests are cancelled when ctx is done
+func (client *Client) DeleteVolumeContext(ctx context.Context, id string) error {
+	return client.withContext(ctx).DeleteVolume(id)
+}
+
+//UpdateVolumeContext renames, resizes or changes the speed of the volume identified by id, the requests are cancelled when ctx is done
+func (client *Client) UpdateVolumeContext(ctx context.Context, id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	return client.withContext(ctx).UpdateVolume(id, request)
+}
+
+//CreateVolumeAttachmentContext attaches a volume to a VM, the requests are cancelled when ctx is done
+func (client *Client) CreateVolumeAttachmentContext(ctx context.Context, request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	return client.withContext(ctx).CreateVolumeAttachment(request)
+}
+
+//GetVolumeAttachmentContext returns the volume attachment identified by id, the requests are cancelled when ctx is done
+func (client *Client) GetVolumeAttachmentContext(ctx context.Context, serverID string, id string) (*api.VolumeAttachment, error) {
+	return client.withContext(ctx).GetVolumeAttachment(serverID, id)
+}
+
+//ListVolumeAttachmentsContext lists available volume attachment, the requests are cancelled when ctx is done
+func (client *Client) ListVolumeAttachmentsContext(ctx context.Context, serverID string) ([]api.VolumeAttachment, error) {
+	return client.withContext(ctx).ListVolumeAttachments(serverID)
+}
+
+//DeleteVolumeAttachmentContext deletes the volume attachment identifed by id, the requests are cancelled when ctx is done
+func (client *Client) DeleteVolumeAttachmentContext(ctx context.Context, serverID string, id string) error {
+	return client.withContext(ctx).DeleteVolumeAttachment(serverID, id)
+}
+
+//CreateContainerContext creates an object container, the requests are cancelled when ctx is done
+func (client *Client) CreateContainerContext(ctx context.Context, name string) error {
+	return client.withContext(ctx).CreateContainer(name)
+}
+
+//DeleteContainerContext deletes an object container, the requests are cancelled when ctx is done
+func (client *Client) DeleteContainerContext(ctx context.Context, name string) error {
+	return client.withContext(ctx).DeleteContainer(name)
+}
+
+//ListContainersContext list object containers, the requests are cancelled when ctx is done
+func (client *Client) ListContainersContext(ctx context.Context) ([]string, error) {
+	return client.withContext(ctx).ListContainers()
+}
+
+//PutObjectContext put an object into an object container, the requests are cancelled when ctx is done
+func (client *Client) PutObjectContext(ctx context.Context, container string, obj api.Object) error {
+	return client.withContext(ctx).PutObject(container, obj)
+}
+
+//UpdateObjectMetadataContext update an object into  object container, the requests are cancelled when ctx is done
+func (client *Client) UpdateObjectMetadataContext(ctx context.Context, container string, obj api.Object) error {
+	return client.withContext(ctx).UpdateObjectMetadata(container, obj)
+}
+
+//GetObjectContext get  object content from an object container, the requests are cancelled when ctx is done
+func (client *Client) GetObjectContext(ctx context.Context, container string, name string, ranges []api.Range) (*api.Object, error) {
+	return client.withContext(ctx).GetObject(container, name, ranges)
+}
+
+//GetObjectMetadataContext get  object metadata from an object container, the requests are cancelled when ctx is done
+func (client *Client) GetObjectMetadataContext(ctx context.Context, container string, name string) (*api.Object, error) {
+	return client.withContext(ctx).GetObjectMetadata(container, name)
+}
+
+//ListObjectsContext list objects of a container, the requests are cancelled when ctx is done
+func (client *Client) ListObjectsContext(ctx context.Context, container string, filter api.ObjectFilter) ([]string, error) {
+	return client.withContext(ctx).ListObjects(container, filter)
+}
+
+//CopyObjectContext copies an object, the requests are cancelled when ctx is done
+func (client *Client) CopyObjectContext(ctx context.Context, containerSrc string, objectSrc string, objectDst string) error {
+	return client.withContext(ctx).CopyObject(containerSrc, objectSrc, objectDst)
+}
+
+//DeleteObjectContext deleta an object from a container, the requests are cancelled when ctx is done
+func (client *Client) DeleteObjectContext(ctx context.Context, container string, object string) error {
+	return client.withContext(ctx).DeleteObject(container, object)
+}
@@ -0,0 +1,686 @@
+package fake
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+	uuid "github.com/satori/go.uuid"
+)
+
+type keypair struct {
+	Name        string `json:"name"`
+	PublicKey   string `json:"public_key"`
+	Fingerprint string `json:"fingerprint"`
+	UserID      string `json:"user_id"`
+}
+
+type secgroupRule struct {
+	ID            string                 `json:"id"`
+	ParentGroupID string                 `json:"parent_group_id"`
+	FromPort      int                    `json:"from_port"`
+	ToPort        int                    `json:"to_port"`
+	IPProtocol    string                 `json:"ip_protocol"`
+	IPRange       map[string]interface{} `json:"ip_range"`
+	Group         map[string]interface{} `json:"group"`
+}
+
+type secgroup struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Rules       []secgroupRule `json:"rules"`
+	TenantID    string         `json:"tenant_id"`
+}
+
+type address struct {
+	Version int    `json:"version"`
+	Addr    string `json:"addr"`
+}
+
+type server struct {
+	ID             string
+	Name           string
+	Status         string
+	FlavorID       string
+	ImageID        string
+	KeyName        string
+	UserData       string
+	SecurityGroups []string
+	Networks       []string
+	Addresses      map[string][]address
+	created        time.Time
+}
+
+type floatingIP struct {
+	ID         string `json:"id"`
+	Pool       string `json:"pool"`
+	IP         string `json:"ip"`
+	FixedIP    string `json:"fixed_ip"`
+	InstanceID string `json:"instance_id"`
+}
+
+//refresh computes the status of a server in BUILD state
+func (srv *Server) refresh(s *server) {
+	if s.Status == "BUILD" && !time.Now().Before(s.created.Add(srv.Opts.BuildDelay)) {
+		s.Status = "ACTIVE"
+	}
+}
+
+func (srv *Server) serverView(s *server) map[string]interface{} {
+	srv.refresh(s)
+	sgs := []map[string]interface{}{}
+	for _, sg := range s.SecurityGroups {
+		sgs = append(sgs, map[string]interface{}{"name": sg})
+	}
+	return map[string]interface{}{
+		"id":              s.ID,
+		"name":            s.Name,
+		"status":          s.Status,
+		"tenant_id":       srv.Opts.TenantID,
+		"user_id":         srv.Opts.Username,
+		"hostId":          "",
+		"progress":        0,
+		"accessIPv4":      "",
+		"accessIPv6":      "",
+		"created":         s.created.UTC().Format(time.RFC3339),
+		"updated":         s.created.UTC().Format(time.RFC3339),
+		"key_name":        s.KeyName,
+		"flavor":          map[string]interface{}{"id": s.FlavorID, "links": []interface{}{}},
+		"image":           map[string]interface{}{"id": s.ImageID, "links": []interface{}{}},
+		"addresses":       s.Addresses,
+		"metadata":        map[string]interface{}{},
+		"links":           []interface{}{},
+		"security_groups": sgs,
+	}
+}
+
+func (srv *Server) findFlavor(id string) *Flavor {
+	for _, f := range srv.Opts.Flavors {
+		if f.ID == id {
+			flv := f
+			return &flv
+		}
+	}
+	return nil
+}
+
+func flavorView(f Flavor) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          f.ID,
+		"name":        f.Name,
+		"vcpus":       f.VCPUs,
+		"ram":         f.RAM,
+		"disk":        f.Disk,
+		"swap":        0,
+		"rxtx_factor": 1.0,
+		"links":       []interface{}{},
+		"OS-FLV-WITH-EXT-SPECS:extra_specs": map[string]interface{}{
+			"class": "standard",
+		},
+	}
+}
+
+func (srv *Server) imageView(id string) map[string]interface{} {
+	for _, img := range srv.Opts.Images {
+		if img.ID == id {
+			return map[string]interface{}{
+				"id":               img.ID,
+				"name":             img.Name,
+				"status":           "active",
+				"visibility":       "public",
+				"container_format": "bare",
+				"disk_format":      "qcow2",
+				"min_disk":         0,
+				"min_ram":          0,
+				"protected":        false,
+				"tags":             []string{},
+				"size":             1073741824,
+			}
+		}
+	}
+	return nil
+}
+
+func (srv *Server) serveCompute(w http.ResponseWriter, r *http.Request, segs []string) {
+	if len(segs) == 0 {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	switch segs[0] {
+	case "images":
+		srv.serveImages(w, r, segs[1:])
+	case "flavors":
+		srv.serveFlavors(w, r, segs[1:])
+	case "os-keypairs":
+		srv.serveKeypairs(w, r, segs[1:])
+	case "os-security-groups":
+		srv.serveSecgroups(w, r, segs[1:])
+	case "os-security-group-rules":
+		srv.serveSecgroupRules(w, r, segs[1:])
+	case "os-floating-ips":
+		srv.serveFloatingIPs(w, r, segs[1:])
+	case "servers":
+		srv.serveServers(w, r, segs[1:])
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (srv *Server) serveImages(w http.ResponseWriter, r *http.Request, segs []string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if len(segs) == 0 {
+		imgs := []map[string]interface{}{}
+		for _, img := range srv.Opts.Images {
+			imgs = append(imgs, srv.imageView(img.ID))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"images": imgs})
+		return
+	}
+	img := srv.imageView(segs[0])
+	if img == nil {
+		writeError(w, http.StatusNotFound, "Image %s could not be found", segs[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, img)
+}
+
+func (srv *Server) serveFlavors(w http.ResponseWriter, r *http.Request, segs []string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if len(segs) == 0 || segs[0] == "detail" {
+		flvs := []map[string]interface{}{}
+		for _, f := range srv.Opts.Flavors {
+			flvs = append(flvs, flavorView(f))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"flavors": flvs})
+		return
+	}
+	f := srv.findFlavor(segs[0])
+	if f == nil {
+		writeError(w, http.StatusNotFound, "Flavor %s could not be found", segs[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"flavor": flavorView(*f)})
+}
+
+func (srv *Server) serveKeypairs(w http.ResponseWriter, r *http.Request, segs []string) {
+	switch {
+	case len(segs) == 0 && r.Method == "GET":
+		var names []string
+		for n := range srv.keypairs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		kps := []map[string]interface{}{}
+		for _, n := range names {
+			kps = append(kps, map[string]interface{}{"keypair": srv.keypairs[n]})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keypairs": kps})
+	case len(segs) == 0 && r.Method == "POST":
+		var req struct {
+			Keypair keypair `json:"keypair"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+			return
+		}
+		if _, ok := srv.keypairs[req.Keypair.Name]; ok {
+			writeError(w, http.StatusConflict, "Key pair '%s' already exists.", req.Keypair.Name)
+			return
+		}
+		kp := req.Keypair
+		kp.UserID = srv.Opts.Username
+		kp.Fingerprint = uuid.NewV4().String()
+		srv.keypairs[kp.Name] = &kp
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keypair": kp})
+	case len(segs) == 1 && r.Method == "GET":
+		kp, ok := srv.keypairs[segs[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Keypair %s not found", segs[0])
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keypair": kp})
+	case len(segs) == 1 && r.Method == "DELETE":
+		if _, ok := srv.keypairs[segs[0]]; !ok {
+			writeError(w, http.StatusNotFound, "Keypair %s not found", segs[0])
+			return
+		}
+		delete(srv.keypairs, segs[0])
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (srv *Server) serveSecgroups(w http.ResponseWriter, r *http.Request, segs []string) {
+	switch {
+	case len(segs) == 0 && r.Method == "GET":
+		sgs := []*secgroup{}
+		for _, sg := range srv.secgroups {
+			sgs = append(sgs, sg)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"security_groups": sgs})
+	case len(segs) == 0 && r.Method == "POST":
+		var req struct {
+			SecurityGroup secgroup `json:"security_group"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+			return
+		}
+		sg := req.SecurityGroup
+		sg.ID = uuid.NewV4().String()
+		sg.TenantID = srv.Opts.TenantID
+		sg.Rules = []secgroupRule{}
+		srv.secgroups[sg.ID] = &sg
+		writeJSON(w, http.StatusOK, map[string]interface{}{"security_group": sg})
+	case len(segs) == 1 && r.Method == "GET":
+		sg, ok := srv.secgroups[segs[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Security group %s not found", segs[0])
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"security_group": sg})
+	case len(segs) == 1 && r.Method == "DELETE":
+		if _, ok := srv.secgroups[segs[0]]; !ok {
+			writeError(w, http.StatusNotFound, "Security group %s not found", segs[0])
+			return
+		}
+		delete(srv.secgroups, segs[0])
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (srv *Server) serveSecgroupRules(w http.ResponseWriter, r *http.Request, segs []string) {
+	if len(segs) != 0 || r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var req struct {
+		Rule struct {
+			ParentGroupID string `json:"parent_group_id"`
+			FromPort      int    `json:"from_port"`
+			ToPort        int    `json:"to_port"`
+			IPProtocol    string `json:"ip_protocol"`
+			CIDR          string `json:"cidr"`
+		} `json:"security_group_rule"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+		return
+	}
+	sg, ok := srv.secgroups[req.Rule.ParentGroupID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Security group %s not found", req.Rule.ParentGroupID)
+		return
+	}
+	rule := secgroupRule{
+		ID:            uuid.NewV4().String(),
+		ParentGroupID: sg.ID,
+		FromPort:      req.Rule.FromPort,
+		ToPort:        req.Rule.ToPort,
+		IPProtocol:    req.Rule.IPProtocol,
+		IPRange:       map[string]interface{}{"cidr": req.Rule.CIDR},
+		Group:         map[string]interface{}{},
+	}
+	sg.Rules = append(sg.Rules, rule)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"security_group_rule": rule})
+}
+
+func (srv *Server) serveFloatingIPs(w http.ResponseWriter, r *http.Request, segs []string) {
+	switch {
+	case len(segs) == 0 && r.Method == "GET":
+		fips := []*floatingIP{}
+		for _, fip := range srv.floatingIPs {
+			fips = append(fips, fip)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"floating_ips": fips})
+	case len(segs) == 0 && r.Method == "POST":
+		var req struct {
+			Pool string `json:"pool"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+			return
+		}
+		if req.Pool != srv.Opts.FloatingIPPool {
+			writeError(w, http.StatusNotFound, "Floating IP pool %s not found", req.Pool)
+			return
+		}
+		ip, err := srv.allocatePublicIP()
+		if err != nil {
+			writeError(w, http.StatusNotFound, "No more floating IPs available: %s", err.Error())
+			return
+		}
+		fip := floatingIP{
+			ID:   uuid.NewV4().String(),
+			Pool: req.Pool,
+			IP:   ip,
+		}
+		srv.floatingIPs[fip.ID] = &fip
+		writeJSON(w, http.StatusOK, map[string]interface{}{"floating_ip": fip})
+	case len(segs) == 1 && r.Method == "GET":
+		fip, ok := srv.floatingIPs[segs[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Floating IP %s not found", segs[0])
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"floating_ip": fip})
+	case len(segs) == 1 && r.Method == "DELETE":
+		if _, ok := srv.floatingIPs[segs[0]]; !ok {
+			writeError(w, http.StatusNotFound, "Floating IP %s not found", segs[0])
+			return
+		}
+		delete(srv.floatingIPs, segs[0])
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (srv *Server) serveServers(w http.ResponseWriter, r *http.Request, segs []string) {
+	switch {
+	case len(segs) == 0 && r.Method == "POST":
+		srv.createServer(w, r)
+	case (len(segs) == 0 || segs[0] == "detail") && r.Method == "GET":
+		var ids []string
+		for id := range srv.servers {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		list := []map[string]interface{}{}
+		for _, id := range ids {
+			list = append(list, srv.serverView(srv.servers[id]))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"servers": list})
+	default:
+		s, ok := srv.servers[segs[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Instance %s could not be found.", segs[0])
+			return
+		}
+		switch {
+		case len(segs) == 1 && r.Method == "GET":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"server": srv.serverView(s)})
+		case len(segs) == 1 && r.Method == "DELETE":
+			srv.deleteServer(w, s)
+		case len(segs) == 2 && segs[1] == "action" && r.Method == "POST":
+			srv.serverAction(w, r, s)
+		case len(segs) >= 2 && segs[1] == "os-volume_attachments":
+			srv.serveVolumeAttachments(w, r, s, segs[2:])
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
+
+func (srv *Server) createServer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Server struct {
+			Name           string `json:"name"`
+			ImageRef       string `json:"imageRef"`
+			FlavorRef      string `json:"flavorRef"`
+			KeyName        string `json:"key_name"`
+			UserData       string `json:"user_data"`
+			SecurityGroups []struct {
+				Name string `json:"name"`
+			} `json:"security_groups"`
+			Networks []struct {
+				UUID string `json:"uuid"`
+			} `json:"networks"`
+		} `json:"server"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+		return
+	}
+	if srv.findFlavor(req.Server.FlavorRef) == nil {
+		writeError(w, http.StatusBadRequest, "Flavor %s could not be found.", req.Server.FlavorRef)
+		return
+	}
+	if srv.imageView(req.Server.ImageRef) == nil {
+		writeError(w, http.StatusBadRequest, "Image %s could not be found.", req.Server.ImageRef)
+		return
+	}
+	if req.Server.KeyName != "" {
+		if _, ok := srv.keypairs[req.Server.KeyName]; !ok {
+			writeError(w, http.StatusBadRequest, "Invalid key_name provided.")
+			return
+		}
+	}
+	if req.Server.UserData != "" {
+		if _, err := base64.StdEncoding.DecodeString(req.Server.UserData); err != nil {
+			writeError(w, http.StatusBadRequest, "User data needs to be valid base 64.")
+			return
+		}
+	}
+	s := server{
+		ID:        uuid.NewV4().String(),
+		Name:      req.Server.Name,
+		Status:    "BUILD",
+		FlavorID:  req.Server.FlavorRef,
+		ImageID:   req.Server.ImageRef,
+		KeyName:   req.Server.KeyName,
+		UserData:  req.Server.UserData,
+		Addresses: map[string][]address{},
+		created:   time.Now(),
+	}
+	for _, sg := range req.Server.SecurityGroups {
+		s.SecurityGroups = append(s.SecurityGroups, sg.Name)
+	}
+	for _, n := range req.Server.Networks {
+		net, ok := srv.networks[n.UUID]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Network %s could not be found.", n.UUID)
+			return
+		}
+		if len(net.Subnets) == 0 {
+			writeError(w, http.StatusBadRequest, "Network %s requires a subnet in order to boot instances on.", n.UUID)
+			return
+		}
+		sn := srv.subnets[net.Subnets[0]]
+		var ip string
+		var err error
+		if net.External {
+			ip, err = srv.allocatePublicIP()
+		} else {
+			ip, err = sn.allocateIP()
+		}
+		if err != nil {
+			writeError(w, http.StatusConflict, "No more IP addresses available on network %s.", n.UUID)
+			return
+		}
+		version := 4
+		if IPVersion.IPv6.Is(ip) {
+			version = 6
+		}
+		s.Networks = append(s.Networks, net.ID)
+		s.Addresses[net.Name] = append(s.Addresses[net.Name], address{Version: version, Addr: ip})
+	}
+	srv.servers[s.ID] = &s
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"server": map[string]interface{}{
+			"id":        s.ID,
+			"adminPass": "secret",
+			"links":     []interface{}{},
+		},
+	})
+}
+
+func (srv *Server) deleteServer(w http.ResponseWriter, s *server) {
+	for _, fip := range srv.floatingIPs {
+		if fip.InstanceID == s.ID {
+			fip.InstanceID = ""
+			fip.FixedIP = ""
+		}
+	}
+	for _, v := range srv.volumes {
+		if v.ServerID == s.ID {
+			v.ServerID = ""
+			v.Device = ""
+			v.Status = "available"
+		}
+	}
+	delete(srv.servers, s.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) serverAction(w http.ResponseWriter, r *http.Request, s *server) {
+	var req map[string]map[string]interface{}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+		return
+	}
+	srv.refresh(s)
+	for action, args := range req {
+		switch action {
+		case "os-stop":
+			if s.Status != "ACTIVE" {
+				writeError(w, http.StatusConflict, "Cannot 'stop' instance %s while it is in vm_state %s", s.ID, s.Status)
+				return
+			}
+			s.Status = "SHUTOFF"
+		case "os-start":
+			if s.Status != "SHUTOFF" {
+				writeError(w, http.StatusConflict, "Cannot 'start' instance %s while it is in vm_state %s", s.ID, s.Status)
+				return
+			}
+			s.Status = "ACTIVE"
+		case "addFloatingIp":
+			addr, _ := args["address"].(string)
+			fip := srv.findFloatingIP(addr)
+			if fip == nil {
+				writeError(w, http.StatusNotFound, "Floating IP %s could not be found", addr)
+				return
+			}
+			if fip.InstanceID != "" {
+				writeError(w, http.StatusBadRequest, "Floating IP %s is already associated", addr)
+				return
+			}
+			fip.InstanceID = s.ID
+			for _, addrs := range s.Addresses {
+				if len(addrs) > 0 {
+					fip.FixedIP = addrs[0].Addr
+					break
+				}
+			}
+		case "removeFloatingIp":
+			addr, _ := args["address"].(string)
+			fip := srv.findFloatingIP(addr)
+			if fip == nil || fip.InstanceID != s.ID {
+				writeError(w, http.StatusNotFound, "Floating IP %s is not associated with instance %s", addr, s.ID)
+				return
+			}
+			fip.InstanceID = ""
+			fip.FixedIP = ""
+		default:
+			writeError(w, http.StatusBadRequest, "Unsupported action %s", action)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (srv *Server) findFloatingIP(ip string) *floatingIP {
+	for _, fip := range srv.floatingIPs {
+		if fip.IP == ip {
+			return fip
+		}
+	}
+	return nil
+}
+
+func (srv *Server) serveVolumeAttachments(w http.ResponseWriter, r *http.Request, s *server, segs []string) {
+	view := func(v *volume) map[string]interface{} {
+		return map[string]interface{}{
+			"id":       v.ID,
+			"volumeId": v.ID,
+			"serverId": v.ServerID,
+			"device":   v.Device,
+		}
+	}
+	switch {
+	case len(segs) == 0 && r.Method == "GET":
+		var ids []string
+		for id, v := range srv.volumes {
+			if v.ServerID == s.ID {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		vas := []map[string]interface{}{}
+		for _, id := range ids {
+			vas = append(vas, view(srv.volumes[id]))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"volumeAttachments": vas})
+	case len(segs) == 0 && r.Method == "POST":
+		var req struct {
+			VolumeAttachment struct {
+				VolumeID string `json:"volumeId"`
+				Device   string `json:"device"`
+			} `json:"volumeAttachment"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+			return
+		}
+		v, ok := srv.volumes[req.VolumeAttachment.VolumeID]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Volume %s could not be found.", req.VolumeAttachment.VolumeID)
+			return
+		}
+		srv.refreshVolume(v)
+		if v.Status != "available" {
+			writeError(w, http.StatusBadRequest, "Invalid volume: volume %s status must be available, but current status is: %s", v.ID, v.Status)
+			return
+		}
+		used := map[string]bool{}
+		for _, o := range srv.volumes {
+			if o.ServerID == s.ID {
+				used[o.Device] = true
+			}
+		}
+		for c := 'b'; c <= 'z'; c++ {
+			d := fmt.Sprintf("/dev/vd%c", c)
+			if !used[d] {
+				v.Device = d
+				break
+			}
+		}
+		v.ServerID = s.ID
+		v.Status = "in-use"
+		writeJSON(w, http.StatusOK, map[string]interface{}{"volumeAttachment": view(v)})
+	case len(segs) == 1:
+		v, ok := srv.volumes[segs[0]]
+		if !ok || v.ServerID != s.ID {
+			writeError(w, http.StatusNotFound, "Instance %s is not attached to volume %s.", s.ID, segs[0])
+			return
+		}
+		switch r.Method {
+		case "GET":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"volumeAttachment": view(v)})
+		case "DELETE":
+			v.ServerID = ""
+			v.Device = ""
+			v.Status = "available"
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
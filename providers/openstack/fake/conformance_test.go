@@ -0,0 +1,29 @@
+package fake
+
+import (
+	"os"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/openstack"
+	"github.com/SebastienDorgan/gpac/providers/tests"
+)
+
+//TestConformance runs the conformance scenarios against the fake, the report is saved in $GPAC_CONFORMANCE_DIR if set
+func TestConformance(t *testing.T) {
+	//the SSD type is used by the VolumeUpdate scenario
+	srv := NewServer(Options{VolumeTypes: []string{"classic", "high-speed", "ssd"}})
+	defer srv.Close()
+	c := tests.Conformance{
+		Provider: "openstack-fake",
+		Factory: func() (api.ClientAPI, error) {
+			return openstack.AuthenticatedClient(srv.AuthOptions(), srv.CfgOptions())
+		},
+		SkipRemote: true,
+	}
+	report := c.Run(t)
+	err := report.Save(os.Getenv("GPAC_CONFORMANCE_DIR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
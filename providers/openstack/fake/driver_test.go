@@ -0,0 +1,231 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeSpeed"
+	"github.com/SebastienDorgan/gpac/providers/openstack"
+)
+
+//newClient returns a client of the openstack driver connected to srv
+func newClient(t *testing.T, srv *Server) *openstack.Client {
+	clt, err := openstack.AuthenticatedClient(srv.AuthOptions(), srv.CfgOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clt
+}
+
+//networkRequest returns the request of a network whose gateway uses the first flavor and image of the fake
+func networkRequest(name string) api.NetworkRequest {
+	return api.NetworkRequest{
+		Name:      name,
+		IPVersion: IPVersion.IPv4,
+		CIDR:      "192.168.1.0/24",
+		GWRequest: api.VMRequest{
+			Name:       name + "-gw",
+			TemplateID: DefaultFlavors[0].ID,
+			ImageID:    DefaultImages[0].ID,
+		},
+	}
+}
+
+//counts returns the number of networks, subnets, routers and servers of srv
+func (srv *Server) counts() (int, int, int, int) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.networks), len(srv.subnets), len(srv.routers), len(srv.servers)
+}
+
+func TestAuthenticatedClient(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	auth := srv.AuthOptions()
+	auth.Password = "wrong"
+	_, err := openstack.AuthenticatedClient(auth, srv.CfgOptions())
+	if err == nil {
+		t.Fatal("client authenticated with a wrong password")
+	}
+
+	//the services are found in the catalog of the token
+	clt := newClient(t, srv)
+	imgs, err := clt.ListImages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imgs) != len(DefaultImages) {
+		t.Fatalf("expected the %d images of the fake, got %+v", len(DefaultImages), imgs)
+	}
+	tpls, err := clt.ListTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpls) != len(DefaultFlavors) {
+		t.Fatalf("expected the %d flavors of the fake, got %+v", len(DefaultFlavors), tpls)
+	}
+}
+
+func TestCreateNetworkRollback(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+	nets, sns, rts, vms := srv.counts()
+
+	req := networkRequest("net")
+	req.GWRequest.TemplateID = "unknown"
+	_, err := clt.CreateNetwork(req)
+	if err == nil {
+		t.Fatal("network created with an unknown flavor")
+	}
+	n, sn, rt, vm := srv.counts()
+	if n != nets || sn != sns || rt != rts || vm != vms {
+		t.Fatalf("resources left behind: %d networks, %d subnets, %d routers, %d servers", n-nets, sn-sns, rt-rts, vm-vms)
+	}
+	kps, err := clt.ListKeyPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kps) != 0 {
+		t.Fatalf("key pairs left behind: %v", kps)
+	}
+}
+
+func TestDeleteNetwork(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+	nets, sns, rts, vms := srv.counts()
+
+	net, err := clt.CreateNetwork(networkRequest("net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clt.GetVM(net.GatewayID); err != nil {
+		t.Fatal(err)
+	}
+	err = clt.DeleteNetwork(net.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, sn, rt, vm := srv.counts()
+	if n != nets || sn != sns || rt != rts || vm != vms {
+		t.Fatalf("resources left behind: %d networks, %d subnets, %d routers, %d servers", n-nets, sn-sns, rt-rts, vm-vms)
+	}
+	_, err = clt.GetNetwork(net.ID)
+	if api.KindOf(err) != api.ErrNotFound {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
+
+func TestGetVMNotFound(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+
+	_, err := clt.GetVM("2f4e7b41-7a4b-4d39-9d8e-5a3f1c6b2e90")
+	if api.KindOf(err) != api.ErrNotFound {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
+
+//floatingIPCount returns the number of floating IPs of srv
+func (srv *Server) floatingIPCount() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.floatingIPs)
+}
+
+//createDeleteVM creates a VM with a public IP on a new network of clt then deletes the VM and the network, no
+//resource must be left behind
+func createDeleteVM(t *testing.T, srv *Server, clt api.ClientAPI) {
+	nets, sns, rts, vms := srv.counts()
+	fips := srv.floatingIPCount()
+
+	net, err := clt.CreateNetwork(networkRequest("net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := clt.CreateVM(api.VMRequest{
+		Name:       "vm",
+		NetworkIDs: []string{net.ID},
+		PublicIP:   true,
+		TemplateID: DefaultFlavors[0].ID,
+		ImageID:    DefaultImages[0].ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.Name != "vm" || vm.PrivateKey == "" || vm.AccessIPv4 == "" {
+		t.Fatalf("expected a VM named vm with a private key and a public IP, got %+v", vm)
+	}
+	got, err := clt.GetVM(vm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != vm.ID || got.PrivateKey != vm.PrivateKey {
+		t.Fatalf("expected VM %s with its private key, got %+v", vm.ID, got)
+	}
+	err = clt.DeleteVM(vm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = clt.GetVM(vm.ID)
+	if api.KindOf(err) != api.ErrNotFound {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	err = clt.DeleteNetwork(net.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, sn, rt, v := srv.counts()
+	if n != nets || sn != sns || rt != rts || v != vms {
+		t.Fatalf("resources left behind: %d networks, %d subnets, %d routers, %d servers", n-nets, sn-sns, rt-rts, v-vms)
+	}
+	if f := srv.floatingIPCount(); f != fips {
+		t.Fatalf("%d floating IPs left behind", f-fips)
+	}
+}
+
+func TestCreateDeleteVM(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	createDeleteVM(t, srv, newClient(t, srv))
+}
+
+func TestUpdateVolume(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+
+	v, err := clt.CreateVolume(api.VolumeRequest{Name: "v1", Size: 10, Speed: VolumeSpeed.COLD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clt.DeleteVolume(v.ID)
+	size, speed := 20, VolumeSpeed.HDD
+	v, err = clt.UpdateVolume(v.ID, api.VolumeUpdateRequest{Size: &size, Speed: &speed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Size != 20 || v.Speed != VolumeSpeed.HDD || srv.volumeType(v.ID) != "high-speed" {
+		t.Fatalf("expected a volume of 20 GB of type high-speed, got %+v", v)
+	}
+	//without SSD type, the volume keeps the HDD type
+	speed = VolumeSpeed.SSD
+	v, err = clt.UpdateVolume(v.ID, api.VolumeUpdateRequest{Speed: &speed})
+	if err != nil || srv.volumeType(v.ID) != "high-speed" {
+		t.Fatalf("expected a volume of type high-speed, got %+v (%v)", v, err)
+	}
+
+	size = 10
+	_, err = clt.UpdateVolume(v.ID, api.VolumeUpdateRequest{Size: &size})
+	if api.KindOf(err) != api.ErrInvalidRequest {
+		t.Fatalf("expected an invalid request error, got %v", err)
+	}
+	_, err = clt.UpdateVolume("2f4e7b41-7a4b-4d39-9d8e-5a3f1c6b2e90", api.VolumeUpdateRequest{Size: &size})
+	if api.KindOf(err) != api.ErrNotFound {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package fake
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/openstack"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/secgroups"
+	"github.com/rackspace/gophercloud/openstack/networking/v2/networks"
+	uuid "github.com/satori/go.uuid"
+)
+
+//putRecord writes a record of kind with data written at written in the metadata store of clt
+func putRecord(t *testing.T, clt *openstack.Client, bucket string, key string, kind string, data interface{}, written time.Time) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := providers.Record{Schema: providers.RecordSchemaVersion, Kind: kind, Data: b, Written: written}
+	b, err = json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = clt.MetadataStore().Put(bucket, key, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+//markCreated records the creation of the resource id of kind at created
+func markCreated(t *testing.T, clt *openstack.Client, kind string, id string, created time.Time) {
+	putRecord(t, clt, openstack.CreatedContainer, id, providers.RecordCreation, map[string]interface{}{
+		"kind":    kind,
+		"created": created,
+	}, created)
+}
+
+//duplicateSecurityGroup creates a duplicate of the security group of gpac with description
+func duplicateSecurityGroup(t *testing.T, clt *openstack.Client, description string) string {
+	sg, err := secgroups.Create(clt.Compute, secgroups.CreateOpts{
+		Name:        clt.SecurityGroup.Name,
+		Description: description,
+	}).Extract()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sg.ID
+}
+
+func TestCollectGarbage(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	clt := newClient(t, srv)
+	old := time.Now().Add(-2 * time.Hour).UTC()
+
+	//resources leaked by gpac two hours ago
+	oldGroup := duplicateSecurityGroup(t, clt, "Default security group, created "+old.Format(time.RFC3339))
+	kp, err := clt.CreateKeyPair("vm_" + uuid.NewV4().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	markCreated(t, clt, providers.OrphanKeyPair, kp.ID, old)
+	net, err := networks.Create(clt.Network, networks.CreateOpts{Name: "leaked"}).Extract()
+	if err != nil {
+		t.Fatal(err)
+	}
+	markCreated(t, clt, providers.OrphanNetwork, net.ID, old)
+	putRecord(t, clt, openstack.VMContainer, "deleted-vm", providers.RecordVM, map[string]string{"id": "deleted-vm"}, old)
+	//resources of unknown age
+	duplicateSecurityGroup(t, clt, "Default security group")
+	_, err = clt.MetadataStore().Put(openstack.VMContainer, "legacy-vm", []byte(`{"id": "legacy-vm"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//resource being created
+	duplicateSecurityGroup(t, clt, "Default security group, created "+time.Now().UTC().Format(time.RFC3339))
+	//network of gpac
+	_, err = clt.CreateNetwork(networkRequest("net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := providers.CollectGarbage(clt, providers.GCOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphans) != 4 || report.Recent != 1 || report.Unknown != 2 {
+		t.Fatalf("expected 4 orphans listed, 1 recent orphan and 2 orphans of unknown age, got %+v", report)
+	}
+	kinds := map[string]string{}
+	for _, o := range report.Orphans {
+		kinds[o.ID] = o.Kind
+	}
+	expected := map[string]string{
+		oldGroup:                              providers.OrphanSecurityGroup,
+		kp.ID:                                 providers.OrphanKeyPair,
+		net.ID:                                providers.OrphanNetwork,
+		openstack.VMContainer + "/deleted-vm": providers.OrphanMetadata,
+	}
+	for id, kind := range expected {
+		if kinds[id] != kind {
+			t.Errorf("expected %s to be an orphan of kind %s, got %+v", id, kind, report.Orphans)
+		}
+	}
+
+	report, err = providers.CollectGarbage(clt, providers.GCOptions{Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deleted != 4 || report.Failed != 0 {
+		t.Fatalf("expected 4 orphans deleted, got %+v", report)
+	}
+	report, err = providers.CollectGarbage(clt, providers.GCOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the creation records of the deleted key pair and network are deleted with them
+	if len(report.Orphans) != 0 || report.Recent != 1 || report.Unknown != 2 {
+		t.Fatalf("expected the old orphans to be deleted, got %+v", report)
+	}
+	report, err = providers.CollectGarbage(clt, providers.GCOptions{Delete: true, UnknownAge: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deleted != 2 || report.Failed != 0 {
+		t.Fatalf("expected the 2 orphans of unknown age to be deleted, got %+v", report)
+	}
+}
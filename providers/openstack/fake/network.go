@@ -0,0 +1,428 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"sort"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+type network struct {
+	ID           string
+	Name         string
+	AdminStateUp bool
+	Status       string
+	Shared       bool
+	External     bool
+	Subnets      []string
+}
+
+type subnet struct {
+	ID         string
+	NetworkID  string
+	Name       string
+	IPVersion  int
+	CIDR       string
+	GatewayIP  string
+	EnableDHCP bool
+	DNS        []string
+	//allocated number of IPs already allocated in the subnet
+	allocated int
+}
+
+type router struct {
+	ID           string
+	Name         string
+	AdminStateUp bool
+	NetworkID    string
+	Subnets      []string
+}
+
+//ipAt returns the n-th address of the network defined by cidr
+func ipAt(cidr string, n int) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := ipNet.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	offset := big.NewInt(int64(n))
+	if offset.Cmp(size) >= 0 {
+		return "", fmt.Errorf("Network %s is full", cidr)
+	}
+	ip := new(big.Int).SetBytes(ipNet.IP)
+	ip.Add(ip, offset)
+	b := ip.Bytes()
+	addr := make(net.IP, len(ipNet.IP))
+	copy(addr[len(addr)-len(b):], b)
+	return addr.String(), nil
+}
+
+//allocateIP allocates an IP in the subnet
+func (sn *subnet) allocateIP() (string, error) {
+	//The first address is reserved to the network, the second one to the gateway
+	sn.allocated++
+	return ipAt(sn.CIDR, sn.allocated+1)
+}
+
+//allocatePublicIP allocates an IP in the provider network
+func (srv *Server) allocatePublicIP() (string, error) {
+	srv.publicIPs++
+	return ipAt(srv.Opts.ProviderCIDR, srv.publicIPs+1)
+}
+
+func (srv *Server) networkView(n *network) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              n.ID,
+		"name":            n.Name,
+		"admin_state_up":  n.AdminStateUp,
+		"status":          n.Status,
+		"shared":          n.Shared,
+		"router:external": n.External,
+		"subnets":         append([]string{}, n.Subnets...),
+		"tenant_id":       srv.Opts.TenantID,
+	}
+}
+
+func (srv *Server) subnetView(sn *subnet) map[string]interface{} {
+	var gw interface{}
+	if sn.GatewayIP != "" {
+		gw = sn.GatewayIP
+	}
+	return map[string]interface{}{
+		"id":               sn.ID,
+		"network_id":       sn.NetworkID,
+		"name":             sn.Name,
+		"ip_version":       sn.IPVersion,
+		"cidr":             sn.CIDR,
+		"gateway_ip":       gw,
+		"enable_dhcp":      sn.EnableDHCP,
+		"dns_nameservers":  append([]string{}, sn.DNS...),
+		"allocation_pools": []interface{}{},
+		"host_routes":      []interface{}{},
+		"tenant_id":        srv.Opts.TenantID,
+	}
+}
+
+func (srv *Server) routerView(rt *router) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             rt.ID,
+		"name":           rt.Name,
+		"status":         "ACTIVE",
+		"admin_state_up": rt.AdminStateUp,
+		"external_gateway_info": map[string]interface{}{
+			"network_id": rt.NetworkID,
+		},
+		"tenant_id": srv.Opts.TenantID,
+	}
+}
+
+func (srv *Server) serveNetwork(w http.ResponseWriter, r *http.Request, segs []string) {
+	if len(segs) == 0 {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	switch segs[0] {
+	case "networks":
+		srv.serveNetworks(w, r, segs[1:])
+	case "subnets":
+		srv.serveSubnets(w, r, segs[1:])
+	case "routers":
+		srv.serveRouters(w, r, segs[1:])
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (srv *Server) serveNetworks(w http.ResponseWriter, r *http.Request, segs []string) {
+	switch {
+	case len(segs) == 0 && r.Method == "GET":
+		name := r.URL.Query().Get("name")
+		var ids []string
+		for id, n := range srv.networks {
+			if name == "" || n.Name == name {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		list := []map[string]interface{}{}
+		for _, id := range ids {
+			list = append(list, srv.networkView(srv.networks[id]))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"networks": list})
+	case len(segs) == 0 && r.Method == "POST":
+		var req struct {
+			Network struct {
+				Name         string `json:"name"`
+				AdminStateUp *bool  `json:"admin_state_up"`
+				Shared       bool   `json:"shared"`
+			} `json:"network"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+			return
+		}
+		n := network{
+			ID:           uuid.NewV4().String(),
+			Name:         req.Network.Name,
+			AdminStateUp: req.Network.AdminStateUp == nil || *req.Network.AdminStateUp,
+			Status:       "ACTIVE",
+			Shared:       req.Network.Shared,
+		}
+		srv.networks[n.ID] = &n
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"network": srv.networkView(&n)})
+	case len(segs) == 1:
+		n, ok := srv.networks[segs[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Network %s could not be found.", segs[0])
+			return
+		}
+		switch r.Method {
+		case "GET":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"network": srv.networkView(n)})
+		case "DELETE":
+			for _, s := range srv.servers {
+				for _, id := range s.Networks {
+					if id == n.ID {
+						writeError(w, http.StatusConflict, "Unable to complete operation on network %s. There are one or more ports still in use on the network.", n.ID)
+						return
+					}
+				}
+			}
+			for _, snID := range n.Subnets {
+				delete(srv.subnets, snID)
+			}
+			delete(srv.networks, n.ID)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (srv *Server) serveSubnets(w http.ResponseWriter, r *http.Request, segs []string) {
+	switch {
+	case len(segs) == 0 && r.Method == "GET":
+		netID := r.URL.Query().Get("network_id")
+		var ids []string
+		for id, sn := range srv.subnets {
+			if netID == "" || sn.NetworkID == netID {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		list := []map[string]interface{}{}
+		for _, id := range ids {
+			list = append(list, srv.subnetView(srv.subnets[id]))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"subnets": list})
+	case len(segs) == 0 && r.Method == "POST":
+		var req struct {
+			Subnet struct {
+				NetworkID  string           `json:"network_id"`
+				Name       string           `json:"name"`
+				CIDR       string           `json:"cidr"`
+				IPVersion  int              `json:"ip_version"`
+				GatewayIP  *json.RawMessage `json:"gateway_ip"`
+				EnableDHCP *bool            `json:"enable_dhcp"`
+				DNS        []string         `json:"dns_nameservers"`
+			} `json:"subnet"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+			return
+		}
+		n, ok := srv.networks[req.Subnet.NetworkID]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Network %s could not be found.", req.Subnet.NetworkID)
+			return
+		}
+		_, ipNet, err := net.ParseCIDR(req.Subnet.CIDR)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid input for cidr: %s", err.Error())
+			return
+		}
+		sn := subnet{
+			ID:         uuid.NewV4().String(),
+			NetworkID:  n.ID,
+			Name:       req.Subnet.Name,
+			IPVersion:  req.Subnet.IPVersion,
+			CIDR:       ipNet.String(),
+			EnableDHCP: req.Subnet.EnableDHCP == nil || *req.Subnet.EnableDHCP,
+			DNS:        req.Subnet.DNS,
+		}
+		if sn.IPVersion == 0 {
+			sn.IPVersion = 4
+		}
+		//gateway_ip is null when no gateway is requested, the first host address is used when not given
+		if req.Subnet.GatewayIP == nil {
+			sn.GatewayIP, _ = ipAt(sn.CIDR, 1)
+		} else {
+			var gw *string
+			json.Unmarshal(*req.Subnet.GatewayIP, &gw)
+			if gw != nil {
+				sn.GatewayIP = *gw
+			}
+		}
+		srv.subnets[sn.ID] = &sn
+		n.Subnets = append(n.Subnets, sn.ID)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"subnet": srv.subnetView(&sn)})
+	case len(segs) == 1:
+		sn, ok := srv.subnets[segs[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Subnet %s could not be found.", segs[0])
+			return
+		}
+		switch r.Method {
+		case "GET":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"subnet": srv.subnetView(sn)})
+		case "DELETE":
+			for _, rt := range srv.routers {
+				for _, id := range rt.Subnets {
+					if id == sn.ID {
+						writeError(w, http.StatusConflict, "Unable to complete operation on subnet %s. One or more ports have an IP allocation from this subnet.", sn.ID)
+						return
+					}
+				}
+			}
+			if n, ok := srv.networks[sn.NetworkID]; ok {
+				n.Subnets = remove(n.Subnets, sn.ID)
+			}
+			delete(srv.subnets, sn.ID)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (srv *Server) serveRouters(w http.ResponseWriter, r *http.Request, segs []string) {
+	switch {
+	case len(segs) == 0 && r.Method == "GET":
+		var ids []string
+		for id := range srv.routers {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		list := []map[string]interface{}{}
+		for _, id := range ids {
+			list = append(list, srv.routerView(srv.routers[id]))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"routers": list})
+	case len(segs) == 0 && r.Method == "POST":
+		var req struct {
+			Router struct {
+				Name         string `json:"name"`
+				AdminStateUp *bool  `json:"admin_state_up"`
+				GatewayInfo  *struct {
+					NetworkID string `json:"network_id"`
+				} `json:"external_gateway_info"`
+			} `json:"router"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+			return
+		}
+		rt := router{
+			ID:           uuid.NewV4().String(),
+			Name:         req.Router.Name,
+			AdminStateUp: req.Router.AdminStateUp == nil || *req.Router.AdminStateUp,
+		}
+		if req.Router.GatewayInfo != nil {
+			if _, ok := srv.networks[req.Router.GatewayInfo.NetworkID]; !ok {
+				writeError(w, http.StatusNotFound, "Network %s could not be found.", req.Router.GatewayInfo.NetworkID)
+				return
+			}
+			rt.NetworkID = req.Router.GatewayInfo.NetworkID
+		}
+		srv.routers[rt.ID] = &rt
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"router": srv.routerView(&rt)})
+	case len(segs) >= 1:
+		rt, ok := srv.routers[segs[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Router %s could not be found", segs[0])
+			return
+		}
+		switch {
+		case len(segs) == 1 && r.Method == "GET":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"router": srv.routerView(rt)})
+		case len(segs) == 1 && r.Method == "DELETE":
+			if len(rt.Subnets) > 0 {
+				writeError(w, http.StatusConflict, "Router %s still has ports", rt.ID)
+				return
+			}
+			delete(srv.routers, rt.ID)
+			w.WriteHeader(http.StatusNoContent)
+		case len(segs) == 2 && r.Method == "PUT":
+			srv.routerInterface(w, r, rt, segs[1])
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (srv *Server) routerInterface(w http.ResponseWriter, r *http.Request, rt *router, action string) {
+	var req struct {
+		SubnetID string `json:"subnet_id"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+		return
+	}
+	if _, ok := srv.subnets[req.SubnetID]; !ok {
+		writeError(w, http.StatusNotFound, "Subnet %s could not be found.", req.SubnetID)
+		return
+	}
+	attached := false
+	for _, id := range rt.Subnets {
+		if id == req.SubnetID {
+			attached = true
+		}
+	}
+	switch action {
+	case "add_router_interface":
+		if attached {
+			writeError(w, http.StatusBadRequest, "Router already has a port on subnet %s", req.SubnetID)
+			return
+		}
+		rt.Subnets = append(rt.Subnets, req.SubnetID)
+	case "remove_router_interface":
+		if !attached {
+			writeError(w, http.StatusNotFound, "Router %s has no interface on subnet %s", rt.ID, req.SubnetID)
+			return
+		}
+		rt.Subnets = remove(rt.Subnets, req.SubnetID)
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":        rt.ID,
+		"subnet_id": req.SubnetID,
+		"port_id":   uuid.NewV4().String(),
+		"tenant_id": srv.Opts.TenantID,
+	})
+}
+
+//remove returns list without value
+func remove(list []string, value string) []string {
+	var res []string
+	for _, v := range list {
+		if v != value {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,71 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeSpeed"
+	"github.com/SebastienDorgan/gpac/providers/cloudwatt"
+	"github.com/SebastienDorgan/gpac/providers/ovh"
+)
+
+//volumeType returns the Cinder type of the volume id of srv
+func (srv *Server) volumeType(id string) string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if v, ok := srv.volumes[id]; ok {
+		return v.VolumeType
+	}
+	return ""
+}
+
+//checkVolumeType checks that the volumes of speed are created with the Cinder type vType
+func checkVolumeType(t *testing.T, srv *Server, clt api.ClientAPI, speed VolumeSpeed.Enum, vType string) {
+	v, err := clt.CreateVolume(api.VolumeRequest{Name: "v", Size: 10, Speed: speed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clt.DeleteVolume(v.ID)
+	if got := srv.volumeType(v.ID); got != vType {
+		t.Fatalf("expected a volume of type %s, got %s", vType, got)
+	}
+}
+
+func TestOVHClient(t *testing.T) {
+	srv := NewServer(OVHOptions("0123456789abcdef0123456789abcdef"))
+	defer srv.Close()
+	clt, err := ovh.AuthenticatedClient(ovh.AuthOptions{
+		Endpoint:          "ovh-eu",
+		ApplicationName:   "gpac",
+		ApplicationKey:    srv.Opts.TenantID,
+		ConsumerKey:       "consumer",
+		OpenstackID:       srv.Opts.Username,
+		OpenstackPassword: srv.Opts.Password,
+		Region:            srv.Opts.Region,
+		IdentityEndpoint:  srv.IdentityEndpoint(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createDeleteVM(t, srv, clt)
+	checkVolumeType(t, srv, clt, VolumeSpeed.COLD, "classic")
+	checkVolumeType(t, srv, clt, VolumeSpeed.HDD, "high-speed")
+}
+
+func TestCloudwattClient(t *testing.T) {
+	srv := NewServer(CloudwattOptions("gpac-tenant"))
+	defer srv.Close()
+	clt, err := cloudwatt.AuthenticatedClient(cloudwatt.AuthOptions{
+		Username:         srv.Opts.Username,
+		Password:         srv.Opts.Password,
+		TenantName:       srv.Opts.TenantName,
+		Region:           srv.Opts.Region,
+		IdentityEndpoint: srv.IdentityEndpoint(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createDeleteVM(t, srv, clt)
+	checkVolumeType(t, srv, clt, VolumeSpeed.COLD, "standard")
+	checkVolumeType(t, srv, clt, VolumeSpeed.HDD, "performant")
+}
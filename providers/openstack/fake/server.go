@@ -0,0 +1,418 @@
+//Package fake provides an in-process stand-in of the OpenStack APIs used by the openstack driver
+//(Keystone v2 tokens, Nova, Neutron, Cinder v1 and Swift) to test the driver without a cloud
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeSpeed"
+	"github.com/SebastienDorgan/gpac/providers/openstack"
+	uuid "github.com/satori/go.uuid"
+)
+
+//Options options of the fake server
+type Options struct {
+	//Region name of the region exposed in the service catalog
+	Region string
+	//TenantID identifier of the tenant (project)
+	TenantID string
+	//TenantName name of the tenant (project)
+	TenantName string
+	//Username user allowed to authenticate
+	Username string
+	//Password password of the user
+	Password string
+	//ProviderNetwork name of the external network
+	ProviderNetwork string
+	//ProviderCIDR CIDR of the external network, used to allocate public and floating IPs
+	ProviderCIDR string
+	//FloatingIPPool name of the floating IP pool
+	FloatingIPPool string
+	//BuildDelay time spent by a server in BUILD state
+	BuildDelay time.Duration
+	//Images images exposed by Glance, DefaultImages is used if empty
+	Images []api.Image
+	//Flavors flavors exposed by Nova, DefaultFlavors is used if empty
+	Flavors []Flavor
+	//VolumeTypes volume types exposed by Cinder, CfgOptions maps them to the COLD, HDD then SSD speeds
+	VolumeTypes []string
+}
+
+//Flavor a Nova flavor
+type Flavor struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	VCPUs int    `json:"vcpus"`
+	//RAM in MB
+	RAM int `json:"ram"`
+	//Disk in GB
+	Disk int `json:"disk"`
+}
+
+//DefaultImages images exposed when Options.Images is empty
+var DefaultImages = []api.Image{
+	{ID: "5e6f1a3c-0b5b-4c1e-8a3b-ubuntu1604", Name: "Ubuntu 16.04"},
+	{ID: "8a1d4b2e-3c4f-4d5a-9b6c-debian9000", Name: "Debian 9"},
+	{ID: "c7e2f3a4-5b6c-4d7e-8f9a-centos7000", Name: "Centos 7"},
+}
+
+//DefaultFlavors flavors exposed when Options.Flavors is empty
+var DefaultFlavors = []Flavor{
+	{ID: "flv-s1-2", Name: "s1-2", VCPUs: 1, RAM: 2000, Disk: 10},
+	{ID: "flv-s1-4", Name: "s1-4", VCPUs: 1, RAM: 4000, Disk: 20},
+	{ID: "flv-s1-8", Name: "s1-8", VCPUs: 2, RAM: 8000, Disk: 40},
+	{ID: "flv-b2-15", Name: "b2-15", VCPUs: 4, RAM: 15000, Disk: 100},
+}
+
+//OVHOptions returns options of a fake cloud matching the configuration of the ovh driver
+//applicationKey is the OVH application key used as tenant ID
+func OVHOptions(applicationKey string) Options {
+	return Options{
+		Region:          "GRA3",
+		TenantID:        applicationKey,
+		ProviderNetwork: "Ext-Net",
+		VolumeTypes:     []string{"classic", "high-speed"},
+	}
+}
+
+//CloudwattOptions returns options of a fake cloud matching the configuration of the cloudwatt driver
+func CloudwattOptions(tenantName string) Options {
+	return Options{
+		Region:          "fr1",
+		TenantName:      tenantName,
+		ProviderNetwork: "public",
+		FloatingIPPool:  "public",
+		VolumeTypes:     []string{"standard", "performant"},
+	}
+}
+
+//Server fake OpenStack cloud listening on a local HTTP endpoint
+type Server struct {
+	*httptest.Server
+	Opts Options
+
+	mu       sync.Mutex
+	token    string
+	requests []string
+
+	keypairs    map[string]*keypair
+	secgroups   map[string]*secgroup
+	servers     map[string]*server
+	floatingIPs map[string]*floatingIP
+	networks    map[string]*network
+	subnets     map[string]*subnet
+	routers     map[string]*router
+	volumes     map[string]*volume
+	containers  map[string]map[string]*object
+	//containerMeta metadata of containers
+	containerMeta map[string]map[string]string
+	publicIPs     int
+}
+
+//NewServer starts a fake OpenStack cloud
+func NewServer(opts Options) *Server {
+	if opts.Region == "" {
+		opts.Region = "RegionOne"
+	}
+	if opts.TenantID == "" {
+		opts.TenantID = "0123456789abcdef0123456789abcdef"
+	}
+	if opts.TenantName == "" {
+		opts.TenantName = "gpac"
+	}
+	if opts.Username == "" {
+		opts.Username = "gpac"
+	}
+	if opts.Password == "" {
+		opts.Password = "secret"
+	}
+	if opts.ProviderNetwork == "" {
+		opts.ProviderNetwork = "public"
+	}
+	if opts.ProviderCIDR == "" {
+		opts.ProviderCIDR = "198.51.100.0/24"
+	}
+	if opts.FloatingIPPool == "" {
+		opts.FloatingIPPool = opts.ProviderNetwork
+	}
+	if len(opts.Images) == 0 {
+		opts.Images = DefaultImages
+	}
+	if len(opts.Flavors) == 0 {
+		opts.Flavors = DefaultFlavors
+	}
+	if len(opts.VolumeTypes) == 0 {
+		opts.VolumeTypes = []string{"classic", "high-speed"}
+	}
+	srv := &Server{
+		Opts:          opts,
+		keypairs:      make(map[string]*keypair),
+		secgroups:     make(map[string]*secgroup),
+		servers:       make(map[string]*server),
+		floatingIPs:   make(map[string]*floatingIP),
+		networks:      make(map[string]*network),
+		subnets:       make(map[string]*subnet),
+		routers:       make(map[string]*router),
+		volumes:       make(map[string]*volume),
+		containers:    make(map[string]map[string]*object),
+		containerMeta: make(map[string]map[string]string),
+	}
+	ext := &network{
+		ID:           uuid.NewV4().String(),
+		Name:         opts.ProviderNetwork,
+		AdminStateUp: true,
+		Status:       "ACTIVE",
+		Shared:       true,
+		External:     true,
+	}
+	srv.networks[ext.ID] = ext
+	sn := &subnet{
+		ID:        uuid.NewV4().String(),
+		NetworkID: ext.ID,
+		Name:      opts.ProviderNetwork,
+		IPVersion: 4,
+		CIDR:      opts.ProviderCIDR,
+	}
+	srv.subnets[sn.ID] = sn
+	ext.Subnets = []string{sn.ID}
+	srv.Server = httptest.NewServer(http.HandlerFunc(srv.serveHTTP))
+	return srv
+}
+
+//IdentityEndpoint returns the Keystone v2 endpoint of the fake cloud
+func (srv *Server) IdentityEndpoint() string {
+	return srv.URL + "/identity/v2.0"
+}
+
+//AuthOptions returns openstack.AuthOptions matching the fake cloud
+func (srv *Server) AuthOptions() openstack.AuthOptions {
+	return openstack.AuthOptions{
+		IdentityEndpoint: srv.IdentityEndpoint(),
+		Username:         srv.Opts.Username,
+		Password:         srv.Opts.Password,
+		TenantID:         srv.Opts.TenantID,
+		Region:           srv.Opts.Region,
+		FloatingIPPool:   srv.Opts.FloatingIPPool,
+	}
+}
+
+//CfgOptions returns openstack.CfgOptions matching the fake cloud
+func (srv *Server) CfgOptions() openstack.CfgOptions {
+	speeds := map[string]VolumeSpeed.Enum{}
+	for i, t := range srv.Opts.VolumeTypes {
+		switch i {
+		case 0:
+			speeds[t] = VolumeSpeed.COLD
+		case 1:
+			speeds[t] = VolumeSpeed.HDD
+		default:
+			speeds[t] = VolumeSpeed.SSD
+		}
+	}
+	return openstack.CfgOptions{
+		ProviderNetwork: srv.Opts.ProviderNetwork,
+		DNSList:         []string{"8.8.8.8"},
+		VolumeSpeeds:    speeds,
+	}
+}
+
+//Requests returns the list of requests received by the server formatted as "METHOD /path"
+func (srv *Server) Requests() []string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return append([]string(nil), srv.requests...)
+}
+
+//Count returns the number of requests received by the server whose method is method
+//and whose path contains pattern
+func (srv *Server) Count(method string, pattern string) int {
+	n := 0
+	for _, r := range srv.Requests() {
+		if strings.HasPrefix(r, method+" ") && strings.Contains(r, pattern) {
+			n++
+		}
+	}
+	return n
+}
+
+//catalog builds the Keystone v2 service catalog
+func (srv *Server) catalog() []map[string]interface{} {
+	entry := func(name, kind, path string) map[string]interface{} {
+		url := srv.URL + path
+		return map[string]interface{}{
+			"name": name,
+			"type": kind,
+			"endpoints": []map[string]interface{}{
+				{
+					"region":      srv.Opts.Region,
+					"tenantId":    srv.Opts.TenantID,
+					"publicURL":   url,
+					"internalURL": url,
+					"adminURL":    url,
+				},
+			},
+			"endpoints_links": []interface{}{},
+		}
+	}
+	return []map[string]interface{}{
+		entry("nova", "compute", "/compute/v2/"+srv.Opts.TenantID),
+		entry("neutron", "network", "/network"),
+		entry("cinder", "volume", "/volume/v1/"+srv.Opts.TenantID),
+		entry("swift", "object-store", "/object/v1/AUTH_"+srv.Opts.TenantID),
+		entry("keystone", "identity", "/identity/v2.0"),
+	}
+}
+
+//writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+//writeError writes an OpenStack like error
+func writeError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	writeJSON(w, code, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": msg,
+		},
+	})
+}
+
+//readJSON decodes the request body into v
+func readJSON(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+//segments splits path in non empty segments
+func segments(path string) []string {
+	var segs []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+func (srv *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	srv.requests = append(srv.requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+	token := srv.token
+	srv.mu.Unlock()
+
+	segs := segments(r.URL.Path)
+	if len(segs) == 0 {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if segs[0] == "identity" {
+		srv.serveIdentity(w, r, segs[1:])
+		return
+	}
+	if token == "" || r.Header.Get("X-Auth-Token") != token {
+		writeError(w, http.StatusUnauthorized, "The request you have made requires authentication.")
+		return
+	}
+	switch segs[0] {
+	case "compute":
+		//compute/v2/{tenant}/...
+		if len(segs) < 3 {
+			break
+		}
+		srv.serveCompute(w, r, segs[3:])
+		return
+	case "network":
+		//network/v2.0/...
+		if len(segs) < 2 {
+			break
+		}
+		srv.serveNetwork(w, r, segs[2:])
+		return
+	case "volume":
+		//volume/v1/{tenant}/...
+		if len(segs) < 3 {
+			break
+		}
+		srv.serveVolume(w, r, segs[3:])
+		return
+	case "object":
+		//object/v1/AUTH_{tenant}/...
+		if len(segs) < 3 {
+			break
+		}
+		srv.serveObject(w, r, segs[3:])
+		return
+	}
+	writeError(w, http.StatusNotFound, "Not found")
+}
+
+func (srv *Server) serveIdentity(w http.ResponseWriter, r *http.Request, segs []string) {
+	if len(segs) != 2 || segs[0] != "v2.0" || segs[1] != "tokens" || r.Method != "POST" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	var req struct {
+		Auth struct {
+			PasswordCredentials *struct {
+				Username string `json:"username"`
+				Password string `json:"password"`
+			} `json:"passwordCredentials"`
+			TenantID   string `json:"tenantId"`
+			TenantName string `json:"tenantName"`
+		} `json:"auth"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+		return
+	}
+	creds := req.Auth.PasswordCredentials
+	if creds == nil || creds.Username != srv.Opts.Username || creds.Password != srv.Opts.Password {
+		writeError(w, http.StatusUnauthorized, "Invalid user / password")
+		return
+	}
+	if req.Auth.TenantID != "" && req.Auth.TenantID != srv.Opts.TenantID {
+		writeError(w, http.StatusUnauthorized, "Invalid tenant %s", req.Auth.TenantID)
+		return
+	}
+	if req.Auth.TenantName != "" && req.Auth.TenantName != srv.Opts.TenantName {
+		writeError(w, http.StatusUnauthorized, "Invalid tenant %s", req.Auth.TenantName)
+		return
+	}
+	srv.mu.Lock()
+	if srv.token == "" {
+		srv.token = uuid.NewV4().String()
+	}
+	token := srv.token
+	srv.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access": map[string]interface{}{
+			"token": map[string]interface{}{
+				"id":      token,
+				"expires": time.Now().Add(24 * time.Hour).UTC().Format("2006-01-02T15:04:05Z"),
+				"tenant": map[string]interface{}{
+					"id":      srv.Opts.TenantID,
+					"name":    srv.Opts.TenantName,
+					"enabled": true,
+				},
+			},
+			"serviceCatalog": srv.catalog(),
+			"user": map[string]interface{}{
+				"id":       srv.Opts.Username,
+				"name":     srv.Opts.Username,
+				"username": srv.Opts.Username,
+				"roles":    []interface{}{},
+			},
+		},
+	})
+}
@@ -0,0 +1,549 @@
+package fake
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+type volume struct {
+	ID         string
+	Name       string
+	Size       int
+	VolumeType string
+	Status     string
+	ServerID   string
+	Device     string
+	created    time.Time
+}
+
+type object struct {
+	Name         string
+	Content      []byte
+	ContentType  string
+	Metadata     map[string]string
+	LastModified time.Time
+	DeleteAt     time.Time
+}
+
+//expired tells if the object has reached its deletion date
+func (o *object) expired() bool {
+	return !o.DeleteAt.IsZero() && !time.Now().Before(o.DeleteAt)
+}
+
+//refreshVolume computes the status of a volume in creating state
+func (srv *Server) refreshVolume(v *volume) {
+	if v.Status == "creating" && !time.Now().Before(v.created.Add(srv.Opts.BuildDelay)) {
+		v.Status = "available"
+	}
+}
+
+func (srv *Server) volumeView(v *volume) map[string]interface{} {
+	srv.refreshVolume(v)
+	attachments := []map[string]interface{}{}
+	if v.ServerID != "" {
+		attachments = append(attachments, map[string]interface{}{
+			"id":        v.ID,
+			"volume_id": v.ID,
+			"server_id": v.ServerID,
+			"device":    v.Device,
+		})
+	}
+	return map[string]interface{}{
+		"id":                  v.ID,
+		"display_name":        v.Name,
+		"display_description": "",
+		"size":                v.Size,
+		"volume_type":         v.VolumeType,
+		"status":              v.Status,
+		"bootable":            "false",
+		"availability_zone":   "nova",
+		"created_at":          v.created.UTC().Format("2006-01-02T15:04:05.000000"),
+		"attachments":         attachments,
+		"metadata":            map[string]interface{}{},
+		"snapshot_id":         nil,
+		"source_volid":        nil,
+	}
+}
+
+func (srv *Server) serveVolume(w http.ResponseWriter, r *http.Request, segs []string) {
+	if len(segs) == 0 || segs[0] != "volumes" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	segs = segs[1:]
+	switch {
+	case (len(segs) == 0 || segs[0] == "detail") && r.Method == "GET":
+		var ids []string
+		for id := range srv.volumes {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		list := []map[string]interface{}{}
+		for _, id := range ids {
+			list = append(list, srv.volumeView(srv.volumes[id]))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"volumes": list})
+	case len(segs) == 0 && r.Method == "POST":
+		var req struct {
+			Volume struct {
+				Name       string `json:"display_name"`
+				Size       int    `json:"size"`
+				VolumeType string `json:"volume_type"`
+			} `json:"volume"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+			return
+		}
+		if req.Volume.Size <= 0 {
+			writeError(w, http.StatusBadRequest, "Invalid input received: size must be greater than 0")
+			return
+		}
+		vType := req.Volume.VolumeType
+		if vType == "" {
+			vType = srv.Opts.VolumeTypes[0]
+		}
+		found := false
+		for _, t := range srv.Opts.VolumeTypes {
+			if t == vType {
+				found = true
+			}
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "Volume type %s could not be found.", vType)
+			return
+		}
+		v := volume{
+			ID:         uuid.NewV4().String(),
+			Name:       req.Volume.Name,
+			Size:       req.Volume.Size,
+			VolumeType: vType,
+			Status:     "creating",
+			created:    time.Now(),
+		}
+		srv.volumes[v.ID] = &v
+		writeJSON(w, http.StatusOK, map[string]interface{}{"volume": srv.volumeView(&v)})
+	case len(segs) == 1:
+		v, ok := srv.volumes[segs[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Volume %s could not be found.", segs[0])
+			return
+		}
+		switch r.Method {
+		case "GET":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"volume": srv.volumeView(v)})
+		case "PUT":
+			var req struct {
+				Volume struct {
+					Name *string `json:"display_name"`
+				} `json:"volume"`
+			}
+			if err := readJSON(r, &req); err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+				return
+			}
+			if req.Volume.Name != nil {
+				v.Name = *req.Volume.Name
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"volume": srv.volumeView(v)})
+		case "DELETE":
+			if v.ServerID != "" {
+				writeError(w, http.StatusBadRequest, "Invalid volume: Volume status must be available or error, but current status is: in-use")
+				return
+			}
+			delete(srv.volumes, v.ID)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case len(segs) == 2 && segs[1] == "action" && r.Method == "POST":
+		v, ok := srv.volumes[segs[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Volume %s could not be found.", segs[0])
+			return
+		}
+		srv.volumeAction(w, r, v)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+//volumeAction extends or retypes a volume, as Cinder the volume must be available
+func (srv *Server) volumeAction(w http.ResponseWriter, r *http.Request, v *volume) {
+	var req map[string]map[string]interface{}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request: %s", err.Error())
+		return
+	}
+	srv.refreshVolume(v)
+	for action, args := range req {
+		if v.Status != "available" {
+			writeError(w, http.StatusBadRequest, "Invalid volume: Volume status must be available, but current status is: %s", v.Status)
+			return
+		}
+		switch action {
+		case "os-extend":
+			size, _ := args["new_size"].(float64)
+			if int(size) <= v.Size {
+				writeError(w, http.StatusBadRequest, "Invalid input received: New size for extend must be greater than current size. (current: %d, extended: %d)", v.Size, int(size))
+				return
+			}
+			v.Size = int(size)
+		case "os-retype":
+			vType, _ := args["new_type"].(string)
+			found := false
+			for _, t := range srv.Opts.VolumeTypes {
+				if t == vType {
+					found = true
+				}
+			}
+			if !found {
+				writeError(w, http.StatusNotFound, "Volume type %s could not be found.", vType)
+				return
+			}
+			v.VolumeType = vType
+		default:
+			writeError(w, http.StatusBadRequest, "Unsupported action %s", action)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+//metadata extracts metadata headers starting with prefix
+func metadata(h http.Header, prefix string) map[string]string {
+	meta := map[string]string{}
+	for k, v := range h {
+		if strings.HasPrefix(k, prefix) && len(v) > 0 {
+			meta[strings.TrimPrefix(k, prefix)] = v[0]
+		}
+	}
+	return meta
+}
+
+//writeMetadata writes metadata headers
+func writeMetadata(w http.ResponseWriter, prefix string, meta map[string]string) {
+	for k, v := range meta {
+		w.Header().Set(prefix+k, v)
+	}
+}
+
+//writeNames writes a Swift listing in text or JSON format
+func writeNames(w http.ResponseWriter, r *http.Request, names []string) {
+	if r.URL.Query().Get("format") == "json" {
+		list := []map[string]interface{}{}
+		for _, n := range names {
+			list = append(list, map[string]interface{}{"name": n})
+		}
+		writeJSON(w, http.StatusOK, list)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	for _, n := range names {
+		fmt.Fprintln(w, n)
+	}
+}
+
+//page applies marker and limit query parameters to sorted names
+func page(r *http.Request, names []string) []string {
+	sort.Strings(names)
+	marker := r.URL.Query().Get("marker")
+	res := []string{}
+	for _, n := range names {
+		if n > marker {
+			res = append(res, n)
+		}
+	}
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit < len(res) {
+		res = res[:limit]
+	}
+	return res
+}
+
+func (srv *Server) serveObject(w http.ResponseWriter, r *http.Request, segs []string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(segs) == 0 {
+		if r.Method != "GET" {
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		var names []string
+		for n := range srv.containers {
+			names = append(names, n)
+		}
+		writeNames(w, r, page(r, names))
+		return
+	}
+	name := segs[0]
+	if len(segs) == 1 {
+		srv.serveContainer(w, r, name)
+		return
+	}
+	objs, ok := srv.containers[name]
+	if !ok {
+		http.Error(w, "Container not found", http.StatusNotFound)
+		return
+	}
+	oName := strings.Join(segs[1:], "/")
+	if r.Method == "PUT" {
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		o := object{
+			Name:         oName,
+			Content:      content,
+			ContentType:  r.Header.Get("Content-Type"),
+			Metadata:     metadata(r.Header, "X-Object-Meta-"),
+			LastModified: time.Now(),
+		}
+		if err := o.setDeleteAt(r.Header.Get("X-Delete-At")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		objs[oName] = &o
+		w.Header().Set("ETag", fmt.Sprintf("%x", md5.Sum(content)))
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	o, ok := objs[oName]
+	if !ok || o.expired() {
+		delete(objs, oName)
+		http.Error(w, "Object not found", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		o.writeHeaders(w)
+		o.writeContent(w, r.Header.Get("Range"))
+	case "HEAD":
+		o.writeHeaders(w)
+		w.Header().Set("Content-Length", strconv.Itoa(len(o.Content)))
+		w.WriteHeader(http.StatusOK)
+	case "POST":
+		if err := o.setDeleteAt(r.Header.Get("X-Delete-At")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		o.Metadata = metadata(r.Header, "X-Object-Meta-")
+		o.LastModified = time.Now()
+		w.WriteHeader(http.StatusAccepted)
+	case "COPY":
+		dst := strings.TrimPrefix(r.Header.Get("Destination"), "/")
+		dstContainer := name
+		if i := strings.Index(dst, "/"); i >= 0 {
+			dstContainer, dst = dst[:i], dst[i+1:]
+		}
+		dstObjs, ok := srv.containers[dstContainer]
+		if !ok || dst == "" {
+			http.Error(w, "Destination not found", http.StatusNotFound)
+			return
+		}
+		cp := *o
+		cp.Name = dst
+		cp.Content = append([]byte(nil), o.Content...)
+		cp.Metadata = map[string]string{}
+		for k, v := range o.Metadata {
+			cp.Metadata[k] = v
+		}
+		cp.LastModified = time.Now()
+		dstObjs[dst] = &cp
+		w.WriteHeader(http.StatusCreated)
+	case "DELETE":
+		delete(objs, oName)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (srv *Server) serveContainer(w http.ResponseWriter, r *http.Request, name string) {
+	objs, ok := srv.containers[name]
+	if r.Method == "PUT" {
+		if ok {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		srv.containers[name] = map[string]*object{}
+		srv.containerMeta[name] = metadata(r.Header, "X-Container-Meta-")
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	if !ok {
+		http.Error(w, "Container not found", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		q := r.URL.Query()
+		dir := ""
+		if q.Get("path") != "" {
+			dir = strings.TrimSuffix(q.Get("path"), "/") + "/"
+		}
+		var names []string
+		for n, o := range objs {
+			if o.expired() {
+				delete(objs, n)
+				continue
+			}
+			if !strings.HasPrefix(n, dir+q.Get("prefix")) {
+				continue
+			}
+			if dir != "" && strings.Contains(strings.TrimPrefix(n, dir), "/") {
+				continue
+			}
+			names = append(names, n)
+		}
+		writeMetadata(w, "X-Container-Meta-", srv.containerMeta[name])
+		writeNames(w, r, page(r, names))
+	case "HEAD":
+		writeMetadata(w, "X-Container-Meta-", srv.containerMeta[name])
+		w.Header().Set("X-Container-Object-Count", strconv.Itoa(len(objs)))
+		w.WriteHeader(http.StatusNoContent)
+	case "POST":
+		meta := srv.containerMeta[name]
+		for k, v := range metadata(r.Header, "X-Container-Meta-") {
+			meta[k] = v
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		for n, o := range objs {
+			if o.expired() {
+				delete(objs, n)
+			}
+		}
+		if len(objs) > 0 {
+			http.Error(w, "There was a conflict when trying to complete your request.", http.StatusConflict)
+			return
+		}
+		delete(srv.containers, name)
+		delete(srv.containerMeta, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+//setDeleteAt sets the deletion date of the object from a X-Delete-At header value
+func (o *object) setDeleteAt(value string) error {
+	if value == "" {
+		o.DeleteAt = time.Time{}
+		return nil
+	}
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid X-Delete-At %s", value)
+	}
+	o.DeleteAt = time.Unix(sec, 0)
+	return nil
+}
+
+func (o *object) writeHeaders(w http.ResponseWriter) {
+	writeMetadata(w, "X-Object-Meta-", o.Metadata)
+	if o.ContentType != "" {
+		w.Header().Set("Content-Type", o.ContentType)
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	w.Header().Set("ETag", fmt.Sprintf("%x", md5.Sum(o.Content)))
+	w.Header().Set("Last-Modified", o.LastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	if !o.DeleteAt.IsZero() {
+		w.Header().Set("X-Delete-At", strconv.FormatInt(o.DeleteAt.Unix(), 10))
+	}
+}
+
+//parseRanges parses a Range header, nil is returned if the header is absent or invalid
+func parseRanges(header string, size int) [][2]int {
+	if !strings.HasPrefix(header, "bytes=") {
+		return nil
+	}
+	var res [][2]int
+	for _, spec := range strings.Split(strings.TrimPrefix(header, "bytes="), ",") {
+		parts := strings.SplitN(strings.TrimSpace(spec), "-", 2)
+		if len(parts) != 2 {
+			return nil
+		}
+		var from, to int
+		var err error
+		switch {
+		case parts[0] == "" && parts[1] == "":
+			return nil
+		case parts[0] == "":
+			n, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return nil
+			}
+			if n > size {
+				n = size
+			}
+			from, to = size-n, size-1
+		default:
+			from, err = strconv.Atoi(parts[0])
+			if err != nil {
+				return nil
+			}
+			to = size - 1
+			if parts[1] != "" {
+				to, err = strconv.Atoi(parts[1])
+				if err != nil {
+					return nil
+				}
+			}
+			if to >= size {
+				to = size - 1
+			}
+		}
+		if from > to || from >= size {
+			return nil
+		}
+		res = append(res, [2]int{from, to})
+	}
+	return res
+}
+
+func (o *object) writeContent(w http.ResponseWriter, rangeHeader string) {
+	size := len(o.Content)
+	ranges := parseRanges(rangeHeader, size)
+	switch len(ranges) {
+	case 0:
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		w.WriteHeader(http.StatusOK)
+		w.Write(o.Content)
+	case 1:
+		rg := ranges[0]
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rg[0], rg[1], size))
+		w.Header().Set("Content-Length", strconv.Itoa(rg[1]-rg[0]+1))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(o.Content[rg[0] : rg[1]+1])
+	default:
+		boundary := uuid.NewV4().String()
+		var buff bytes.Buffer
+		for _, rg := range ranges {
+			fmt.Fprintf(&buff, "--%s\r\n", boundary)
+			fmt.Fprintf(&buff, "Content-Type: %s\r\n", w.Header().Get("Content-Type"))
+			fmt.Fprintf(&buff, "Content-Range: bytes %d-%d/%d\r\n\r\n", rg[0], rg[1], size)
+			buff.Write(o.Content[rg[0] : rg[1]+1])
+			buff.WriteString("\r\n")
+		}
+		fmt.Fprintf(&buff, "--%s--\r\n", boundary)
+		w.Header().Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+		w.Header().Set("Content-Length", strconv.Itoa(buff.Len()))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(buff.Bytes())
+	}
+}
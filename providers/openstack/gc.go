@@ -0,0 +1,278 @@
+package openstack
+
+import (
+	"strings"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/floatingip"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/keypairs"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/secgroups"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/servers"
+	"github.com/rackspace/gophercloud/openstack/networking/v2/networks"
+	"github.com/rackspace/gophercloud/pagination"
+)
+
+//liveServers returns the IDs of the existing servers
+func (client *Client) liveServers() (map[string]bool, error) {
+	ids := map[string]bool{}
+	err := servers.List(client.Compute, servers.ListOpts{}).EachPage(func(page pagination.Page) (bool, error) {
+		list, err := servers.ExtractServers(page)
+		if err != nil {
+			return false, err
+		}
+		for _, srv := range list {
+			ids[srv.ID] = true
+		}
+		return true, nil
+	})
+	return ids, err
+}
+
+//creation creation record of a resource created by gpac
+type creation struct {
+	//Kind kind of the resource, a kind of orphan
+	Kind    string    `json:"kind"`
+	Created time.Time `json:"created"`
+}
+
+//markCreated records the creation of the resource id of kind, only the recorded resources can be orphans
+func (client *Client) markCreated(kind string, id string) error {
+	b, err := providers.EncodeRecord(providers.RecordCreation, creation{Kind: kind, Created: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	_, err = client.metadata.Put(CreatedContainer, id, b)
+	return err
+}
+
+//forgetCreated removes the creation record of the resource id once it is deleted, records left by resources deleted
+//by other means are collected as orphans
+func (client *Client) forgetCreated(id string) error {
+	return client.metadata.Delete(CreatedContainer, id)
+}
+
+//creations returns the IDs of the recorded resources and their creation records
+func (client *Client) creations() ([]string, map[string]creation, error) {
+	keys, err := client.metadata.List(CreatedContainer, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	res := map[string]creation{}
+	for _, k := range keys {
+		b, _, err := client.metadata.Get(CreatedContainer, k)
+		if api.KindOf(err) == api.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		c := creation{}
+		err = providers.DecodeRecord(providers.RecordCreation, b, &c)
+		if err != nil {
+			return nil, nil, err
+		}
+		res[k] = c
+	}
+	return keys, res, nil
+}
+
+//securityGroupCreated returns the creation time written in the description of a security group of gpac, it is zero
+//(unknown) for the groups created before it was written
+func securityGroupCreated(sg secgroups.SecurityGroup) time.Time {
+	created, err := time.Parse(time.RFC3339, strings.TrimPrefix(sg.Description, securityGroupDescription))
+	if err != nil {
+		return time.Time{}
+	}
+	return created
+}
+
+//Orphans lists the resources created by gpac and leaked by failed operations: floating IPs not associated (if
+//floating IPs are used), temporary key pairs, duplicates of the security group of gpac, networks (and their
+//subnets) without gateway record and records of the VMs, networks and resources that do not exist anymore
+//Neutron resources cannot be tagged with the version of gophercloud used, only the resources recorded in
+//CreatedContainer when they were created are considered. Volumes have no bookkeeping data, they are not collected
+func (client *Client) Orphans() ([]providers.Orphan, error) {
+	orphans := []providers.Orphan{}
+	//records are listed first, the records of the resources created meanwhile are not seen
+	createdKeys, created, err := client.creations()
+	if err != nil {
+		return nil, providerError(err, "Error listing orphans")
+	}
+	gateways, err := client.metadata.List(NetworkGWContainer, "")
+	if err != nil {
+		return nil, providerError(err, "Error listing orphans")
+	}
+	vmKeys, err := client.metadata.List(VMContainer, "")
+	if err != nil {
+		return nil, providerError(err, "Error listing orphans")
+	}
+	live, err := client.liveServers()
+	if err != nil {
+		return nil, providerError(err, "Error listing orphans")
+	}
+
+	//resources that exist, or that cannot be listed, keep their creation record
+	existing := map[string]bool{}
+	for id, c := range created {
+		if c.Kind == providers.OrphanAddress && !client.Cfg.UseFloatingIP {
+			existing[id] = true
+		}
+	}
+	if client.Cfg.UseFloatingIP {
+		err := floatingip.List(client.Compute).EachPage(func(page pagination.Page) (bool, error) {
+			list, err := floatingip.ExtractFloatingIPs(page)
+			if err != nil {
+				return false, err
+			}
+			for _, fip := range list {
+				existing[fip.ID] = true
+				c, ok := created[fip.ID]
+				if ok && fip.InstanceID == "" {
+					orphans = append(orphans, providers.Orphan{
+						Kind:    providers.OrphanAddress,
+						ID:      fip.ID,
+						Name:    fip.IP,
+						Reason:  "floating IP not associated",
+						Created: c.Created,
+					})
+				}
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, providerError(err, "Error listing orphans")
+		}
+	}
+
+	err = keypairs.List(client.Compute).EachPage(func(page pagination.Page) (bool, error) {
+		list, err := keypairs.ExtractKeyPairs(page)
+		if err != nil {
+			return false, err
+		}
+		for _, kp := range list {
+			existing[kp.Name] = true
+			c, ok := created[kp.Name]
+			if ok && providers.TemporaryKeyPair(kp.Name) {
+				orphans = append(orphans, providers.Orphan{
+					Kind:    providers.OrphanKeyPair,
+					ID:      kp.Name,
+					Reason:  "temporary key pair of a VM creation",
+					Created: c.Created,
+				})
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, providerError(err, "Error listing orphans")
+	}
+
+	//VMs are created with the security group by name, duplicates are left by clients initialized concurrently
+	//The security group is created before the metadata store is set, its name is specific to gpac and its
+	//description gives its creation time
+	err = secgroups.List(client.Compute).EachPage(func(page pagination.Page) (bool, error) {
+		list, err := secgroups.ExtractSecurityGroups(page)
+		if err != nil {
+			return false, err
+		}
+		for _, sg := range list {
+			if sg.Name == defaultSecurityGroup && sg.ID != client.SecurityGroup.ID {
+				orphans = append(orphans, providers.Orphan{
+					Kind:    providers.OrphanSecurityGroup,
+					ID:      sg.ID,
+					Name:    sg.Name,
+					Reason:  "duplicate of the security group of gpac",
+					Created: securityGroupCreated(sg),
+				})
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, providerError(err, "Error listing orphans")
+	}
+
+	recorded := map[string]bool{}
+	for _, id := range gateways {
+		recorded[id] = true
+	}
+	var orphanNets []networks.Network
+	err = networks.List(client.Network, networks.ListOpts{}).EachPage(func(page pagination.Page) (bool, error) {
+		list, err := networks.ExtractNetworks(page)
+		if err != nil {
+			return false, err
+		}
+		for _, n := range list {
+			existing[n.ID] = true
+			if _, ok := created[n.ID]; ok && !recorded[n.ID] {
+				orphanNets = append(orphanNets, n)
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, providerError(err, "Error listing orphans")
+	}
+	for _, n := range orphanNets {
+		sns, err := client.ListSubnets(n.ID)
+		if err != nil {
+			return nil, providerError(err, "Error listing orphans")
+		}
+		for _, sn := range sns {
+			orphans = append(orphans, providers.Orphan{
+				Kind:    providers.OrphanSubnet,
+				ID:      sn.ID,
+				Name:    sn.Name,
+				Reason:  "subnet of network " + n.ID + " without gateway record",
+				Created: created[n.ID].Created,
+			})
+		}
+	}
+	for _, n := range orphanNets {
+		orphans = append(orphans, providers.Orphan{
+			Kind:    providers.OrphanNetwork,
+			ID:      n.ID,
+			Name:    n.Name,
+			Reason:  "network without gateway record",
+			Created: created[n.ID].Created,
+		})
+	}
+
+	orphans = append(orphans, providers.MetadataOrphans(client.metadata, VMContainer, vmKeys, live, "VM record of a deleted server")...)
+	orphans = append(orphans, providers.MetadataOrphans(client.metadata, NetworkGWContainer, gateways, existing, "gateway record of a deleted network")...)
+	orphans = append(orphans, providers.MetadataOrphans(client.metadata, CreatedContainer, createdKeys, existing, "creation record of a deleted resource")...)
+	return orphans, nil
+}
+
+//DeleteOrphan deletes an orphan returned by Orphans, the router of an orphaned subnet is deleted with it
+func (client *Client) DeleteOrphan(o providers.Orphan) error {
+	var err error
+	switch o.Kind {
+	case providers.OrphanAddress:
+		err = floatingip.Delete(client.Compute, o.ID).ExtractErr()
+		if err == nil {
+			client.forgetCreated(o.ID)
+		}
+	case providers.OrphanKeyPair:
+		return client.DeleteKeyPair(o.ID)
+	case providers.OrphanSecurityGroup:
+		err = secgroups.Delete(client.Compute, o.ID).ExtractErr()
+	case providers.OrphanSubnet:
+		return client.DeleteSubnet(o.ID)
+	case providers.OrphanNetwork:
+		err = networks.Delete(client.Network, o.ID).ExtractErr()
+		if err == nil {
+			client.forgetCreated(o.ID)
+		}
+	case providers.OrphanMetadata:
+		return providers.DeleteMetadataOrphan(client.metadata, o)
+	default:
+		return providers.UnknownOrphanError(o)
+	}
+	if err != nil {
+		return providerError(err, "Error deleting orphan %s", o.ID)
+	}
+	return nil
+}
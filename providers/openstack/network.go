@@ -1,11 +1,10 @@
 package openstack
 
 import (
-	"bytes"
 	"fmt"
-	"strings"
 	"time"
 
+	"github.com/SebastienDorgan/gpac/providers"
 	"github.com/SebastienDorgan/gpac/providers/api"
 	"github.com/SebastienDorgan/gpac/providers/api/IPVersion"
 	"github.com/rackspace/gophercloud/openstack/networking/v2/extensions/layer3/routers"
@@ -42,25 +41,26 @@ type Subnet struct {
 }
 
 func (client *Client) saveGateway(netID string, vmID string) error {
-	err := client.PutObject("__network_gws__", api.Object{
-		Name:    netID,
-		Content: strings.NewReader(vmID),
-	})
+	b, err := providers.EncodeRecord(providers.RecordGateway, vmID)
+	if err != nil {
+		return err
+	}
+	_, err = client.metadata.Put(NetworkGWContainer, netID, b)
 	return err
 }
 
 func (client *Client) getGateway(netID string) (string, error) {
-	o, err := client.GetObject("__network_gws__", netID, nil)
+	b, _, err := client.metadata.Get(NetworkGWContainer, netID)
 	if err != nil {
 		return "", err
 	}
-	var buffer bytes.Buffer
-	buffer.ReadFrom(o.Content)
-	return buffer.String(), nil
+	vmID := ""
+	err = providers.DecodeRecord(providers.RecordGateway, b, &vmID)
+	return vmID, err
 }
 
 func (client *Client) removeGateway(netID string) error {
-	return client.DeleteObject("__network_gws__", netID)
+	return client.metadata.Delete(NetworkGWContainer, netID)
 }
 
 //CreateNetwork creates a network named name
@@ -74,27 +74,32 @@ func (client *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error
 	// Execute the operation and get back a networks.Network struct
 	network, err := networks.Create(client.Network, opts).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error creating network %s: %s", req.Name, errorString(err))
+		return nil, providerError(err, "Error creating network %s", req.Name)
+	}
+	err = client.markCreated(providers.OrphanNetwork, network.ID)
+	if err != nil {
+		networks.Delete(client.unbound().Network, network.ID)
+		return nil, providerError(err, "Error creating network %s", req.Name)
 	}
 
 	sn, err := client.CreateSubnet(req.Name, network.ID, req.CIDR, req.IPVersion)
 	if err != nil {
-		client.DeleteNetwork(network.ID)
-		return nil, fmt.Errorf("Error creating network %s: %s", req.Name, errorString(err))
+		client.unbound().DeleteNetwork(network.ID)
+		return nil, providerError(err, "Error creating network %s", req.Name)
 	}
 	req.GWRequest.PublicIP = true
 	req.GWRequest.IsGateway = true
 	req.GWRequest.NetworkIDs = append(req.GWRequest.NetworkIDs, network.ID)
 	vm, err := client.CreateVM(req.GWRequest)
 	if err != nil {
-		client.DeleteNetwork(network.ID)
-		return nil, fmt.Errorf("Error creating network %s: %s", req.Name, errorString(err))
+		client.unbound().DeleteNetwork(network.ID)
+		return nil, providerError(err, "Error creating network %s", req.Name)
 	}
 	err = client.saveGateway(network.ID, vm.ID)
 	if err != nil {
-		client.DeleteVM(vm.ID)
-		client.DeleteNetwork(network.ID)
-		return nil, fmt.Errorf("Error creating network %s: %s", req.Name, errorString(err))
+		client.unbound().DeleteVM(vm.ID)
+		client.unbound().DeleteNetwork(network.ID)
+		return nil, providerError(err, "Error creating network %s", req.Name)
 	}
 	return &api.Network{
 		ID:        network.ID,
@@ -110,11 +115,11 @@ func (client *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error
 func (client *Client) GetNetwork(id string) (*api.Network, error) {
 	network, err := networks.Get(client.Network, id).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting network: %s", errorString(err))
+		return nil, providerError(err, "Error getting network")
 	}
 	sns, err := client.ListSubnets(id)
 	if err != nil {
-		return nil, fmt.Errorf("Error getting network: %s", errorString(err))
+		return nil, providerError(err, "Error getting network")
 	}
 	if len(sns) != 1 {
 		return nil, fmt.Errorf("Bad configuration, each network should have exactly one subnet")
@@ -154,7 +159,7 @@ func (client *Client) ListNetworks() ([]api.Network, error) {
 
 			sns, err := client.ListSubnets(n.ID)
 			if err != nil {
-				return false, fmt.Errorf("Error getting network: %s", errorString(err))
+				return false, providerError(err, "Error getting network")
 			}
 			if len(sns) != 1 {
 				continue
@@ -165,7 +170,7 @@ func (client *Client) ListNetworks() ([]api.Network, error) {
 			sn := sns[0]
 			gwID, err := client.getGateway(n.ID)
 			if err != nil {
-				return false, fmt.Errorf("Error getting network: %s", errorString(err))
+				return false, providerError(err, "Error getting network")
 			}
 			netList = append(netList, api.Network{
 				ID:        n.ID,
@@ -178,7 +183,7 @@ func (client *Client) ListNetworks() ([]api.Network, error) {
 		return true, nil
 	})
 	if len(netList) == 0 && err != nil {
-		return nil, fmt.Errorf("Error listing networks: %s", errorString(err))
+		return nil, providerError(err, "Error listing networks")
 	}
 	return netList, nil
 }
@@ -186,28 +191,36 @@ func (client *Client) ListNetworks() ([]api.Network, error) {
 //DeleteNetwork deletes the network identified by id
 func (client *Client) DeleteNetwork(id string) error {
 	srv, err := client.readGateway(id)
-	if err != nil {
-		return fmt.Errorf("Error deleting network: %s", errorString(err))
-	}
-	client.DeleteVM(srv.ID)
-	for err = nil; err != nil; _, err = client.GetVM(srv.ID) {
-		time.Sleep(100 * time.Millisecond)
+	//the gateway is not recorded if the creation of the network failed before it was created
+	if err != nil && api.KindOf(err) != api.ErrNotFound {
+		return providerError(err, "Error deleting network")
+	}
+	if err == nil {
+		client.DeleteVM(srv.ID)
+		service := providers.Service{
+			ClientAPI: client,
+		}
+		err = service.WaitVMDeleted(srv.ID, 120*time.Second)
+		if err != nil {
+			return providerError(err, "Error deleting network")
+		}
 	}
 	client.removeGateway(id)
 	sns, err := client.ListSubnets(id)
 	if err != nil {
-		return fmt.Errorf("Error deleting network: %s", errorString(err))
+		return providerError(err, "Error deleting network")
 	}
 	for _, sn := range sns {
 		err := client.DeleteSubnet(sn.ID)
 		if err != nil {
-			return fmt.Errorf("Error deleting network: %s", errorString(err))
+			return providerError(err, "Error deleting network")
 		}
 	}
 	err = networks.Delete(client.Network, id).ExtractErr()
 	if err != nil {
-		return fmt.Errorf("Error deleting network: %s", errorString(err))
+		return providerError(err, "Error deleting network")
 	}
+	client.forgetCreated(id)
 	return nil
 }
 
@@ -249,24 +262,23 @@ func (client *Client) CreateSubnet(name string, networkID string, cidr string, i
 
 	// Execute the operation and get back a subnets.Subnet struct
 	subnet, err := subnets.Create(client.Network, opts).Extract()
+	if err != nil {
+		return nil, providerError(err, "Error creating subnet")
+	}
 	if client.Cfg.UseLayer3Networking {
-		if err != nil {
-			return nil, fmt.Errorf("Error creating subnet: %s", errorString(err))
-		}
-
 		router, err := client.CreateRouter(RouterRequest{
 			Name:      subnet.ID,
 			NetworkID: client.ProviderNetworkID,
 		})
 		if err != nil {
-			client.DeleteSubnet(subnet.ID)
-			return nil, fmt.Errorf("Error creating subnet: %s", errorString(err))
+			client.unbound().DeleteSubnet(subnet.ID)
+			return nil, providerError(err, "Error creating subnet")
 		}
 		err = client.AddSubnetToRouter(router.ID, subnet.ID)
 		if err != nil {
-			client.DeleteSubnet(subnet.ID)
-			client.DeleteRouter(router.ID)
-			return nil, fmt.Errorf("Error creating subnet: %s", errorString(err))
+			client.unbound().DeleteSubnet(subnet.ID)
+			client.unbound().DeleteRouter(router.ID)
+			return nil, providerError(err, "Error creating subnet")
 		}
 	}
 
@@ -284,7 +296,7 @@ func (client *Client) GetSubnet(id string) (*Subnet, error) {
 	// Execute the operation and get back a subnets.Subnet struct
 	subnet, err := subnets.Get(client.Network, id).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting subnet: %s", errorString(err))
+		return nil, providerError(err, "Error getting subnet")
 	}
 	return &Subnet{
 		ID:        subnet.ID,
@@ -304,7 +316,7 @@ func (client *Client) ListSubnets(netID string) ([]Subnet, error) {
 	pager.EachPage(func(page pagination.Page) (bool, error) {
 		list, err := subnets.ExtractSubnets(page)
 		if err != nil {
-			return false, fmt.Errorf("Error listing subnets: %s", errorString(err))
+			return false, providerError(err, "Error listing subnets")
 		}
 
 		for _, subnet := range list {
@@ -333,15 +345,15 @@ func (client *Client) DeleteSubnet(id string) error {
 	}
 	if router != nil {
 		if err := client.RemoveSubnetFromRouter(router.ID, id); err != nil {
-			return fmt.Errorf("Error deleting subnets: %s", errorString(err))
+			return providerError(err, "Error deleting subnets")
 		}
 		if err := client.DeleteRouter(router.ID); err != nil {
-			return fmt.Errorf("Error deleting subnets: %s", errorString(err))
+			return providerError(err, "Error deleting subnets")
 		}
 	}
 
 	if err := subnets.Delete(client.Network, id).ExtractErr(); err != nil {
-		return fmt.Errorf("Error deleting subnets: %s", errorString(err))
+		return providerError(err, "Error deleting subnets")
 	}
 
 	return nil
@@ -360,7 +372,7 @@ func (client *Client) CreateRouter(req RouterRequest) (*Router, error) {
 	}
 	router, err := routers.Create(client.Network, opts).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error creating Router: %s", errorString(err))
+		return nil, providerError(err, "Error creating Router")
 	}
 	return &Router{
 		ID:        router.ID,
@@ -375,7 +387,7 @@ func (client *Client) GetRouter(id string) (*Router, error) {
 
 	r, err := routers.Get(client.Network, id).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting Router: %s", errorString(err))
+		return nil, providerError(err, "Error getting Router")
 	}
 	return &Router{
 		ID:        r.ID,
@@ -405,7 +417,7 @@ func (client *Client) ListRouter() ([]Router, error) {
 		return true, nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Error listing volume types: %s", errorString(err))
+		return nil, providerError(err, "Error listing volume types")
 	}
 	return ns, nil
 }
@@ -414,7 +426,7 @@ func (client *Client) ListRouter() ([]Router, error) {
 func (client *Client) DeleteRouter(id string) error {
 	err := routers.Delete(client.Network, id).ExtractErr()
 	if err != nil {
-		return fmt.Errorf("Error deleting Router: %s", errorString(err))
+		return providerError(err, "Error deleting Router")
 	}
 	return nil
 }
@@ -425,7 +437,7 @@ func (client *Client) AddSubnetToRouter(routerID string, subnetID string) error
 		SubnetID: subnetID,
 	}).Extract()
 	if err != nil {
-		return fmt.Errorf("Error addinter subnet: %s", errorString(err))
+		return providerError(err, "Error addinter subnet")
 	}
 	return nil
 }
@@ -436,7 +448,7 @@ func (client *Client) RemoveSubnetFromRouter(routerID string, subnetID string) e
 		SubnetID: subnetID,
 	}).Extract()
 	if err != nil {
-		return fmt.Errorf("Error addinter subnet: %s", errorString(err))
+		return providerError(err, "Error addinter subnet")
 	}
 	return nil
 }
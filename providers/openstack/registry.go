@@ -0,0 +1,50 @@
+package openstack
+
+import (
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/providers/api/VolumeSpeed"
+)
+
+func init() {
+	providers.Register(providers.Provider{
+		Name:        "openstack",
+		Description: "generic OpenStack cloud",
+		Fields: []providers.ConfigField{
+			{Name: "IdentityEndpoint", Description: "URL of the Keystone identity service", Required: true, Sample: "https://keystone.example.com:5000/v2.0"},
+			{Name: "Username", Description: "user name", Sample: "admin"},
+			{Name: "UserID", Description: "user ID (Identity V3)"},
+			{Name: "Password", Description: "password, exactly one of Password or APIKey is required", Secret: true, Sample: "password"},
+			{Name: "APIKey", Description: "API key, exactly one of Password or APIKey is required", Secret: true},
+			{Name: "DomainID", Description: "domain ID (Identity V3)"},
+			{Name: "DomainName", Description: "domain name (Identity V3)"},
+			{Name: "TenantID", Description: "tenant ID"},
+			{Name: "TenantName", Description: "tenant name", Sample: "admin"},
+			{Name: "AllowReauth", Description: "allow re-authentication when the token expires"},
+			{Name: "TokenID", Description: "authentication token ID", Secret: true},
+			{Name: "Region", Description: "region (data center) where the infrastructure is created", Required: true, Sample: "RegionOne"},
+			{Name: "FloatingIPPool", Description: "name of the floating IP pool, necessary only if UseFloatingIP is true"},
+			{Name: "ProviderNetwork", Description: "name of the provider (external) network", Required: true, Sample: "public"},
+			{Name: "DNSList", Description: "list of DNS servers", Sample: []string{"8.8.8.8", "8.8.4.4"}},
+			{Name: "UseFloatingIP", Description: "use floating IPs (requires UseLayer3Networking)"},
+			{Name: "UseLayer3Networking", Description: "layer 3 networking features (router) can be used"},
+			{Name: "AutoVMNetworkInterfaces", Description: "network interfaces are configured automatically by the provider"},
+			{Name: "VolumeSpeeds", Description: "map of volume types to volume speeds (0: SSD, 1: HDD, 2: COLD)", Sample: map[string]VolumeSpeed.Enum{"standard": VolumeSpeed.HDD}},
+			{Name: "TemplatePrices", Description: "prices of VM templates indexed by flavor name (optional)"},
+		},
+		Factory: func(cfg map[string]interface{}) (api.ClientAPI, error) {
+			//cfg holds the fields of both AuthOptions and CfgOptions
+			opts := AuthOptions{}
+			err := providers.DecodeConfig(cfg, &opts)
+			if err != nil {
+				return nil, err
+			}
+			cfgOpts := CfgOptions{}
+			err = providers.DecodeConfig(cfg, &cfgOpts)
+			if err != nil {
+				return nil, err
+			}
+			return AuthenticatedClient(opts, cfgOpts)
+		},
+	})
+}
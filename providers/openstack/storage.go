@@ -3,12 +3,14 @@ package openstack
 import (
 	"bytes"
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/SebastienDorgan/gpac/providers/api"
 	"github.com/SebastienDorgan/gpac/providers/api/VolumeSpeed"
 	"github.com/SebastienDorgan/gpac/providers/api/VolumeState"
+	gc "github.com/rackspace/gophercloud"
 	"github.com/rackspace/gophercloud/openstack/objectstorage/v1/objects"
 
 	"github.com/rackspace/gophercloud/openstack/objectstorage/v1/containers"
@@ -75,7 +77,7 @@ func (client *Client) CreateVolume(request api.VolumeRequest) (*api.Volume, erro
 		VolumeType: client.getVolumeType(request.Speed),
 	}).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error creating volume : %s", errorString(err))
+		return nil, providerError(err, "Error creating volume")
 	}
 	v := api.Volume{
 		ID:    vol.ID,
@@ -91,7 +93,7 @@ func (client *Client) CreateVolume(request api.VolumeRequest) (*api.Volume, erro
 func (client *Client) GetVolume(id string) (*api.Volume, error) {
 	vol, err := volumes.Get(client.Volume, id).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting volume: %s", errorString(err))
+		return nil, providerError(err, "Error getting volume")
 	}
 	av := api.Volume{
 		ID:    vol.ID,
@@ -124,7 +126,7 @@ func (client *Client) ListVolumes() ([]api.Volume, error) {
 		return true, nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Error listing volume types: %s", errorString(err))
+		return nil, providerError(err, "Error listing volume types")
 	}
 	return vs, nil
 }
@@ -133,11 +135,62 @@ func (client *Client) ListVolumes() ([]api.Volume, error) {
 func (client *Client) DeleteVolume(id string) error {
 	err := volumes.Delete(client.Volume, id).ExtractErr()
 	if err != nil {
-		return fmt.Errorf("Error deleting volume: %s", errorString(err))
+		return providerError(err, "Error deleting volume")
 	}
 	return nil
 }
 
+//volumeAction runs the Cinder action of the volume identified by id, gophercloud does not implement the volume actions
+func (client *Client) volumeAction(id string, action string, args map[string]interface{}) error {
+	_, err := client.Volume.Request("POST", client.Volume.ServiceURL("volumes", id, "action"), gc.RequestOpts{
+		JSONBody: map[string]interface{}{action: args},
+		OkCodes:  []int{202},
+	})
+	return err
+}
+
+//UpdateVolume renames, resizes or changes the speed of the volume identified by id
+//Cinder extends and retypes the volumes which are not attached, the volume is extended then retyped then renamed
+func (client *Client) UpdateVolume(id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	if request.Size != nil || request.Speed != nil {
+		vol, err := volumes.Get(client.Volume, id).Extract()
+		if err != nil {
+			return nil, providerError(err, "Error updating volume")
+		}
+		if request.Size != nil && *request.Size < vol.Size {
+			return nil, api.NewError(api.ErrInvalidRequest, nil, "Error updating volume: volume %s cannot shrink from %d to %d GB", id, vol.Size, *request.Size)
+		}
+		if request.Size != nil && *request.Size > vol.Size {
+			err = client.volumeAction(id, "os-extend", map[string]interface{}{"new_size": *request.Size})
+			if err != nil {
+				return nil, providerError(err, "Error updating volume")
+			}
+		}
+		if request.Speed != nil {
+			vType := client.getVolumeType(*request.Speed)
+			if vType == "" {
+				return nil, api.NewError(api.ErrInvalidRequest, nil, "Error updating volume: no volume type of speed %s", *request.Speed)
+			}
+			if vType != vol.VolumeType {
+				//the data of the volume are migrated if the new type is not available on its backend
+				err = client.volumeAction(id, "os-retype", map[string]interface{}{"new_type": vType, "migration_policy": "on-demand"})
+				if err != nil {
+					return nil, providerError(err, "Error updating volume")
+				}
+			}
+		}
+	}
+	if request.Name != nil {
+		_, err := volumes.Update(client.Volume, id, volumes.UpdateOpts{
+			Name: *request.Name,
+		}).Extract()
+		if err != nil {
+			return nil, providerError(err, "Error updating volume")
+		}
+	}
+	return client.GetVolume(id)
+}
+
 //CreateVolumeAttachment attaches a volume to a VM
 //- name the name of the volume attachment
 //- volume the volume to attach
@@ -147,7 +200,7 @@ func (client *Client) CreateVolumeAttachment(request api.VolumeAttachmentRequest
 		VolumeID: request.VolumeID,
 	}).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error creating volume attachement between server %s and volume %s: %s", request.ServerID, request.VolumeID, errorString(err))
+		return nil, providerError(err, "Error creating volume attachement between server %s and volume %s", request.ServerID, request.VolumeID)
 	}
 
 	return &api.VolumeAttachment{
@@ -162,7 +215,7 @@ func (client *Client) CreateVolumeAttachment(request api.VolumeAttachmentRequest
 func (client *Client) GetVolumeAttachment(serverID, id string) (*api.VolumeAttachment, error) {
 	va, err := volumeattach.Get(client.Compute, serverID, id).Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting volume attachement %s: %s", id, errorString(err))
+		return nil, providerError(err, "Error getting volume attachement %s", id)
 	}
 	return &api.VolumeAttachment{
 		ID:       va.ID,
@@ -192,7 +245,7 @@ func (client *Client) ListVolumeAttachments(serverID string) ([]api.VolumeAttach
 		return true, nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Error listing volume types: %s", errorString(err))
+		return nil, providerError(err, "Error listing volume types")
 	}
 	return vs, nil
 }
@@ -201,7 +254,7 @@ func (client *Client) ListVolumeAttachments(serverID string) ([]api.VolumeAttach
 func (client *Client) DeleteVolumeAttachment(serverID, id string) error {
 	err := volumeattach.Delete(client.Compute, serverID, id).ExtractErr()
 	if err != nil {
-		return fmt.Errorf("Error deleting volume attachement %s: %s", id, errorString(err))
+		return providerError(err, "Error deleting volume attachement %s", id)
 	}
 	return nil
 }
@@ -213,7 +266,7 @@ func (client *Client) CreateContainer(name string) error {
 	}
 	_, err := containers.Create(client.Container, name, opts).Extract()
 	if err != nil {
-		return fmt.Errorf("Error creating container %s: %s", name, errorString(err))
+		return providerError(err, "Error creating container %s", name)
 	}
 	return nil
 }
@@ -222,18 +275,32 @@ func (client *Client) CreateContainer(name string) error {
 func (client *Client) DeleteContainer(name string) error {
 	_, err := containers.Delete(client.Container, name).Extract()
 	if err != nil {
-		return fmt.Errorf("Error deleting container %s: %s", name, errorString(err))
+		return providerError(err, "Error deleting container %s", name)
 	}
 	return err
 }
 
+//S3QLBackend returns the s3ql storage URL of the container and the credentials to access it
+func (client *Client) S3QLBackend(container string) (string, string, string, error) {
+	u, err := url.Parse(client.Opts.IdentityEndpoint)
+	if err != nil {
+		return "", "", "", fmt.Errorf("Error parsing identity endpoint: %s", err.Error())
+	}
+	tenant := client.Opts.TenantName
+	if tenant == "" {
+		tenant = client.Opts.TenantID
+	}
+	storageURL := fmt.Sprintf("swiftks://%s/%s:%s", u.Host, client.Opts.Region, container)
+	return storageURL, tenant + ":" + client.Opts.Username, client.Opts.Password, nil
+}
+
 //UpdateContainer updates an object container
 func (client *Client) UpdateContainer(name string, meta map[string]string) error {
 	_, err := containers.Update(client.Container, name, containers.UpdateOpts{
 		Metadata: meta,
 	}).Extract()
 	if err != nil {
-		return fmt.Errorf("Error updating container %s: %s", name, errorString(err))
+		return providerError(err, "Error updating container %s", name)
 	}
 	return nil
 }
@@ -242,7 +309,7 @@ func (client *Client) UpdateContainer(name string, meta map[string]string) error
 func (client *Client) GetContainerMetadata(name string) (map[string]string, error) {
 	meta, err := containers.Get(client.Container, name).ExtractMetadata()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting container %s: %s", name, errorString(err))
+		return nil, providerError(err, "Error getting container %s", name)
 	}
 	return meta, nil
 
@@ -269,7 +336,7 @@ func (client *Client) ListContainers() ([]string, error) {
 		return true, nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Error listing containers: %s", errorString(err))
+		return nil, providerError(err, "Error listing containers")
 	}
 	return containerList, nil
 }
@@ -286,7 +353,7 @@ func (client *Client) PutObject(container string, obj api.Object) error {
 	}
 	_, err := objects.Create(client.Container, container, obj.Name, obj.Content, opts).Extract()
 	if err != nil {
-		return fmt.Errorf("Error creating object %s in container %s : %s", obj.Name, container, errorString(err))
+		return providerError(err, "Error creating object %s in container %s", obj.Name, container)
 	}
 	return nil
 }
@@ -301,7 +368,10 @@ func (client *Client) UpdateObjectMetadata(container string, obj api.Object) err
 		opts.DeleteAt = int(obj.DeleteAt.Unix())
 	}
 	_, err := objects.Update(client.Container, container, obj.Name, opts).Extract()
-	return err
+	if err != nil {
+		return providerError(err, "Error updating metadata of object %s in container %s", obj.Name, container)
+	}
+	return nil
 }
 
 //GetObject get  object content from an object container
@@ -310,13 +380,24 @@ func (client *Client) GetObject(container string, name string, ranges []api.Rang
 	for _, r := range ranges {
 		rList = append(rList, r.String())
 	}
-	sRanges := strings.Join(rList, ",")
-	res := objects.Download(client.Container, container, name, objects.DownloadOpts{
-		Range: fmt.Sprintf("bytes=%s", sRanges),
+	headers := map[string]string{}
+	if len(rList) > 0 {
+		headers["Range"] = fmt.Sprintf("bytes=%s", strings.Join(rList, ","))
+	}
+	//objects.Download does not accept the 206 Partial Content answer of Swift to range requests
+	res := objects.DownloadResult{}
+	resp, err := client.Container.Request("GET", client.Container.ServiceURL(container, name), gc.RequestOpts{
+		MoreHeaders: headers,
+		OkCodes:     []int{200, 206, 304},
 	})
+	if resp != nil {
+		res.Header = resp.Header
+		res.Body = resp.Body
+	}
+	res.Err = err
 	content, err := res.ExtractContent()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting object %s from %s : %s", name, container, errorString(err))
+		return nil, providerError(err, "Error getting object %s from %s", name, container)
 	}
 	metadata := make(map[string]string)
 	for k, v := range res.Header {
@@ -327,7 +408,7 @@ func (client *Client) GetObject(container string, name string, ranges []api.Rang
 	}
 	header, err := res.Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting object %s from %s : %s", name, container, errorString(err))
+		return nil, providerError(err, "Error getting object %s from %s", name, container)
 	}
 
 	if len(ranges) > 1 {
@@ -367,11 +448,11 @@ func (client *Client) GetObjectMetadata(container string, name string) (*api.Obj
 	meta, err := res.ExtractMetadata()
 
 	if err != nil {
-		return nil, fmt.Errorf("Error getting object content: %s", errorString(err))
+		return nil, providerError(err, "Error getting object content")
 	}
 	header, err := res.Extract()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting object content: %s", errorString(err))
+		return nil, providerError(err, "Error getting object content")
 	}
 
 	return &api.Object{
@@ -396,7 +477,7 @@ func (client *Client) ListObjects(container string, filter api.ObjectFilter) ([]
 	pager := objects.List(client.Container, container, opts)
 	var objectList []string
 	err := pager.EachPage(func(page pagination.Page) (bool, error) {
-		objectNames, err := containers.ExtractNames(page)
+		objectNames, err := objects.ExtractNames(page)
 		if err != nil {
 			return false, err
 		}
@@ -404,7 +485,7 @@ func (client *Client) ListObjects(container string, filter api.ObjectFilter) ([]
 		return true, nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Error listing objects od container%s: %s", container, errorString(err))
+		return nil, providerError(err, "Error listing objects od container%s", container)
 	}
 	return objectList, nil
 }
@@ -420,7 +501,7 @@ func (client *Client) CopyObject(containerSrc, objectSrc, objectDst string) erro
 
 	_, err := result.ExtractHeader()
 	if err != nil {
-		return fmt.Errorf("Error copying object %s into %s from container %s : %s", objectSrc, objectDst, containerSrc, errorString(err))
+		return providerError(err, "Error copying object %s into %s from container %s", objectSrc, objectDst, containerSrc)
 	}
 	return nil
 }
@@ -429,7 +510,7 @@ func (client *Client) CopyObject(containerSrc, objectSrc, objectDst string) erro
 func (client *Client) DeleteObject(container, object string) error {
 	_, err := objects.Delete(client.Container, container, object, objects.DeleteOpts{}).Extract()
 	if err != nil {
-		return fmt.Errorf("Error deleting objects %s of container %s: %s", object, container, errorString(err))
+		return providerError(err, "Error deleting objects %s of container %s", object, container)
 	}
 	return nil
 
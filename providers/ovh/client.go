@@ -29,6 +29,8 @@ type AuthOptions struct {
 	OpenstackPassword string
 	//Name of the data center (GRA3, BHS3 ...)
 	Region string
+	//IdentityEndpoint overrides the OVH Keystone endpoint (optional)
+	IdentityEndpoint string
 }
 
 // func parseOpenRC(openrc string) (*openstack.AuthOptions, error) {
@@ -43,8 +45,12 @@ func AuthenticatedClient(opts AuthOptions) (*Client, error) {
 		return nil, err
 	}
 	client.ovh = c
+	identityEndpoint := opts.IdentityEndpoint
+	if identityEndpoint == "" {
+		identityEndpoint = "https://auth.cloud.ovh.net/v2.0"
+	}
 	os, err := openstack.AuthenticatedClient(openstack.AuthOptions{
-		IdentityEndpoint: "https://auth.cloud.ovh.net/v2.0",
+		IdentityEndpoint: identityEndpoint,
 		//UserID:           opts.OpenstackID,
 		Username: opts.OpenstackID,
 		Password: opts.OpenstackPassword,
@@ -61,6 +67,7 @@ func AuthenticatedClient(opts AuthOptions) (*Client, error) {
 				"classic":    VolumeSpeed.COLD,
 				"high-speed": VolumeSpeed.HDD,
 			},
+			TemplatePrices: TemplatePrices,
 		},
 	)
 
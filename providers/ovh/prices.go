@@ -0,0 +1,36 @@
+package ovh
+
+import (
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//hourly returns an hourly price in euros excluding taxes
+func hourly(amount float64) api.Price {
+	return api.Price{
+		Amount:   amount,
+		Currency: "EUR",
+		Unit:     api.PriceUnitHour,
+	}
+}
+
+//TemplatePrices public prices of OVH public cloud flavors (hourly billing, excluding taxes)
+var TemplatePrices = map[string]api.Price{
+	"s1-2":   hourly(0.008),
+	"s1-4":   hourly(0.015),
+	"s1-8":   hourly(0.03),
+	"b2-7":   hourly(0.05),
+	"b2-15":  hourly(0.1),
+	"b2-30":  hourly(0.2),
+	"b2-60":  hourly(0.4),
+	"b2-120": hourly(0.8),
+	"c2-7":   hourly(0.07),
+	"c2-15":  hourly(0.14),
+	"c2-30":  hourly(0.28),
+	"c2-60":  hourly(0.56),
+	"c2-120": hourly(1.12),
+	"r2-15":  hourly(0.08),
+	"r2-30":  hourly(0.15),
+	"r2-60":  hourly(0.29),
+	"r2-120": hourly(0.57),
+	"r2-240": hourly(1.14),
+}
@@ -0,0 +1,31 @@
+package ovh
+
+import (
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+func init() {
+	providers.Register(providers.Provider{
+		Name:        "ovh",
+		Description: "OVH public cloud",
+		Fields: []providers.ConfigField{
+			{Name: "Endpoint", Description: "OVH API end point (ovh-eu, ovh-ca ...)", Required: true, Sample: "ovh-eu"},
+			{Name: "ApplicationName", Description: "application or project name", Required: true, Sample: "my-project"},
+			{Name: "ApplicationKey", Description: "application key or project ID", Required: true, Sample: "a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"},
+			{Name: "ConsumerKey", Description: "OVH API consumer key", Required: true, Secret: true, Sample: "consumer-key"},
+			{Name: "OpenstackID", Description: "OpenStack user name", Required: true, Sample: "user-abcdefgh"},
+			{Name: "OpenstackPassword", Description: "OpenStack password", Required: true, Secret: true, Sample: "password"},
+			{Name: "Region", Description: "data center (GRA3, BHS3 ...)", Required: true, Sample: "GRA3"},
+			{Name: "IdentityEndpoint", Description: "URL overriding the OVH Keystone endpoint (optional)"},
+		},
+		Factory: func(cfg map[string]interface{}) (api.ClientAPI, error) {
+			opts := AuthOptions{}
+			err := providers.DecodeConfig(cfg, &opts)
+			if err != nil {
+				return nil, err
+			}
+			return AuthenticatedClient(opts)
+		},
+	})
+}
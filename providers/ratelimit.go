@@ -0,0 +1,104 @@
+package providers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//RateLimiter token bucket limiting the rate of the calls to a provider
+//The bucket holds up to Burst tokens and is refilled at Rate tokens per second, each call takes a token
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+//NewRateLimiter creates a rate limiter allowing rate calls per second with bursts of burst calls
+//A rate lower or equal to 0 disables the limit
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	l := &RateLimiter{}
+	l.SetLimit(rate, burst)
+	return l
+}
+
+//SetLimit changes the rate and the burst of the limiter, the tokens refilled at the previous rate are kept
+func (l *RateLimiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if burst < 1 {
+		burst = 1
+	}
+	if !l.last.IsZero() && l.rate == rate && l.burst == float64(burst) {
+		return
+	}
+	if !l.last.IsZero() && l.rate > 0 {
+		l.tokens += time.Since(l.last).Seconds() * l.rate
+	}
+	l.rate = rate
+	l.burst = float64(burst)
+	if l.last.IsZero() || l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = time.Now()
+}
+
+//reserve takes a token, it returns the time to wait before a token is available if the bucket is empty
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+//Wait waits for a token or for ctx to be done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		d := l.reserve()
+		if d == 0 {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*RateLimiter{}
+)
+
+//TenantRateLimiter returns the rate limiter shared by the clients of the tenant name
+//Each tenant has its own credentials and quotas on its provider, so the tenants of a provider do not share a limiter.
+//The limit of the limiter is set to rate and burst
+func TenantRateLimiter(name string, rate float64, burst int) *RateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	l, ok := limiters[name]
+	if !ok {
+		l = NewRateLimiter(rate, burst)
+		limiters[name] = l
+		return l
+	}
+	l.SetLimit(rate, burst)
+	return l
+}
@@ -0,0 +1,35 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTenantRateLimiter(t *testing.T) {
+	slow := TenantRateLimiter("rate-test-slow", 1, 1)
+	fast := TenantRateLimiter("rate-test-fast", 1000, 10)
+	if slow == fast {
+		t.Fatal("expected the tenants to have their own limiter")
+	}
+	if TenantRateLimiter("rate-test-slow", 1, 1) != slow {
+		t.Fatal("expected the clients of a tenant to share a limiter")
+	}
+	if slow.rate != 1 || slow.burst != 1 {
+		t.Errorf("expected the limit of a tenant not to be changed by another tenant, got %v/%v", slow.rate, slow.burst)
+	}
+}
+
+func TestSetLimitKeepsTokens(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := l.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	//the bucket is empty, setting the same limit must not refill it
+	l.SetLimit(1, 1)
+	if d := l.reserve(); d == 0 {
+		t.Error("expected the bucket to stay empty when the limit is unchanged")
+	}
+}
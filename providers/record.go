@@ -0,0 +1,203 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//RecordSchemaVersion version of the records written by the drivers
+const RecordSchemaVersion = 1
+
+//Kinds of the records persisted by the drivers
+const (
+	//RecordVM definition of a VM (api.VM), it keeps the private key and the gateway of the VM
+	RecordVM = "vm"
+	//RecordNetwork definition of a network (api.Network)
+	RecordNetwork = "network"
+	//RecordGateway ID of the gateway VM of a network (string)
+	RecordGateway = "gateway"
+	//RecordVolumeName name of a volume the provider does not keep (string)
+	RecordVolumeName = "volume_name"
+	//RecordCreation kind and creation time of a resource the provider cannot tag as created by gpac (driver specific)
+	RecordCreation = "creation"
+)
+
+//Record format of the resource definitions persisted in the metadata store, e.g.
+//{"schema": 1, "kind": "vm", "data": {"id": "...", "name": "vm1", "private_key": "...", ...}}
+//Data is the JSON encoding of the api structure of the kind (api.VM for "vm", api.Network for "network") or a JSON
+//string for "gateway" and "volume_name". Schema is incremented when the encoding of a kind changes in a way older
+//readers cannot ignore; records of a previous schema are migrated when they are read and rewritten by MigrateRecords.
+//Values written before records existed (gob encoded VMs, bare JSON networks, raw strings) are read as schema 0
+//The private key of a VM is replaced by an encrypted sealed_private_key object when the drivers use a
+//SealedMetadataStore
+type Record struct {
+	Schema int             `json:"schema"`
+	Kind   string          `json:"kind"`
+	Data   json.RawMessage `json:"data"`
+	//Written time the record was encoded, it gives the age of the records of deleted resources to the garbage
+	//collector. It is zero for the records written before it was added
+	Written time.Time `json:"written"`
+}
+
+//recordMigrations migrations of the data of the records indexed by kind then by schema version
+//recordMigrations[kind][n] converts data of schema n to schema n+1
+var recordMigrations = map[string]map[int]func(json.RawMessage) (json.RawMessage, error){}
+
+//EncodeRecord encodes v as a record of kind
+func EncodeRecord(kind string, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding %s record: %s", kind, err.Error())
+	}
+	b, err := json.Marshal(Record{
+		Schema:  RecordSchemaVersion,
+		Kind:    kind,
+		Data:    data,
+		Written: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding %s record: %s", kind, err.Error())
+	}
+	return b, nil
+}
+
+//DecodeRecord decodes the record of kind b into v, records of a previous schema and values written before records
+//existed are migrated
+func DecodeRecord(kind string, b []byte, v interface{}) error {
+	_, err := decodeRecord(kind, b, v)
+	return err
+}
+
+//decodeRecord decodes the record of kind b into v and returns the schema of b, 0 for a legacy value
+func decodeRecord(kind string, b []byte, v interface{}) (int, error) {
+	rec := Record{}
+	if json.Unmarshal(b, &rec) != nil || rec.Schema == 0 {
+		return 0, decodeLegacy(kind, b, v)
+	}
+	if rec.Kind != kind {
+		return rec.Schema, fmt.Errorf("Error decoding %s record: record of kind %s", kind, rec.Kind)
+	}
+	if rec.Schema > RecordSchemaVersion {
+		return rec.Schema, fmt.Errorf("Error decoding %s record: schema %d written by a newer version, this version reads up to schema %d", kind, rec.Schema, RecordSchemaVersion)
+	}
+	data := rec.Data
+	for s := rec.Schema; s < RecordSchemaVersion; s++ {
+		migrate, ok := recordMigrations[kind][s]
+		if !ok {
+			continue
+		}
+		var err error
+		data, err = migrate(data)
+		if err != nil {
+			return rec.Schema, fmt.Errorf("Error migrating %s record from schema %d: %s", kind, s, err.Error())
+		}
+	}
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return rec.Schema, fmt.Errorf("Error decoding %s record: %s", kind, err.Error())
+	}
+	return rec.Schema, nil
+}
+
+//decodeLegacy decodes a value written before records existed: strings were stored raw, networks as JSON and VMs
+//with gob
+func decodeLegacy(kind string, b []byte, v interface{}) error {
+	if s, ok := v.(*string); ok {
+		*s = string(b)
+		return nil
+	}
+	if json.Valid(b) {
+		err := json.Unmarshal(b, v)
+		if err != nil {
+			return fmt.Errorf("Error decoding legacy %s record: %s", kind, err.Error())
+		}
+		return nil
+	}
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+	if err != nil {
+		return fmt.Errorf("Error decoding legacy %s record: %s", kind, err.Error())
+	}
+	return nil
+}
+
+//RecordBucket bucket of the metadata store holding records of a single kind
+type RecordBucket struct {
+	Bucket string
+	Kind   string
+	//New returns a pointer to the value decoded from the records of the bucket
+	New func() interface{}
+}
+
+//MigrationReport result of MigrateRecords
+type MigrationReport struct {
+	//Migrated keys of the migrated records formatted as <bucket>/<key>
+	Migrated []string `json:"migrated"`
+	//Current number of records already at the current schema
+	Current int `json:"current"`
+	//Failed errors of the records that could not be migrated indexed by <bucket>/<key>
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+//MigrateRecords rewrites the records of buckets that are not at the current schema, records are only listed if
+//dryRun is true. A record modified while it is migrated is left to the writer and reported as failed
+func MigrateRecords(store MetadataStore, buckets []RecordBucket, dryRun bool) (*MigrationReport, error) {
+	report := MigrationReport{
+		Migrated: []string{},
+		Failed:   map[string]string{},
+	}
+	for _, bucket := range buckets {
+		keys, err := store.List(bucket.Bucket, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			name := bucket.Bucket + "/" + key
+			err := migrateRecord(store, bucket, key, dryRun)
+			switch {
+			case errors.Is(err, errRecordCurrent):
+				report.Current++
+			case errors.Is(err, api.ErrNotFound):
+				//deleted since it was listed
+			case err != nil:
+				report.Failed[name] = err.Error()
+			default:
+				report.Migrated = append(report.Migrated, name)
+			}
+		}
+	}
+	return &report, nil
+}
+
+//errRecordCurrent is returned by migrateRecord when the record is already at the current schema
+var errRecordCurrent = errors.New("record at the current schema")
+
+//migrateRecord rewrites the record key of bucket at the current schema
+func migrateRecord(store MetadataStore, bucket RecordBucket, key string, dryRun bool) error {
+	b, version, err := store.Get(bucket.Bucket, key)
+	if err != nil {
+		return err
+	}
+	v := bucket.New()
+	schema, err := decodeRecord(bucket.Kind, b, v)
+	if err != nil {
+		return err
+	}
+	if schema == RecordSchemaVersion {
+		return errRecordCurrent
+	}
+	if dryRun {
+		return nil
+	}
+	b, err = EncodeRecord(bucket.Kind, v)
+	if err != nil {
+		return err
+	}
+	_, err = store.CompareAndSwap(bucket.Bucket, key, b, version)
+	return err
+}
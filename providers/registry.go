@@ -0,0 +1,169 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//ConfigField describes a field of a provider configuration
+type ConfigField struct {
+	//Name of the field in the configuration map
+	Name string `json:"name"`
+	//Description human readable description of the field
+	Description string `json:"description,omitempty"`
+	//Required the field must be present in the configuration
+	Required bool `json:"required,omitempty"`
+	//Secret the value of the field must not be displayed (passwords, keys ...)
+	Secret bool `json:"secret,omitempty"`
+	//Sample value used to build sample configurations
+	Sample interface{} `json:"sample,omitempty"`
+}
+
+//Factory creates an authenticated client from a configuration
+type Factory func(cfg map[string]interface{}) (api.ClientAPI, error)
+
+//Provider a provider registered in the registry
+type Provider struct {
+	//Name of the provider
+	Name string `json:"name"`
+	//Description human readable description of the provider
+	Description string `json:"description,omitempty"`
+	//Fields schema of the configuration
+	Fields []ConfigField `json:"fields"`
+	//Factory creates clients of the provider
+	Factory Factory `json:"-"`
+}
+
+//Sample returns a sample configuration of the provider
+func (p *Provider) Sample() map[string]interface{} {
+	cfg := map[string]interface{}{}
+	for _, f := range p.Fields {
+		if f.Sample != nil {
+			cfg[f.Name] = f.Sample
+		}
+	}
+	return cfg
+}
+
+//Validate checks that cfg contains the required fields of the provider and only known fields
+func (p *Provider) Validate(cfg map[string]interface{}) error {
+	known := map[string]bool{}
+	for _, f := range p.Fields {
+		known[f.Name] = true
+		if _, ok := cfg[f.Name]; f.Required && !ok {
+			return fmt.Errorf("Error validating %s configuration: missing field %s", p.Name, f.Name)
+		}
+	}
+	var unknown []string
+	for k := range cfg {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("Error validating %s configuration: unknown fields %s", p.Name, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*Provider{}
+)
+
+//Register registers a provider, drivers call it from their init function
+//Register panics if a provider is registered twice under the same name or has no factory
+func Register(p Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if p.Factory == nil {
+		panic(fmt.Sprintf("providers: Register %s without factory", p.Name))
+	}
+	if _, ok := registry[p.Name]; ok {
+		panic(fmt.Sprintf("providers: Register called twice for provider %s", p.Name))
+	}
+	registry[p.Name] = &p
+}
+
+//GetProvider returns the provider registered under name
+func GetProvider(name string) (*Provider, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[name]
+	if !ok {
+		return nil, ResourceNotFoundError("Provider", name)
+	}
+	return p, nil
+}
+
+//Providers returns registered providers sorted by name
+func Providers() []Provider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var list []Provider
+	for _, p := range registry {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+//New creates an authenticated client of the provider name using configuration cfg
+func New(name string, cfg map[string]interface{}) (api.ClientAPI, error) {
+	p, err := GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	err = p.Validate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return p.Factory(cfg)
+}
+
+//FileConfig content of a provider configuration file
+type FileConfig struct {
+	//Provider name of the provider
+	Provider string `json:"provider"`
+	//Config configuration of the provider
+	Config map[string]interface{} `json:"config"`
+}
+
+//NewFromFile creates an authenticated client from a JSON configuration file
+//The file contains an object with the provider name and its configuration, e.g.
+//{"provider": "ovh", "config": {"ApplicationKey": "...", ...}}
+func NewFromFile(path string) (api.ClientAPI, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading provider configuration: %s", err.Error())
+	}
+	fc := FileConfig{}
+	err = json.Unmarshal(b, &fc)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading provider configuration %s: %s", path, err.Error())
+	}
+	return New(fc.Provider, fc.Config)
+}
+
+//DecodeConfig decodes a configuration map into the options structure of a driver
+//Keys of the map are the names of the fields of the structure
+func DecodeConfig(cfg map[string]interface{}, opts interface{}) error {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("Error decoding configuration: %s", err.Error())
+	}
+	err = json.Unmarshal(b, opts)
+	if err != nil {
+		return fmt.Errorf("Error decoding configuration: %s", err.Error())
+	}
+	return nil
+}
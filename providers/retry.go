@@ -0,0 +1,802 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+	"github.com/SebastienDorgan/gpac/system"
+)
+
+//Default settings of the retries
+const (
+	//DefaultMaxAttempts maximum number of attempts of an idempotent call
+	DefaultMaxAttempts = 4
+	//DefaultRetryMinDelay delay before the first retry
+	DefaultRetryMinDelay = 500 * time.Millisecond
+	//DefaultRetryMaxDelay maximum delay between two attempts
+	DefaultRetryMaxDelay = 30 * time.Second
+)
+
+//RetryOptions retry and rate limit settings of the calls to a provider
+type RetryOptions struct {
+	//MaxAttempts maximum number of attempts of idempotent calls, DefaultMaxAttempts is used if 0, 1 disables the retries
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	//MinDelay delay before the first retry, it doubles after each attempt. DefaultRetryMinDelay is used if 0
+	//The delays are written in JSON as duration strings, e.g. "500ms" or "1m30s"
+	MinDelay time.Duration `json:"min_delay,omitempty"`
+	//MaxDelay maximum delay between two attempts, DefaultRetryMaxDelay is used if 0
+	MaxDelay time.Duration `json:"max_delay,omitempty"`
+	//Rate maximum number of calls per second of the tenant to the provider, 0 disables the limit
+	Rate float64 `json:"rate,omitempty"`
+	//Burst number of calls allowed above the rate
+	Burst int `json:"burst,omitempty"`
+}
+
+//retryOptions has the fields of RetryOptions without its JSON methods
+type retryOptions RetryOptions
+
+//MarshalJSON writes the delays as duration strings
+func (opts RetryOptions) MarshalJSON() ([]byte, error) {
+	v := struct {
+		retryOptions
+		MinDelay string `json:"min_delay,omitempty"`
+		MaxDelay string `json:"max_delay,omitempty"`
+	}{retryOptions: retryOptions(opts)}
+	if opts.MinDelay != 0 {
+		v.MinDelay = opts.MinDelay.String()
+	}
+	if opts.MaxDelay != 0 {
+		v.MaxDelay = opts.MaxDelay.String()
+	}
+	return json.Marshal(&v)
+}
+
+//UnmarshalJSON reads the delays from duration strings, numbers of nanoseconds are accepted too
+func (opts *RetryOptions) UnmarshalJSON(b []byte) error {
+	v := struct {
+		*retryOptions
+		MinDelay json.RawMessage `json:"min_delay"`
+		MaxDelay json.RawMessage `json:"max_delay"`
+	}{retryOptions: (*retryOptions)(opts)}
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	opts.MinDelay, err = parseDelay("min_delay", v.MinDelay)
+	if err != nil {
+		return err
+	}
+	opts.MaxDelay, err = parseDelay("max_delay", v.MaxDelay)
+	return err
+}
+
+//parseDelay parses the delay field, a duration string or a number of nanoseconds
+func parseDelay(field string, value json.RawMessage) (time.Duration, error) {
+	if len(value) == 0 || string(value) == "null" {
+		return 0, nil
+	}
+	s := ""
+	if json.Unmarshal(value, &s) == nil {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, api.NewError(api.ErrInvalidRequest, err, "Invalid retry option %s", field)
+		}
+		return d, nil
+	}
+	var n int64
+	err := json.Unmarshal(value, &n)
+	if err != nil {
+		return 0, api.NewError(api.ErrInvalidRequest, err, "Invalid retry option %s", field)
+	}
+	return time.Duration(n), nil
+}
+
+/*RetryClient decorates a ClientAPI with retries and a client side rate limit
+Get and List calls are retried with an exponential backoff when they fail with a transient error
+(api.ErrUnavailable, api.ErrTimeout or an error telling when to retry). Delete calls are retried too and succeed
+if the resource is not found after the first attempt. Other calls are not idempotent and are never retried.
+The delay given by the provider (Retry-After) takes precedence over the backoff.
+All the calls take a token of the rate limiter of the tenant
+*/
+type RetryClient struct {
+	clt       api.ClientAPIContext
+	decorated api.ClientAPI
+	opts      RetryOptions
+	backoff   *Waiter
+	limiter   *RateLimiter
+}
+
+//NewRetryClient decorates clt, a client of the tenant named tenant, with retries and rate limit
+//The clients of a tenant share the same rate limiter
+func NewRetryClient(clt api.ClientAPI, tenant string, opts RetryOptions) *RetryClient {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.MinDelay <= 0 {
+		opts.MinDelay = DefaultRetryMinDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultRetryMaxDelay
+	}
+	return &RetryClient{
+		clt:       api.WithContext(clt),
+		decorated: clt,
+		opts:      opts,
+		backoff: &Waiter{
+			MinDelay: opts.MinDelay,
+			MaxDelay: opts.MaxDelay,
+			Jitter:   DefaultJitter,
+		},
+		limiter: TenantRateLimiter(tenant, opts.Rate, opts.Burst),
+	}
+}
+
+//Unwrap returns the decorated client
+func (c *RetryClient) Unwrap() api.ClientAPI {
+	return c.decorated
+}
+
+//Retryable tells if a call failing with err may succeed later
+func Retryable(err error) bool {
+	if api.RetryAfter(err) > 0 {
+		return true
+	}
+	kind := api.KindOf(err)
+	return kind == api.ErrUnavailable || kind == api.ErrTimeout
+}
+
+//call calls f once the rate limiter allows it
+func (c *RetryClient) call(ctx context.Context, f func(ctx context.Context) error) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return f(ctx)
+}
+
+//do calls f until it succeeds, fails with an error which is not transient, or MaxAttempts is reached
+//If notFoundOK is true, a not found error after the first attempt is a success
+func (c *RetryClient) do(ctx context.Context, notFoundOK bool, f func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := c.call(ctx, f)
+		if err == nil {
+			return nil
+		}
+		if notFoundOK && attempt > 1 && errors.Is(err, api.ErrNotFound) {
+			return nil
+		}
+		if ctx.Err() != nil || attempt >= c.opts.MaxAttempts || !Retryable(err) {
+			return err
+		}
+		d := api.RetryAfter(err)
+		if d <= 0 {
+			d = c.backoff.delay(attempt)
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+//retry calls the idempotent function f, retrying on transient errors
+func (c *RetryClient) retry(ctx context.Context, f func(ctx context.Context) error) error {
+	return c.do(ctx, false, f)
+}
+
+//retryDelete calls the delete function f, retrying on transient errors
+func (c *RetryClient) retryDelete(ctx context.Context, f func(ctx context.Context) error) error {
+	return c.do(ctx, true, f)
+}
+
+//ListImages lists available OS images, the call is retried on transient errors
+func (c *RetryClient) ListImages() ([]api.Image, error) {
+	return c.ListImagesContext(context.Background())
+}
+
+//ListImagesContext implements api.ClientAPIContext
+func (c *RetryClient) ListImagesContext(ctx context.Context) ([]api.Image, error) {
+	var res []api.Image
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.ListImagesContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetImage returns the Image referenced by id, the call is retried on transient errors
+func (c *RetryClient) GetImage(id string) (*api.Image, error) {
+	return c.GetImageContext(context.Background(), id)
+}
+
+//GetImageContext implements api.ClientAPIContext
+func (c *RetryClient) GetImageContext(ctx context.Context, id string) (*api.Image, error) {
+	var res *api.Image
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.GetImageContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetTemplate returns the Template referenced by id, the call is retried on transient errors
+func (c *RetryClient) GetTemplate(id string) (*api.VMTemplate, error) {
+	return c.GetTemplateContext(context.Background(), id)
+}
+
+//GetTemplateContext implements api.ClientAPIContext
+func (c *RetryClient) GetTemplateContext(ctx context.Context, id string) (*api.VMTemplate, error) {
+	var res *api.VMTemplate
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.GetTemplateContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListTemplates lists available VM templates, the call is retried on transient errors
+func (c *RetryClient) ListTemplates() ([]api.VMTemplate, error) {
+	return c.ListTemplatesContext(context.Background())
+}
+
+//ListTemplatesContext implements api.ClientAPIContext
+func (c *RetryClient) ListTemplatesContext(ctx context.Context) ([]api.VMTemplate, error) {
+	var res []api.VMTemplate
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.ListTemplatesContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CreateKeyPair creates and import a key pair
+func (c *RetryClient) CreateKeyPair(name string) (*api.KeyPair, error) {
+	return c.CreateKeyPairContext(context.Background(), name)
+}
+
+//CreateKeyPairContext implements api.ClientAPIContext
+func (c *RetryClient) CreateKeyPairContext(ctx context.Context, name string) (*api.KeyPair, error) {
+	var res *api.KeyPair
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.CreateKeyPairContext(ctx, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetKeyPair returns the key pair identified by id, the call is retried on transient errors
+func (c *RetryClient) GetKeyPair(id string) (*api.KeyPair, error) {
+	return c.GetKeyPairContext(context.Background(), id)
+}
+
+//GetKeyPairContext implements api.ClientAPIContext
+func (c *RetryClient) GetKeyPairContext(ctx context.Context, id string) (*api.KeyPair, error) {
+	var res *api.KeyPair
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.GetKeyPairContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListKeyPairs lists available key pairs, the call is retried on transient errors
+func (c *RetryClient) ListKeyPairs() ([]api.KeyPair, error) {
+	return c.ListKeyPairsContext(context.Background())
+}
+
+//ListKeyPairsContext implements api.ClientAPIContext
+func (c *RetryClient) ListKeyPairsContext(ctx context.Context) ([]api.KeyPair, error) {
+	var res []api.KeyPair
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.ListKeyPairsContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteKeyPair deletes the key pair identified by id, the call is retried on transient errors
+func (c *RetryClient) DeleteKeyPair(id string) error {
+	return c.DeleteKeyPairContext(context.Background(), id)
+}
+
+//DeleteKeyPairContext implements api.ClientAPIContext
+func (c *RetryClient) DeleteKeyPairContext(ctx context.Context, id string) error {
+	return c.retryDelete(ctx, func(ctx context.Context) error {
+		return c.clt.DeleteKeyPairContext(ctx, id)
+	})
+}
+
+//CreateNetwork creates a network named name
+func (c *RetryClient) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
+	return c.CreateNetworkContext(context.Background(), req)
+}
+
+//CreateNetworkContext implements api.ClientAPIContext
+func (c *RetryClient) CreateNetworkContext(ctx context.Context, req api.NetworkRequest) (*api.Network, error) {
+	var res *api.Network
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.CreateNetworkContext(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetNetwork returns the network identified by id, the call is retried on transient errors
+func (c *RetryClient) GetNetwork(id string) (*api.Network, error) {
+	return c.GetNetworkContext(context.Background(), id)
+}
+
+//GetNetworkContext implements api.ClientAPIContext
+func (c *RetryClient) GetNetworkContext(ctx context.Context, id string) (*api.Network, error) {
+	var res *api.Network
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.GetNetworkContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListNetworks lists available networks, the call is retried on transient errors
+func (c *RetryClient) ListNetworks() ([]api.Network, error) {
+	return c.ListNetworksContext(context.Background())
+}
+
+//ListNetworksContext implements api.ClientAPIContext
+func (c *RetryClient) ListNetworksContext(ctx context.Context) ([]api.Network, error) {
+	var res []api.Network
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.ListNetworksContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteNetwork deletes the network identified by id, the call is retried on transient errors
+func (c *RetryClient) DeleteNetwork(id string) error {
+	return c.DeleteNetworkContext(context.Background(), id)
+}
+
+//DeleteNetworkContext implements api.ClientAPIContext
+func (c *RetryClient) DeleteNetworkContext(ctx context.Context, id string) error {
+	return c.retryDelete(ctx, func(ctx context.Context) error {
+		return c.clt.DeleteNetworkContext(ctx, id)
+	})
+}
+
+//CreateVM creates a VM that fulfils the request
+func (c *RetryClient) CreateVM(request api.VMRequest) (*api.VM, error) {
+	return c.CreateVMContext(context.Background(), request)
+}
+
+//CreateVMContext implements api.ClientAPIContext
+func (c *RetryClient) CreateVMContext(ctx context.Context, request api.VMRequest) (*api.VM, error) {
+	var res *api.VM
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.CreateVMContext(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVM returns the VM identified by id, the call is retried on transient errors
+func (c *RetryClient) GetVM(id string) (*api.VM, error) {
+	return c.GetVMContext(context.Background(), id)
+}
+
+//GetVMContext implements api.ClientAPIContext
+func (c *RetryClient) GetVMContext(ctx context.Context, id string) (*api.VM, error) {
+	var res *api.VM
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.GetVMContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListVMs lists available VMs, the call is retried on transient errors
+func (c *RetryClient) ListVMs() ([]api.VM, error) {
+	return c.ListVMsContext(context.Background())
+}
+
+//ListVMsContext implements api.ClientAPIContext
+func (c *RetryClient) ListVMsContext(ctx context.Context) ([]api.VM, error) {
+	var res []api.VM
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.ListVMsContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteVM deletes the VM identified by id, the call is retried on transient errors
+func (c *RetryClient) DeleteVM(id string) error {
+	return c.DeleteVMContext(context.Background(), id)
+}
+
+//DeleteVMContext implements api.ClientAPIContext
+func (c *RetryClient) DeleteVMContext(ctx context.Context, id string) error {
+	return c.retryDelete(ctx, func(ctx context.Context) error {
+		return c.clt.DeleteVMContext(ctx, id)
+	})
+}
+
+//StopVM stops the VM identified by id
+func (c *RetryClient) StopVM(id string) error {
+	return c.StopVMContext(context.Background(), id)
+}
+
+//StopVMContext implements api.ClientAPIContext
+func (c *RetryClient) StopVMContext(ctx context.Context, id string) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		return c.clt.StopVMContext(ctx, id)
+	})
+}
+
+//StartVM starts the VM identified by id
+func (c *RetryClient) StartVM(id string) error {
+	return c.StartVMContext(context.Background(), id)
+}
+
+//StartVMContext implements api.ClientAPIContext
+func (c *RetryClient) StartVMContext(ctx context.Context, id string) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		return c.clt.StartVMContext(ctx, id)
+	})
+}
+
+//GetSSHConfig creates SSHConfig from VM, the call is retried on transient errors
+func (c *RetryClient) GetSSHConfig(id string) (*system.SSHConfig, error) {
+	return c.GetSSHConfigContext(context.Background(), id)
+}
+
+//GetSSHConfigContext implements api.ClientAPIContext
+func (c *RetryClient) GetSSHConfigContext(ctx context.Context, id string) (*system.SSHConfig, error) {
+	var res *system.SSHConfig
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.GetSSHConfigContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CreateVolume creates a block volume
+func (c *RetryClient) CreateVolume(request api.VolumeRequest) (*api.Volume, error) {
+	return c.CreateVolumeContext(context.Background(), request)
+}
+
+//CreateVolumeContext implements api.ClientAPIContext
+func (c *RetryClient) CreateVolumeContext(ctx context.Context, request api.VolumeRequest) (*api.Volume, error) {
+	var res *api.Volume
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.CreateVolumeContext(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVolume returns the volume identified by id, the call is retried on transient errors
+func (c *RetryClient) GetVolume(id string) (*api.Volume, error) {
+	return c.GetVolumeContext(context.Background(), id)
+}
+
+//GetVolumeContext implements api.ClientAPIContext
+func (c *RetryClient) GetVolumeContext(ctx context.Context, id string) (*api.Volume, error) {
+	var res *api.Volume
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.GetVolumeContext(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListVolumes list available volumes, the call is retried on transient errors
+func (c *RetryClient) ListVolumes() ([]api.Volume, error) {
+	return c.ListVolumesContext(context.Background())
+}
+
+//ListVolumesContext implements api.ClientAPIContext
+func (c *RetryClient) ListVolumesContext(ctx context.Context) ([]api.Volume, error) {
+	var res []api.Volume
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.ListVolumesContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteVolume deletes the volume identified by id, the call is retried on transient errors
+func (c *RetryClient) DeleteVolume(id string) error {
+	return c.DeleteVolumeContext(context.Background(), id)
+}
+
+//DeleteVolumeContext implements api.ClientAPIContext
+func (c *RetryClient) DeleteVolumeContext(ctx context.Context, id string) error {
+	return c.retryDelete(ctx, func(ctx context.Context) error {
+		return c.clt.DeleteVolumeContext(ctx, id)
+	})
+}
+
+//UpdateVolume renames, resizes or changes the speed of the volume identified by id, the call is retried on transient
+//errors
+func (c *RetryClient) UpdateVolume(id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	return c.UpdateVolumeContext(context.Background(), id, request)
+}
+
+//UpdateVolumeContext implements api.ClientAPIContext
+func (c *RetryClient) UpdateVolumeContext(ctx context.Context, id string, request api.VolumeUpdateRequest) (*api.Volume, error) {
+	var res *api.Volume
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.UpdateVolumeContext(ctx, id, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CreateVolumeAttachment attaches a volume to a VM
+func (c *RetryClient) CreateVolumeAttachment(request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	return c.CreateVolumeAttachmentContext(context.Background(), request)
+}
+
+//CreateVolumeAttachmentContext implements api.ClientAPIContext
+func (c *RetryClient) CreateVolumeAttachmentContext(ctx context.Context, request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	var res *api.VolumeAttachment
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.CreateVolumeAttachmentContext(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetVolumeAttachment returns the volume attachment identified by id, the call is retried on transient errors
+func (c *RetryClient) GetVolumeAttachment(serverID string, id string) (*api.VolumeAttachment, error) {
+	return c.GetVolumeAttachmentContext(context.Background(), serverID, id)
+}
+
+//GetVolumeAttachmentContext implements api.ClientAPIContext
+func (c *RetryClient) GetVolumeAttachmentContext(ctx context.Context, serverID string, id string) (*api.VolumeAttachment, error) {
+	var res *api.VolumeAttachment
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.GetVolumeAttachmentContext(ctx, serverID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListVolumeAttachments lists available volume attachment, the call is retried on transient errors
+func (c *RetryClient) ListVolumeAttachments(serverID string) ([]api.VolumeAttachment, error) {
+	return c.ListVolumeAttachmentsContext(context.Background(), serverID)
+}
+
+//ListVolumeAttachmentsContext implements api.ClientAPIContext
+func (c *RetryClient) ListVolumeAttachmentsContext(ctx context.Context, serverID string) ([]api.VolumeAttachment, error) {
+	var res []api.VolumeAttachment
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.ListVolumeAttachmentsContext(ctx, serverID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//DeleteVolumeAttachment deletes the volume attachment identifed by id, the call is retried on transient errors
+func (c *RetryClient) DeleteVolumeAttachment(serverID string, id string) error {
+	return c.DeleteVolumeAttachmentContext(context.Background(), serverID, id)
+}
+
+//DeleteVolumeAttachmentContext implements api.ClientAPIContext
+func (c *RetryClient) DeleteVolumeAttachmentContext(ctx context.Context, serverID string, id string) error {
+	return c.retryDelete(ctx, func(ctx context.Context) error {
+		return c.clt.DeleteVolumeAttachmentContext(ctx, serverID, id)
+	})
+}
+
+//CreateContainer creates an object container
+func (c *RetryClient) CreateContainer(name string) error {
+	return c.CreateContainerContext(context.Background(), name)
+}
+
+//CreateContainerContext implements api.ClientAPIContext
+func (c *RetryClient) CreateContainerContext(ctx context.Context, name string) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		return c.clt.CreateContainerContext(ctx, name)
+	})
+}
+
+//DeleteContainer deletes an object container, the call is retried on transient errors
+func (c *RetryClient) DeleteContainer(name string) error {
+	return c.DeleteContainerContext(context.Background(), name)
+}
+
+//DeleteContainerContext implements api.ClientAPIContext
+func (c *RetryClient) DeleteContainerContext(ctx context.Context, name string) error {
+	return c.retryDelete(ctx, func(ctx context.Context) error {
+		return c.clt.DeleteContainerContext(ctx, name)
+	})
+}
+
+//ListContainers list object containers, the call is retried on transient errors
+func (c *RetryClient) ListContainers() ([]string, error) {
+	return c.ListContainersContext(context.Background())
+}
+
+//ListContainersContext implements api.ClientAPIContext
+func (c *RetryClient) ListContainersContext(ctx context.Context) ([]string, error) {
+	var res []string
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.ListContainersContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//PutObject put an object into an object container
+func (c *RetryClient) PutObject(container string, obj api.Object) error {
+	return c.PutObjectContext(context.Background(), container, obj)
+}
+
+//PutObjectContext implements api.ClientAPIContext
+func (c *RetryClient) PutObjectContext(ctx context.Context, container string, obj api.Object) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		return c.clt.PutObjectContext(ctx, container, obj)
+	})
+}
+
+//UpdateObjectMetadata update an object into  object container
+func (c *RetryClient) UpdateObjectMetadata(container string, obj api.Object) error {
+	return c.UpdateObjectMetadataContext(context.Background(), container, obj)
+}
+
+//UpdateObjectMetadataContext implements api.ClientAPIContext
+func (c *RetryClient) UpdateObjectMetadataContext(ctx context.Context, container string, obj api.Object) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		return c.clt.UpdateObjectMetadataContext(ctx, container, obj)
+	})
+}
+
+//GetObject get  object content from an object container, the call is retried on transient errors
+func (c *RetryClient) GetObject(container string, name string, ranges []api.Range) (*api.Object, error) {
+	return c.GetObjectContext(context.Background(), container, name, ranges)
+}
+
+//GetObjectContext implements api.ClientAPIContext
+func (c *RetryClient) GetObjectContext(ctx context.Context, container string, name string, ranges []api.Range) (*api.Object, error) {
+	var res *api.Object
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.GetObjectContext(ctx, container, name, ranges)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//GetObjectMetadata get  object metadata from an object container, the call is retried on transient errors
+func (c *RetryClient) GetObjectMetadata(container string, name string) (*api.Object, error) {
+	return c.GetObjectMetadataContext(context.Background(), container, name)
+}
+
+//GetObjectMetadataContext implements api.ClientAPIContext
+func (c *RetryClient) GetObjectMetadataContext(ctx context.Context, container string, name string) (*api.Object, error) {
+	var res *api.Object
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.GetObjectMetadataContext(ctx, container, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//ListObjects list objects of a container, the call is retried on transient errors
+func (c *RetryClient) ListObjects(container string, filter api.ObjectFilter) ([]string, error) {
+	return c.ListObjectsContext(context.Background(), container, filter)
+}
+
+//ListObjectsContext implements api.ClientAPIContext
+func (c *RetryClient) ListObjectsContext(ctx context.Context, container string, filter api.ObjectFilter) ([]string, error) {
+	var res []string
+	err := c.retry(ctx, func(ctx context.Context) (err error) {
+		res, err = c.clt.ListObjectsContext(ctx, container, filter)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+//CopyObject copies an object
+func (c *RetryClient) CopyObject(containerSrc string, objectSrc string, objectDst string) error {
+	return c.CopyObjectContext(context.Background(), containerSrc, objectSrc, objectDst)
+}
+
+//CopyObjectContext implements api.ClientAPIContext
+func (c *RetryClient) CopyObjectContext(ctx context.Context, containerSrc string, objectSrc string, objectDst string) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		return c.clt.CopyObjectContext(ctx, containerSrc, objectSrc, objectDst)
+	})
+}
+
+//DeleteObject deleta an object from a container, the call is retried on transient errors
+func (c *RetryClient) DeleteObject(container string, object string) error {
+	return c.DeleteObjectContext(context.Background(), container, object)
+}
+
+//DeleteObjectContext implements api.ClientAPIContext
+func (c *RetryClient) DeleteObjectContext(ctx context.Context, container string, object string) error {
+	return c.retryDelete(ctx, func(ctx context.Context) error {
+		return c.clt.DeleteObjectContext(ctx, container, object)
+	})
+}
@@ -0,0 +1,179 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//flakyClient fails its calls with the errors of errs, in order, then succeeds
+type flakyClient struct {
+	api.ClientAPI
+	errs  []error
+	calls []time.Time
+}
+
+func (c *flakyClient) call() error {
+	c.calls = append(c.calls, time.Now())
+	if len(c.errs) == 0 {
+		return nil
+	}
+	err := c.errs[0]
+	c.errs = c.errs[1:]
+	return err
+}
+
+func (c *flakyClient) ListImages() ([]api.Image, error) {
+	return nil, c.call()
+}
+
+func (c *flakyClient) DeleteVM(id string) error {
+	return c.call()
+}
+
+func (c *flakyClient) CreateVM(request api.VMRequest) (*api.VM, error) {
+	err := c.call()
+	if err != nil {
+		return nil, err
+	}
+	return &api.VM{Name: request.Name}, nil
+}
+
+//check checks the number of calls
+func (c *flakyClient) check(t *testing.T, expected int) {
+	t.Helper()
+	if len(c.calls) != expected {
+		t.Fatalf("expected %d calls, got %d", expected, len(c.calls))
+	}
+}
+
+func unavailable() error {
+	return api.NewError(api.ErrUnavailable, nil, "Service unavailable")
+}
+
+func TestRetryBackoff(t *testing.T) {
+	flaky := &flakyClient{errs: []error{unavailable(), api.NewError(api.ErrTimeout, nil, "Request timeout")}}
+	clt := NewRetryClient(flaky, "retry-backoff", RetryOptions{MinDelay: 20 * time.Millisecond, MaxDelay: time.Second})
+	_, err := clt.ListImages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flaky.check(t, 3)
+	//the delay doubles after each attempt, the jitter changes it by 20% at most
+	for i, min := range []time.Duration{16 * time.Millisecond, 32 * time.Millisecond} {
+		if d := flaky.calls[i+1].Sub(flaky.calls[i]); d < min {
+			t.Fatalf("expected a delay of %s at least before attempt %d, got %s", min, i+2, d)
+		}
+	}
+
+	//permanent errors are not retried
+	flaky = &flakyClient{errs: []error{api.NewError(api.ErrInvalidRequest, nil, "Invalid request")}}
+	_, err = NewRetryClient(flaky, "retry-backoff", RetryOptions{MinDelay: time.Millisecond}).ListImages()
+	if !errors.Is(err, api.ErrInvalidRequest) {
+		t.Fatalf("expected an invalid request error, got %v", err)
+	}
+	flaky.check(t, 1)
+}
+
+func TestRetryAfter(t *testing.T) {
+	//the provider asks to retry after 10ms, the backoff would wait an hour
+	throttled := &api.Error{Message: "Too many requests", RetryAfter: 10 * time.Millisecond}
+	flaky := &flakyClient{errs: []error{throttled}}
+	clt := NewRetryClient(flaky, "retry-after", RetryOptions{MinDelay: time.Hour, MaxDelay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := clt.ListImagesContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flaky.check(t, 2)
+}
+
+func TestRetryDelete(t *testing.T) {
+	//the first attempt deleted the VM but its response was lost
+	notFound := ResourceNotFoundError("VM", "vm1")
+	flaky := &flakyClient{errs: []error{unavailable(), notFound}}
+	clt := NewRetryClient(flaky, "retry-delete", RetryOptions{MinDelay: time.Millisecond})
+	err := clt.DeleteVM("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flaky.check(t, 2)
+
+	//the VM did not exist
+	flaky = &flakyClient{errs: []error{notFound}}
+	err = NewRetryClient(flaky, "retry-delete", RetryOptions{MinDelay: time.Millisecond}).DeleteVM("vm1")
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	flaky.check(t, 1)
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	flaky := &flakyClient{errs: []error{unavailable()}}
+	clt := NewRetryClient(flaky, "retry-create", RetryOptions{MinDelay: time.Millisecond})
+	_, err := clt.CreateVM(api.VMRequest{Name: "vm1"})
+	if !errors.Is(err, api.ErrUnavailable) {
+		t.Fatalf("expected an unavailable error, got %v", err)
+	}
+	flaky.check(t, 1)
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	flaky := &flakyClient{errs: []error{unavailable(), unavailable(), unavailable(), unavailable()}}
+	clt := NewRetryClient(flaky, "retry-max", RetryOptions{MaxAttempts: 3, MinDelay: time.Millisecond})
+	_, err := clt.ListImages()
+	if !errors.Is(err, api.ErrUnavailable) {
+		t.Fatalf("expected an unavailable error, got %v", err)
+	}
+	flaky.check(t, 3)
+
+	//1 disables the retries
+	flaky = &flakyClient{errs: []error{unavailable()}}
+	_, err = NewRetryClient(flaky, "retry-max", RetryOptions{MaxAttempts: 1}).ListImages()
+	if err == nil {
+		t.Fatal("call retried")
+	}
+	flaky.check(t, 1)
+}
+
+func TestRetryOptionsJSON(t *testing.T) {
+	opts := RetryOptions{MaxAttempts: 5, MinDelay: 500 * time.Millisecond, MaxDelay: 90 * time.Second, Rate: 10}
+	b, err := json.Marshal(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"max_attempts":5,"rate":10,"min_delay":"500ms","max_delay":"1m30s"}` {
+		t.Fatalf("unexpected JSON %s", b)
+	}
+	read := RetryOptions{}
+	err = json.Unmarshal(b, &read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != opts {
+		t.Fatalf("expected %+v, got %+v", opts, read)
+	}
+
+	//delays written in nanoseconds
+	read = RetryOptions{}
+	err = json.Unmarshal([]byte(`{"max_attempts":2,"min_delay":1000000,"max_delay":"2s"}`), &read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.MaxAttempts != 2 || read.MinDelay != time.Millisecond || read.MaxDelay != 2*time.Second {
+		t.Fatalf("unexpected options %+v", read)
+	}
+	err = json.Unmarshal([]byte(`{"min_delay":"soon"}`), &read)
+	if !errors.Is(err, api.ErrInvalidRequest) {
+		t.Fatalf("expected an invalid request error, got %v", err)
+	}
+	b, err = json.Marshal(&RetryOptions{})
+	if err != nil || string(b) != "{}" {
+		t.Fatalf("expected {}, got %s (%v)", b, err)
+	}
+}
@@ -1,6 +1,8 @@
 package providers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -36,6 +38,11 @@ func (e ResourceNotFound) Error() string {
 	return fmt.Sprintf("Unable to find %s %s", e.ResourceType, e.Name)
 }
 
+//Is tells if target is api.ErrNotFound
+func (e ResourceNotFound) Is(target error) bool {
+	return target == api.ErrNotFound
+}
+
 //ResourceAlreadyExists resource already exists error
 type ResourceAlreadyExists struct {
 	ResourceError
@@ -55,9 +62,34 @@ func (e ResourceAlreadyExists) Error() string {
 	return fmt.Sprintf("%s %s alredy exists", e.ResourceType, e.Name)
 }
 
+//Is tells if target is api.ErrAlreadyExists
+func (e ResourceAlreadyExists) Is(target error) bool {
+	return target == api.ErrAlreadyExists
+}
+
 //Service Client High level service
 type Service struct {
 	api.ClientAPI
+	//Waiter waiter used to wait for resource states, a waiter with the default settings is used if nil
+	Waiter *Waiter
+}
+
+//Decorator is implemented by the clients decorating the client of a driver
+type Decorator interface {
+	//Unwrap returns the decorated client
+	Unwrap() api.ClientAPI
+}
+
+//Driver returns the client of the driver decorated by clt, the optional interfaces of the drivers are not
+//implemented by the decorators
+func Driver(clt api.ClientAPI) api.ClientAPI {
+	for {
+		d, ok := clt.(Decorator)
+		if !ok {
+			return clt
+		}
+		clt = d.Unwrap()
+	}
 }
 
 //FromClient contructs a Service instance from a ClientAPI
@@ -92,6 +124,23 @@ func (a ByRankDRF) Len() int           { return len(a) }
 func (a ByRankDRF) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByRankDRF) Less(i, j int) bool { return RankDRF(&a[i]) < RankDRF(&a[j]) }
 
+// ByCost implements sort.Interface for []VMTemplate based on the hourly price
+// Templates with an unknown price are placed after priced ones and sorted by Dominant Resource Fairness
+type ByCost []api.VMTemplate
+
+func (a ByCost) Len() int      { return len(a) }
+func (a ByCost) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a ByCost) Less(i, j int) bool {
+	pi, pj := a[i].Price, a[j].Price
+	if pi != nil && pj != nil && pi.HourlyAmount() != pj.HourlyAmount() {
+		return pi.HourlyAmount() < pj.HourlyAmount()
+	}
+	if (pi == nil) != (pj == nil) {
+		return pi != nil
+	}
+	return RankDRF(&a[i]) < RankDRF(&a[j])
+}
+
 //VMAccess a VM and the SSH Key Pair
 type VMAccess struct {
 	VM      *api.VM
@@ -124,108 +173,185 @@ type ServerRequest struct {
 	Gateway *VMAccess `json:"gateway,omitempty"`
 }
 
-//WaitVMState waits a vm achieve state
-func (srv *Service) WaitVMState(vmID string, state VMState.Enum, timeout time.Duration) (*api.VM, error) {
-	cout := make(chan int)
-	next := make(chan bool)
-	vmc := make(chan *api.VM)
+//contextAPI returns the context-aware API of the client
+func (srv *Service) contextAPI() api.ClientAPIContext {
+	return api.WithContext(srv.ClientAPI)
+}
 
-	go pollVM(srv, vmID, state, cout, next, vmc)
-	for {
-		select {
-		case res := <-cout:
-			if res == 0 {
-				//next <- false
-				return nil, fmt.Errorf("Error getting vm state")
-			}
-			if res == 1 {
-				//next <- false
-				return <-vmc, nil
-			}
-			if res == 2 {
-				next <- true
-			}
-		case <-time.After(timeout):
-			next <- false
-			return nil, &api.TimeoutError{Message: "Wait vm state timeout"}
-		}
+//waiter returns the waiter of the service
+func (srv *Service) waiter() *Waiter {
+	if srv.Waiter != nil {
+		return srv.Waiter
 	}
+	return NewWaiter()
 }
 
-func pollVM(client api.ClientAPI, vmID string, state VMState.Enum, cout chan int, next chan bool, vmc chan *api.VM) {
-	for {
+//withTimeout calls f with a context bounded by timeout, reaching the timeout is reported with a TimeoutError
+func withTimeout(timeout time.Duration, msg string, f func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := f(ctx)
+	if err == context.DeadlineExceeded {
+		return &api.TimeoutError{Message: msg}
+	}
+	return err
+}
 
-		vm, err := client.GetVM(vmID)
+//WaitVMState waits a vm achieve state
+func (srv *Service) WaitVMState(vmID string, state VMState.Enum, timeout time.Duration) (*api.VM, error) {
+	var vm *api.VM
+	err := withTimeout(timeout, "Wait vm state timeout", func(ctx context.Context) (err error) {
+		vm, err = srv.WaitVMStateContext(ctx, vmID, state)
+		return err
+	})
+	return vm, err
+}
+
+//WaitVMStateContext waits a vm achieve state until ctx is done
+//The wait fails with a StateError if the VM reaches the ERROR state and with an api.ErrNotFound error if the VM disappears
+func (srv *Service) WaitVMStateContext(ctx context.Context, vmID string, state VMState.Enum) (*api.VM, error) {
+	clt := srv.contextAPI()
+	var vm *api.VM
+	err := srv.waiter().Wait(ctx, func(ctx context.Context) (bool, string, error) {
+		v, err := clt.GetVMContext(ctx, vmID)
+		if errors.Is(err, api.ErrNotFound) {
+			return false, "", Permanent(err)
+		}
 		if err != nil {
-			fmt.Println(err)
-			cout <- 0
-			return
+			return false, "", err
 		}
-		if vm.State == state {
-			cout <- 1
-			vmc <- vm
-			return
+		vm = v
+		if v.State == state {
+			return true, v.State.String(), nil
 		}
-		cout <- 2
-		if !<-next {
-			return
+		if v.State == VMState.ERROR {
+			return false, v.State.String(), StateError{ResourceType: "VM", ID: vmID, State: v.State.String()}
 		}
+		return false, v.State.String(), nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return vm, nil
 }
 
-//WaitVolumeState waits a vm achieve state
-func (srv *Service) WaitVolumeState(volumeID string, state VolumeState.Enum, timeout time.Duration) (*api.Volume, error) {
-	cout := make(chan int)
-	next := make(chan bool)
-	vc := make(chan *api.Volume)
+//WaitVMDeleted waits a vm to be deleted
+func (srv *Service) WaitVMDeleted(vmID string, timeout time.Duration) error {
+	return withTimeout(timeout, "Wait vm deletion timeout", func(ctx context.Context) error {
+		return srv.WaitVMDeletedContext(ctx, vmID)
+	})
+}
 
-	go pollVolume(srv, volumeID, state, cout, next, vc)
-	for {
-		select {
-		case res := <-cout:
-			if res == 0 {
-				//next <- false
-				return nil, fmt.Errorf("Error getting vm state")
-			}
-			if res == 1 {
-				//next <- false
-				return <-vc, nil
+//WaitVMDeletedContext waits a vm to be deleted until ctx is done
+func (srv *Service) WaitVMDeletedContext(ctx context.Context, vmID string) error {
+	clt := srv.contextAPI()
+	return srv.waiter().Wait(ctx, func(ctx context.Context) (bool, string, error) {
+		vm, err := clt.GetVMContext(ctx, vmID)
+		if err == nil {
+			if vm.State == VMState.ERROR {
+				return false, vm.State.String(), StateError{ResourceType: "VM", ID: vmID, State: vm.State.String()}
 			}
-			if res == 2 {
-				next <- true
+			return false, vm.State.String(), nil
+		}
+		if errors.Is(err, api.ErrNotFound) {
+			return true, "deleted", nil
+		}
+		//drivers do not all report missing VMs with ResourceNotFound
+		vms, lerr := clt.ListVMsContext(ctx)
+		if lerr != nil {
+			return false, "", err
+		}
+		for _, v := range vms {
+			if v.ID == vmID {
+				return false, v.State.String(), err
 			}
-		case <-time.After(timeout):
-			next <- false
-			return nil, &api.TimeoutError{Message: "Wait vm state timeout"}
 		}
-	}
+		return true, "deleted", nil
+	})
 }
 
-func pollVolume(client api.ClientAPI, volumeID string, state VolumeState.Enum, cout chan int, next chan bool, vmc chan *api.Volume) {
-	for {
+//WaitVolumeState waits a vm achieve state
+func (srv *Service) WaitVolumeState(volumeID string, state VolumeState.Enum, timeout time.Duration) (*api.Volume, error) {
+	var v *api.Volume
+	err := withTimeout(timeout, "Wait volume state timeout", func(ctx context.Context) (err error) {
+		v, err = srv.WaitVolumeStateContext(ctx, volumeID, state)
+		return err
+	})
+	return v, err
+}
 
-		v, err := client.GetVolume(volumeID)
+//WaitVolumeStateContext waits a volume achieve state until ctx is done
+//The wait fails with a StateError if the volume reaches the ERROR state and with an api.ErrNotFound error if the volume disappears
+func (srv *Service) WaitVolumeStateContext(ctx context.Context, volumeID string, state VolumeState.Enum) (*api.Volume, error) {
+	clt := srv.contextAPI()
+	var volume *api.Volume
+	err := srv.waiter().Wait(ctx, func(ctx context.Context) (bool, string, error) {
+		v, err := clt.GetVolumeContext(ctx, volumeID)
+		if errors.Is(err, api.ErrNotFound) {
+			return false, "", Permanent(err)
+		}
 		if err != nil {
-
-			cout <- 0
-			return
+			return false, "", err
 		}
+		volume = v
 		if v.State == state {
-			cout <- 1
-			vmc <- v
-			return
+			return true, v.State.String(), nil
 		}
-		cout <- 2
-		if !<-next {
-			return
+		if v.State == VolumeState.ERROR {
+			return false, v.State.String(), StateError{ResourceType: "Volume", ID: volumeID, State: v.State.String()}
 		}
+		return false, v.State.String(), nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return volume, nil
+}
+
+//WaitVolumeDeleted waits a volume to be deleted
+func (srv *Service) WaitVolumeDeleted(volumeID string, timeout time.Duration) error {
+	return withTimeout(timeout, "Wait volume deletion timeout", func(ctx context.Context) error {
+		return srv.WaitVolumeDeletedContext(ctx, volumeID)
+	})
+}
+
+//WaitVolumeDeletedContext waits a volume to be deleted until ctx is done
+func (srv *Service) WaitVolumeDeletedContext(ctx context.Context, volumeID string) error {
+	clt := srv.contextAPI()
+	return srv.waiter().Wait(ctx, func(ctx context.Context) (bool, string, error) {
+		v, err := clt.GetVolumeContext(ctx, volumeID)
+		if err == nil {
+			if v.State == VolumeState.ERROR {
+				return false, v.State.String(), StateError{ResourceType: "Volume", ID: volumeID, State: v.State.String()}
+			}
+			return false, v.State.String(), nil
+		}
+		if errors.Is(err, api.ErrNotFound) {
+			return true, "deleted", nil
+		}
+		//drivers do not all report missing volumes with ResourceNotFound
+		volumes, lerr := clt.ListVolumesContext(ctx)
+		if lerr != nil {
+			return false, "", err
+		}
+		for _, v := range volumes {
+			if v.ID == volumeID {
+				return false, v.State.String(), err
+			}
+		}
+		return true, "deleted", nil
+	})
 }
 
 //SelectTemplatesBySize select templates satisfying sizing requirements
 //returned list is ordered by size fitting
 func (srv *Service) SelectTemplatesBySize(sizing api.SizingRequirements) ([]api.VMTemplate, error) {
-	tpls, err := srv.ListTemplates()
+	return srv.SelectTemplatesBySizeContext(context.Background(), sizing)
+}
+
+//SelectTemplatesBySizeContext is like SelectTemplatesBySize but includes a context
+func (srv *Service) SelectTemplatesBySizeContext(ctx context.Context, sizing api.SizingRequirements) ([]api.VMTemplate, error) {
+	tpls, err := srv.contextAPI().ListTemplatesContext(ctx)
 	var selectedTpls []api.VMTemplate
 	if err != nil {
 		return nil, err
@@ -239,6 +365,23 @@ func (srv *Service) SelectTemplatesBySize(sizing api.SizingRequirements) ([]api.
 	return selectedTpls, nil
 }
 
+//SelectTemplatesByCost select templates satisfying sizing requirements
+//returned list is ordered by hourly price, the cheapest first
+//templates without price are ordered by size fitting after priced ones
+func (srv *Service) SelectTemplatesByCost(sizing api.SizingRequirements) ([]api.VMTemplate, error) {
+	return srv.SelectTemplatesByCostContext(context.Background(), sizing)
+}
+
+//SelectTemplatesByCostContext is like SelectTemplatesByCost but includes a context
+func (srv *Service) SelectTemplatesByCostContext(ctx context.Context, sizing api.SizingRequirements) ([]api.VMTemplate, error) {
+	tpls, err := srv.SelectTemplatesBySizeContext(ctx, sizing)
+	if err != nil {
+		return nil, err
+	}
+	sort.Stable(ByCost(tpls))
+	return tpls, nil
+}
+
 func matchScore(fields []string, s2 string) float64 {
 	score := 0.0
 	index := 0
@@ -257,8 +400,12 @@ func matchScore(fields []string, s2 string) float64 {
 //SearchImage search an image corresponding to OS Name
 //use the Jaro Winkler algorithm to match os name and image name
 func (srv *Service) SearchImage(osname string) (*api.Image, error) {
+	return srv.SearchImageContext(context.Background(), osname)
+}
 
-	imgs, err := srv.ListImages()
+//SearchImageContext is like SearchImage but includes a context
+func (srv *Service) SearchImageContext(ctx context.Context, osname string) (*api.Image, error) {
+	imgs, err := srv.contextAPI().ListImagesContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -273,13 +420,7 @@ func (srv *Service) SearchImage(osname string) (*api.Image, error) {
 			maxscore = score
 			maxi = i
 		}
-		if score > 1 {
-			fmt.Println(img.Name, ">>", score)
-		}
-
 	}
-	fmt.Println(fields, len(fields))
-	fmt.Println(len(fields))
 	if maxscore < 1 || maxi < 0 || len(imgs) == 0 {
 		return nil, fmt.Errorf("Unable to found and image matching %s", osname)
 	}
@@ -288,7 +429,12 @@ func (srv *Service) SearchImage(osname string) (*api.Image, error) {
 
 //GetNetworkByName returns the network named name
 func (srv *Service) GetNetworkByName(name string) (*api.Network, error) {
-	nets, err := srv.ListNetworksByName()
+	return srv.GetNetworkByNameContext(context.Background(), name)
+}
+
+//GetNetworkByNameContext is like GetNetworkByName but includes a context
+func (srv *Service) GetNetworkByNameContext(ctx context.Context, name string) (*api.Network, error) {
+	nets, err := srv.ListNetworksByNameContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -302,7 +448,12 @@ func (srv *Service) GetNetworkByName(name string) (*api.Network, error) {
 
 //ListNetworksByName returns network list
 func (srv *Service) ListNetworksByName() (map[string]api.Network, error) {
-	nets, err := srv.ListNetworks()
+	return srv.ListNetworksByNameContext(context.Background())
+}
+
+//ListNetworksByNameContext is like ListNetworksByName but includes a context
+func (srv *Service) ListNetworksByNameContext(ctx context.Context) (map[string]api.Network, error) {
+	nets, err := srv.contextAPI().ListNetworksContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -316,17 +467,24 @@ func (srv *Service) ListNetworksByName() (map[string]api.Network, error) {
 
 //CreateVMWithKeyPair creates a VM
 func (srv *Service) CreateVMWithKeyPair(request api.VMRequest) (*api.VM, *api.KeyPair, error) {
-	_, err := srv.GetVMByName(request.Name)
+	return srv.CreateVMWithKeyPairContext(context.Background(), request)
+}
+
+//CreateVMWithKeyPairContext is like CreateVMWithKeyPair but includes a context
+func (srv *Service) CreateVMWithKeyPairContext(ctx context.Context, request api.VMRequest) (*api.VM, *api.KeyPair, error) {
+	clt := srv.contextAPI()
+	_, err := srv.GetVMByNameContext(ctx, request.Name)
 	if err == nil {
 		return nil, nil, ResourceAlreadyExistsError("VM", request.Name)
 	}
 
 	//Create temporary key pair
 	kpName := uuid.NewV4().String()
-	kp, err := srv.CreateKeyPair(kpName)
+	kp, err := clt.CreateKeyPairContext(ctx, kpName)
 	if err != nil {
 		return nil, nil, err
 	}
+	//the key pair is deleted even if ctx is done
 	defer srv.DeleteKeyPair(kpName)
 
 	//Create VM
@@ -338,7 +496,7 @@ func (srv *Service) CreateVMWithKeyPair(request api.VMRequest) (*api.VM, *api.Ke
 		NetworkIDs: request.NetworkIDs,
 		TemplateID: request.TemplateID,
 	}
-	vm, err := srv.CreateVM(vmReq)
+	vm, err := clt.CreateVMContext(ctx, vmReq)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -347,7 +505,12 @@ func (srv *Service) CreateVMWithKeyPair(request api.VMRequest) (*api.VM, *api.Ke
 
 //ListVMsByName list VMs by name
 func (srv *Service) ListVMsByName() (map[string]api.VM, error) {
-	vms, err := srv.ListVMs()
+	return srv.ListVMsByNameContext(context.Background())
+}
+
+//ListVMsByNameContext is like ListVMsByName but includes a context
+func (srv *Service) ListVMsByNameContext(ctx context.Context) (map[string]api.VM, error) {
+	vms, err := srv.contextAPI().ListVMsContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -360,7 +523,12 @@ func (srv *Service) ListVMsByName() (map[string]api.VM, error) {
 
 //GetVMByName returns VM corresponding to name
 func (srv *Service) GetVMByName(name string) (*api.VM, error) {
-	vms, err := srv.ListVMsByName()
+	return srv.GetVMByNameContext(context.Background(), name)
+}
+
+//GetVMByNameContext is like GetVMByName but includes a context
+func (srv *Service) GetVMByNameContext(ctx context.Context, name string) (*api.VM, error) {
+	vms, err := srv.ListVMsByNameContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,71 @@
+package providers
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//template returns a template of cores cores and ram GB of RAM whose price is price, the template has no price if
+//price is nil
+func template(id string, cores int, ram float32, price *api.Price) api.VMTemplate {
+	return api.VMTemplate{
+		ID:     id,
+		VMSize: api.VMSize{Cores: cores, RAMSize: ram, DiskSize: 10},
+		Price:  price,
+	}
+}
+
+func hourly(amount float64) *api.Price {
+	return &api.Price{Amount: amount, Currency: "EUR", Unit: api.PriceUnitHour}
+}
+
+func monthly(amount float64) *api.Price {
+	return &api.Price{Amount: amount, Currency: "EUR", Unit: api.PriceUnitMonth}
+}
+
+func TestByCost(t *testing.T) {
+	cases := []struct {
+		name      string
+		templates []api.VMTemplate
+		expected  []string
+	}{
+		{
+			name:      "priced",
+			templates: []api.VMTemplate{template("b", 2, 4, hourly(0.2)), template("a", 4, 16, hourly(0.1))},
+			expected:  []string{"a", "b"},
+		},
+		{
+			name:      "monthly prices",
+			templates: []api.VMTemplate{template("b", 1, 2, hourly(0.1)), template("a", 1, 2, monthly(0.05*api.HoursPerMonth))},
+			expected:  []string{"a", "b"},
+		},
+		{
+			name:      "unpriced after priced",
+			templates: []api.VMTemplate{template("c", 1, 2, nil), template("b", 8, 32, hourly(2)), template("a", 2, 4, hourly(0.1))},
+			expected:  []string{"a", "b", "c"},
+		},
+		{
+			name:      "unpriced by DRF rank",
+			templates: []api.VMTemplate{template("b", 4, 8, nil), template("a", 2, 4, nil), template("c", 4, 16, nil)},
+			expected:  []string{"a", "b", "c"},
+		},
+		{
+			name:      "same price by DRF rank",
+			templates: []api.VMTemplate{template("b", 2, 8, hourly(0.1)), template("a", 2, 4, hourly(0.1))},
+			expected:  []string{"a", "b"},
+		},
+	}
+	for _, c := range cases {
+		sort.Stable(ByCost(c.templates))
+		ids := []string{}
+		for _, tpl := range c.templates {
+			ids = append(ids, tpl.ID)
+		}
+		if !reflect.DeepEqual(ids, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, ids)
+		}
+	}
+}
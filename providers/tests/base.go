@@ -154,7 +154,7 @@ func (tester *ClientTester) CreateVM(t *testing.T, name string, networkID string
 	return vm
 }
 
-//Networks test
+//Networks test, the gateways are not connected (see NetworkSSH)
 func (tester *ClientTester) Networks(t *testing.T) {
 	network1 := tester.CreateNetwork(t, "test_network_1")
 	defer tester.Service.DeleteNetwork(network1.ID)
@@ -164,24 +164,12 @@ func (tester *ClientTester) Networks(t *testing.T) {
 	assert.True(t, vm.AccessIPv4 != "" || vm.AccessIPv6 != "")
 	assert.NotEmpty(t, vm.PrivateKey)
 	assert.Empty(t, vm.GatewayID)
-	fmt.Println(vm.AccessIPv4)
-	fmt.Println(vm.PrivateKey)
 	ssh, err := tester.Service.GetSSHConfig(vm.ID)
 	assert.Nil(t, err)
-
-	//Waits sshd deamon is up
-	time.Sleep(30 * time.Second)
-	cmd, err := ssh.Command("whoami")
-	assert.Nil(t, err)
-	out, err := cmd.Output()
-	assert.Nil(t, err)
-	content := strings.Trim(string(out), "\n")
-	assert.Equal(t, api.DefaultUser, content)
+	assert.NotEmpty(t, ssh.PrivateKey)
 
 	network2 := tester.CreateNetwork(t, "test_network_2")
 
-	defer tester.Service.DeleteNetwork(network2.ID)
-
 	nets, err := tester.Service.ListNetworks()
 	assert.Nil(t, err)
 	assert.Equal(t, 2, len(nets))
@@ -205,46 +193,41 @@ func (tester *ClientTester) Networks(t *testing.T) {
 	assert.Equal(t, n1.IPVersion, network1.IPVersion)
 	assert.Equal(t, n1.Name, network1.Name)
 
-	//network := tester.CreateNetwork(t, "test_network_1")
-
-	// network, err := tester.Service.CreateNetwork("test_network")
-	// assert.Nil(t, err)
-	// defer tester.Service.DeleteNetwork(network.ID)
-	// network2, err := tester.Service.GetNetwork(network.ID)
-	// assert.Nil(t, err)
-	// assert.Equal(t, network2.ID, network.ID)
-	// assert.Equal(t, network2.Name, network2.Name)
-	// assert.Empty(t, network.Subnets)
-	// assert.Empty(t, network2.Subnets)
+	err = tester.Service.DeleteNetwork(network2.ID)
+	assert.Nil(t, err)
+	nets, err = tester.Service.ListNetworks()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(nets))
+	_, err = tester.Service.GetNetwork(network2.ID)
+	assert.Error(t, err)
 }
 
-//VMs test
-func (tester *ClientTester) VMs(t *testing.T) {
+//NetworkSSH test, connects the gateway of a network using SSH
+func (tester *ClientTester) NetworkSSH(t *testing.T) {
 	network := tester.CreateNetwork(t, "test_network")
 	defer tester.Service.DeleteNetwork(network.ID)
-	vm := tester.CreateVM(t, "vm1", network.ID)
+
+	ssh, err := tester.Service.GetSSHConfig(network.GatewayID)
+	assert.Nil(t, err)
+
+	//Waits sshd deamon is up
 	time.Sleep(30 * time.Second)
-	ssh, err := tester.Service.GetSSHConfig(vm.ID)
 	cmd, err := ssh.Command("whoami")
 	assert.Nil(t, err)
 	out, err := cmd.Output()
 	assert.Nil(t, err)
 	content := strings.Trim(string(out), "\n")
 	assert.Equal(t, api.DefaultUser, content)
+}
 
-	cmd, err = ssh.Command("ping -c1 8.8.8.8")
-	fmt.Println(ssh.PrivateKey)
-	assert.Nil(t, err)
-	err = cmd.Run()
-	assert.Nil(t, err)
-
-	cmd, err = ssh.Command("ping -c1 www.google.fr")
-	fmt.Println(ssh.PrivateKey)
-	assert.Nil(t, err)
-	err = cmd.Run()
-	assert.Nil(t, err)
+//VMs test, the VMs are not connected (see VMSSH)
+func (tester *ClientTester) VMs(t *testing.T) {
+	network := tester.CreateNetwork(t, "test_network")
+	defer tester.Service.DeleteNetwork(network.ID)
+	vm := tester.CreateVM(t, "vm1", network.ID)
 
 	vms, err := tester.Service.ListVMs()
+	assert.Nil(t, err)
 	assert.Equal(t, 2, len(vms))
 	found := 0
 	for _, v := range vms {
@@ -277,8 +260,41 @@ func (tester *ClientTester) VMs(t *testing.T) {
 		assert.Equal(t, addr, vm.PrivateIPsV6[i])
 	}
 
-	tester.Service.DeleteVM(vm.ID)
+	ssh, err := tester.Service.GetSSHConfig(vm.ID)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, ssh.PrivateKey)
+
+	err = tester.Service.DeleteVM(vm.ID)
+	assert.Nil(t, err)
+}
+
+//VMSSH test, connects a VM through the gateway of its network and checks it reaches internet
+func (tester *ClientTester) VMSSH(t *testing.T) {
+	network := tester.CreateNetwork(t, "test_network")
+	defer tester.Service.DeleteNetwork(network.ID)
+	vm := tester.CreateVM(t, "vm1", network.ID)
+	defer tester.Service.DeleteVM(vm.ID)
+
+	//Waits sshd deamon is up
+	time.Sleep(30 * time.Second)
+	ssh, err := tester.Service.GetSSHConfig(vm.ID)
+	assert.Nil(t, err)
+	cmd, err := ssh.Command("whoami")
+	assert.Nil(t, err)
+	out, err := cmd.Output()
+	assert.Nil(t, err)
+	content := strings.Trim(string(out), "\n")
+	assert.Equal(t, api.DefaultUser, content)
 
+	cmd, err = ssh.Command("ping -c1 8.8.8.8")
+	assert.Nil(t, err)
+	err = cmd.Run()
+	assert.Nil(t, err)
+
+	cmd, err = ssh.Command("ping -c1 www.google.fr")
+	assert.Nil(t, err)
+	err = cmd.Run()
+	assert.Nil(t, err)
 }
 
 //StartStopVM test
@@ -352,6 +368,36 @@ func (tester *ClientTester) Volume(t *testing.T) {
 
 }
 
+//VolumeUpdate test
+func (tester *ClientTester) VolumeUpdate(t *testing.T) {
+	v, err := tester.Service.CreateVolume(api.VolumeRequest{
+		Name:  "test_volume",
+		Size:  500,
+		Speed: VolumeSpeed.HDD,
+	})
+	assert.Nil(t, err)
+	tester.Service.WaitVolumeState(v.ID, VolumeState.AVAILABLE, 40*time.Second)
+	defer tester.Service.DeleteVolume(v.ID)
+
+	name := "test_volume_renamed"
+	size := 600
+	speed := VolumeSpeed.SSD
+	v2, err := tester.Service.UpdateVolume(v.ID, api.VolumeUpdateRequest{Name: &name, Size: &size, Speed: &speed})
+	assert.Nil(t, err)
+	assert.Equal(t, name, v2.Name)
+	assert.Equal(t, size, v2.Size)
+	assert.Equal(t, speed, v2.Speed)
+	v3, err := tester.Service.GetVolume(v.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, name, v3.Name)
+	assert.Equal(t, size, v3.Size)
+
+	//volumes cannot shrink
+	size = 100
+	_, err = tester.Service.UpdateVolume(v.ID, api.VolumeUpdateRequest{Size: &size})
+	assert.Error(t, err)
+}
+
 //VolumeAttachment test
 func (tester *ClientTester) VolumeAttachment(t *testing.T) {
 	net := tester.CreateNetwork(t, "test_network")
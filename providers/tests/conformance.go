@@ -0,0 +1,442 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers"
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//Factory creates the client under test
+//It is called once per scenario so each scenario starts from a fresh client
+type Factory func() (api.ClientAPI, error)
+
+//Scenario is a conformance scenario run against a driver
+type Scenario struct {
+	//Name of the scenario
+	Name string
+	//Operations ClientAPI operations exercised by the scenario
+	Operations []string
+	//Remote is true if the scenario needs to connect VMs using SSH
+	Remote bool
+	//Run runs the scenario
+	Run func(tester *ClientTester, t *testing.T)
+}
+
+//Scenarios is the list of all conformance scenarios
+var Scenarios = []Scenario{
+	{
+		Name:       "ListImages",
+		Operations: []string{"ListImages"},
+		Run:        (*ClientTester).ListImages,
+	},
+	{
+		Name:       "ListVMTemplates",
+		Operations: []string{"ListTemplates"},
+		Run:        (*ClientTester).ListVMTemplates,
+	},
+	{
+		Name:       "CreateKeyPair",
+		Operations: []string{"CreateKeyPair", "DeleteKeyPair"},
+		Run:        (*ClientTester).CreateKeyPair,
+	},
+	{
+		Name:       "GetKeyPair",
+		Operations: []string{"CreateKeyPair", "GetKeyPair", "DeleteKeyPair"},
+		Run:        (*ClientTester).GetKeyPair,
+	},
+	{
+		Name:       "ListKeyPairs",
+		Operations: []string{"CreateKeyPair", "ListKeyPairs", "DeleteKeyPair"},
+		Run:        (*ClientTester).ListKeyPairs,
+	},
+	{
+		Name:       "Networks",
+		Operations: []string{"CreateNetwork", "GetNetwork", "ListNetworks", "DeleteNetwork", "GetVM", "GetSSHConfig"},
+		Run:        (*ClientTester).Networks,
+	},
+	{
+		Name:       "NetworkSSH",
+		Operations: []string{"CreateNetwork", "GetSSHConfig"},
+		Remote:     true,
+		Run:        (*ClientTester).NetworkSSH,
+	},
+	{
+		Name:       "VMs",
+		Operations: []string{"CreateVM", "GetVM", "ListVMs", "DeleteVM", "GetSSHConfig"},
+		Run:        (*ClientTester).VMs,
+	},
+	{
+		Name:       "VMSSH",
+		Operations: []string{"CreateVM", "GetSSHConfig"},
+		Remote:     true,
+		Run:        (*ClientTester).VMSSH,
+	},
+	{
+		Name:       "StartStopVM",
+		Operations: []string{"StartVM", "StopVM"},
+		Run:        (*ClientTester).StartStopVM,
+	},
+	{
+		Name:       "Volume",
+		Operations: []string{"CreateVolume", "GetVolume", "ListVolumes", "DeleteVolume"},
+		Run:        (*ClientTester).Volume,
+	},
+	{
+		Name:       "VolumeUpdate",
+		Operations: []string{"CreateVolume", "UpdateVolume", "DeleteVolume"},
+		Run:        (*ClientTester).VolumeUpdate,
+	},
+	{
+		Name:       "VolumeAttachment",
+		Operations: []string{"CreateVolumeAttachment", "GetVolumeAttachment", "ListVolumeAttachments", "DeleteVolumeAttachment"},
+		Run:        (*ClientTester).VolumeAttachment,
+	},
+	{
+		Name:       "Containers",
+		Operations: []string{"CreateContainer", "ListContainers", "DeleteContainer"},
+		Run:        (*ClientTester).Containers,
+	},
+	{
+		Name:       "Objects",
+		Operations: []string{"PutObject", "GetObject", "GetObjectMetadata", "DeleteObject"},
+		Run:        (*ClientTester).Objects,
+	},
+}
+
+//Status status of a scenario run
+type Status string
+
+const (
+	//Passed the scenario succeeded
+	Passed Status = "pass"
+	//Failed the scenario failed
+	Failed Status = "fail"
+	//Skipped the scenario was not run, the scenarios needing SSH access are skipped when Conformance.SkipRemote is set
+	Skipped Status = "skip"
+	//Unsupported the scenario was not run because the driver is known to fail it, see Conformance.Unsupported
+	Unsupported Status = "unsupported"
+)
+
+//Result result of a scenario run
+type Result struct {
+	Scenario   string   `json:"scenario"`
+	Operations []string `json:"operations,omitempty"`
+	Status     Status   `json:"status"`
+	Duration   float64  `json:"duration_seconds"`
+	Message    string   `json:"message,omitempty"`
+}
+
+//Report conformance report of a provider
+type Report struct {
+	Provider string    `json:"provider"`
+	Date     time.Time `json:"date"`
+	Results  []Result  `json:"results"`
+}
+
+//Conformance runs conformance scenarios against a driver
+//
+//Use it from a go test of the driver package:
+//	func TestConformance(t *testing.T) {
+//		c := tests.Conformance{Provider: "ovh", Factory: newClient}
+//		report := c.Run(t)
+//		report.Save(os.Getenv("GPAC_CONFORMANCE_DIR"))
+//	}
+type Conformance struct {
+	//Provider name of the provider used in reports
+	Provider string
+	//Factory creates the client under test
+	Factory Factory
+	//Scenarios to run, all Scenarios are run if empty
+	Scenarios []Scenario
+	//Unsupported known gaps of the driver, the reason why the driver fails indexed by scenario name
+	//The scenarios are not run and are reported as unsupported with their reason, they are not hidden as skipped
+	Unsupported map[string]string
+	//SkipRemote skips scenarios needing SSH access to the VMs
+	SkipRemote bool
+}
+
+//Run runs the scenarios as sub tests of t and returns the conformance report
+//Resources left behind by a scenario are deleted before the next one starts
+func (c *Conformance) Run(t *testing.T) *Report {
+	scenarios := c.Scenarios
+	if len(scenarios) == 0 {
+		scenarios = Scenarios
+	}
+	report := Report{
+		Provider: c.Provider,
+		Date:     time.Now().UTC(),
+	}
+	for _, s := range scenarios {
+		res := Result{
+			Scenario:   s.Name,
+			Operations: s.Operations,
+			Status:     Skipped,
+		}
+		if reason, ok := c.Unsupported[s.Name]; ok {
+			res.Status = Unsupported
+			res.Message = reason
+			report.Results = append(report.Results, res)
+			continue
+		}
+		if s.Remote && c.SkipRemote {
+			report.Results = append(report.Results, res)
+			continue
+		}
+		scenario := s
+		t.Run(scenario.Name, func(t *testing.T) {
+			clt, err := c.Factory()
+			if err != nil {
+				res.Status = Failed
+				res.Message = fmt.Sprintf("Unable to create client: %s", err.Error())
+				t.Fatal(res.Message)
+			}
+			before := snapshot(clt)
+			start := time.Now()
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("panic: %v", r)
+					res.Message = fmt.Sprintf("panic: %v", r)
+				}
+				res.Duration = time.Since(start).Seconds()
+				res.Status = Passed
+				if t.Failed() {
+					res.Status = Failed
+				}
+				for _, err := range cleanup(clt, before) {
+					t.Logf("cleanup: %s", err.Error())
+				}
+			}()
+			tester := ClientTester{Service: *providers.FromClient(clt)}
+			scenario.Run(&tester, t)
+		})
+		report.Results = append(report.Results, res)
+	}
+	return &report
+}
+
+//resources identifiers of the resources existing on a provider
+type resources struct {
+	keyPairs   map[string]bool
+	networks   map[string]bool
+	vms        map[string]bool
+	volumes    map[string]bool
+	containers map[string]bool
+}
+
+//snapshot lists the resources existing on the provider
+func snapshot(clt api.ClientAPI) resources {
+	res := resources{
+		keyPairs:   map[string]bool{},
+		networks:   map[string]bool{},
+		vms:        map[string]bool{},
+		volumes:    map[string]bool{},
+		containers: map[string]bool{},
+	}
+	kps, _ := clt.ListKeyPairs()
+	for _, kp := range kps {
+		res.keyPairs[kp.ID] = true
+	}
+	nets, _ := clt.ListNetworks()
+	for _, n := range nets {
+		res.networks[n.ID] = true
+	}
+	vms, _ := clt.ListVMs()
+	for _, vm := range vms {
+		res.vms[vm.ID] = true
+	}
+	vols, _ := clt.ListVolumes()
+	for _, v := range vols {
+		res.volumes[v.ID] = true
+	}
+	cs, _ := clt.ListContainers()
+	for _, c := range cs {
+		res.containers[c] = true
+	}
+	return res
+}
+
+//cleanup deletes the resources created since before was taken
+func cleanup(clt api.ClientAPI, before resources) []error {
+	var errs []error
+	after := snapshot(clt)
+	gateways := map[string]bool{}
+	nets, _ := clt.ListNetworks()
+	for _, n := range nets {
+		gateways[n.GatewayID] = true
+	}
+	for id := range after.vms {
+		if before.vms[id] {
+			continue
+		}
+		vas, _ := clt.ListVolumeAttachments(id)
+		for _, va := range vas {
+			if err := clt.DeleteVolumeAttachment(id, va.ID); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if gateways[id] {
+			continue
+		}
+		if err := clt.DeleteVM(id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for id := range after.networks {
+		if !before.networks[id] {
+			if err := clt.DeleteNetwork(id); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for id := range after.volumes {
+		if !before.volumes[id] {
+			if err := clt.DeleteVolume(id); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for id := range after.keyPairs {
+		if !before.keyPairs[id] {
+			if err := clt.DeleteKeyPair(id); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for c := range after.containers {
+		if before.containers[c] {
+			continue
+		}
+		objs, _ := clt.ListObjects(c, api.ObjectFilter{})
+		for _, o := range objs {
+			clt.DeleteObject(c, o)
+		}
+		if err := clt.DeleteContainer(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+//Passed returns true if no scenario failed, unsupported scenarios are not failures
+func (r *Report) Passed() bool {
+	for _, res := range r.Results {
+		if res.Status == Failed {
+			return false
+		}
+	}
+	return true
+}
+
+//WriteJSON writes the report in JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+//Save writes the report as <provider>.json in dir and refreshes the markdown matrix README.md
+//built from all the reports of dir
+//Nothing is written if dir is empty
+func (r *Report) Save(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	var buffer bytes.Buffer
+	err = r.WriteJSON(&buffer)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, r.Provider+".json"), buffer.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+	reports, err := LoadReports(dir)
+	if err != nil {
+		return err
+	}
+	buffer.Reset()
+	err = WriteMarkdown(&buffer, reports...)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "README.md"), buffer.Bytes(), 0644)
+}
+
+//LoadReports loads the JSON reports stored in dir
+func LoadReports(dir string) ([]*Report, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var reports []*Report
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		r := Report{}
+		err = json.Unmarshal(b, &r)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid report %s: %s", f, err.Error())
+		}
+		reports = append(reports, &r)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Provider < reports[j].Provider })
+	return reports, nil
+}
+
+//WriteMarkdown writes a markdown matrix of the scenario results, one column per provider
+func WriteMarkdown(w io.Writer, reports ...*Report) error {
+	var scenarios []string
+	seen := map[string]bool{}
+	results := map[string]map[string]Status{}
+	for _, r := range reports {
+		results[r.Provider] = map[string]Status{}
+		for _, res := range r.Results {
+			if !seen[res.Scenario] {
+				seen[res.Scenario] = true
+				scenarios = append(scenarios, res.Scenario)
+			}
+			results[r.Provider][res.Scenario] = res.Status
+		}
+	}
+	symbols := map[Status]string{
+		Passed:      "PASS",
+		Failed:      "FAIL",
+		Skipped:     "-",
+		Unsupported: "UNSUPPORTED",
+		"":          " ",
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString("| Scenario |")
+	for _, r := range reports {
+		buffer.WriteString(fmt.Sprintf(" %s |", r.Provider))
+	}
+	buffer.WriteString("\n|---|")
+	buffer.WriteString(strings.Repeat("---|", len(reports)))
+	buffer.WriteString("\n")
+	for _, s := range scenarios {
+		buffer.WriteString(fmt.Sprintf("| %s |", s))
+		for _, r := range reports {
+			buffer.WriteString(fmt.Sprintf(" %s |", symbols[results[r.Provider][s]]))
+		}
+		buffer.WriteString("\n")
+	}
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
@@ -0,0 +1,289 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//Span operation traced by a Tracer, identifiers follow the OpenTelemetry format
+type Span struct {
+	TraceID    string            `json:"trace_id"`
+	SpanID     string            `json:"span_id"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Name       string            `json:"name"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	//Error message of the error of the operation, empty if it succeeded
+	Error  string `json:"error,omitempty"`
+	tracer *Tracer
+}
+
+//SetAttribute sets an attribute of the span, s may be nil
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	s.Attributes[key] = value
+}
+
+//Finish ends the span and exports it, err is the error of the operation. s may be nil
+func (s *Span) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.End = time.Now()
+	if err != nil {
+		s.Error = err.Error()
+		s.Attributes["error.kind"] = api.KindName(err)
+	}
+	s.tracer.export(s)
+}
+
+//SpanExporter exports the finished spans
+type SpanExporter interface {
+	//Export exports spans
+	Export(spans []*Span) error
+	//Flush exports the spans buffered by the exporter
+	Flush() error
+}
+
+//spanKey key of the current span in a context
+type spanKey struct{}
+
+//SpanFromContext returns the current span of ctx, nil if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+//Tracer creates spans and exports them when they finish, spans are not created if the tracer has no exporter
+type Tracer struct {
+	mu       sync.RWMutex
+	exporter SpanExporter
+}
+
+//DefaultTracer tracer of the provider calls of the process, it has no exporter by default
+var DefaultTracer = &Tracer{}
+
+//SetExporter sets the exporter of the tracer, nil disables the tracing
+func (t *Tracer) SetExporter(e SpanExporter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.exporter = e
+}
+
+//Flush flushes the exporter of the tracer
+func (t *Tracer) Flush() error {
+	t.mu.RLock()
+	e := t.exporter
+	t.mu.RUnlock()
+	if e == nil {
+		return nil
+	}
+	return e.Flush()
+}
+
+//randomID returns a random identifier of n bytes in hexadecimal
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//Start starts a span named name, child of the current span of ctx. The returned context holds the new span
+//Start returns a nil span if the tracer has no exporter
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	t.mu.RLock()
+	e := t.exporter
+	t.mu.RUnlock()
+	if e == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		SpanID:     randomID(8),
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: map[string]string{},
+		tracer:     t,
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.TraceID = parent.TraceID
+		s.ParentID = parent.SpanID
+	} else {
+		s.TraceID = randomID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+//export exports a finished span, export errors are ignored to never fail the traced operation
+func (t *Tracer) export(s *Span) {
+	t.mu.RLock()
+	e := t.exporter
+	t.mu.RUnlock()
+	if e != nil {
+		e.Export([]*Span{s})
+	}
+}
+
+//JSONExporter writes the spans in JSON, one span per line
+type JSONExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+//NewJSONExporter creates an exporter writing the spans to w
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{w: w}
+}
+
+//Export writes spans
+func (e *JSONExporter) Export(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Flush does nothing, spans are written by Export
+func (e *JSONExporter) Flush() error {
+	return nil
+}
+
+//Settings of the OTLP exporter
+const (
+	//OTLPBatchSize number of buffered spans triggering an export
+	OTLPBatchSize = 64
+	//OTLPFlushInterval maximum time a span is buffered
+	OTLPFlushInterval = 5 * time.Second
+)
+
+//OTLPExporter exports the spans to an OpenTelemetry collector with the OTLP/HTTP JSON protocol
+//Spans are buffered and sent by batches of OTLPBatchSize spans or every OTLPFlushInterval
+type OTLPExporter struct {
+	//Endpoint base URL of the collector, spans are posted to Endpoint/v1/traces
+	Endpoint string
+	//ServiceName value of the service.name attribute of the resource
+	ServiceName string
+	//Client HTTP client used to post the spans
+	Client *http.Client
+
+	mu     sync.Mutex
+	spans  []*Span
+	ticker *time.Ticker
+}
+
+//NewOTLPExporter creates an exporter posting the spans to the collector at endpoint
+func NewOTLPExporter(endpoint string, serviceName string) *OTLPExporter {
+	e := &OTLPExporter{
+		Endpoint:    strings.TrimSuffix(endpoint, "/"),
+		ServiceName: serviceName,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		ticker:      time.NewTicker(OTLPFlushInterval),
+	}
+	go func() {
+		for range e.ticker.C {
+			e.Flush()
+		}
+	}()
+	return e
+}
+
+//Export buffers spans, the buffer is sent when it is full
+func (e *OTLPExporter) Export(spans []*Span) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, spans...)
+	full := len(e.spans) >= OTLPBatchSize
+	e.mu.Unlock()
+	if full {
+		return e.Flush()
+	}
+	return nil
+}
+
+//otlpAttributes converts attributes in OTLP key values
+func otlpAttributes(attrs map[string]string) []map[string]interface{} {
+	kvs := []map[string]interface{}{}
+	for k, v := range attrs {
+		kvs = append(kvs, map[string]interface{}{
+			"key":   k,
+			"value": map[string]string{"stringValue": v},
+		})
+	}
+	return kvs
+}
+
+//Flush sends the buffered spans
+func (e *OTLPExporter) Flush() error {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	list := []map[string]interface{}{}
+	for _, s := range spans {
+		span := map[string]interface{}{
+			"traceId":           s.TraceID,
+			"spanId":            s.SpanID,
+			"name":              s.Name,
+			"kind":              3, //SPAN_KIND_CLIENT
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.Attributes),
+			"status":            map[string]interface{}{"code": 1}, //STATUS_CODE_OK
+		}
+		if s.ParentID != "" {
+			span["parentSpanId"] = s.ParentID
+		}
+		if s.Error != "" {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.Error} //STATUS_CODE_ERROR
+		}
+		list = append(list, span)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]string{"service.name": e.ServiceName}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "github.com/SebastienDorgan/gpac/providers"},
+						"spans": list,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Error exporting spans: %s", err.Error())
+	}
+	resp, err := e.Client.Post(e.Endpoint+"/v1/traces", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Error exporting spans: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Error exporting spans: collector replied %s", resp.Status)
+	}
+	return nil
+}
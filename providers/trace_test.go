@@ -0,0 +1,133 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SebastienDorgan/gpac/providers/api"
+)
+
+//recordingExporter keeps the exported spans
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (e *recordingExporter) Export(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) Flush() error {
+	return nil
+}
+
+func TestTracerWithoutExporter(t *testing.T) {
+	ctx, span := (&Tracer{}).Start(context.Background(), "provider.ListVMs")
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatal("span created without exporter")
+	}
+	//a nil span can be used
+	span.SetAttribute("provider", "memory")
+	span.Finish(nil)
+}
+
+func TestTracer(t *testing.T) {
+	e := &recordingExporter{}
+	tracer := &Tracer{}
+	tracer.SetExporter(e)
+
+	ctx, parent := tracer.Start(context.Background(), "broker.CreateVM")
+	_, child := tracer.Start(ctx, "provider.CreateVM")
+	child.SetAttribute("provider", "memory")
+	child.Finish(api.NewError(api.ErrQuotaExceeded, nil, "Error creating VM"))
+	parent.Finish(nil)
+
+	if len(e.spans) != 2 || e.spans[0] != child || e.spans[1] != parent {
+		t.Fatalf("expected the child then the parent to be exported, got %+v", e.spans)
+	}
+	if len(parent.TraceID) != 32 || len(parent.SpanID) != 16 || parent.ParentID != "" {
+		t.Fatalf("unexpected root span %+v", parent)
+	}
+	if child.TraceID != parent.TraceID || child.ParentID != parent.SpanID || child.SpanID == parent.SpanID {
+		t.Fatalf("expected a child of %+v, got %+v", parent, child)
+	}
+	if child.Error != "Error creating VM" || child.Attributes["error.kind"] != "quota_exceeded" || child.Attributes["provider"] != "memory" {
+		t.Fatalf("unexpected span %+v", child)
+	}
+	if child.End.Before(child.Start) {
+		t.Fatalf("span ended before it started: %+v", child)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	bodies := make(chan map[string]interface{}, 1)
+	var status int32 = http.StatusOK
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("spans posted to %s", r.URL.Path)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		body := map[string]interface{}{}
+		json.Unmarshal(b, &body)
+		bodies <- body
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer collector.Close()
+
+	e := NewOTLPExporter(collector.URL+"/", "broker")
+	tracer := &Tracer{}
+	tracer.SetExporter(e)
+	ctx, parent := tracer.Start(context.Background(), "broker.DeleteVM")
+	_, child := tracer.Start(ctx, "provider.DeleteVM")
+	child.Finish(api.NewError(api.ErrNotFound, nil, "VM not found"))
+	parent.Finish(nil)
+	//the spans are buffered until the batch is full or the exporter is flushed
+	select {
+	case <-bodies:
+		t.Fatal("spans sent before the flush")
+	default:
+	}
+	err := tracer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := <-bodies
+	resource := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	spans := resource["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %+v", spans)
+	}
+	sent := spans[0].(map[string]interface{})
+	if sent["traceId"] != child.TraceID || sent["parentSpanId"] != parent.SpanID || sent["name"] != "provider.DeleteVM" {
+		t.Fatalf("unexpected span %+v", sent)
+	}
+	if s := sent["status"].(map[string]interface{}); s["code"] != 2.0 || s["message"] != "VM not found" {
+		t.Fatalf("expected an error status, got %+v", s)
+	}
+	if _, ok := spans[1].(map[string]interface{})["parentSpanId"]; ok {
+		t.Fatal("root span sent with a parent")
+	}
+
+	//a flush without span sends nothing, a rejected batch is reported
+	err = e.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&status, http.StatusBadRequest)
+	_, span := tracer.Start(context.Background(), "provider.ListVMs")
+	span.Finish(nil)
+	err = e.Flush()
+	<-bodies
+	if err == nil {
+		t.Fatal("rejected spans not reported")
+	}
+}
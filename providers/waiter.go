@@ -0,0 +1,136 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+//Default settings of the waiters
+const (
+	//DefaultMinDelay delay between the first two polls
+	DefaultMinDelay = 250 * time.Millisecond
+	//DefaultMaxDelay maximum delay between two polls
+	DefaultMaxDelay = 10 * time.Second
+	//DefaultJitter fraction of the delay randomly added or removed
+	DefaultJitter = 0.2
+	//DefaultMaxErrors number of consecutive transient errors tolerated
+	DefaultMaxErrors = 3
+)
+
+//Poll polls a resource, it returns true when the awaited condition is reached
+//status describes the current state of the resource, it is passed to the progress callback
+//Errors are transient unless they are PermanentError or StateError
+type Poll func(ctx context.Context) (done bool, status string, err error)
+
+//Progress is called after each poll with the number of the poll, the status of the resource and the error of the poll
+type Progress func(attempt int, status string, err error)
+
+//PermanentError error stopping a wait immediately, Wait returns the wrapped error
+type PermanentError struct {
+	Err error
+}
+
+func (e PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+//Unwrap returns the wrapped error
+func (e PermanentError) Unwrap() error {
+	return e.Err
+}
+
+//Permanent marks err as permanent, a wait fails immediately on a permanent error
+func Permanent(err error) error {
+	return PermanentError{Err: err}
+}
+
+//StateError error raised when a resource reaches a terminal error state while waiting for another state
+type StateError struct {
+	ResourceType string
+	ID           string
+	State        string
+}
+
+func (e StateError) Error() string {
+	return fmt.Sprintf("%s %s is in %s state", e.ResourceType, e.ID, e.State)
+}
+
+//Waiter polls a resource until a condition is reached
+//The delay between two polls starts at MinDelay and doubles after each poll up to MaxDelay, Jitter spreads the polls
+//of concurrent waiters. Up to MaxErrors consecutive transient errors are tolerated
+type Waiter struct {
+	MinDelay  time.Duration
+	MaxDelay  time.Duration
+	Jitter    float64
+	MaxErrors int
+	//Progress optional progress callback
+	Progress Progress
+}
+
+//NewWaiter creates a waiter with the default settings
+func NewWaiter() *Waiter {
+	return &Waiter{
+		MinDelay:  DefaultMinDelay,
+		MaxDelay:  DefaultMaxDelay,
+		Jitter:    DefaultJitter,
+		MaxErrors: DefaultMaxErrors,
+	}
+}
+
+//delay returns the delay following the poll attempt
+func (w *Waiter) delay(attempt int) time.Duration {
+	d := w.MinDelay
+	for i := 1; i < attempt && d < w.MaxDelay; i++ {
+		d *= 2
+	}
+	if w.MaxDelay > 0 && d > w.MaxDelay {
+		d = w.MaxDelay
+	}
+	if w.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + w.Jitter*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+//Wait polls until poll returns true, ctx is done, poll returns a permanent error or too many transient errors
+//The error of ctx is returned when it is done. Permanent and state errors are recognized even if they are wrapped
+func (w *Waiter) Wait(ctx context.Context, poll Poll) error {
+	failures := 0
+	for attempt := 1; ; attempt++ {
+		done, status, err := poll(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if w.Progress != nil {
+			w.Progress(attempt, status, err)
+		}
+		var permanent PermanentError
+		var state StateError
+		switch {
+		case err == nil:
+			failures = 0
+			if done {
+				return nil
+			}
+		case errors.As(err, &permanent):
+			return permanent.Err
+		case errors.As(err, &state):
+			return err
+		default:
+			failures++
+			if failures > w.MaxErrors {
+				return err
+			}
+		}
+		timer := time.NewTimer(w.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newTestWaiter() *Waiter {
+	w := NewWaiter()
+	w.MinDelay = time.Millisecond
+	w.MaxDelay = time.Millisecond
+	return w
+}
+
+func TestWaitTransientErrors(t *testing.T) {
+	polls := 0
+	err := newTestWaiter().Wait(context.Background(), func(ctx context.Context) (bool, string, error) {
+		polls++
+		return false, "", errors.New("transient")
+	})
+	if err == nil || polls != DefaultMaxErrors+1 {
+		t.Fatalf("expected the wait to fail after %d polls, got %d polls and %v", DefaultMaxErrors+1, polls, err)
+	}
+}
+
+func TestWaitWrappedPermanentError(t *testing.T) {
+	notFound := ResourceNotFoundError("VM", "vm1")
+	polls := 0
+	err := newTestWaiter().Wait(context.Background(), func(ctx context.Context) (bool, string, error) {
+		polls++
+		return false, "", fmt.Errorf("Error getting VM: %w", Permanent(notFound))
+	})
+	if polls != 1 {
+		t.Errorf("expected the wait to stop at the first poll, got %d polls", polls)
+	}
+	if err != notFound {
+		t.Errorf("expected the wrapped error, got %v", err)
+	}
+}
+
+func TestWaitWrappedStateError(t *testing.T) {
+	polls := 0
+	err := newTestWaiter().Wait(context.Background(), func(ctx context.Context) (bool, string, error) {
+		polls++
+		return false, "ERROR", fmt.Errorf("Error waiting for VM: %w", StateError{ResourceType: "VM", ID: "vm1", State: "ERROR"})
+	})
+	if polls != 1 {
+		t.Errorf("expected the wait to stop at the first poll, got %d polls", polls)
+	}
+	var state StateError
+	if !errors.As(err, &state) || state.ID != "vm1" {
+		t.Errorf("expected a state error, got %v", err)
+	}
+}
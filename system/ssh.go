@@ -94,7 +94,8 @@ func isTunnelReady(port int) bool {
 }
 
 //CreateTunnel create SSH from local host to remote host throw gateway
-func createTunnel(cfg *SSHConfig) (*sshTunnel, error) {
+//The tunnel is closed when ctx is done
+func createTunnel(ctx context.Context, cfg *SSHConfig) (*sshTunnel, error) {
 	f, err := createKeyFile(cfg.GatewayConfig.PrivateKey)
 	if err != nil {
 		return nil, err
@@ -115,14 +116,19 @@ func createTunnel(cfg *SSHConfig) (*sshTunnel, error) {
 		options,
 		cfg.GatewayConfig.Port,
 	)
-	cmd := exec.Command("sh", "-c", cmdString)
+	cmd := exec.CommandContext(ctx, "sh", "-c", cmdString)
 	err = cmd.Start()
 	//	err = cmd.Wait()
 	if err != nil {
 		return nil, err
 	}
 	for !isTunnelReady(freePort) {
-		time.Sleep(10 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			cmd.Process.Kill()
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
 	}
 	return &sshTunnel{
 		port:      freePort,
@@ -242,9 +248,9 @@ func (c *SSHCommand) end() error {
 	return nil
 }
 
-func recCreateTunnels(ssh *SSHConfig, tunnels *[]*sshTunnel) (*sshTunnel, error) {
+func recCreateTunnels(ctx context.Context, ssh *SSHConfig, tunnels *[]*sshTunnel) (*sshTunnel, error) {
 	if ssh != nil {
-		tunnel, err := recCreateTunnels(ssh.GatewayConfig, tunnels)
+		tunnel, err := recCreateTunnels(ctx, ssh.GatewayConfig, tunnels)
 		if err != nil {
 			return nil, err
 		}
@@ -256,7 +262,7 @@ func recCreateTunnels(ssh *SSHConfig, tunnels *[]*sshTunnel) (*sshTunnel, error)
 			cfg.GatewayConfig = &gateway
 		}
 		if cfg.GatewayConfig != nil {
-			tunnel, err = createTunnel(cfg)
+			tunnel, err = createTunnel(ctx, cfg)
 			if err != nil {
 				return nil, err
 			}
@@ -269,10 +275,13 @@ func recCreateTunnels(ssh *SSHConfig, tunnels *[]*sshTunnel) (*sshTunnel, error)
 
 }
 
-func (ssh *SSHConfig) createTunnels() ([]*sshTunnel, *SSHConfig, error) {
+func (ssh *SSHConfig) createTunnels(ctx context.Context) ([]*sshTunnel, *SSHConfig, error) {
 	var tunnels []*sshTunnel
-	tunnel, err := recCreateTunnels(ssh, &tunnels)
+	tunnel, err := recCreateTunnels(ctx, ssh, &tunnels)
 	if err != nil {
+		for _, t := range tunnels {
+			t.Close()
+		}
 		if err != nil {
 			return nil, nil, fmt.Errorf("Unable to create SSH Tunnels : %s", err.Error())
 		}
@@ -325,7 +334,7 @@ func createSSHCmd(sshConfig *SSHConfig, cmdString string) (string, *os.File, err
 
 // Command returns the Cmd struct to execute cmdString remotely
 func (ssh *SSHConfig) Command(cmdString string) (*SSHCommand, error) {
-	tunnels, sshConfig, err := ssh.createTunnels()
+	tunnels, sshConfig, err := ssh.createTunnels(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
 	}
@@ -344,7 +353,7 @@ func (ssh *SSHConfig) Command(cmdString string) (*SSHCommand, error) {
 
 //Exec executes the cmd using ssh
 func (ssh *SSHConfig) Exec(cmdString string) error {
-	tunnels, sshConfig, err := ssh.createTunnels()
+	tunnels, sshConfig, err := ssh.createTunnels(context.Background())
 	if err != nil {
 		for _, t := range tunnels {
 			t.Close()
@@ -385,14 +394,18 @@ func (ssh *SSHConfig) Exec(cmdString string) error {
 //
 // The provided context is used to kill the process (by calling
 // os.Process.Kill) if the context becomes done before the command
-// completes on its own.
+// completes on its own. The context also bounds the creation of the SSH tunnels
+// through the gateways and closes them when it becomes done.
 func (ssh *SSHConfig) CommandContext(ctx context.Context, cmdString string) (*SSHCommand, error) {
-	tunnels, sshConfig, err := ssh.createTunnels()
+	tunnels, sshConfig, err := ssh.createTunnels(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
 	}
 	sshCmdString, keyFile, err := createSSHCmd(sshConfig, cmdString)
 	if err != nil {
+		for _, t := range tunnels {
+			t.Close()
+		}
 		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
 	}
 
@@ -405,6 +418,32 @@ func (ssh *SSHConfig) CommandContext(ctx context.Context, cmdString string) (*SS
 	return &sshCommand, nil
 }
 
+//CommandWithInputContext is like CommandContext but cmdString is passed on the command line of ssh, the standard
+//input of the command is left to the caller (see StdinPipe) to stream contents of any size
+//cmdString is limited by the maximum length of a command line and must be short
+func (ssh *SSHConfig) CommandWithInputContext(ctx context.Context, cmdString string) (*SSHCommand, error) {
+	tunnels, sshConfig, err := ssh.createTunnels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
+	}
+	sshCmdString, keyFile, err := createSSHCmd(sshConfig, "")
+	if err != nil {
+		for _, t := range tunnels {
+			t.Close()
+		}
+		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
+	}
+	//the remote shell runs the argument of ssh
+	sshCmdString += " '" + strings.Replace(cmdString, "'", `'"'"'`, -1) + "'"
+	cmd := exec.CommandContext(ctx, "bash", "-c", sshCmdString)
+	sshCommand := SSHCommand{
+		cmd:     cmd,
+		tunnels: tunnels,
+		keyFile: keyFile,
+	}
+	return &sshCommand, nil
+}
+
 //CreateKeyPair creates a key pair
 func CreateKeyPair() (publicKeyBytes []byte, privateKeyBytes []byte, err error) {
 	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)